@@ -31,6 +31,9 @@ _alert_queue = Queue(maxsize=50)
 _worker_running = False
 _worker_thread = None
 
+# Optional schema-version-2 telemetry groups accepted by /api/devices/:id/data
+TELEMETRY_V2_FIELDS = ("mouth", "head_pose", "gaze", "face_detected", "gps", "extra")
+
 # Setup logging
 logging.basicConfig(level=logging.INFO)
 logger = logging.getLogger(__name__)
@@ -136,6 +139,14 @@ def _send_data_sync(data):
             "status": data.get("status", "NORMAL"),
             "timestamp": data.get("timestamp", datetime.now(timezone.utc).isoformat()),
         }
+        if "eye_closure" in data:
+            payload["eye_closure"] = data["eye_closure"]
+
+        # Extended telemetry (schema version 2): send only the groups the detector measured
+        extended = {k: data[k] for k in TELEMETRY_V2_FIELDS if data.get(k) is not None}
+        if extended:
+            payload["schema_version"] = 2
+            payload.update(extended)
 
         response = requests.post(
             f"{BACKEND_URL}/api/devices/{DEVICE_ID}/data",
@@ -0,0 +1,181 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+
+	"driver-drowsiness-backend/models"
+)
+
+// Fleets returns every fleet
+func (c *Client) Fleets(ctx context.Context) ([]models.Fleet, error) {
+	var r struct {
+		Fleets []models.Fleet `json:"fleets"`
+	}
+	if err := c.call(ctx, request{method: http.MethodGet, path: "/admin/fleets"}, &r); err != nil {
+		return nil, err
+	}
+	return r.Fleets, nil
+}
+
+// CreateFleet creates a fleet
+func (c *Client) CreateFleet(ctx context.Context, req models.FleetRequest) (*models.Fleet, error) {
+	var f models.Fleet
+	if err := c.call(ctx, request{method: http.MethodPost, path: "/admin/fleets", json: req}, &f); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// Vehicles returns every vehicle with the device installed now
+func (c *Client) Vehicles(ctx context.Context) ([]models.Vehicle, error) {
+	var r struct {
+		Vehicles []models.Vehicle `json:"vehicles"`
+	}
+	if err := c.call(ctx, request{method: http.MethodGet, path: "/admin/vehicles"}, &r); err != nil {
+		return nil, err
+	}
+	return r.Vehicles, nil
+}
+
+// CreateVehicle creates a vehicle
+func (c *Client) CreateVehicle(ctx context.Context, req models.VehicleRequest) (*models.Vehicle, error) {
+	var v models.Vehicle
+	if err := c.call(ctx, request{method: http.MethodPost, path: "/admin/vehicles", json: req}, &v); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// InstallDevice mounts a device in a vehicle
+func (c *Client) InstallDevice(ctx context.Context, vehicleID int, req models.InstallRequest) (*models.DeviceInstallation, error) {
+	var inst models.DeviceInstallation
+	if err := c.call(ctx, request{method: http.MethodPost, path: path("admin", "vehicles", vehicleID, "devices"), json: req}, &inst); err != nil {
+		return nil, err
+	}
+	return &inst, nil
+}
+
+// CreateShift schedules a shift; times without an offset are read in tz
+func (c *Client) CreateShift(ctx context.Context, req models.ShiftRequest, tz string) (*models.Shift, error) {
+	var q url.Values
+	if tz != "" {
+		q = url.Values{"tz": {tz}}
+	}
+	var s models.Shift
+	if err := c.call(ctx, request{method: http.MethodPost, path: "/admin/shifts", query: q, json: req}, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// EndShift ends a shift now
+func (c *Client) EndShift(ctx context.Context, id int) (*models.Shift, error) {
+	var s models.Shift
+	if err := c.call(ctx, request{method: http.MethodPost, path: path("admin", "shifts", id, "end")}, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// DeviceConfig returns the desired and reported configuration of a device
+func (c *Client) DeviceConfig(ctx context.Context, deviceID string) (*models.DeviceShadow, error) {
+	var shadow models.DeviceShadow
+	if err := c.call(ctx, request{method: http.MethodGet, path: path("admin", "devices", deviceID, "config")}, &shadow); err != nil {
+		return nil, err
+	}
+	return &shadow, nil
+}
+
+// UpdateDeviceConfig changes settings of the desired configuration of a device
+func (c *Client) UpdateDeviceConfig(ctx context.Context, deviceID string, req models.DeviceConfigRequest) (*models.DeviceShadow, error) {
+	var shadow models.DeviceShadow
+	if err := c.call(ctx, request{method: http.MethodPut, path: path("admin", "devices", deviceID, "config"), json: req}, &shadow); err != nil {
+		return nil, err
+	}
+	return &shadow, nil
+}
+
+// EnqueueCommand queues a remote command for a device
+func (c *Client) EnqueueCommand(ctx context.Context, deviceID string, req models.CommandRequest) (*models.DeviceCommand, error) {
+	var cmd models.DeviceCommand
+	if err := c.call(ctx, request{method: http.MethodPost, path: path("admin", "devices", deviceID, "commands"), json: req}, &cmd); err != nil {
+		return nil, err
+	}
+	return &cmd, nil
+}
+
+// Command returns a command with its audit trail
+func (c *Client) Command(ctx context.Context, id int) (*models.DeviceCommand, []models.CommandEvent, error) {
+	var r struct {
+		Command models.DeviceCommand  `json:"command"`
+		Events  []models.CommandEvent `json:"events"`
+	}
+	if err := c.call(ctx, request{method: http.MethodGet, path: path("admin", "commands", id)}, &r); err != nil {
+		return nil, nil, err
+	}
+	return &r.Command, r.Events, nil
+}
+
+// CancelCommand withdraws a command the device has not acknowledged
+func (c *Client) CancelCommand(ctx context.Context, id int) (*models.DeviceCommand, error) {
+	var cmd models.DeviceCommand
+	if err := c.call(ctx, request{method: http.MethodPost, path: path("admin", "commands", id, "cancel")}, &cmd); err != nil {
+		return nil, err
+	}
+	return &cmd, nil
+}
+
+// CreateReport generates the PDF safety report of a fleet or driver
+func (c *Client) CreateReport(ctx context.Context, req models.ReportRequest) (*models.Report, error) {
+	var rep models.Report
+	if err := c.call(ctx, request{method: http.MethodPost, path: "/admin/reports", json: req}, &rep); err != nil {
+		return nil, err
+	}
+	return &rep, nil
+}
+
+// DownloadReport returns the PDF of a ready report; the caller closes it
+func (c *Client) DownloadReport(ctx context.Context, id int) (io.ReadCloser, error) {
+	resp, err := c.send(ctx, request{method: http.MethodGet, path: path("admin", "reports", id, "pdf")})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Export streams the "history" or "alerts" of a driver, device or fleet as
+// CSV or XLSX; the caller closes it. Query: format, lang, fleet_id,
+// driver_id and device_id.
+func (c *Client) Export(ctx context.Context, kind string, r Range, q url.Values) (io.ReadCloser, error) {
+	resp, err := c.send(ctx, request{method: http.MethodGet, path: path("admin", "export", kind), query: r.values(q)})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// CreateSubscription subscribes to a digest
+func (c *Client) CreateSubscription(ctx context.Context, req models.SubscriptionRequest) (*models.Subscription, error) {
+	var sub models.Subscription
+	if err := c.call(ctx, request{method: http.MethodPost, path: "/admin/subscriptions", json: req}, &sub); err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+// DeleteSubscription unsubscribes
+func (c *Client) DeleteSubscription(ctx context.Context, id int) error {
+	return c.call(ctx, request{method: http.MethodDelete, path: path("admin", "subscriptions", id)}, nil)
+}
+
+// APIUsage returns the requests of every API version since the server started
+func (c *Client) APIUsage(ctx context.Context) (*models.APIUsage, error) {
+	var u models.APIUsage
+	if err := c.call(ctx, request{method: http.MethodGet, path: "/admin/api-usage"}, &u); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
@@ -0,0 +1,193 @@
+// Package client is a typed Go client of the HTTP API for integration
+// services and device simulators. It speaks API v2 and is kept in step
+// with the OpenAPI document served at /api/v2/openapi.json; its tests check
+// every request it sends against that document.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"driver-drowsiness-backend/models"
+)
+
+// Root is the path of the API version the client speaks
+const Root = "/api/v2"
+
+// Client calls the API at BaseURL
+type Client struct {
+	BaseURL string       // e.g. https://api.example.com, without a trailing /api
+	Token   string       // bearer token of admin and account routes; set by Login and Register
+	Lang    string       // Accept-Language of error messages, e.g. "en"; the API defaults to Thai, or English for unsupported languages
+	HTTP    *http.Client // http.DefaultClient when nil
+}
+
+// New returns a client of the API at baseURL
+func New(baseURL string) *Client {
+	return &Client{BaseURL: strings.TrimRight(baseURL, "/")}
+}
+
+// Error is an error answer of the API
+type Error struct {
+	Status int // HTTP status
+	models.APIError
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("api: %d %s: %s", e.Status, e.Code, e.Message)
+}
+
+// request describes one call of the API
+type request struct {
+	method      string
+	path        string // relative to Root, with path parameters escaped
+	query       url.Values
+	body        io.Reader // sent as is when set, else json is encoded
+	contentType string
+	json        interface{}
+	header      http.Header
+}
+
+// send performs r and returns the response of a 2xx status; other statuses
+// are returned as *Error
+func (c *Client) send(ctx context.Context, r request) (*http.Response, error) {
+	body := r.body
+	contentType := r.contentType
+	if body == nil && r.json != nil {
+		b, err := json.Marshal(r.json)
+		if err != nil {
+			return nil, err
+		}
+		body, contentType = bytes.NewReader(b), "application/json"
+	}
+	u := c.BaseURL + Root + r.path
+	if len(r.query) > 0 {
+		u += "?" + r.query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, r.method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range r.header {
+		req.Header[k] = v
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	if c.Lang != "" {
+		req.Header.Set("Accept-Language", c.Lang)
+	}
+	hc := c.HTTP
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	apiErr := &Error{Status: resp.StatusCode}
+	var envelope models.ErrorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err == nil && envelope.Error.Code != "" {
+		apiErr.APIError = envelope.Error
+	} else {
+		apiErr.Message = http.StatusText(resp.StatusCode)
+	}
+	return nil, apiErr
+}
+
+// call performs r and decodes the JSON answer into out, when not nil
+func (c *Client) call(ctx context.Context, r request, out interface{}) error {
+	resp, err := c.send(ctx, r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// path joins segments, escaping each
+func path(segments ...interface{}) string {
+	var b strings.Builder
+	for _, s := range segments {
+		b.WriteByte('/')
+		b.WriteString(url.PathEscape(fmt.Sprint(s)))
+	}
+	return b.String()
+}
+
+// Range bounds the items a list returns; zero times leave a bound to the server
+type Range struct {
+	From, To time.Time
+	TZ       string // IANA timezone of day boundaries
+}
+
+func (r Range) values(q url.Values) url.Values {
+	if q == nil {
+		q = url.Values{}
+	}
+	if !r.From.IsZero() {
+		q.Set("from", r.From.Format(time.RFC3339))
+	}
+	if !r.To.IsZero() {
+		q.Set("to", r.To.Format(time.RFC3339))
+	}
+	if r.TZ != "" {
+		q.Set("tz", r.TZ)
+	}
+	return q
+}
+
+// PageQuery selects a page of the history or alerts of a device
+type PageQuery struct {
+	Range
+	Limit  int
+	Cursor string // from Cursor(page.Next) or Cursor(page.Prev)
+	Level  string // history only
+	Status string
+	// Severity filters alerts only
+	Severity string
+}
+
+func (p PageQuery) values() url.Values {
+	q := p.Range.values(nil)
+	if p.Limit > 0 {
+		q.Set("limit", fmt.Sprint(p.Limit))
+	}
+	for name, v := range map[string]string{"cursor": p.Cursor, "level": p.Level, "status": p.Status, "severity": p.Severity} {
+		if v != "" {
+			q.Set(name, v)
+		}
+	}
+	return q
+}
+
+// Cursor returns the cursor of a next or prev link, "" for none
+func Cursor(link *string) string {
+	if link == nil {
+		return ""
+	}
+	u, err := url.Parse(*link)
+	if err != nil {
+		return ""
+	}
+	return u.Query().Get("cursor")
+}
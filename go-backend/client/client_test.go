@@ -0,0 +1,213 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"driver-drowsiness-backend/config"
+	"driver-drowsiness-backend/handlers"
+	"driver-drowsiness-backend/models"
+	"driver-drowsiness-backend/openapi"
+	"driver-drowsiness-backend/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// recorder remembers the method and path of every request it carries
+type recorder struct {
+	mu   sync.Mutex
+	sent []string
+}
+
+func (r *recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.mu.Lock()
+	r.sent = append(r.sent, req.Method+" "+req.URL.Path)
+	r.mu.Unlock()
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	server := handlers.NewServer(repository.NewMemoryStore(), &config.Config{
+		JWTSecret:  "test-secret",
+		Timezone:   "UTC",
+		Location:   time.UTC,
+		StorageDir: t.TempDir(),
+		UploadDir:  t.TempDir(),
+	})
+	router := gin.New()
+	server.RegisterRoutes(router)
+	ts := httptest.NewServer(router)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestClient(t *testing.T) {
+	ts := newTestServer(t)
+	rec := &recorder{}
+	ctx := context.Background()
+
+	// A device simulator needs no account
+	device := New(ts.URL + "/")
+	device.HTTP = &http.Client{Transport: rec}
+	for i, level := range []string{"low", "medium", "high"} {
+		r, err := device.SendData(ctx, "sim 01", models.DataPayloadV2{EyeClosure: float64(i) / 4, DrowsinessLevel: level})
+		if err != nil || r.DeviceID != "sim 01" {
+			t.Fatalf("SendData: %+v, %v", r, err)
+		}
+	}
+	alertID, err := device.SendAlert(ctx, "sim 01", models.AlertPayload{AlertType: "drowsiness", Severity: "high"})
+	if err != nil || alertID == 0 {
+		t.Fatalf("SendAlert: %d, %v", alertID, err)
+	}
+	e, err := device.UploadEvidence(ctx, "sim 01", alertID, "frame.jpg", strings.NewReader("\xff\xd8\xff\xe0 not really a jpeg"))
+	if err != nil || e.AlertID != alertID {
+		t.Fatalf("UploadEvidence: %+v, %v", e, err)
+	}
+
+	// Errors carry the envelope of the API
+	device.Lang = "en"
+	_, err = device.SendData(ctx, "sim 01", models.DataPayloadV2{EyeClosure: 2, DrowsinessLevel: "sleepy"})
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusBadRequest || apiErr.Code != models.CodeValidation || len(apiErr.Details) != 2 {
+		t.Fatalf("validation error = %v", err)
+	}
+	if _, err = device.Latest(ctx, "unknown"); !errors.As(err, &apiErr) || apiErr.Code != models.CodeNotFound || apiErr.RequestID == "" {
+		t.Errorf("not found = %v", err)
+	}
+
+	// Pages follow the cursors of their links
+	page, err := device.History(ctx, "sim 01", PageQuery{Limit: 2})
+	if err != nil || page.Count != 2 || page.Items[0].DrowsinessLevel != "high" || page.Next == nil {
+		t.Fatalf("History: %+v, %v", page, err)
+	}
+	page, err = device.History(ctx, "sim 01", PageQuery{Limit: 2, Cursor: Cursor(page.Next)})
+	if err != nil || page.Count != 1 || page.Items[0].DrowsinessLevel != "low" || page.Next != nil {
+		t.Fatalf("History next: %+v, %v", page, err)
+	}
+	alerts, err := device.Alerts(ctx, "sim 01", PageQuery{Severity: "high"})
+	if err != nil || alerts.Count != 1 {
+		t.Errorf("Alerts: %+v, %v", alerts, err)
+	}
+
+	// Admin calls need a token
+	admin := New(ts.URL)
+	admin.HTTP = device.HTTP
+	if _, err := admin.Fleets(ctx); !errors.As(err, &apiErr) || apiErr.Status != http.StatusUnauthorized {
+		t.Fatalf("anonymous Fleets = %v", err)
+	}
+	if _, err := admin.Register(ctx, models.RegisterRequest{Email: "ops@example.com", Password: "secret123", Name: "Ops"}); err != nil {
+		t.Fatal(err)
+	}
+	if me, err := admin.Me(ctx); err != nil || me.Email != "ops@example.com" {
+		t.Fatalf("Me: %+v, %v", me, err)
+	}
+	// A self-registered driver is no admin
+	if _, err := admin.Fleets(ctx); !errors.As(err, &apiErr) || apiErr.Status != http.StatusForbidden {
+		t.Fatalf("driver Fleets = %v", err)
+	}
+	seed := `{"email":"admin@example.com","password":"secret123","name":"Admin","secret":"drowsiness-admin-setup-2026"}`
+	if resp, err := http.Post(ts.URL+Root+"/seed/admin", "application/json", strings.NewReader(seed)); err != nil || resp.StatusCode != http.StatusCreated {
+		t.Fatalf("seed admin: %+v, %v", resp, err)
+	}
+	if _, err := admin.Login(ctx, "admin@example.com", "secret123"); err != nil || admin.Token == "" {
+		t.Fatalf("Login: %v", err)
+	}
+	fleet, err := admin.CreateFleet(ctx, models.FleetRequest{Name: "North", Timezone: "Asia/Bangkok"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vehicle, err := admin.CreateVehicle(ctx, models.VehicleRequest{PlateNumber: "1กข 1234", FleetID: fleet.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := admin.InstallDevice(ctx, vehicle.ID, models.InstallRequest{DeviceID: "sim 01"}); err != nil {
+		t.Fatal(err)
+	}
+	if vehicles, err := admin.Vehicles(ctx); err != nil || len(vehicles) != 1 || vehicles[0].DeviceID != "sim 01" {
+		t.Errorf("Vehicles: %+v, %v", vehicles, err)
+	}
+
+	// Remote configuration and commands round-trip through the device
+	volume := 30
+	if _, err := admin.UpdateDeviceConfig(ctx, "sim 01", models.DeviceConfigRequest{AlarmVolume: &volume}); err != nil {
+		t.Fatal(err)
+	}
+	cfg, version, err := device.Config(ctx, "sim 01")
+	if err != nil || cfg.AlarmVolume != 30 {
+		t.Fatalf("Config: %+v, %v", cfg, err)
+	}
+	if shadow, err := device.ReportConfig(ctx, "sim 01", models.ConfigReport{Version: version, Config: *cfg}); err != nil || shadow.Sync != models.ShadowInSync {
+		t.Errorf("ReportConfig: %+v, %v", shadow, err)
+	}
+	cmd, err := admin.EnqueueCommand(ctx, "sim 01", models.CommandRequest{Type: models.CommandAlarm, DurationSeconds: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending, err := device.PollCommands(ctx, "sim 01", 0)
+	if err != nil || len(pending) != 1 || pending[0].ID != cmd.ID {
+		t.Fatalf("PollCommands: %+v, %v", pending, err)
+	}
+	if _, err := device.AckCommand(ctx, "sim 01", cmd.ID, models.CommandAck{Status: models.CommandSucceeded}); err != nil {
+		t.Fatal(err)
+	}
+	if got, events, err := admin.Command(ctx, cmd.ID); err != nil || got.Status != models.CommandSucceeded || len(events) < 2 {
+		t.Errorf("Command: %+v %+v, %v", got, events, err)
+	}
+	fps := 24.0
+	if hb, err := device.Heartbeat(ctx, "sim 01", models.HeartbeatPayload{CameraStatus: "ok", FPS: &fps, ConfigVersion: &version}); err != nil || hb.ConfigVersion != version {
+		t.Errorf("Heartbeat: %+v, %v", hb, err)
+	}
+
+	export, err := admin.Export(ctx, "history", Range{TZ: "UTC"}, url.Values{"format": {"csv"}, "device_id": {"sim 01"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(export)
+	export.Close()
+	if strings.Count(string(body), "\n") != 4 {
+		t.Errorf("export = %q", body)
+	}
+	if err := admin.DeleteSubscription(ctx, 999); !errors.As(err, &apiErr) || apiErr.Status != http.StatusNotFound {
+		t.Errorf("DeleteSubscription = %v", err)
+	}
+	if u, err := admin.APIUsage(ctx); err != nil || len(u.Versions) != 1 || u.Versions[0].Devices["sim 01"] == 0 {
+		t.Errorf("APIUsage: %+v, %v", u, err)
+	}
+
+	// Every request the client sent is an operation of the OpenAPI document
+	resp, err := http.Get(ts.URL + Root + "/openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var doc openapi.Document
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	param := regexp.MustCompile(`\\\{[^}]+\\\}`)
+	var ops []*regexp.Regexp
+	for _, op := range doc.Operations() {
+		ops = append(ops, regexp.MustCompile("^"+param.ReplaceAllString(regexp.QuoteMeta(op), "[^/]+")+"$"))
+	}
+	for _, sent := range rec.sent {
+		found := false
+		for _, op := range ops {
+			found = found || op.MatchString(sent)
+		}
+		if !found {
+			t.Errorf("%s is not in the OpenAPI document", sent)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"driver-drowsiness-backend/models"
+)
+
+// Register creates an account and keeps its token
+func (c *Client) Register(ctx context.Context, req models.RegisterRequest) (*models.AuthResponse, error) {
+	var resp models.AuthResponse
+	if err := c.call(ctx, request{method: http.MethodPost, path: "/auth/register", json: req}, &resp); err != nil {
+		return nil, err
+	}
+	c.Token = resp.Token
+	return &resp, nil
+}
+
+// Login signs in and keeps the token
+func (c *Client) Login(ctx context.Context, email, password string) (*models.AuthResponse, error) {
+	var resp models.AuthResponse
+	req := models.LoginRequest{Email: email, Password: password}
+	if err := c.call(ctx, request{method: http.MethodPost, path: "/auth/login", json: req}, &resp); err != nil {
+		return nil, err
+	}
+	c.Token = resp.Token
+	return &resp, nil
+}
+
+// Me returns the signed-in user
+func (c *Client) Me(ctx context.Context) (*models.Profile, error) {
+	var p models.Profile
+	if err := c.call(ctx, request{method: http.MethodGet, path: "/auth/me"}, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// DataResult is the answer to a drowsiness sample
+type DataResult struct {
+	DeviceID     string                   `json:"device_id"`
+	FatigueScore float64                  `json:"fatigue_score"`
+	FatigueLevel string                   `json:"fatigue_level"`
+	Compliance   *models.ComplianceStatus `json:"compliance,omitempty"` // driving time of the current driver
+}
+
+// SendData reports a drowsiness sample of a device
+func (c *Client) SendData(ctx context.Context, deviceID string, data models.DataPayloadV2) (*DataResult, error) {
+	var r DataResult
+	if err := c.call(ctx, request{method: http.MethodPost, path: path("devices", deviceID, "data"), json: data}, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// SendAlert reports an alert of a device and returns its id
+func (c *Client) SendAlert(ctx context.Context, deviceID string, alert models.AlertPayload) (int, error) {
+	var r struct {
+		AlertID int `json:"alert_id"`
+	}
+	if err := c.call(ctx, request{method: http.MethodPost, path: path("devices", deviceID, "alert"), json: alert}, &r); err != nil {
+		return 0, err
+	}
+	return r.AlertID, nil
+}
+
+// HeartbeatResult is the answer to a heartbeat
+type HeartbeatResult struct {
+	Health        models.DeviceHealth    `json:"health"`
+	ConfigVersion int                    `json:"config_version"`
+	Config        *models.DeviceConfig   `json:"config,omitempty"` // set when the device runs an older version
+	Commands      []models.DeviceCommand `json:"commands,omitempty"`
+}
+
+// Heartbeat reports the health of a device
+func (c *Client) Heartbeat(ctx context.Context, deviceID string, hb models.HeartbeatPayload) (*HeartbeatResult, error) {
+	var r HeartbeatResult
+	if err := c.call(ctx, request{method: http.MethodPost, path: path("devices", deviceID, "heartbeat"), json: hb}, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// Config returns the desired configuration of a device and its version
+func (c *Client) Config(ctx context.Context, deviceID string) (*models.DeviceConfig, int, error) {
+	var r struct {
+		Version int                 `json:"version"`
+		Config  models.DeviceConfig `json:"config"`
+	}
+	if err := c.call(ctx, request{method: http.MethodGet, path: path("devices", deviceID, "config")}, &r); err != nil {
+		return nil, 0, err
+	}
+	return &r.Config, r.Version, nil
+}
+
+// ReportConfig records the configuration a device applied
+func (c *Client) ReportConfig(ctx context.Context, deviceID string, report models.ConfigReport) (*models.DeviceShadow, error) {
+	var shadow models.DeviceShadow
+	if err := c.call(ctx, request{method: http.MethodPost, path: path("devices", deviceID, "config", "reported"), json: report}, &shadow); err != nil {
+		return nil, err
+	}
+	return &shadow, nil
+}
+
+// PollCommands returns the pending commands of a device, waiting up to
+// wait (whole seconds, at most a minute) for one to arrive
+func (c *Client) PollCommands(ctx context.Context, deviceID string, wait time.Duration) ([]models.DeviceCommand, error) {
+	q := url.Values{"wait": {strconv.Itoa(int(wait / time.Second))}}
+	var r struct {
+		Commands []models.DeviceCommand `json:"commands"`
+	}
+	if err := c.call(ctx, request{method: http.MethodGet, path: path("devices", deviceID, "commands"), query: q}, &r); err != nil {
+		return nil, err
+	}
+	return r.Commands, nil
+}
+
+// AckCommand reports whether a device carried out a command
+func (c *Client) AckCommand(ctx context.Context, deviceID string, commandID int, ack models.CommandAck) (*models.DeviceCommand, error) {
+	var cmd models.DeviceCommand
+	if err := c.call(ctx, request{method: http.MethodPost, path: path("devices", deviceID, "commands", commandID, "ack"), json: ack}, &cmd); err != nil {
+		return nil, err
+	}
+	return &cmd, nil
+}
+
+// StartSession starts a driving session of a device
+func (c *Client) StartSession(ctx context.Context, deviceID string, event models.SessionEventPayload) (*models.DrivingSession, error) {
+	var s models.DrivingSession
+	if err := c.call(ctx, request{method: http.MethodPost, path: path("devices", deviceID, "sessions", "start"), json: event}, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// StopSession stops the open driving session of a device
+func (c *Client) StopSession(ctx context.Context, deviceID string, event models.SessionEventPayload) (*models.DrivingSession, error) {
+	var s models.DrivingSession
+	if err := c.call(ctx, request{method: http.MethodPost, path: path("devices", deviceID, "sessions", "stop"), json: event}, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// UploadEvidence uploads the evidence file of an alert in one request; its
+// content type follows the extension of filename, e.g. .jpg or .mp4
+func (c *Client) UploadEvidence(ctx context.Context, deviceID string, alertID int, filename string, file io.Reader) (*models.Evidence, error) {
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename=%q`, filepath.Base(filename)))
+	header.Set("Content-Type", mime.TypeByExtension(filepath.Ext(filename)))
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		part, err := mw.CreatePart(header)
+		if err == nil {
+			_, err = io.Copy(part, file)
+		}
+		if err == nil {
+			err = mw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	var e models.Evidence
+	err := c.call(ctx, request{
+		method: http.MethodPost, path: path("devices", deviceID, "alerts", alertID, "evidence"),
+		body: pr, contentType: mw.FormDataContentType(),
+	}, &e)
+	pr.Close()
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// Devices returns every device
+func (c *Client) Devices(ctx context.Context) ([]models.Device, error) {
+	var r struct {
+		Devices []models.Device `json:"devices"`
+	}
+	if err := c.call(ctx, request{method: http.MethodGet, path: "/devices"}, &r); err != nil {
+		return nil, err
+	}
+	return r.Devices, nil
+}
+
+// Latest returns the latest sample of a device
+func (c *Client) Latest(ctx context.Context, deviceID string) (*models.DrowsinessData, error) {
+	var d models.DrowsinessData
+	if err := c.call(ctx, request{method: http.MethodGet, path: path("devices", deviceID, "data")}, &d); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// HistoryPage is a page of samples, newest first
+type HistoryPage struct {
+	DeviceID string                  `json:"device_id"`
+	Count    int                     `json:"count"`
+	Items    []models.DrowsinessData `json:"items"`
+	Next     *string                 `json:"next"` // link of older samples
+	Prev     *string                 `json:"prev"` // link of newer samples
+}
+
+// History returns a page of the samples of a device
+func (c *Client) History(ctx context.Context, deviceID string, q PageQuery) (*HistoryPage, error) {
+	var p HistoryPage
+	if err := c.call(ctx, request{method: http.MethodGet, path: path("devices", deviceID, "history"), query: q.values()}, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// AlertPage is a page of alerts, newest first
+type AlertPage struct {
+	DeviceID string         `json:"device_id"`
+	Count    int            `json:"count"`
+	Items    []models.Alert `json:"items"`
+	Next     *string        `json:"next"`
+	Prev     *string        `json:"prev"`
+}
+
+// Alerts returns a page of the alerts of a device
+func (c *Client) Alerts(ctx context.Context, deviceID string, q PageQuery) (*AlertPage, error) {
+	var p AlertPage
+	if err := c.call(ctx, request{method: http.MethodGet, path: path("devices", deviceID, "alerts"), query: q.values()}, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Fatigue returns the live fatigue metrics of a device
+func (c *Client) Fatigue(ctx context.Context, deviceID string) (*models.FatigueMetrics, error) {
+	var r struct {
+		Fatigue models.FatigueMetrics `json:"fatigue"`
+	}
+	if err := c.call(ctx, request{method: http.MethodGet, path: path("devices", deviceID, "fatigue")}, &r); err != nil {
+		return nil, err
+	}
+	return &r.Fatigue, nil
+}
+
+// Health returns the last heartbeat and state of a device
+func (c *Client) Health(ctx context.Context, deviceID string) (*models.DeviceHealth, error) {
+	var h models.DeviceHealth
+	if err := c.call(ctx, request{method: http.MethodGet, path: path("devices", deviceID, "health")}, &h); err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+// Compliance returns the driving time of the current driver of a device
+func (c *Client) Compliance(ctx context.Context, deviceID string) (*models.ComplianceStatus, error) {
+	var s models.ComplianceStatus
+	if err := c.call(ctx, request{method: http.MethodGet, path: path("devices", deviceID, "compliance")}, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Sessions returns the driving sessions of a device, newest first
+func (c *Client) Sessions(ctx context.Context, deviceID string, r Range, limit int) ([]models.DrivingSession, error) {
+	q := r.values(nil)
+	if limit > 0 {
+		q.Set("limit", fmt.Sprint(limit))
+	}
+	var resp struct {
+		Sessions []models.DrivingSession `json:"sessions"`
+	}
+	if err := c.call(ctx, request{method: http.MethodGet, path: path("devices", deviceID, "sessions"), query: q}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Sessions, nil
+}
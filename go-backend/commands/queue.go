@@ -0,0 +1,89 @@
+// Package commands delivers remote commands to devices through long polling
+package commands
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"driver-drowsiness-backend/models"
+	"driver-drowsiness-backend/repository"
+)
+
+// RedeliverAfter is how long a delivered command waits for its
+// acknowledgement before it is handed to the device again
+const RedeliverAfter = 2 * time.Minute
+
+// Queue hands queued commands to devices and wakes their long polls
+type Queue struct {
+	repo repository.CommandRepository
+
+	mu      sync.Mutex
+	waiters map[string]chan struct{} // closed when a command is queued for the device
+}
+
+// NewQueue creates a Queue on top of stored commands
+func NewQueue(repo repository.CommandRepository) *Queue {
+	return &Queue{repo: repo, waiters: make(map[string]chan struct{})}
+}
+
+// waiter returns the channel closed on the next command for a device
+func (q *Queue) waiter(deviceID string) <-chan struct{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	ch, ok := q.waiters[deviceID]
+	if !ok {
+		ch = make(chan struct{})
+		q.waiters[deviceID] = ch
+	}
+	return ch
+}
+
+// notify wakes every poll waiting on a device
+func (q *Queue) notify(deviceID string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if ch, ok := q.waiters[deviceID]; ok {
+		close(ch)
+		delete(q.waiters, deviceID)
+	}
+}
+
+// Enqueue stores a command and wakes the device if it is polling
+func (q *Queue) Enqueue(ctx context.Context, cmd *models.DeviceCommand, actor string) error {
+	if err := q.repo.Create(ctx, cmd, actor); err != nil {
+		return err
+	}
+	q.notify(cmd.DeviceID)
+	return nil
+}
+
+// Deliver hands the pending commands of a device to it without waiting
+func (q *Queue) Deliver(ctx context.Context, deviceID string, now time.Time) ([]models.DeviceCommand, error) {
+	if _, err := q.repo.Expire(ctx, now); err != nil {
+		return nil, err
+	}
+	return q.repo.Deliver(ctx, deviceID, now, now.Add(-RedeliverAfter))
+}
+
+// Poll hands the pending commands of a device to it, waiting up to wait for
+// one to be queued when there are none. now is read again after waiting.
+func (q *Queue) Poll(ctx context.Context, deviceID string, wait time.Duration, now func() time.Time) ([]models.DeviceCommand, error) {
+	// Subscribe before looking so a command queued in between still wakes us
+	woken := q.waiter(deviceID)
+	commands, err := q.Deliver(ctx, deviceID, now())
+	if err != nil || len(commands) > 0 || wait <= 0 {
+		return commands, err
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-woken:
+	case <-timer.C:
+		return nil, nil
+	case <-ctx.Done():
+		return nil, nil
+	}
+	return q.Deliver(ctx, deviceID, now())
+}
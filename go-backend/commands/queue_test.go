@@ -0,0 +1,73 @@
+package commands
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"driver-drowsiness-backend/models"
+	"driver-drowsiness-backend/repository"
+)
+
+var t0 = time.Date(2025, 11, 9, 3, 0, 0, 0, time.UTC)
+
+func enqueue(t *testing.T, q *Queue, deviceID string, at time.Time) models.DeviceCommand {
+	t.Helper()
+	cmd := models.DeviceCommand{DeviceID: deviceID, Type: models.CommandAlarm, CreatedAt: at, ExpiresAt: at.Add(5 * time.Minute)}
+	if err := q.Enqueue(context.Background(), &cmd, "user:1"); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	return cmd
+}
+
+func TestRedelivery(t *testing.T) {
+	ctx := context.Background()
+	q := NewQueue(repository.NewMemoryStore().Commands)
+	cmd := enqueue(t, q, "device_01", t0)
+
+	if got, _ := q.Deliver(ctx, "device_01", t0); len(got) != 1 || got[0].Attempts != 1 {
+		t.Fatalf("first delivery = %+v", got)
+	}
+	if got, _ := q.Deliver(ctx, "device_01", t0.Add(RedeliverAfter-time.Second)); len(got) != 0 {
+		t.Errorf("redelivered too early: %+v", got)
+	}
+	got, _ := q.Deliver(ctx, "device_01", t0.Add(RedeliverAfter+time.Second))
+	if len(got) != 1 || got[0].ID != cmd.ID || got[0].Attempts != 2 {
+		t.Errorf("redelivery = %+v", got)
+	}
+	// Past its expiry the command is expired rather than delivered again
+	if got, _ := q.Deliver(ctx, "device_01", t0.Add(10*time.Minute)); len(got) != 0 {
+		t.Errorf("delivered after expiry: %+v", got)
+	}
+}
+
+func TestPollWakesOnEnqueue(t *testing.T) {
+	q := NewQueue(repository.NewMemoryStore().Commands)
+	clock := func() time.Time { return t0 }
+
+	// Commands of other devices do not wake the poll
+	start := time.Now()
+	enqueue(t, q, "device_02", t0)
+	if got, _ := q.Poll(context.Background(), "device_01", 20*time.Millisecond, clock); len(got) != 0 {
+		t.Errorf("poll returned %+v", got)
+	}
+	if time.Since(start) < 20*time.Millisecond {
+		t.Error("poll returned before its wait elapsed")
+	}
+
+	done := make(chan []models.DeviceCommand)
+	go func() {
+		got, _ := q.Poll(context.Background(), "device_01", 5*time.Second, clock)
+		done <- got
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cmd := enqueue(t, q, "device_01", t0)
+	select {
+	case got := <-done:
+		if len(got) != 1 || got[0].ID != cmd.ID {
+			t.Errorf("woken poll = %+v", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("poll was not woken")
+	}
+}
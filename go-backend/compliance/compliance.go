@@ -0,0 +1,251 @@
+// Package compliance tracks hours-of-service: continuous driving without a
+// break and driving time per business day, derived from the sample stream.
+package compliance
+
+import (
+	"sort"
+	"time"
+
+	"driver-drowsiness-backend/models"
+)
+
+// Event types
+const (
+	ContinuousWarning   = "continuous_warning"
+	ContinuousViolation = "continuous_violation"
+	DailyWarning        = "daily_warning"
+	DailyViolation      = "daily_violation"
+)
+
+// Rules are the driving-time limits. The defaults follow the Thai Land
+// Transport Act: at most 4 hours of continuous driving followed by a break
+// of at least 30 minutes, and at most 8 hours of driving a day.
+type Rules struct {
+	MaxContinuous time.Duration // driving allowed without a break
+	MinBreak      time.Duration // silence that counts as a break
+	MaxDaily      time.Duration // driving allowed per business day
+	WarnBefore    time.Duration // warn this long before a limit; 0 disables warnings
+	MaxSampleGap  time.Duration // longest silence between samples still counted as driving
+}
+
+// DefaultRules returns the statutory limits with a 30-minute warning
+func DefaultRules() Rules {
+	return Rules{
+		MaxContinuous: 4 * time.Hour,
+		MinBreak:      30 * time.Minute,
+		MaxDaily:      8 * time.Hour,
+		WarnBefore:    30 * time.Minute,
+		MaxSampleGap:  5 * time.Minute,
+	}
+}
+
+// Log accumulates the driving time of one driver from its sample times,
+// which must be added in order
+type Log struct {
+	rules Rules
+	loc   *time.Location
+
+	last       time.Time
+	continuous time.Duration
+	dayStart   time.Time
+	dayEnd     time.Time
+	daily      time.Duration
+	fired      map[string]bool // event types already raised this streak or day
+	breaks     int
+	longest    time.Duration
+	days       []models.ComplianceDay // closed days, kept only when recording
+	recordDays bool
+}
+
+// NewLog creates an empty Log whose days are calendar days of loc
+func NewLog(rules Rules, loc *time.Location) *Log {
+	return &Log{rules: rules, loc: loc, fired: make(map[string]bool)}
+}
+
+// ResumeLog creates a Log that continues from a saved state
+func ResumeLog(rules Rules, loc *time.Location, st models.ComplianceState) *Log {
+	l := NewLog(rules, loc)
+	if st.LastDrivingAt.IsZero() {
+		return l
+	}
+	l.startDay(st.DayStart)
+	l.last = st.LastDrivingAt
+	l.continuous = time.Duration(st.ContinuousSeconds * float64(time.Second))
+	l.daily = time.Duration(st.DailySeconds * float64(time.Second))
+	for _, kind := range st.Fired {
+		l.fired[kind] = true
+	}
+	return l
+}
+
+// State returns what ResumeLog needs to continue the Log
+func (l *Log) State() models.ComplianceState {
+	st := models.ComplianceState{
+		LastDrivingAt:     l.last,
+		ContinuousSeconds: l.continuous.Seconds(),
+		DayStart:          l.dayStart,
+		DailySeconds:      l.daily.Seconds(),
+		Fired:             []string{},
+	}
+	for kind := range l.fired {
+		st.Fired = append(st.Fired, kind)
+	}
+	sort.Strings(st.Fired)
+	return st
+}
+
+// Add records a sample at the given time and returns the limits it
+// approached or exceeded. Samples not after the previous one are ignored.
+func (l *Log) Add(at time.Time) []models.ComplianceEvent {
+	if l.last.IsZero() {
+		l.startDay(at)
+		l.last = at
+		return nil
+	}
+	if !at.After(l.last) {
+		return nil
+	}
+
+	gap := at.Sub(l.last)
+	switch {
+	case gap <= l.rules.MaxSampleGap:
+		l.drive(l.last, at)
+	case gap >= l.rules.MinBreak:
+		if l.continuous > 0 {
+			l.breaks++
+		}
+		l.continuous = 0
+		delete(l.fired, ContinuousWarning)
+		delete(l.fired, ContinuousViolation)
+	}
+	if !at.Before(l.dayEnd) {
+		l.startDay(at)
+	}
+	l.last = at
+
+	var events []models.ComplianceEvent
+	events = l.check(events, at, l.continuous, l.rules.MaxContinuous, ContinuousWarning, ContinuousViolation)
+	events = l.check(events, at, l.daily, l.rules.MaxDaily, DailyWarning, DailyViolation)
+	return events
+}
+
+// drive credits [from, to) as driving, splitting it at day boundaries
+func (l *Log) drive(from, to time.Time) {
+	l.continuous += to.Sub(from)
+	if l.continuous > l.longest {
+		l.longest = l.continuous
+	}
+	for from.Before(to) {
+		if !from.Before(l.dayEnd) {
+			l.startDay(from)
+		}
+		end := to
+		if end.After(l.dayEnd) {
+			end = l.dayEnd
+		}
+		l.daily += end.Sub(from)
+		from = end
+	}
+}
+
+// startDay closes the current day and opens the one containing t
+func (l *Log) startDay(t time.Time) {
+	if l.recordDays && !l.dayStart.IsZero() && l.daily > 0 {
+		l.days = append(l.days, models.ComplianceDay{
+			Date:           l.dayStart.In(l.loc).Format("2006-01-02"),
+			DrivingSeconds: l.daily.Seconds(),
+			Exceeded:       l.daily > l.rules.MaxDaily,
+		})
+	}
+	local := t.In(l.loc)
+	l.dayStart = time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, l.loc)
+	l.dayEnd = time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, l.loc)
+	l.daily = 0
+	delete(l.fired, DailyWarning)
+	delete(l.fired, DailyViolation)
+}
+
+// check raises each of a limit's events once while driving stays above it
+func (l *Log) check(events []models.ComplianceEvent, at time.Time, driving, limit time.Duration, warning, violation string) []models.ComplianceEvent {
+	event := func(kind string) models.ComplianceEvent {
+		l.fired[kind] = true
+		return models.ComplianceEvent{Type: kind, At: at, DrivingSeconds: driving.Seconds(), LimitSeconds: limit.Seconds()}
+	}
+	switch {
+	case driving > limit:
+		l.fired[warning] = true
+		if !l.fired[violation] {
+			events = append(events, event(violation))
+		}
+	case l.rules.WarnBefore > 0 && driving >= limit-l.rules.WarnBefore && !l.fired[warning]:
+		events = append(events, event(warning))
+	}
+	return events
+}
+
+// Status returns the driving time at now against the limits; silence since
+// the last sample counts as a break or a new day once long enough
+func (l *Log) Status(now time.Time) models.ComplianceStatus {
+	st := models.ComplianceStatus{
+		State:                  models.ComplianceOK,
+		ContinuousLimitSeconds: l.rules.MaxContinuous.Seconds(),
+		MinBreakSeconds:        l.rules.MinBreak.Seconds(),
+		DailyLimitSeconds:      l.rules.MaxDaily.Seconds(),
+	}
+	continuous, daily := l.continuous, l.daily
+	if !l.last.IsZero() {
+		last := l.last
+		st.LastDrivingAt = &last
+		if now.Sub(l.last) >= l.rules.MinBreak {
+			continuous = 0
+		}
+		if !now.Before(l.dayEnd) {
+			daily = 0
+		}
+	}
+	st.ContinuousSeconds = continuous.Seconds()
+	st.BreakDueInSeconds = (l.rules.MaxContinuous - continuous).Seconds()
+	st.DailySeconds = daily.Seconds()
+	st.DailyRemainingSeconds = (l.rules.MaxDaily - daily).Seconds()
+
+	switch {
+	case continuous > l.rules.MaxContinuous || daily > l.rules.MaxDaily:
+		st.State = models.ComplianceViolation
+	case l.rules.WarnBefore > 0 &&
+		(continuous >= l.rules.MaxContinuous-l.rules.WarnBefore || daily >= l.rules.MaxDaily-l.rules.WarnBefore):
+		st.State = models.ComplianceWarning
+	}
+	return st
+}
+
+// Summarize replays the sample times of one driver, in order, into a report
+func Summarize(times []time.Time, rules Rules, loc *time.Location) models.DriverCompliance {
+	l := NewLog(rules, loc)
+	l.recordDays = true
+	r := models.DriverCompliance{Violations: []models.ComplianceEvent{}}
+	var driving time.Duration
+	for _, t := range times {
+		before := l.continuous
+		for _, e := range l.Add(t) {
+			if e.Type == ContinuousWarning || e.Type == DailyWarning {
+				r.Warnings++
+			} else {
+				r.Violations = append(r.Violations, e)
+			}
+		}
+		if l.continuous > before {
+			driving += l.continuous - before
+		}
+	}
+	l.startDay(l.dayEnd) // close the last day
+
+	r.DrivingSeconds = driving.Seconds()
+	r.LongestContinuousSeconds = l.longest.Seconds()
+	r.Breaks = l.breaks
+	r.Days = l.days
+	if r.Days == nil {
+		r.Days = []models.ComplianceDay{}
+	}
+	r.Compliant = len(r.Violations) == 0
+	return r
+}
@@ -0,0 +1,127 @@
+package compliance
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"driver-drowsiness-backend/models"
+	"driver-drowsiness-backend/repository"
+)
+
+var bangkok = time.FixedZone("ICT", 7*3600)
+
+// t0 is 2025-11-09 06:00 Bangkok time
+var t0 = time.Date(2025, 11, 8, 23, 0, 0, 0, time.UTC)
+
+// drive returns sample times every minute over [from, from+d]
+func drive(from time.Time, d time.Duration) []time.Time {
+	var times []time.Time
+	for t := from; !t.After(from.Add(d)); t = t.Add(time.Minute) {
+		times = append(times, t)
+	}
+	return times
+}
+
+func eventTypes(l *Log, times []time.Time) []string {
+	var types []string
+	for _, t := range times {
+		for _, e := range l.Add(t) {
+			types = append(types, e.Type)
+		}
+	}
+	return types
+}
+
+func TestContinuousDriving(t *testing.T) {
+	l := NewLog(DefaultRules(), bangkok)
+	got := eventTypes(l, drive(t0, 4*time.Hour+2*time.Minute))
+	if len(got) != 2 || got[0] != ContinuousWarning || got[1] != ContinuousViolation {
+		t.Fatalf("events = %v", got)
+	}
+	st := l.Status(t0.Add(4*time.Hour + 2*time.Minute))
+	if st.State != models.ComplianceViolation || st.BreakDueInSeconds != -120 {
+		t.Errorf("status = %+v", st)
+	}
+
+	// A 30-minute break resets continuous driving but not the day
+	resume := t0.Add(4*time.Hour + 32*time.Minute)
+	if st := l.Status(resume); st.ContinuousSeconds != 0 || st.DailySeconds != (4*time.Hour+2*time.Minute).Seconds() {
+		t.Errorf("status after break = %+v", st)
+	}
+	if got := eventTypes(l, drive(resume, time.Hour)); len(got) != 0 {
+		t.Errorf("events after break = %v", got)
+	}
+}
+
+func TestShortStopsDoNotReset(t *testing.T) {
+	l := NewLog(DefaultRules(), bangkok)
+	eventTypes(l, drive(t0, 2*time.Hour))
+	// A 20-minute stop is neither driving nor a break
+	eventTypes(l, drive(t0.Add(2*time.Hour+20*time.Minute), time.Hour))
+	st := l.Status(t0.Add(3*time.Hour + 20*time.Minute))
+	if st.ContinuousSeconds != (3 * time.Hour).Seconds() {
+		t.Errorf("continuous = %v, want 3h", st.ContinuousSeconds)
+	}
+}
+
+func TestDailyLimitAndSummary(t *testing.T) {
+	rules := DefaultRules()
+	var times []time.Time
+	// Three 3-hour legs with breaks: 9h in the day, no continuous violation
+	for leg := 0; leg < 3; leg++ {
+		times = append(times, drive(t0.Add(time.Duration(leg)*(3*time.Hour+time.Hour)), 3*time.Hour)...)
+	}
+	// Crosses midnight Bangkok time into the next day
+	times = append(times, drive(time.Date(2025, 11, 9, 16, 30, 0, 0, time.UTC), time.Hour)...)
+
+	r := Summarize(times, rules, bangkok)
+	if len(r.Violations) != 1 || r.Violations[0].Type != DailyViolation {
+		t.Fatalf("violations = %+v", r.Violations)
+	}
+	if r.Breaks != 3 || r.LongestContinuousSeconds != (3*time.Hour).Seconds() || r.Compliant {
+		t.Errorf("summary = %+v", r)
+	}
+	want := []models.ComplianceDay{
+		{Date: "2025-11-09", DrivingSeconds: (9*time.Hour + 30*time.Minute).Seconds(), Exceeded: true},
+		{Date: "2025-11-10", DrivingSeconds: (30 * time.Minute).Seconds()},
+	}
+	if len(r.Days) != 2 || r.Days[0] != want[0] || r.Days[1] != want[1] {
+		t.Errorf("days = %+v, want %+v", r.Days, want)
+	}
+	if r.DrivingSeconds != (10 * time.Hour).Seconds() {
+		t.Errorf("driving = %v, want 10h", r.DrivingSeconds)
+	}
+}
+
+func TestMonitorResumesAfterPurge(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore()
+	uid, _ := store.Users.Create(ctx, &models.User{Email: "d@example.com", Role: "driver"})
+	times := drive(t0, 3*time.Hour+40*time.Minute)
+	m := NewMonitor(store.Compliance, DefaultRules())
+	for _, ts := range times {
+		if _, err := m.Observe(ctx, uid, ts, bangkok); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The purge deletes the samples the monitor was fed, which it never reads back
+	last := times[len(times)-1]
+	if err := store.Retention.Purge(ctx, repository.PurgeCutoffs{Default: last.Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A fresh monitor, as after a restart, picks up the ongoing streak
+	m = NewMonitor(store.Compliance, DefaultRules())
+	events, err := m.Observe(ctx, uid, last.Add(time.Minute), bangkok)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 0 {
+		t.Errorf("warning repeated after restart: %+v", events)
+	}
+	st, _ := m.Status(ctx, uid, last.Add(time.Minute), bangkok)
+	if st.State != models.ComplianceWarning || st.ContinuousSeconds != (3*time.Hour+41*time.Minute).Seconds() {
+		t.Errorf("status = %+v", st)
+	}
+}
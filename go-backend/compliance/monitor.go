@@ -0,0 +1,107 @@
+package compliance
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"driver-drowsiness-backend/models"
+	"driver-drowsiness-backend/repository"
+)
+
+// driver is the live Log of a driver; its lock orders the driver's samples
+// without holding up the others
+type driver struct {
+	mu    sync.Mutex
+	log   *Log      // nil until resumed
+	saved time.Time // last sample of the saved state
+}
+
+// Monitor keeps a live Log per driver as samples are ingested and saves it,
+// so a restart resumes it even after the daily purge deleted its samples.
+// m.mu guards the driver map only; the repository is never called with it held.
+type Monitor struct {
+	rules Rules
+	repo  repository.ComplianceRepository
+
+	mu      sync.Mutex
+	drivers map[int]*driver
+}
+
+// NewMonitor creates a Monitor enforcing rules
+func NewMonitor(repo repository.ComplianceRepository, rules Rules) *Monitor {
+	return &Monitor{rules: rules, repo: repo, drivers: make(map[int]*driver)}
+}
+
+// Rules returns the limits the monitor enforces
+func (m *Monitor) Rules() Rules {
+	return m.rules
+}
+
+// driver returns the entry of a driver, creating it on first use
+func (m *Monitor) driver(driverID int) *driver {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d, ok := m.drivers[driverID]
+	if !ok {
+		d = &driver{}
+		m.drivers[driverID] = d
+	}
+	return d
+}
+
+// resume loads the saved Log of a driver on first use; d.mu must be held
+func (m *Monitor) resume(ctx context.Context, driverID int, d *driver, loc *time.Location) error {
+	if d.log != nil {
+		return nil
+	}
+	st, err := m.repo.State(ctx, driverID)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		d.log = NewLog(m.rules, loc)
+	case err != nil:
+		return err
+	default:
+		d.log = ResumeLog(m.rules, loc, *st)
+		d.saved = st.LastDrivingAt
+	}
+	return nil
+}
+
+// Observe adds an ingested sample of a driver and returns the limits it
+// approached or exceeded. The state is saved with every event and at least
+// every half MaxSampleGap of driving, which bounds the driving a restart can
+// lose. When only the save fails, the events are returned with the error.
+func (m *Monitor) Observe(ctx context.Context, driverID int, at time.Time, loc *time.Location) ([]models.ComplianceEvent, error) {
+	d := m.driver(driverID)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := m.resume(ctx, driverID, d, loc); err != nil {
+		return nil, err
+	}
+	events := d.log.Add(at)
+	if len(events) == 0 && at.Sub(d.saved) < m.rules.MaxSampleGap/2 {
+		return nil, nil
+	}
+	st := d.log.State()
+	st.DriverID = driverID
+	if err := m.repo.SaveState(ctx, st); err != nil {
+		return events, err
+	}
+	d.saved = st.LastDrivingAt
+	return events, nil
+}
+
+// Status returns the live driving time of a driver at now
+func (m *Monitor) Status(ctx context.Context, driverID int, now time.Time, loc *time.Location) (models.ComplianceStatus, error) {
+	d := m.driver(driverID)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := m.resume(ctx, driverID, d, loc); err != nil {
+		return models.ComplianceStatus{}, err
+	}
+	st := d.log.Status(now)
+	st.DriverID = driverID
+	return st, nil
+}
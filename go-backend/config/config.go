@@ -2,22 +2,83 @@ package config
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	DBHost      string
-	DBPort      string
-	DBUser      string
-	DBPassword  string
-	DBName      string
-	DatabaseURL string // For Render/Heroku style DATABASE_URL
-	ServerPort  string
-	Environment string
-	JWTSecret   string
+	DBHost        string
+	DBPort        string
+	DBUser        string
+	DBPassword    string
+	DBName        string
+	DatabaseURL   string // For Render/Heroku style DATABASE_URL
+	ServerPort    string
+	Environment   string
+	JWTSecret     string
+	Timezone      string         // IANA name used when no fleet/request timezone applies
+	Location      *time.Location // Parsed Timezone
+	SessionGap    time.Duration  // Silence that ends a driving session
+	FatigueWindow time.Duration  // Sliding window of PERCLOS and fatigue metrics
+
+	// Hours-of-service limits
+	MaxContinuousDriving time.Duration // Driving allowed without a break
+	MinBreak             time.Duration // Rest that resets continuous driving
+	MaxDailyDriving      time.Duration // Driving allowed per business day
+	DrivingWarnBefore    time.Duration // Warn drivers this long before a limit
+
+	// Device health
+	HeartbeatTimeout time.Duration // Silence after which a device is offline
+	MaxCPUTemp       float64       // CPU temperature (°C) at which a device is degraded
+	MinFPS           float64       // Detector frame rate below which a device is degraded
+	CommandTTL       time.Duration // Default time a remote command waits for its acknowledgement
+
+	// Alert evidence (snapshots and clips)
+	StorageBackend        string // "local" or "s3"
+	StorageDir            string // Root of the local backend
+	UploadDir             string // Partial chunked uploads
+	S3Endpoint            string // e.g. https://s3.ap-southeast-1.amazonaws.com or a MinIO URL
+	S3Region              string
+	S3Bucket              string
+	S3AccessKey           string
+	S3SecretKey           string
+	EvidenceMaxBytes      int64         // Largest accepted file
+	EvidenceRetention     time.Duration // How long snapshots are kept
+	EvidenceClipRetention time.Duration // How long clips are kept
+	EvidenceURLTTL        time.Duration // Lifetime of signed download URLs
+	EvidenceSigningKey    string        // Signs download URLs; kept apart from JWTSecret
+
+	FaceMatchThreshold float64 // Cosine similarity at which face embeddings match
+
+	// PDF safety reports
+	ReportFont    string // TrueType font with Thai glyphs, e.g. Sarabun-Regular.ttf; empty uses the embedded Noto Sans Thai
+	WeeklyReports bool   // Generate last week's report of every fleet each Monday
+
+	// Digest subscriptions delivered by email
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUser     string
+	SMTPPassword string
+	SMTPFrom     string
+
+	WebhookAllowPrivate bool // Let webhook targets be internal hosts, for on-premises receivers
+
+	// Bearer token scrapers send to /metrics; empty leaves it open
+	MetricsToken string
+
+	// Logging
+	LogLevel          string        // debug, info, warn or error
+	LogFormat         string        // json or text
+	LogSampleInterval time.Duration // One ingestion log per device per interval; 0 logs all
+	SlowQuery         time.Duration // Queries at least this slow are logged as warnings
+
+	// API versioning: v1 is frozen and answered with these dates
+	V1DeprecatedAt time.Time // Deprecation header of v1 responses
+	V1Sunset       time.Time // Sunset header, when v1 stops being served
 }
 
 var AppConfig *Config
@@ -27,7 +88,7 @@ func LoadConfig() {
 	// Load .env file (only in development)
 	err := godotenv.Load()
 	if err != nil {
-		log.Println("Warning: .env file not found, using environment variables")
+		slog.Warn(".env file not found, using environment variables")
 	}
 
 	AppConfig = &Config{
@@ -40,18 +101,73 @@ func LoadConfig() {
 		ServerPort:  getEnv("PORT", "8080"),
 		Environment: getEnv("ENV", "development"),
 		JWTSecret:   getEnv("JWT_SECRET", "dev-secret-change-me"),
+		Timezone:    getEnv("APP_TIMEZONE", "Asia/Bangkok"),
 	}
 
-	log.Println("✅ Configuration loaded successfully")
-	if AppConfig.DatabaseURL != "" {
-		log.Println("📊 Using DATABASE_URL connection string")
-	} else {
-		log.Printf("📊 Database: %s@%s:%s/%s", AppConfig.DBUser, AppConfig.DBHost, AppConfig.DBPort, AppConfig.DBName)
+	loc, err := time.LoadLocation(AppConfig.Timezone)
+	if err != nil {
+		slog.Warn("Invalid APP_TIMEZONE, falling back to Asia/Bangkok", "value", AppConfig.Timezone, "error", err)
+		AppConfig.Timezone = "Asia/Bangkok"
+		loc, _ = time.LoadLocation(AppConfig.Timezone)
 	}
-	log.Printf("🌐 Server Port: %s", AppConfig.ServerPort)
-	log.Printf("🌍 Environment: %s", AppConfig.Environment)
+	AppConfig.Location = loc
+
+	AppConfig.SessionGap = getEnvDuration("SESSION_GAP", 5*time.Minute)
+	AppConfig.FatigueWindow = getEnvDuration("FATIGUE_WINDOW", time.Minute)
+	AppConfig.MaxContinuousDriving = getEnvDuration("HOS_MAX_CONTINUOUS", 4*time.Hour)
+	AppConfig.MinBreak = getEnvDuration("HOS_MIN_BREAK", 30*time.Minute)
+	AppConfig.MaxDailyDriving = getEnvDuration("HOS_MAX_DAILY", 8*time.Hour)
+	AppConfig.DrivingWarnBefore = getEnvDuration("HOS_WARN_BEFORE", 30*time.Minute)
+	AppConfig.HeartbeatTimeout = getEnvDuration("HEARTBEAT_TIMEOUT", 90*time.Second)
+	AppConfig.MaxCPUTemp = getEnvFloat("DEVICE_MAX_CPU_TEMP", 80)
+	AppConfig.MinFPS = getEnvFloat("DEVICE_MIN_FPS", 10)
+	AppConfig.CommandTTL = getEnvDuration("COMMAND_TTL", 5*time.Minute)
+	AppConfig.StorageBackend = getEnv("STORAGE_BACKEND", "local")
+	AppConfig.StorageDir = getEnv("STORAGE_DIR", "./data/evidence")
+	AppConfig.UploadDir = getEnv("UPLOAD_DIR", "./data/uploads")
+	AppConfig.S3Endpoint = getEnv("S3_ENDPOINT", "")
+	AppConfig.S3Region = getEnv("S3_REGION", "us-east-1")
+	AppConfig.S3Bucket = getEnv("S3_BUCKET", "")
+	AppConfig.S3AccessKey = getEnv("S3_ACCESS_KEY", "")
+	AppConfig.S3SecretKey = getEnv("S3_SECRET_KEY", "")
+	AppConfig.EvidenceMaxBytes = getEnvInt64("EVIDENCE_MAX_BYTES", 25<<20)
+	AppConfig.EvidenceRetention = getEnvDuration("EVIDENCE_RETENTION", 30*24*time.Hour)
+	AppConfig.EvidenceClipRetention = getEnvDuration("EVIDENCE_CLIP_RETENTION", 7*24*time.Hour)
+	AppConfig.EvidenceURLTTL = getEnvDuration("EVIDENCE_URL_TTL", 15*time.Minute)
+	AppConfig.EvidenceSigningKey = getEnv("EVIDENCE_SIGNING_KEY", "dev-evidence-key-change-me")
+	AppConfig.FaceMatchThreshold = getEnvFloat("FACE_MATCH_THRESHOLD", 0.6)
+	AppConfig.ReportFont = getEnv("REPORT_FONT", "")
+	AppConfig.WeeklyReports = getEnv("WEEKLY_REPORTS", "true") == "true"
+	AppConfig.SMTPHost = getEnv("SMTP_HOST", "")
+	AppConfig.SMTPPort = int(getEnvInt64("SMTP_PORT", 587))
+	AppConfig.SMTPUser = getEnv("SMTP_USER", "")
+	AppConfig.SMTPPassword = getEnv("SMTP_PASSWORD", "")
+	AppConfig.SMTPFrom = getEnv("SMTP_FROM", "no-reply@drowsiness.local")
+	AppConfig.WebhookAllowPrivate = getEnv("WEBHOOK_ALLOW_PRIVATE", "false") == "true"
+	AppConfig.MetricsToken = getEnv("METRICS_TOKEN", "")
+	AppConfig.LogLevel = getEnv("LOG_LEVEL", "info")
+	AppConfig.LogFormat = getEnv("LOG_FORMAT", "json")
+	AppConfig.LogSampleInterval = getEnvDuration("LOG_SAMPLE_INTERVAL", 10*time.Second)
+	AppConfig.SlowQuery = getEnvDuration("SLOW_QUERY", 500*time.Millisecond)
+	AppConfig.V1DeprecatedAt = getEnvDate("API_V1_DEPRECATED_AT", time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC))
+	AppConfig.V1Sunset = getEnvDate("API_V1_SUNSET", time.Date(2027, 5, 1, 0, 0, 0, 0, time.UTC))
+}
+
+// LogSummary logs the main settings, once logging is set up
+func LogSummary() {
+	database := "DATABASE_URL"
+	if AppConfig.DatabaseURL == "" {
+		database = fmt.Sprintf("%s@%s:%s/%s", AppConfig.DBUser, AppConfig.DBHost, AppConfig.DBPort, AppConfig.DBName)
+	}
+	slog.Info("Configuration loaded", "database", database, "port", AppConfig.ServerPort,
+		"environment", AppConfig.Environment, "timezone", AppConfig.Timezone,
+		"log_level", AppConfig.LogLevel, "log_format", AppConfig.LogFormat,
+		"log_sample_interval", AppConfig.LogSampleInterval.String())
 	if AppConfig.Environment == "production" && AppConfig.JWTSecret == "dev-secret-change-me" {
-		log.Println("⚠️ Warning: Using default JWT secret in production. Set JWT_SECRET env variable!")
+		slog.Warn("Using default JWT secret in production. Set JWT_SECRET env variable!")
+	}
+	if AppConfig.Environment == "production" && AppConfig.EvidenceSigningKey == "dev-evidence-key-change-me" {
+		slog.Warn("Using default evidence signing key in production. Set EVIDENCE_SIGNING_KEY env variable!")
 	}
 }
 
@@ -79,6 +195,62 @@ func GetDatabaseURL() string {
 	)
 }
 
+// getEnvDuration parses a Go duration (e.g. "5m") or returns default value
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		slog.Warn("Invalid setting, using default", "key", key, "value", value, "default", defaultValue.String())
+		return defaultValue
+	}
+	return d
+}
+
+// getEnvDate parses a YYYY-MM-DD date (midnight UTC) or returns default value
+func getEnvDate(key string, defaultValue time.Time) time.Time {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		slog.Warn("Invalid setting, using default", "key", key, "value", value, "default", defaultValue.Format("2006-01-02"))
+		return defaultValue
+	}
+	return t
+}
+
+// getEnvFloat parses a positive number or returns default value
+func getEnvFloat(key string, defaultValue float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f <= 0 {
+		slog.Warn("Invalid setting, using default", "key", key, "value", value, "default", defaultValue)
+		return defaultValue
+	}
+	return f
+}
+
+// getEnvInt64 parses a positive integer or returns default value
+func getEnvInt64(key string, defaultValue int64) int64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n <= 0 {
+		slog.Warn("Invalid setting, using default", "key", key, "value", value, "default", defaultValue)
+		return defaultValue
+	}
+	return n
+}
+
 // getEnv gets environment variable or returns default value
 func getEnv(key, defaultValue string) string {
 	value := os.Getenv(key)
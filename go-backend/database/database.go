@@ -2,13 +2,11 @@ package database
 
 import (
 	"database/sql"
-	"log"
+	"log/slog"
 	"time"
 
 	"driver-drowsiness-backend/config"
-	"driver-drowsiness-backend/models"
-
-	_ "github.com/lib/pq"
+	"driver-drowsiness-backend/repository"
 )
 
 var DB *sql.DB
@@ -17,8 +15,11 @@ var DB *sql.DB
 func Connect() error {
 	var err error
 
+	if config.AppConfig.SlowQuery > 0 {
+		slowQuery = config.AppConfig.SlowQuery
+	}
 	connStr := config.GetDatabaseURL()
-	DB, err = sql.Open("postgres", connStr)
+	DB, err = sql.Open(loggedDriverName, connStr)
 	if err != nil {
 		return err
 	}
@@ -29,7 +30,7 @@ func Connect() error {
 		return err
 	}
 
-	log.Println("✅ Database connected successfully")
+	slog.Info("Database connected")
 	return nil
 }
 
@@ -37,13 +38,13 @@ func Connect() error {
 func Close() {
 	if DB != nil {
 		DB.Close()
-		log.Println("🔌 Database connection closed")
+		slog.Info("Database connection closed")
 	}
 }
 
 // Migrate creates database tables if they don't exist
 func Migrate() error {
-	log.Println("📊 Running database migrations...")
+	slog.Info("Running database migrations")
 
 	// Create users table FIRST (other tables reference this)
 	_, err := DB.Exec(`
@@ -72,6 +73,24 @@ func Migrate() error {
 		ADD COLUMN IF NOT EXISTS user_type VARCHAR(50);
 	`)
 
+	// Create fleets table; each fleet carries its own business timezone
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS fleets (
+			id SERIAL PRIMARY KEY,
+			name VARCHAR(100) NOT NULL,
+			timezone VARCHAR(64) NOT NULL DEFAULT 'Asia/Bangkok',
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, _ = DB.Exec(`
+		ALTER TABLE users
+		ADD COLUMN IF NOT EXISTS fleet_id INT REFERENCES fleets(id) ON DELETE SET NULL;
+	`)
+
 	// Create devices table (references users)
 	_, err = DB.Exec(`
 		CREATE TABLE IF NOT EXISTS devices (
@@ -139,8 +158,40 @@ func Migrate() error {
 
 	// Create index on device_id and timestamp for faster queries
 	_, err = DB.Exec(`
-		CREATE INDEX IF NOT EXISTS idx_drowsiness_device_timestamp 
-		ON drowsiness_data(device_id, timestamp DESC)
+		CREATE INDEX IF NOT EXISTS idx_drowsiness_device_keyset
+		ON drowsiness_data(device_id, timestamp DESC, id DESC)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Server-side fatigue score of each sample
+	_, err = DB.Exec(`
+		ALTER TABLE drowsiness_data
+		ADD COLUMN IF NOT EXISTS fatigue_score DOUBLE PRECISION,
+		ADD COLUMN IF NOT EXISTS fatigue_level VARCHAR(20)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Extended telemetry of schema version 2; NULL where a device does not report it
+	_, err = DB.Exec(`
+		ALTER TABLE drowsiness_data
+		ADD COLUMN IF NOT EXISTS schema_version SMALLINT NOT NULL DEFAULT 1,
+		ADD COLUMN IF NOT EXISTS mouth_aspect_ratio DOUBLE PRECISION,
+		ADD COLUMN IF NOT EXISTS yawning BOOLEAN,
+		ADD COLUMN IF NOT EXISTS yawn_count INT,
+		ADD COLUMN IF NOT EXISTS head_pitch DOUBLE PRECISION,
+		ADD COLUMN IF NOT EXISTS head_yaw DOUBLE PRECISION,
+		ADD COLUMN IF NOT EXISTS head_roll DOUBLE PRECISION,
+		ADD COLUMN IF NOT EXISTS gaze_off_road_seconds DOUBLE PRECISION,
+		ADD COLUMN IF NOT EXISTS face_detected BOOLEAN,
+		ADD COLUMN IF NOT EXISTS latitude DOUBLE PRECISION,
+		ADD COLUMN IF NOT EXISTS longitude DOUBLE PRECISION,
+		ADD COLUMN IF NOT EXISTS speed_kmh DOUBLE PRECISION,
+		ADD COLUMN IF NOT EXISTS heading DOUBLE PRECISION,
+		ADD COLUMN IF NOT EXISTS extra JSONB
 	`)
 	if err != nil {
 		return err
@@ -166,8 +217,8 @@ func Migrate() error {
 
 	// Create index on device_id for faster queries
 	_, err = DB.Exec(`
-		CREATE INDEX IF NOT EXISTS idx_alerts_device 
-		ON alerts(device_id, timestamp DESC)
+		CREATE INDEX IF NOT EXISTS idx_alerts_device_keyset
+		ON alerts(device_id, timestamp DESC, id DESC)
 	`)
 	if err != nil {
 		return err
@@ -189,69 +240,612 @@ func Migrate() error {
 		return err
 	}
 
-	log.Println("✅ Database migrations completed successfully")
-	return nil
-}
+	// Where each alert was raised
+	_, err = DB.Exec(`
+		ALTER TABLE alerts
+		ADD COLUMN IF NOT EXISTS latitude DOUBLE PRECISION,
+		ADD COLUMN IF NOT EXISTS longitude DOUBLE PRECISION
+	`)
+	if err != nil {
+		return err
+	}
 
-// CreateUser inserts a new user
-func CreateUser(email, passwordHash, name, role, phone, userType string) (int, error) {
-	var id int
-	err := DB.QueryRow(`
-		INSERT INTO users (email, password_hash, name, role, phone, user_type)
-		VALUES ($1, $2, $3, COALESCE($4, 'driver'), $5, $6)
-		RETURNING id
-	`, email, passwordHash, name, role, phone, userType).Scan(&id)
-	return id, err
-}
+	// Create driving_sessions table: one row per continuous drive of a device
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS driving_sessions (
+			id SERIAL PRIMARY KEY,
+			device_id VARCHAR(50) NOT NULL,
+			driver_id INT,
+			started_at TIMESTAMP NOT NULL,
+			ended_at TIMESTAMP,
+			last_sample_at TIMESTAMP NOT NULL,
+			end_reason VARCHAR(20),
+			sample_count INT NOT NULL DEFAULT 0,
+			low_count INT NOT NULL DEFAULT 0,
+			medium_count INT NOT NULL DEFAULT 0,
+			high_count INT NOT NULL DEFAULT 0,
+			longest_high_seconds DOUBLE PRECISION NOT NULL DEFAULT 0,
+			high_run_start TIMESTAMP,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (device_id) REFERENCES devices(id) ON DELETE CASCADE,
+			FOREIGN KEY (driver_id) REFERENCES users(id) ON DELETE SET NULL
+		)
+	`)
+	if err != nil {
+		return err
+	}
 
-// GetUserByEmail retrieves user by email
-func GetUserByEmail(email string) (*models.User, error) {
-	var u models.User
-	var phone sql.NullString
-	var userType sql.NullString
-	err := DB.QueryRow(`
-		SELECT id, email, password_hash, name, phone, role, user_type, created_at
-		FROM users WHERE email = $1
-	`, email).Scan(&u.ID, &u.Email, &u.PasswordHash, &u.Name, &phone, &u.Role, &userType, &u.CreatedAt)
-	if err != nil {
-		return nil, err
-	}
-	if phone.Valid {
-		u.Phone = phone.String
-	} else {
-		u.Phone = ""
-	}
-	if userType.Valid {
-		u.UserType = userType.String
-	} else {
-		u.UserType = ""
-	}
-	return &u, nil
-}
+	_, err = DB.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_sessions_device_started
+		ON driving_sessions(device_id, started_at DESC)
+	`)
+	if err != nil {
+		return err
+	}
 
-// GetUserByID retrieves user by id
-func GetUserByID(id int) (*models.User, error) {
-	var u models.User
-	var phone sql.NullString
-	var userType sql.NullString
-	err := DB.QueryRow(`
-		SELECT id, email, password_hash, name, phone, role, user_type, created_at
-		FROM users WHERE id = $1
-	`, id).Scan(&u.ID, &u.Email, &u.PasswordHash, &u.Name, &phone, &u.Role, &userType, &u.CreatedAt)
-	if err != nil {
-		return nil, err
-	}
-	if phone.Valid {
-		u.Phone = phone.String
-	} else {
-		u.Phone = ""
-	}
-	if userType.Valid {
-		u.UserType = userType.String
-	} else {
-		u.UserType = ""
-	}
-	return &u, nil
+	// At most one open session per device
+	_, err = DB.Exec(`
+		CREATE UNIQUE INDEX IF NOT EXISTS uq_sessions_open_device
+		ON driving_sessions(device_id) WHERE ended_at IS NULL
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Fatigue totals of each session; PERCLOS is closed_seconds / observed_seconds
+	_, err = DB.Exec(`
+		ALTER TABLE driving_sessions
+		ADD COLUMN IF NOT EXISTS fatigue_score DOUBLE PRECISION NOT NULL DEFAULT 0,
+		ADD COLUMN IF NOT EXISTS max_fatigue_score DOUBLE PRECISION NOT NULL DEFAULT 0,
+		ADD COLUMN IF NOT EXISTS blink_count INT NOT NULL DEFAULT 0,
+		ADD COLUMN IF NOT EXISTS microsleep_count INT NOT NULL DEFAULT 0,
+		ADD COLUMN IF NOT EXISTS microsleep_seconds DOUBLE PRECISION NOT NULL DEFAULT 0,
+		ADD COLUMN IF NOT EXISTS observed_seconds DOUBLE PRECISION NOT NULL DEFAULT 0,
+		ADD COLUMN IF NOT EXISTS closed_seconds DOUBLE PRECISION NOT NULL DEFAULT 0
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Geofences: polygon is a JSON ring of [lon, lat]; rules change alert handling inside
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS geofences (
+			id SERIAL PRIMARY KEY,
+			name VARCHAR(100) NOT NULL,
+			kind VARCHAR(50) NOT NULL DEFAULT '',
+			polygon JSONB NOT NULL,
+			rules JSONB NOT NULL DEFAULT '[]',
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Enter/exit events of devices crossing geofence boundaries
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS geofence_events (
+			id SERIAL PRIMARY KEY,
+			geofence_id INT NOT NULL REFERENCES geofences(id) ON DELETE CASCADE,
+			device_id VARCHAR(50) NOT NULL,
+			event VARCHAR(10) NOT NULL,
+			latitude DOUBLE PRECISION NOT NULL,
+			longitude DOUBLE PRECISION NOT NULL,
+			timestamp TIMESTAMP NOT NULL
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = DB.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_geofence_events_device
+		ON geofence_events(device_id, geofence_id, timestamp DESC)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Geofence of alerts changed by a geofence rule
+	_, err = DB.Exec(`
+		ALTER TABLE alerts
+		ADD COLUMN IF NOT EXISTS geofence_id INT REFERENCES geofences(id) ON DELETE SET NULL
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Vehicles are separate from the devices mounted in them
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS vehicles (
+			id SERIAL PRIMARY KEY,
+			plate_number VARCHAR(20) UNIQUE NOT NULL,
+			type VARCHAR(50) NOT NULL DEFAULT '',
+			capacity INT NOT NULL DEFAULT 0,
+			fleet_id INT REFERENCES fleets(id) ON DELETE SET NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Installation history of devices in vehicles; removed_at is NULL while mounted
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS device_installations (
+			id SERIAL PRIMARY KEY,
+			device_id VARCHAR(50) NOT NULL REFERENCES devices(id) ON DELETE CASCADE,
+			vehicle_id INT NOT NULL REFERENCES vehicles(id) ON DELETE CASCADE,
+			installed_at TIMESTAMP NOT NULL,
+			removed_at TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = DB.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_installations_device
+		ON device_installations(device_id, installed_at DESC)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// A device is mounted in at most one vehicle at a time
+	_, err = DB.Exec(`
+		CREATE UNIQUE INDEX IF NOT EXISTS uq_installations_open_device
+		ON device_installations(device_id) WHERE removed_at IS NULL
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Vehicle the device was mounted in when an alert or session happened
+	_, err = DB.Exec(`
+		ALTER TABLE alerts
+		ADD COLUMN IF NOT EXISTS vehicle_id INT REFERENCES vehicles(id) ON DELETE SET NULL
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = DB.Exec(`
+		ALTER TABLE driving_sessions
+		ADD COLUMN IF NOT EXISTS vehicle_id INT REFERENCES vehicles(id) ON DELETE SET NULL
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Shifts: which driver drives which vehicle and device from start to end
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS shifts (
+			id SERIAL PRIMARY KEY,
+			driver_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			vehicle_id INT NOT NULL REFERENCES vehicles(id) ON DELETE CASCADE,
+			device_id VARCHAR(50) NOT NULL REFERENCES devices(id) ON DELETE CASCADE,
+			starts_at TIMESTAMP NOT NULL,
+			ends_at TIMESTAMP,
+			notes TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			CHECK (ends_at IS NULL OR ends_at > starts_at)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = DB.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_shifts_device ON shifts(device_id, starts_at DESC)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = DB.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_shifts_driver ON shifts(driver_id, starts_at DESC)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Driver on shift when a sample or alert was ingested; NULL falls back to the device owner
+	_, err = DB.Exec(`
+		ALTER TABLE drowsiness_data
+		ADD COLUMN IF NOT EXISTS driver_id INT REFERENCES users(id) ON DELETE SET NULL
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = DB.Exec(`
+		ALTER TABLE alerts
+		ADD COLUMN IF NOT EXISTS driver_id INT REFERENCES users(id) ON DELETE SET NULL
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = DB.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_drowsiness_driver_timestamp
+		ON drowsiness_data(driver_id, timestamp DESC)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Last heartbeat and derived state of each device
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS device_health (
+			device_id VARCHAR(50) PRIMARY KEY REFERENCES devices(id) ON DELETE CASCADE,
+			state VARCHAR(20) NOT NULL,
+			reason VARCHAR(100) NOT NULL DEFAULT '',
+			state_since TIMESTAMP NOT NULL,
+			last_heartbeat TIMESTAMP NOT NULL,
+			cpu_temp_c DOUBLE PRECISION,
+			camera_status VARCHAR(20) NOT NULL DEFAULT '',
+			fps DOUBLE PRECISION,
+			app_version VARCHAR(50) NOT NULL DEFAULT ''
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Transitions of the device state machine, kept for uptime history
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS device_state_events (
+			id SERIAL PRIMARY KEY,
+			device_id VARCHAR(50) NOT NULL REFERENCES devices(id) ON DELETE CASCADE,
+			from_state VARCHAR(20),
+			to_state VARCHAR(20) NOT NULL,
+			reason VARCHAR(100) NOT NULL DEFAULT '',
+			at TIMESTAMP NOT NULL
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = DB.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_state_events_device_at
+		ON device_state_events(device_id, at)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Desired and reported configuration of each device (device shadow)
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS device_shadows (
+			device_id VARCHAR(50) PRIMARY KEY REFERENCES devices(id) ON DELETE CASCADE,
+			desired JSONB NOT NULL,
+			version INT NOT NULL DEFAULT 0,
+			updated_at TIMESTAMP,
+			reported JSONB,
+			reported_version INT NOT NULL DEFAULT 0,
+			reported_at TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Remote commands queued for devices
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS device_commands (
+			id SERIAL PRIMARY KEY,
+			device_id VARCHAR(50) NOT NULL REFERENCES devices(id) ON DELETE CASCADE,
+			type VARCHAR(30) NOT NULL,
+			message TEXT NOT NULL DEFAULT '',
+			duration_seconds INT NOT NULL DEFAULT 0,
+			status VARCHAR(20) NOT NULL,
+			issued_by INT REFERENCES users(id) ON DELETE SET NULL,
+			attempts INT NOT NULL DEFAULT 0,
+			result TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP NOT NULL,
+			expires_at TIMESTAMP NOT NULL,
+			delivered_at TIMESTAMP,
+			completed_at TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = DB.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_commands_device_status
+		ON device_commands(device_id, status, created_at DESC)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Audit trail of every command status change
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS device_command_events (
+			id SERIAL PRIMARY KEY,
+			command_id INT NOT NULL REFERENCES device_commands(id) ON DELETE CASCADE,
+			status VARCHAR(20) NOT NULL,
+			actor VARCHAR(50) NOT NULL,
+			note TEXT NOT NULL DEFAULT '',
+			at TIMESTAMP NOT NULL
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = DB.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_command_events_command
+		ON device_command_events(command_id, at)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Alert evidence; alert fields are copied since alerts are purged daily
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS evidence (
+			id SERIAL PRIMARY KEY,
+			alert_id INT NOT NULL,
+			device_id VARCHAR(50) NOT NULL REFERENCES devices(id) ON DELETE CASCADE,
+			alert_type VARCHAR(100) NOT NULL,
+			severity VARCHAR(50) NOT NULL,
+			alert_at TIMESTAMP NOT NULL,
+			kind VARCHAR(20) NOT NULL,
+			content_type VARCHAR(50) NOT NULL,
+			size BIGINT NOT NULL,
+			sha256 CHAR(64) NOT NULL,
+			storage_key TEXT NOT NULL,
+			thumbnail_key TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP NOT NULL,
+			expires_at TIMESTAMP NOT NULL
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = DB.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_evidence_alert ON evidence(alert_id)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = DB.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_evidence_device_time ON evidence(device_id, alert_at DESC)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = DB.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_evidence_expires ON evidence(expires_at)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Resumable evidence uploads in progress
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS evidence_uploads (
+			id VARCHAR(64) PRIMARY KEY,
+			device_id VARCHAR(50) NOT NULL REFERENCES devices(id) ON DELETE CASCADE,
+			alert_id INT NOT NULL,
+			content_type VARCHAR(50) NOT NULL,
+			size BIGINT NOT NULL,
+			received BIGINT NOT NULL DEFAULT 0,
+			created_at TIMESTAMP NOT NULL,
+			updated_at TIMESTAMP NOT NULL,
+			alert_type VARCHAR(100) NOT NULL DEFAULT '',
+			severity VARCHAR(50) NOT NULL DEFAULT '',
+			alert_at TIMESTAMP NOT NULL,
+			locked_until TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Reference face embeddings; never face images
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS face_embeddings (
+			id SERIAL PRIMARY KEY,
+			user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			model VARCHAR(50) NOT NULL DEFAULT '',
+			embedding DOUBLE PRECISION[] NOT NULL,
+			created_at TIMESTAMP NOT NULL
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = DB.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_face_embeddings_user ON face_embeddings(user_id)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = DB.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_face_embeddings_model ON face_embeddings(model)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Face check of the driver at trip start
+	_, err = DB.Exec(`
+		ALTER TABLE driving_sessions
+		ADD COLUMN IF NOT EXISTS identity VARCHAR(20),
+		ADD COLUMN IF NOT EXISTS expected_driver_id INT REFERENCES users(id) ON DELETE SET NULL,
+		ADD COLUMN IF NOT EXISTS identity_similarity DOUBLE PRECISION
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Hourly level counts kept by the daily purge for reports and analytics
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS hourly_level_counts (
+			hour TIMESTAMP NOT NULL,
+			device_id VARCHAR(50) NOT NULL,
+			driver_id INT NOT NULL DEFAULT 0,
+			high INT NOT NULL DEFAULT 0,
+			medium INT NOT NULL DEFAULT 0,
+			PRIMARY KEY (hour, device_id, driver_id)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+	_, err = DB.Exec(`ALTER TABLE hourly_level_counts ADD COLUMN IF NOT EXISTS low INT NOT NULL DEFAULT 0`)
+	if err != nil {
+		return err
+	}
+
+	// Generated PDF safety reports
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS reports (
+			id SERIAL PRIMARY KEY,
+			kind VARCHAR(20) NOT NULL,
+			fleet_id INT REFERENCES fleets(id) ON DELETE CASCADE,
+			driver_id INT REFERENCES users(id) ON DELETE CASCADE,
+			title TEXT NOT NULL,
+			timezone VARCHAR(64) NOT NULL,
+			period_from TIMESTAMP NOT NULL,
+			period_to TIMESTAMP NOT NULL,
+			source VARCHAR(20) NOT NULL DEFAULT 'manual',
+			status VARCHAR(20) NOT NULL DEFAULT 'pending',
+			error TEXT NOT NULL DEFAULT '',
+			storage_key TEXT NOT NULL DEFAULT '',
+			size BIGINT NOT NULL DEFAULT 0,
+			pages INT NOT NULL DEFAULT 0,
+			created_at TIMESTAMP NOT NULL,
+			completed_at TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = DB.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_reports_created ON reports(created_at DESC)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// One scheduled report per subject and period, even with several replicas
+	_, err = DB.Exec(`
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_reports_scheduled
+		ON reports(kind, COALESCE(fleet_id, 0), COALESCE(driver_id, 0), period_from)
+		WHERE source = 'schedule'
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Driving time per driver and day, archived before the samples are purged
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS compliance_days (
+			day TIMESTAMP NOT NULL,
+			driver_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			driving_seconds DOUBLE PRECISION NOT NULL DEFAULT 0,
+			exceeded BOOLEAN NOT NULL DEFAULT FALSE,
+			violations INT NOT NULL DEFAULT 0,
+			PRIMARY KEY (day, driver_id)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Live driving time per driver, which the purge of samples leaves alone
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS compliance_state (
+			driver_id INT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+			last_driving_at TIMESTAMP NOT NULL,
+			continuous_seconds DOUBLE PRECISION NOT NULL DEFAULT 0,
+			day_start TIMESTAMP NOT NULL,
+			daily_seconds DOUBLE PRECISION NOT NULL DEFAULT 0,
+			fired TEXT[] NOT NULL DEFAULT '{}'
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Scheduled digests and their delivery history
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS report_subscriptions (
+			id SERIAL PRIMARY KEY,
+			user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			fleet_id INT NOT NULL REFERENCES fleets(id) ON DELETE CASCADE,
+			digest VARCHAR(30) NOT NULL,
+			format VARCHAR(10) NOT NULL,
+			channel VARCHAR(10) NOT NULL,
+			target TEXT NOT NULL,
+			secret TEXT NOT NULL DEFAULT '',
+			lang VARCHAR(5) NOT NULL DEFAULT 'th',
+			active BOOLEAN NOT NULL DEFAULT TRUE,
+			next_run_at TIMESTAMP NOT NULL,
+			created_at TIMESTAMP NOT NULL
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = DB.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_report_subscriptions_due ON report_subscriptions(next_run_at) WHERE active
+	`)
+	if err != nil {
+		return err
+	}
+
+	// One delivery per subscription and period, even with several replicas
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS subscription_deliveries (
+			id SERIAL PRIMARY KEY,
+			subscription_id INT NOT NULL REFERENCES report_subscriptions(id) ON DELETE CASCADE,
+			period_from TIMESTAMP NOT NULL,
+			period_to TIMESTAMP NOT NULL,
+			status VARCHAR(20) NOT NULL DEFAULT 'pending',
+			attempts INT NOT NULL DEFAULT 0,
+			error TEXT NOT NULL DEFAULT '',
+			next_attempt_at TIMESTAMP NOT NULL,
+			last_attempt_at TIMESTAMP,
+			delivered_at TIMESTAMP,
+			created_at TIMESTAMP NOT NULL,
+			UNIQUE (subscription_id, period_from)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = DB.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_subscription_deliveries_pending
+		ON subscription_deliveries(next_attempt_at) WHERE status = 'pending'
+	`)
+	if err != nil {
+		return err
+	}
+
+	// The keyset indexes of history and alert pages replaced these
+	_, err = DB.Exec(`DROP INDEX IF EXISTS idx_drowsiness_device_timestamp, idx_alerts_device`)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("Database migrations completed")
+	return nil
 }
 
 // SeedDevices inserts sample devices for testing
@@ -282,117 +876,33 @@ func GetUserByID(id int) (*models.User, error) {
 	return nil
 }*/
 
-// PurgeNonTodayData deletes records not from the current UTC date
-// If you need local timezone day-based retention, adjust the query accordingly.
-func PurgeNonTodayData() error {
-	log.Println("🧹 Purging non-today drowsiness and alert data...")
-	// Delete old drowsiness_data rows
-	if _, err := DB.Exec(`DELETE FROM drowsiness_data WHERE timestamp::date <> CURRENT_DATE`); err != nil {
-		log.Printf("⚠️ Failed to purge drowsiness_data: %v", err)
-		return err
-	}
-	// Delete old alerts rows
-	if _, err := DB.Exec(`DELETE FROM alerts WHERE timestamp::date <> CURRENT_DATE`); err != nil {
-		log.Printf("⚠️ Failed to purge alerts: %v", err)
-		return err
-	}
-	log.Println("✅ Purge complete (retained only today's rows)")
-	return nil
-}
-
-// ScheduleDailyPurge sets up a background goroutine to purge at next midnight UTC
-func ScheduleDailyPurge() {
+// ScheduleDailyPurge sets up a background goroutine to run purge at each
+// local midnight of every location, e.g. the server's PurgeOldData wrapped
+// in job metrics. locations is asked again before every wait, so timezones
+// of fleets added since are picked up.
+func ScheduleDailyPurge(locations func() []*time.Location, purge func() error) {
 	go func() {
 		for {
-			now := time.Now().UTC()
+			now := time.Now()
 			// Next midnight + small offset (5s) to avoid race with incoming data
-			next := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 5, 0, time.UTC)
-			sleepDur := next.Sub(now)
-			time.Sleep(sleepDur)
-			if err := PurgeNonTodayData(); err != nil {
-				log.Printf("❌ Daily purge failed: %v", err)
+			next := nextMidnight(now, locations())
+			time.Sleep(next.Add(5 * time.Second).Sub(now))
+			if err := purge(); err != nil {
+				slog.Error("Daily purge failed", "error", err)
 			}
 		}
 	}()
-	log.Println("⏰ Scheduled daily purge at UTC midnight")
-}
-
-// UpsertDeviceForUser links a device to a user (by email and user ID).
-// If the device already exists, its driver_email and user_id are updated.
-func UpsertDeviceForUser(deviceID, email string, userID int) error {
-	if deviceID == "" {
-		return nil
-	}
-	_, err := DB.Exec(`
-		INSERT INTO devices (id, driver_email, user_id, status)
-		VALUES ($1, $2, $3, 'active')
-		ON CONFLICT (id) DO UPDATE SET
-		  driver_email = EXCLUDED.driver_email,
-		  user_id = EXCLUDED.user_id
-	`, deviceID, email, userID)
-	return err
-}
-
-// GetPrimaryDeviceForUser returns the latest device associated with a user.
-func GetPrimaryDeviceForUser(userID int) (string, error) {
-	var deviceID string
-	err := DB.QueryRow(`
-		SELECT id FROM devices
-		WHERE user_id = $1
-		ORDER BY created_at DESC
-		LIMIT 1
-	`, userID).Scan(&deviceID)
-	if err != nil {
-		return "", err
-	}
-	return deviceID, nil
-}
-
-// ================== PASSWORD RESET FUNCTIONS ==================
-
-// StoreResetCode stores a password reset code for a user
-func StoreResetCode(userID int, code string) error {
-	// Delete any existing reset codes for this user
-	_, _ = DB.Exec(`DELETE FROM password_resets WHERE user_id = $1`, userID)
-
-	// Insert new reset code (valid for 15 minutes)
-	_, err := DB.Exec(`
-		INSERT INTO password_resets (user_id, reset_code, expires_at)
-		VALUES ($1, $2, NOW() + INTERVAL '15 minutes')
-	`, userID, code)
-	return err
+	slog.Info("Scheduled daily purge at midnight of every fleet timezone")
 }
 
-// ValidateResetCode checks if a reset code is valid and not expired
-func ValidateResetCode(email, code string) (*models.User, error) {
-	var u models.User
-	err := DB.QueryRow(`
-		SELECT u.id, u.email, u.name, u.role
-		FROM users u
-		JOIN password_resets pr ON u.id = pr.user_id
-		WHERE u.email = $1 
-		  AND pr.reset_code = $2 
-		  AND pr.expires_at > NOW() 
-		  AND pr.used = FALSE
-	`, email, code).Scan(&u.ID, &u.Email, &u.Name, &u.Role)
-	if err != nil {
-		return nil, err
+// nextMidnight returns the first midnight after now in any of locations,
+// or in UTC when there are none
+func nextMidnight(now time.Time, locations []*time.Location) time.Time {
+	next := repository.DayRange(now, time.UTC).To
+	for i, loc := range locations {
+		if t := repository.DayRange(now, loc).To; i == 0 || t.Before(next) {
+			next = t
+		}
 	}
-	return &u, nil
-}
-
-// UpdateUserPassword updates user's password
-func UpdateUserPassword(userID int, passwordHash string) error {
-	_, err := DB.Exec(`
-		UPDATE users SET password_hash = $1 WHERE id = $2
-	`, passwordHash, userID)
-	return err
-}
-
-// ClearResetCode marks reset code as used
-func ClearResetCode(userID int) error {
-	_, err := DB.Exec(`
-		UPDATE password_resets SET used = TRUE WHERE user_id = $1
-	`, userID)
-	return err
+	return next
 }
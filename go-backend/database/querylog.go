@@ -0,0 +1,106 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// loggedDriverName is the postgres driver that logs its queries
+const loggedDriverName = "postgres+log"
+
+func init() {
+	sql.Register(loggedDriverName, &logDriver{next: &pq.Driver{}})
+}
+
+// slowQuery is the duration from which queries are logged as warnings
+// instead of at debug level; set from config on Connect
+var slowQuery = 500 * time.Millisecond
+
+// logDriver wraps a driver so every query and exec is logged with its
+// duration and the request ID of its context. Arguments are never logged.
+type logDriver struct {
+	next driver.Driver
+}
+
+func (d *logDriver) Open(name string) (driver.Conn, error) {
+	c, err := d.next.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &logConn{Conn: c}, nil
+}
+
+// logConn forwards to a pq connection, which implements every optional
+// interface used here
+type logConn struct {
+	driver.Conn
+}
+
+func (c *logConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	rows, err := c.Conn.(driver.QueryerContext).QueryContext(ctx, query, args)
+	logQuery(ctx, query, start, err)
+	return rows, err
+}
+
+func (c *logConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	res, err := c.Conn.(driver.ExecerContext).ExecContext(ctx, query, args)
+	logQuery(ctx, query, start, err)
+	return res, err
+}
+
+func (c *logConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return c.Conn.(driver.ConnPrepareContext).PrepareContext(ctx, query)
+}
+
+func (c *logConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return c.Conn.(driver.ConnBeginTx).BeginTx(ctx, opts)
+}
+
+func (c *logConn) Ping(ctx context.Context) error {
+	return c.Conn.(driver.Pinger).Ping(ctx)
+}
+
+func (c *logConn) ResetSession(ctx context.Context) error {
+	return c.Conn.(driver.SessionResetter).ResetSession(ctx)
+}
+
+func (c *logConn) IsValid() bool {
+	return c.Conn.(driver.Validator).IsValid()
+}
+
+// logQuery logs a query at debug level, or as a warning when slow or failed
+func logQuery(ctx context.Context, query string, start time.Time, err error) {
+	elapsed := time.Since(start)
+	level := slog.LevelDebug
+	if elapsed >= slowQuery || (err != nil && err != driver.ErrSkip) {
+		level = slog.LevelWarn
+	}
+	if !slog.Default().Enabled(ctx, level) {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("query", compactQuery(query)),
+		slog.Float64("duration_ms", float64(elapsed.Microseconds())/1000),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	slog.LogAttrs(ctx, level, "db query", attrs...)
+}
+
+// compactQuery folds the whitespace of a query and shortens it
+func compactQuery(q string) string {
+	q = strings.Join(strings.Fields(q), " ")
+	if len(q) > 300 {
+		q = q[:300] + "…"
+	}
+	return q
+}
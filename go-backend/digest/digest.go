@@ -0,0 +1,112 @@
+// Package digest builds the recurring digests users subscribe to: when they
+// run, the period they cover, how they are rendered and how they are sent
+package digest
+
+import (
+	"sort"
+	"time"
+
+	"driver-drowsiness-backend/models"
+	"driver-drowsiness-backend/reports"
+	"driver-drowsiness-backend/repository"
+)
+
+// SendHour is the local hour of the fleet timezone digests are sent at
+const SendHour = 8
+
+// Valid reports whether digest, format and channel are all known
+func Valid(digest, format, channel string) bool {
+	switch digest {
+	case models.DigestDailySummary, models.DigestWeeklyCompliance:
+	default:
+		return false
+	}
+	switch format {
+	case models.DigestHTML, models.DigestPDF, models.DigestCSV:
+	default:
+		return false
+	}
+	return channel == models.ChannelEmail || channel == models.ChannelWebhook
+}
+
+// NextRun returns the first send time of a digest after t: 08:00 every day
+// for the daily summary, 08:00 on Mondays for weekly compliance
+func NextRun(digest string, t time.Time, loc *time.Location) time.Time {
+	l := t.In(loc)
+	run := time.Date(l.Year(), l.Month(), l.Day(), SendHour, 0, 0, 0, loc)
+	step := 1
+	if digest == models.DigestWeeklyCompliance {
+		run = run.AddDate(0, 0, -(int(run.Weekday())+6)%7) // Monday of this week
+		step = 7
+	}
+	for !run.After(t) {
+		run = run.AddDate(0, 0, step)
+	}
+	return run.UTC()
+}
+
+// Period returns what a digest sent at run covers: the previous local day,
+// or the Monday-to-Monday week before
+func Period(digest string, run time.Time, loc *time.Location) repository.TimeRange {
+	if digest == models.DigestWeeklyCompliance {
+		return reports.LastWeek(run, loc)
+	}
+	l := run.In(loc)
+	return repository.TimeRange{
+		From: time.Date(l.Year(), l.Month(), l.Day()-1, 0, 0, 0, 0, loc).UTC(),
+		To:   time.Date(l.Year(), l.Month(), l.Day(), 0, 0, 0, 0, loc).UTC(),
+	}
+}
+
+// ComplianceDriver is one driver's row of the weekly compliance digest
+type ComplianceDriver struct {
+	Name           string
+	DrivingSeconds float64
+	Days           int // days with driving
+	ExceededDays   int // days over the daily limit
+	Violations     int
+}
+
+// Compliant reports whether the driver kept every limit
+func (d ComplianceDriver) Compliant() bool {
+	return d.Violations == 0 && d.ExceededDays == 0
+}
+
+// Compliance is the content of the weekly compliance digest
+type Compliance struct {
+	Fleet    string
+	Period   repository.TimeRange
+	Location *time.Location
+	Drivers  []ComplianceDriver // most violations first
+}
+
+// SummarizeCompliance folds archived days, ordered by driver, into driver rows
+func SummarizeCompliance(fleet string, days []models.ComplianceArchiveDay, names map[int]string,
+	period repository.TimeRange, loc *time.Location) *Compliance {
+	c := &Compliance{Fleet: fleet, Period: period, Location: loc}
+	for i, d := range days {
+		if i == 0 || days[i-1].DriverID != d.DriverID {
+			c.Drivers = append(c.Drivers, ComplianceDriver{Name: names[d.DriverID]})
+		}
+		row := &c.Drivers[len(c.Drivers)-1]
+		row.DrivingSeconds += d.DrivingSeconds
+		row.Violations += d.Violations
+		if d.DrivingSeconds > 0 {
+			row.Days++
+		}
+		if d.Exceeded {
+			row.ExceededDays++
+		}
+	}
+	sort.SliceStable(c.Drivers, func(i, j int) bool {
+		a, b := c.Drivers[i], c.Drivers[j]
+		if a.Violations != b.Violations {
+			return a.Violations > b.Violations
+		}
+		if a.ExceededDays != b.ExceededDays {
+			return a.ExceededDays > b.ExceededDays
+		}
+		return a.DrivingSeconds > b.DrivingSeconds
+	})
+	return c
+}
@@ -0,0 +1,254 @@
+package digest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+
+	"driver-drowsiness-backend/models"
+	"driver-drowsiness-backend/reports"
+	"driver-drowsiness-backend/repository"
+)
+
+var bangkok = time.FixedZone("ICT", 7*3600)
+
+func TestSchedule(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Sunday 2025-11-09 10:30 Bangkok
+	now := time.Date(2025, 11, 9, 3, 30, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		digest string
+		at     time.Time
+		loc    *time.Location
+		want   string
+	}{
+		{models.DigestDailySummary, now, bangkok, "2025-11-10 08:00"},
+		{models.DigestDailySummary, time.Date(2025, 11, 9, 0, 59, 0, 0, time.UTC), bangkok, "2025-11-09 08:00"},
+		{models.DigestDailySummary, time.Date(2025, 11, 9, 1, 0, 0, 0, time.UTC), bangkok, "2025-11-10 08:00"},
+		{models.DigestWeeklyCompliance, now, bangkok, "2025-11-10 08:00"},
+		{models.DigestWeeklyCompliance, time.Date(2025, 11, 10, 1, 0, 0, 0, time.UTC), bangkok, "2025-11-17 08:00"},
+		// Across the end of daylight saving time the send stays at 08:00 local
+		{models.DigestDailySummary, time.Date(2025, 10, 25, 12, 0, 0, 0, time.UTC), berlin, "2025-10-26 08:00"},
+	} {
+		got := NextRun(tc.digest, tc.at, tc.loc)
+		if got.In(tc.loc).Format("2006-01-02 15:04") != tc.want {
+			t.Errorf("NextRun(%s, %v) = %v, want %s", tc.digest, tc.at, got.In(tc.loc), tc.want)
+		}
+	}
+
+	run := time.Date(2025, 11, 10, 1, 0, 0, 0, time.UTC) // Monday 08:00 Bangkok
+	day := Period(models.DigestDailySummary, run, bangkok)
+	if day.From.In(bangkok).Format("2006-01-02 15:04") != "2025-11-09 00:00" || day.To.Sub(day.From) != 24*time.Hour {
+		t.Errorf("daily period = %v", day)
+	}
+	week := Period(models.DigestWeeklyCompliance, run, bangkok)
+	if week.From.In(bangkok).Format("2006-01-02") != "2025-11-03" || week.To.In(bangkok).Format("2006-01-02") != "2025-11-10" {
+		t.Errorf("weekly period = %v", week)
+	}
+
+	if !Valid(models.DigestDailySummary, models.DigestPDF, models.ChannelWebhook) || Valid("monthly", models.DigestPDF, models.ChannelEmail) ||
+		Valid(models.DigestDailySummary, "docx", models.ChannelEmail) || Valid(models.DigestDailySummary, models.DigestCSV, "sms") {
+		t.Error("Valid")
+	}
+	for attempt, want := range map[int]time.Duration{1: time.Minute, 2: 4 * time.Minute, 3: 16 * time.Minute, 4: time.Hour, 9: time.Hour} {
+		if got := Backoff(attempt); got != want {
+			t.Errorf("Backoff(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+}
+
+func dailySummary() *reports.Summary {
+	period := repository.TimeRange{
+		From: time.Date(2025, 11, 8, 0, 0, 0, 0, bangkok),
+		To:   time.Date(2025, 11, 9, 0, 0, 0, 0, bangkok),
+	}
+	return reports.Summarize("Bangkok", []repository.HourlyCount{
+		{Hour: period.From.Add(time.Hour), DriverID: 1, DriverName: "Somchai", High: 2, Medium: 1},
+		{Hour: period.From.Add(15 * time.Hour), DriverID: 2, DriverName: "<Malee>", Medium: 2},
+	}, period, bangkok)
+}
+
+func TestRenderDaily(t *testing.T) {
+	now := time.Date(2025, 11, 9, 1, 0, 0, 0, time.UTC)
+	c, err := Daily(dailySummary(), models.DigestCSV, "en", nil, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Subject != "[Bangkok] Daily drowsiness summary 2025-11-08" || c.Filename != "daily_summary_2025-11-08.csv" ||
+		!strings.Contains(c.Text, "High events: 2\n") || !strings.Contains(c.Text, "Worst time slot: 00-02\n") {
+		t.Errorf("content = %+v", c)
+	}
+	want := "\uFEFFDriver,High,Medium,Risk score,Worst time slot\nSomchai,2,1,7,00-02\n<Malee>,0,2,2,14-16\n"
+	if string(c.Body) != want {
+		t.Errorf("csv = %q", c.Body)
+	}
+
+	c, err = Daily(dailySummary(), models.DigestHTML, "th", nil, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := string(c.Body)
+	if c.ContentType != "text/html; charset=utf-8" || !strings.Contains(body, "สรุปเหตุการณ์ง่วงนอนประจำวัน") ||
+		!strings.Contains(body, "&lt;Malee&gt;") || strings.Contains(body, "<Malee>") {
+		t.Errorf("html = %s", body)
+	}
+
+	c, err = Daily(dailySummary(), models.DigestPDF, "th", nil, now)
+	if err != nil || c.ContentType != "application/pdf" || !strings.HasPrefix(string(c.Body), "%PDF-") {
+		t.Errorf("pdf: %v %q", err, c.ContentType)
+	}
+}
+
+func TestRenderCompliance(t *testing.T) {
+	period := repository.TimeRange{
+		From: time.Date(2025, 11, 3, 0, 0, 0, 0, bangkok),
+		To:   time.Date(2025, 11, 10, 0, 0, 0, 0, bangkok),
+	}
+	day := func(d int) time.Time { return time.Date(2025, 11, d, 0, 0, 0, 0, bangkok) }
+	cp := SummarizeCompliance("Bangkok", []models.ComplianceArchiveDay{
+		{Day: day(3), DriverID: 1, DrivingSeconds: 3 * 3600},
+		{Day: day(4), DriverID: 1, DrivingSeconds: 2 * 3600},
+		{Day: day(3), DriverID: 2, DrivingSeconds: 9 * 3600, Exceeded: true, Violations: 2},
+	}, map[int]string{1: "Somchai", 2: "Malee"}, period, bangkok)
+	if len(cp.Drivers) != 2 || cp.Drivers[0].Name != "Malee" || cp.Drivers[1].Days != 2 || !cp.Drivers[1].Compliant() {
+		t.Fatalf("drivers = %+v", cp.Drivers)
+	}
+
+	c, err := WeeklyCompliance(cp, models.DigestCSV, "en", nil, period.To)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "\uFEFFDriver,Driving hours,Days driven,Days over limit,Violations,Compliant\n" +
+		"Malee,9,1,1,2,No\nSomchai,5,2,0,0,Yes\n"
+	if string(c.Body) != want || c.Subject != "[Bangkok] Weekly driving-time compliance 2025-11-03 – 2025-11-09" {
+		t.Errorf("csv %q, subject %q", c.Body, c.Subject)
+	}
+
+	// Without a Thai font the PDF falls back to English
+	c, err = WeeklyCompliance(cp, models.DigestPDF, "th", nil, period.To)
+	if err != nil || !strings.HasPrefix(string(c.Body), "%PDF-") || !strings.Contains(c.Subject, "Weekly driving-time compliance") {
+		t.Errorf("pdf: %v %q", err, c.Subject)
+	}
+}
+
+func TestMailer(t *testing.T) {
+	var sent []byte
+	m := NewMailer("smtp.example.com", 587, "user", "pass", "reports@example.com")
+	m.now = func() time.Time { return time.Date(2025, 11, 9, 1, 0, 0, 0, time.UTC) }
+	m.send = func(addr string, _ smtp.Auth, from string, to []string, msg []byte) error {
+		if addr != "smtp.example.com:587" || from != "reports@example.com" || len(to) != 1 || to[0] != "boss@example.com" {
+			t.Errorf("send(%s, %s, %v)", addr, from, to)
+		}
+		sent = msg
+		return nil
+	}
+	c, _ := Daily(dailySummary(), models.DigestCSV, "th", nil, time.Now())
+	if err := m.Send(context.Background(), Message{To: "boss@example.com", DeliveryID: 7, Content: c}); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(string(sent)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if subject != c.Subject || msg.Header.Get("X-Delivery-ID") != "7" {
+		t.Errorf("headers = %v", msg.Header)
+	}
+	_, params, _ := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	var parts []*multipart.Part
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts = append(parts, p)
+		if p.FileName() != "" {
+			data, _ := io.ReadAll(p)
+			if p.Header.Get("Content-Transfer-Encoding") != "base64" || len(data) == 0 {
+				t.Errorf("attachment %s", p.FileName())
+			}
+		}
+	}
+	if len(parts) != 2 || parts[1].FileName() != "daily_summary_2025-11-08.csv" {
+		t.Errorf("parts = %d", len(parts))
+	}
+
+	if err := NewMailer("", 587, "", "", "x@example.com").Send(context.Background(), Message{Content: c}); err == nil {
+		t.Error("unconfigured mailer sent")
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	var got *http.Request
+	var body []byte
+	status := http.StatusNoContent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	c, _ := Daily(dailySummary(), models.DigestHTML, "en", nil, time.Now())
+	if err := NewWebhook(5*time.Second, false).Send(context.Background(), Message{To: srv.URL, Content: c}); !errors.Is(err, ErrPrivateTarget) {
+		t.Errorf("loopback target: %v", err)
+	}
+	if got != nil {
+		t.Fatal("loopback target was dialled")
+	}
+	wh := NewWebhook(5*time.Second, true)
+	if err := wh.Send(context.Background(), Message{To: srv.URL, Secret: "s3cret", DeliveryID: 3, Content: c}); err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != string(c.Body) || got.Header.Get("X-Digest-Signature") != Sign("s3cret", c.Body) ||
+		got.Header.Get("X-Delivery-ID") != "3" || got.Header.Get("Content-Type") != c.ContentType {
+		t.Errorf("request headers = %v", got.Header)
+	}
+
+	status = http.StatusBadGateway
+	if err := wh.Send(context.Background(), Message{To: srv.URL, Content: c}); err == nil || !strings.Contains(err.Error(), "502") {
+		t.Errorf("failing endpoint: %v", err)
+	}
+	if got.Header.Get("X-Digest-Signature") != "" {
+		t.Error("unsigned delivery has a signature")
+	}
+}
+
+func TestCheckTarget(t *testing.T) {
+	for target, public := range map[string]bool{
+		"http://93.184.216.34/hook":        true,
+		"https://[2606:2800:220:1::]/hook": true,
+		"http://127.0.0.1:8080/hook":       false,
+		"http://[::1]/hook":                false,
+		"http://10.1.2.3/hook":             false,
+		"http://192.168.0.10/hook":         false,
+		"http://172.16.5.4/hook":           false,
+		"http://169.254.169.254/latest":    false,
+		"http://100.64.0.1/hook":           false,
+		"http://0.0.0.0/hook":              false,
+		"http://[fe80::1]/hook":            false,
+		"http://[::ffff:127.0.0.1]/hook":   false,
+	} {
+		err := CheckTarget(context.Background(), target)
+		if public && err != nil || !public && !errors.Is(err, ErrPrivateTarget) {
+			t.Errorf("%s: %v", target, err)
+		}
+	}
+}
@@ -0,0 +1,318 @@
+package digest
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"driver-drowsiness-backend/export"
+	"driver-drowsiness-backend/i18n"
+	"driver-drowsiness-backend/models"
+	"driver-drowsiness-backend/pdf"
+	"driver-drowsiness-backend/reports"
+	"driver-drowsiness-backend/repository"
+)
+
+// Content is a rendered digest
+type Content struct {
+	Subject     string
+	Text        string // plain-text summary, the body of emails with an attachment
+	Filename    string
+	ContentType string
+	Body        []byte
+}
+
+var (
+	titleDaily      = i18n.Label{EN: "Daily drowsiness summary", TH: "สรุปเหตุการณ์ง่วงนอนประจำวัน"}
+	titleCompliance = i18n.Label{EN: "Weekly driving-time compliance", TH: "สรุปชั่วโมงการขับรถประจำสัปดาห์"}
+
+	labelHigh       = i18n.Label{EN: "High events", TH: "เหตุการณ์ระดับสูง"}
+	labelMedium     = i18n.Label{EN: "Medium events", TH: "เหตุการณ์ระดับกลาง"}
+	labelActive     = i18n.Label{EN: "Drivers with events", TH: "คนขับที่มีเหตุการณ์"}
+	labelPeak       = i18n.Label{EN: "Worst time slot", TH: "ช่วงเวลาเสี่ยงสุด"}
+	labelDrivers    = i18n.Label{EN: "Drivers", TH: "คนขับ"}
+	labelCompliant  = i18n.Label{EN: "Compliant drivers", TH: "คนขับที่ขับตามเกณฑ์"}
+	labelViolations = i18n.Label{EN: "Violations", TH: "การฝ่าฝืน"}
+	labelNoName     = i18n.Label{EN: "Unassigned device", TH: "อุปกรณ์ที่ไม่มีคนขับ"}
+	labelYes        = i18n.Label{EN: "Yes", TH: "ใช่"}
+	labelNo         = i18n.Label{EN: "No", TH: "ไม่"}
+)
+
+var dailyColumns = []i18n.Label{
+	{EN: "Driver", TH: "คนขับ"},
+	{EN: "High", TH: "สูง"},
+	{EN: "Medium", TH: "กลาง"},
+	{EN: "Risk score", TH: "คะแนนความเสี่ยง"},
+	{EN: "Worst time slot", TH: "ช่วงเวลาเสี่ยงสุด"},
+}
+
+var complianceColumns = []i18n.Label{
+	{EN: "Driver", TH: "คนขับ"},
+	{EN: "Driving hours", TH: "ชั่วโมงขับรถ"},
+	{EN: "Days driven", TH: "จำนวนวันที่ขับ"},
+	{EN: "Days over limit", TH: "วันที่ขับเกินเกณฑ์"},
+	{EN: "Violations", TH: "การฝ่าฝืน"},
+	{EN: "Compliant", TH: "ตามเกณฑ์"},
+}
+
+// table is a digest as a few headline facts and one table, the shape every
+// format is rendered from
+type table struct {
+	lang    string
+	title   string
+	subject string
+	period  string
+	facts   [][2]string
+	header  []string
+	rows    [][]interface{}
+}
+
+func newTable(title i18n.Label, subject string, period repository.TimeRange, loc *time.Location, lang string) *table {
+	from := period.From.In(loc).Format("2006-01-02")
+	to := period.To.Add(-time.Nanosecond).In(loc).Format("2006-01-02")
+	t := &table{lang: lang, title: title.In(lang), subject: subject, period: from}
+	if to != from {
+		t.period += " – " + to
+	}
+	return t
+}
+
+func (t *table) columns(labels []i18n.Label) {
+	for _, l := range labels {
+		t.header = append(t.header, l.In(t.lang))
+	}
+}
+
+func (t *table) fact(label i18n.Label, value string) {
+	t.facts = append(t.facts, [2]string{label.In(t.lang), value})
+}
+
+func (t *table) subjectLine() string {
+	return "[" + t.subject + "] " + t.title + " " + t.period
+}
+
+func (t *table) text() string {
+	var b strings.Builder
+	b.WriteString(t.subjectLine() + "\n\n")
+	for _, f := range t.facts {
+		b.WriteString(f[0] + ": " + f[1] + "\n")
+	}
+	return b.String()
+}
+
+// filename names the file of a digest, e.g. daily_summary_2025-11-08.pdf
+func filename(digest string, period repository.TimeRange, loc *time.Location, format string) string {
+	return digest + "_" + period.From.In(loc).Format("2006-01-02") + "." + format
+}
+
+// Daily renders the daily summary of a fleet
+func Daily(s *reports.Summary, format, lang string, font *pdf.Font, now time.Time) (*Content, error) {
+	t := newTable(titleDaily, s.Subject, s.Period, s.Location, lang)
+	t.fact(labelHigh, strconv.Itoa(s.High))
+	t.fact(labelMedium, strconv.Itoa(s.Medium))
+	t.fact(labelActive, strconv.Itoa(len(s.Drivers)))
+	t.fact(labelPeak, peak(&s.Breakdown))
+	t.columns(dailyColumns)
+	for _, d := range s.Drivers {
+		name := d.Name
+		if name == "" {
+			name = labelNoName.In(lang)
+		}
+		t.rows = append(t.rows, []interface{}{name, d.High, d.Medium, d.Risk(), peak(&d.Breakdown)})
+	}
+
+	c := &Content{Subject: t.subjectLine(), Text: t.text(), Filename: filename(models.DigestDailySummary, s.Period, s.Location, format)}
+	if format == models.DigestPDF {
+		return c, writePDF(c, reports.Render(s, models.ReportFleet, font, now))
+	}
+	return c, t.render(c, format, s.Location)
+}
+
+// WeeklyCompliance renders the weekly driving-time digest of a fleet
+func WeeklyCompliance(cp *Compliance, format, lang string, font *pdf.Font, now time.Time) (*Content, error) {
+	if format == models.DigestPDF && (font == nil || !font.Has('ก')) {
+		lang = i18n.English // Helvetica has no Thai
+	}
+	t := newTable(titleCompliance, cp.Fleet, cp.Period, cp.Location, lang)
+	compliant, violations := 0, 0
+	for _, d := range cp.Drivers {
+		if d.Compliant() {
+			compliant++
+		}
+		violations += d.Violations
+	}
+	t.fact(labelDrivers, strconv.Itoa(len(cp.Drivers)))
+	t.fact(labelCompliant, strconv.Itoa(compliant))
+	t.fact(labelViolations, strconv.Itoa(violations))
+	t.columns(complianceColumns)
+	for _, d := range cp.Drivers {
+		ok := labelNo.In(lang)
+		if d.Compliant() {
+			ok = labelYes.In(lang)
+		}
+		hours := math.Round(d.DrivingSeconds/360) / 10
+		t.rows = append(t.rows, []interface{}{d.Name, hours, d.Days, d.ExceededDays, d.Violations, ok})
+	}
+
+	c := &Content{Subject: t.subjectLine(), Text: t.text(), Filename: filename(models.DigestWeeklyCompliance, cp.Period, cp.Location, format)}
+	if format == models.DigestPDF {
+		return c, writePDF(c, t.pdf(font, now, cp.Location))
+	}
+	return c, t.render(c, format, cp.Location)
+}
+
+// peak returns the label of the worst time slot, or "-"
+func peak(b *reports.Breakdown) string {
+	worst := b.Worst(1)
+	if len(worst) == 0 {
+		return "-"
+	}
+	return worst[0].Label()
+}
+
+func writePDF(c *Content, doc *pdf.Document) error {
+	var buf bytes.Buffer
+	if _, err := doc.WriteTo(&buf); err != nil {
+		return err
+	}
+	c.ContentType, c.Body = "application/pdf", buf.Bytes()
+	return nil
+}
+
+// render writes the table as CSV or HTML
+func (t *table) render(c *Content, format string, loc *time.Location) error {
+	var buf bytes.Buffer
+	if format == models.DigestCSV {
+		w := export.New(export.CSV, &buf, loc, "")
+		if err := w.Row(headerCells(t.header)...); err != nil {
+			return err
+		}
+		for _, row := range t.rows {
+			if err := w.Row(row...); err != nil {
+				return err
+			}
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+		c.ContentType, c.Body = export.CSV.ContentType(), buf.Bytes()
+		return nil
+	}
+	if err := htmlTemplate.Execute(&buf, t.view()); err != nil {
+		return err
+	}
+	c.ContentType, c.Body = "text/html; charset=utf-8", buf.Bytes()
+	return nil
+}
+
+func headerCells(s []string) []interface{} {
+	cells := make([]interface{}, len(s))
+	for i, v := range s {
+		cells[i] = v
+	}
+	return cells
+}
+
+// cell formats a table cell for HTML and PDF
+func cell(v interface{}) string {
+	if f, ok := v.(float64); ok {
+		return strconv.FormatFloat(f, 'f', 1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+type htmlView struct {
+	Lang, Title, Subject, Period string
+	Facts                        [][2]string
+	Header                       []string
+	Rows                         [][]string
+}
+
+func (t *table) view() htmlView {
+	v := htmlView{Lang: t.lang, Title: t.title, Subject: t.subject, Period: t.period, Facts: t.facts, Header: t.header}
+	for _, row := range t.rows {
+		cells := make([]string, len(row))
+		for i, c := range row {
+			cells[i] = cell(c)
+		}
+		v.Rows = append(v.Rows, cells)
+	}
+	return v
+}
+
+var htmlTemplate = template.Must(template.New("digest").Parse(`<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head><meta charset="utf-8"><title>{{.Title}} – {{.Subject}}</title></head>
+<body style="font-family: Sarabun, 'Noto Sans Thai', Tahoma, sans-serif; color: #212529; margin: 0; padding: 24px;">
+<div style="background: #0d47a1; color: #fff; padding: 16px 20px;">
+<h1 style="margin: 0; font-size: 20px;">{{.Title}}</h1>
+<div style="margin-top: 4px;">{{.Subject}} · {{.Period}}</div>
+</div>
+<table style="margin: 16px 0; border-collapse: collapse;">
+{{range .Facts}}<tr><td style="padding: 4px 16px 4px 0; color: #6c757d;">{{index . 0}}</td><td style="padding: 4px 0; font-weight: bold;">{{index . 1}}</td></tr>
+{{end}}</table>
+{{if .Rows}}<table style="border-collapse: collapse; width: 100%;">
+<tr>{{range .Header}}<th style="text-align: left; background: #f1f3f5; padding: 6px 8px;">{{.}}</th>{{end}}</tr>
+{{range .Rows}}<tr>{{range .}}<td style="border-bottom: 1px solid #e9ecef; padding: 6px 8px;">{{.}}</td>{{end}}</tr>
+{{end}}</table>{{end}}
+</body>
+</html>
+`))
+
+var headerColor = pdf.RGB(13, 71, 161)
+
+// pdf lays the table out on A4 pages, continuing it on as many as needed
+func (t *table) pdf(font *pdf.Font, now time.Time, loc *time.Location) *pdf.Document {
+	doc := pdf.New(font)
+	doc.Title = t.title + " – " + t.subject
+	const margin, rowHeight = 40.0, 18.0
+	width := pdf.PageWidth - 2*margin
+	text := pdf.Style{Size: 9, Color: pdf.Black}
+	bold := pdf.Style{Size: 9, Bold: true, Color: pdf.Black}
+
+	var p *pdf.Page
+	var y float64
+	header := func() {
+		p.Rect(margin, y, width, rowHeight, pdf.Light)
+		for i, h := range t.header {
+			p.Text(margin+4+float64(i)*width/float64(len(t.header)), y+12, doc.Truncate(h, 9, width/float64(len(t.header))-8), bold)
+		}
+		y += rowHeight
+	}
+	newPage := func() {
+		p = doc.AddPage()
+		p.Rect(0, 0, pdf.PageWidth, 70, headerColor)
+		p.Text(margin, 32, t.title, pdf.Style{Size: 16, Bold: true, Color: pdf.White})
+		p.Text(margin, 54, doc.Truncate(t.subject+" · "+t.period, 11, width), pdf.Style{Size: 11, Color: pdf.White})
+		p.Text(margin, pdf.PageHeight-22, now.In(loc).Format("2006-01-02 15:04"), pdf.Style{Size: 8, Color: pdf.Gray})
+		y = 90
+	}
+
+	newPage()
+	for _, f := range t.facts {
+		p.Text(margin, y+12, f[0], pdf.Style{Size: 10, Color: pdf.Gray})
+		p.Text(margin+160, y+12, f[1], pdf.Style{Size: 10, Bold: true, Color: pdf.Black})
+		y += rowHeight
+	}
+	y += 10
+	if len(t.rows) > 0 {
+		header()
+	}
+	for _, row := range t.rows {
+		if y+rowHeight > pdf.PageHeight-50 {
+			newPage()
+			header()
+		}
+		for i, c := range row {
+			colW := width / float64(len(row))
+			p.Text(margin+4+float64(i)*colW, y+12, doc.Truncate(cell(c), 9, colW-8), text)
+		}
+		p.Line(margin, y+rowHeight, margin+width, y+rowHeight, 0.3, pdf.Light)
+		y += rowHeight
+	}
+	return doc
+}
@@ -0,0 +1,237 @@
+package digest
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Message is a rendered digest addressed to a subscription target
+type Message struct {
+	To         string // email address or webhook URL
+	Secret     string // webhook signing key; empty leaves the body unsigned
+	DeliveryID int    // lets receivers drop retried duplicates
+	Content    *Content
+}
+
+// Sender delivers digests over one channel
+type Sender interface {
+	Send(ctx context.Context, m Message) error
+}
+
+// Mailer sends digests over SMTP: HTML digests as the email body, PDF and
+// CSV ones as an attachment to a plain-text summary
+type Mailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+	send func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+	now  func() time.Time
+}
+
+// NewMailer returns a Mailer of the server at host:port; without a host
+// every send fails, so deliveries record that mail is not configured
+func NewMailer(host string, port int, username, password, from string) *Mailer {
+	m := &Mailer{from: from, send: smtp.SendMail, now: time.Now}
+	if host != "" {
+		m.addr = host + ":" + strconv.Itoa(port)
+	}
+	if username != "" {
+		m.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return m
+}
+
+// Send mails m; the SMTP exchange does not observe ctx
+func (m *Mailer) Send(_ context.Context, msg Message) error {
+	if m.addr == "" {
+		return errors.New("SMTP is not configured")
+	}
+	body, err := m.compose(msg)
+	if err != nil {
+		return err
+	}
+	return m.send(m.addr, m.auth, m.from, []string{msg.To}, body)
+}
+
+// compose builds the MIME message of a digest
+func (m *Mailer) compose(msg Message) ([]byte, error) {
+	c := msg.Content
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\nMIME-Version: 1.0\r\nX-Delivery-ID: %d\r\n",
+		m.from, msg.To, mime.QEncoding.Encode("utf-8", c.Subject), m.now().Format(time.RFC1123Z), msg.DeliveryID)
+
+	if strings.HasPrefix(c.ContentType, "text/html") {
+		b.WriteString("Content-Type: text/html; charset=utf-8\r\nContent-Transfer-Encoding: base64\r\n\r\n")
+		writeBase64(&b, c.Body)
+		return b.Bytes(), nil
+	}
+
+	mw := multipart.NewWriter(&b)
+	fmt.Fprintf(&b, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", mw.Boundary())
+	part, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	writeBase64(part, []byte(c.Text))
+	part, err = mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {c.ContentType},
+		"Content-Transfer-Encoding": {"base64"},
+		"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": c.Filename})},
+	})
+	if err != nil {
+		return nil, err
+	}
+	writeBase64(part, c.Body)
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// writeBase64 writes data base64-encoded in lines of 76 characters
+func writeBase64(w io.Writer, data []byte) {
+	enc := base64.StdEncoding.EncodeToString(data)
+	for len(enc) > 76 {
+		io.WriteString(w, enc[:76]+"\r\n")
+		enc = enc[76:]
+	}
+	io.WriteString(w, enc+"\r\n")
+}
+
+// ErrPrivateTarget is returned for webhook targets on loopback, private,
+// link-local or otherwise internal addresses
+var ErrPrivateTarget = errors.New("webhook target is not a public address")
+
+// sharedAddressSpace is 100.64.0.0/10, carrier-grade NAT and some cloud
+// metadata services
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// publicIP reports whether ip may be the target of a webhook
+func publicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsMulticast() && !ip.IsUnspecified() && !sharedAddressSpace.Contains(ip)
+}
+
+// CheckTarget resolves the host of a webhook URL and fails with
+// ErrPrivateTarget if any of its addresses is not public. Deliveries check
+// the address they connect to again, as DNS may change in between.
+func CheckTarget(ctx context.Context, target string) error {
+	u, err := url.Parse(target)
+	if err != nil {
+		return err
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil {
+		return err
+	}
+	for _, a := range addrs {
+		if !publicIP(a.IP) {
+			return ErrPrivateTarget
+		}
+	}
+	return nil
+}
+
+// Webhook posts digests to HTTP endpoints. The body is the rendered file;
+// with a secret, X-Digest-Signature is "sha256=" and the hex HMAC-SHA256
+// of the body.
+type Webhook struct {
+	client *http.Client
+}
+
+// NewWebhook returns a Webhook whose requests time out after timeout.
+// Unless allowPrivate, it refuses to connect to addresses that are not
+// public, redirects included, so a subscription cannot reach internal hosts.
+func NewWebhook(timeout time.Duration, allowPrivate bool) *Webhook {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	if !allowPrivate {
+		dialer.Control = func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+				return ErrPrivateTarget
+			}
+			return nil
+		}
+	}
+	// No proxy: the dialer must see the address of the target itself
+	transport := &http.Transport{
+		DialContext:         dialer.DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+		MaxIdleConns:        10,
+		IdleConnTimeout:     90 * time.Second,
+	}
+	return &Webhook{client: &http.Client{Timeout: timeout, Transport: transport}}
+}
+
+// Sign returns the signature header value of body
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Send posts m, failing unless the endpoint answers 2xx
+func (w *Webhook) Send(ctx context.Context, m Message) error {
+	c := m.Content
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.To, bytes.NewReader(c.Body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", c.ContentType)
+	req.Header.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": c.Filename}))
+	req.Header.Set("X-Delivery-ID", strconv.Itoa(m.DeliveryID))
+	req.Header.Set("X-Digest-Subject", mime.QEncoding.Encode("utf-8", c.Subject))
+	if m.Secret != "" {
+		req.Header.Set("X-Digest-Signature", Sign(m.Secret, c.Body))
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
+
+// Backoff returns how long to wait after a failed attempt: a minute, then
+// four times longer each attempt, at most an hour
+func Backoff(attempt int) time.Duration {
+	d := time.Minute
+	for i := 1; i < attempt && d < time.Hour; i++ {
+		d *= 4
+	}
+	if d > time.Hour {
+		d = time.Hour
+	}
+	return d
+}
+
+// MaxAttempts is how often a delivery is tried before it fails for good
+const MaxAttempts = 5
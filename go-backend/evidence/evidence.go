@@ -0,0 +1,134 @@
+// Package evidence turns uploaded alert snapshots and clips into stored
+// files with thumbnails, retention and signed download URLs
+package evidence
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"driver-drowsiness-backend/models"
+)
+
+// contentTypes maps the accepted content types to their evidence kind
+var contentTypes = map[string]string{
+	"image/jpeg":      models.EvidenceSnapshot,
+	"video/mp4":       models.EvidenceClip,
+	"video/webm":      models.EvidenceClip,
+	"video/quicktime": models.EvidenceClip,
+}
+
+// KindOf returns the evidence kind of a content type, if it is accepted
+func KindOf(contentType string) (string, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", false
+	}
+	kind, ok := contentTypes[mediaType]
+	return kind, ok
+}
+
+// Upload errors
+var (
+	ErrUnsupportedType = errors.New("content_type must be image/jpeg, video/mp4, video/webm or video/quicktime")
+	ErrContentMismatch = errors.New("file content does not match its content_type")
+)
+
+// Check returns the kind of an upload declared as contentType after
+// sniffing its first bytes, so a device cannot store arbitrary files
+func Check(contentType string, head []byte) (string, error) {
+	kind, ok := KindOf(contentType)
+	if !ok {
+		return "", ErrUnsupportedType
+	}
+	sniffed := http.DetectContentType(head)
+	switch kind {
+	case models.EvidenceSnapshot:
+		if sniffed != "image/jpeg" {
+			return "", ErrContentMismatch
+		}
+	case models.EvidenceClip:
+		// QuickTime is not sniffed, so only reject what is clearly not video
+		if strings.HasPrefix(sniffed, "image/") || strings.HasPrefix(sniffed, "text/") {
+			return "", ErrContentMismatch
+		}
+	}
+	return kind, nil
+}
+
+// Extension returns the file extension stored objects of a content type get
+func Extension(contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "image/jpeg":
+		return ".jpg"
+	case "video/mp4":
+		return ".mp4"
+	case "video/webm":
+		return ".webm"
+	case "video/quicktime":
+		return ".mov"
+	}
+	return ""
+}
+
+// Retention is how long each kind of evidence is kept
+type Retention struct {
+	Snapshot time.Duration
+	Clip     time.Duration
+}
+
+// DefaultRetention keeps snapshots for 30 days and the larger clips for 7
+func DefaultRetention() Retention {
+	return Retention{Snapshot: 30 * 24 * time.Hour, Clip: 7 * 24 * time.Hour}
+}
+
+// ExpiresAt returns when evidence of kind created at t is deleted
+func (r Retention) ExpiresAt(kind string, t time.Time) time.Time {
+	if kind == models.EvidenceClip {
+		return t.Add(r.Clip)
+	}
+	return t.Add(r.Snapshot)
+}
+
+// Signer issues and checks expiring download URLs for evidence files
+type Signer struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+// NewSigner creates a Signer whose URLs are valid for ttl
+func NewSigner(secret string, ttl time.Duration) *Signer {
+	return &Signer{secret: []byte(secret), ttl: ttl}
+}
+
+func (s *Signer) signature(id int, variant string, expires int64) string {
+	mac := hmac.New(sha256.New, s.secret)
+	fmt.Fprintf(mac, "evidence/%d/%s/%d", id, variant, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// URL returns a signed path to a variant ("file" or "thumbnail") of
+// evidence id under the API prefix base, e.g. "/api/v2", and when it stops
+// working. The signature does not cover base, so it holds in every version.
+func (s *Signer) URL(base string, id int, variant string, now time.Time) (string, time.Time) {
+	expires := now.Add(s.ttl).Truncate(time.Second)
+	return fmt.Sprintf("%s/evidence/%d/%s?expires=%d&signature=%s",
+		base, id, variant, expires.Unix(), s.signature(id, variant, expires.Unix())), expires
+}
+
+// Verify reports whether a signed URL is authentic and not expired at now
+func (s *Signer) Verify(id int, variant, expires, signature string, now time.Time) bool {
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || now.Unix() > exp {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(s.signature(id, variant, exp)))
+}
@@ -0,0 +1,132 @@
+package evidence
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"driver-drowsiness-backend/models"
+)
+
+var t0 = time.Date(2025, 11, 9, 3, 0, 0, 0, time.UTC)
+
+func TestCheck(t *testing.T) {
+	var jpg bytes.Buffer
+	jpeg.Encode(&jpg, image.NewGray(image.Rect(0, 0, 4, 4)), nil)
+	mp4 := []byte("\x00\x00\x00\x18ftypmp42\x00\x00\x00\x00")
+	cases := []struct {
+		contentType string
+		head        []byte
+		kind        string
+		err         error
+	}{
+		{"image/jpeg", jpg.Bytes(), models.EvidenceSnapshot, nil},
+		{"video/mp4", mp4, models.EvidenceClip, nil},
+		{"video/quicktime", []byte("\x00\x00\x00\x14ftypqt  "), models.EvidenceClip, nil},
+		{"image/jpeg", mp4, "", ErrContentMismatch},
+		{"video/mp4", jpg.Bytes(), "", ErrContentMismatch},
+		{"image/png", jpg.Bytes(), "", ErrUnsupportedType},
+	}
+	for _, c := range cases {
+		if kind, err := Check(c.contentType, c.head); kind != c.kind || err != c.err {
+			t.Errorf("Check(%s) = %q, %v; want %q, %v", c.contentType, kind, err, c.kind, c.err)
+		}
+	}
+}
+
+func TestThumbnail(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 1280, 720))
+	for x := 0; x < 640; x++ {
+		for y := 0; y < 720; y++ {
+			src.Set(x, y, color.White)
+		}
+	}
+	var buf bytes.Buffer
+	png.Encode(&buf, src)
+
+	thumb, err := Thumbnail(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := jpeg.Decode(bytes.NewReader(thumb))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != ThumbnailSize || b.Dy() != 180 {
+		t.Fatalf("thumbnail is %dx%d, want 320x180", b.Dx(), b.Dy())
+	}
+	// The left half stays white and the right half black
+	if r, _, _, _ := img.At(40, 90).RGBA(); r>>8 < 240 {
+		t.Errorf("left pixel r=%d, want white", r>>8)
+	}
+	if r, _, _, _ := img.At(280, 90).RGBA(); r>>8 > 15 {
+		t.Errorf("right pixel r=%d, want black", r>>8)
+	}
+
+	// Small images keep their size
+	buf.Reset()
+	png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 100, 50)))
+	thumb, _ = Thumbnail(&buf)
+	if cfg, _ := jpeg.DecodeConfig(bytes.NewReader(thumb)); cfg.Width != 100 || cfg.Height != 50 {
+		t.Errorf("small thumbnail is %dx%d", cfg.Width, cfg.Height)
+	}
+
+	// A tiny PNG declaring 100000x100000 pixels is refused before decoding
+	buf.Reset()
+	png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1)))
+	bomb := buf.Bytes()
+	binary.BigEndian.PutUint32(bomb[16:], 100000) // IHDR width
+	binary.BigEndian.PutUint32(bomb[20:], 100000) // IHDR height
+	binary.BigEndian.PutUint32(bomb[29:], crc32.ChecksumIEEE(bomb[12:29]))
+	if _, err := Thumbnail(bytes.NewReader(bomb)); err != ErrImageTooLarge {
+		t.Errorf("oversized image: got %v, want ErrImageTooLarge", err)
+	}
+}
+
+func TestSigner(t *testing.T) {
+	s := NewSigner("secret", 15*time.Minute)
+	path, expires := s.URL("/api/v2", 7, "file", t0)
+	if !expires.Equal(t0.Add(15 * time.Minute)) {
+		t.Errorf("expires = %s", expires)
+	}
+	u, err := url.Parse(path)
+	if err != nil || u.Path != "/api/v2/evidence/7/file" {
+		t.Fatalf("URL = %q", path)
+	}
+	q := u.Query()
+	exp, sig := q.Get("expires"), q.Get("signature")
+
+	if !s.Verify(7, "file", exp, sig, t0.Add(15*time.Minute)) {
+		t.Error("valid URL rejected")
+	}
+	if s.Verify(7, "file", exp, sig, t0.Add(15*time.Minute+time.Second)) {
+		t.Error("expired URL accepted")
+	}
+	if s.Verify(8, "file", exp, sig, t0) || s.Verify(7, "thumbnail", exp, sig, t0) {
+		t.Error("signature accepted for other evidence")
+	}
+	if s.Verify(7, "file", exp, strings.Repeat("0", len(sig)), t0) {
+		t.Error("forged signature accepted")
+	}
+	if NewSigner("other", time.Minute).Verify(7, "file", exp, sig, t0) {
+		t.Error("signature accepted with another secret")
+	}
+}
+
+func TestRetention(t *testing.T) {
+	r := DefaultRetention()
+	if got := r.ExpiresAt(models.EvidenceSnapshot, t0); !got.Equal(t0.AddDate(0, 0, 30)) {
+		t.Errorf("snapshot expires %s", got)
+	}
+	if got := r.ExpiresAt(models.EvidenceClip, t0); !got.Equal(t0.AddDate(0, 0, 7)) {
+		t.Errorf("clip expires %s", got)
+	}
+}
@@ -0,0 +1,100 @@
+package evidence
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	_ "image/png" // posters may be PNG
+	"io"
+	"os/exec"
+)
+
+// ThumbnailSize is the longest side of generated thumbnails in pixels
+const ThumbnailSize = 320
+
+// MaxImagePixels bounds the images Thumbnail decodes, well above camera
+// frames: a small upload may declare dimensions that take gigabytes to decode
+const MaxImagePixels = 40 * 1000 * 1000
+
+// ErrNoThumbnailer is returned for clips when ffmpeg is not installed
+var ErrNoThumbnailer = errors.New("ffmpeg not available for clip thumbnails")
+
+// ErrImageTooLarge is returned for images over MaxImagePixels
+var ErrImageTooLarge = errors.New("image dimensions exceed the thumbnail pixel budget")
+
+// Thumbnail decodes an image and returns a JPEG scaled to fit ThumbnailSize.
+// The dimensions in the header are checked before anything is decoded.
+func Thumbnail(r io.Reader) ([]byte, error) {
+	var head bytes.Buffer
+	cfg, _, err := image.DecodeConfig(io.TeeReader(r, &head))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || int64(cfg.Width)*int64(cfg.Height) > MaxImagePixels {
+		return nil, ErrImageTooLarge
+	}
+	src, _, err := image.Decode(io.MultiReader(&head, r))
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, scale(src, ThumbnailSize), &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// scale shrinks src to fit max x max by averaging each block of source
+// pixels; smaller images are returned unchanged
+func scale(src image.Image, max int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= max && h <= max {
+		return src
+	}
+	dw, dh := max, h*max/w
+	if h > w {
+		dw, dh = w*max/h, max
+	}
+	if dw < 1 {
+		dw = 1
+	}
+	if dh < 1 {
+		dh = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := b.Min.Y+y*h/dh, b.Min.Y+(y+1)*h/dh
+		for x := 0; x < dw; x++ {
+			x0, x1 := b.Min.X+x*w/dw, b.Min.X+(x+1)*w/dw
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a, n = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca), n+1
+				}
+			}
+			dst.Set(x, y, color.RGBA64{uint16(r / n), uint16(g / n), uint16(bl / n), uint16(a / n)})
+		}
+	}
+	return dst
+}
+
+// ClipThumbnail extracts the first frame of the video at path with ffmpeg
+// and scales it like Thumbnail
+func ClipThumbnail(ctx context.Context, path string) ([]byte, error) {
+	ffmpeg, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return nil, ErrNoThumbnailer
+	}
+	frame, err := exec.CommandContext(ctx, ffmpeg, "-v", "error", "-i", path,
+		"-frames:v", "1", "-f", "image2pipe", "-vcodec", "mjpeg", "-").Output()
+	if err != nil {
+		return nil, err
+	}
+	return Thumbnail(bytes.NewReader(frame))
+}
@@ -0,0 +1,63 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+	"time"
+)
+
+// csvWriter writes RFC 4180 CSV with a UTF-8 byte order mark,
+// which Excel needs to show Thai text correctly
+type csvWriter struct {
+	out     io.Writer
+	w       *csv.Writer
+	loc     *time.Location
+	started bool
+	record  []string
+}
+
+// NewCSV returns a CSV writer on w
+func NewCSV(w io.Writer, loc *time.Location) Writer {
+	return &csvWriter{out: w, w: csv.NewWriter(w), loc: loc}
+}
+
+// formulaPrefixes start text that spreadsheets would evaluate as a formula
+const formulaPrefixes = "=+-@\t\r"
+
+// bom is written before the first row
+func (c *csvWriter) bom() error {
+	if c.started {
+		return nil
+	}
+	c.started = true
+	_, err := io.WriteString(c.out, "\uFEFF")
+	return err
+}
+
+func (c *csvWriter) Row(cells ...interface{}) error {
+	if err := c.bom(); err != nil {
+		return err
+	}
+	c.record = c.record[:0]
+	for _, cell := range cells {
+		s, err := text(cell, c.loc)
+		if err != nil {
+			return err
+		}
+		// Device-supplied text must not become a formula in Excel
+		if _, ok := cell.(string); ok && s != "" && strings.ContainsRune(formulaPrefixes, rune(s[0])) {
+			s = "'" + s
+		}
+		c.record = append(c.record, s)
+	}
+	return c.w.Write(c.record)
+}
+
+func (c *csvWriter) Close() error {
+	if err := c.bom(); err != nil {
+		return err
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
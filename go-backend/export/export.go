@@ -0,0 +1,79 @@
+// Package export writes tabular reports as CSV or XLSX one row at a time,
+// so exports of any size stream straight to the response
+package export
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Format is a supported export file format
+type Format string
+
+const (
+	CSV  Format = "csv"
+	XLSX Format = "xlsx"
+)
+
+// ParseFormat accepts "csv" or "xlsx" in any case; empty means CSV
+func ParseFormat(s string) (Format, bool) {
+	switch Format(strings.ToLower(s)) {
+	case "", CSV:
+		return CSV, true
+	case XLSX:
+		return XLSX, true
+	}
+	return "", false
+}
+
+// ContentType returns the MIME type of the format
+func (f Format) ContentType() string {
+	if f == XLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// Writer writes one table. Cells may be string, int, float64, bool,
+// time.Time or nil (empty); times are shown on the wall clock of the
+// writer's location.
+type Writer interface {
+	Row(cells ...interface{}) error
+	// Close flushes the table; the output is incomplete until it is called
+	Close() error
+}
+
+// New returns a writer of the format on w. sheet names the XLSX worksheet.
+func New(f Format, w io.Writer, loc *time.Location, sheet string) Writer {
+	if f == XLSX {
+		return NewXLSX(w, loc, sheet)
+	}
+	return NewCSV(w, loc)
+}
+
+// TimeLayout is how CSV exports show times
+const TimeLayout = "2006-01-02 15:04:05"
+
+// text formats a cell as CSV shows it
+func text(cell interface{}, loc *time.Location) (string, error) {
+	switch v := cell.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case int:
+		return fmt.Sprint(v), nil
+	case float64:
+		return fmt.Sprint(v), nil
+	case bool:
+		if v {
+			return "TRUE", nil
+		}
+		return "FALSE", nil
+	case time.Time:
+		return v.In(loc).Format(TimeLayout), nil
+	}
+	return "", fmt.Errorf("export: unsupported cell type %T", cell)
+}
@@ -0,0 +1,141 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+var bangkok = time.FixedZone("ICT", 7*3600)
+
+// t0 is 2025-11-09 10:30:15 Bangkok time
+var t0 = time.Date(2025, 11, 9, 3, 30, 15, 0, time.UTC)
+
+func TestCSV(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewCSV(&buf, bangkok)
+	w.Row("เวลา", "อุปกรณ์", "ค่า", "รับทราบ")
+	w.Row(t0, "=HYPERLINK(\"x\")", 0.25, true)
+	w.Row(nil, "pi-01, cab", -3, false)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	if !strings.HasPrefix(out, "\uFEFF") {
+		t.Fatalf("missing byte order mark: %q", out)
+	}
+	records, err := csv.NewReader(strings.NewReader(strings.TrimPrefix(out, "\uFEFF"))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"เวลา", "อุปกรณ์", "ค่า", "รับทราบ"},
+		{"2025-11-09 10:30:15", "'=HYPERLINK(\"x\")", "0.25", "TRUE"},
+		{"", "pi-01, cab", "-3", "FALSE"},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("got %d records, want %d", len(records), len(want))
+	}
+	for i := range want {
+		if strings.Join(records[i], "|") != strings.Join(want[i], "|") {
+			t.Errorf("record %d = %q, want %q", i, records[i], want[i])
+		}
+	}
+
+	// An empty export is still a valid file
+	buf.Reset()
+	if err := NewCSV(&buf, bangkok).Close(); err != nil || buf.String() != "\uFEFF" {
+		t.Errorf("empty export = %q, %v", buf.String(), err)
+	}
+}
+
+type sheetXML struct {
+	Rows []struct {
+		R     int `xml:"r,attr"`
+		Cells []struct {
+			Ref    string `xml:"r,attr"`
+			Type   string `xml:"t,attr"`
+			Style  string `xml:"s,attr"`
+			Value  string `xml:"v"`
+			Inline string `xml:"is>t"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// readPart returns one file of a zip archive
+func readPart(t *testing.T, data []byte, name string) []byte {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("open workbook: %v", err)
+	}
+	f, err := zr.Open(name)
+	if err != nil {
+		t.Fatalf("open %s: %v", name, err)
+	}
+	defer f.Close()
+	body, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func TestXLSX(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewXLSX(&buf, bangkok, "ประวัติ/history")
+	w.Row("เวลา", "อุปกรณ์", "ค่า", "รับทราบ")
+	w.Row(t0, "pi-01 <cab> & co", 0.25, true)
+	w.Row(nil, "", 7, false)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, part := range []string{"[Content_Types].xml", "_rels/.rels", "xl/_rels/workbook.xml.rels", "xl/styles.xml"} {
+		readPart(t, buf.Bytes(), part)
+	}
+	if book := string(readPart(t, buf.Bytes(), "xl/workbook.xml")); !strings.Contains(book, `name="ประวัติhistory"`) {
+		t.Errorf("sheet name not sanitized: %s", book)
+	}
+
+	var sheet sheetXML
+	if err := xml.Unmarshal(readPart(t, buf.Bytes(), "xl/worksheets/sheet1.xml"), &sheet); err != nil {
+		t.Fatalf("parse sheet: %v", err)
+	}
+	if len(sheet.Rows) != 3 {
+		t.Fatalf("got %d rows, want 3", len(sheet.Rows))
+	}
+	header := sheet.Rows[0].Cells
+	if header[0].Inline != "เวลา" || header[0].Style != "2" || header[3].Ref != "D1" {
+		t.Errorf("header = %+v", header)
+	}
+	data := sheet.Rows[1].Cells
+	// 2025-11-09 10:30:15 is day 45970 plus 37815 seconds
+	if data[0].Style != "1" || !strings.HasPrefix(data[0].Value, "45970.4376") {
+		t.Errorf("time cell = %+v", data[0])
+	}
+	if data[1].Inline != "pi-01 <cab> & co" || data[1].Type != "inlineStr" {
+		t.Errorf("text cell = %+v", data[1])
+	}
+	if data[2].Value != "0.25" || data[3].Type != "b" || data[3].Value != "1" {
+		t.Errorf("number and bool cells = %+v", data[2:])
+	}
+	// Empty cells are skipped rather than written
+	if last := sheet.Rows[2].Cells; len(last) != 3 || last[0].Ref != "B3" || last[1].Ref != "C3" {
+		t.Errorf("row 3 = %+v", last)
+	}
+}
+
+func TestColumn(t *testing.T) {
+	for i, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"} {
+		if got := column(i); got != want {
+			t.Errorf("column(%d) = %s, want %s", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package export
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// xlsxWriter writes a single-sheet Office Open XML workbook. The zip
+// entries are written in order and the sheet last, so rows go straight
+// to the output through the deflate stream. The first row is the header:
+// it is bold and frozen above the scrolling rows.
+type xlsxWriter struct {
+	zip     *zip.Writer
+	sheet   *bufio.Writer
+	loc     *time.Location
+	name    string
+	started bool
+	row     int
+}
+
+// NewXLSX returns an XLSX writer on w with one worksheet called sheet
+func NewXLSX(w io.Writer, loc *time.Location, sheet string) Writer {
+	return &xlsxWriter{zip: zip.NewWriter(w), loc: loc, name: sheetName(sheet)}
+}
+
+// sheetName drops the characters Excel forbids and keeps 31 runes
+func sheetName(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return -1
+		}
+		return r
+	}, s)
+	if r := []rune(s); len(r) > 31 {
+		s = string(r[:31])
+	}
+	if s == "" {
+		return "Sheet1"
+	}
+	return s
+}
+
+// Cell styles in xlsxStyles: 1 is a date and time, 2 the bold header
+const (
+	styleTime   = 1
+	styleHeader = 2
+)
+
+const xlsxContentTypes = xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>
+<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+</Types>`
+
+const xlsxRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`
+
+const xlsxWorkbook = xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets>
+</workbook>`
+
+const xlsxWorkbookRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>
+</Relationships>`
+
+const xlsxStyles = xml.Header + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<numFmts count="1"><numFmt numFmtId="164" formatCode="yyyy-mm-dd hh:mm:ss"/></numFmts>
+<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>
+<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>
+<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>
+<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>
+<cellXfs count="3">
+<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>
+<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>
+<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>
+</cellXfs>
+</styleSheet>`
+
+const xlsxSheetStart = xml.Header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>
+<sheetData>`
+
+const xlsxSheetEnd = `</sheetData></worksheet>`
+
+// start writes every part but the sheet, then opens the sheet entry
+func (x *xlsxWriter) start() error {
+	if x.started {
+		return nil
+	}
+	x.started = true
+	var name strings.Builder
+	xml.EscapeText(&name, []byte(x.name))
+	parts := []struct{ path, body string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRels},
+		{"xl/workbook.xml", fmt.Sprintf(xlsxWorkbook, name.String())},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+		{"xl/styles.xml", xlsxStyles},
+	}
+	for _, p := range parts {
+		w, err := x.zip.Create(p.path)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, p.body); err != nil {
+			return err
+		}
+	}
+	w, err := x.zip.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	x.sheet = bufio.NewWriter(w)
+	_, err = x.sheet.WriteString(xlsxSheetStart)
+	return err
+}
+
+// column returns the letters of a zero-based column index (A, B, ..., AA)
+func column(i int) string {
+	var b []byte
+	for i++; i > 0; i = (i - 1) / 26 {
+		b = append([]byte{byte('A' + (i-1)%26)}, b...)
+	}
+	return string(b)
+}
+
+// excelEpoch is day zero of the 1900 date system as Excel counts it
+var excelEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// serial returns the Excel date serial of the wall-clock time of t in loc
+func serial(t time.Time, loc *time.Location) float64 {
+	l := t.In(loc)
+	wall := time.Date(l.Year(), l.Month(), l.Day(), l.Hour(), l.Minute(), l.Second(), l.Nanosecond(), time.UTC)
+	return wall.Sub(excelEpoch).Hours() / 24
+}
+
+func (x *xlsxWriter) Row(cells ...interface{}) error {
+	if err := x.start(); err != nil {
+		return err
+	}
+	x.row++
+	w := x.sheet
+	fmt.Fprintf(w, `<row r="%d">`, x.row)
+	for i, cell := range cells {
+		ref := column(i) + strconv.Itoa(x.row)
+		style := ""
+		if x.row == 1 {
+			style = fmt.Sprintf(` s="%d"`, styleHeader)
+		}
+		switch v := cell.(type) {
+		case nil:
+			continue
+		case string:
+			fmt.Fprintf(w, `<c r="%s"%s t="inlineStr"><is><t xml:space="preserve">`, ref, style)
+			xml.EscapeText(w, []byte(v))
+			w.WriteString(`</t></is></c>`)
+		case int:
+			fmt.Fprintf(w, `<c r="%s"%s><v>%d</v></c>`, ref, style, v)
+		case float64:
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				continue
+			}
+			fmt.Fprintf(w, `<c r="%s"%s><v>%s</v></c>`, ref, style, strconv.FormatFloat(v, 'g', -1, 64))
+		case bool:
+			b := 0
+			if v {
+				b = 1
+			}
+			fmt.Fprintf(w, `<c r="%s"%s t="b"><v>%d</v></c>`, ref, style, b)
+		case time.Time:
+			fmt.Fprintf(w, `<c r="%s" s="%d"><v>%s</v></c>`, ref, styleTime,
+				strconv.FormatFloat(serial(v, x.loc), 'f', -1, 64))
+		default:
+			return fmt.Errorf("export: unsupported cell type %T", cell)
+		}
+	}
+	_, err := w.WriteString(`</row>`)
+	return err
+}
+
+func (x *xlsxWriter) Close() error {
+	if err := x.start(); err != nil {
+		return err
+	}
+	if _, err := x.sheet.WriteString(xlsxSheetEnd); err != nil {
+		return err
+	}
+	if err := x.sheet.Flush(); err != nil {
+		return err
+	}
+	return x.zip.Close()
+}
@@ -0,0 +1,195 @@
+// Package faceid verifies who is driving by comparing the face embedding a
+// device computes at trip start with the embeddings drivers enrolled
+package faceid
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"driver-drowsiness-backend/models"
+	"driver-drowsiness-backend/repository"
+)
+
+// Embedding limits. Real face embeddings have 128 to 512 dimensions; the
+// upper bound also keeps raw pixel data from being stored as an "embedding".
+const (
+	MinDimensions = 64
+	MaxDimensions = 1024
+
+	// DefaultThreshold is the cosine similarity at which two embeddings
+	// are taken to be the same person
+	DefaultThreshold = 0.6
+)
+
+// cacheTTL bounds how long enrolments made through other replicas go unseen
+const cacheTTL = time.Minute
+
+// ErrInvalidEmbedding is returned for vectors that cannot be a face embedding
+var ErrInvalidEmbedding = errors.New("embedding must have 64 to 1024 finite values and must not be all zero")
+
+// Normalize validates an embedding and returns it scaled to unit length
+func Normalize(v []float64) ([]float64, error) {
+	if len(v) < MinDimensions || len(v) > MaxDimensions {
+		return nil, ErrInvalidEmbedding
+	}
+	var sum float64
+	for _, x := range v {
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return nil, ErrInvalidEmbedding
+		}
+		sum += x * x
+	}
+	if sum == 0 {
+		return nil, ErrInvalidEmbedding
+	}
+	norm := math.Sqrt(sum)
+	out := make([]float64, len(v))
+	for i, x := range v {
+		out[i] = x / norm
+	}
+	return out, nil
+}
+
+// Cosine returns the cosine similarity of two embeddings, 0 when their
+// dimensions differ or either is all zero
+func Cosine(a, b []float64) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += a[i] * b[i]
+		na += a[i] * a[i]
+		nb += b[i] * b[i]
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
+
+// candidateKey identifies the references a probe can be compared with
+type candidateKey struct {
+	model      string
+	dimensions int
+}
+
+// candidates is a loaded set of references; it is never modified once loaded
+type candidates struct {
+	refs     []models.FaceEmbedding
+	loadedAt time.Time
+}
+
+// Verifier checks trip-start faces against the enrolled references, which
+// it caches per model. v.mu guards the cache only; the repository is never
+// called with it held.
+type Verifier struct {
+	faces     repository.FaceRepository
+	threshold float64
+
+	mu         sync.Mutex
+	cache      map[candidateKey]*candidates
+	generation int // bumped by Invalidate so loads started before it are dropped
+}
+
+// NewVerifier creates a Verifier accepting matches at or above threshold
+func NewVerifier(faces repository.FaceRepository, threshold float64) *Verifier {
+	return &Verifier{faces: faces, threshold: threshold, cache: make(map[candidateKey]*candidates)}
+}
+
+// Threshold returns the similarity at which faces match
+func (v *Verifier) Threshold() float64 {
+	return v.threshold
+}
+
+// Invalidate forces a reload of the references on the next check; call it
+// after enrolling or deleting embeddings
+func (v *Verifier) Invalidate() {
+	v.mu.Lock()
+	v.cache = make(map[candidateKey]*candidates)
+	v.generation++
+	v.mu.Unlock()
+}
+
+// candidates returns the cached references of a model and dimension count,
+// reloading them when stale
+func (v *Verifier) candidates(ctx context.Context, key candidateKey) ([]models.FaceEmbedding, error) {
+	v.mu.Lock()
+	c, generation := v.cache[key], v.generation
+	v.mu.Unlock()
+	if c != nil && time.Since(c.loadedAt) < cacheTTL {
+		return c.refs, nil
+	}
+
+	refs, err := v.faces.Candidates(ctx, key.model, key.dimensions)
+	if err != nil {
+		return nil, err
+	}
+	v.mu.Lock()
+	if v.generation == generation {
+		v.cache[key] = &candidates{refs: refs, loadedAt: time.Now()}
+	}
+	v.mu.Unlock()
+	return refs, nil
+}
+
+// Check compares a face embedding of the given model with every enrolled
+// reference of the same model. The expected driver wins when they match;
+// otherwise the best matching other driver takes the trip, else nobody.
+func (v *Verifier) Check(ctx context.Context, expectedDriverID int, embedding []float64, model string) (models.IdentityCheck, error) {
+	check := models.IdentityCheck{ExpectedDriverID: expectedDriverID}
+	probe, err := Normalize(embedding)
+	if err != nil {
+		return check, err
+	}
+	refs, err := v.candidates(ctx, candidateKey{model: model, dimensions: len(probe)})
+	if err != nil {
+		return check, err
+	}
+
+	// Best similarity per enrolled driver
+	best := make(map[int]float64)
+	for _, ref := range refs {
+		sim := Cosine(probe, ref.Vector)
+		if prev, ok := best[ref.UserID]; !ok || sim > prev {
+			best[ref.UserID] = sim
+		}
+	}
+
+	expected, enrolled := best[expectedDriverID]
+	if expectedDriverID != 0 && enrolled && expected >= v.threshold {
+		check.Status, check.DriverID, check.Similarity = models.IdentityVerified, expectedDriverID, &expected
+		return check, nil
+	}
+	other, top := 0, math.Inf(-1)
+	for userID, sim := range best {
+		if userID != expectedDriverID && (sim > top || (sim == top && userID < other)) {
+			other, top = userID, sim
+		}
+	}
+	if other != 0 && top >= v.threshold {
+		check.Status, check.DriverID, check.Similarity = models.IdentityReassigned, other, &top
+		return check, nil
+	}
+
+	if enrolled && expected > top {
+		top = expected
+	}
+	if len(best) > 0 {
+		check.Similarity = &top
+	}
+	if expectedDriverID != 0 && !enrolled {
+		// Without a reference the expected driver cannot be ruled out
+		check.Status, check.DriverID = models.IdentityNotEnrolled, expectedDriverID
+		return check, nil
+	}
+	if len(best) == 0 {
+		check.Status = models.IdentityNotEnrolled
+		return check, nil
+	}
+	check.Status = models.IdentityUnrecognized
+	return check, nil
+}
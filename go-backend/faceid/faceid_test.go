@@ -0,0 +1,150 @@
+package faceid
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"driver-drowsiness-backend/models"
+	"driver-drowsiness-backend/repository"
+)
+
+// face returns a 128-dimensional embedding pointing mostly along axis,
+// tilted towards axis+1 by tilt
+func face(axis int, tilt float64) []float64 {
+	v := make([]float64, 128)
+	v[axis] = 1
+	v[axis+1] = tilt
+	return v
+}
+
+func TestNormalize(t *testing.T) {
+	v, err := Normalize(face(0, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(v[0]-1/math.Sqrt2) > 1e-9 || math.Abs(Cosine(v, v)-1) > 1e-9 {
+		t.Errorf("normalized = %v", v[:2])
+	}
+	bad := [][]float64{
+		make([]float64, 128),  // all zero
+		make([]float64, 32),   // too short
+		make([]float64, 4096), // an image, not an embedding
+		append(face(0, 0)[:127], math.NaN()),
+	}
+	for _, v := range bad {
+		if _, err := Normalize(v); err != ErrInvalidEmbedding {
+			t.Errorf("Normalize(len %d) error = %v", len(v), err)
+		}
+	}
+}
+
+func TestCosine(t *testing.T) {
+	if got := Cosine(face(0, 0), face(0, 1)); math.Abs(got-1/math.Sqrt2) > 1e-9 {
+		t.Errorf("Cosine at 45° = %f", got)
+	}
+	if got := Cosine(face(0, 0), face(2, 0)); got != 0 {
+		t.Errorf("Cosine of orthogonal = %f", got)
+	}
+	if got := Cosine(face(0, 0), make([]float64, 64)); got != 0 {
+		t.Errorf("Cosine of mismatched dimensions = %f", got)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore()
+	enroll := func(userID, axis int, model string) {
+		v, _ := Normalize(face(axis, 0))
+		store.Faces.Enroll(ctx, &models.FaceEmbedding{UserID: userID, Model: model, Vector: v, CreatedAt: time.Now()})
+	}
+	enroll(1, 0, "facenet")
+	enroll(2, 10, "facenet")
+	enroll(3, 20, "arcface") // another model is never compared
+	v := NewVerifier(store.Faces, DefaultThreshold)
+
+	cases := []struct {
+		name     string
+		expected int
+		probe    []float64
+		model    string
+		status   string
+		driver   int
+	}{
+		{"expected driver", 1, face(0, 0.3), "facenet", models.IdentityVerified, 1},
+		{"another enrolled driver", 1, face(10, 0.3), "facenet", models.IdentityReassigned, 2},
+		{"stranger", 1, face(40, 0), "facenet", models.IdentityUnrecognized, 0},
+		{"stranger without expected driver", 0, face(40, 0), "facenet", models.IdentityUnrecognized, 0},
+		{"expected driver not enrolled", 4, face(40, 0), "facenet", models.IdentityNotEnrolled, 4},
+		{"no references of the model", 3, face(20, 0), "facenet", models.IdentityNotEnrolled, 3},
+	}
+	for _, c := range cases {
+		got, err := v.Check(ctx, c.expected, c.probe, c.model)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if got.Status != c.status || got.DriverID != c.driver || got.ExpectedDriverID != c.expected {
+			t.Errorf("%s: got %+v, want %s for driver %d", c.name, got, c.status, c.driver)
+		}
+	}
+
+	got, _ := v.Check(ctx, 1, face(0, 0.3), "facenet")
+	if got.Similarity == nil || math.Abs(*got.Similarity-1/math.Sqrt(1.09)) > 1e-9 {
+		t.Errorf("similarity = %v", got.Similarity)
+	}
+	if _, err := v.Check(ctx, 1, face(0, 0)[:10], "facenet"); err != ErrInvalidEmbedding {
+		t.Errorf("short probe error = %v", err)
+	}
+}
+
+// countingFaces counts the candidate loads that reach the repository
+type countingFaces struct {
+	repository.FaceRepository
+	loads int
+}
+
+func (f *countingFaces) Candidates(ctx context.Context, model string, dimensions int) ([]models.FaceEmbedding, error) {
+	f.loads++
+	return f.FaceRepository.Candidates(ctx, model, dimensions)
+}
+
+func TestCheckCachesReferences(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore()
+	faces := &countingFaces{FaceRepository: store.Faces}
+	v := NewVerifier(faces, DefaultThreshold)
+	enroll := func(userID, axis int) {
+		ref, _ := Normalize(face(axis, 0))
+		store.Faces.Enroll(ctx, &models.FaceEmbedding{UserID: userID, Model: "facenet", Vector: ref, CreatedAt: time.Now()})
+	}
+	enroll(1, 0)
+
+	for i := 0; i < 3; i++ {
+		if got, _ := v.Check(ctx, 1, face(0, 0.3), "facenet"); got.Status != models.IdentityVerified {
+			t.Fatalf("check %d: %+v", i, got)
+		}
+	}
+	if faces.loads != 1 {
+		t.Errorf("loads after 3 checks = %d, want 1", faces.loads)
+	}
+
+	// A new driver is only seen once the cache is invalidated
+	enroll(2, 10)
+	if got, _ := v.Check(ctx, 1, face(10, 0.3), "facenet"); got.Status != models.IdentityUnrecognized {
+		t.Errorf("before invalidate: %+v", got)
+	}
+	v.Invalidate()
+	if got, _ := v.Check(ctx, 1, face(10, 0.3), "facenet"); got.Status != models.IdentityReassigned || got.DriverID != 2 {
+		t.Errorf("after invalidate: %+v", got)
+	}
+	if faces.loads != 2 {
+		t.Errorf("loads = %d, want 2", faces.loads)
+	}
+
+	// Each model is loaded on its own
+	v.Check(ctx, 1, face(0, 0.3), "arcface")
+	if faces.loads != 3 {
+		t.Errorf("loads after another model = %d, want 3", faces.loads)
+	}
+}
@@ -0,0 +1,230 @@
+// Package fatigue derives eye-closure metrics and a fatigue score from the
+// raw eye_closure stream, so levels do not depend on device firmware.
+package fatigue
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"driver-drowsiness-backend/models"
+)
+
+// Config tunes the sliding-window metrics
+type Config struct {
+	Window          time.Duration // length of the sliding window
+	ClosedThreshold float64       // eye_closure at or above which the eyes count as closed (P80)
+	MicrosleepMin   time.Duration // closures at least this long are microsleeps, shorter ones blinks
+	MaxInterval     time.Duration // longest time a single sample may stand for
+}
+
+// DefaultConfig returns a one-minute P80 window with 500ms microsleeps
+func DefaultConfig() Config {
+	return Config{
+		Window:          time.Minute,
+		ClosedThreshold: 0.8,
+		MicrosleepMin:   500 * time.Millisecond,
+		MaxInterval:     2 * time.Second,
+	}
+}
+
+// Update is the outcome of one observed sample
+type Update struct {
+	Metrics models.FatigueMetrics // window metrics after the sample
+
+	// Increments credited by this sample, for per-session totals
+	ObservedSeconds   float64 // time covered by the previous sample
+	ClosedSeconds     float64 // part of ObservedSeconds with the eyes closed
+	Blinks            int     // closures that ended with this sample and were blinks
+	MicrosleepSeconds float64 // duration of a microsleep that ended with this sample, if any
+}
+
+type point struct {
+	at     time.Time
+	closed bool
+	dur    time.Duration // time until the next sample, capped at MaxInterval
+}
+
+type closure struct {
+	end time.Time
+	dur time.Duration
+}
+
+// window is the recent history of one device
+type window struct {
+	points      []point
+	closures    []closure  // ended closures, blinks and microsleeps alike
+	closedSince *time.Time // start of the ongoing closure
+	latest      models.FatigueMetrics
+}
+
+// Analyzer keeps a sliding window per device
+type Analyzer struct {
+	cfg     Config
+	mu      sync.Mutex
+	devices map[string]*window
+}
+
+// NewAnalyzer creates an Analyzer with the given settings
+func NewAnalyzer(cfg Config) *Analyzer {
+	return &Analyzer{cfg: cfg, devices: make(map[string]*window)}
+}
+
+// Latest returns the last computed metrics of a device
+func (a *Analyzer) Latest(deviceID string) (models.FatigueMetrics, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	w, ok := a.devices[deviceID]
+	if !ok {
+		return models.FatigueMetrics{}, false
+	}
+	return w.latest, true
+}
+
+// Sweep forgets the devices whose last sample is before cutoff, so the
+// analyzer only keeps windows of devices that are still reporting. It
+// returns how many devices were forgotten.
+func (a *Analyzer) Sweep(cutoff time.Time) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	n := 0
+	for id, w := range a.devices {
+		if w.latest.UpdatedAt.Before(cutoff) {
+			delete(a.devices, id)
+			n++
+		}
+	}
+	return n
+}
+
+// Observe adds a sample to the device window and recomputes its metrics.
+// Samples must arrive in timestamp order; older ones are ignored.
+func (a *Analyzer) Observe(deviceID string, at time.Time, eyeClosure float64) Update {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	w, ok := a.devices[deviceID]
+	if !ok {
+		w = &window{}
+		a.devices[deviceID] = w
+	}
+	closed := eyeClosure >= a.cfg.ClosedThreshold
+
+	var u Update
+	if n := len(w.points); n > 0 {
+		prev := &w.points[n-1]
+		if at.Before(prev.at) {
+			u.Metrics = w.latest
+			return u
+		}
+		prev.dur = at.Sub(prev.at)
+		if prev.dur > a.cfg.MaxInterval {
+			prev.dur = a.cfg.MaxInterval
+		}
+		u.ObservedSeconds = prev.dur.Seconds()
+		if prev.closed {
+			u.ClosedSeconds = u.ObservedSeconds
+		}
+
+		// A closure ends on the first open sample, or after a data gap
+		if w.closedSince != nil && (!closed || at.Sub(prev.at) > a.cfg.MaxInterval) {
+			end := prev.at.Add(prev.dur)
+			c := closure{end: end, dur: end.Sub(*w.closedSince)}
+			w.closures = append(w.closures, c)
+			w.closedSince = nil
+			if c.dur < a.cfg.MicrosleepMin {
+				u.Blinks = 1
+			} else {
+				u.MicrosleepSeconds = c.dur.Seconds()
+			}
+		}
+	}
+	if closed && w.closedSince == nil {
+		start := at
+		w.closedSince = &start
+	}
+	w.points = append(w.points, point{at: at, closed: closed})
+
+	w.evict(at.Add(-a.cfg.Window))
+	w.latest = a.metrics(w, at)
+	u.Metrics = w.latest
+	return u
+}
+
+// evict drops points and closures that ended before cutoff
+func (w *window) evict(cutoff time.Time) {
+	i := 0
+	for i < len(w.points)-1 && !w.points[i].at.Add(w.points[i].dur).After(cutoff) {
+		i++
+	}
+	w.points = w.points[i:]
+
+	j := 0
+	for j < len(w.closures) && w.closures[j].end.Before(cutoff) {
+		j++
+	}
+	w.closures = w.closures[j:]
+}
+
+// metrics summarizes the window ending at now
+func (a *Analyzer) metrics(w *window, now time.Time) models.FatigueMetrics {
+	var observed, closed time.Duration
+	for _, p := range w.points {
+		observed += p.dur
+		if p.closed {
+			closed += p.dur
+		}
+	}
+
+	m := models.FatigueMetrics{WindowSeconds: a.cfg.Window.Seconds(), UpdatedAt: now}
+	if observed > 0 {
+		m.Perclos = closed.Seconds() / observed.Seconds()
+	}
+	for _, c := range w.closures {
+		if c.dur < a.cfg.MicrosleepMin {
+			m.BlinkCount++
+			continue
+		}
+		m.MicrosleepCount++
+		m.MicrosleepSeconds += c.dur.Seconds()
+		m.LongestMicrosleepSeconds = math.Max(m.LongestMicrosleepSeconds, c.dur.Seconds())
+	}
+	// An ongoing long closure already counts as a microsleep
+	if w.closedSince != nil {
+		if d := now.Sub(*w.closedSince); d >= a.cfg.MicrosleepMin {
+			m.MicrosleepCount++
+			m.MicrosleepSeconds += d.Seconds()
+			m.LongestMicrosleepSeconds = math.Max(m.LongestMicrosleepSeconds, d.Seconds())
+		}
+	}
+	if observed > 0 {
+		m.BlinkRate = float64(m.BlinkCount) / observed.Minutes()
+	}
+
+	m.Score = Score(m)
+	m.Level = Level(m.Score)
+	return m
+}
+
+// Score combines window metrics into 0-100: PERCLOS weighs 60 (saturating
+// at 30%), microsleeps 30 (saturating at 3 per window) and an elevated
+// blink rate 10 (above 20 per minute, saturating at 40)
+func Score(m models.FatigueMetrics) float64 {
+	perclos := math.Min(m.Perclos/0.3, 1)
+	microsleeps := math.Min(float64(m.MicrosleepCount)/3, 1)
+	blinks := math.Min(math.Max(m.BlinkRate-20, 0)/20, 1)
+	score := 60*perclos + 30*microsleeps + 10*blinks
+	return math.Round(score*10) / 10
+}
+
+// Level classifies a score as low, medium or high
+func Level(score float64) string {
+	switch {
+	case score >= 60:
+		return "high"
+	case score >= 30:
+		return "medium"
+	default:
+		return "low"
+	}
+}
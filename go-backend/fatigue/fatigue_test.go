@@ -0,0 +1,109 @@
+package fatigue
+
+import (
+	"testing"
+	"time"
+
+	"driver-drowsiness-backend/models"
+)
+
+var t0 = time.Date(2025, 11, 9, 3, 0, 0, 0, time.UTC)
+
+// feed sends eye_closure values 100ms apart and returns the last update
+func feed(a *Analyzer, from time.Duration, values ...float64) Update {
+	var u Update
+	for i, v := range values {
+		u = a.Observe("device_01", t0.Add(from+time.Duration(i)*100*time.Millisecond), v)
+	}
+	return u
+}
+
+func TestBlinksAndMicrosleeps(t *testing.T) {
+	a := NewAnalyzer(DefaultConfig())
+
+	// 200ms blink, then a 700ms closure, then open again
+	u := feed(a, 0, 0.1, 0.9, 0.9, 0.1, 0.1, 0.9, 0.9, 0.9, 0.9, 0.9, 0.9, 0.9, 0.1)
+	m := u.Metrics
+	if m.BlinkCount != 1 || m.MicrosleepCount != 1 {
+		t.Fatalf("blinks=%d microsleeps=%d, want 1 and 1", m.BlinkCount, m.MicrosleepCount)
+	}
+	if d := m.LongestMicrosleepSeconds; d < 0.69 || d > 0.71 {
+		t.Errorf("longest microsleep = %v, want 0.7", d)
+	}
+	if u.MicrosleepSeconds == 0 {
+		t.Error("closing sample did not report the microsleep")
+	}
+	// 9 of 12 covered intervals closed
+	if p := m.Perclos; p < 0.74 || p > 0.76 {
+		t.Errorf("perclos = %v, want 0.75", p)
+	}
+	if m.Level != Level(m.Score) || m.Score <= 0 {
+		t.Errorf("score %v level %q", m.Score, m.Level)
+	}
+}
+
+func TestWindowEviction(t *testing.T) {
+	a := NewAnalyzer(Config{Window: time.Second, ClosedThreshold: 0.8, MicrosleepMin: 500 * time.Millisecond, MaxInterval: 2 * time.Second})
+
+	feed(a, 0, 0.9, 0.9, 0.1)
+	m := feed(a, 5*time.Second, 0.1, 0.1, 0.1).Metrics
+	if m.Perclos != 0 || m.BlinkCount != 0 {
+		t.Errorf("old samples still in window: %+v", m)
+	}
+}
+
+func TestSweepForgetsSilentDevices(t *testing.T) {
+	a := NewAnalyzer(DefaultConfig())
+	feed(a, 0, 0.1, 0.9)
+	a.Observe("device_02", t0.Add(10*time.Minute), 0.1)
+
+	if n := a.Sweep(t0.Add(5 * time.Minute)); n != 1 {
+		t.Errorf("swept %d devices, want 1", n)
+	}
+	if _, ok := a.Latest("device_01"); ok {
+		t.Error("silent device still has metrics")
+	}
+	if _, ok := a.Latest("device_02"); !ok {
+		t.Error("reporting device was forgotten")
+	}
+	// A forgotten device starts over without an open closure
+	if u := a.Observe("device_01", t0.Add(11*time.Minute), 0.1); u.MicrosleepSeconds != 0 || u.ObservedSeconds != 0 {
+		t.Errorf("update after sweep = %+v", u)
+	}
+}
+
+func TestGapEndsClosure(t *testing.T) {
+	a := NewAnalyzer(DefaultConfig())
+
+	a.Observe("device_01", t0, 0.9)
+	u := a.Observe("device_01", t0.Add(time.Minute), 0.9)
+	// The closure is capped at MaxInterval and a new one starts
+	if u.MicrosleepSeconds != 2 || u.ObservedSeconds != 2 {
+		t.Errorf("update after gap = %+v", u)
+	}
+	if old := a.Observe("device_01", t0, 0.1); old.ObservedSeconds != 0 {
+		t.Errorf("out-of-order sample credited %v seconds", old.ObservedSeconds)
+	}
+}
+
+func TestScoreAndLevel(t *testing.T) {
+	cases := []struct {
+		perclos     float64
+		microsleeps int
+		blinkRate   float64
+		score       float64
+		level       string
+	}{
+		{0, 0, 15, 0, "low"},
+		{0.15, 0, 15, 30, "medium"},
+		{0.3, 3, 40, 100, "high"},
+		{0.6, 10, 100, 100, "high"},
+	}
+	for _, c := range cases {
+		m := Score(models.FatigueMetrics{Perclos: c.perclos, MicrosleepCount: c.microsleeps, BlinkRate: c.blinkRate})
+		if m != c.score || Level(m) != c.level {
+			t.Errorf("perclos=%v microsleeps=%d blinks=%v: score %v %s, want %v %s",
+				c.perclos, c.microsleeps, c.blinkRate, m, Level(m), c.score, c.level)
+		}
+	}
+}
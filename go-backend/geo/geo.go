@@ -0,0 +1,96 @@
+// Package geo holds the planar geometry used for GPS tracks and GeoJSON,
+// kept in plain Go so the database needs no PostGIS.
+package geo
+
+import "math"
+
+const earthRadiusMeters = 6371000
+
+// Point is a WGS84 position in degrees
+type Point struct {
+	Lat float64
+	Lon float64
+}
+
+// Distance returns the great-circle distance between a and b in meters
+func Distance(a, b Point) float64 {
+	lat1, lat2 := radians(a.Lat), radians(b.Lat)
+	dLat := lat2 - lat1
+	dLon := radians(b.Lon - a.Lon)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusMeters * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// Simplify reduces a polyline with Douglas-Peucker, keeping every point
+// farther than tolerance meters from the simplified line. Endpoints are kept.
+func Simplify(points []Point, tolerance float64) []Point {
+	if len(points) < 3 || tolerance <= 0 {
+		return append([]Point(nil), points...)
+	}
+	keep := make([]bool, len(points))
+	keep[0], keep[len(points)-1] = true, true
+
+	// Iterative to stay safe on long tracks
+	type span struct{ first, last int }
+	stack := []span{{0, len(points) - 1}}
+	for len(stack) > 0 {
+		s := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		farthest, maxDist := -1, tolerance
+		for i := s.first + 1; i < s.last; i++ {
+			if d := segmentDistance(points[i], points[s.first], points[s.last]); d > maxDist {
+				farthest, maxDist = i, d
+			}
+		}
+		if farthest >= 0 {
+			keep[farthest] = true
+			stack = append(stack, span{s.first, farthest}, span{farthest, s.last})
+		}
+	}
+
+	var out []Point
+	for i, p := range points {
+		if keep[i] {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// Contains reports whether p lies inside the polygon ring, by ray casting on
+// lon/lat. The ring may be open or closed; points on an edge may go either way.
+func Contains(ring []Point, p Point) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a.Lat > p.Lat) != (b.Lat > p.Lat) &&
+			p.Lon < (b.Lon-a.Lon)*(p.Lat-a.Lat)/(b.Lat-a.Lat)+a.Lon {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// segmentDistance returns the distance in meters from p to the segment a-b,
+// on an equirectangular projection around a (accurate over short spans)
+func segmentDistance(p, a, b Point) float64 {
+	px, py := project(p, a)
+	bx, by := project(b, a)
+	t := 0.0
+	if l := bx*bx + by*by; l > 0 {
+		t = math.Max(0, math.Min(1, (px*bx+py*by)/l))
+	}
+	return math.Hypot(px-t*bx, py-t*by)
+}
+
+// project maps p to meters east/north of origin
+func project(p, origin Point) (x, y float64) {
+	x = radians(p.Lon-origin.Lon) * math.Cos(radians(origin.Lat)) * earthRadiusMeters
+	y = radians(p.Lat-origin.Lat) * earthRadiusMeters
+	return x, y
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
@@ -0,0 +1,77 @@
+package geo
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestDistance(t *testing.T) {
+	// Bangkok Victory Monument to Siam, roughly 2.3 km
+	d := Distance(Point{13.7649, 100.5383}, Point{13.7456, 100.5341})
+	if d < 2100 || d > 2300 {
+		t.Errorf("distance = %.0f m", d)
+	}
+	if d := Distance(Point{0, 0}, Point{0, 1}); math.Abs(d-111195) > 10 {
+		t.Errorf("one degree at the equator = %.0f m", d)
+	}
+}
+
+func TestSimplify(t *testing.T) {
+	// A straight road north with a small wobble, then a right turn
+	track := []Point{
+		{13.7000, 100.5000},
+		{13.7010, 100.50001},
+		{13.7020, 100.5000},
+		{13.7030, 100.49999},
+		{13.7040, 100.5000},
+		{13.7040, 100.5020},
+	}
+	got := Simplify(track, 10)
+	want := []Point{track[0], track[4], track[5]}
+	if len(got) != len(want) {
+		t.Fatalf("simplified to %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("point %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+	if got := Simplify(track, 0); len(got) != len(track) {
+		t.Errorf("zero tolerance dropped points: %v", got)
+	}
+}
+
+func TestGeoJSONCoordinateOrder(t *testing.T) {
+	fc := NewFeatureCollection([]Feature{PointFeature(Point{Lat: 13.75, Lon: 100.5}, nil)})
+	b, _ := json.Marshal(fc)
+	want := `{"type":"FeatureCollection","features":[{"type":"Feature","geometry":{"type":"Point","coordinates":[100.5,13.75]},"properties":null}]}`
+	if string(b) != want {
+		t.Errorf("got %s", b)
+	}
+}
+
+func TestContains(t *testing.T) {
+	// An L-shaped depot, open ring
+	ring := []Point{{0, 0}, {0, 2}, {1, 2}, {1, 1}, {2, 1}, {2, 0}}
+	cases := []struct {
+		p    Point
+		want bool
+	}{
+		{Point{0.5, 0.5}, true},
+		{Point{0.5, 1.5}, true},
+		{Point{1.5, 0.5}, true},
+		{Point{1.5, 1.5}, false}, // the notch
+		{Point{-1, 0.5}, false},
+		{Point{3, 3}, false},
+	}
+	for _, c := range cases {
+		if got := Contains(ring, c.p); got != c.want {
+			t.Errorf("Contains(%v) = %v, want %v", c.p, got, c.want)
+		}
+	}
+	closed := append(ring, ring[0])
+	if !Contains(closed, Point{0.5, 0.5}) || Contains(closed, Point{1.5, 1.5}) {
+		t.Error("closed ring evaluates differently")
+	}
+}
@@ -0,0 +1,56 @@
+package geo
+
+// FeatureCollection is a GeoJSON (RFC 7946) feature collection
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+}
+
+// Feature is a GeoJSON feature
+type Feature struct {
+	Type       string                 `json:"type"`
+	ID         interface{}            `json:"id,omitempty"`
+	Geometry   Geometry               `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// Geometry is a GeoJSON geometry; coordinates are [lon, lat] pairs
+type Geometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+// NewFeatureCollection wraps features, never encoding a null list
+func NewFeatureCollection(features []Feature) FeatureCollection {
+	if features == nil {
+		features = []Feature{}
+	}
+	return FeatureCollection{Type: "FeatureCollection", Features: features}
+}
+
+// PointFeature returns a Point feature at p
+func PointFeature(p Point, props map[string]interface{}) Feature {
+	return Feature{
+		Type:       "Feature",
+		Geometry:   Geometry{Type: "Point", Coordinates: position(p)},
+		Properties: props,
+	}
+}
+
+// LineFeature returns a LineString feature through points
+func LineFeature(points []Point, props map[string]interface{}) Feature {
+	coords := make([][2]float64, len(points))
+	for i, p := range points {
+		coords[i] = position(p)
+	}
+	return Feature{
+		Type:       "Feature",
+		Geometry:   Geometry{Type: "LineString", Coordinates: coords},
+		Properties: props,
+	}
+}
+
+// position orders a point as GeoJSON expects: longitude first
+func position(p Point) [2]float64 {
+	return [2]float64{p.Lon, p.Lat}
+}
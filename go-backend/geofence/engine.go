@@ -0,0 +1,225 @@
+// Package geofence evaluates device positions against geofences on
+// ingestion, recording enter/exit events and applying location rules.
+package geofence
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"driver-drowsiness-backend/geo"
+	"driver-drowsiness-backend/models"
+	"driver-drowsiness-backend/repository"
+)
+
+// cacheTTL bounds how long geofence edits made by other replicas go unseen
+const cacheTTL = 30 * time.Second
+
+// Match is a rule that applies at a position, with its geofence
+type Match struct {
+	Geofence models.Geofence
+	Rule     models.GeofenceRule
+}
+
+// Result is the outcome of evaluating one sample
+type Result struct {
+	Events      []models.GeofenceEvent
+	Escalations []Match // escalate rules that fired for the first time in this visit
+}
+
+// visit is what the engine remembers about a device; its lock orders the
+// samples of the device without holding up the others
+type visit struct {
+	mu     sync.Mutex
+	inside map[int]bool // geofence IDs the device is in; nil until resumed
+	fired  map[int]bool // geofences whose escalation already fired during the visit
+}
+
+// fenceSet is a loaded set of geofences with their rings; it is never
+// modified once loaded
+type fenceSet struct {
+	fences []models.Geofence
+	rings  map[int][]geo.Point
+}
+
+// Engine caches geofences and tracks which of them each device is inside.
+// e.mu guards the cache and the device map only; the repository is never
+// called with it held.
+type Engine struct {
+	repo repository.GeofenceRepository
+
+	mu         sync.Mutex
+	set        *fenceSet
+	loadedAt   time.Time
+	generation int // bumped by Invalidate so loads started before it are dropped
+	devices    map[string]*visit
+}
+
+// NewEngine creates an Engine over the geofence repository
+func NewEngine(repo repository.GeofenceRepository) *Engine {
+	return &Engine{repo: repo, devices: make(map[string]*visit)}
+}
+
+// Invalidate forces a reload of geofences on the next evaluation
+func (e *Engine) Invalidate() {
+	e.mu.Lock()
+	e.loadedAt = time.Time{}
+	e.generation++
+	e.mu.Unlock()
+}
+
+// fences returns the cached geofences, reloading them when stale
+func (e *Engine) fences(ctx context.Context) (*fenceSet, error) {
+	e.mu.Lock()
+	set, generation := e.set, e.generation
+	fresh := set != nil && !e.loadedAt.IsZero() && time.Since(e.loadedAt) < cacheTTL
+	e.mu.Unlock()
+	if fresh {
+		return set, nil
+	}
+
+	fences, err := e.repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	set = &fenceSet{fences: fences, rings: make(map[int][]geo.Point, len(fences))}
+	for _, f := range fences {
+		ring := make([]geo.Point, len(f.Polygon))
+		for i, p := range f.Polygon {
+			ring[i] = geo.Point{Lon: p[0], Lat: p[1]}
+		}
+		set.rings[f.ID] = ring
+	}
+
+	e.mu.Lock()
+	if e.generation == generation {
+		e.set, e.loadedAt = set, time.Now()
+	}
+	e.mu.Unlock()
+	return set, nil
+}
+
+// containing returns the geofences that contain p
+func (s *fenceSet) containing(p geo.Point) []models.Geofence {
+	var fences []models.Geofence
+	for _, f := range s.fences {
+		if geo.Contains(s.rings[f.ID], p) {
+			fences = append(fences, f)
+		}
+	}
+	return fences
+}
+
+// device returns the state of a device, creating it on first use
+func (e *Engine) device(deviceID string) *visit {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	v, ok := e.devices[deviceID]
+	if !ok {
+		v = &visit{fired: make(map[int]bool)}
+		e.devices[deviceID] = v
+	}
+	return v
+}
+
+// Observe evaluates a located sample: it records enter/exit events against
+// the device's previous position and returns the escalate rules that match
+// the sample level, each at most once per visit.
+func (e *Engine) Observe(ctx context.Context, deviceID string, fix models.GPSFix, level string, at time.Time) (Result, error) {
+	var res Result
+	set, err := e.fences(ctx)
+	if err != nil {
+		return res, err
+	}
+	v := e.device(deviceID)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.inside == nil {
+		// Resume from persisted events after a restart
+		ids, err := e.repo.Inside(ctx, deviceID)
+		if err != nil {
+			return res, err
+		}
+		v.inside = make(map[int]bool, len(ids))
+		for _, id := range ids {
+			v.inside[id] = true
+		}
+	}
+
+	now := make(map[int]bool)
+	for _, f := range set.containing(geo.Point{Lat: fix.Latitude, Lon: fix.Longitude}) {
+		now[f.ID] = true
+		if !v.inside[f.ID] {
+			res.Events = append(res.Events, e.event(f.ID, deviceID, "enter", fix, at))
+			delete(v.fired, f.ID)
+		}
+		if v.fired[f.ID] {
+			continue
+		}
+		for _, rule := range f.Rules {
+			if rule.Escalate && models.LevelRank(level) >= models.LevelRank(rule.Level) {
+				res.Escalations = append(res.Escalations, Match{Geofence: f, Rule: rule})
+				v.fired[f.ID] = true
+				break
+			}
+		}
+	}
+	for id := range v.inside {
+		if !now[id] {
+			res.Events = append(res.Events, e.event(id, deviceID, "exit", fix, at))
+			delete(v.fired, id)
+		}
+	}
+	v.inside = now
+
+	for i := range res.Events {
+		if err := e.repo.InsertEvent(ctx, &res.Events[i]); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+func (e *Engine) event(geofenceID int, deviceID, kind string, fix models.GPSFix, at time.Time) models.GeofenceEvent {
+	return models.GeofenceEvent{
+		GeofenceID: geofenceID,
+		DeviceID:   deviceID,
+		Event:      kind,
+		Latitude:   fix.Latitude,
+		Longitude:  fix.Longitude,
+		Timestamp:  at,
+	}
+}
+
+// ApplyRules sets the severity, routing and geofence of a located alert from
+// the strictest rule matching its severity; alerts without a match are untouched.
+func (e *Engine) ApplyRules(ctx context.Context, a *models.Alert) error {
+	if a.Location == nil {
+		return nil
+	}
+	set, err := e.fences(ctx)
+	if err != nil {
+		return err
+	}
+
+	var best *Match
+	rank := models.LevelRank(a.Severity)
+	for _, f := range set.containing(geo.Point{Lat: a.Location.Latitude, Lon: a.Location.Longitude}) {
+		for _, rule := range f.Rules {
+			if rank < models.LevelRank(rule.Level) || rule.Severity == "" {
+				continue
+			}
+			if best == nil || models.LevelRank(rule.Severity) > models.LevelRank(best.Rule.Severity) {
+				best = &Match{Geofence: f, Rule: rule}
+			}
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	if models.LevelRank(best.Rule.Severity) > rank {
+		a.Severity = best.Rule.Severity
+	}
+	a.GeofenceID = best.Geofence.ID
+	return nil
+}
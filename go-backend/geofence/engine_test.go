@@ -0,0 +1,166 @@
+package geofence
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"driver-drowsiness-backend/models"
+	"driver-drowsiness-backend/repository"
+)
+
+var t0 = time.Date(2025, 11, 9, 3, 0, 0, 0, time.UTC)
+
+// highway is a strip between lon 100.0 and 101.0, lat 13.0 and 13.1
+func newEngine(t *testing.T, rules ...models.GeofenceRule) (*Engine, *repository.Store) {
+	t.Helper()
+	store := repository.NewMemoryStore()
+	g := &models.Geofence{
+		Name: "Highway 1", Kind: "highway",
+		Polygon: [][2]float64{{100.0, 13.0}, {101.0, 13.0}, {101.0, 13.1}, {100.0, 13.1}},
+		Rules:   rules,
+	}
+	if err := store.Geofences.Create(context.Background(), g); err != nil {
+		t.Fatal(err)
+	}
+	return NewEngine(store.Geofences), store
+}
+
+func TestEnterExitEvents(t *testing.T) {
+	ctx := context.Background()
+	e, store := newEngine(t)
+
+	outside := models.GPSFix{Latitude: 12.9, Longitude: 100.5}
+	inside := models.GPSFix{Latitude: 13.05, Longitude: 100.5}
+	steps := []struct {
+		fix  models.GPSFix
+		want string
+	}{
+		{outside, ""}, {inside, "enter"}, {inside, ""}, {outside, "exit"},
+	}
+	for i, s := range steps {
+		res, err := e.Observe(ctx, "device_01", s.fix, "low", t0.Add(time.Duration(i)*time.Second))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := ""
+		if len(res.Events) == 1 {
+			got = res.Events[0].Event
+		}
+		if got != s.want || len(res.Events) > 1 {
+			t.Errorf("step %d: events %+v, want %q", i, res.Events, s.want)
+		}
+	}
+
+	events, _ := store.Geofences.Events(ctx, repository.GeofenceEventFilter{DeviceID: "device_01"})
+	if len(events) != 2 || events[0].Event != "exit" {
+		t.Errorf("stored events = %+v", events)
+	}
+}
+
+func TestStateSurvivesRestart(t *testing.T) {
+	ctx := context.Background()
+	e, store := newEngine(t)
+	inside := models.GPSFix{Latitude: 13.05, Longitude: 100.5}
+	e.Observe(ctx, "device_01", inside, "low", t0)
+
+	restarted := NewEngine(store.Geofences)
+	if res, _ := restarted.Observe(ctx, "device_01", inside, "low", t0.Add(time.Second)); len(res.Events) != 0 {
+		t.Errorf("re-entered after restart: %+v", res.Events)
+	}
+}
+
+func TestEscalationOncePerVisit(t *testing.T) {
+	ctx := context.Background()
+	e, _ := newEngine(t, models.GeofenceRule{Level: "high", Escalate: true})
+	inside := models.GPSFix{Latitude: 13.05, Longitude: 100.5}
+	outside := models.GPSFix{Latitude: 12.9, Longitude: 100.5}
+
+	counts := []int{}
+	for i, s := range []struct {
+		fix   models.GPSFix
+		level string
+	}{
+		{inside, "medium"}, {inside, "high"}, {inside, "high"}, {outside, "high"}, {inside, "high"},
+	} {
+		res, _ := e.Observe(ctx, "device_01", s.fix, s.level, t0.Add(time.Duration(i)*time.Second))
+		counts = append(counts, len(res.Escalations))
+	}
+	want := []int{0, 1, 0, 0, 1}
+	for i := range want {
+		if counts[i] != want[i] {
+			t.Fatalf("escalations per sample = %v, want %v", counts, want)
+		}
+	}
+}
+
+func TestApplyRules(t *testing.T) {
+	ctx := context.Background()
+	e, _ := newEngine(t,
+		models.GeofenceRule{Level: "medium", Severity: "high"},
+		models.GeofenceRule{Level: "high", Severity: "critical"},
+	)
+
+	a := models.Alert{Severity: "high", Location: &models.GPSFix{Latitude: 13.05, Longitude: 100.5}}
+	e.ApplyRules(ctx, &a)
+	if a.Severity != "critical" || a.GeofenceID == 0 {
+		t.Errorf("high alert inside = %+v", a)
+	}
+
+	a = models.Alert{Severity: "medium", Location: &models.GPSFix{Latitude: 13.05, Longitude: 100.5}}
+	e.ApplyRules(ctx, &a)
+	if a.Severity != "high" || a.GeofenceID == 0 {
+		t.Errorf("medium alert inside = %+v", a)
+	}
+
+	a = models.Alert{Severity: "high", Location: &models.GPSFix{Latitude: 14, Longitude: 100.5}}
+	e.ApplyRules(ctx, &a)
+	if a.Severity != "high" || a.GeofenceID != 0 {
+		t.Errorf("alert outside = %+v", a)
+	}
+}
+
+// slowInside blocks the resume lookup of one device until released
+type slowInside struct {
+	repository.GeofenceRepository
+	device  string
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (r *slowInside) Inside(ctx context.Context, deviceID string) ([]int, error) {
+	if deviceID == r.device {
+		close(r.entered)
+		<-r.release
+	}
+	return r.GeofenceRepository.Inside(ctx, deviceID)
+}
+
+func TestSlowDeviceDoesNotBlockOthers(t *testing.T) {
+	ctx := context.Background()
+	_, store := newEngine(t)
+	repo := &slowInside{GeofenceRepository: store.Geofences, device: "device_01",
+		entered: make(chan struct{}), release: make(chan struct{})}
+	e := NewEngine(repo)
+	inside := models.GPSFix{Latitude: 13.05, Longitude: 100.5}
+
+	blocked := make(chan struct{})
+	go func() {
+		e.Observe(ctx, "device_01", inside, "low", t0)
+		close(blocked)
+	}()
+	<-repo.entered
+	done := make(chan struct{})
+	go func() {
+		e.Observe(ctx, "device_02", inside, "low", t0)
+		e.ApplyRules(ctx, &models.Alert{Severity: "high", Location: &inside})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("device_02 waited for the lookup of device_01")
+	}
+	close(repo.release)
+	<-blocked
+}
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"driver-drowsiness-backend/models"
+	"driver-drowsiness-backend/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxAnalyticsBuckets bounds the size of a zero-filled response
+const maxAnalyticsBuckets = 2000
+
+// parseTimeParam accepts RFC3339 or a YYYY-MM-DD date taken as midnight in loc
+func parseTimeParam(value string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", value, loc)
+}
+
+// AdminAnalytics returns zero-filled time series of drowsiness samples.
+// Query: from, to (RFC3339 or YYYY-MM-DD; default today), bucket (15m|1h|1d|1w),
+// group_by (fleet|driver|device|vehicle), level (comma list, default medium,high),
+// fleet_id, driver_id, device_id and tz.
+func (s *Server) AdminAnalytics(c *gin.Context) {
+	noCache(c)
+	loc, ok := s.requestLocation(c)
+	if !ok {
+		return
+	}
+
+	q := repository.AnalyticsQuery{
+		Range:    repository.DayRange(s.now(), loc),
+		Bucket:   repository.Bucket(c.DefaultQuery("bucket", string(repository.Bucket1h))),
+		Location: loc,
+		GroupBy:  c.Query("group_by"),
+		DeviceID: c.Query("device_id"),
+	}
+	if !q.Bucket.Valid() {
+		respondError(c, http.StatusBadRequest, "bucket must be one of 15m, 1h, 1d, 1w")
+		return
+	}
+	if !repository.ValidGroup(q.GroupBy) {
+		respondError(c, http.StatusBadRequest, "group_by must be one of fleet, driver, device, vehicle")
+		return
+	}
+	if v := c.Query("from"); v != "" {
+		t, err := parseTimeParam(v, loc)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Invalid from")
+			return
+		}
+		q.Range.From = t.UTC()
+	}
+	if v := c.Query("to"); v != "" {
+		t, err := parseTimeParam(v, loc)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Invalid to")
+			return
+		}
+		q.Range.To = t.UTC()
+	}
+	if !q.Range.From.Before(q.Range.To) {
+		respondError(c, http.StatusBadRequest, "from must be before to")
+		return
+	}
+	for _, name := range []string{"fleet_id", "driver_id"} {
+		v := c.Query(name)
+		if v == "" {
+			continue
+		}
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Invalid "+name)
+			return
+		}
+		if name == "fleet_id" {
+			q.FleetID = id
+		} else {
+			q.DriverID = id
+		}
+	}
+	for _, level := range strings.Split(c.DefaultQuery("level", "medium,high"), ",") {
+		level = strings.ToLower(strings.TrimSpace(level))
+		switch level {
+		case "":
+		case "low", "medium", "high":
+			q.Levels = append(q.Levels, level)
+		default:
+			respondError(c, http.StatusBadRequest, "level must be low, medium or high")
+			return
+		}
+	}
+
+	// Every bucket overlapping [from, to), in order
+	var buckets []time.Time
+	for b := q.Bucket.Start(q.Range.From, loc); b.Before(q.Range.To); b = q.Bucket.Next(b) {
+		if len(buckets) == maxAnalyticsBuckets {
+			respondError(c, http.StatusBadRequest, "Too many buckets; narrow the range or widen the bucket")
+			return
+		}
+		buckets = append(buckets, b)
+	}
+
+	rows, err := s.store.Analytics.CountSamples(c.Request.Context(), q)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error querying analytics", "error", err)
+		respondError(c, http.StatusInternalServerError, "failed to fetch analytics")
+		return
+	}
+
+	series := zeroFill(rows, buckets, q.GroupBy == repository.GroupNone)
+	labels := make([]string, len(buckets))
+	for i, b := range buckets {
+		labels[i] = b.Format(time.RFC3339)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":     q.Range.From.In(loc).Format(time.RFC3339),
+		"to":       q.Range.To.In(loc).Format(time.RFC3339),
+		"timezone": loc.String(),
+		"bucket":   q.Bucket,
+		"group_by": q.GroupBy,
+		"levels":   q.Levels,
+		"buckets":  labels,
+		"series":   series,
+	})
+}
+
+// zeroFill spreads sparse rows over every bucket, one series per group.
+// Ungrouped queries always yield a single "all" series.
+func zeroFill(rows []repository.AnalyticsRow, buckets []time.Time, single bool) []models.AnalyticsSeries {
+	index := make(map[int64]int, len(buckets))
+	for i, b := range buckets {
+		index[b.Unix()] = i
+	}
+
+	var series []models.AnalyticsSeries
+	byKey := make(map[string]int)
+	get := func(key, label string) *models.AnalyticsSeries {
+		if i, ok := byKey[key]; ok {
+			return &series[i]
+		}
+		points := make([]models.AnalyticsPoint, len(buckets))
+		for i, b := range buckets {
+			points[i].Time = b.Format(time.RFC3339)
+		}
+		byKey[key] = len(series)
+		series = append(series, models.AnalyticsSeries{Key: key, Label: label, Points: points})
+		return &series[len(series)-1]
+	}
+	if single {
+		get("all", "All")
+	}
+
+	for _, row := range rows {
+		i, ok := index[row.Bucket.Unix()]
+		if !ok {
+			continue
+		}
+		key, label := row.Group, row.Label
+		if single {
+			key, label = "all", "All"
+		} else if key == "" {
+			key, label = "unassigned", "Unassigned"
+		}
+		sr := get(key, label)
+		sr.Points[i].Count += row.Count
+		sr.Total += row.Count
+	}
+	if series == nil {
+		series = []models.AnalyticsSeries{}
+	}
+	return series
+}
@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"driver-drowsiness-backend/models"
+	"driver-drowsiness-backend/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxPollWait caps how long a device long poll is held open
+const maxPollWait = 60 * time.Second
+
+// commandID parses the :command_id (or :id) path parameter, answering 400 on error
+func commandID(c *gin.Context, param string) (int, bool) {
+	id, err := strconv.Atoi(c.Param(param))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Invalid command id")
+		return 0, false
+	}
+	return id, true
+}
+
+// EnqueueCommand queues a command for a device
+func (s *Server) EnqueueCommand(c *gin.Context) {
+	ctx := c.Request.Context()
+	deviceID := c.Param("id")
+	if !s.knownDevice(c, deviceID) {
+		return
+	}
+	var req models.CommandRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if err := req.Validate(); err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ttl := s.cfg.CommandTTL
+	if req.TTLSeconds > 0 {
+		ttl = time.Duration(req.TTLSeconds) * time.Second
+	}
+	if ttl <= 0 {
+		ttl = 5 * time.Minute
+	}
+	now := s.now().UTC()
+	userID := c.GetInt("user_id")
+	cmd := models.DeviceCommand{
+		DeviceID:        deviceID,
+		Type:            req.Type,
+		Message:         req.Message,
+		DurationSeconds: req.DurationSeconds,
+		IssuedBy:        userID,
+		CreatedAt:       now,
+		ExpiresAt:       now.Add(ttl),
+	}
+	if err := s.queue.Enqueue(ctx, &cmd, fmt.Sprintf("user:%d", userID)); err != nil {
+		slog.ErrorContext(ctx, "Error queuing command", "device_id", deviceID, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to queue command")
+		return
+	}
+	slog.InfoContext(ctx, "Command queued", "user_id", userID, "type", cmd.Type, "device_id", deviceID, "command_id", cmd.ID)
+	c.JSON(http.StatusCreated, cmd)
+}
+
+// ListDeviceCommands returns the commands of a device, newest first.
+// Query: status, from, to (RFC3339 or YYYY-MM-DD), limit and tz.
+func (s *Server) ListDeviceCommands(c *gin.Context) {
+	noCache(c)
+	loc, ok := s.requestLocation(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	if _, err := s.store.Commands.Expire(ctx, s.now().UTC()); err != nil {
+		slog.WarnContext(ctx, "Could not expire commands", "error", err)
+	}
+
+	f := repository.CommandFilter{DeviceID: c.Param("id"), Status: c.Query("status"), Limit: queryLimit(c, 100)}
+	if v := c.Query("from"); v != "" {
+		t, err := parseTimeParam(v, loc)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Invalid from")
+			return
+		}
+		f.Range.From = t.UTC()
+	}
+	if v := c.Query("to"); v != "" {
+		t, err := parseTimeParam(v, loc)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Invalid to")
+			return
+		}
+		f.Range.To = t.UTC()
+	}
+
+	commands, err := s.store.Commands.List(ctx, f)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error fetching commands", "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to fetch commands")
+		return
+	}
+	if commands == nil {
+		commands = []models.DeviceCommand{}
+	}
+	c.JSON(http.StatusOK, gin.H{"count": len(commands), "commands": commands})
+}
+
+// GetCommand returns a command with its audit trail
+func (s *Server) GetCommand(c *gin.Context) {
+	noCache(c)
+	id, ok := commandID(c, "id")
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	if _, err := s.store.Commands.Expire(ctx, s.now().UTC()); err != nil {
+		slog.WarnContext(ctx, "Could not expire commands", "error", err)
+	}
+	cmd, err := s.store.Commands.GetByID(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, "Command not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Error fetching command", "command_id", id, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to fetch command")
+		return
+	}
+	events, err := s.store.Commands.Events(ctx, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error fetching audit trail of command", "command_id", id, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to fetch command")
+		return
+	}
+	if events == nil {
+		events = []models.CommandEvent{}
+	}
+	c.JSON(http.StatusOK, gin.H{"command": cmd, "events": events})
+}
+
+// CancelCommand withdraws a command the device has not acknowledged
+func (s *Server) CancelCommand(c *gin.Context) {
+	id, ok := commandID(c, "id")
+	if !ok {
+		return
+	}
+	actor := fmt.Sprintf("user:%d", c.GetInt("user_id"))
+	cmd, err := s.store.Commands.Transition(c.Request.Context(), id,
+		[]string{models.CommandQueued, models.CommandDelivered}, models.CommandCancelled, "", actor, s.now().UTC())
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		respondError(c, http.StatusNotFound, "Command not found")
+	case errors.Is(err, repository.ErrConflict):
+		respondError(c, http.StatusConflict, "Command is already finished")
+	case err != nil:
+		slog.ErrorContext(c.Request.Context(), "Error cancelling command", "command_id", id, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to cancel command")
+	default:
+		c.JSON(http.StatusOK, cmd)
+	}
+}
+
+// PollCommands hands pending commands to a device. With "wait" (seconds,
+// default 25, at most 60) the request is held open until a command arrives.
+func (s *Server) PollCommands(c *gin.Context) {
+	noCache(c)
+	wait := 25 * time.Second
+	if v := c.Query("wait"); v != "" {
+		secs, err := strconv.Atoi(v)
+		if err != nil || secs < 0 {
+			respondError(c, http.StatusBadRequest, "Invalid wait")
+			return
+		}
+		wait = time.Duration(secs) * time.Second
+	}
+	if wait > maxPollWait {
+		wait = maxPollWait
+	}
+
+	deviceID := c.Param("id")
+	commands, err := s.queue.Poll(c.Request.Context(), deviceID, wait, func() time.Time { return s.now().UTC() })
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error delivering commands", "device_id", deviceID, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to fetch commands")
+		return
+	}
+	if commands == nil {
+		commands = []models.DeviceCommand{}
+	}
+	c.JSON(http.StatusOK, gin.H{"count": len(commands), "commands": commands})
+}
+
+// AckCommand records whether a device carried out a command
+func (s *Server) AckCommand(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, ok := commandID(c, "command_id")
+	if !ok {
+		return
+	}
+	var ack models.CommandAck
+	if !bindJSON(c, &ack) {
+		return
+	}
+
+	// Commands of other devices are not visible to this one
+	cmd, err := s.store.Commands.GetByID(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && cmd.DeviceID != c.Param("id")) {
+		respondError(c, http.StatusNotFound, "Command not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Error fetching command", "command_id", id, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to acknowledge command")
+		return
+	}
+
+	now := s.now().UTC()
+	if _, err := s.store.Commands.Expire(ctx, now); err != nil {
+		slog.WarnContext(ctx, "Could not expire commands", "error", err)
+	}
+	cmd, err = s.store.Commands.Transition(ctx, id,
+		[]string{models.CommandQueued, models.CommandDelivered}, ack.Status, ack.Result, "device", now)
+	if errors.Is(err, repository.ErrConflict) {
+		respondError(c, http.StatusConflict, "Command is already finished")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Error acknowledging command", "command_id", id, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to acknowledge command")
+		return
+	}
+	slog.InfoContext(ctx, "Command acknowledged", "device_id", cmd.DeviceID, "status", cmd.Status, "command_id", cmd.ID, "type", cmd.Type)
+	c.JSON(http.StatusOK, cmd)
+}
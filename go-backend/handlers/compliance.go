@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"driver-drowsiness-backend/compliance"
+	"driver-drowsiness-backend/models"
+	"driver-drowsiness-backend/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// complianceAlerts maps driving-time events to the alert raised on the device
+var complianceAlerts = map[string]struct{ alertType, severity string }{
+	compliance.ContinuousWarning:   {"break_due", "warning"},
+	compliance.ContinuousViolation: {"continuous_driving_exceeded", "critical"},
+	compliance.DailyWarning:        {"daily_driving_limit_near", "warning"},
+	compliance.DailyViolation:      {"daily_driving_exceeded", "critical"},
+}
+
+// observeCompliance adds a sample to its driver's driving time, raising an
+// alert on the device for every limit approached or exceeded. It returns the
+// driver's status, or nil when the sample has no driver.
+func (s *Server) observeCompliance(ctx context.Context, d models.DrowsinessData) *models.ComplianceStatus {
+	if d.DriverID == 0 {
+		return nil
+	}
+	loc := s.userLocation(ctx, d.DriverID)
+	events, err := s.hos.Observe(ctx, d.DriverID, d.Timestamp, loc)
+	if err != nil {
+		slog.WarnContext(ctx, "Could not track driving time of driver", "driver_id", d.DriverID, "error", err)
+	}
+	for _, e := range events {
+		kind := complianceAlerts[e.Type]
+		alert := models.Alert{
+			DeviceID:  d.DeviceID,
+			AlertType: kind.alertType,
+			Severity:  kind.severity,
+			Status:    "active",
+			DriverID:  d.DriverID,
+			Timestamp: d.Timestamp,
+		}
+		if err := s.store.Alerts.Insert(ctx, &alert); err != nil {
+			slog.ErrorContext(ctx, "Error inserting driving-time alert", "error", err)
+			continue
+		}
+		s.metrics.alerts.Inc(alert.Severity)
+		slog.InfoContext(ctx, "Driving time limit reached", "driver_id", d.DriverID, "type", e.Type,
+			"driving_seconds", e.DrivingSeconds, "limit_seconds", e.LimitSeconds)
+	}
+	status, err := s.hos.Status(ctx, d.DriverID, d.Timestamp, loc)
+	if err != nil {
+		return nil
+	}
+	return &status
+}
+
+// GetDeviceCompliance returns the live driving time of the driver of a device
+func (s *Server) GetDeviceCompliance(c *gin.Context) {
+	noCache(c)
+	ctx := c.Request.Context()
+	deviceID := c.Param("id")
+	now := s.now().UTC()
+	driverID := s.driverAt(ctx, deviceID, now)
+	if driverID == 0 {
+		respondError(c, http.StatusNotFound, "No driver for this device")
+		return
+	}
+	status, err := s.hos.Status(ctx, driverID, now, s.userLocation(ctx, driverID))
+	if err != nil {
+		slog.ErrorContext(ctx, "Error computing driving time of driver", "driver_id", driverID, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to compute driving time")
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// eachDriver calls fn with the sample times of each driver in samples,
+// which are ordered by driver then time
+func eachDriver(samples []repository.DrivingSample, fn func(driverID int, times []time.Time)) {
+	for start := 0; start < len(samples); {
+		end := start
+		for end < len(samples) && samples[end].DriverID == samples[start].DriverID {
+			end++
+		}
+		times := make([]time.Time, 0, end-start)
+		for _, smp := range samples[start:end] {
+			times = append(times, smp.Timestamp)
+		}
+		fn(samples[start].DriverID, times)
+		start = end
+	}
+}
+
+// AdminCompliance returns hours-of-service reports per driver and per fleet.
+// Query: from, to (RFC3339 or YYYY-MM-DD; default today), driver_id, fleet_id and tz.
+func (s *Server) AdminCompliance(c *gin.Context) {
+	noCache(c)
+	loc, ok := s.requestLocation(c)
+	if !ok {
+		return
+	}
+	f := repository.DrivingFilter{Range: repository.DayRange(s.now(), loc)}
+	if v := c.Query("from"); v != "" {
+		t, err := parseTimeParam(v, loc)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Invalid from")
+			return
+		}
+		f.Range.From = t.UTC()
+		if c.Query("to") == "" {
+			f.Range.To = repository.DayRange(t, loc).To
+		}
+	}
+	if v := c.Query("to"); v != "" {
+		t, err := parseTimeParam(v, loc)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Invalid to")
+			return
+		}
+		f.Range.To = t.UTC()
+	}
+	if !f.Range.From.Before(f.Range.To) {
+		respondError(c, http.StatusBadRequest, "from must be before to")
+		return
+	}
+	for name, dst := range map[string]*int{"driver_id": &f.DriverID, "fleet_id": &f.FleetID} {
+		if v := c.Query(name); v != "" {
+			id, err := strconv.Atoi(v)
+			if err != nil {
+				respondError(c, http.StatusBadRequest, "Invalid "+name)
+				return
+			}
+			*dst = id
+		}
+	}
+
+	ctx := c.Request.Context()
+	samples, err := s.store.Compliance.DrivingSamples(ctx, f)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error fetching driving samples", "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to build compliance report")
+		return
+	}
+	fleets, err := s.store.Fleets.List(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error fetching fleets", "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to build compliance report")
+		return
+	}
+	fleetNames := make(map[int]string, len(fleets))
+	for _, fl := range fleets {
+		fleetNames[fl.ID] = fl.Name
+	}
+
+	drivers := []models.DriverCompliance{}
+	byFleet := make(map[int]*models.FleetCompliance)
+	rules := s.hos.Rules()
+	eachDriver(samples, func(driverID int, times []time.Time) {
+		report := compliance.Summarize(times, rules, loc)
+		report.DriverID = driverID
+		if u, err := s.store.Users.GetByID(ctx, report.DriverID); err == nil {
+			report.Name, report.FleetID = u.Name, u.FleetID
+			if report.Name == "" {
+				report.Name = u.Email
+			}
+		}
+		drivers = append(drivers, report)
+
+		fc, ok := byFleet[report.FleetID]
+		if !ok {
+			fc = &models.FleetCompliance{FleetID: report.FleetID, Name: fleetNames[report.FleetID]}
+			byFleet[report.FleetID] = fc
+		}
+		fc.Drivers++
+		fc.Violations += len(report.Violations)
+		fc.DrivingSeconds += report.DrivingSeconds
+		if report.Compliant {
+			fc.CompliantDrivers++
+		}
+	})
+
+	fleetReports := make([]models.FleetCompliance, 0, len(byFleet))
+	for _, fc := range byFleet {
+		fleetReports = append(fleetReports, *fc)
+	}
+	sort.Slice(fleetReports, func(i, j int) bool { return fleetReports[i].FleetID < fleetReports[j].FleetID })
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":     f.Range.From.In(loc).Format(time.RFC3339),
+		"to":       f.Range.To.In(loc).Format(time.RFC3339),
+		"timezone": loc.String(),
+		"rules": gin.H{
+			"max_continuous_seconds": rules.MaxContinuous.Seconds(),
+			"min_break_seconds":      rules.MinBreak.Seconds(),
+			"max_daily_seconds":      rules.MaxDaily.Seconds(),
+		},
+		"drivers": drivers,
+		"fleets":  fleetReports,
+	})
+}
+
+// ArchiveCompliance keeps the driving time per driver and day of the samples
+// before the cutoff of the driver's fleet, which the daily purge is about to
+// delete. Days are those of the fleet, so each is archived whole.
+func (s *Server) ArchiveCompliance(ctx context.Context, cutoffs repository.PurgeCutoffs) error {
+	samples, err := s.store.Compliance.DrivingSamples(ctx, repository.DrivingFilter{
+		Range: repository.TimeRange{To: cutoffs.Latest()},
+	})
+	if err != nil {
+		return err
+	}
+	var days []models.ComplianceArchiveDay
+	eachDriver(samples, func(driverID int, times []time.Time) {
+		cutoff := cutoffs.Default
+		if u, err := s.store.Users.GetByID(ctx, driverID); err == nil {
+			cutoff = cutoffs.For(u.FleetID)
+		}
+		end := sort.Search(len(times), func(i int) bool { return !times[i].Before(cutoff) })
+		if end == 0 {
+			return
+		}
+		loc := s.userLocation(ctx, driverID)
+		report := compliance.Summarize(times[:end], s.hos.Rules(), loc)
+		violations := make(map[string]int)
+		for _, v := range report.Violations {
+			violations[v.At.In(loc).Format("2006-01-02")]++
+		}
+		for _, d := range report.Days {
+			day, err := time.ParseInLocation("2006-01-02", d.Date, loc)
+			if err != nil {
+				continue
+			}
+			days = append(days, models.ComplianceArchiveDay{
+				Day: day.UTC(), DriverID: driverID, DrivingSeconds: d.DrivingSeconds,
+				Exceeded: d.Exceeded, Violations: violations[d.Date],
+			})
+		}
+	})
+	if len(days) == 0 {
+		return nil
+	}
+	if err := s.store.Compliance.ArchiveDays(ctx, days); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "Archived driving time", "driver_days", len(days))
+	return nil
+}
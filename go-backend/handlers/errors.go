@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"driver-drowsiness-backend/i18n"
+	"driver-drowsiness-backend/logging"
+	"driver-drowsiness-backend/models"
+	"driver-drowsiness-backend/validate"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+func init() {
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		if err := validate.Register(v); err != nil {
+			panic(fmt.Sprintf("register validation rules: %v", err))
+		}
+	}
+}
+
+// requestIDPattern is what an X-Request-ID of a client must look like to be kept
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestID gives every request an ID, keeping a well-formed X-Request-ID
+// of the client, and echoes it in the response. The ID rides on the request
+// context, so logs of the request and its database queries carry it.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader("X-Request-ID")
+		if !requestIDPattern.MatchString(id) {
+			var b [8]byte
+			rand.Read(b[:])
+			id = hex.EncodeToString(b[:])
+		}
+		c.Set("request_id", id)
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), id))
+		c.Header("X-Request-ID", id)
+		c.Next()
+	}
+}
+
+// errorCode returns the APIError code of a status without a specific one
+func errorCode(status int) string {
+	switch status {
+	case http.StatusInternalServerError:
+		return models.CodeInternal
+	case http.StatusBadRequest:
+		return models.CodeBadRequest
+	}
+	return strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+}
+
+// errorBody builds the error envelope of a request and keeps its code for
+// the metrics of rejected payloads
+func errorBody(c *gin.Context, code, message string, details []models.FieldError) models.ErrorResponse {
+	c.Set("error_code", code)
+	return models.ErrorResponse{Error: models.APIError{
+		Code: code, Message: message, Details: details, RequestID: c.GetString("request_id"),
+	}}
+}
+
+// respondError answers with the error envelope of status
+func respondError(c *gin.Context, status int, message string) {
+	c.JSON(status, errorBody(c, errorCode(status), message, nil))
+}
+
+// respondErrorWith answers with the error envelope of status and the
+// fields of extra beside it, e.g. the offset an upload must resume at
+func respondErrorWith(c *gin.Context, status int, message string, extra gin.H) {
+	body := gin.H{"error": errorBody(c, errorCode(status), message, nil).Error}
+	for k, v := range extra {
+		body[k] = v
+	}
+	c.JSON(status, body)
+}
+
+// abortError answers with the error envelope of status and stops the chain
+func abortError(c *gin.Context, status int, message string) {
+	c.AbortWithStatusJSON(status, errorBody(c, errorCode(status), message, nil))
+}
+
+// validationMessages is the message of a validation error by language
+var validationMessages = i18n.Label{EN: "Validation failed", TH: "ข้อมูลไม่ถูกต้อง"}
+
+// NotFound answers requests that match no route
+func (s *Server) NotFound(c *gin.Context) {
+	respondError(c, http.StatusNotFound, "Route not found")
+}
+
+// bindJSON decodes the JSON body into obj and applies its binding rules,
+// answering 400 with field details in the Accept-Language of the request
+// when either fails
+func bindJSON(c *gin.Context, obj interface{}) bool {
+	err := c.ShouldBindJSON(obj)
+	if err == nil {
+		return true
+	}
+	lang := i18n.ParseLang(c.GetHeader("Accept-Language"))
+	if details := validate.Details(err, lang); details != nil {
+		c.JSON(http.StatusBadRequest, errorBody(c, models.CodeValidation, validationMessages.In(lang), details))
+		return false
+	}
+	message := "Invalid JSON payload"
+	if errors.Is(err, io.EOF) {
+		message = "Request body is empty"
+	}
+	c.JSON(http.StatusBadRequest, errorBody(c, models.CodeInvalidJSON, message, nil))
+	return false
+}
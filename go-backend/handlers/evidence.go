@@ -0,0 +1,673 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"driver-drowsiness-backend/config"
+	"driver-drowsiness-backend/evidence"
+	"driver-drowsiness-backend/models"
+	"driver-drowsiness-backend/repository"
+	"driver-drowsiness-backend/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Evidence limits
+const (
+	maxPosterBytes = 2 << 20          // clip poster frames sent alongside a clip
+	uploadMaxAge   = 24 * time.Hour   // unfinished chunked uploads are dropped after this
+	uploadLease    = 10 * time.Minute // longest a chunk keeps its upload to itself
+)
+
+// newFileStore builds the evidence storage backend chosen in config,
+// falling back to the local disk when S3 is not fully configured
+func newFileStore(cfg *config.Config) storage.Backend {
+	if cfg.StorageBackend == "s3" {
+		s3, err := storage.NewS3(storage.S3Config{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+		})
+		if err == nil {
+			return s3
+		}
+		slog.Warn("S3 storage unavailable, storing evidence on local disk", "error", err)
+	}
+	dir := cfg.StorageDir
+	if dir == "" {
+		dir = filepath.Join("data", "evidence")
+	}
+	return storage.NewLocal(dir)
+}
+
+// maxEvidenceBytes returns the largest evidence file accepted
+func (s *Server) maxEvidenceBytes() int64 {
+	if s.cfg.EvidenceMaxBytes > 0 {
+		return s.cfg.EvidenceMaxBytes
+	}
+	return 25 << 20
+}
+
+// uploadDir returns the directory holding partial uploads, creating it
+func (s *Server) uploadDir() (string, error) {
+	dir := s.cfg.UploadDir
+	if dir == "" {
+		dir = filepath.Join("data", "uploads")
+	}
+	return dir, os.MkdirAll(dir, 0o755)
+}
+
+// randomToken returns n random bytes as hex
+func randomToken(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// deviceAlert returns an alert of the device in the :alert_id path
+// parameter, answering 400 or 404 on error
+func (s *Server) deviceAlert(c *gin.Context, deviceID string) (*models.Alert, bool) {
+	id, err := strconv.Atoi(c.Param("alert_id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Invalid alert id")
+		return nil, false
+	}
+	alert, err := s.store.Alerts.GetByID(c.Request.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && alert.DeviceID != deviceID) {
+		respondError(c, http.StatusNotFound, "Alert not found")
+		return nil, false
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error fetching alert", "alert_id", id, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to fetch alert")
+		return nil, false
+	}
+	return alert, true
+}
+
+// evidenceError answers a failed saveEvidence
+func evidenceError(c *gin.Context, deviceID string, err error) {
+	if errors.Is(err, evidence.ErrUnsupportedType) || errors.Is(err, evidence.ErrContentMismatch) {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	slog.ErrorContext(c.Request.Context(), "Error storing evidence", "device_id", deviceID, "error", err)
+	respondError(c, http.StatusInternalServerError, "Failed to store evidence")
+}
+
+// saveEvidence checks the complete file at path, stores it and its thumbnail
+// and records it against the alert. A poster, if any, is the clip thumbnail.
+func (s *Server) saveEvidence(ctx context.Context, alert *models.Alert, path, contentType string, poster []byte) (*models.Evidence, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	kind, err := evidence.Check(contentType, head[:n])
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.New()
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(hash, f); err != nil {
+		return nil, err
+	}
+
+	now := s.now().UTC()
+	prefix := fmt.Sprintf("evidence/%s/%d/%s", alert.DeviceID, alert.ID, randomToken(8))
+	e := models.Evidence{
+		AlertID:     alert.ID,
+		DeviceID:    alert.DeviceID,
+		AlertType:   alert.AlertType,
+		Severity:    alert.Severity,
+		AlertAt:     alert.Timestamp,
+		Kind:        kind,
+		ContentType: contentType,
+		Size:        info.Size(),
+		SHA256:      hex.EncodeToString(hash.Sum(nil)),
+		StorageKey:  prefix + evidence.Extension(contentType),
+		CreatedAt:   now,
+		ExpiresAt:   s.retention.ExpiresAt(kind, now),
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if err := s.files.Put(ctx, e.StorageKey, f, e.Size, contentType); err != nil {
+		return nil, err
+	}
+
+	// A missing thumbnail never fails the upload
+	var thumb []byte
+	switch {
+	case len(poster) > 0:
+		thumb, err = evidence.Thumbnail(bytes.NewReader(poster))
+	case kind == models.EvidenceSnapshot:
+		if _, err = f.Seek(0, io.SeekStart); err == nil {
+			thumb, err = evidence.Thumbnail(f)
+		}
+	default:
+		thumb, err = evidence.ClipThumbnail(ctx, path)
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "No thumbnail for evidence of alert", "alert_id", alert.ID, "error", err)
+	} else {
+		key := prefix + "_thumb.jpg"
+		if err := s.files.Put(ctx, key, bytes.NewReader(thumb), int64(len(thumb)), "image/jpeg"); err != nil {
+			slog.WarnContext(ctx, "Could not store thumbnail for alert", "alert_id", alert.ID, "error", err)
+		} else {
+			e.ThumbnailKey = key
+		}
+	}
+
+	if err := s.store.Evidence.Create(ctx, &e); err != nil {
+		s.deleteFiles(ctx, e)
+		return nil, err
+	}
+	slog.InfoContext(ctx, "Evidence stored", "kind", kind, "evidence_id", e.ID, "alert_id", alert.ID, "device_id", alert.DeviceID, "bytes", e.Size)
+	return &e, nil
+}
+
+// deleteFiles removes the stored objects of evidence
+func (s *Server) deleteFiles(ctx context.Context, e models.Evidence) {
+	for _, key := range []string{e.StorageKey, e.ThumbnailKey} {
+		if key == "" {
+			continue
+		}
+		if err := s.files.Delete(ctx, key); err != nil {
+			slog.WarnContext(ctx, "Could not delete file", "key", key, "error", err)
+		}
+	}
+}
+
+// signEvidence fills the signed download URLs of evidence, under the API
+// version of the request
+func (s *Server) signEvidence(c *gin.Context, e *models.Evidence, now time.Time) {
+	base := apiPrefix(c)
+	url, expires := s.signer.URL(base, e.ID, "file", now)
+	e.URL, e.URLsExpireAt = url, &expires
+	e.ThumbnailURL = ""
+	if e.ThumbnailKey != "" {
+		e.ThumbnailURL, _ = s.signer.URL(base, e.ID, "thumbnail", now)
+	}
+}
+
+// UploadEvidence stores a snapshot or clip sent in one multipart request.
+// Form fields: file (required) and poster, an optional JPEG/PNG frame used
+// as the thumbnail of a clip.
+func (s *Server) UploadEvidence(c *gin.Context) {
+	ctx := c.Request.Context()
+	deviceID := c.Param("id")
+	alert, ok := s.deviceAlert(c, deviceID)
+	if !ok {
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, s.maxEvidenceBytes()+maxPosterBytes+(1<<20))
+	header, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			respondError(c, http.StatusRequestEntityTooLarge, "File too large")
+			return
+		}
+		respondError(c, http.StatusBadRequest, "Multipart field \"file\" is required")
+		return
+	}
+	if header.Size > s.maxEvidenceBytes() {
+		respondError(c, http.StatusRequestEntityTooLarge, "File too large")
+		return
+	}
+	var poster []byte
+	if ph, err := c.FormFile("poster"); err == nil {
+		if ph.Size > maxPosterBytes {
+			respondError(c, http.StatusRequestEntityTooLarge, "Poster too large")
+			return
+		}
+		pf, err := ph.Open()
+		if err == nil {
+			poster, err = io.ReadAll(pf)
+			pf.Close()
+		}
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Could not read poster")
+			return
+		}
+	}
+
+	dir, err := s.uploadDir()
+	if err != nil {
+		evidenceError(c, deviceID, err)
+		return
+	}
+	path := filepath.Join(dir, randomToken(16)+".part")
+	defer os.Remove(path)
+	if err := c.SaveUploadedFile(header, path); err != nil {
+		evidenceError(c, deviceID, err)
+		return
+	}
+
+	e, err := s.saveEvidence(ctx, alert, path, header.Header.Get("Content-Type"), poster)
+	if err != nil {
+		evidenceError(c, deviceID, err)
+		return
+	}
+	s.signEvidence(c, e, s.now())
+	c.JSON(http.StatusCreated, e)
+}
+
+// StartEvidenceUpload begins a resumable upload for large files on flaky
+// links; chunks are then PUT to the returned upload_id from offset
+func (s *Server) StartEvidenceUpload(c *gin.Context) {
+	deviceID := c.Param("id")
+	alert, ok := s.deviceAlert(c, deviceID)
+	if !ok {
+		return
+	}
+	var req models.EvidenceUploadRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if _, ok := evidence.KindOf(req.ContentType); !ok {
+		respondError(c, http.StatusBadRequest, evidence.ErrUnsupportedType.Error())
+		return
+	}
+	if req.Size > s.maxEvidenceBytes() {
+		respondError(c, http.StatusRequestEntityTooLarge, "File too large")
+		return
+	}
+
+	now := s.now().UTC()
+	upload := models.EvidenceUpload{
+		ID:          randomToken(16),
+		DeviceID:    deviceID,
+		AlertID:     alert.ID,
+		ContentType: req.ContentType,
+		Size:        req.Size,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		AlertType:   alert.AlertType,
+		Severity:    alert.Severity,
+		AlertAt:     alert.Timestamp,
+	}
+	if err := s.store.Evidence.CreateUpload(c.Request.Context(), &upload); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error starting upload", "device_id", deviceID, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to start upload")
+		return
+	}
+	c.JSON(http.StatusCreated, upload)
+}
+
+// deviceUpload returns the upload of the device in the :upload_id path
+// parameter, answering 404 on error
+func (s *Server) deviceUpload(c *gin.Context, deviceID string) (*models.EvidenceUpload, bool) {
+	upload, err := s.store.Evidence.GetUpload(c.Request.Context(), c.Param("upload_id"))
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && upload.DeviceID != deviceID) {
+		respondError(c, http.StatusNotFound, "Upload not found")
+		return nil, false
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error fetching upload", "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to fetch upload")
+		return nil, false
+	}
+	return upload, true
+}
+
+// GetEvidenceUpload returns how much of an upload arrived, so a device
+// that lost its connection knows where to resume
+func (s *Server) GetEvidenceUpload(c *gin.Context) {
+	noCache(c)
+	upload, ok := s.deviceUpload(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Received, 10))
+	c.JSON(http.StatusOK, upload)
+}
+
+// UploadEvidenceChunk appends the request body to an upload at the offset
+// in the Upload-Offset header. The last chunk stores the evidence.
+func (s *Server) UploadEvidenceChunk(c *gin.Context) {
+	ctx := c.Request.Context()
+	deviceID := c.Param("id")
+	release, ok := s.claimUpload(c, c.Param("upload_id"))
+	if !ok {
+		return
+	}
+	defer release()
+
+	upload, ok := s.deviceUpload(c, deviceID)
+	if !ok {
+		return
+	}
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Upload-Offset header is required")
+		return
+	}
+	if offset != upload.Received {
+		c.Header("Upload-Offset", strconv.FormatInt(upload.Received, 10))
+		respondErrorWith(c, http.StatusConflict, "Upload offset mismatch", gin.H{"offset": upload.Received})
+		return
+	}
+
+	dir, err := s.uploadDir()
+	if err != nil {
+		evidenceError(c, deviceID, err)
+		return
+	}
+	path := filepath.Join(dir, upload.ID+".part")
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0o644)
+	if err != nil {
+		evidenceError(c, deviceID, err)
+		return
+	}
+	// Drop whatever an interrupted or rejected chunk left past the offset
+	remaining := upload.Size - offset
+	var n int64
+	if err = f.Truncate(offset); err == nil {
+		n, err = io.Copy(io.NewOffsetWriter(f, offset), io.LimitReader(c.Request.Body, remaining+1))
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "Chunk of upload interrupted", "upload_id", upload.ID, "error", err)
+		respondErrorWith(c, http.StatusBadRequest, "Could not read chunk", gin.H{"offset": upload.Received})
+		return
+	}
+	if n > remaining {
+		respondErrorWith(c, http.StatusRequestEntityTooLarge, "Chunk exceeds the declared size", gin.H{"offset": upload.Received})
+		return
+	}
+	if err := s.store.Evidence.AdvanceUpload(ctx, upload.ID, offset, offset+n, s.now()); err != nil {
+		slog.ErrorContext(ctx, "Error advancing upload", "upload_id", upload.ID, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to record chunk")
+		return
+	}
+	upload.Received = offset + n
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Received, 10))
+	if upload.Received < upload.Size {
+		c.JSON(http.StatusOK, upload)
+		return
+	}
+
+	// Complete: store it and forget the upload. The alert comes from the
+	// upload, as an upload running past midnight outlives it.
+	defer s.dropUpload(ctx, upload.ID, path)
+	alert := &models.Alert{
+		ID:        upload.AlertID,
+		DeviceID:  upload.DeviceID,
+		AlertType: upload.AlertType,
+		Severity:  upload.Severity,
+		Timestamp: upload.AlertAt,
+	}
+	e, err := s.saveEvidence(ctx, alert, path, upload.ContentType, nil)
+	if err != nil {
+		evidenceError(c, deviceID, err)
+		return
+	}
+	s.signEvidence(c, e, s.now())
+	c.JSON(http.StatusCreated, e)
+}
+
+// claimUpload holds an upload for the chunk of the request, across
+// replicas, until release is called; a chunk sent while another is being
+// written gets 409
+func (s *Server) claimUpload(c *gin.Context, id string) (release func(), ok bool) {
+	ctx := c.Request.Context()
+	now := s.now()
+	until := now.Add(uploadLease)
+	err := s.store.Evidence.ClaimUpload(ctx, id, now, until)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		respondError(c, http.StatusNotFound, "Upload not found")
+		return nil, false
+	case errors.Is(err, repository.ErrConflict):
+		respondError(c, http.StatusConflict, "Another chunk of this upload is being written")
+		return nil, false
+	case err != nil:
+		slog.ErrorContext(ctx, "Error claiming upload", "upload_id", id, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to fetch upload")
+		return nil, false
+	}
+	return func() {
+		// A finished upload is deleted by then, which leaves nothing to free
+		if err := s.store.Evidence.ReleaseUpload(ctx, id, until); err != nil {
+			slog.WarnContext(ctx, "Could not release upload", "upload_id", id, "error", err)
+		}
+	}, true
+}
+
+// dropUpload deletes an upload and its partial file
+func (s *Server) dropUpload(ctx context.Context, id, path string) {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		slog.WarnContext(ctx, "Could not remove file", "path", path, "error", err)
+	}
+	if err := s.store.Evidence.DeleteUpload(ctx, id); err != nil {
+		slog.WarnContext(ctx, "Could not delete upload", "upload_id", id, "error", err)
+	}
+}
+
+// AdminAlertEvidence returns the evidence of an alert with signed URLs
+func (s *Server) AdminAlertEvidence(c *gin.Context) {
+	noCache(c)
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Invalid alert id")
+		return
+	}
+	items, err := s.store.Evidence.List(c.Request.Context(), repository.EvidenceFilter{AlertID: id})
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error fetching evidence of alert", "alert_id", id, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to fetch evidence")
+		return
+	}
+	now := s.now()
+	for i := range items {
+		s.signEvidence(c, &items[i], now)
+	}
+	c.JSON(http.StatusOK, gin.H{"alert_id": id, "count": len(items), "evidence": items})
+}
+
+// AdminListEvidence returns evidence newest first with signed URLs.
+// Query: device_id, from, to (RFC3339 or YYYY-MM-DD), limit and tz.
+func (s *Server) AdminListEvidence(c *gin.Context) {
+	noCache(c)
+	loc, ok := s.requestLocation(c)
+	if !ok {
+		return
+	}
+	f := repository.EvidenceFilter{DeviceID: c.Query("device_id"), Limit: queryLimit(c, 100)}
+	if v := c.Query("from"); v != "" {
+		t, err := parseTimeParam(v, loc)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Invalid from")
+			return
+		}
+		f.Range.From = t
+	}
+	if v := c.Query("to"); v != "" {
+		t, err := parseTimeParam(v, loc)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Invalid to")
+			return
+		}
+		f.Range.To = t
+	}
+
+	items, err := s.store.Evidence.List(c.Request.Context(), f)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error fetching evidence", "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to fetch evidence")
+		return
+	}
+	now := s.now()
+	for i := range items {
+		s.signEvidence(c, &items[i], now)
+	}
+	c.JSON(http.StatusOK, gin.H{"count": len(items), "evidence": items})
+}
+
+// AdminDeleteEvidence deletes evidence and its files before its retention ends
+func (s *Server) AdminDeleteEvidence(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Invalid evidence id")
+		return
+	}
+	e, err := s.store.Evidence.GetByID(ctx, id)
+	if err == nil {
+		err = s.store.Evidence.Delete(ctx, id)
+	}
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, "Evidence not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Error deleting evidence", "evidence_id", id, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to delete evidence")
+		return
+	}
+	s.deleteFiles(ctx, *e)
+	slog.InfoContext(ctx, "User deleted evidence of alert", "user_id", c.GetInt("user_id"), "evidence_id", id, "alert_id", e.AlertID)
+	c.JSON(http.StatusOK, gin.H{"success": true, "id": id})
+}
+
+// ServeEvidence streams an evidence file or its thumbnail to anyone holding
+// a valid signed URL, so dashboards can use it directly in <img>/<video>
+func (s *Server) ServeEvidence(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := strconv.Atoi(c.Param("id"))
+	variant := c.Param("variant")
+	if err != nil || (variant != "file" && variant != "thumbnail") {
+		respondError(c, http.StatusNotFound, "Evidence not found")
+		return
+	}
+	if !s.signer.Verify(id, variant, c.Query("expires"), c.Query("signature"), s.now()) {
+		respondError(c, http.StatusForbidden, "Invalid or expired link")
+		return
+	}
+
+	e, err := s.store.Evidence.GetByID(ctx, id)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		slog.ErrorContext(ctx, "Error fetching evidence", "evidence_id", id, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to fetch evidence")
+		return
+	}
+	key, contentType := "", "image/jpeg"
+	if err == nil {
+		key = e.ThumbnailKey
+		if variant == "file" {
+			key, contentType = e.StorageKey, e.ContentType
+		}
+	}
+	if key == "" {
+		respondError(c, http.StatusNotFound, "Evidence not found")
+		return
+	}
+
+	body, size, err := s.files.Open(ctx, key)
+	if errors.Is(err, storage.ErrNotFound) {
+		respondError(c, http.StatusNotFound, "Evidence not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Error opening file", "key", key, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to read evidence")
+		return
+	}
+	defer body.Close()
+	c.DataFromReader(http.StatusOK, size, contentType, body, map[string]string{
+		"Cache-Control": "private, max-age=300",
+	})
+}
+
+// purgeEvidence deletes evidence past its retention and abandoned uploads
+func (s *Server) purgeEvidence(ctx context.Context) {
+	now := s.now().UTC()
+	purged := 0
+	for {
+		expired, err := s.store.Evidence.Expired(ctx, now, 100)
+		if err != nil {
+			slog.WarnContext(ctx, "Could not list expired evidence", "error", err)
+			break
+		}
+		for _, e := range expired {
+			if err := s.store.Evidence.Delete(ctx, e.ID); err != nil && !errors.Is(err, repository.ErrNotFound) {
+				slog.WarnContext(ctx, "Could not delete evidence", "evidence_id", e.ID, "error", err)
+				return
+			}
+			s.deleteFiles(ctx, e)
+			purged++
+		}
+		if len(expired) < 100 {
+			break
+		}
+	}
+
+	stale, err := s.store.Evidence.StaleUploads(ctx, now.Add(-uploadMaxAge))
+	if err != nil {
+		slog.WarnContext(ctx, "Could not list stale uploads", "error", err)
+	}
+	dir, _ := s.uploadDir()
+	for _, u := range stale {
+		s.dropUpload(ctx, u.ID, filepath.Join(dir, u.ID+".part"))
+	}
+	if purged > 0 || len(stale) > 0 {
+		slog.InfoContext(ctx, "Purged expired evidence", "files", purged, "uploads", len(stale))
+	}
+}
+
+// WatchEvidence applies evidence retention every interval until ctx is done
+func (s *Server) WatchEvidence(ctx context.Context, interval time.Duration) {
+	purge := s.TimeJob(jobEvidenceRetention, func() error {
+		s.purgeEvidence(ctx)
+		return nil
+	})
+	go func() {
+		purge()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				purge()
+			}
+		}
+	}()
+	slog.InfoContext(ctx, "Evidence retention scheduled", "snapshots", s.retention.Snapshot.String(),
+		"clips", s.retention.Clip.String(), "interval", interval.String())
+}
@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"driver-drowsiness-backend/export"
+	"driver-drowsiness-backend/i18n"
+	"driver-drowsiness-backend/models"
+	"driver-drowsiness-backend/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// historyColumns are the columns of a drowsiness history export
+var historyColumns = []i18n.Label{
+	{EN: "Time", TH: "เวลา"},
+	{EN: "Device", TH: "อุปกรณ์"},
+	{EN: "Driver", TH: "คนขับ"},
+	{EN: "Fleet", TH: "กลุ่มรถ"},
+	{EN: "Vehicle", TH: "ทะเบียนรถ"},
+	{EN: "Eye closure", TH: "การหลับตา"},
+	{EN: "Drowsiness level", TH: "ระดับความง่วง"},
+	{EN: "Status", TH: "สถานะ"},
+	{EN: "Fatigue score", TH: "คะแนนความล้า"},
+	{EN: "Fatigue level", TH: "ระดับความล้า"},
+	{EN: "Latitude", TH: "ละติจูด"},
+	{EN: "Longitude", TH: "ลองจิจูด"},
+	{EN: "Speed (km/h)", TH: "ความเร็ว (กม./ชม.)"},
+}
+
+// alertColumns are the columns of an alert export
+var alertColumns = []i18n.Label{
+	{EN: "Time", TH: "เวลา"},
+	{EN: "Device", TH: "อุปกรณ์"},
+	{EN: "Driver", TH: "คนขับ"},
+	{EN: "Fleet", TH: "กลุ่มรถ"},
+	{EN: "Vehicle", TH: "ทะเบียนรถ"},
+	{EN: "Alert type", TH: "ประเภทการแจ้งเตือน"},
+	{EN: "Severity", TH: "ความรุนแรง"},
+	{EN: "Status", TH: "สถานะ"},
+	{EN: "Acknowledged", TH: "รับทราบแล้ว"},
+	{EN: "Latitude", TH: "ละติจูด"},
+	{EN: "Longitude", TH: "ลองจิจูด"},
+}
+
+// exportRequest is a parsed export query
+type exportRequest struct {
+	format export.Format
+	lang   string
+	loc    *time.Location
+	filter repository.ExportFilter
+}
+
+// parseExport reads format, lang, fleet_id, driver_id, device_id, tz, from
+// and to (default today), answering 400 or 404 itself when they are invalid
+// and 422 when from is before the data the daily purge kept.
+// Times use the "tz" parameter, else the fleet of the fleet or driver
+// exported, else the timezone of the caller.
+func (s *Server) parseExport(c *gin.Context) (*exportRequest, bool) {
+	req := &exportRequest{lang: i18n.ParseLang(c.Query("lang"))}
+	if c.Query("lang") == "" {
+		req.lang = i18n.ParseLang(c.GetHeader("Accept-Language"))
+	}
+	format, ok := export.ParseFormat(c.Query("format"))
+	if !ok {
+		respondError(c, http.StatusBadRequest, "format must be csv or xlsx")
+		return nil, false
+	}
+	req.format = format
+	req.filter.DeviceID = c.Query("device_id")
+	for _, name := range []string{"fleet_id", "driver_id"} {
+		v := c.Query(name)
+		if v == "" {
+			continue
+		}
+		id, err := strconv.Atoi(v)
+		if err != nil || id <= 0 {
+			respondError(c, http.StatusBadRequest, "Invalid "+name)
+			return nil, false
+		}
+		if name == "fleet_id" {
+			req.filter.FleetID = id
+		} else {
+			req.filter.DriverID = id
+		}
+	}
+
+	ctx := c.Request.Context()
+	switch {
+	case c.Query("tz") != "":
+		loc, ok := s.requestLocation(c)
+		if !ok {
+			return nil, false
+		}
+		req.loc = loc
+	case req.filter.FleetID != 0:
+		fleet, err := s.store.Fleets.GetByID(ctx, req.filter.FleetID)
+		if errors.Is(err, repository.ErrNotFound) {
+			respondError(c, http.StatusNotFound, "Fleet not found")
+			return nil, false
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Error fetching fleet", "fleet_id", req.filter.FleetID, "error", err)
+			respondError(c, http.StatusInternalServerError, "Failed to fetch fleet")
+			return nil, false
+		}
+		if req.loc, err = time.LoadLocation(fleet.Timezone); err != nil {
+			req.loc = s.defaultLocation()
+		}
+	case req.filter.DriverID != 0:
+		req.loc = s.userLocation(ctx, req.filter.DriverID)
+	default:
+		loc, ok := s.requestLocation(c)
+		if !ok {
+			return nil, false
+		}
+		req.loc = loc
+	}
+
+	req.filter.Range = repository.DayRange(s.now(), req.loc)
+	for _, name := range []string{"from", "to"} {
+		v := c.Query(name)
+		if v == "" {
+			continue
+		}
+		t, err := parseTimeParam(v, req.loc)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Invalid "+name)
+			return nil, false
+		}
+		if name == "from" {
+			req.filter.Range.From = t.UTC()
+		} else {
+			req.filter.Range.To = t.UTC()
+		}
+	}
+	if !req.filter.Range.From.Before(req.filter.Range.To) {
+		respondError(c, http.StatusBadRequest, "from must be before to")
+		return nil, false
+	}
+	if !s.checkRetained(c, req.filter.Range.From, req.filter.FleetID, req.filter.DriverID, req.filter.DeviceID, req.loc) {
+		return nil, false
+	}
+	return req, true
+}
+
+// header returns the localized header row; the time column names the zone
+func (r *exportRequest) header(columns []i18n.Label) []interface{} {
+	row := make([]interface{}, len(columns))
+	for i, col := range columns {
+		row[i] = col.In(r.lang)
+	}
+	row[0] = fmt.Sprintf("%s (%s)", row[0], r.loc)
+	return row
+}
+
+// filename names the download after the kind and the local dates covered
+func (r *exportRequest) filename(kind string) string {
+	from := r.filter.Range.From.In(r.loc).Format("2006-01-02")
+	to := r.filter.Range.To.Add(-time.Nanosecond).In(r.loc).Format("2006-01-02")
+	return fmt.Sprintf("%s_%s_%s.%s", kind, from, to, r.format)
+}
+
+// stream runs each, writing the headers and the header row only when the
+// first row arrives, so a failing query still gets a JSON error
+func (s *Server) stream(c *gin.Context, req *exportRequest, kind, sheet string, columns []i18n.Label,
+	each func(row func(...interface{}) error) error) {
+	var w export.Writer
+	start := func() error {
+		if w != nil {
+			return nil
+		}
+		noCache(c)
+		c.Header("Content-Type", req.format.ContentType())
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, req.filename(kind)))
+		c.Header("X-Content-Type-Options", "nosniff")
+		c.Status(http.StatusOK)
+		w = export.New(req.format, c.Writer, req.loc, sheet)
+		return w.Row(req.header(columns)...)
+	}
+
+	rows := 0
+	err := each(func(cells ...interface{}) error {
+		if err := start(); err != nil {
+			return err
+		}
+		rows++
+		return w.Row(cells...)
+	})
+	if err == nil {
+		err = start()
+	}
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil && w == nil {
+		slog.ErrorContext(c.Request.Context(), "Error exporting", "kind", kind, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to export "+kind)
+		return
+	}
+	if err != nil {
+		// The response has started; the client sees a truncated file
+		slog.ErrorContext(c.Request.Context(), "Export failed", "kind", kind, "rows", rows, "error", err)
+		return
+	}
+	slog.InfoContext(c.Request.Context(), "Exported rows", "kind", kind, "rows", rows, "format", req.format)
+}
+
+// gpsCells returns the latitude and longitude cells of an optional fix
+func gpsCells(fix *models.GPSFix) (interface{}, interface{}) {
+	if fix == nil {
+		return nil, nil
+	}
+	return fix.Latitude, fix.Longitude
+}
+
+// ExportHistory streams the drowsiness samples of a driver, device or fleet.
+// Query: format (csv|xlsx), lang (th|en), fleet_id, driver_id, device_id,
+// from, to (RFC3339 or YYYY-MM-DD; default today) and tz.
+func (s *Server) ExportHistory(c *gin.Context) {
+	req, ok := s.parseExport(c)
+	if !ok {
+		return
+	}
+	sheet := i18n.Label{EN: "History", TH: "ประวัติ"}.In(req.lang)
+	s.stream(c, req, "history", sheet, historyColumns, func(row func(...interface{}) error) error {
+		return s.store.Exports.EachSample(c.Request.Context(), req.filter, func(d *repository.SampleExportRow) error {
+			var fix *models.GPSFix
+			var speed interface{}
+			if d.Telemetry != nil && d.Telemetry.GPS != nil {
+				fix = d.Telemetry.GPS
+				if fix.SpeedKmh != nil {
+					speed = *fix.SpeedKmh
+				}
+			}
+			lat, lon := gpsCells(fix)
+			return row(d.Timestamp, d.DeviceID, d.DriverName, d.FleetName, d.VehiclePlate,
+				d.EyeClosure, d.DrowsinessLevel, d.Status, d.FatigueScore, d.FatigueLevel, lat, lon, speed)
+		})
+	})
+}
+
+// ExportAlerts streams the alerts of a driver, device or fleet.
+// It takes the same query as ExportHistory.
+func (s *Server) ExportAlerts(c *gin.Context) {
+	req, ok := s.parseExport(c)
+	if !ok {
+		return
+	}
+	sheet := i18n.Label{EN: "Alerts", TH: "การแจ้งเตือน"}.In(req.lang)
+	s.stream(c, req, "alerts", sheet, alertColumns, func(row func(...interface{}) error) error {
+		return s.store.Exports.EachAlert(c.Request.Context(), req.filter, func(a *repository.AlertExportRow) error {
+			lat, lon := gpsCells(a.Location)
+			return row(a.Timestamp, a.DeviceID, a.DriverName, a.FleetName, a.VehiclePlate,
+				a.AlertType, a.Severity, a.Status, a.Acknowledged, lat, lon)
+		})
+	})
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"driver-drowsiness-backend/faceid"
+	"driver-drowsiness-backend/models"
+	"driver-drowsiness-backend/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxFaceEmbeddings caps the references one driver can enrol
+const maxFaceEmbeddings = 5
+
+// checkIdentity compares the face a device saw at trip start with the
+// enrolled references. It answers 400 on an invalid embedding; other
+// failures leave the trip unchecked.
+func (s *Server) checkIdentity(c *gin.Context, deviceID string, at time.Time, p *models.SessionEventPayload) (*models.IdentityCheck, bool) {
+	if len(p.FaceEmbedding) == 0 {
+		return nil, true
+	}
+	ctx := c.Request.Context()
+	expected, err := repository.DriverAt(ctx, s.store.Shifts, s.store.Devices, deviceID, at)
+	if err != nil {
+		slog.WarnContext(ctx, "Could not resolve driver", "device_id", deviceID, "error", err)
+	}
+	check, err := s.faces.Check(ctx, expected, p.FaceEmbedding, p.FaceModel)
+	if errors.Is(err, faceid.ErrInvalidEmbedding) {
+		respondError(c, http.StatusBadRequest, "Invalid face_embedding: "+err.Error())
+		return nil, false
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "Could not check driver identity", "device_id", deviceID, "error", err)
+		return nil, true
+	}
+	slog.InfoContext(ctx, "Driver checked", "device_id", deviceID, "status", check.Status,
+		"expected_driver_id", check.ExpectedDriverID, "driver_id", check.DriverID)
+	return &check, true
+}
+
+// raiseUnrecognizedDriver alerts that nobody enrolled is driving a device
+func (s *Server) raiseUnrecognizedDriver(c *gin.Context, deviceID string, at time.Time) {
+	ctx := c.Request.Context()
+	alert := models.Alert{
+		DeviceID:  deviceID,
+		AlertType: "unrecognized_driver",
+		Severity:  "high",
+		Status:    "active",
+		Location:  s.alertLocation(c, deviceID, nil, at),
+		Timestamp: at,
+	}
+	if err := s.fences.ApplyRules(ctx, &alert); err != nil {
+		slog.WarnContext(ctx, "Could not apply geofence rules to alert", "device_id", deviceID, "error", err)
+	}
+	if err := s.store.Alerts.Insert(ctx, &alert); err != nil {
+		slog.ErrorContext(ctx, "Error inserting unrecognized driver alert", "error", err)
+		return
+	}
+	s.metrics.alerts.Inc(alert.Severity)
+	slog.InfoContext(ctx, "Unrecognized driver", "device_id", deviceID)
+}
+
+// listFaces answers the enrolled references of a user; vectors are never returned
+func (s *Server) listFaces(c *gin.Context, userID int) {
+	faces, err := s.store.Faces.List(c.Request.Context(), userID)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error fetching face embeddings of user", "user_id", userID, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to fetch face embeddings")
+		return
+	}
+	if faces == nil {
+		faces = []models.FaceEmbedding{}
+	}
+	c.JSON(http.StatusOK, gin.H{"user_id": userID, "count": len(faces), "embeddings": faces})
+}
+
+// ListMyFaces returns the face references the current user enrolled
+func (s *Server) ListMyFaces(c *gin.Context) {
+	noCache(c)
+	s.listFaces(c, c.GetInt("user_id"))
+}
+
+// EnrollMyFace adds a reference face embedding computed on the device.
+// Images are not accepted: the server only ever sees embeddings.
+func (s *Server) EnrollMyFace(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID := c.GetInt("user_id")
+	var req models.FaceEnrollRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	vector, err := faceid.Normalize(req.Embedding)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	existing, err := s.store.Faces.List(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error fetching face embeddings of user", "user_id", userID, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to enrol face")
+		return
+	}
+	if len(existing) >= maxFaceEmbeddings {
+		respondError(c, http.StatusConflict, "At most "+strconv.Itoa(maxFaceEmbeddings)+" face embeddings can be enrolled; delete one first")
+		return
+	}
+
+	face := models.FaceEmbedding{
+		UserID:     userID,
+		Model:      req.Model,
+		Dimensions: len(vector),
+		Vector:     vector,
+		CreatedAt:  s.now().UTC(),
+	}
+	if err := s.store.Faces.Enroll(ctx, &face); err != nil {
+		slog.ErrorContext(ctx, "Error enrolling face of user", "user_id", userID, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to enrol face")
+		return
+	}
+	s.faces.Invalidate()
+	slog.InfoContext(ctx, "Face enrolled", "user_id", userID, "embedding_id", face.ID, "dimensions", face.Dimensions)
+	c.JSON(http.StatusCreated, face)
+}
+
+// DeleteMyFace removes one of the current user's face references
+func (s *Server) DeleteMyFace(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Invalid embedding id")
+		return
+	}
+	userID := c.GetInt("user_id")
+	err = s.store.Faces.Delete(c.Request.Context(), userID, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, "Face embedding not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error deleting face embedding", "embedding_id", id, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to delete face embedding")
+		return
+	}
+	s.faces.Invalidate()
+	c.JSON(http.StatusOK, gin.H{"success": true, "id": id})
+}
+
+// AdminDriverFaces returns the face references a driver enrolled
+func (s *Server) AdminDriverFaces(c *gin.Context) {
+	noCache(c)
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Invalid driver id")
+		return
+	}
+	s.listFaces(c, id)
+}
+
+// AdminResetDriverFaces deletes every face reference of a driver so they
+// can enrol again
+func (s *Server) AdminResetDriverFaces(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Invalid driver id")
+		return
+	}
+	if err := s.store.Faces.DeleteAll(c.Request.Context(), id); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error resetting face embeddings of user", "user_id", id, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to reset face embeddings")
+		return
+	}
+	s.faces.Invalidate()
+	slog.InfoContext(c.Request.Context(), "User reset the face embeddings of driver", "user_id", c.GetInt("user_id"), "driver_id", id)
+	c.JSON(http.StatusOK, gin.H{"success": true, "user_id": id})
+}
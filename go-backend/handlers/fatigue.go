@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetDeviceFatigue returns the live PERCLOS, blink and microsleep metrics
+// and fatigue score of a device over the sliding window
+func (s *Server) GetDeviceFatigue(c *gin.Context) {
+	deviceID := c.Param("id")
+	noCache(c)
+
+	metrics, ok := s.fatigue.Latest(deviceID)
+	if !ok {
+		respondError(c, http.StatusNotFound, "No fatigue data for this device")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"device_id": deviceID,
+		"fatigue":   metrics,
+	})
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"driver-drowsiness-backend/models"
+	"driver-drowsiness-backend/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultLocation returns the configured business timezone
+func (s *Server) defaultLocation() *time.Location {
+	if s.cfg.Location != nil {
+		return s.cfg.Location
+	}
+	return time.UTC
+}
+
+// location resolves the timezone of a request, in order of precedence:
+// the "tz" query parameter, the fleet of the authenticated user, then config.
+func (s *Server) location(c *gin.Context) (*time.Location, error) {
+	if tz := c.Query("tz"); tz != "" {
+		return time.LoadLocation(tz)
+	}
+	if userID, ok := c.Get("user_id"); ok {
+		return s.userLocation(c.Request.Context(), userID.(int)), nil
+	}
+	return s.defaultLocation(), nil
+}
+
+// userLocation returns the timezone of a user's fleet, else the configured one
+func (s *Server) userLocation(ctx context.Context, userID int) *time.Location {
+	if user, err := s.store.Users.GetByID(ctx, userID); err == nil && user.FleetID != 0 {
+		if fleet, err := s.store.Fleets.GetByID(ctx, user.FleetID); err == nil {
+			if loc, err := time.LoadLocation(fleet.Timezone); err == nil {
+				return loc
+			}
+			slog.WarnContext(ctx, "Fleet has invalid timezone", "fleet_id", fleet.ID, "timezone", fleet.Timezone)
+		}
+	}
+	return s.defaultLocation()
+}
+
+// requestLocation is location() that answers 400 on an unknown "tz"
+func (s *Server) requestLocation(c *gin.Context) (*time.Location, bool) {
+	loc, err := s.location(c)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Invalid timezone")
+		return nil, false
+	}
+	return loc, true
+}
+
+// ListFleets returns every fleet
+func (s *Server) ListFleets(c *gin.Context) {
+	fleets, err := s.store.Fleets.List(c.Request.Context())
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error fetching fleets", "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to fetch fleets")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"count": len(fleets), "fleets": fleets})
+}
+
+// CreateFleet registers a fleet; the timezone defaults to the configured one
+func (s *Server) CreateFleet(c *gin.Context) {
+	var req models.FleetRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	fleet := models.Fleet{Name: req.Name, Timezone: req.Timezone}
+	if fleet.Timezone == "" {
+		fleet.Timezone = s.defaultLocation().String()
+	}
+	if _, err := time.LoadLocation(fleet.Timezone); err != nil {
+		respondError(c, http.StatusBadRequest, "Invalid timezone")
+		return
+	}
+
+	if err := s.store.Fleets.Create(c.Request.Context(), &fleet); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error creating fleet", "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to create fleet")
+		return
+	}
+	c.JSON(http.StatusCreated, fleet)
+}
+
+// UpdateFleet renames a fleet or changes its timezone
+func (s *Server) UpdateFleet(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Invalid fleet id")
+		return
+	}
+	var req models.FleetRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	fleet := models.Fleet{ID: id, Name: req.Name, Timezone: req.Timezone}
+	if fleet.Timezone == "" {
+		fleet.Timezone = s.defaultLocation().String()
+	}
+	if _, err := time.LoadLocation(fleet.Timezone); err != nil {
+		respondError(c, http.StatusBadRequest, "Invalid timezone")
+		return
+	}
+
+	err = s.store.Fleets.Update(c.Request.Context(), &fleet)
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, "Fleet not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error updating fleet", "fleet_id", id, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to update fleet")
+		return
+	}
+	c.JSON(http.StatusOK, fleet)
+}
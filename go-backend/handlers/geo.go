@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"driver-drowsiness-backend/geo"
+	"driver-drowsiness-backend/models"
+	"driver-drowsiness-backend/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// locationMaxAge is how old the last GPS fix of a device may be to locate an alert
+const locationMaxAge = 2 * time.Minute
+
+// defaultTrackTolerance is the simplification tolerance of tracks in meters
+const defaultTrackTolerance = 10.0
+
+// alertLocation returns the reported position of an alert, falling back to
+// the device's last fix shortly before it
+func (s *Server) alertLocation(c *gin.Context, deviceID string, reported *models.GPSFix, at time.Time) *models.GPSFix {
+	if reported != nil {
+		return reported
+	}
+	fix, err := s.store.Drowsiness.LastLocation(c.Request.Context(), deviceID, at.Add(-locationMaxAge))
+	if err != nil {
+		if !errors.Is(err, repository.ErrNotFound) {
+			slog.WarnContext(c.Request.Context(), "Could not look up location", "device_id", deviceID, "error", err)
+		}
+		return nil
+	}
+	return fix
+}
+
+// AdminGeoAlerts returns located alerts as a GeoJSON FeatureCollection.
+// Query: from, to (RFC3339 or YYYY-MM-DD; default today), driver_id,
+// fleet_id, device_id and tz.
+func (s *Server) AdminGeoAlerts(c *gin.Context) {
+	noCache(c)
+	loc, ok := s.requestLocation(c)
+	if !ok {
+		return
+	}
+
+	f := repository.GeoFilter{Range: repository.DayRange(s.now(), loc), DeviceID: c.Query("device_id")}
+	if v := c.Query("from"); v != "" {
+		t, err := parseTimeParam(v, loc)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Invalid from")
+			return
+		}
+		f.Range.From = t.UTC()
+	}
+	if v := c.Query("to"); v != "" {
+		t, err := parseTimeParam(v, loc)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Invalid to")
+			return
+		}
+		f.Range.To = t.UTC()
+	}
+	if !f.Range.From.Before(f.Range.To) {
+		respondError(c, http.StatusBadRequest, "from must be before to")
+		return
+	}
+	for name, dst := range map[string]*int{"driver_id": &f.DriverID, "fleet_id": &f.FleetID} {
+		if v := c.Query(name); v != "" {
+			id, err := strconv.Atoi(v)
+			if err != nil {
+				respondError(c, http.StatusBadRequest, "Invalid "+name)
+				return
+			}
+			*dst = id
+		}
+	}
+
+	if !s.checkRetained(c, f.Range.From, f.FleetID, f.DriverID, f.DeviceID, loc) {
+		return
+	}
+
+	alerts, err := s.store.Geo.Alerts(c.Request.Context(), f)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error fetching located alerts", "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to fetch alerts")
+		return
+	}
+
+	features := make([]geo.Feature, 0, len(alerts))
+	for _, a := range alerts {
+		feature := geo.PointFeature(geo.Point{Lat: a.Location.Latitude, Lon: a.Location.Longitude}, map[string]interface{}{
+			"device_id":  a.DeviceID,
+			"driver_id":  a.DriverID,
+			"alert_type": a.AlertType,
+			"severity":   a.Severity,
+			"timestamp":  a.Timestamp.In(loc).Format(time.RFC3339),
+		})
+		feature.ID = a.ID
+		features = append(features, feature)
+	}
+	c.JSON(http.StatusOK, geo.NewFeatureCollection(features))
+}
+
+// AdminSessionTrack returns the GPS track of a session as GeoJSON: one
+// simplified LineString per run of samples at the same level, so dangerous
+// road segments stand out, plus a Point per located alert.
+// Query: tolerance (meters, default 10) and tz.
+func (s *Server) AdminSessionTrack(c *gin.Context) {
+	noCache(c)
+	ctx := c.Request.Context()
+	loc, ok := s.requestLocation(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Invalid session id")
+		return
+	}
+	tolerance := defaultTrackTolerance
+	if v := c.Query("tolerance"); v != "" {
+		if tolerance, err = strconv.ParseFloat(v, 64); err != nil || tolerance < 0 {
+			respondError(c, http.StatusBadRequest, "Invalid tolerance")
+			return
+		}
+	}
+
+	session, err := s.store.Sessions.GetByID(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, "Session not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Error fetching session", "session_id", id, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to fetch session")
+		return
+	}
+	end := session.LastSampleAt
+	if session.EndedAt != nil {
+		end = *session.EndedAt
+	}
+	r := repository.TimeRange{From: session.StartedAt, To: end.Add(time.Microsecond)}
+
+	samples, err := s.store.Drowsiness.Between(ctx, session.DeviceID, r)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error fetching session samples", "session_id", id, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to fetch session")
+		return
+	}
+	alerts, err := s.store.Alerts.Between(ctx, session.DeviceID, r)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error fetching session alerts", "session_id", id, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to fetch session")
+		return
+	}
+
+	var features []geo.Feature
+	var run []geo.Point
+	var runLevel string
+	var runStart, runEnd time.Time
+	flush := func() {
+		if len(run) < 2 {
+			return
+		}
+		distance := 0.0
+		for i := 1; i < len(run); i++ {
+			distance += geo.Distance(run[i-1], run[i])
+		}
+		features = append(features, geo.LineFeature(geo.Simplify(run, tolerance), map[string]interface{}{
+			"kind":       "segment",
+			"level":      runLevel,
+			"start":      runStart.In(loc).Format(time.RFC3339),
+			"end":        runEnd.In(loc).Format(time.RFC3339),
+			"distance_m": distance,
+		}))
+	}
+	for _, d := range samples {
+		if d.Telemetry == nil || d.Telemetry.GPS == nil {
+			continue
+		}
+		p := geo.Point{Lat: d.Telemetry.GPS.Latitude, Lon: d.Telemetry.GPS.Longitude}
+		if level := strings.ToLower(d.DrowsinessLevel); level != runLevel {
+			flush()
+			// Start where the previous segment ended so the line stays connected
+			var last []geo.Point
+			if len(run) > 0 {
+				last = []geo.Point{run[len(run)-1]}
+				runStart = runEnd
+			} else {
+				runStart = d.Timestamp
+			}
+			run, runLevel = append(last, p), level
+		} else {
+			run = append(run, p)
+		}
+		runEnd = d.Timestamp
+	}
+	flush()
+
+	for _, a := range alerts {
+		if a.Location == nil {
+			continue
+		}
+		feature := geo.PointFeature(geo.Point{Lat: a.Location.Latitude, Lon: a.Location.Longitude}, map[string]interface{}{
+			"kind":       "alert",
+			"alert_type": a.AlertType,
+			"severity":   a.Severity,
+			"timestamp":  a.Timestamp.In(loc).Format(time.RFC3339),
+		})
+		feature.ID = a.ID
+		features = append(features, feature)
+	}
+	c.JSON(http.StatusOK, geo.NewFeatureCollection(features))
+}
@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"driver-drowsiness-backend/models"
+	"driver-drowsiness-backend/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// escalationSeverity is used by escalate rules that name no severity
+const escalationSeverity = "critical"
+
+// observeGeofences records enter/exit events of a located sample and raises
+// an alert for every escalate rule it triggers
+func (s *Server) observeGeofences(c *gin.Context, d models.DrowsinessData) {
+	ctx := c.Request.Context()
+	fix := *d.Telemetry.GPS
+	res, err := s.fences.Observe(ctx, d.DeviceID, fix, d.DrowsinessLevel, d.Timestamp)
+	if err != nil {
+		slog.WarnContext(ctx, "Could not evaluate geofences", "device_id", d.DeviceID, "error", err)
+		return
+	}
+	for _, e := range res.Events {
+		slog.InfoContext(ctx, "Geofence event", "device_id", d.DeviceID, "event", e.Event, "geofence_id", e.GeofenceID)
+	}
+	for _, m := range res.Escalations {
+		severity := m.Rule.Severity
+		if severity == "" {
+			severity = escalationSeverity
+		}
+		alert := models.Alert{
+			DeviceID:   d.DeviceID,
+			AlertType:  "geofence_escalation",
+			Severity:   severity,
+			Status:     "active",
+			Location:   &fix,
+			GeofenceID: m.Geofence.ID,
+			DriverID:   d.DriverID,
+			Timestamp:  d.Timestamp,
+		}
+		if err := s.store.Alerts.Insert(ctx, &alert); err != nil {
+			slog.ErrorContext(ctx, "Error inserting geofence alert", "error", err)
+			continue
+		}
+		s.metrics.alerts.Inc(alert.Severity)
+		slog.InfoContext(ctx, "Drowsiness escalated inside geofence", "drowsiness", d.DrowsinessLevel, "device_id", d.DeviceID,
+			"geofence", m.Geofence.Name, "severity", severity)
+	}
+}
+
+// geofenceID parses the ":id" path parameter
+func geofenceID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Invalid geofence id")
+		return 0, false
+	}
+	return id, true
+}
+
+// bindGeofence reads and validates a geofence request body
+func bindGeofence(c *gin.Context) (*models.Geofence, bool) {
+	var req models.GeofenceRequest
+	if !bindJSON(c, &req) {
+		return nil, false
+	}
+	if err := req.Validate(); err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+	return &models.Geofence{Name: req.Name, Kind: req.Kind, Polygon: req.Polygon, Rules: req.Rules}, true
+}
+
+// ListGeofences returns every geofence with its rules
+func (s *Server) ListGeofences(c *gin.Context) {
+	geofences, err := s.store.Geofences.List(c.Request.Context())
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error fetching geofences", "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to fetch geofences")
+		return
+	}
+	if geofences == nil {
+		geofences = []models.Geofence{}
+	}
+	c.JSON(http.StatusOK, gin.H{"count": len(geofences), "geofences": geofences})
+}
+
+// GetGeofence returns one geofence
+func (s *Server) GetGeofence(c *gin.Context) {
+	id, ok := geofenceID(c)
+	if !ok {
+		return
+	}
+	g, err := s.store.Geofences.GetByID(c.Request.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, "Geofence not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error fetching geofence", "geofence_id", id, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to fetch geofence")
+		return
+	}
+	c.JSON(http.StatusOK, g)
+}
+
+// CreateGeofence adds a geofence
+func (s *Server) CreateGeofence(c *gin.Context) {
+	g, ok := bindGeofence(c)
+	if !ok {
+		return
+	}
+	if err := s.store.Geofences.Create(c.Request.Context(), g); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error creating geofence", "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to create geofence")
+		return
+	}
+	s.fences.Invalidate()
+	c.JSON(http.StatusCreated, g)
+}
+
+// UpdateGeofence replaces the polygon, rules and name of a geofence
+func (s *Server) UpdateGeofence(c *gin.Context) {
+	id, ok := geofenceID(c)
+	if !ok {
+		return
+	}
+	g, ok := bindGeofence(c)
+	if !ok {
+		return
+	}
+	g.ID = id
+	err := s.store.Geofences.Update(c.Request.Context(), g)
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, "Geofence not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error updating geofence", "geofence_id", id, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to update geofence")
+		return
+	}
+	s.fences.Invalidate()
+	c.JSON(http.StatusOK, g)
+}
+
+// DeleteGeofence removes a geofence and its events
+func (s *Server) DeleteGeofence(c *gin.Context) {
+	id, ok := geofenceID(c)
+	if !ok {
+		return
+	}
+	err := s.store.Geofences.Delete(c.Request.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, "Geofence not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error deleting geofence", "geofence_id", id, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to delete geofence")
+		return
+	}
+	s.fences.Invalidate()
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// ListGeofenceEvents returns enter/exit events newest first.
+// Query: device_id, geofence_id, from, to and limit.
+func (s *Server) ListGeofenceEvents(c *gin.Context) {
+	noCache(c)
+	loc, ok := s.requestLocation(c)
+	if !ok {
+		return
+	}
+	f := repository.GeofenceEventFilter{DeviceID: c.Query("device_id"), Limit: queryLimit(c, 100)}
+	if v := c.Query("geofence_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Invalid geofence_id")
+			return
+		}
+		f.GeofenceID = id
+	}
+	if v := c.Query("from"); v != "" {
+		t, err := parseTimeParam(v, loc)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Invalid from")
+			return
+		}
+		f.Range.From = t.UTC()
+	}
+	if v := c.Query("to"); v != "" {
+		t, err := parseTimeParam(v, loc)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Invalid to")
+			return
+		}
+		f.Range.To = t.UTC()
+	}
+
+	events, err := s.store.Geofences.Events(c.Request.Context(), f)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error fetching geofence events", "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to fetch geofence events")
+		return
+	}
+	if events == nil {
+		events = []models.GeofenceEvent{}
+	}
+	c.JSON(http.StatusOK, gin.H{"count": len(events), "events": events})
+}
@@ -1,14 +1,28 @@
 package handlers
 
 import (
-	"database/sql"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"time"
 
+	"driver-drowsiness-backend/commands"
+	"driver-drowsiness-backend/compliance"
 	"driver-drowsiness-backend/config"
-	"driver-drowsiness-backend/database"
+	"driver-drowsiness-backend/digest"
+	"driver-drowsiness-backend/evidence"
+	"driver-drowsiness-backend/faceid"
+	"driver-drowsiness-backend/fatigue"
+	"driver-drowsiness-backend/geofence"
+	"driver-drowsiness-backend/health"
+	"driver-drowsiness-backend/logging"
 	"driver-drowsiness-backend/models"
+	"driver-drowsiness-backend/pdf"
+	"driver-drowsiness-backend/repository"
+	"driver-drowsiness-backend/sessions"
+	"driver-drowsiness-backend/storage"
+	"driver-drowsiness-backend/usage"
 	"strconv"
 	"strings"
 
@@ -17,8 +31,151 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// Server holds the dependencies shared by all HTTP handlers
+type Server struct {
+	store   *repository.Store
+	cfg     *config.Config
+	now     func() time.Time
+	tracker *sessions.Tracker
+	fatigue *fatigue.Analyzer
+	fences  *geofence.Engine
+	hos     *compliance.Monitor
+	health  *health.Monitor
+	queue   *commands.Queue
+	faces   *faceid.Verifier
+
+	// Alert evidence
+	files     storage.Backend
+	signer    *evidence.Signer
+	retention evidence.Retention
+
+	reportFont *pdf.Font
+
+	// Digest delivery channels
+	mailer   digest.Sender
+	webhooks digest.Sender
+
+	metrics    *serverMetrics
+	logSampler *logging.Sampler // per-device sampling of ingestion logs
+
+	// API versions
+	usage          *usage.Tracker
+	v1DeprecatedAt time.Time
+	v1Sunset       time.Time
+}
+
+// NewServer creates a Server backed by the given repositories and config
+func NewServer(store *repository.Store, cfg *config.Config) *Server {
+	gap := cfg.SessionGap
+	if gap <= 0 {
+		gap = 5 * time.Minute
+	}
+	fatigueCfg := fatigue.DefaultConfig()
+	if cfg.FatigueWindow > 0 {
+		fatigueCfg.Window = cfg.FatigueWindow
+	}
+	rules := compliance.DefaultRules()
+	rules.MaxSampleGap = gap
+	if cfg.MaxContinuousDriving > 0 {
+		rules.MaxContinuous = cfg.MaxContinuousDriving
+	}
+	if cfg.MinBreak > 0 {
+		rules.MinBreak = cfg.MinBreak
+	}
+	if cfg.MaxDailyDriving > 0 {
+		rules.MaxDaily = cfg.MaxDailyDriving
+	}
+	if cfg.DrivingWarnBefore > 0 {
+		rules.WarnBefore = cfg.DrivingWarnBefore
+	}
+	thresholds := health.DefaultThresholds()
+	if cfg.HeartbeatTimeout > 0 {
+		thresholds.Timeout = cfg.HeartbeatTimeout
+	}
+	if cfg.MaxCPUTemp > 0 {
+		thresholds.MaxCPUTemp = cfg.MaxCPUTemp
+	}
+	if cfg.MinFPS > 0 {
+		thresholds.MinFPS = cfg.MinFPS
+	}
+	retention := evidence.DefaultRetention()
+	if cfg.EvidenceRetention > 0 {
+		retention.Snapshot = cfg.EvidenceRetention
+	}
+	if cfg.EvidenceClipRetention > 0 {
+		retention.Clip = cfg.EvidenceClipRetention
+	}
+	faceThreshold := faceid.DefaultThreshold
+	if cfg.FaceMatchThreshold > 0 {
+		faceThreshold = cfg.FaceMatchThreshold
+	}
+	v1DeprecatedAt, v1Sunset := cfg.V1DeprecatedAt, cfg.V1Sunset
+	if v1DeprecatedAt.IsZero() {
+		v1DeprecatedAt = defaultV1DeprecatedAt
+	}
+	if v1Sunset.IsZero() {
+		v1Sunset = defaultV1Sunset
+	}
+	urlTTL := cfg.EvidenceURLTTL
+	if urlTTL <= 0 {
+		urlTTL = 15 * time.Minute
+	}
+	// Without a key, URLs are signed with one of this process only and stop
+	// working when it restarts
+	signingKey := cfg.EvidenceSigningKey
+	if signingKey == "" {
+		signingKey = randomToken(32)
+	}
+	s := &Server{
+		store:   store,
+		cfg:     cfg,
+		now:     time.Now,
+		tracker: sessions.NewTracker(store, gap),
+		fatigue: fatigue.NewAnalyzer(fatigueCfg),
+		fences:  geofence.NewEngine(store.Geofences),
+		hos:     compliance.NewMonitor(store.Compliance, rules),
+		health:  health.NewMonitor(store.Health, thresholds),
+		queue:   commands.NewQueue(store.Commands),
+		faces:   faceid.NewVerifier(store.Faces, faceThreshold),
+
+		files:     newFileStore(cfg),
+		signer:    evidence.NewSigner(signingKey, urlTTL),
+		retention: retention,
+
+		reportFont: loadReportFont(cfg.ReportFont),
+
+		mailer:   digest.NewMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUser, cfg.SMTPPassword, cfg.SMTPFrom),
+		webhooks: digest.NewWebhook(30*time.Second, cfg.WebhookAllowPrivate),
+
+		usage:          usage.NewTracker(time.Now().UTC()),
+		v1DeprecatedAt: v1DeprecatedAt,
+		v1Sunset:       v1Sunset,
+
+		metrics:    newServerMetrics(),
+		logSampler: logging.NewSampler(cfg.LogSampleInterval),
+	}
+	s.collectOnline()
+	return s
+}
+
+// noCache prevents client/proxy caching so dashboards always see fresh data
+func noCache(c *gin.Context) {
+	c.Header("Cache-Control", "no-store, no-cache, must-revalidate, private")
+	c.Header("Pragma", "no-cache")
+	c.Header("Expires", "0")
+}
+
+// queryLimit parses a positive "limit" query parameter with a fallback
+func queryLimit(c *gin.Context, fallback int) int {
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit <= 0 {
+		return fallback
+	}
+	return limit
+}
+
 // Root returns a simple landing response
-func Root(c *gin.Context) {
+func (s *Server) Root(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"app":     "Driver Drowsiness Detection API",
 		"version": "v1",
@@ -29,299 +186,278 @@ func Root(c *gin.Context) {
 			"/api/devices/:id/data",
 			"/api/devices/:id/alerts",
 			"/api/devices/:id/history",
+			"/api/openapi.json",
+			"/api/docs",
+			"/api/v2/docs",
 		},
-		"time": time.Now().Format(time.RFC3339),
+		"api_versions": gin.H{apiV1: "/api/" + apiV1, apiV2: "/api/" + apiV2}, // /api is v1
+		"time":         s.now().Format(time.RFC3339),
 	})
 }
 
 // HealthCheck returns API health status
-func HealthCheck(c *gin.Context) {
+func (s *Server) HealthCheck(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"status":  "ok",
 		"message": "Driver Drowsiness Detection API is running",
-		"time":    time.Now().Format(time.RFC3339),
+		"time":    s.now().Format(time.RFC3339),
 	})
 }
 
 // DevToolsManifest returns a minimal JSON for Chrome devtools probing
-func DevToolsManifest(c *gin.Context) {
+func (s *Server) DevToolsManifest(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"version": 1,
 		"targets": []gin.H{},
 	})
 }
 
-// ensureDeviceExists creates a device if it doesn't exist (auto-registration)
-func ensureDeviceExists(deviceID string, driverEmail string) error {
-	// Use UPSERT (INSERT ... ON CONFLICT DO NOTHING)
-	_, err := database.DB.Exec(`
-		INSERT INTO devices (id, driver_email, status, last_update, created_at)
-		VALUES ($1, $2, 'active', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
-		ON CONFLICT (id) DO UPDATE SET last_update = CURRENT_TIMESTAMP
-	`, deviceID, driverEmail)
-	return err
+// ReceiveDeviceData receives drowsiness data from Python hardware
+func (s *Server) ReceiveDeviceData(c *gin.Context) {
+	var payload models.DataPayload
+	if !bindJSON(c, &payload) {
+		return
+	}
+	s.ingestData(c, payload)
 }
 
-// ReceiveDeviceData receives drowsiness data from Python hardware
-func ReceiveDeviceData(c *gin.Context) {
+// ingestData stores a sample of any API version and answers the device
+func (s *Server) ingestData(c *gin.Context, payload models.DataPayload) {
+	ctx := c.Request.Context()
 	deviceID := c.Param("id")
 
-	var payload models.DataPayload
-	if err := c.ShouldBindJSON(&payload); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON payload"})
+	// Payloads of older firmware carry no schema_version
+	version := payload.SchemaVersion
+	if version == 0 {
+		version = models.TelemetrySchemaV1
+	}
+	if version < models.TelemetrySchemaV1 || version > models.TelemetrySchemaLatest {
+		respondError(c, http.StatusBadRequest, fmt.Sprintf("Unsupported schema_version %d (latest is %d)", version, models.TelemetrySchemaLatest))
+		return
+	}
+	if err := payload.Telemetry.Validate(); err != nil {
+		respondError(c, http.StatusBadRequest, "Invalid telemetry: "+err.Error())
 		return
 	}
 
-	// Auto-register device if it doesn't exist
+	// Use server-side timestamp to ensure consistent ordering
+	timestamp := s.now().UTC()
+
+	// Auto-register device if it doesn't exist and bump last_update
 	driverEmail := payload.DriverEmail
 	if driverEmail == "" {
 		driverEmail = "unknown@device.local" // Default email for unregistered devices
 	}
-	if err := ensureDeviceExists(deviceID, driverEmail); err != nil {
-		log.Printf("⚠️ Warning: Could not ensure device exists: %v", err)
+	if err := s.store.Devices.Touch(ctx, deviceID, driverEmail, timestamp); err != nil {
+		slog.WarnContext(ctx, "Could not ensure device exists", "device_id", deviceID, "error", err)
 	}
 
-	// Use server-side timestamp to ensure consistent ordering
-	timestamp := time.Now()
-
-	// Insert data into database
-	_, err := database.DB.Exec(`
-		INSERT INTO drowsiness_data (device_id, eye_closure, drowsiness_level, status, timestamp)
-		VALUES ($1, $2, $3, $4, $5)
-	`, deviceID, payload.EyeClosure, payload.DrowsinessLevel, payload.Status, timestamp)
+	// Score eye closure on the server so levels do not depend on firmware
+	update := s.fatigue.Observe(deviceID, timestamp, payload.EyeClosure)
 
-	if err != nil {
-		log.Printf("❌ Error inserting data: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save data"})
+	data := models.DrowsinessData{
+		DeviceID:        deviceID,
+		DriverID:        s.driverAt(ctx, deviceID, timestamp),
+		EyeClosure:      payload.EyeClosure,
+		DrowsinessLevel: payload.DrowsinessLevel,
+		Status:          payload.Status,
+		FatigueScore:    update.Metrics.Score,
+		FatigueLevel:    update.Metrics.Level,
+		SchemaVersion:   version,
+		Timestamp:       timestamp,
+	}
+	if !payload.Telemetry.Empty() {
+		telemetry := payload.Telemetry
+		data.Telemetry = &telemetry
+	}
+	if err := s.store.Drowsiness.Insert(ctx, &data); err != nil {
+		slog.ErrorContext(ctx, "Error inserting data", "device_id", deviceID, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to save data")
 		return
 	}
-
-	// Update device last_update
-	_, err = database.DB.Exec(`
-		UPDATE devices SET last_update = $1 WHERE id = $2
-	`, time.Now(), deviceID)
-
-	if err != nil {
-		log.Printf("⚠️ Warning: Could not update device last_update: %v", err)
+	s.metrics.samples.Inc(deviceID)
+	if _, err := s.tracker.Observe(ctx, data, update); err != nil {
+		slog.WarnContext(ctx, "Could not update driving session", "device_id", deviceID, "error", err)
+	}
+	if data.Telemetry != nil && data.Telemetry.GPS != nil {
+		s.observeGeofences(c, data)
 	}
 
-	// Diagnostic: confirm latest row id for this device
-	var latestID int
-	_ = database.DB.QueryRow(`
-		SELECT id FROM drowsiness_data WHERE device_id = $1 ORDER BY timestamp DESC, id DESC LIMIT 1
-	`, deviceID).Scan(&latestID)
-
-	log.Printf("✅ Data received from device %s: drowsiness=%s, eye_closure=%.2f (latest id=%d)",
-		deviceID, payload.DrowsinessLevel, payload.EyeClosure, latestID)
+	// Devices send several samples a second: log one per device per interval
+	if ok, suppressed := s.logSampler.Allow(deviceID+" data", timestamp); ok {
+		slog.InfoContext(ctx, "Data received", "device_id", deviceID, "drowsiness", payload.DrowsinessLevel,
+			"eye_closure", payload.EyeClosure, "fatigue", data.FatigueScore, "id", data.ID, "suppressed", suppressed)
+	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"success":   true,
-		"message":   "Data received successfully",
-		"device_id": deviceID,
-	})
+	resp := gin.H{
+		"success":       true,
+		"message":       "Data received successfully",
+		"device_id":     deviceID,
+		"fatigue_score": data.FatigueScore,
+		"fatigue_level": data.FatigueLevel,
+	}
+	// Driving-time status lets the device warn the driver before a limit
+	if status := s.observeCompliance(ctx, data); status != nil {
+		resp["compliance"] = status
+	}
+	c.JSON(http.StatusOK, resp)
 }
 
 // ReceiveAlert receives alert from Python hardware
-func ReceiveAlert(c *gin.Context) {
+func (s *Server) ReceiveAlert(c *gin.Context) {
+	ctx := c.Request.Context()
 	deviceID := c.Param("id")
 
 	var payload models.AlertPayload
-	if err := c.ShouldBindJSON(&payload); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON payload"})
+	if !bindJSON(c, &payload) {
 		return
 	}
 
 	// Auto-register device if it doesn't exist
-	if err := ensureDeviceExists(deviceID, "unknown@device.local"); err != nil {
-		log.Printf("⚠️ Warning: Could not ensure device exists: %v", err)
+	now := s.now().UTC()
+	if err := s.store.Devices.Touch(ctx, deviceID, "unknown@device.local", now); err != nil {
+		slog.WarnContext(ctx, "Could not ensure device exists", "device_id", deviceID, "error", err)
 	}
 
 	// Parse timestamp or use current time
-	timestamp := time.Now()
+	timestamp := now
 	if payload.Timestamp != "" {
 		parsedTime, err := time.Parse(time.RFC3339, payload.Timestamp)
 		if err == nil {
-			timestamp = parsedTime
+			timestamp = parsedTime.UTC()
 		}
 	}
 
-	// Insert alert into database
-	_, err := database.DB.Exec(`
-		INSERT INTO alerts (device_id, alert_type, severity, timestamp, status)
-		VALUES ($1, $2, $3, $4, 'active')
-	`, deviceID, payload.AlertType, payload.Severity, timestamp)
+	if err := (&models.Telemetry{GPS: payload.GPS}).Validate(); err != nil {
+		respondError(c, http.StatusBadRequest, "Invalid location: "+err.Error())
+		return
+	}
 
-	if err != nil {
-		log.Printf("❌ Error inserting alert: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save alert"})
+	alert := models.Alert{
+		DeviceID:  deviceID,
+		AlertType: payload.AlertType,
+		Severity:  payload.Severity,
+		Status:    "active",
+		Location:  s.alertLocation(c, deviceID, payload.GPS, timestamp),
+		DriverID:  s.driverAt(ctx, deviceID, timestamp),
+		Timestamp: timestamp,
+	}
+	if err := s.fences.ApplyRules(ctx, &alert); err != nil {
+		slog.WarnContext(ctx, "Could not apply geofence rules to alert", "device_id", deviceID, "error", err)
+	}
+	if err := s.store.Alerts.Insert(ctx, &alert); err != nil {
+		slog.ErrorContext(ctx, "Error inserting alert", "device_id", deviceID, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to save alert")
 		return
 	}
+	s.metrics.alerts.Inc(alert.Severity)
 
-	log.Printf("🚨 Alert received from device %s: type=%s, severity=%s",
-		deviceID, payload.AlertType, payload.Severity)
+	slog.InfoContext(ctx, "Alert received", "device_id", deviceID, "type", payload.AlertType,
+		"severity", payload.Severity, "id", alert.ID)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success":   true,
 		"message":   "Alert received successfully",
 		"device_id": deviceID,
+		"alert_id":  alert.ID,
 	})
 }
 
 // GetDeviceLatestData returns the latest drowsiness data for a device
-func GetDeviceLatestData(c *gin.Context) {
+func (s *Server) GetDeviceLatestData(c *gin.Context) {
 	deviceID := c.Param("id")
+	noCache(c)
 
-	// Prevent client/proxy caching so latest data is always fetched
-	c.Header("Cache-Control", "no-store, no-cache, must-revalidate, private")
-	c.Header("Pragma", "no-cache")
-	c.Header("Expires", "0")
-
-	var data models.DrowsinessData
-	err := database.DB.QueryRow(`
-		SELECT id, device_id, eye_closure, drowsiness_level, status, timestamp, created_at
-		FROM drowsiness_data
-		WHERE device_id = $1
-		ORDER BY timestamp DESC, id DESC
-		LIMIT 1
-	`, deviceID).Scan(
-		&data.ID, &data.DeviceID, &data.EyeClosure,
-		&data.DrowsinessLevel, &data.Status, &data.Timestamp, &data.CreatedAt,
-	)
-
-	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "No data found for this device"})
+	data, err := s.store.Drowsiness.Latest(c.Request.Context(), deviceID)
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, "No data found for this device")
 		return
 	}
-
 	if err != nil {
-		log.Printf("❌ Error fetching data: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch data"})
+		slog.ErrorContext(c.Request.Context(), "Error fetching data", "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to fetch data")
 		return
 	}
 
 	c.JSON(http.StatusOK, data)
 }
 
-// GetDeviceHistory returns historical data for a device
-func GetDeviceHistory(c *gin.Context) {
+// GetDeviceHistory returns a page of the samples of a device, newest first.
+// Query: limit, cursor, from, to, level, status and tz.
+func (s *Server) GetDeviceHistory(c *gin.Context) {
 	deviceID := c.Param("id")
-	limit := c.DefaultQuery("limit", "100")
-
-	// Prevent caching
-	c.Header("Cache-Control", "no-store, no-cache, must-revalidate, private")
-	c.Header("Pragma", "no-cache")
-	c.Header("Expires", "0")
-
-	rows, err := database.DB.Query(`
-		SELECT id, device_id, eye_closure, drowsiness_level, status, timestamp, created_at
-		FROM drowsiness_data
-		WHERE device_id = $1
-		ORDER BY timestamp DESC, id DESC
-		LIMIT $2
-	`, deviceID, limit)
+	noCache(c)
+	f, ok := s.parsePage(c, 100)
+	if !ok {
+		return
+	}
+	f.Level = c.Query("level")
 
+	history, err := s.store.Drowsiness.History(c.Request.Context(), f)
 	if err != nil {
-		log.Printf("❌ Error fetching history: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch history"})
+		slog.ErrorContext(c.Request.Context(), "Error fetching history", "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to fetch history")
 		return
 	}
-	defer rows.Close()
-
-	var history []models.DrowsinessData
-	for rows.Next() {
-		var data models.DrowsinessData
-		err := rows.Scan(
-			&data.ID, &data.DeviceID, &data.EyeClosure,
-			&data.DrowsinessLevel, &data.Status, &data.Timestamp, &data.CreatedAt,
-		)
-		if err != nil {
-			log.Printf("❌ Error scanning row: %v", err)
-			continue
-		}
-		history = append(history, data)
+	lo, hi, next, prev := pageLinks(c, f, len(history), func(i int) (time.Time, int) {
+		return history[i].Timestamp, history[i].ID
+	})
+	history = history[lo:hi]
+	if history == nil {
+		history = []models.DrowsinessData{}
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"device_id": deviceID,
 		"count":     len(history),
-		"data":      history,
+		"items":     history,
+		"next":      next,
+		"prev":      prev,
 	})
 }
 
-// GetDeviceAlerts returns alerts for a device
-func GetDeviceAlerts(c *gin.Context) {
+// GetDeviceAlerts returns a page of the alerts of a device, newest first.
+// Query: limit, cursor, from, to, severity, status and tz.
+func (s *Server) GetDeviceAlerts(c *gin.Context) {
 	deviceID := c.Param("id")
-	limit := c.DefaultQuery("limit", "50")
-
-	// Prevent caching
-	c.Header("Cache-Control", "no-store, no-cache, must-revalidate, private")
-	c.Header("Pragma", "no-cache")
-	c.Header("Expires", "0")
-
-	rows, err := database.DB.Query(`
-		SELECT id, device_id, alert_type, severity, acknowledged, status, timestamp, created_at
-		FROM alerts
-		WHERE device_id = $1
-		ORDER BY timestamp DESC, id DESC
-		LIMIT $2
-	`, deviceID, limit)
+	noCache(c)
+	f, ok := s.parsePage(c, 50)
+	if !ok {
+		return
+	}
+	f.Severity = c.Query("severity")
 
+	alerts, err := s.store.Alerts.ListByDevice(c.Request.Context(), f)
 	if err != nil {
-		log.Printf("❌ Error fetching alerts: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch alerts"})
+		slog.ErrorContext(c.Request.Context(), "Error fetching alerts", "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to fetch alerts")
 		return
 	}
-	defer rows.Close()
-
-	var alerts []models.Alert
-	for rows.Next() {
-		var alert models.Alert
-		err := rows.Scan(
-			&alert.ID, &alert.DeviceID, &alert.AlertType, &alert.Severity,
-			&alert.Acknowledged, &alert.Status, &alert.Timestamp, &alert.CreatedAt,
-		)
-		if err != nil {
-			log.Printf("❌ Error scanning row: %v", err)
-			continue
-		}
-		alerts = append(alerts, alert)
+	lo, hi, next, prev := pageLinks(c, f, len(alerts), func(i int) (time.Time, int) {
+		return alerts[i].Timestamp, alerts[i].ID
+	})
+	alerts = alerts[lo:hi]
+	if alerts == nil {
+		alerts = []models.Alert{}
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"device_id": deviceID,
 		"count":     len(alerts),
-		"alerts":    alerts,
+		"items":     alerts,
+		"next":      next,
+		"prev":      prev,
 	})
 }
 
 // GetAllDevices returns all registered devices
-func GetAllDevices(c *gin.Context) {
-	rows, err := database.DB.Query(`
-		SELECT id, driver_email, status, last_update, created_at
-		FROM devices
-		ORDER BY last_update DESC
-	`)
-
+func (s *Server) GetAllDevices(c *gin.Context) {
+	devices, err := s.store.Devices.List(c.Request.Context())
 	if err != nil {
-		log.Printf("❌ Error fetching devices: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch devices"})
+		slog.ErrorContext(c.Request.Context(), "Error fetching devices", "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to fetch devices")
 		return
 	}
-	defer rows.Close()
-
-	var devices []models.Device
-	for rows.Next() {
-		var device models.Device
-		err := rows.Scan(
-			&device.ID, &device.DriverEmail, &device.Status,
-			&device.LastUpdate, &device.CreatedAt,
-		)
-		if err != nil {
-			log.Printf("❌ Error scanning row: %v", err)
-			continue
-		}
-		devices = append(devices, device)
-	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"count":   len(devices),
@@ -331,292 +467,122 @@ func GetAllDevices(c *gin.Context) {
 
 // AdminOverview returns aggregated statistics for master dashboard
 // - total_drivers: จำนวนผู้ขับขี่ทั้งหมดจาก users (role='driver')
-// - active_drivers: จำนวนผู้ขับขี่ที่มีการอัปเดตล่าสุดภายใน 1 นาที
+// - active_drivers: จำนวนผู้ขับขี่ที่อุปกรณ์ออนไลน์ (ตาม heartbeat; เฟิร์มแวร์เก่าใช้การอัปเดตภายใน 1 นาที)
 // - total_devices: จำนวน device id ทั้งหมดจาก devices
 // - alerts_today: การแจ้งเตือนระดับด่วนวันนี้ (drowsiness_level='high')
-func AdminOverview(c *gin.Context) {
+func (s *Server) AdminOverview(c *gin.Context) {
 	// Prevent caching so dashboard always sees latest summary
-	c.Header("Cache-Control", "no-store, no-cache, must-revalidate, private")
-	c.Header("Pragma", "no-cache")
-	c.Header("Expires", "0")
-	var totalDrivers int
-	var activeDrivers int
-	var totalDevices int
-	var alertsToday int
-	var criticalAlertsToday int
-
-	// Simplified query without mock tables - using Thailand timezone (UTC+7)
-	// Note: timestamp column stores UTC time, so we add 7 hours to convert to Bangkok time
-	query := `
-SELECT
-	COALESCE((SELECT COUNT(*) FROM users WHERE role = 'driver'), 0) AS total_drivers,
-	COALESCE((
-		SELECT COUNT(DISTINCT d.user_id)
-		FROM devices d
-		WHERE d.last_update >= NOW() - INTERVAL '1 minute'
-		  AND d.user_id IS NOT NULL
-	), 0) AS active_drivers,
-	COALESCE((SELECT COUNT(*) FROM devices), 0) AS total_devices,
-	COALESCE((
-		SELECT COUNT(*)
-		FROM drowsiness_data dd
-		WHERE (dd.timestamp + INTERVAL '7 hours')::date = (NOW() + INTERVAL '7 hours')::date
-		  AND LOWER(dd.drowsiness_level) = 'high'
-	), 0) AS alerts_today,
-	COALESCE((
-		SELECT COUNT(*)
-		FROM drowsiness_data dd
-		WHERE (dd.timestamp + INTERVAL '7 hours')::date = (NOW() + INTERVAL '7 hours')::date
-		  AND LOWER(dd.drowsiness_level) = 'high'
-	), 0) AS critical_alerts_today;
-`
-
-	if err := database.DB.QueryRow(query).Scan(&totalDrivers, &activeDrivers, &totalDevices, &alertsToday, &criticalAlertsToday); err != nil {
-		log.Printf("❌ Error fetching admin overview stats: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch overview stats"})
+	noCache(c)
+	s.sweepDevices(c.Request.Context())
+	loc, ok := s.requestLocation(c)
+	if !ok {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"total_drivers":         totalDrivers,
-		"active_drivers":        activeDrivers,
-		"total_devices":         totalDevices,
-		"alerts_today":          alertsToday,
-		"critical_alerts_today": criticalAlertsToday,
-		"generated_at":          time.Now().Format(time.RFC3339),
-	})
+	now := s.now()
+	overview, err := s.store.Dashboard.Overview(c.Request.Context(), now, repository.DayRange(now, loc))
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error fetching admin overview stats", "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to fetch overview stats")
+		return
+	}
+	overview.GeneratedAt = now.In(loc).Format(time.RFC3339)
+
+	c.JSON(http.StatusOK, overview)
 }
 
 // AdminDrivers returns a list of drivers with online status
 // and count of today's critical alerts, for use in the master dashboard driver table.
-// Online criteria: devices.last_update ภายใน 1 นาที
-func AdminDrivers(c *gin.Context) {
+// Online criteria: สถานะจาก heartbeat (ไม่ใช่ offline); อุปกรณ์ที่ไม่ส่ง heartbeat ใช้ devices.last_update ภายใน 1 นาที
+func (s *Server) AdminDrivers(c *gin.Context) {
 	// Prevent caching so driver list reflects real-time status
-	c.Header("Cache-Control", "no-store, no-cache, must-revalidate, private")
-	c.Header("Pragma", "no-cache")
-	c.Header("Expires", "0")
+	noCache(c)
+	s.sweepDevices(c.Request.Context())
+	loc, ok := s.requestLocation(c)
+	if !ok {
+		return
+	}
 
-	const driversQuery = `
-SELECT
-	u.id,
-	COALESCE(NULLIF(u.name, ''), u.email) AS name,
-	COALESCE(dev.device_id, '') AS device_id,
-	COALESCE(act.last_ts, NULL) AS last_ts,
-	dev.last_update,
-	CASE
-		WHEN (
-			(act.last_ts IS NOT NULL AND act.last_ts >= NOW() - INTERVAL '1 minute')
-			OR (dev.last_update IS NOT NULL AND dev.last_update >= NOW() - INTERVAL '1 minute')
-		) THEN TRUE
-		ELSE FALSE
-	END AS is_online,
-	COALESCE(ac.critical_count, 0) AS critical_alerts_today
-FROM users u
-LEFT JOIN LATERAL (
-	SELECT d.id AS device_id,
-	       d.last_update
-	FROM devices d
-	WHERE d.user_id = u.id
-	ORDER BY d.created_at DESC
-	LIMIT 1
-) dev ON TRUE
-LEFT JOIN LATERAL (
-	SELECT MAX(dd.timestamp) AS last_ts
-	FROM drowsiness_data dd
-	WHERE dd.device_id = dev.device_id
-		AND (dd.timestamp + INTERVAL '7 hours')::date = (NOW() + INTERVAL '7 hours')::date
-) act ON TRUE
-LEFT JOIN LATERAL (
-	SELECT COUNT(*) AS critical_count
-	FROM drowsiness_data dd
-	WHERE dd.device_id = dev.device_id
-		AND (dd.timestamp + INTERVAL '7 hours')::date = (NOW() + INTERVAL '7 hours')::date
-		AND LOWER(dd.drowsiness_level) = 'high'
-) ac ON TRUE
-WHERE u.role = 'driver';`
-
-	var results []models.AdminDriverSummary
-
-	// Query drivers
-	rows, err := database.DB.Query(driversQuery)
+	now := s.now()
+	results, err := s.store.Dashboard.DriverSummaries(c.Request.Context(), now, repository.DayRange(now, loc))
 	if err != nil {
-		log.Printf("error querying drivers: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query drivers"})
-		return
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var (
-			userID         int
-			name           string
-			deviceID       string
-			lastTS         sql.NullTime
-			lastUpdate     sql.NullTime
-			isOnlineSQL    bool
-			criticalAlerts int
-		)
-		if err := rows.Scan(&userID, &name, &deviceID, &lastTS, &lastUpdate, &isOnlineSQL, &criticalAlerts); err != nil {
-			log.Printf("error scanning driver row: %v", err)
-			continue
-		}
-
-		results = append(results, models.AdminDriverSummary{
-			ID:                  strconv.Itoa(userID),
-			Name:                name,
-			DeviceID:            deviceID,
-			IsOnline:            isOnlineSQL,
-			CriticalAlertsToday: criticalAlerts,
-			Source:              "real",
-		})
+		slog.ErrorContext(c.Request.Context(), "Error querying drivers", "error", err)
+		respondError(c, http.StatusInternalServerError, "failed to query drivers")
+		return
 	}
 
-	if err := rows.Err(); err != nil {
-		log.Printf("error after iterating driver rows: %v", err)
+	for i := range results {
+		if !results[i].IsOnline {
+			continue
+		}
+		if m, ok := s.fatigue.Latest(results[i].DeviceID); ok {
+			score := m.Score
+			results[i].FatigueScore = &score
+			results[i].FatigueLevel = m.Level
+		}
 	}
 
 	c.JSON(http.StatusOK, gin.H{"drivers": results})
 }
 
-// AdminRecentAlerts returns a unified list of recent medium/high drowsiness events
-// from both real devices (drowsiness_data) and mock drivers (mock_drowsiness_events).
+// AdminRecentAlerts returns a unified list of recent medium/high drowsiness events.
 // This is used by the master dashboard "recent alerts" card and includes both
 // medium (warning) and high (critical) severity events.
-func AdminRecentAlerts(c *gin.Context) {
+func (s *Server) AdminRecentAlerts(c *gin.Context) {
 	// Prevent caching for recent alerts feed
-	c.Header("Cache-Control", "no-store, no-cache, must-revalidate, private")
-	c.Header("Pragma", "no-cache")
-	c.Header("Expires", "0")
-	limitStr := c.DefaultQuery("limit", "20")
-	limit, err := strconv.Atoi(limitStr)
-	if err != nil || limit <= 0 {
-		limit = 20
-	}
-
-	query := `
-SELECT
-	dd.timestamp AS ts,
-	COALESCE(NULLIF(u.name, ''), u.email) AS driver_name,
-	CASE
-		WHEN LOWER(dd.drowsiness_level) = 'high' THEN 'ความเหนื่อยล้าสูง'
-		WHEN LOWER(dd.drowsiness_level) = 'medium' THEN 'เหนื่อยล้าเล็กน้อย'
-		ELSE 'สถานะปกติ'
-	END AS alert_type,
-	CASE
-		WHEN LOWER(dd.drowsiness_level) = 'high' THEN 'critical'
-		WHEN LOWER(dd.drowsiness_level) = 'medium' THEN 'warning'
-		ELSE 'info'
-	END AS severity,
-	d.id AS vehicle_id,
-	'real' AS source
-FROM drowsiness_data dd
-JOIN devices d ON dd.device_id = d.id
-JOIN users u ON d.user_id = u.id
-WHERE LOWER(dd.drowsiness_level) IN ('medium', 'high')
-	AND u.role = 'driver'
-ORDER BY ts DESC
-LIMIT $1;
-`
-
-	rows, err := database.DB.Query(query, limit)
+	noCache(c)
+	limit := queryLimit(c, 20)
+	loc, ok := s.requestLocation(c)
+	if !ok {
+		return
+	}
+
+	rows, err := s.store.Dashboard.RecentAlerts(c.Request.Context(), limit)
 	if err != nil {
-		log.Printf("error querying recent alerts: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch recent alerts"})
+		slog.ErrorContext(c.Request.Context(), "Error querying recent alerts", "error", err)
+		respondError(c, http.StatusInternalServerError, "failed to fetch recent alerts")
 		return
 	}
-	defer rows.Close()
 
 	var results []models.AdminRecentAlert
-	for rows.Next() {
-		var (
-			ts       time.Time
-			driver   string
-			typeStr  string
-			severity string
-			vehicle  string
-			source   string
-		)
-		if err := rows.Scan(&ts, &driver, &typeStr, &severity, &vehicle, &source); err != nil {
-			log.Printf("error scanning recent alert row: %v", err)
-			continue
+	for _, row := range rows {
+		alert := models.AdminRecentAlert{
+			Time:         row.Timestamp.In(loc).Format("15:04"),
+			Driver:       row.Driver,
+			Type:         "สถานะปกติ",
+			Severity:     "info",
+			VehicleID:    row.DeviceID,
+			VehiclePlate: row.VehiclePlate,
+			Source:       "real",
 		}
-
-		results = append(results, models.AdminRecentAlert{
-			Time:      ts.UTC().Format("15:04"),
-			Driver:    driver,
-			Type:      typeStr,
-			Severity:  severity,
-			VehicleID: vehicle,
-			Source:    source,
-		})
-	}
-
-	if err := rows.Err(); err != nil {
-		log.Printf("error after iterating recent alert rows: %v", err)
+		switch row.Level {
+		case "high":
+			alert.Type, alert.Severity = "ความเหนื่อยล้าสูง", "critical"
+		case "medium":
+			alert.Type, alert.Severity = "เหนื่อยล้าเล็กน้อย", "warning"
+		}
+		results = append(results, alert)
 	}
 
 	c.JSON(http.StatusOK, gin.H{"alerts": results})
 }
 
 // AdminAlertSlots returns aggregated high-level alerts per time slot
-// Slots are 2-hour windows from 06:00-24:00 (06-08, 08-10, ..., 22-24).
-func AdminAlertSlots(c *gin.Context) {
+// Slots are 2-hour windows from 06:00-24:00 (06-08, 08-10, ..., 22-24),
+// bucketed by the local hour of the business timezone.
+func (s *Server) AdminAlertSlots(c *gin.Context) {
 	// Prevent caching so time-slot analytics stay fresh
-	c.Header("Cache-Control", "no-store, no-cache, must-revalidate, private")
-	c.Header("Pragma", "no-cache")
-	c.Header("Expires", "0")
-	// Predefine all slots to ensure zero-count slots are included
-	slotLabels := []string{"06-08", "08-10", "10-12", "12-14", "14-16", "16-18", "18-20", "20-22", "22-24"}
-
-	// Using Thailand timezone (UTC+7) - add 7 hours to convert from UTC to Bangkok time
-	// Use subquery because PostgreSQL doesn't allow column aliases in GROUP BY/HAVING
-	query := `
-SELECT slot, COUNT(*) AS cnt
-FROM (
-    SELECT
-        CASE
-            WHEN EXTRACT(HOUR FROM dd.timestamp + INTERVAL '7 hours') >= 6 AND EXTRACT(HOUR FROM dd.timestamp + INTERVAL '7 hours') < 8 THEN '06-08'
-            WHEN EXTRACT(HOUR FROM dd.timestamp + INTERVAL '7 hours') >= 8 AND EXTRACT(HOUR FROM dd.timestamp + INTERVAL '7 hours') < 10 THEN '08-10'
-            WHEN EXTRACT(HOUR FROM dd.timestamp + INTERVAL '7 hours') >= 10 AND EXTRACT(HOUR FROM dd.timestamp + INTERVAL '7 hours') < 12 THEN '10-12'
-            WHEN EXTRACT(HOUR FROM dd.timestamp + INTERVAL '7 hours') >= 12 AND EXTRACT(HOUR FROM dd.timestamp + INTERVAL '7 hours') < 14 THEN '12-14'
-            WHEN EXTRACT(HOUR FROM dd.timestamp + INTERVAL '7 hours') >= 14 AND EXTRACT(HOUR FROM dd.timestamp + INTERVAL '7 hours') < 16 THEN '14-16'
-            WHEN EXTRACT(HOUR FROM dd.timestamp + INTERVAL '7 hours') >= 16 AND EXTRACT(HOUR FROM dd.timestamp + INTERVAL '7 hours') < 18 THEN '16-18'
-            WHEN EXTRACT(HOUR FROM dd.timestamp + INTERVAL '7 hours') >= 18 AND EXTRACT(HOUR FROM dd.timestamp + INTERVAL '7 hours') < 20 THEN '18-20'
-            WHEN EXTRACT(HOUR FROM dd.timestamp + INTERVAL '7 hours') >= 20 AND EXTRACT(HOUR FROM dd.timestamp + INTERVAL '7 hours') < 22 THEN '20-22'
-            WHEN EXTRACT(HOUR FROM dd.timestamp + INTERVAL '7 hours') >= 22 AND EXTRACT(HOUR FROM dd.timestamp + INTERVAL '7 hours') < 24 THEN '22-24'
-            ELSE NULL
-        END AS slot
-    FROM drowsiness_data dd
-    WHERE (dd.timestamp + INTERVAL '7 hours')::date = (NOW() + INTERVAL '7 hours')::date
-      AND LOWER(dd.drowsiness_level) = 'high'
-) sub
-WHERE slot IS NOT NULL
-GROUP BY slot;
-`
-
-	rows, err := database.DB.Query(query)
-	if err != nil {
-		log.Printf("error querying alert slots: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch alert slots"})
+	noCache(c)
+	loc, ok := s.requestLocation(c)
+	if !ok {
 		return
 	}
-	defer rows.Close()
-
-	counts := make(map[string]int)
-	for rows.Next() {
-		var (
-			slotLabel string
-			count     int
-		)
-		if err := rows.Scan(&slotLabel, &count); err != nil {
-			log.Printf("error scanning alert slot row: %v", err)
-			continue
-		}
-		counts[slotLabel] = count
-	}
 
-	if err := rows.Err(); err != nil {
-		log.Printf("error after iterating alert slot rows: %v", err)
+	hourly, err := s.store.Dashboard.HighCountsByHour(c.Request.Context(), repository.DayRange(s.now(), loc), loc)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error querying alert slots", "error", err)
+		respondError(c, http.StatusInternalServerError, "failed to fetch alert slots")
+		return
 	}
 
 	var (
@@ -626,8 +592,10 @@ GROUP BY slot;
 		peakCnt   int
 	)
 
-	for _, label := range slotLabels {
-		cnt := counts[label]
+	// Predefine all slots to ensure zero-count slots are included
+	for start := 6; start < 24; start += 2 {
+		label := fmt.Sprintf("%02d-%02d", start, start+2)
+		cnt := hourly[start] + hourly[start+1]
 		slots = append(slots, models.AdminAlertSlot{Label: label, Count: cnt})
 		totalHigh += cnt
 		if cnt > peakCnt {
@@ -646,27 +614,18 @@ GROUP BY slot;
 
 // AdminAlertLevels returns aggregated counts and percentages of medium/high alerts
 // for use in the donut card.
-func AdminAlertLevels(c *gin.Context) {
+func (s *Server) AdminAlertLevels(c *gin.Context) {
 	// Prevent caching for alert level distribution (donut card)
-	c.Header("Cache-Control", "no-store, no-cache, must-revalidate, private")
-	c.Header("Pragma", "no-cache")
-	c.Header("Expires", "0")
-	// Using Thailand timezone (UTC+7) - add 7 hours to convert from UTC to Bangkok time
-	query := `
-SELECT
-	COUNT(*) FILTER (WHERE LOWER(dd.drowsiness_level) = 'high') AS high_total,
-	COUNT(*) FILTER (WHERE LOWER(dd.drowsiness_level) = 'medium') AS medium_total
-FROM drowsiness_data dd
-JOIN devices d ON dd.device_id = d.id
-JOIN users u ON d.user_id = u.id
-WHERE u.role = 'driver'
-  AND (dd.timestamp + INTERVAL '7 hours')::date = (NOW() + INTERVAL '7 hours')::date
-`
+	noCache(c)
+	loc, ok := s.requestLocation(c)
+	if !ok {
+		return
+	}
 
-	var highCount, mediumCount int
-	if err := database.DB.QueryRow(query).Scan(&highCount, &mediumCount); err != nil {
-		log.Printf("error querying alert levels: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch alert levels"})
+	highCount, mediumCount, err := s.store.Dashboard.LevelCounts(c.Request.Context(), repository.DayRange(s.now(), loc))
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error querying alert levels", "error", err)
+		respondError(c, http.StatusInternalServerError, "failed to fetch alert levels")
 		return
 	}
 
@@ -697,42 +656,61 @@ WHERE u.role = 'driver'
 // ================== AUTH HANDLERS & MIDDLEWARE ==================
 
 // Register creates a new user account
-func Register(c *gin.Context) {
+func (s *Server) Register(c *gin.Context) {
+	ctx := c.Request.Context()
 	var req models.RegisterRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+	if !bindJSON(c, &req) {
 		return
 	}
 
 	// Check existing user
-	if _, err := database.GetUserByEmail(req.Email); err == nil {
-		c.JSON(http.StatusConflict, gin.H{"error": "Email already registered"})
+	if _, err := s.store.Users.GetByEmail(ctx, req.Email); err == nil {
+		respondError(c, http.StatusConflict, "Email already registered")
 		return
 	}
 
+	if req.FleetID != 0 {
+		if _, err := s.store.Fleets.GetByID(ctx, req.FleetID); err != nil {
+			respondError(c, http.StatusBadRequest, "Unknown fleet")
+			return
+		}
+	}
+
 	// Hash password
 	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		respondError(c, http.StatusInternalServerError, "Failed to hash password")
 		return
 	}
 
-	userID, err := database.CreateUser(req.Email, string(hash), req.Name, "driver", req.Phone, req.UserType)
+	userID, err := s.store.Users.Create(ctx, &models.User{
+		Email:        req.Email,
+		PasswordHash: string(hash),
+		Name:         req.Name,
+		Role:         "driver",
+		Phone:        req.Phone,
+		UserType:     req.UserType,
+		FleetID:      req.FleetID,
+	})
+	if errors.Is(err, repository.ErrConflict) {
+		respondError(c, http.StatusConflict, "Email already registered")
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		respondError(c, http.StatusInternalServerError, "Failed to create user")
 		return
 	}
 
 	// Link device to this user if provided
 	if strings.TrimSpace(req.DeviceID) != "" {
-		if err := database.UpsertDeviceForUser(req.DeviceID, req.Email, userID); err != nil {
-			log.Printf("⚠️ Failed to link device %s to user %s: %v", req.DeviceID, req.Email, err)
+		if err := s.store.Devices.AssignToUser(ctx, req.DeviceID, req.Email, userID); err != nil {
+			slog.WarnContext(ctx, "Failed to link device to user", "device_id", req.DeviceID, "email", req.Email, "error", err)
 		}
 	}
 
-	token, err := generateJWT(userID, req.Email, "driver")
+	token, err := s.generateJWT(userID, req.Email, "driver")
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create token"})
+		respondError(c, http.StatusInternalServerError, "Failed to create token")
 		return
 	}
 
@@ -749,31 +727,31 @@ func Register(c *gin.Context) {
 }
 
 // Login authenticates a user and returns a JWT
-func Login(c *gin.Context) {
+func (s *Server) Login(c *gin.Context) {
+	ctx := c.Request.Context()
 	var req models.LoginRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+	if !bindJSON(c, &req) {
 		return
 	}
 
-	user, err := database.GetUserByEmail(req.Email)
+	user, err := s.store.Users.GetByEmail(ctx, req.Email)
 	if err != nil {
-		log.Printf("🔐 Login failed: user not found for email=%s: %v", req.Email, err)
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		slog.InfoContext(ctx, "Login failed: user not found", "email", req.Email, "error", err)
+		respondError(c, http.StatusUnauthorized, "Invalid credentials")
 		return
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
-		log.Printf("🔐 Login failed: wrong password for email=%s", req.Email)
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		slog.InfoContext(ctx, "Login failed: wrong password", "email", req.Email)
+		respondError(c, http.StatusUnauthorized, "Invalid credentials")
 		return
 	}
 
-	deviceID, _ := database.GetPrimaryDeviceForUser(user.ID)
+	deviceID := s.currentDevice(ctx, user.ID)
 
-	token, err := generateJWT(user.ID, user.Email, user.Role)
+	token, err := s.generateJWT(user.ID, user.Email, user.Role)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create token"})
+		respondError(c, http.StatusInternalServerError, "Failed to create token")
 		return
 	}
 
@@ -789,123 +767,138 @@ func Login(c *gin.Context) {
 }
 
 // Me returns current authenticated user
-func Me(c *gin.Context) {
+func (s *Server) Me(c *gin.Context) {
+	ctx := c.Request.Context()
 	userIDVal, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		respondError(c, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 	userID := userIDVal.(int)
-	user, err := database.GetUserByID(userID)
+	user, err := s.store.Users.GetByID(ctx, userID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-		return
-	}
-	deviceID, _ := database.GetPrimaryDeviceForUser(user.ID)
-	c.JSON(http.StatusOK, gin.H{
-		"id":        user.ID,
-		"email":     user.Email,
-		"name":      user.Name,
-		"role":      user.Role,
-		"phone":     user.Phone,
-		"user_type": user.UserType,
-		"device_id": deviceID,
+		respondError(c, http.StatusNotFound, "User not found")
+		return
+	}
+	deviceID := s.currentDevice(ctx, user.ID)
+	c.JSON(http.StatusOK, models.Profile{
+		ID:       user.ID,
+		Email:    user.Email,
+		Name:     user.Name,
+		Role:     user.Role,
+		Phone:    user.Phone,
+		UserType: user.UserType,
+		FleetID:  user.FleetID,
+		DeviceID: deviceID,
 	})
 }
 
 // AuthMiddleware validates JWT and sets user in context
-func AuthMiddleware() gin.HandlerFunc {
+func (s *Server) AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		header := c.GetHeader("Authorization")
 		if header == "" || !strings.HasPrefix(header, "Bearer ") {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Missing token"})
+			abortError(c, http.StatusUnauthorized, "Missing token")
 			return
 		}
 		tokenStr := strings.TrimPrefix(header, "Bearer ")
 		token, err := jwt.Parse(tokenStr, func(t *jwt.Token) (interface{}, error) {
-			return []byte(config.AppConfig.JWTSecret), nil
-		})
+			return []byte(s.cfg.JWTSecret), nil
+		}, jwt.WithTimeFunc(s.now))
 		if err != nil || !token.Valid {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			abortError(c, http.StatusUnauthorized, "Invalid token")
 			return
 		}
 		claims, ok := token.Claims.(jwt.MapClaims)
 		if !ok {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid claims"})
+			abortError(c, http.StatusUnauthorized, "Invalid claims")
 			return
 		}
 		uidFloat, ok := claims["user_id"].(float64)
 		if !ok {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid user id"})
+			abortError(c, http.StatusUnauthorized, "Invalid user id")
 			return
 		}
 		c.Set("user_id", int(uidFloat))
+		role, _ := claims["role"].(string)
 		c.Set("user_email", claims["email"])
+		c.Set("user_role", role)
 		c.Next()
 	}
 }
 
+// RequireRole lets through only users authenticated by AuthMiddleware
+// whose token carries one of roles
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("user_role")
+		for _, r := range roles {
+			if role == r {
+				c.Next()
+				return
+			}
+		}
+		abortError(c, http.StatusForbidden, "Insufficient role")
+	}
+}
+
 // generateJWT creates a signed token
-func generateJWT(userID int, email, role string) (string, error) {
+func (s *Server) generateJWT(userID int, email, role string) (string, error) {
+	now := s.now()
 	claims := jwt.MapClaims{
 		"user_id": userID,
 		"email":   email,
 		"role":    role,
-		"exp":     time.Now().Add(24 * time.Hour).Unix(),
-		"iat":     time.Now().Unix(),
+		"exp":     now.Add(24 * time.Hour).Unix(),
+		"iat":     now.Unix(),
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(config.AppConfig.JWTSecret))
-}
-
-// (Optional) helper to convert string id param to int
-func paramIDToInt(c *gin.Context, name string) (int, error) {
-	s := c.Param(name)
-	return strconv.Atoi(s)
+	return token.SignedString([]byte(s.cfg.JWTSecret))
 }
 
 // ================== SEED & PASSWORD RESET HANDLERS ==================
 
 // SeedAdmin creates an admin account (for initial setup)
-func SeedAdmin(c *gin.Context) {
-	var req struct {
-		Email    string `json:"email"`
-		Name     string `json:"name"`
-		Password string `json:"password"`
-		Secret   string `json:"secret"` // Simple protection
-	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+func (s *Server) SeedAdmin(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req models.SeedAdminRequest
+	if !bindJSON(c, &req) {
 		return
 	}
 
 	// Simple secret check to prevent abuse
 	if req.Secret != "drowsiness-admin-setup-2026" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid setup secret"})
+		respondError(c, http.StatusForbidden, "Invalid setup secret")
 		return
 	}
 
 	// Check if admin already exists
-	if _, err := database.GetUserByEmail(req.Email); err == nil {
-		c.JSON(http.StatusConflict, gin.H{"error": "Admin already exists"})
+	if _, err := s.store.Users.GetByEmail(ctx, req.Email); err == nil {
+		respondError(c, http.StatusConflict, "Admin already exists")
 		return
 	}
 
 	// Hash password
 	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		respondError(c, http.StatusInternalServerError, "Failed to hash password")
 		return
 	}
 
 	// Create admin user
-	userID, err := database.CreateUser(req.Email, string(hash), req.Name, "admin", "", "admin")
+	userID, err := s.store.Users.Create(ctx, &models.User{
+		Email:        req.Email,
+		PasswordHash: string(hash),
+		Name:         req.Name,
+		Role:         "admin",
+		UserType:     "admin",
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create admin"})
+		respondError(c, http.StatusInternalServerError, "Failed to create admin")
 		return
 	}
 
-	log.Printf("✅ Admin account created: %s (ID: %d)", req.Email, userID)
+	slog.InfoContext(ctx, "Admin account created", "email", req.Email, "user_id", userID)
 	c.JSON(http.StatusCreated, gin.H{
 		"success": true,
 		"message": "Admin account created successfully",
@@ -914,17 +907,15 @@ func SeedAdmin(c *gin.Context) {
 }
 
 // ForgotPassword initiates password reset
-func ForgotPassword(c *gin.Context) {
-	var req struct {
-		Email string `json:"email"`
-	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+func (s *Server) ForgotPassword(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req models.ForgotPasswordRequest
+	if !bindJSON(c, &req) {
 		return
 	}
 
 	// Check if user exists
-	user, err := database.GetUserByEmail(req.Email)
+	user, err := s.store.Users.GetByEmail(ctx, req.Email)
 	if err != nil {
 		// Don't reveal if email exists for security
 		c.JSON(http.StatusOK, gin.H{
@@ -937,17 +928,16 @@ func ForgotPassword(c *gin.Context) {
 	// Generate 6-digit reset code
 	resetCode := generateResetCode()
 
-	// Store reset code in database (valid for 15 minutes)
-	err = database.StoreResetCode(user.ID, resetCode)
+	// Store reset code (valid for 15 minutes)
+	err = s.store.PasswordResets.Store(ctx, user.ID, resetCode, s.now().UTC().Add(15*time.Minute))
 	if err != nil {
-		log.Printf("❌ Failed to store reset code: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate reset code"})
+		slog.ErrorContext(ctx, "Failed to store reset code", "user_id", user.ID, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to generate reset code")
 		return
 	}
 
-	// In production, send email here
-	// For now, log the code (REMOVE IN PRODUCTION!)
-	log.Printf("🔑 Reset code for %s: %s", req.Email, resetCode)
+	// In production, send email here; the code itself is never logged
+	slog.InfoContext(ctx, "Reset code issued", "email", req.Email, "user_id", user.ID)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success":    true,
@@ -957,42 +947,39 @@ func ForgotPassword(c *gin.Context) {
 }
 
 // ResetPassword completes password reset
-func ResetPassword(c *gin.Context) {
-	var req struct {
-		Email       string `json:"email"`
-		ResetCode   string `json:"reset_code"`
-		NewPassword string `json:"new_password"`
-	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+func (s *Server) ResetPassword(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req models.ResetPasswordRequest
+	if !bindJSON(c, &req) {
 		return
 	}
 
 	// Validate reset code
-	user, err := database.ValidateResetCode(req.Email, req.ResetCode)
+	user, err := s.store.PasswordResets.Validate(ctx, req.Email, req.ResetCode, s.now().UTC())
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset code"})
+		respondError(c, http.StatusBadRequest, "Invalid or expired reset code")
 		return
 	}
 
 	// Hash new password
 	hash, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		respondError(c, http.StatusInternalServerError, "Failed to hash password")
 		return
 	}
 
 	// Update password
-	err = database.UpdateUserPassword(user.ID, string(hash))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
+	if err := s.store.Users.UpdatePassword(ctx, user.ID, string(hash)); err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to update password")
 		return
 	}
 
 	// Clear reset code
-	database.ClearResetCode(user.ID)
+	if err := s.store.PasswordResets.MarkUsed(ctx, user.ID); err != nil {
+		slog.WarnContext(ctx, "Failed to clear reset code", "email", req.Email, "error", err)
+	}
 
-	log.Printf("✅ Password reset successful for %s", req.Email)
+	slog.InfoContext(ctx, "Password reset successful", "email", req.Email)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "Password reset successful. You can now login with your new password.",
@@ -0,0 +1,2716 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"image"
+	"image/jpeg"
+	"io"
+	"log"
+	"log/slog"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"driver-drowsiness-backend/config"
+	"driver-drowsiness-backend/digest"
+	"driver-drowsiness-backend/evidence"
+	"driver-drowsiness-backend/logging"
+	"driver-drowsiness-backend/models"
+	"driver-drowsiness-backend/openapi"
+	"driver-drowsiness-backend/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testClock is 2025-11-09 10:30 Bangkok time
+var testClock = time.Date(2025, 11, 9, 3, 30, 0, 0, time.UTC)
+
+var bangkokZone = time.FixedZone("ICT", 7*3600)
+
+type testEnv struct {
+	t      *testing.T
+	server *Server
+	store  *repository.Store
+	router *gin.Engine
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	store := repository.NewMemoryStore()
+	bangkok, err := time.LoadLocation("Asia/Bangkok")
+	if err != nil {
+		t.Fatalf("load timezone: %v", err)
+	}
+	server := NewServer(store, &config.Config{
+		JWTSecret:          "test-secret",
+		EvidenceSigningKey: "test-evidence-key",
+		Timezone:           "Asia/Bangkok",
+		Location:           bangkok,
+		StorageDir:         t.TempDir(),
+		UploadDir:          t.TempDir(),
+	})
+	server.now = func() time.Time { return testClock }
+	router := gin.New()
+	server.RegisterRoutes(router)
+	return &testEnv{t: t, server: server, store: store, router: router}
+}
+
+// do sends a request with an optional JSON body and bearer token
+func (e *testEnv) do(method, path string, body interface{}, token string) *httptest.ResponseRecorder {
+	e.t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			e.t.Fatalf("encode body: %v", err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	e.router.ServeHTTP(w, req)
+	return w
+}
+
+// decode unmarshals a response body, failing the test on error
+func decode(t *testing.T, w *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("decode %q: %v", w.Body.String(), err)
+	}
+}
+
+// register creates a driver account and returns its token
+func (e *testEnv) register(email, deviceID string) string {
+	e.t.Helper()
+	w := e.do(http.MethodPost, "/api/auth/register", gin.H{
+		"email": email, "password": "secret123", "name": "Driver " + deviceID, "device_id": deviceID,
+	}, "")
+	if w.Code != http.StatusCreated {
+		e.t.Fatalf("register %s: got %d %s", email, w.Code, w.Body.String())
+	}
+	var resp struct {
+		Token string `json:"token"`
+	}
+	decode(e.t, w, &resp)
+	return resp.Token
+}
+
+// admin seeds an admin account and returns its token
+func (e *testEnv) admin(email string) string {
+	e.t.Helper()
+	w := e.do(http.MethodPost, "/api/seed/admin", gin.H{
+		"email": email, "password": "secret123", "name": "Admin", "secret": "drowsiness-admin-setup-2026",
+	}, "")
+	if w.Code != http.StatusCreated {
+		e.t.Fatalf("seed admin %s: got %d %s", email, w.Code, w.Body.String())
+	}
+	w = e.do(http.MethodPost, "/api/auth/login", gin.H{"email": email, "password": "secret123"}, "")
+	if w.Code != http.StatusOK {
+		e.t.Fatalf("login %s: got %d %s", email, w.Code, w.Body.String())
+	}
+	var resp struct {
+		Token string `json:"token"`
+	}
+	decode(e.t, w, &resp)
+	return resp.Token
+}
+
+func TestReceiveDeviceDataAndHistory(t *testing.T) {
+	e := newTestEnv(t)
+
+	for _, level := range []string{"low", "medium", "high"} {
+		w := e.do(http.MethodPost, "/api/devices/device_01/data", gin.H{
+			"eye_closure": 0.5, "drowsiness_level": level, "status": "ok",
+		}, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("post data: got %d %s", w.Code, w.Body.String())
+		}
+	}
+
+	w := e.do(http.MethodGet, "/api/devices/device_01/data", nil, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("latest: got %d", w.Code)
+	}
+	var latest struct {
+		DrowsinessLevel string `json:"drowsiness_level"`
+	}
+	decode(t, w, &latest)
+	if latest.DrowsinessLevel != "high" {
+		t.Errorf("latest level = %q, want high", latest.DrowsinessLevel)
+	}
+
+	w = e.do(http.MethodGet, "/api/devices/device_01/history?limit=2", nil, "")
+	var history struct {
+		Count int `json:"count"`
+	}
+	decode(t, w, &history)
+	if history.Count != 2 {
+		t.Errorf("history count = %d, want 2", history.Count)
+	}
+
+	w = e.do(http.MethodGet, "/api/devices", nil, "")
+	var devices struct {
+		Count int `json:"count"`
+	}
+	decode(t, w, &devices)
+	if devices.Count != 1 {
+		t.Errorf("device count = %d, want 1", devices.Count)
+	}
+}
+
+func TestGetDeviceLatestDataNotFound(t *testing.T) {
+	e := newTestEnv(t)
+	if w := e.do(http.MethodGet, "/api/devices/missing/data", nil, ""); w.Code != http.StatusNotFound {
+		t.Errorf("got %d, want 404", w.Code)
+	}
+}
+
+func TestReceiveAlert(t *testing.T) {
+	e := newTestEnv(t)
+	w := e.do(http.MethodPost, "/api/devices/device_01/alert", gin.H{
+		"alert_type": "drowsiness_detected", "severity": "high",
+	}, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("post alert: got %d %s", w.Code, w.Body.String())
+	}
+
+	w = e.do(http.MethodGet, "/api/devices/device_01/alerts", nil, "")
+	var resp struct {
+		Count  int `json:"count"`
+		Alerts []struct {
+			Severity string `json:"severity"`
+			Status   string `json:"status"`
+		} `json:"alerts"`
+	}
+	decode(t, w, &resp)
+	if resp.Count != 1 || resp.Alerts[0].Severity != "high" || resp.Alerts[0].Status != "active" {
+		t.Errorf("unexpected alerts: %s", w.Body.String())
+	}
+}
+
+func TestRegisterLoginMe(t *testing.T) {
+	e := newTestEnv(t)
+	e.register("driver@example.com", "device_01")
+
+	w := e.do(http.MethodPost, "/api/auth/register", gin.H{
+		"email": "driver@example.com", "password": "secret123",
+	}, "")
+	if w.Code != http.StatusConflict {
+		t.Errorf("duplicate register: got %d, want 409", w.Code)
+	}
+
+	w = e.do(http.MethodPost, "/api/auth/login", gin.H{
+		"email": "driver@example.com", "password": "wrong-pass",
+	}, "")
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("wrong password: got %d, want 401", w.Code)
+	}
+
+	w = e.do(http.MethodPost, "/api/auth/login", gin.H{
+		"email": "driver@example.com", "password": "secret123",
+	}, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("login: got %d %s", w.Code, w.Body.String())
+	}
+	var login struct {
+		Token string `json:"token"`
+		User  struct {
+			DeviceID string `json:"device_id"`
+		} `json:"user"`
+	}
+	decode(t, w, &login)
+	if login.User.DeviceID != "device_01" {
+		t.Errorf("login device_id = %q, want device_01", login.User.DeviceID)
+	}
+
+	w = e.do(http.MethodGet, "/api/auth/me", nil, login.Token)
+	var me struct {
+		Email string `json:"email"`
+	}
+	decode(t, w, &me)
+	if me.Email != "driver@example.com" {
+		t.Errorf("me email = %q", me.Email)
+	}
+
+	if w := e.do(http.MethodGet, "/api/auth/me", nil, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("me without token: got %d, want 401", w.Code)
+	}
+}
+
+func TestForgotAndResetPassword(t *testing.T) {
+	e := newTestEnv(t)
+	e.register("driver@example.com", "")
+
+	w := e.do(http.MethodPost, "/api/auth/forgot-password", gin.H{"email": "driver@example.com"}, "")
+	var forgot struct {
+		ResetCode string `json:"reset_code"`
+	}
+	decode(t, w, &forgot)
+	if forgot.ResetCode == "" {
+		t.Fatalf("no reset code in %s", w.Body.String())
+	}
+
+	reset := gin.H{"email": "driver@example.com", "reset_code": forgot.ResetCode, "new_password": "newpass456"}
+	if w := e.do(http.MethodPost, "/api/auth/reset-password", reset, ""); w.Code != http.StatusOK {
+		t.Fatalf("reset: got %d %s", w.Code, w.Body.String())
+	}
+	if w := e.do(http.MethodPost, "/api/auth/reset-password", reset, ""); w.Code != http.StatusBadRequest {
+		t.Errorf("reused code: got %d, want 400", w.Code)
+	}
+
+	w = e.do(http.MethodPost, "/api/auth/login", gin.H{"email": "driver@example.com", "password": "newpass456"}, "")
+	if w.Code != http.StatusOK {
+		t.Errorf("login with new password: got %d", w.Code)
+	}
+}
+
+func TestAdminEndpoints(t *testing.T) {
+	e := newTestEnv(t)
+	e.register("driver@example.com", "device_01")
+	token := e.admin("admin@example.com")
+
+	if w := e.do(http.MethodGet, "/api/admin/overview", nil, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("overview without token: got %d, want 401", w.Code)
+	}
+
+	for _, level := range []string{"high", "high", "medium", "low"} {
+		e.do(http.MethodPost, "/api/devices/device_01/data", gin.H{
+			"eye_closure": 0.9, "drowsiness_level": level, "status": "drowsy",
+		}, "")
+	}
+
+	w := e.do(http.MethodGet, "/api/admin/overview", nil, token)
+	var overview struct {
+		TotalDrivers  int `json:"total_drivers"`
+		ActiveDrivers int `json:"active_drivers"`
+		TotalDevices  int `json:"total_devices"`
+		AlertsToday   int `json:"alerts_today"`
+	}
+	decode(t, w, &overview)
+	if overview.TotalDrivers != 1 || overview.ActiveDrivers != 1 || overview.TotalDevices != 1 || overview.AlertsToday != 2 {
+		t.Errorf("unexpected overview: %s", w.Body.String())
+	}
+
+	w = e.do(http.MethodGet, "/api/admin/drivers", nil, token)
+	var drivers struct {
+		Drivers []struct {
+			DeviceID            string `json:"device_id"`
+			IsOnline            bool   `json:"is_online"`
+			CriticalAlertsToday int    `json:"critical_alerts_today"`
+		} `json:"drivers"`
+	}
+	decode(t, w, &drivers)
+	if len(drivers.Drivers) != 1 || !drivers.Drivers[0].IsOnline || drivers.Drivers[0].CriticalAlertsToday != 2 {
+		t.Errorf("unexpected drivers: %s", w.Body.String())
+	}
+
+	w = e.do(http.MethodGet, "/api/admin/recent-alerts", nil, token)
+	var recent struct {
+		Alerts []struct {
+			Severity  string `json:"severity"`
+			VehicleID string `json:"vehicleId"`
+		} `json:"alerts"`
+	}
+	decode(t, w, &recent)
+	if len(recent.Alerts) != 3 {
+		t.Errorf("recent alerts = %d, want 3", len(recent.Alerts))
+	}
+	// Without a vehicle, vehicleId is still the device, and there is no plate
+	if strings.Contains(w.Body.String(), "vehiclePlate") || recent.Alerts[0].VehicleID != "device_01" {
+		t.Errorf("recent alerts without vehicles: %s", w.Body.String())
+	}
+
+	w = e.do(http.MethodGet, "/api/admin/alert-slots", nil, token)
+	var slots struct {
+		TotalHigh int    `json:"total_high"`
+		PeakSlot  string `json:"peak_slot"`
+	}
+	decode(t, w, &slots)
+	if slots.TotalHigh != 2 || slots.PeakSlot != "10-12" {
+		t.Errorf("unexpected slots: %s", w.Body.String())
+	}
+
+	w = e.do(http.MethodGet, "/api/admin/alert-levels", nil, token)
+	var levels struct {
+		HighCount   int `json:"high_count"`
+		MediumCount int `json:"medium_count"`
+	}
+	decode(t, w, &levels)
+	if levels.HighCount != 2 || levels.MediumCount != 1 {
+		t.Errorf("unexpected levels: %s", w.Body.String())
+	}
+}
+
+func TestAdminRequiresRole(t *testing.T) {
+	e := newTestEnv(t)
+	driver := e.register("driver@example.com", "device_01")
+	admin := e.admin("admin@example.com")
+
+	// Anyone can register as a driver, so a driver token opens no v2 admin route
+	for _, r := range []struct{ method, path string }{
+		{http.MethodGet, "/api/v2/admin/overview"},
+		{http.MethodPost, "/api/v2/admin/devices/device_01/commands"},
+		{http.MethodDelete, "/api/v2/admin/evidence/1"},
+		{http.MethodDelete, "/api/v2/admin/drivers/1/face"},
+		{http.MethodPost, "/api/v2/admin/subscriptions"},
+		{http.MethodPut, "/api/v2/admin/fleets/1"},
+	} {
+		w := e.do(r.method, r.path, gin.H{}, driver)
+		var body models.ErrorResponse
+		decode(t, w, &body)
+		if w.Code != http.StatusForbidden || body.Error.Code != models.CodeForbidden {
+			t.Errorf("%s %s with a driver token: got %d %s, want 403", r.method, r.path, w.Code, w.Body.String())
+		}
+	}
+	if w := e.do(http.MethodGet, "/api/v2/admin/overview", nil, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("without a token: got %d, want 401", w.Code)
+	}
+	if w := e.do(http.MethodGet, "/api/v2/admin/overview", nil, admin); w.Code != http.StatusOK {
+		t.Errorf("admin token: got %d", w.Code)
+	}
+
+	// The frozen v1 dashboard still takes any valid token
+	for _, path := range []string{"/api/admin/overview", "/api/v1/admin/alert-levels"} {
+		if w := e.do(http.MethodGet, path, nil, driver); w.Code != http.StatusOK {
+			t.Errorf("%s with a driver token: got %d, want 200", path, w.Code)
+		}
+		if w := e.do(http.MethodGet, path, nil, ""); w.Code != http.StatusUnauthorized {
+			t.Errorf("%s without a token: got %d, want 401", path, w.Code)
+		}
+	}
+}
+
+func TestAdminTimezone(t *testing.T) {
+	e := newTestEnv(t)
+	admin := e.admin("admin@example.com")
+
+	w := e.do(http.MethodPost, "/api/v2/admin/fleets", gin.H{"name": "Nope", "timezone": "Mars/Olympus"}, admin)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("invalid timezone: got %d, want 400", w.Code)
+	}
+	w = e.do(http.MethodPost, "/api/v2/admin/fleets", gin.H{"name": "Berlin", "timezone": "Europe/Berlin"}, admin)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create fleet: got %d %s", w.Code, w.Body.String())
+	}
+	var fleet struct {
+		ID int `json:"id"`
+	}
+	decode(t, w, &fleet)
+
+	e.do(http.MethodPost, "/api/auth/register", gin.H{
+		"email": "berlin@example.com", "password": "secret123", "device_id": "device_de", "fleet_id": fleet.ID,
+	}, "")
+	// The manager of the Berlin fleet sees Berlin time by default
+	managerID, err := e.store.Users.Create(context.Background(), &models.User{
+		Email: "manager@example.com", Name: "Manager", Role: "admin", UserType: "admin", FleetID: fleet.ID,
+	})
+	if err != nil {
+		t.Fatalf("create manager: %v", err)
+	}
+	manager, err := e.server.generateJWT(managerID, "manager@example.com", "admin")
+	if err != nil {
+		t.Fatalf("manager token: %v", err)
+	}
+
+	e.do(http.MethodPost, "/api/devices/device_de/data", gin.H{
+		"eye_closure": 0.9, "drowsiness_level": "high", "status": "drowsy",
+	}, "")
+
+	// 03:30 UTC is 04:30 in Berlin (CET) and 10:30 in Bangkok
+	cases := []struct {
+		token, query, time, peak string
+		total                    int
+	}{
+		{manager, "", "04:30", "", 0},
+		{admin, "", "10:30", "10-12", 1},
+		{admin, "?tz=Asia/Tokyo", "12:30", "12-14", 1},
+	}
+	for _, tc := range cases {
+		w = e.do(http.MethodGet, "/api/admin/recent-alerts"+tc.query, nil, tc.token)
+		var recent struct {
+			Alerts []struct {
+				Time string `json:"time"`
+			} `json:"alerts"`
+		}
+		decode(t, w, &recent)
+		if len(recent.Alerts) != 1 || recent.Alerts[0].Time != tc.time {
+			t.Errorf("recent-alerts%s: %s, want time %s", tc.query, w.Body.String(), tc.time)
+		}
+
+		w = e.do(http.MethodGet, "/api/admin/alert-slots"+tc.query, nil, tc.token)
+		var slots struct {
+			TotalHigh int    `json:"total_high"`
+			PeakSlot  string `json:"peak_slot"`
+		}
+		decode(t, w, &slots)
+		if slots.TotalHigh != tc.total || slots.PeakSlot != tc.peak {
+			t.Errorf("alert-slots%s: %s, want peak %q", tc.query, w.Body.String(), tc.peak)
+		}
+	}
+
+	if w := e.do(http.MethodGet, "/api/admin/overview?tz=Nowhere/City", nil, admin); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid tz query: got %d, want 400", w.Code)
+	}
+}
+
+func TestAdminAnalytics(t *testing.T) {
+	e := newTestEnv(t)
+	e.register("driver@example.com", "device_01")
+	token := e.admin("admin@example.com")
+	e.register("other@example.com", "device_02")
+
+	ctx := context.Background()
+	// Bangkok wall times on 2025-11-09, including the small hours
+	for _, s := range []struct {
+		device, level string
+		hour, minute  int
+	}{
+		{"device_01", "high", 1, 5},
+		{"device_01", "high", 1, 20},
+		{"device_01", "medium", 2, 0},
+		{"device_02", "high", 1, 10},
+		{"device_02", "low", 1, 10},
+	} {
+		ts := time.Date(2025, 11, 9, s.hour-7, s.minute, 0, 0, time.UTC)
+		if err := e.store.Drowsiness.Insert(ctx, &models.DrowsinessData{
+			DeviceID: s.device, DrowsinessLevel: s.level, Timestamp: ts,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	type response struct {
+		Buckets []string `json:"buckets"`
+		Series  []struct {
+			Key    string `json:"key"`
+			Total  int    `json:"total"`
+			Points []struct {
+				T     string `json:"t"`
+				Count int    `json:"count"`
+			} `json:"points"`
+		} `json:"series"`
+	}
+
+	w := e.do(http.MethodGet, "/api/v2/admin/analytics/alerts", nil, token)
+	var day response
+	decode(t, w, &day)
+	if len(day.Buckets) != 24 || len(day.Series) != 1 || day.Series[0].Total != 4 {
+		t.Fatalf("default query: %s", w.Body.String())
+	}
+	if p := day.Series[0].Points[1]; p.T != "2025-11-09T01:00:00+07:00" || p.Count != 3 {
+		t.Errorf("01:00 bucket = %+v, want 3", p)
+	}
+
+	w = e.do(http.MethodGet, "/api/v2/admin/analytics/alerts?from=2025-11-09T01:00:00%2B07:00&to=2025-11-09T02:00:00%2B07:00&bucket=15m&group_by=device&level=high", nil, token)
+	var grouped response
+	decode(t, w, &grouped)
+	if len(grouped.Buckets) != 4 || len(grouped.Series) != 2 {
+		t.Fatalf("grouped query: %s", w.Body.String())
+	}
+	if s := grouped.Series[0]; s.Key != "device_01" || s.Total != 2 || s.Points[0].Count != 1 || s.Points[1].Count != 1 {
+		t.Errorf("device_01 series = %+v", s)
+	}
+
+	w = e.do(http.MethodGet, "/api/v2/admin/analytics/alerts?from=2025-11-03&to=2025-11-17&bucket=1w&driver_id=1", nil, token)
+	var weekly response
+	decode(t, w, &weekly)
+	if len(weekly.Buckets) != 2 || weekly.Series[0].Total != 3 || weekly.Series[0].Points[0].Count != 3 {
+		t.Errorf("weekly query: %s", w.Body.String())
+	}
+
+	for _, q := range []string{"bucket=5m", "group_by=route", "level=severe", "from=yesterday", "from=2025-11-10&to=2025-11-09", "from=2020-01-01&bucket=15m"} {
+		if w := e.do(http.MethodGet, "/api/v2/admin/analytics/alerts?"+q, nil, token); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: got %d, want 400", q, w.Code)
+		}
+	}
+}
+
+func TestDrivingSessions(t *testing.T) {
+	e := newTestEnv(t)
+	e.register("driver@example.com", "device_01")
+	token := e.admin("admin@example.com")
+
+	now := testClock
+	e.server.now = func() time.Time { return now }
+	send := func(level string) {
+		t.Helper()
+		w := e.do(http.MethodPost, "/api/devices/device_01/data", gin.H{"eye_closure": 0.5, "drowsiness_level": level}, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("send %s: got %d", level, w.Code)
+		}
+	}
+
+	// First trip: three samples a minute apart, then a long gap
+	for _, level := range []string{"low", "high", "high"} {
+		send(level)
+		now = now.Add(time.Minute)
+	}
+	e.do(http.MethodPost, "/api/devices/device_01/alert", gin.H{"alert_type": "drowsy", "severity": "high",
+		"timestamp": testClock.Add(90 * time.Second).Format(time.RFC3339)}, "")
+	now = now.Add(10 * time.Minute)
+	send("medium")
+
+	if w := e.do(http.MethodPost, "/api/v2/devices/device_01/sessions/stop", nil, ""); w.Code != http.StatusOK {
+		t.Fatalf("stop: got %d %s", w.Code, w.Body.String())
+	}
+	if w := e.do(http.MethodPost, "/api/v2/devices/device_01/sessions/stop", nil, ""); w.Code != http.StatusNotFound {
+		t.Errorf("second stop: got %d, want 404", w.Code)
+	}
+
+	var list struct {
+		Count    int                     `json:"count"`
+		Sessions []models.DrivingSession `json:"sessions"`
+	}
+	w := e.do(http.MethodGet, "/api/v2/admin/sessions?driver_id=1", nil, token)
+	decode(t, w, &list)
+	if list.Count != 2 {
+		t.Fatalf("sessions: %s", w.Body.String())
+	}
+	second, first := list.Sessions[0], list.Sessions[1]
+	if first.EndReason != "gap" || first.SampleCount != 3 || first.HighCount != 2 || first.LongestHighSeconds != 60 ||
+		first.AlertCount != 1 || first.DurationSeconds != 120 || first.Status != "ended" || first.DriverID != 1 {
+		t.Errorf("first session = %+v", first)
+	}
+	if second.EndReason != "stop" || second.SampleCount != 1 || second.MediumCount != 1 {
+		t.Errorf("second session = %+v", second)
+	}
+
+	var detail models.SessionDetail
+	w = e.do(http.MethodGet, "/api/v2/admin/sessions/"+strconv.Itoa(first.ID), nil, token)
+	decode(t, w, &detail)
+	if len(detail.Samples) != 3 || len(detail.Alerts) != 1 {
+		t.Errorf("detail: %s", w.Body.String())
+	}
+
+	w = e.do(http.MethodGet, "/api/v2/devices/device_01/sessions?from=2025-11-09T10:40:00%2B07:00", nil, "")
+	decode(t, w, &list)
+	if list.Count != 1 || list.Sessions[0].ID != second.ID {
+		t.Errorf("device sessions from 10:40: %s", w.Body.String())
+	}
+	if w := e.do(http.MethodGet, "/api/v2/admin/sessions/999", nil, token); w.Code != http.StatusNotFound {
+		t.Errorf("unknown session: got %d, want 404", w.Code)
+	}
+}
+
+func TestFatigueScoring(t *testing.T) {
+	e := newTestEnv(t)
+	e.register("driver@example.com", "device_01")
+	token := e.admin("admin@example.com")
+
+	if w := e.do(http.MethodGet, "/api/v2/devices/device_01/fatigue", nil, ""); w.Code != http.StatusNotFound {
+		t.Errorf("fatigue before data: got %d, want 404", w.Code)
+	}
+
+	// Eyes closed for most of ten seconds, whatever level the device reports
+	now := testClock.Add(-10 * time.Second)
+	e.server.now = func() time.Time { return now }
+	for i := 0; i <= 10; i++ {
+		closure := 0.95
+		if i%4 == 0 {
+			closure = 0.1
+		}
+		e.do(http.MethodPost, "/api/devices/device_01/data", gin.H{"eye_closure": closure, "drowsiness_level": "low"}, "")
+		now = now.Add(time.Second)
+	}
+
+	var live struct {
+		Fatigue models.FatigueMetrics `json:"fatigue"`
+	}
+	decode(t, e.do(http.MethodGet, "/api/v2/devices/device_01/fatigue", nil, ""), &live)
+	if live.Fatigue.Perclos < 0.6 || live.Fatigue.MicrosleepCount != 3 || live.Fatigue.Level != "high" {
+		t.Errorf("live fatigue = %+v", live.Fatigue)
+	}
+
+	var latest models.DrowsinessData
+	decode(t, e.do(http.MethodGet, "/api/devices/device_01/data", nil, ""), &latest)
+	if latest.FatigueScore != live.Fatigue.Score || latest.FatigueLevel != "high" {
+		t.Errorf("latest sample = %+v", latest)
+	}
+
+	var drivers struct {
+		Drivers []models.AdminDriverSummary `json:"drivers"`
+	}
+	decode(t, e.do(http.MethodGet, "/api/admin/drivers", nil, token), &drivers)
+	if len(drivers.Drivers) != 1 || drivers.Drivers[0].FatigueScore == nil || *drivers.Drivers[0].FatigueScore != live.Fatigue.Score {
+		t.Errorf("drivers = %+v", drivers.Drivers)
+	}
+
+	var sessions struct {
+		Sessions []models.DrivingSession `json:"sessions"`
+	}
+	decode(t, e.do(http.MethodGet, "/api/v2/devices/device_01/sessions", nil, ""), &sessions)
+	if len(sessions.Sessions) != 1 || sessions.Sessions[0].MaxFatigueScore == 0 || sessions.Sessions[0].Perclos < 0.6 {
+		t.Errorf("sessions = %+v", sessions.Sessions)
+	}
+
+	// The window of a device silent for longer than the session gap is dropped
+	now = now.Add(e.server.tracker.Gap() + time.Second)
+	e.server.sweepDevices(context.Background())
+	if w := e.do(http.MethodGet, "/api/v2/devices/device_01/fatigue", nil, ""); w.Code != http.StatusNotFound {
+		t.Errorf("fatigue after silence: got %d, want 404", w.Code)
+	}
+}
+
+func TestExtendedTelemetry(t *testing.T) {
+	e := newTestEnv(t)
+
+	w := e.do(http.MethodPost, "/api/devices/device_01/data", gin.H{
+		"schema_version": 2, "eye_closure": 0.4, "drowsiness_level": "medium", "status": "yawning",
+		"mouth":         gin.H{"aspect_ratio": 0.82, "yawning": true, "yawn_count": 1},
+		"head_pose":     gin.H{"pitch": -12.5, "yaw": 30, "roll": 2},
+		"gaze":          gin.H{"off_road_seconds": 1.5},
+		"face_detected": true,
+		"gps":           gin.H{"lat": 13.7563, "lon": 100.5018, "speed_kmh": 62.5, "heading": 270},
+		"extra":         gin.H{"cabin_temp_c": 31},
+	}, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("v2 payload: got %d %s", w.Code, w.Body.String())
+	}
+	// Current firmware keeps sending the version 1 payload
+	if w := e.do(http.MethodPost, "/api/devices/device_01/data", gin.H{"eye_closure": 0.1, "drowsiness_level": "low", "status": "NORMAL"}, ""); w.Code != http.StatusOK {
+		t.Fatalf("v1 payload: got %d", w.Code)
+	}
+
+	var history struct {
+		Data []models.DrowsinessData `json:"data"`
+	}
+	decode(t, e.do(http.MethodGet, "/api/devices/device_01/history", nil, ""), &history)
+	if len(history.Data) != 2 {
+		t.Fatalf("history has %d samples", len(history.Data))
+	}
+	var v1, v2 models.DrowsinessData
+	for _, d := range history.Data {
+		if d.SchemaVersion == 2 {
+			v2 = d
+		} else {
+			v1 = d
+		}
+	}
+	if v1.SchemaVersion != 1 || v1.Telemetry != nil {
+		t.Errorf("v1 sample = %+v", v1)
+	}
+	tel := v2.Telemetry
+	if tel == nil || tel.Mouth == nil || !tel.Mouth.Yawning || tel.HeadPose.Yaw != 30 || tel.Gaze.OffRoadSeconds != 1.5 ||
+		tel.FaceDetected == nil || !*tel.FaceDetected || tel.GPS == nil || *tel.GPS.SpeedKmh != 62.5 || tel.Extra["cabin_temp_c"] != float64(31) {
+		t.Errorf("v2 telemetry = %+v", tel)
+	}
+
+	for name, body := range map[string]gin.H{
+		"future version": {"schema_version": 9, "eye_closure": 0.1},
+		"latitude":       {"schema_version": 2, "gps": gin.H{"lat": 123.0, "lon": 100.0}},
+		"head pose":      {"schema_version": 2, "head_pose": gin.H{"pitch": 400}},
+		"yawn count":     {"schema_version": 2, "mouth": gin.H{"aspect_ratio": 0.5, "yawn_count": -1}},
+		"speed":          {"schema_version": 2, "gps": gin.H{"lat": 13.7, "lon": 100.5, "speed_kmh": -3}},
+	} {
+		if w := e.do(http.MethodPost, "/api/devices/device_01/data", body, ""); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: got %d, want 400", name, w.Code)
+		}
+	}
+}
+
+func TestAlertLocationsAndTrack(t *testing.T) {
+	e := newTestEnv(t)
+	e.register("driver@example.com", "device_01")
+	token := e.admin("admin@example.com")
+	e.register("other@example.com", "device_02")
+
+	now := testClock
+	e.server.now = func() time.Time { return now }
+	// Driving north, drowsy in the middle stretch
+	for i, level := range []string{"low", "low", "high", "high", "low"} {
+		e.do(http.MethodPost, "/api/devices/device_01/data", gin.H{
+			"schema_version": 2, "eye_closure": 0.1, "drowsiness_level": level,
+			"gps": gin.H{"lat": 13.70 + float64(i)*0.001, "lon": 100.50},
+		}, "")
+		if i == 2 {
+			// No GPS in the alert: located at the last fix
+			e.do(http.MethodPost, "/api/devices/device_01/alert", gin.H{"alert_type": "drowsy", "severity": "high"}, "")
+		}
+		now = now.Add(time.Second)
+	}
+	e.do(http.MethodPost, "/api/devices/device_02/alert", gin.H{"alert_type": "drowsy", "severity": "high",
+		"gps": gin.H{"lat": 18.79, "lon": 98.98}}, "")
+	e.do(http.MethodPost, "/api/devices/device_03/alert", gin.H{"alert_type": "drowsy", "severity": "high"}, "")
+	if w := e.do(http.MethodPost, "/api/devices/device_02/alert", gin.H{"alert_type": "drowsy", "gps": gin.H{"lat": 91, "lon": 0}}, ""); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid alert location: got %d, want 400", w.Code)
+	}
+
+	type collection struct {
+		Type     string `json:"type"`
+		Features []struct {
+			Geometry struct {
+				Type        string          `json:"type"`
+				Coordinates json.RawMessage `json:"coordinates"`
+			} `json:"geometry"`
+			Properties map[string]interface{} `json:"properties"`
+		} `json:"features"`
+	}
+
+	var all collection
+	decode(t, e.do(http.MethodGet, "/api/v2/admin/geo/alerts", nil, token), &all)
+	if all.Type != "FeatureCollection" || len(all.Features) != 2 {
+		t.Fatalf("all located alerts = %+v", all)
+	}
+	var mine collection
+	decode(t, e.do(http.MethodGet, "/api/v2/admin/geo/alerts?driver_id=1", nil, token), &mine)
+	if len(mine.Features) != 1 || string(mine.Features[0].Geometry.Coordinates) != "[100.5,13.702]" {
+		t.Errorf("driver 1 alerts = %+v", mine)
+	}
+	if w := e.do(http.MethodGet, "/api/v2/admin/geo/alerts?from=2025-11-01", nil, token); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("purged range: got %d, want 422", w.Code)
+	}
+
+	var sessions struct {
+		Sessions []models.DrivingSession `json:"sessions"`
+	}
+	decode(t, e.do(http.MethodGet, "/api/v2/devices/device_01/sessions", nil, ""), &sessions)
+	trackPath := "/api/v2/admin/sessions/" + strconv.Itoa(sessions.Sessions[0].ID) + "/track"
+
+	var track collection
+	w := e.do(http.MethodGet, trackPath+"?tolerance=5", nil, token)
+	decode(t, w, &track)
+	var levels []string
+	alerts := 0
+	for _, f := range track.Features {
+		switch f.Properties["kind"] {
+		case "segment":
+			levels = append(levels, f.Properties["level"].(string))
+		case "alert":
+			alerts++
+		}
+	}
+	if got := strings.Join(levels, ","); got != "low,high,low" || alerts != 1 {
+		t.Errorf("track segments %s, %d alerts: %s", got, alerts, w.Body.String())
+	}
+	if w := e.do(http.MethodGet, trackPath+"?tolerance=-1", nil, token); w.Code != http.StatusBadRequest {
+		t.Errorf("negative tolerance: got %d, want 400", w.Code)
+	}
+}
+
+func TestGeofences(t *testing.T) {
+	e := newTestEnv(t)
+	e.register("driver@example.com", "device_01")
+	token := e.admin("admin@example.com")
+
+	highway := gin.H{
+		"name": "Highway 1", "kind": "highway",
+		"polygon": [][2]float64{{100.0, 13.0}, {101.0, 13.0}, {101.0, 13.1}, {100.0, 13.1}, {100.0, 13.0}},
+		"rules":   []gin.H{{"level": "high", "escalate": true}},
+	}
+	w := e.do(http.MethodPost, "/api/v2/admin/geofences", highway, token)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: got %d %s", w.Code, w.Body.String())
+	}
+	var created models.Geofence
+	decode(t, w, &created)
+	path := "/api/v2/admin/geofences/" + strconv.Itoa(created.ID)
+
+	for name, body := range map[string]gin.H{
+		"too few points": {"name": "x", "polygon": [][2]float64{{100, 13}, {101, 13}, {100, 13}}},
+		"lat range":      {"name": "x", "polygon": [][2]float64{{100, 13}, {101, 95}, {100, 14}}},
+		"rule level":     {"name": "x", "polygon": highway["polygon"], "rules": []gin.H{{"level": "severe", "escalate": true}}},
+		"empty rule":     {"name": "x", "polygon": highway["polygon"], "rules": []gin.H{{"level": "high"}}},
+	} {
+		if w := e.do(http.MethodPost, "/api/v2/admin/geofences", body, token); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: got %d, want 400", name, w.Code)
+		}
+	}
+
+	now := testClock
+	e.server.now = func() time.Time { return now }
+	for _, s := range []struct {
+		lat   float64
+		level string
+	}{{12.9, "high"}, {13.05, "medium"}, {13.06, "high"}, {13.07, "high"}, {12.95, "low"}} {
+		e.do(http.MethodPost, "/api/devices/device_01/data", gin.H{
+			"schema_version": 2, "eye_closure": 0.2, "drowsiness_level": s.level, "gps": gin.H{"lat": s.lat, "lon": 100.5},
+		}, "")
+		now = now.Add(time.Second)
+	}
+
+	var events struct {
+		Events []models.GeofenceEvent `json:"events"`
+	}
+	decode(t, e.do(http.MethodGet, "/api/v2/admin/geofences/events?device_id=device_01", nil, token), &events)
+	if len(events.Events) != 2 || events.Events[0].Event != "exit" || events.Events[1].Event != "enter" {
+		t.Errorf("events = %+v", events.Events)
+	}
+
+	var alerts struct {
+		Alerts []models.Alert `json:"alerts"`
+	}
+	decode(t, e.do(http.MethodGet, "/api/devices/device_01/alerts", nil, ""), &alerts)
+	if len(alerts.Alerts) != 1 || alerts.Alerts[0].AlertType != "geofence_escalation" ||
+		alerts.Alerts[0].Severity != "critical" {
+		t.Errorf("escalation alerts = %+v", alerts.Alerts)
+	}
+
+	// Rules also raise the severity of alerts the device raises itself
+	highway["rules"] = []gin.H{{"level": "medium", "severity": "high"}}
+	if w := e.do(http.MethodPut, path, highway, token); w.Code != http.StatusOK {
+		t.Fatalf("update: got %d %s", w.Code, w.Body.String())
+	}
+	e.do(http.MethodPost, "/api/devices/device_01/alert", gin.H{"alert_type": "drowsy", "severity": "medium",
+		"gps": gin.H{"lat": 13.05, "lon": 100.2}}, "")
+	decode(t, e.do(http.MethodGet, "/api/devices/device_01/alerts", nil, ""), &alerts)
+	if a := alerts.Alerts[0]; a.Severity != "high" || a.GeofenceID != created.ID {
+		t.Errorf("raised alert = %+v", a)
+	}
+
+	if w := e.do(http.MethodDelete, path, nil, token); w.Code != http.StatusOK {
+		t.Errorf("delete: got %d", w.Code)
+	}
+	if w := e.do(http.MethodGet, path, nil, token); w.Code != http.StatusNotFound {
+		t.Errorf("get deleted: got %d, want 404", w.Code)
+	}
+}
+
+func TestVehicles(t *testing.T) {
+	e := newTestEnv(t)
+	e.register("driver@example.com", "device_01")
+	token := e.admin("admin@example.com")
+
+	create := func(plate string) models.Vehicle {
+		w := e.do(http.MethodPost, "/api/v2/admin/vehicles", gin.H{"plate_number": plate, "type": "bus", "capacity": 40}, token)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("create %s: got %d %s", plate, w.Code, w.Body.String())
+		}
+		var v models.Vehicle
+		decode(t, w, &v)
+		return v
+	}
+	bus, van := create("1กข-1234"), create("2คง-5678")
+	if w := e.do(http.MethodPost, "/api/v2/admin/vehicles", gin.H{"plate_number": bus.PlateNumber}, token); w.Code != http.StatusConflict {
+		t.Errorf("duplicate plate: got %d, want 409", w.Code)
+	}
+
+	now := testClock
+	e.server.now = func() time.Time { return now }
+	install := func(v models.Vehicle, at string) *httptest.ResponseRecorder {
+		return e.do(http.MethodPost, "/api/v2/admin/vehicles/"+strconv.Itoa(v.ID)+"/devices",
+			gin.H{"device_id": "device_01", "installed_at": at}, token)
+	}
+	if w := install(bus, "2025-11-09T02:00:00Z"); w.Code != http.StatusCreated {
+		t.Fatalf("install: got %d %s", w.Code, w.Body.String())
+	}
+	e.do(http.MethodPost, "/api/devices/device_01/data", gin.H{"drowsiness_level": "high"}, "")
+	e.do(http.MethodPost, "/api/devices/device_01/alert", gin.H{"alert_type": "drowsy", "severity": "high"}, "")
+
+	// Moving the device ends the bus installation; earlier alerts keep the bus
+	now = now.Add(time.Hour)
+	if w := install(van, "2025-11-09T01:00:00Z"); w.Code != http.StatusConflict {
+		t.Errorf("install before current: got %d, want 409", w.Code)
+	}
+	if w := install(van, now.Format(time.RFC3339)); w.Code != http.StatusCreated {
+		t.Fatalf("move: got %d %s", w.Code, w.Body.String())
+	}
+	e.do(http.MethodPost, "/api/devices/device_01/data", gin.H{"drowsiness_level": "medium"}, "")
+	e.do(http.MethodPost, "/api/devices/device_01/alert", gin.H{"alert_type": "drowsy", "severity": "medium"}, "")
+
+	var alerts struct {
+		Alerts []models.Alert `json:"alerts"`
+	}
+	decode(t, e.do(http.MethodGet, "/api/devices/device_01/alerts", nil, ""), &alerts)
+	if len(alerts.Alerts) != 2 || alerts.Alerts[0].VehicleID != van.ID || alerts.Alerts[1].VehicleID != bus.ID {
+		t.Errorf("alert vehicles = %+v", alerts.Alerts)
+	}
+
+	var recent struct {
+		Alerts []models.AdminRecentAlert `json:"alerts"`
+	}
+	decode(t, e.do(http.MethodGet, "/api/admin/recent-alerts", nil, token), &recent)
+	if len(recent.Alerts) != 2 || recent.Alerts[0].VehiclePlate != van.PlateNumber ||
+		recent.Alerts[1].VehiclePlate != bus.PlateNumber || recent.Alerts[0].VehicleID != "device_01" {
+		t.Errorf("recent alerts = %+v", recent.Alerts)
+	}
+
+	var sessions struct {
+		Sessions []models.DrivingSession `json:"sessions"`
+	}
+	decode(t, e.do(http.MethodGet, "/api/v2/devices/device_01/sessions", nil, ""), &sessions)
+	if len(sessions.Sessions) != 2 || sessions.Sessions[0].VehicleID != van.ID || sessions.Sessions[1].VehicleID != bus.ID {
+		t.Errorf("session vehicles = %+v", sessions.Sessions)
+	}
+
+	var analytics struct {
+		Series []struct {
+			Label string `json:"label"`
+		} `json:"series"`
+	}
+	decode(t, e.do(http.MethodGet, "/api/v2/admin/analytics/alerts?group_by=vehicle&bucket=1d", nil, token), &analytics)
+	labels := map[string]bool{}
+	for _, series := range analytics.Series {
+		labels[series.Label] = true
+	}
+	if len(labels) != 2 || !labels[bus.PlateNumber] || !labels[van.PlateNumber] {
+		t.Errorf("vehicle series = %+v", analytics.Series)
+	}
+
+	var history struct {
+		Installations []models.DeviceInstallation `json:"installations"`
+	}
+	decode(t, e.do(http.MethodGet, "/api/v2/admin/installations?device_id=device_01", nil, token), &history)
+	if len(history.Installations) != 2 || history.Installations[0].RemovedAt != nil ||
+		history.Installations[1].RemovedAt == nil || !history.Installations[1].RemovedAt.Equal(now) {
+		t.Errorf("history = %+v", history.Installations)
+	}
+
+	var got models.Vehicle
+	decode(t, e.do(http.MethodGet, "/api/v2/admin/vehicles/"+strconv.Itoa(van.ID), nil, token), &got)
+	if got.DeviceID != "device_01" {
+		t.Errorf("installed device = %q", got.DeviceID)
+	}
+	if w := e.do(http.MethodDelete, "/api/v2/admin/vehicles/"+strconv.Itoa(bus.ID)+"/devices/device_01", nil, token); w.Code != http.StatusNotFound {
+		t.Errorf("remove from old vehicle: got %d, want 404", w.Code)
+	}
+	if w := e.do(http.MethodDelete, "/api/v2/admin/vehicles/"+strconv.Itoa(van.ID)+"/devices/device_01", nil, token); w.Code != http.StatusOK {
+		t.Errorf("remove: got %d", w.Code)
+	}
+}
+
+func TestShifts(t *testing.T) {
+	e := newTestEnv(t)
+	ownerToken := e.register("owner@example.com", "device_01")
+	token := e.register("relief@example.com", "device_02")
+	userID := func(token string) int {
+		var me struct {
+			ID int `json:"id"`
+		}
+		decode(t, e.do(http.MethodGet, "/api/auth/me", nil, token), &me)
+		return me.ID
+	}
+	owner, relief := userID(ownerToken), userID(token)
+	admin := e.admin("admin@example.com")
+
+	var truck models.Vehicle
+	decode(t, e.do(http.MethodPost, "/api/v2/admin/vehicles", gin.H{"plate_number": "70-1234"}, admin), &truck)
+	e.do(http.MethodPost, "/api/v2/admin/vehicles/"+strconv.Itoa(truck.ID)+"/devices", gin.H{"device_id": "device_01"}, admin)
+
+	// The relief driver takes the owner's truck for the morning
+	shift := gin.H{"driver_id": relief, "vehicle_id": truck.ID, "starts_at": "2025-11-09T09:00:00+07:00", "ends_at": "2025-11-09T12:00:00+07:00"}
+	w := e.do(http.MethodPost, "/api/v2/admin/shifts", shift, admin)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: got %d %s", w.Code, w.Body.String())
+	}
+	var created models.Shift
+	decode(t, w, &created)
+	if created.DeviceID != "device_01" || created.Status != "active" {
+		t.Errorf("created = %+v", created)
+	}
+
+	for name, body := range map[string]gin.H{
+		"unknown driver":  {"driver_id": 9999, "vehicle_id": truck.ID, "starts_at": "2025-11-10"},
+		"unknown vehicle": {"driver_id": relief, "vehicle_id": 9999, "starts_at": "2025-11-10"},
+		"ends before":     {"driver_id": relief, "vehicle_id": truck.ID, "starts_at": "2025-11-10", "ends_at": "2025-11-09"},
+	} {
+		if w := e.do(http.MethodPost, "/api/v2/admin/shifts", body, admin); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: got %d, want 400", name, w.Code)
+		}
+	}
+	overlap := gin.H{"driver_id": owner, "vehicle_id": truck.ID, "starts_at": "2025-11-09T11:00:00+07:00"}
+	if w := e.do(http.MethodPost, "/api/v2/admin/shifts", overlap, admin); w.Code != http.StatusConflict {
+		t.Errorf("overlapping device: got %d, want 409", w.Code)
+	}
+
+	now := testClock
+	e.server.now = func() time.Time { return now }
+	e.do(http.MethodPost, "/api/devices/device_01/data", gin.H{"drowsiness_level": "high"}, "")
+
+	var history struct {
+		Data []models.DrowsinessData `json:"data"`
+	}
+	decode(t, e.do(http.MethodGet, "/api/devices/device_01/history", nil, ""), &history)
+	if len(history.Data) != 1 || history.Data[0].DriverID != relief {
+		t.Errorf("sample driver = %+v", history.Data)
+	}
+	var me struct {
+		DeviceID string `json:"device_id"`
+	}
+	decode(t, e.do(http.MethodGet, "/api/auth/me", nil, token), &me)
+	if me.DeviceID != "device_01" {
+		t.Errorf("device on shift = %q", me.DeviceID)
+	}
+	var drivers struct {
+		Drivers []models.AdminDriverSummary `json:"drivers"`
+	}
+	decode(t, e.do(http.MethodGet, "/api/admin/drivers", nil, admin), &drivers)
+	for _, d := range drivers.Drivers {
+		want := map[string]int{strconv.Itoa(owner): 0, strconv.Itoa(relief): 1}[d.ID]
+		if d.CriticalAlertsToday != want {
+			t.Errorf("driver %s critical = %d, want %d", d.ID, d.CriticalAlertsToday, want)
+		}
+	}
+
+	// Ending the shift hands the truck back to its owner mid-drive
+	now = now.Add(time.Minute)
+	w = e.do(http.MethodPost, "/api/v2/admin/shifts/"+strconv.Itoa(created.ID)+"/end", nil, admin)
+	if w.Code != http.StatusOK {
+		t.Fatalf("end: got %d %s", w.Code, w.Body.String())
+	}
+	now = now.Add(time.Second)
+	e.do(http.MethodPost, "/api/devices/device_01/data", gin.H{"drowsiness_level": "low"}, "")
+
+	var sessions struct {
+		Sessions []models.DrivingSession `json:"sessions"`
+	}
+	decode(t, e.do(http.MethodGet, "/api/v2/devices/device_01/sessions", nil, ""), &sessions)
+	if len(sessions.Sessions) != 2 || sessions.Sessions[0].DriverID != owner ||
+		sessions.Sessions[1].DriverID != relief || sessions.Sessions[1].EndReason != "handover" {
+		t.Errorf("sessions = %+v", sessions.Sessions)
+	}
+	if w := e.do(http.MethodPost, "/api/v2/admin/shifts/"+strconv.Itoa(created.ID)+"/end", nil, admin); w.Code != http.StatusConflict {
+		t.Errorf("end twice: got %d, want 409", w.Code)
+	}
+
+	var list struct {
+		Shifts []models.Shift `json:"shifts"`
+	}
+	decode(t, e.do(http.MethodGet, "/api/v2/admin/shifts?driver_id="+strconv.Itoa(relief), nil, admin), &list)
+	if len(list.Shifts) != 1 || list.Shifts[0].Status != "ended" {
+		t.Errorf("shifts = %+v", list.Shifts)
+	}
+}
+
+func TestDrivingCompliance(t *testing.T) {
+	e := newTestEnv(t)
+	e.register("driver@example.com", "device_01")
+	token := e.admin("admin@example.com")
+
+	now := time.Date(2025, 11, 9, 0, 0, 0, 0, time.UTC) // 07:00 Bangkok
+	e.server.now = func() time.Time { return now }
+	var last struct {
+		Compliance models.ComplianceStatus `json:"compliance"`
+	}
+	for i := 0; i <= 243; i++ {
+		decode(t, e.do(http.MethodPost, "/api/devices/device_01/data", gin.H{"drowsiness_level": "low"}, ""), &last)
+		if i < 243 {
+			now = now.Add(time.Minute)
+		}
+	}
+	if last.Compliance.State != models.ComplianceViolation || last.Compliance.ContinuousSeconds != 243*60 {
+		t.Errorf("compliance in ingest response = %+v", last.Compliance)
+	}
+
+	var alerts struct {
+		Alerts []models.Alert `json:"alerts"`
+	}
+	decode(t, e.do(http.MethodGet, "/api/devices/device_01/alerts", nil, ""), &alerts)
+	if len(alerts.Alerts) != 2 || alerts.Alerts[0].AlertType != "continuous_driving_exceeded" ||
+		alerts.Alerts[1].AlertType != "break_due" || alerts.Alerts[1].Severity != "warning" {
+		t.Errorf("alerts = %+v", alerts.Alerts)
+	}
+
+	// After a 30-minute break the driver is back within limits
+	now = now.Add(30 * time.Minute)
+	var status models.ComplianceStatus
+	decode(t, e.do(http.MethodGet, "/api/v2/devices/device_01/compliance", nil, ""), &status)
+	if status.State != models.ComplianceOK || status.DailySeconds != 243*60 {
+		t.Errorf("status after break = %+v", status)
+	}
+
+	var report struct {
+		Drivers []models.DriverCompliance `json:"drivers"`
+		Fleets  []models.FleetCompliance  `json:"fleets"`
+	}
+	decode(t, e.do(http.MethodGet, "/api/v2/admin/compliance?from=2025-11-09", nil, token), &report)
+	if len(report.Drivers) != 1 || len(report.Drivers[0].Violations) != 1 || report.Drivers[0].Compliant ||
+		report.Drivers[0].Days[0].Date != "2025-11-09" {
+		t.Errorf("driver report = %+v", report.Drivers)
+	}
+	if len(report.Fleets) != 1 || report.Fleets[0].Violations != 1 || report.Fleets[0].CompliantDrivers != 0 {
+		t.Errorf("fleet report = %+v", report.Fleets)
+	}
+	if w := e.do(http.MethodGet, "/api/v2/devices/unknown/compliance", nil, ""); w.Code != http.StatusNotFound {
+		t.Errorf("unknown device: got %d, want 404", w.Code)
+	}
+}
+
+func TestDailyPurge(t *testing.T) {
+	e := newTestEnv(t)
+	admin := e.admin("admin@example.com")
+	var fleet models.Fleet
+	decode(t, e.do(http.MethodPost, "/api/v2/admin/fleets", gin.H{"name": "Berlin", "timezone": "Europe/Berlin"}, admin), &fleet)
+	e.do(http.MethodPost, "/api/auth/register", gin.H{
+		"email": "berlin@example.com", "password": "secret123", "device_id": "device_de", "fleet_id": fleet.ID,
+	}, "")
+	e.register("driver@example.com", "device_th")
+
+	now := time.Date(2025, 11, 7, 20, 0, 0, 0, time.UTC) // 21:00 in Berlin, 03:00 in Bangkok
+	e.server.now = func() time.Time { return now }
+	for _, at := range []time.Time{now, now.Add(14 * time.Hour)} { // and 11:00 in Berlin, 17:00 in Bangkok
+		now = at
+		for _, device := range []string{"device_de", "device_th"} {
+			e.do(http.MethodPost, "/api/devices/"+device+"/data", gin.H{"eye_closure": 0.8, "drowsiness_level": "high"}, "")
+			e.do(http.MethodPost, "/api/devices/"+device+"/alert", gin.H{"alert_type": "drowsiness", "severity": "high"}, "")
+		}
+	}
+
+	// Bangkok midnight is 18:05 in Berlin: Berlin keeps the whole of its day
+	now = time.Date(2025, 11, 8, 17, 5, 0, 0, time.UTC)
+	if err := e.server.PurgeOldData(); err != nil {
+		t.Fatal(err)
+	}
+	all := repository.TimeRange{To: now}
+	for device, want := range map[string]int{"device_de": 1, "device_th": 0} {
+		samples, _ := e.store.Drowsiness.Between(context.Background(), device, all)
+		alerts, _ := e.store.Alerts.Between(context.Background(), device, all)
+		if len(samples) != want || len(alerts) != want {
+			t.Errorf("%s kept %d samples and %d alerts, want %d", device, len(samples), len(alerts), want)
+		}
+	}
+	// Purged samples are still counted per hour for reports
+	counts, err := e.store.Reports.HourlyCounts(context.Background(), repository.ExportFilter{Range: all})
+	if err != nil {
+		t.Fatal(err)
+	}
+	high := 0
+	for _, c := range counts {
+		high += c.High
+	}
+	if high != 4 {
+		t.Errorf("hourly high counts = %d, want 4 (3 rolled up, 1 live)", high)
+	}
+
+	// The purge also runs at Berlin midnight, which ends the Berlin day
+	var zones []string
+	for _, loc := range e.server.PurgeLocations() {
+		zones = append(zones, loc.String())
+	}
+	if strings.Join(zones, ",") != "Asia/Bangkok,Europe/Berlin" {
+		t.Errorf("purge locations = %v", zones)
+	}
+	now = time.Date(2025, 11, 8, 23, 0, 5, 0, time.UTC)
+	if err := e.server.PurgeOldData(); err != nil {
+		t.Fatal(err)
+	}
+	if samples, _ := e.store.Drowsiness.Between(context.Background(), "device_de", all); len(samples) != 0 {
+		t.Errorf("Berlin midnight purge kept %d samples", len(samples))
+	}
+}
+
+func TestAnalyticsAfterPurge(t *testing.T) {
+	e := newTestEnv(t)
+	e.register("driver@example.com", "device_01")
+	token := e.admin("admin@example.com")
+
+	// Bangkok wall times on 2025-11-08, purged at 10:30 on the 9th
+	now := time.Date(2025, 11, 8, 1, 0, 0, 0, time.UTC)
+	e.server.now = func() time.Time { return now }
+	for i, level := range []string{"high", "high", "medium", "low", "high"} {
+		now = time.Date(2025, 11, 8, 1+i/2, 10*i, 0, 0, time.UTC)
+		e.do(http.MethodPost, "/api/devices/device_01/data", gin.H{"eye_closure": 0.8, "drowsiness_level": level}, "")
+	}
+
+	query := func() string {
+		t.Helper()
+		w := e.do(http.MethodGet, "/api/v2/admin/analytics/alerts?from=2025-11-08&to=2025-11-09&group_by=driver&level=low,medium,high", nil, token)
+		if w.Code != http.StatusOK {
+			t.Fatalf("analytics: got %d: %s", w.Code, w.Body.String())
+		}
+		return w.Body.String()
+	}
+	before := query()
+	if !strings.Contains(before, `"total":5`) {
+		t.Fatalf("before purge: %s", before)
+	}
+	now = testClock
+	if err := e.server.PurgeOldData(); err != nil {
+		t.Fatal(err)
+	}
+	if samples, _ := e.store.Drowsiness.Between(context.Background(), "device_01", repository.TimeRange{To: now}); len(samples) != 0 {
+		t.Fatalf("purge kept %d samples", len(samples))
+	}
+	// Hour buckets come out the same from the rollup
+	if after := query(); after != before {
+		t.Errorf("after purge:\n%s\nwant\n%s", after, before)
+	}
+}
+
+func TestDeviceHeartbeat(t *testing.T) {
+	e := newTestEnv(t)
+	e.register("driver@example.com", "device_01")
+	token := e.admin("admin@example.com")
+
+	start := testClock
+	now := start
+	e.server.now = func() time.Time { return now }
+	beats := []gin.H{
+		{"cpu_temp_c": 55.0, "camera_status": "ok", "fps": 24.0, "app_version": "1.4.0"},
+		{"cpu_temp_c": 85.0, "camera_status": "ok", "fps": 24.0, "app_version": "1.4.0"},
+		{"cpu_temp_c": 60.0, "camera_status": "blocked", "fps": 24.0, "app_version": "1.4.0"},
+		{"cpu_temp_c": 60.0, "camera_status": "ok", "fps": 24.0, "app_version": "1.4.0"},
+	}
+	wantStates := []string{models.DeviceOnline, models.DeviceDegraded, models.DeviceCameraBlocked, models.DeviceOnline}
+	for i, beat := range beats {
+		now = start.Add(time.Duration(i*30) * time.Second)
+		var resp struct {
+			Health models.DeviceHealth `json:"health"`
+		}
+		decode(t, e.do(http.MethodPost, "/api/v2/devices/device_01/heartbeat", beat, ""), &resp)
+		if resp.Health.State != wantStates[i] || !resp.Health.StateSince.Equal(now) {
+			t.Errorf("heartbeat %d: health = %+v", i, resp.Health)
+		}
+	}
+	if w := e.do(http.MethodPost, "/api/v2/devices/device_01/heartbeat", gin.H{"camera_status": "foggy"}, ""); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid camera_status: got %d, want 400", w.Code)
+	}
+
+	type driverRow struct {
+		IsOnline    bool   `json:"is_online"`
+		DeviceState string `json:"device_state"`
+	}
+	var drivers struct {
+		Drivers []driverRow `json:"drivers"`
+	}
+	decode(t, e.do(http.MethodGet, "/api/admin/drivers", nil, token), &drivers)
+	if len(drivers.Drivers) != 1 || !drivers.Drivers[0].IsOnline || drivers.Drivers[0].DeviceState != models.DeviceOnline {
+		t.Errorf("drivers while online = %+v", drivers.Drivers)
+	}
+
+	// Heartbeats stop at +90s; data alone no longer keeps the device online
+	now = start.Add(190 * time.Second)
+	e.do(http.MethodPost, "/api/devices/device_01/data", gin.H{"drowsiness_level": "low"}, "")
+	decode(t, e.do(http.MethodGet, "/api/admin/drivers", nil, token), &drivers)
+	if drivers.Drivers[0].IsOnline || drivers.Drivers[0].DeviceState != models.DeviceOffline {
+		t.Errorf("drivers after timeout = %+v", drivers.Drivers)
+	}
+	var overview struct {
+		ActiveDrivers int `json:"active_drivers"`
+	}
+	decode(t, e.do(http.MethodGet, "/api/admin/overview", nil, token), &overview)
+	if overview.ActiveDrivers != 0 {
+		t.Errorf("active_drivers = %d, want 0", overview.ActiveDrivers)
+	}
+
+	var h models.DeviceHealth
+	decode(t, e.do(http.MethodGet, "/api/v2/devices/device_01/health", nil, ""), &h)
+	if h.State != models.DeviceOffline || !h.StateSince.Equal(start.Add(180*time.Second)) || h.AppVersion != "1.4.0" {
+		t.Errorf("health = %+v", h)
+	}
+
+	var events struct {
+		Events []models.DeviceStateEvent `json:"events"`
+	}
+	decode(t, e.do(http.MethodGet, "/api/v2/admin/device-events?device_id=device_01", nil, token), &events)
+	if len(events.Events) != 5 || events.Events[0].FromState != "" ||
+		events.Events[4].FromState != models.DeviceOnline || events.Events[4].ToState != models.DeviceOffline {
+		t.Errorf("events = %+v", events.Events)
+	}
+
+	var uptime models.DeviceUptime
+	decode(t, e.do(http.MethodGet, "/api/v2/admin/devices/device_01/uptime?from="+start.Format(time.RFC3339), nil, token), &uptime)
+	want := map[string]float64{
+		models.DeviceOnline: 120, models.DeviceDegraded: 30, models.DeviceCameraBlocked: 30, models.DeviceOffline: 10,
+	}
+	for state, secs := range want {
+		if uptime.Seconds[state] != secs {
+			t.Errorf("uptime %s = %v s, want %v", state, uptime.Seconds[state], secs)
+		}
+	}
+	if len(uptime.Intervals) != 5 || math.Abs(uptime.UptimePercent-180.0/190*100) > 1e-9 {
+		t.Errorf("uptime = %+v", uptime)
+	}
+
+	if w := e.do(http.MethodGet, "/api/v2/devices/unknown/health", nil, ""); w.Code != http.StatusNotFound {
+		t.Errorf("unknown device: got %d, want 404", w.Code)
+	}
+}
+
+func TestDeviceConfigShadow(t *testing.T) {
+	e := newTestEnv(t)
+	e.register("driver@example.com", "device_01")
+	token := e.admin("admin@example.com")
+	e.register("driver2@example.com", "device_02")
+
+	var fetched struct {
+		Version int                 `json:"version"`
+		Config  models.DeviceConfig `json:"config"`
+	}
+	decode(t, e.do(http.MethodGet, "/api/v2/devices/device_01/config", nil, ""), &fetched)
+	if fetched.Version != 0 || fetched.Config != models.DefaultDeviceConfig() {
+		t.Errorf("default config = %+v", fetched)
+	}
+
+	var shadow models.DeviceShadow
+	w := e.do(http.MethodPut, "/api/v2/admin/devices/device_01/config", gin.H{"ear_threshold": 0.22, "alarm_volume": 60}, token)
+	decode(t, w, &shadow)
+	if w.Code != http.StatusOK || shadow.Version != 1 || shadow.Desired.EARThreshold != 0.22 ||
+		shadow.Desired.ConsecutiveFrames != 20 || shadow.Sync != models.ShadowNeverReported {
+		t.Errorf("update config: %d %+v", w.Code, shadow)
+	}
+	if w := e.do(http.MethodPut, "/api/v2/admin/devices/device_01/config", gin.H{"alarm_volume": 150}, token); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid volume: got %d, want 400", w.Code)
+	}
+	if w := e.do(http.MethodPut, "/api/v2/admin/devices/unknown/config", gin.H{"alarm_volume": 50}, token); w.Code != http.StatusNotFound {
+		t.Errorf("unknown device: got %d, want 404", w.Code)
+	}
+
+	// A device on an older version receives the desired config on heartbeat
+	var beat struct {
+		ConfigVersion int                  `json:"config_version"`
+		Config        *models.DeviceConfig `json:"config"`
+	}
+	decode(t, e.do(http.MethodPost, "/api/v2/devices/device_01/heartbeat", gin.H{"config_version": 0}, ""), &beat)
+	if beat.ConfigVersion != 1 || beat.Config == nil || beat.Config.AlarmVolume != 60 {
+		t.Errorf("heartbeat config = %+v", beat)
+	}
+
+	// The device clamps the volume it applied
+	applied := shadow.Desired
+	applied.AlarmVolume = 50
+	decode(t, e.do(http.MethodPost, "/api/v2/devices/device_01/config/reported", gin.H{"version": 1, "config": applied}, ""), &shadow)
+	if shadow.Sync != models.ShadowDrift || len(shadow.Drift) != 1 || shadow.Drift[0].Field != "alarm_volume" {
+		t.Errorf("reported shadow = %+v", shadow)
+	}
+	if w := e.do(http.MethodPost, "/api/v2/devices/device_01/config/reported", gin.H{"version": 2, "config": applied}, ""); w.Code != http.StatusBadRequest {
+		t.Errorf("future version: got %d, want 400", w.Code)
+	}
+
+	var fleet struct {
+		Count   int                   `json:"count"`
+		Summary map[string]int        `json:"summary"`
+		Devices []models.DeviceShadow `json:"devices"`
+	}
+	decode(t, e.do(http.MethodGet, "/api/v2/admin/device-configs?sync=drift", nil, token), &fleet)
+	if fleet.Count != 1 || fleet.Devices[0].DeviceID != "device_01" ||
+		fleet.Summary[models.ShadowDrift] != 1 || fleet.Summary[models.ShadowNeverReported] != 1 {
+		t.Errorf("fleet drift = %+v", fleet)
+	}
+
+	decode(t, e.do(http.MethodPost, "/api/v2/devices/device_01/config/reported", gin.H{"version": 1, "config": shadow.Desired}, ""), &shadow)
+	if shadow.Sync != models.ShadowInSync || len(shadow.Drift) != 0 {
+		t.Errorf("in-sync shadow = %+v", shadow)
+	}
+	beat.Config = nil
+	decode(t, e.do(http.MethodPost, "/api/v2/devices/device_01/heartbeat", gin.H{"config_version": 1}, ""), &beat)
+	if beat.Config != nil {
+		t.Errorf("up-to-date heartbeat still carries config %+v", beat.Config)
+	}
+}
+
+func TestDeviceCommands(t *testing.T) {
+	e := newTestEnv(t)
+	e.register("driver@example.com", "device_01")
+	token := e.admin("admin@example.com")
+	e.register("driver2@example.com", "device_02")
+	now := testClock
+	e.server.now = func() time.Time { return now }
+
+	for _, body := range []gin.H{{"type": "self_destruct"}, {"type": "voice_message", "message": "  "}} {
+		if w := e.do(http.MethodPost, "/api/v2/admin/devices/device_01/commands", body, token); w.Code != http.StatusBadRequest {
+			t.Errorf("enqueue %v: got %d, want 400", body, w.Code)
+		}
+	}
+	if w := e.do(http.MethodPost, "/api/v2/admin/devices/unknown/commands", gin.H{"type": "alarm"}, token); w.Code != http.StatusNotFound {
+		t.Errorf("unknown device: got %d, want 404", w.Code)
+	}
+
+	var alarm models.DeviceCommand
+	w := e.do(http.MethodPost, "/api/v2/admin/devices/device_01/commands", gin.H{"type": "alarm", "duration_seconds": 10}, token)
+	decode(t, w, &alarm)
+	if w.Code != http.StatusCreated || alarm.Status != models.CommandQueued || !alarm.ExpiresAt.Equal(now.Add(5*time.Minute)) {
+		t.Fatalf("enqueue alarm: %d %+v", w.Code, alarm)
+	}
+
+	var polled struct {
+		Commands []models.DeviceCommand `json:"commands"`
+	}
+	decode(t, e.do(http.MethodGet, "/api/v2/devices/device_01/commands?wait=0", nil, ""), &polled)
+	if len(polled.Commands) != 1 || polled.Commands[0].ID != alarm.ID || polled.Commands[0].Attempts != 1 {
+		t.Fatalf("poll = %+v", polled.Commands)
+	}
+	decode(t, e.do(http.MethodGet, "/api/v2/devices/device_01/commands?wait=0", nil, ""), &polled)
+	if len(polled.Commands) != 0 {
+		t.Errorf("second poll = %+v", polled.Commands)
+	}
+
+	ackPath := "/api/v2/devices/device_01/commands/" + strconv.Itoa(alarm.ID) + "/ack"
+	if w := e.do(http.MethodPost, "/api/v2/devices/device_02/commands/"+strconv.Itoa(alarm.ID)+"/ack", gin.H{"status": "succeeded"}, ""); w.Code != http.StatusNotFound {
+		t.Errorf("ack by another device: got %d, want 404", w.Code)
+	}
+	if w := e.do(http.MethodPost, ackPath, gin.H{"status": "succeeded"}, ""); w.Code != http.StatusOK {
+		t.Errorf("ack: got %d %s", w.Code, w.Body.String())
+	}
+	if w := e.do(http.MethodPost, ackPath, gin.H{"status": "failed"}, ""); w.Code != http.StatusConflict {
+		t.Errorf("second ack: got %d, want 409", w.Code)
+	}
+
+	var detail struct {
+		Command models.DeviceCommand  `json:"command"`
+		Events  []models.CommandEvent `json:"events"`
+	}
+	decode(t, e.do(http.MethodGet, "/api/v2/admin/commands/"+strconv.Itoa(alarm.ID), nil, token), &detail)
+	if detail.Command.Status != models.CommandSucceeded || len(detail.Events) != 3 ||
+		detail.Events[0].Actor != "user:"+strconv.Itoa(alarm.IssuedBy) || detail.Events[2].Status != models.CommandSucceeded {
+		t.Errorf("audit trail = %+v", detail)
+	}
+
+	// A long poll returns as soon as a command is queued
+	done := make(chan []models.DeviceCommand)
+	go func() {
+		var resp struct {
+			Commands []models.DeviceCommand `json:"commands"`
+		}
+		decode(t, e.do(http.MethodGet, "/api/v2/devices/device_01/commands?wait=5", nil, ""), &resp)
+		done <- resp.Commands
+	}()
+	time.Sleep(50 * time.Millisecond)
+	e.do(http.MethodPost, "/api/v2/admin/devices/device_01/commands", gin.H{"type": "voice_message", "message": "กรุณาจอดพัก"}, token)
+	select {
+	case cmds := <-done:
+		if len(cmds) != 1 || cmds[0].Message != "กรุณาจอดพัก" {
+			t.Errorf("long poll = %+v", cmds)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("long poll was not woken by the new command")
+	}
+
+	// Unacknowledged commands expire
+	var snapshot models.DeviceCommand
+	decode(t, e.do(http.MethodPost, "/api/v2/admin/devices/device_01/commands", gin.H{"type": "snapshot", "ttl_seconds": 60}, token), &snapshot)
+	now = now.Add(2 * time.Minute)
+	if w := e.do(http.MethodPost, "/api/v2/devices/device_01/commands/"+strconv.Itoa(snapshot.ID)+"/ack", gin.H{"status": "succeeded"}, ""); w.Code != http.StatusConflict {
+		t.Errorf("ack after expiry: got %d, want 409", w.Code)
+	}
+	var list struct {
+		Commands []models.DeviceCommand `json:"commands"`
+	}
+	decode(t, e.do(http.MethodGet, "/api/v2/admin/devices/device_01/commands?status=expired", nil, token), &list)
+	if len(list.Commands) != 1 || list.Commands[0].ID != snapshot.ID {
+		t.Errorf("expired commands = %+v", list.Commands)
+	}
+
+	// Heartbeats carry pending commands too; delivered commands can still be cancelled
+	var restart models.DeviceCommand
+	decode(t, e.do(http.MethodPost, "/api/v2/admin/devices/device_01/commands", gin.H{"type": "restart"}, token), &restart)
+	var beat struct {
+		Commands []models.DeviceCommand `json:"commands"`
+	}
+	decode(t, e.do(http.MethodPost, "/api/v2/devices/device_01/heartbeat", gin.H{}, ""), &beat)
+	if len(beat.Commands) != 1 || beat.Commands[0].ID != restart.ID {
+		t.Errorf("heartbeat commands = %+v", beat.Commands)
+	}
+	cancelPath := "/api/v2/admin/commands/" + strconv.Itoa(restart.ID) + "/cancel"
+	if w := e.do(http.MethodPost, cancelPath, nil, token); w.Code != http.StatusOK {
+		t.Errorf("cancel: got %d", w.Code)
+	}
+	if w := e.do(http.MethodPost, cancelPath, nil, token); w.Code != http.StatusConflict {
+		t.Errorf("second cancel: got %d, want 409", w.Code)
+	}
+}
+
+func TestAlertEvidence(t *testing.T) {
+	e := newTestEnv(t)
+	e.register("driver@example.com", "device_01")
+	token := e.admin("admin@example.com")
+	e.register("driver2@example.com", "device_02")
+	now := testClock
+	e.server.now = func() time.Time { return now }
+	send := func(req *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		e.router.ServeHTTP(w, req)
+		return w
+	}
+
+	var alert struct {
+		AlertID int `json:"alert_id"`
+	}
+	decode(t, e.do(http.MethodPost, "/api/devices/device_01/alert", gin.H{"alert_type": "drowsiness_detected", "severity": "high"}, ""), &alert)
+	if alert.AlertID == 0 {
+		t.Fatal("alert response has no alert_id")
+	}
+	alertPath := "/api/v2/devices/device_01/alerts/" + strconv.Itoa(alert.AlertID)
+
+	var jpg bytes.Buffer
+	if err := jpeg.Encode(&jpg, image.NewRGBA(image.Rect(0, 0, 640, 480)), nil); err != nil {
+		t.Fatal(err)
+	}
+	multipartUpload := func(path, contentType string, data []byte) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		h := textproto.MIMEHeader{}
+		h.Set("Content-Disposition", `form-data; name="file"; filename="snap"`)
+		h.Set("Content-Type", contentType)
+		part, _ := mw.CreatePart(h)
+		part.Write(data)
+		mw.Close()
+		req := httptest.NewRequest(http.MethodPost, path, &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		return send(req)
+	}
+
+	// Snapshots must be what they claim and belong to the device's alert
+	if w := multipartUpload(alertPath+"/evidence", "image/jpeg", []byte("not a jpeg")); w.Code != http.StatusBadRequest {
+		t.Errorf("fake jpeg: got %d, want 400", w.Code)
+	}
+	if w := multipartUpload("/api/v2/devices/device_02/alerts/"+strconv.Itoa(alert.AlertID)+"/evidence", "image/jpeg", jpg.Bytes()); w.Code != http.StatusNotFound {
+		t.Errorf("another device's alert: got %d, want 404", w.Code)
+	}
+	var snap models.Evidence
+	w := multipartUpload(alertPath+"/evidence", "image/jpeg", jpg.Bytes())
+	decode(t, w, &snap)
+	if w.Code != http.StatusCreated || snap.Kind != models.EvidenceSnapshot || snap.Size != int64(jpg.Len()) ||
+		snap.URL == "" || snap.ThumbnailURL == "" || !snap.ExpiresAt.Equal(now.Add(30*24*time.Hour)) {
+		t.Fatalf("snapshot upload: %d %s", w.Code, w.Body.String())
+	}
+
+	// Signed URLs point at the API version asked and serve the file and
+	// thumbnail until they expire
+	if !strings.HasPrefix(snap.URL, "/api/v2/evidence/") || !strings.HasPrefix(snap.ThumbnailURL, "/api/v2/evidence/") {
+		t.Errorf("v2 URLs = %q, %q", snap.URL, snap.ThumbnailURL)
+	}
+	w = e.do(http.MethodGet, snap.URL, nil, "")
+	if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), jpg.Bytes()) || w.Header().Get("Content-Type") != "image/jpeg" {
+		t.Errorf("file: got %d (%d bytes)", w.Code, w.Body.Len())
+	}
+	w = e.do(http.MethodGet, snap.ThumbnailURL, nil, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("thumbnail: got %d", w.Code)
+	}
+	if thumb, err := jpeg.DecodeConfig(w.Body); err != nil || thumb.Width != 320 || thumb.Height != 240 {
+		t.Errorf("thumbnail = %+v, %v", thumb, err)
+	}
+	if w := e.do(http.MethodGet, strings.Replace(snap.URL, "/file?", "/thumbnail?", 1), nil, ""); w.Code != http.StatusForbidden {
+		t.Errorf("signature of another variant: got %d, want 403", w.Code)
+	}
+	// Evidence URLs have their own key: the JWT secret signs none
+	forged, _ := evidence.NewSigner("test-secret", time.Hour).URL("/api/v2", snap.ID, "file", now)
+	if w := e.do(http.MethodGet, forged, nil, ""); w.Code != http.StatusForbidden {
+		t.Errorf("URL signed with the JWT secret: got %d, want 403", w.Code)
+	}
+	now = now.Add(16 * time.Minute)
+	if w := e.do(http.MethodGet, snap.URL, nil, ""); w.Code != http.StatusForbidden {
+		t.Errorf("expired URL: got %d, want 403", w.Code)
+	}
+
+	// A clip sent in chunks survives a dropped connection
+	clip := append([]byte("\x00\x00\x00\x18ftypmp42"), bytes.Repeat([]byte{7}, 5000)...)
+	var upload models.EvidenceUpload
+	w = e.do(http.MethodPost, alertPath+"/uploads", gin.H{"content_type": "video/mp4", "size": len(clip)}, "")
+	decode(t, w, &upload)
+	if w.Code != http.StatusCreated || upload.ID == "" || upload.Received != 0 {
+		t.Fatalf("start upload: %d %s", w.Code, w.Body.String())
+	}
+	uploadPath := "/api/v2/devices/device_01/uploads/" + upload.ID
+	chunk := func(offset int, data []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, uploadPath, bytes.NewReader(data))
+		req.Header.Set("Upload-Offset", strconv.Itoa(offset))
+		return send(req)
+	}
+	if w := chunk(0, clip[:2000]); w.Code != http.StatusOK {
+		t.Fatalf("first chunk: got %d %s", w.Code, w.Body.String())
+	}
+	// A chunk arriving while another is being written, on any replica, is refused
+	held := now.Add(time.Minute)
+	if err := e.store.Evidence.ClaimUpload(context.Background(), upload.ID, now, held); err != nil {
+		t.Fatal(err)
+	}
+	if w := chunk(2000, clip[2000:]); w.Code != http.StatusConflict {
+		t.Errorf("chunk of a claimed upload: got %d, want 409", w.Code)
+	}
+	e.store.Evidence.ReleaseUpload(context.Background(), upload.ID, held)
+	w = chunk(0, clip[:2000])
+	if w.Code != http.StatusConflict || w.Header().Get("Upload-Offset") != "2000" {
+		t.Errorf("replayed chunk: got %d offset %q", w.Code, w.Header().Get("Upload-Offset"))
+	}
+	decode(t, e.do(http.MethodGet, uploadPath, nil, ""), &upload)
+	if upload.Received != 2000 {
+		t.Errorf("resume offset = %d, want 2000", upload.Received)
+	}
+	if w := chunk(2000, append(clip[2000:], 1)); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized chunk: got %d, want 413", w.Code)
+	}
+	// The midnight purge deletes the alert before the last chunk arrives
+	if err := e.store.Retention.Purge(context.Background(), repository.PurgeCutoffs{Default: now.Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	var clipEvidence models.Evidence
+	w = chunk(2000, clip[2000:])
+	decode(t, w, &clipEvidence)
+	if w.Code != http.StatusCreated || clipEvidence.Kind != models.EvidenceClip || clipEvidence.Size != int64(len(clip)) ||
+		!clipEvidence.ExpiresAt.Equal(now.Add(7*24*time.Hour)) {
+		t.Fatalf("last chunk: %d %s", w.Code, w.Body.String())
+	}
+	if w := e.do(http.MethodGet, uploadPath, nil, ""); w.Code != http.StatusNotFound {
+		t.Errorf("finished upload: got %d, want 404", w.Code)
+	}
+
+	var list struct {
+		Count    int               `json:"count"`
+		Evidence []models.Evidence `json:"evidence"`
+	}
+	decode(t, e.do(http.MethodGet, "/api/v2/admin/alerts/"+strconv.Itoa(alert.AlertID)+"/evidence", nil, token), &list)
+	if list.Count != 2 || list.Evidence[0].URL == "" {
+		t.Fatalf("alert evidence = %+v", list)
+	}
+	if w := e.do(http.MethodGet, list.Evidence[0].URL, nil, ""); w.Code != http.StatusOK {
+		t.Errorf("fresh URL: got %d", w.Code)
+	}
+	decode(t, e.do(http.MethodGet, "/api/v2/admin/alerts/"+strconv.Itoa(alert.AlertID)+"/evidence", nil, token), &list)
+	if list.Count != 2 || !strings.HasPrefix(list.Evidence[0].URL, "/api/v2/evidence/") {
+		t.Fatalf("v2 alert evidence = %+v", list)
+	}
+	if w := e.do(http.MethodGet, list.Evidence[0].URL, nil, ""); w.Code != http.StatusOK || w.Header().Get("Deprecation") != "" {
+		t.Errorf("v2 URL: got %d, Deprecation %q", w.Code, w.Header().Get("Deprecation"))
+	}
+
+	// Clips expire first; deleted evidence is gone from storage too
+	now = now.Add(8 * 24 * time.Hour)
+	e.server.purgeEvidence(context.Background())
+	var login struct {
+		Token string `json:"token"`
+	}
+	decode(t, e.do(http.MethodPost, "/api/auth/login", gin.H{"email": "admin@example.com", "password": "secret123"}, ""), &login)
+	token = login.Token
+	decode(t, e.do(http.MethodGet, "/api/v2/admin/evidence?device_id=device_01", nil, token), &list)
+	if list.Count != 1 || list.Evidence[0].ID != snap.ID {
+		t.Fatalf("after clip retention = %+v", list.Evidence)
+	}
+	if w := e.do(http.MethodDelete, "/api/v2/admin/evidence/"+strconv.Itoa(snap.ID), nil, token); w.Code != http.StatusOK {
+		t.Errorf("delete: got %d", w.Code)
+	}
+	if _, _, err := e.server.files.Open(context.Background(), snap.StorageKey); err == nil {
+		t.Error("deleted snapshot is still stored")
+	}
+}
+
+func TestFaceVerification(t *testing.T) {
+	e := newTestEnv(t)
+	tokenA := e.register("driver@example.com", "device_01")
+	tokenB := e.register("driver2@example.com", "device_02")
+	userID := func(token string) int {
+		var me struct {
+			ID int `json:"id"`
+		}
+		decode(t, e.do(http.MethodGet, "/api/auth/me", nil, token), &me)
+		return me.ID
+	}
+	driverA, driverB := userID(tokenA), userID(tokenB)
+	face := func(axis int, tilt float64) []float64 {
+		v := make([]float64, 128)
+		v[axis], v[axis+1] = 1, tilt
+		return v
+	}
+
+	if w := e.do(http.MethodPost, "/api/v2/auth/me/face", gin.H{"embedding": make([]float64, 112*112)}, tokenA); w.Code != http.StatusBadRequest {
+		t.Errorf("image-sized embedding: got %d, want 400", w.Code)
+	}
+	for _, enrol := range []struct {
+		token string
+		axis  int
+	}{{tokenA, 0}, {tokenA, 1}, {tokenB, 10}} {
+		if w := e.do(http.MethodPost, "/api/v2/auth/me/face", gin.H{"embedding": face(enrol.axis, 0.1), "model": "facenet"}, enrol.token); w.Code != http.StatusCreated {
+			t.Fatalf("enrol: got %d %s", w.Code, w.Body.String())
+		}
+	}
+	w := e.do(http.MethodGet, "/api/v2/auth/me/face", nil, tokenA)
+	if !strings.Contains(w.Body.String(), `"count":2`) || strings.Contains(w.Body.String(), "0.99") {
+		t.Errorf("my faces = %s", w.Body.String())
+	}
+
+	start := func(embedding []float64) models.DrivingSession {
+		var s models.DrivingSession
+		w := e.do(http.MethodPost, "/api/v2/devices/device_01/sessions/start", gin.H{"face_embedding": embedding, "face_model": "facenet"}, "")
+		decode(t, w, &s)
+		if w.Code != http.StatusCreated || s.Identity == nil {
+			t.Fatalf("start: got %d %s", w.Code, w.Body.String())
+		}
+		return s
+	}
+	sampleDriver := func() int {
+		e.do(http.MethodPost, "/api/devices/device_01/data", gin.H{"eye_closure": 0.2, "drowsiness_level": "low"}, "")
+		open, err := e.store.Sessions.Open(context.Background(), "device_01")
+		if err != nil {
+			t.Fatalf("open session: %v", err)
+		}
+		return open.DriverID
+	}
+
+	s := start(face(0, 0.2))
+	if s.Identity.Status != models.IdentityVerified || s.DriverID != driverA || s.Identity.Similarity == nil {
+		t.Errorf("owner's face: %+v %+v", s, s.Identity)
+	}
+
+	// Driver B takes device_01: the trip and its samples are theirs
+	s = start(face(10, 0.2))
+	if s.Identity.Status != models.IdentityReassigned || s.DriverID != driverB || s.Identity.ExpectedDriverID != driverA {
+		t.Errorf("other driver's face: %+v %+v", s, s.Identity)
+	}
+	if got := sampleDriver(); got != driverB {
+		t.Errorf("sample attributed to %d, want %d", got, driverB)
+	}
+
+	// A stranger raises an alert and the trip belongs to nobody
+	s = start(face(50, 0))
+	if s.Identity.Status != models.IdentityUnrecognized || s.DriverID != 0 {
+		t.Errorf("stranger: %+v %+v", s, s.Identity)
+	}
+	if got := sampleDriver(); got != 0 {
+		t.Errorf("stranger's sample attributed to %d", got)
+	}
+	var alerts struct {
+		Alerts []models.Alert `json:"alerts"`
+	}
+	decode(t, e.do(http.MethodGet, "/api/devices/device_01/alerts", nil, ""), &alerts)
+	if len(alerts.Alerts) != 1 || alerts.Alerts[0].AlertType != "unrecognized_driver" {
+		t.Errorf("alerts = %+v", alerts.Alerts)
+	}
+
+	if w := e.do(http.MethodPost, "/api/v2/devices/device_01/sessions/start", gin.H{"face_embedding": []float64{1, 2, 3}}, ""); w.Code != http.StatusBadRequest {
+		t.Errorf("short embedding: got %d, want 400", w.Code)
+	}
+
+	// Once a driver's references are reset their face is no longer recognised
+	if w := e.do(http.MethodDelete, "/api/v2/admin/drivers/"+strconv.Itoa(driverB)+"/face", nil, tokenA); w.Code != http.StatusForbidden {
+		t.Errorf("reset by a driver: got %d, want 403", w.Code)
+	}
+	admin := e.admin("admin@example.com")
+	if w := e.do(http.MethodDelete, "/api/v2/admin/drivers/"+strconv.Itoa(driverB)+"/face", nil, admin); w.Code != http.StatusOK {
+		t.Errorf("reset: got %d", w.Code)
+	}
+	if s = start(face(10, 0.2)); s.Identity.Status != models.IdentityUnrecognized {
+		t.Errorf("after reset: %+v", s.Identity)
+	}
+}
+
+func TestDataExport(t *testing.T) {
+	e := newTestEnv(t)
+	e.register("driver@example.com", "device_th")
+	admin := e.admin("admin@example.com")
+	w := e.do(http.MethodPost, "/api/v2/admin/fleets", gin.H{"name": "Berlin", "timezone": "Europe/Berlin"}, admin)
+	var fleet struct {
+		ID int `json:"id"`
+	}
+	decode(t, w, &fleet)
+	w = e.do(http.MethodPost, "/api/auth/register", gin.H{
+		"email": "berlin@example.com", "password": "secret123", "name": "Jonas", "device_id": "device_de", "fleet_id": fleet.ID,
+	}, "")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("register: got %d %s", w.Code, w.Body.String())
+	}
+
+	for _, device := range []string{"device_de", "device_th"} {
+		e.do(http.MethodPost, "/api/devices/"+device+"/data", gin.H{
+			"eye_closure": 0.9, "drowsiness_level": "high", "status": "drowsy",
+		}, "")
+		e.do(http.MethodPost, "/api/devices/"+device+"/alert", gin.H{"alert_type": "drowsiness", "severity": "high"}, "")
+	}
+
+	// The fleet export is in Berlin time with Thai headers by default
+	w = e.do(http.MethodGet, "/api/v2/admin/export/history?fleet_id="+strconv.Itoa(fleet.ID), nil, admin)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/csv; charset=utf-8" {
+		t.Fatalf("history csv: got %d %s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Content-Disposition"); got != `attachment; filename="history_2025-11-09_2025-11-09.csv"` {
+		t.Errorf("content disposition = %q", got)
+	}
+	lines := strings.Split(strings.TrimSpace(strings.TrimPrefix(w.Body.String(), "\uFEFF")), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "เวลา (Europe/Berlin),อุปกรณ์,คนขับ,กลุ่มรถ") {
+		t.Fatalf("history csv = %q", w.Body.String())
+	}
+	// 03:30 UTC is 04:30 in Berlin
+	if !strings.HasPrefix(lines[1], "2025-11-09 04:30:00,device_de,Jonas,Berlin,,0.9,high,drowsy") {
+		t.Errorf("history row = %q", lines[1])
+	}
+
+	w = e.do(http.MethodGet, "/api/v2/admin/export/alerts?lang=en&device_id=device_th&from=2025-11-09&to=2025-11-10", nil, admin)
+	lines = strings.Split(strings.TrimSpace(strings.TrimPrefix(w.Body.String(), "\uFEFF")), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "Time (Asia/Bangkok),Device,Driver,Fleet,Vehicle,Alert type") ||
+		!strings.HasPrefix(lines[1], "2025-11-09 10:30:00,device_th,Driver device_th,,,drowsiness,high") {
+		t.Errorf("alerts csv = %q", w.Body.String())
+	}
+
+	w = e.do(http.MethodGet, "/api/v2/admin/export/alerts?format=xlsx", nil, admin)
+	body := w.Body.Bytes()
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "application/vnd.openxmlformats") ||
+		!bytes.HasPrefix(body, []byte("PK")) {
+		t.Fatalf("alerts xlsx: got %d %q", w.Code, w.Header())
+	}
+	if _, err := zip.NewReader(bytes.NewReader(body), int64(len(body))); err != nil {
+		t.Errorf("alerts xlsx is not a zip: %v", err)
+	}
+
+	// An empty range still yields the header row
+	w = e.do(http.MethodGet, "/api/v2/admin/export/history?driver_id=999&lang=en", nil, admin)
+	if lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n"); w.Code != http.StatusOK || len(lines) != 1 {
+		t.Errorf("empty export: got %d %q", w.Code, w.Body.String())
+	}
+
+	for _, tc := range []struct {
+		query string
+		code  int
+	}{
+		{"format=pdf", http.StatusBadRequest},
+		{"fleet_id=abc", http.StatusBadRequest},
+		{"from=2025-11-10&to=2025-11-09", http.StatusBadRequest},
+		{"tz=Nowhere/City", http.StatusBadRequest},
+		{"fleet_id=999", http.StatusNotFound},
+		// Raw data is kept from the start of today in each fleet's timezone,
+		// purged at its own midnight: 04:30 in Berlin is already the 9th
+		{"device_id=device_th&from=2025-11-08", http.StatusUnprocessableEntity},
+		{"fleet_id=" + strconv.Itoa(fleet.ID) + "&from=2025-11-09", http.StatusOK},
+		{"fleet_id=" + strconv.Itoa(fleet.ID) + "&from=2025-11-08", http.StatusUnprocessableEntity},
+	} {
+		if w := e.do(http.MethodGet, "/api/v2/admin/export/history?"+tc.query, nil, admin); w.Code != tc.code {
+			t.Errorf("%s: got %d, want %d", tc.query, w.Code, tc.code)
+		}
+	}
+	var purged struct {
+		RetainedFrom string `json:"retained_from"`
+	}
+	decode(t, e.do(http.MethodGet, "/api/v2/admin/export/alerts?device_id=device_th&from=2025-11-08", nil, admin), &purged)
+	if purged.RetainedFrom != "2025-11-09T00:00:00+07:00" {
+		t.Errorf("retained_from = %q", purged.RetainedFrom)
+	}
+	if w := e.do(http.MethodGet, "/api/v2/admin/export/history", nil, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("unauthenticated export: got %d, want 401", w.Code)
+	}
+}
+
+func TestSafetyReports(t *testing.T) {
+	e := newTestEnv(t)
+	admin := e.admin("admin@example.com")
+	w := e.do(http.MethodPost, "/api/v2/admin/fleets", gin.H{"name": "Bangkok", "timezone": "Asia/Bangkok"}, admin)
+	var fleet struct {
+		ID int `json:"id"`
+	}
+	decode(t, w, &fleet)
+	for _, d := range []struct{ email, name, device string }{
+		{"somchai@example.com", "Somchai", "device_1"}, {"malee@example.com", "Malee", "device_2"},
+	} {
+		w = e.do(http.MethodPost, "/api/auth/register", gin.H{
+			"email": d.email, "password": "secret123", "name": d.name, "device_id": d.device, "fleet_id": fleet.ID,
+		}, "")
+		if w.Code != http.StatusCreated {
+			t.Fatalf("register: got %d %s", w.Code, w.Body.String())
+		}
+	}
+	for _, level := range []string{"high", "high", "medium"} {
+		e.do(http.MethodPost, "/api/devices/device_1/data", gin.H{"eye_closure": 0.9, "drowsiness_level": level, "status": "drowsy"}, "")
+	}
+	e.do(http.MethodPost, "/api/devices/device_2/data", gin.H{"eye_closure": 0.6, "drowsiness_level": "medium", "status": "drowsy"}, "")
+
+	w = e.do(http.MethodPost, "/api/v2/admin/reports", gin.H{"fleet_id": fleet.ID, "from": "2025-11-03", "to": "2025-11-10"}, admin)
+	var rep models.Report
+	decode(t, w, &rep)
+	if w.Code != http.StatusCreated || rep.Status != models.ReportReady || rep.Kind != models.ReportFleet {
+		t.Fatalf("create: got %d %s", w.Code, w.Body.String())
+	}
+	// An overview page and one page per driver with events
+	if rep.Pages != 3 || rep.Size == 0 || rep.Title != "Bangkok 2025-11-03 – 2025-11-09" || rep.Timezone != "Asia/Bangkok" {
+		t.Errorf("report = %+v", rep)
+	}
+
+	w = e.do(http.MethodGet, "/api/v2/admin/reports/"+strconv.Itoa(rep.ID)+"/pdf", nil, admin)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/pdf" || !strings.HasPrefix(w.Body.String(), "%PDF-") {
+		t.Fatalf("download: got %d %q", w.Code, w.Header())
+	}
+	if got := w.Header().Get("Content-Disposition"); got != `attachment; filename="report_fleet_`+strconv.Itoa(rep.ID)+`_2025-11-03.pdf"` {
+		t.Errorf("content disposition = %q", got)
+	}
+
+	// Driver reports default to last week, which has no events yet
+	somchai, err := e.store.Users.GetByEmail(context.Background(), "somchai@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w = e.do(http.MethodPost, "/api/v2/admin/reports", gin.H{"driver_id": somchai.ID}, admin)
+	var driverRep models.Report
+	decode(t, w, &driverRep)
+	if w.Code != http.StatusCreated || driverRep.Pages != 1 || driverRep.Title != "Somchai 2025-10-27 – 2025-11-02" {
+		t.Errorf("driver report: got %d %s", w.Code, w.Body.String())
+	}
+
+	// The weekly schedule adds one report per fleet and week, however often it runs
+	e.server.scheduleReports(context.Background())
+	e.server.scheduleReports(context.Background())
+	var list struct {
+		Count   int             `json:"count"`
+		Reports []models.Report `json:"reports"`
+	}
+	decode(t, e.do(http.MethodGet, "/api/v2/admin/reports?fleet_id="+strconv.Itoa(fleet.ID), nil, admin), &list)
+	if list.Count != 2 || list.Reports[0].Source != models.ReportSchedule || list.Reports[1].ID != rep.ID {
+		t.Errorf("fleet reports = %+v", list)
+	}
+
+	for _, tc := range []struct {
+		method, path string
+		body         interface{}
+		code         int
+	}{
+		{http.MethodPost, "/api/v2/admin/reports", gin.H{}, http.StatusBadRequest},
+		{http.MethodPost, "/api/v2/admin/reports", gin.H{"fleet_id": fleet.ID, "driver_id": somchai.ID}, http.StatusBadRequest},
+		{http.MethodPost, "/api/v2/admin/reports", gin.H{"fleet_id": fleet.ID, "from": "2025-11-10", "to": "2025-11-03"}, http.StatusBadRequest},
+		{http.MethodPost, "/api/v2/admin/reports", gin.H{"fleet_id": 999}, http.StatusNotFound},
+		{http.MethodPost, "/api/v2/admin/reports", gin.H{"driver_id": 999}, http.StatusNotFound},
+		{http.MethodGet, "/api/v2/admin/reports/abc", nil, http.StatusBadRequest},
+		{http.MethodGet, "/api/v2/admin/reports/999/pdf", nil, http.StatusNotFound},
+	} {
+		if w := e.do(tc.method, tc.path, tc.body, admin); w.Code != tc.code {
+			t.Errorf("%s %s %v: got %d, want %d", tc.method, tc.path, tc.body, w.Code, tc.code)
+		}
+	}
+
+	path := "/api/v2/admin/reports/" + strconv.Itoa(rep.ID)
+	if w := e.do(http.MethodDelete, path, nil, admin); w.Code != http.StatusOK {
+		t.Fatalf("delete: got %d %s", w.Code, w.Body.String())
+	}
+	if w := e.do(http.MethodGet, path, nil, admin); w.Code != http.StatusNotFound {
+		t.Errorf("deleted report: got %d", w.Code)
+	}
+	if w := e.do(http.MethodGet, "/api/v2/admin/reports", nil, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("unauthenticated list: got %d, want 401", w.Code)
+	}
+}
+
+// fakeSender records messages and fails while fail is set
+type fakeSender struct {
+	sent []digest.Message
+	fail bool
+}
+
+func (f *fakeSender) Send(_ context.Context, m digest.Message) error {
+	if f.fail {
+		return errors.New("mail server unavailable")
+	}
+	f.sent = append(f.sent, m)
+	return nil
+}
+
+func TestReportSubscriptions(t *testing.T) {
+	e := newTestEnv(t)
+	admin := e.admin("admin@example.com")
+	w := e.do(http.MethodPost, "/api/v2/admin/fleets", gin.H{"name": "Bangkok", "timezone": "Asia/Bangkok"}, admin)
+	var fleet struct {
+		ID int `json:"id"`
+	}
+	decode(t, w, &fleet)
+	e.do(http.MethodPost, "/api/auth/register", gin.H{
+		"email": "somchai@example.com", "password": "secret123", "name": "Somchai", "device_id": "device_1", "fleet_id": fleet.ID,
+	}, "")
+	e.do(http.MethodPost, "/api/devices/device_1/data", gin.H{"eye_closure": 0.9, "drowsiness_level": "high", "status": "drowsy"}, "")
+
+	var hooks []*http.Request
+	var bodies [][]byte
+	status := http.StatusServiceUnavailable
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		hooks, bodies = append(hooks, r), append(bodies, body)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+	mailer := &fakeSender{}
+	e.server.mailer = mailer
+
+	for _, bad := range []gin.H{
+		{"fleet_id": fleet.ID, "digest": "monthly", "channel": "email", "target": "boss@example.com"},
+		{"fleet_id": fleet.ID, "digest": "daily_summary", "format": "docx", "channel": "email", "target": "boss@example.com"},
+		{"fleet_id": fleet.ID, "digest": "daily_summary", "channel": "email", "target": "not an address"},
+		{"fleet_id": fleet.ID, "digest": "daily_summary", "channel": "webhook", "target": "ftp://example.com/x"},
+	} {
+		if w = e.do(http.MethodPost, "/api/v2/admin/subscriptions", bad, admin); w.Code != http.StatusBadRequest {
+			t.Errorf("create %v: got %d", bad, w.Code)
+		}
+	}
+	if w = e.do(http.MethodPost, "/api/v2/admin/subscriptions", gin.H{
+		"fleet_id": 999, "digest": "daily_summary", "channel": "email", "target": "boss@example.com",
+	}, admin); w.Code != http.StatusNotFound {
+		t.Errorf("unknown fleet: got %d", w.Code)
+	}
+
+	webhook := gin.H{
+		"fleet_id": fleet.ID, "digest": "daily_summary", "format": "csv", "channel": "webhook", "target": srv.URL, "secret": "s3cret", "lang": "en",
+	}
+	if w = e.do(http.MethodPost, "/api/v2/admin/subscriptions", webhook, admin); w.Code != http.StatusBadRequest {
+		t.Errorf("loopback webhook: got %d", w.Code)
+	}
+	e.server.cfg.WebhookAllowPrivate = true
+	e.server.webhooks = digest.NewWebhook(5*time.Second, true)
+	w = e.do(http.MethodPost, "/api/v2/admin/subscriptions", webhook, admin)
+	var hook models.Subscription
+	decode(t, w, &hook)
+	if w.Code != http.StatusCreated || !hook.Active || hook.Lang != "en" || !hook.NextRunAt.Equal(time.Date(2025, 11, 10, 1, 0, 0, 0, time.UTC)) {
+		t.Fatalf("create: got %d %s", w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "s3cret") {
+		t.Error("secret returned")
+	}
+	w = e.do(http.MethodPost, "/api/v2/admin/subscriptions", gin.H{
+		"fleet_id": fleet.ID, "digest": "weekly_compliance", "channel": "email", "target": "boss@example.com", "lang": "th",
+	}, admin)
+	var weekly models.Subscription
+	decode(t, w, &weekly)
+	if w.Code != http.StatusCreated || weekly.Format != models.DigestHTML || weekly.Lang != "th" {
+		t.Fatalf("create weekly: got %d %s", w.Code, w.Body.String())
+	}
+
+	// Nothing is due before Monday 08:00 Bangkok
+	e.server.runSubscriptions(context.Background())
+	if len(hooks) != 0 || len(mailer.sent) != 0 {
+		t.Fatal("delivered before the schedule")
+	}
+
+	somchai, err := e.store.Users.GetByEmail(context.Background(), "somchai@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.store.Compliance.ArchiveDays(context.Background(), []models.ComplianceArchiveDay{
+		{Day: time.Date(2025, 11, 4, 0, 0, 0, 0, bangkokZone), DriverID: somchai.ID, DrivingSeconds: 10 * 3600, Exceeded: true, Violations: 1},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2025, 11, 10, 1, 0, 30, 0, time.UTC)
+	e.server.now = func() time.Time { return now }
+	e.server.runSubscriptions(context.Background())
+	if len(hooks) != 1 || len(mailer.sent) != 1 {
+		t.Fatalf("first run: %d webhooks, %d mails", len(hooks), len(mailer.sent))
+	}
+	if got := hooks[0].Header.Get("X-Digest-Signature"); got != digest.Sign("s3cret", bodies[0]) {
+		t.Errorf("signature = %q", got)
+	}
+	if !strings.Contains(string(bodies[0]), "Somchai,1,0") {
+		t.Errorf("daily csv = %q", bodies[0])
+	}
+	if m := mailer.sent[0]; m.To != "boss@example.com" || !strings.Contains(m.Content.Subject, "2025-11-03") ||
+		!strings.Contains(string(m.Content.Body), "Somchai") {
+		t.Errorf("weekly mail = %+v", m.Content)
+	}
+
+	// The failed webhook is retried after the backoff, once however often the scheduler runs
+	e.server.runSubscriptions(context.Background())
+	status = http.StatusNoContent
+	now = now.Add(2 * time.Minute)
+	e.server.runSubscriptions(context.Background())
+	e.server.runSubscriptions(context.Background())
+	if len(hooks) != 2 || hooks[1].Header.Get("X-Delivery-ID") != hooks[0].Header.Get("X-Delivery-ID") {
+		t.Fatalf("retry: %d webhooks", len(hooks))
+	}
+
+	var deliveries struct {
+		Count      int               `json:"count"`
+		Deliveries []models.Delivery `json:"deliveries"`
+	}
+	w = e.do(http.MethodGet, "/api/v2/admin/subscriptions/"+strconv.Itoa(hook.ID)+"/deliveries", nil, admin)
+	decode(t, w, &deliveries)
+	if deliveries.Count != 1 {
+		t.Fatalf("deliveries: %s", w.Body.String())
+	}
+	if d := deliveries.Deliveries[0]; d.Status != models.DeliverySent || d.Attempts != 2 || d.DeliveredAt == nil ||
+		!d.PeriodFrom.Equal(time.Date(2025, 11, 9, 0, 0, 0, 0, bangkokZone)) {
+		t.Errorf("delivery = %+v", d)
+	}
+
+	// Pausing stops deliveries; deleting removes the history
+	f := false
+	w = e.do(http.MethodPut, "/api/v2/admin/subscriptions/"+strconv.Itoa(weekly.ID), gin.H{"active": f, "target": "ops@example.com"}, admin)
+	if decode(t, w, &weekly); w.Code != http.StatusOK || weekly.Active || weekly.Target != "ops@example.com" {
+		t.Errorf("pause: got %d %s", w.Code, w.Body.String())
+	}
+	if w = e.do(http.MethodDelete, "/api/v2/admin/subscriptions/"+strconv.Itoa(hook.ID), nil, admin); w.Code != http.StatusOK {
+		t.Errorf("delete: got %d", w.Code)
+	}
+	if w = e.do(http.MethodGet, "/api/v2/admin/subscriptions/"+strconv.Itoa(hook.ID)+"/deliveries", nil, admin); w.Code != http.StatusNotFound {
+		t.Errorf("deliveries of deleted: got %d", w.Code)
+	}
+	if d, _ := e.store.Subscriptions.ListDeliveries(context.Background(), hook.ID, 10); len(d) != 0 {
+		t.Errorf("deliveries left after delete: %d", len(d))
+	}
+	var list struct {
+		Count int `json:"count"`
+	}
+	decode(t, e.do(http.MethodGet, "/api/v2/admin/subscriptions?fleet_id="+strconv.Itoa(fleet.ID), nil, admin), &list)
+	if list.Count != 1 {
+		t.Errorf("list count = %d", list.Count)
+	}
+}
+
+func TestHistoryPagination(t *testing.T) {
+	e := newTestEnv(t)
+	ctx := context.Background()
+	// Seven samples a minute apart, the last two sharing a timestamp
+	for i, level := range []string{"low", "high", "low", "medium", "high", "low", "high"} {
+		at := testClock.Add(time.Duration(min(i, 5)-6) * time.Minute)
+		if err := e.store.Drowsiness.Insert(ctx, &models.DrowsinessData{
+			DeviceID: "device_01", EyeClosure: 0.5, DrowsinessLevel: level, Status: "ok", Timestamp: at,
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if err := e.store.Alerts.Insert(ctx, &models.Alert{
+			DeviceID: "device_01", AlertType: "drowsy", Severity: level, Timestamp: at,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	type page struct {
+		Count int                     `json:"count"`
+		Data  []models.DrowsinessData `json:"items"`
+		Next  *string                 `json:"next"`
+		Prev  *string                 `json:"prev"`
+	}
+	get := func(path string) page {
+		t.Helper()
+		w := e.do(http.MethodGet, path, nil, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: got %d %s", path, w.Code, w.Body.String())
+		}
+		var p page
+		decode(t, w, &p)
+		return p
+	}
+	ids := func(p page) (out []int) {
+		for _, d := range p.Data {
+			out = append(out, d.ID)
+		}
+		return out
+	}
+
+	// Forward through every sample, newest first, then back again
+	var forward [][]int
+	p := get("/api/v2/devices/device_01/history?limit=3&level=")
+	if p.Prev != nil {
+		t.Errorf("first page has prev %q", *p.Prev)
+	}
+	for {
+		forward = append(forward, ids(p))
+		if p.Next == nil {
+			break
+		}
+		p = get(*p.Next)
+	}
+	var seen []int
+	for _, page := range forward {
+		seen = append(seen, page...)
+	}
+	if len(forward) != 3 || len(seen) != 7 {
+		t.Fatalf("pages = %v", forward)
+	}
+	for i := 1; i < len(seen); i++ {
+		a, b := e.sample(seen[i-1]), e.sample(seen[i])
+		if b.Timestamp.After(a.Timestamp) || (b.Timestamp.Equal(a.Timestamp) && b.ID > a.ID) {
+			t.Fatalf("order broken at %d: %v", i, seen)
+		}
+	}
+	for i := len(forward) - 2; i >= 0; i-- {
+		if p.Prev == nil {
+			t.Fatalf("page %d has no prev", i+1)
+		}
+		p = get(*p.Prev)
+		if got := ids(p); len(got) != len(forward[i]) || got[0] != forward[i][0] || got[len(got)-1] != forward[i][len(got)-1] {
+			t.Errorf("back to page %d = %v, want %v", i, got, forward[i])
+		}
+	}
+	if p.Prev != nil {
+		t.Errorf("back on the first page, prev = %q", *p.Prev)
+	}
+
+	// Past the oldest sample the page is empty and links back to it
+	oldest := e.sample(seen[len(seen)-1])
+	p = get("/api/v2/devices/device_01/history?limit=3&cursor=" + encodeCursor("next", oldest.Timestamp, oldest.ID))
+	if p.Count != 0 || p.Next != nil || p.Prev == nil {
+		t.Fatalf("past the end: %+v", p)
+	}
+	if p = get(*p.Prev); p.Count != 3 || p.Data[2].ID != oldest.ID {
+		t.Errorf("back from the end: %v", ids(p))
+	}
+
+	// Filters stay in the links
+	p = get("/api/v2/devices/device_01/history?limit=2&level=HIGH&from=2025-11-09T10:25:00%2B07:00")
+	if p.Count != 2 || p.Next == nil || !strings.Contains(*p.Next, "level=HIGH") {
+		t.Fatalf("filtered page = %+v", p)
+	}
+	if p = get(*p.Next); p.Count != 1 || p.Data[0].Timestamp.Before(testClock.Add(-5*time.Minute)) || p.Next != nil || p.Prev == nil {
+		t.Errorf("last high samples: %+v", p)
+	}
+
+	var alerts struct {
+		Count  int            `json:"count"`
+		Alerts []models.Alert `json:"items"`
+		Next   *string        `json:"next"`
+	}
+	decode(t, e.do(http.MethodGet, "/api/v2/devices/device_01/alerts?severity=low&limit=2", nil, ""), &alerts)
+	if alerts.Count != 2 || alerts.Next == nil || alerts.Alerts[0].Severity != "low" {
+		t.Fatalf("alerts = %+v", alerts)
+	}
+	decode(t, e.do(http.MethodGet, *alerts.Next, nil, ""), &alerts)
+	if alerts.Count != 1 || alerts.Next != nil {
+		t.Errorf("second alert page = %+v", alerts)
+	}
+
+	for _, q := range []string{"cursor=bm9wZQ", "from=yesterday", "from=2025-11-10&to=2025-11-09"} {
+		if w := e.do(http.MethodGet, "/api/v2/devices/device_01/history?"+q, nil, ""); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: got %d", q, w.Code)
+		}
+	}
+	// A from the purge already deleted is an error rather than an empty page
+	if w := e.do(http.MethodGet, "/api/v2/devices/device_01/alerts?from=2025-11-08", nil, ""); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("purged range: got %d, want 422", w.Code)
+	}
+
+	// v1 keeps its frozen shape: the latest rows without filters or links
+	for path, key := range map[string]string{
+		"/api/devices/device_01/history?limit=3&level=high&cursor=bm9wZQ": "data",
+		"/api/v1/devices/device_01/alerts?limit=3&from=2025-11-08":        "alerts",
+	} {
+		w := e.do(http.MethodGet, path, nil, "")
+		var body map[string]json.RawMessage
+		decode(t, w, &body)
+		var items []map[string]interface{}
+		json.Unmarshal(body[key], &items)
+		if _, paged := body["next"]; w.Code != http.StatusOK || len(items) != 3 || paged || body["items"] != nil {
+			t.Errorf("%s: got %d %s", path, w.Code, w.Body.String())
+		}
+	}
+}
+
+// sample returns a stored sample by id
+func (e *testEnv) sample(id int) models.DrowsinessData {
+	e.t.Helper()
+	data, _ := e.store.Drowsiness.History(context.Background(), repository.PageFilter{DeviceID: "device_01"})
+	for _, d := range data {
+		if d.ID == id {
+			return d
+		}
+	}
+	e.t.Fatalf("no sample %d", id)
+	return models.DrowsinessData{}
+}
+
+func TestErrorEnvelope(t *testing.T) {
+	e := newTestEnv(t)
+	send := func(path, body, lang, requestID string) (*httptest.ResponseRecorder, models.ErrorResponse) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept-Language", lang)
+		if requestID != "" {
+			req.Header.Set("X-Request-ID", requestID)
+		}
+		w := httptest.NewRecorder()
+		e.router.ServeHTTP(w, req)
+		var resp models.ErrorResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w, resp
+	}
+
+	w, resp := send("/api/devices/device_01/data", `{"eye_closure": -0.2, "drowsiness_level": "sleepy"}`, "en-US,en;q=0.9", "trace-42")
+	if w.Code != http.StatusBadRequest || resp.Error.Code != models.CodeValidation || resp.Error.Message != "Validation failed" {
+		t.Fatalf("got %d %s", w.Code, w.Body.String())
+	}
+	if resp.Error.RequestID != "trace-42" || w.Header().Get("X-Request-ID") != "trace-42" {
+		t.Errorf("request id = %q, header %q", resp.Error.RequestID, w.Header().Get("X-Request-ID"))
+	}
+	want := map[string]string{
+		"eye_closure":      "eye_closure must be at least 0",
+		"drowsiness_level": "drowsiness_level must be low, medium or high",
+	}
+	if len(resp.Error.Details) != len(want) {
+		t.Fatalf("details = %+v", resp.Error.Details)
+	}
+	for _, d := range resp.Error.Details {
+		if want[d.Field] != d.Message {
+			t.Errorf("detail %+v", d)
+		}
+	}
+
+	// Thai unless English is preferred; a malformed request id is replaced
+	w, resp = send("/api/devices/device_01/data", `{"eye_closure": 1.5, "drowsiness_level": "HIGH"}`, "th", "bad id!")
+	if len(resp.Error.Details) != 1 || resp.Error.Details[0].Message != "eye_closure ต้องไม่เกิน 1" || resp.Error.Message != "ข้อมูลไม่ถูกต้อง" {
+		t.Errorf("thai details = %+v", resp.Error)
+	}
+	if id := w.Header().Get("X-Request-ID"); id == "" || id == "bad id!" || resp.Error.RequestID != id {
+		t.Errorf("generated request id = %q", id)
+	}
+
+	// A language without a translation gets English, not Thai
+	if _, resp = send("/api/devices/device_01/data", `{"eye_closure": 1.5, "drowsiness_level": "low"}`, "de-DE", ""); resp.Error.Message != "Validation failed" {
+		t.Errorf("german details = %+v", resp.Error)
+	}
+
+	_, resp = send("/api/devices/device_01/data", `{"eye_closure": "closed", "drowsiness_level": "low"}`, "en", "")
+	if len(resp.Error.Details) != 1 || resp.Error.Details[0].Rule != "type" || resp.Error.Details[0].Message != "eye_closure must be of type number" {
+		t.Errorf("type details = %+v", resp.Error)
+	}
+	if _, resp = send("/api/devices/device_01/data", `{"eye_closure": `, "en", ""); resp.Error.Code != models.CodeInvalidJSON || resp.Error.Details != nil {
+		t.Errorf("malformed = %+v", resp.Error)
+	}
+
+	_, resp = send("/api/auth/register", `{"email": "not-an-email", "password": "password"}`, "en", "")
+	rules := map[string]string{}
+	for _, d := range resp.Error.Details {
+		rules[d.Field] = d.Rule
+	}
+	if rules["email"] != "email" || rules["password"] != "password" {
+		t.Errorf("register details = %+v", resp.Error.Details)
+	}
+	_, resp = send("/api/devices/device_01/alert", `{"alert_type": "yawn", "severity": "extreme"}`, "en", "")
+	if len(resp.Error.Details) != 1 || resp.Error.Details[0].Field != "severity" {
+		t.Errorf("alert details = %+v", resp.Error.Details)
+	}
+
+	// Errors outside validation share the envelope
+	w = e.do(http.MethodGet, "/api/auth/me", nil, "")
+	var unauthorized models.ErrorResponse
+	decode(t, w, &unauthorized)
+	if w.Code != http.StatusUnauthorized || unauthorized.Error.Code != models.CodeUnauthorized || unauthorized.Error.Message != "Missing token" {
+		t.Errorf("unauthorized = %d %s", w.Code, w.Body.String())
+	}
+	w = e.do(http.MethodGet, "/api/devices/device_01/data", nil, "")
+	var notFound models.ErrorResponse
+	decode(t, w, &notFound)
+	if w.Code != http.StatusNotFound || notFound.Error.Code != models.CodeNotFound || notFound.Error.RequestID == "" {
+		t.Errorf("not found = %d %s", w.Code, w.Body.String())
+	}
+}
+
+// update rewrites the golden files of testdata
+var update = flag.Bool("update", false, "rewrite the golden files of testdata")
+
+// getDocument fetches the OpenAPI document at path
+func (e *testEnv) getDocument(t *testing.T, path string) (*openapi.Document, string) {
+	t.Helper()
+	w := e.do(http.MethodGet, path, nil, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("%s = %d", path, w.Code)
+	}
+	var doc openapi.Document
+	decode(t, w, &doc)
+	if doc.OpenAPI != openapi.Version || doc.Components.Schemas["ErrorResponse"] == nil {
+		t.Fatalf("%s = %s %v", path, doc.OpenAPI, doc.Info)
+	}
+	return &doc, w.Body.String()
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	e := newTestEnv(t)
+	v1, _ := e.getDocument(t, "/api/v1/openapi.json")
+	v2, body := e.getDocument(t, "/api/v2/openapi.json")
+	if legacy, _ := e.getDocument(t, "/api/openapi.json"); legacy.Info.Version != "v1" || v2.Info.Version != "v2" {
+		t.Errorf("versions = %s, %s", legacy.Info.Version, v2.Info.Version)
+	}
+
+	// Every registered route is documented and every documented one
+	// registered; /api serves the operations of v1
+	var routes []string
+	for _, r := range e.router.Routes() {
+		routes = append(routes, r.Method+" "+openapi.Path(r.Path))
+	}
+	sort.Strings(routes)
+	var ops []string
+	for _, op := range v1.Operations() {
+		if strings.Contains(op, " /api/v1/") {
+			ops = append(ops, op, strings.Replace(op, " /api/v1/", " /api/", 1))
+		} else {
+			ops = append(ops, op) // root routes
+		}
+	}
+	for _, op := range v2.Operations() {
+		if strings.Contains(op, " /api/v2/") {
+			ops = append(ops, op)
+		}
+	}
+	sort.Strings(ops)
+	if strings.Join(ops, "\n") != strings.Join(routes, "\n") {
+		documented := map[string]bool{}
+		for _, op := range ops {
+			documented[op] = true
+		}
+		for _, r := range routes {
+			if !documented[r] {
+				t.Errorf("route %s is not documented", r)
+			}
+			delete(documented, r)
+		}
+		for op := range documented {
+			t.Errorf("documented %s is not registered", op)
+		}
+	}
+
+	for version, doc := range map[string]*openapi.Document{"v1": v1, "v2": v2} {
+		ids := map[string]bool{}
+		for route, item := range doc.Paths {
+			for method, op := range item {
+				if ids[op.OperationID] || op.OperationID == "" {
+					t.Errorf("%s: operationId %q of %s %s is not unique", version, op.OperationID, method, route)
+				}
+				ids[op.OperationID] = true
+				prefix := "/api/" + version
+				if admin := strings.HasPrefix(route, prefix+"/admin/"); admin != (op.Security != nil) && !strings.HasPrefix(route, prefix+"/auth/me") {
+					t.Errorf("%s %s security = %v", method, route, op.Security)
+				}
+			}
+		}
+	}
+
+	// References resolve and request schemas carry their binding rules
+	for _, ref := range regexp.MustCompile(`"\$ref":"#/components/schemas/([^"]+)"`).FindAllStringSubmatch(body, -1) {
+		if v2.Components.Schemas[ref[1]] == nil {
+			t.Errorf("dangling $ref %s", ref[1])
+		}
+	}
+	data := v2.Components.Schemas["DataPayloadV2"]
+	if data == nil || data.Properties["drowsiness_level"] == nil || strings.Join(data.Properties["drowsiness_level"].Enum, ",") != "low,medium,high" ||
+		*data.Properties["eye_closure"].Maximum != 1 || !strings.Contains(strings.Join(data.Required, ","), "drowsiness_level") ||
+		data.Properties["telemetry"] == nil || data.Properties["head_pose"] != nil {
+		t.Errorf("DataPayloadV2 schema = %+v", data)
+	}
+	if v1.Components.Schemas["DataPayload"] == nil || v1.Components.Schemas["DataPayloadV2"] != nil {
+		t.Errorf("v1 schemas = %v", v1.Components.Schemas)
+	}
+	history := v2.Operation(http.MethodGet, "/api/v2/devices/{id}/history")
+	if history == nil || history.Parameters[0].In != "path" || history.Responses["200"].Content["application/json"].Schema.Properties["items"] == nil {
+		t.Errorf("history operation = %+v", history)
+	}
+
+	for _, path := range []string{"/api/docs", "/api/v2/docs"} {
+		w := e.do(http.MethodGet, path, nil, "")
+		if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") || !strings.Contains(w.Body.String(), "openapi.json") {
+			t.Errorf("%s = %d %s", path, w.Code, w.Header().Get("Content-Type"))
+		}
+	}
+}
+
+// TestOpenAPIV1Frozen compares the contract of v1 with testdata; run
+// go test ./handlers -run V1Frozen -update only for changes v1 clients
+// cannot notice
+func TestOpenAPIV1Frozen(t *testing.T) {
+	e := newTestEnv(t)
+	doc, _ := e.getDocument(t, "/api/v1/openapi.json")
+	frozen := struct {
+		Paths      map[string]openapi.PathItem `json:"paths"`
+		Components openapi.Components          `json:"components"`
+	}{Paths: map[string]openapi.PathItem{}, Components: doc.Components}
+	for path, item := range doc.Paths {
+		if strings.HasPrefix(path, "/api/v1/") {
+			frozen.Paths[path] = item
+		}
+	}
+	got, err := json.MarshalIndent(frozen, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden := filepath.Join("testdata", "openapi_v1.json")
+	if *update {
+		if err := os.WriteFile(golden, append(got, '\n'), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(want) != string(got)+"\n" {
+		t.Errorf("the v1 contract changed; make the change in v2 only, or run with -update if v1 clients cannot notice it")
+	}
+}
+
+func TestAPIVersions(t *testing.T) {
+	e := newTestEnv(t)
+
+	// v1, under /api and /api/v1, announces its deprecation and successor
+	for path, successor := range map[string]string{
+		"/api/devices/device_01/history":    "</api/v2/devices/device_01/history>",
+		"/api/v1/devices/device_01/history": "</api/v2/devices/device_01/history>",
+	} {
+		w := e.do(http.MethodGet, path, nil, "")
+		h := w.Header()
+		if w.Code != http.StatusOK || h.Get("Deprecation") != "@1793491200" || h.Get("Sunset") != "Sat, 01 May 2027 00:00:00 GMT" ||
+			!strings.HasPrefix(h.Get("Link"), successor+`; rel="successor-version"`) {
+			t.Errorf("%s = %d, headers %v", path, w.Code, h)
+		}
+	}
+	if w := e.do(http.MethodGet, "/api/v2/devices/device_01/history", nil, ""); w.Header().Get("Deprecation") != "" || w.Header().Get("Sunset") != "" {
+		t.Errorf("v2 headers = %v", w.Header())
+	}
+
+	// v2 nests the telemetry, as samples are returned
+	w := e.do(http.MethodPost, "/api/v2/devices/device_01/data", gin.H{
+		"eye_closure": 0.4, "drowsiness_level": "medium", "status": "yawning",
+		"telemetry": gin.H{"head_pose": gin.H{"pitch": -12.5, "yaw": 30, "roll": 2}, "mouth": gin.H{"aspect_ratio": 0.8, "yawning": true}},
+	}, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("v2 data = %d %s", w.Code, w.Body.String())
+	}
+	if w := e.do(http.MethodPost, "/api/v2/devices/device_01/data", gin.H{
+		"eye_closure": 0.4, "drowsiness_level": "medium", "telemetry": gin.H{"head_pose": gin.H{"pitch": 400}},
+	}, ""); w.Code != http.StatusBadRequest {
+		t.Errorf("v2 invalid telemetry = %d", w.Code)
+	}
+	e.do(http.MethodPost, "/api/devices/device_01/data", gin.H{"eye_closure": 0.1, "drowsiness_level": "low"}, "")
+
+	// v2 pages name their list "items"; v1 keeps "data"
+	var v2 struct {
+		Items []models.DrowsinessData `json:"items"`
+		Next  *string                 `json:"next"`
+	}
+	decode(t, e.do(http.MethodGet, "/api/v2/devices/device_01/history?limit=1", nil, ""), &v2)
+	if len(v2.Items) != 1 || v2.Next == nil || !strings.HasPrefix(*v2.Next, "/api/v2/devices/device_01/history?") {
+		t.Fatalf("v2 history = %+v", v2)
+	}
+	decode(t, e.do(http.MethodGet, *v2.Next, nil, ""), &v2)
+	if len(v2.Items) != 1 || v2.Items[0].Telemetry == nil || v2.Items[0].Telemetry.HeadPose.Yaw != 30 || v2.Items[0].SchemaVersion != models.TelemetrySchemaV2 {
+		t.Errorf("v2 sample = %+v", v2.Items)
+	}
+	var v1 map[string]json.RawMessage
+	decode(t, e.do(http.MethodGet, "/api/v1/devices/device_01/alerts", nil, ""), &v1)
+	if v1["alerts"] == nil || v1["items"] != nil {
+		t.Errorf("v1 alerts keys = %v", v1)
+	}
+
+	// Usage per version tells when v1 can go
+	e.register("ops@example.com", "device_09")
+	token := e.admin("admin@example.com")
+	// Routes added after v1 froze are not part of it
+	for _, path := range []string{"/api/admin/api-usage", "/api/admin/sessions", "/api/v1/admin/export/history", "/api/v1/devices/device_01/fatigue"} {
+		if w := e.do(http.MethodGet, path, nil, token); w.Code != http.StatusNotFound {
+			t.Errorf("v1 %s = %d, want 404", path, w.Code)
+		}
+	}
+	var usage models.APIUsage
+	decode(t, e.do(http.MethodGet, "/api/v2/admin/api-usage", nil, token), &usage)
+	if len(usage.Versions) != 2 {
+		t.Fatalf("usage = %+v", usage)
+	}
+	old, cur := usage.Versions[0], usage.Versions[1]
+	if old.Version != "v1" || !old.Deprecated || old.Sunset == nil || old.Devices["device_01"] != 4 ||
+		old.Routes["POST /api/devices/:id/data"] != 1 || old.Routes["GET /api/v1/devices/:id/history"] != 1 {
+		t.Errorf("v1 usage = %+v", old)
+	}
+	if cur.Version != "v2" || cur.Deprecated || cur.Routes["POST /api/v2/devices/:id/data"] != 2 || cur.Devices["device_01"] != 5 {
+		t.Errorf("v2 usage = %+v", cur)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	e := newTestEnv(t)
+	for i := 0; i < 2; i++ {
+		e.do(http.MethodPost, "/api/devices/device_01/data", gin.H{"eye_closure": 0.1, "drowsiness_level": "low"}, "")
+	}
+	e.do(http.MethodPost, "/api/v2/devices/device_01/data", gin.H{"eye_closure": 2, "drowsiness_level": "low"}, "")
+	e.do(http.MethodPost, "/api/devices/device_01/alert", gin.H{"alert_type": "drowsy", "severity": "high"}, "")
+	e.do(http.MethodGet, "/api/nowhere", nil, "")
+	run := e.server.TimeJob("test_job", func() error { return nil })
+	if err := run(); err != nil {
+		t.Fatal(err)
+	}
+
+	w := e.do(http.MethodGet, "/metrics", nil, "")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Fatalf("metrics = %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	body := w.Body.String()
+	for _, line := range []string{
+		`http_requests_total{method="POST",route="/api/devices/:id/data",status="200"} 2`,
+		`http_requests_total{method="GET",route="(unmatched)",status="404"} 1`,
+		`http_request_duration_seconds_count{method="POST",route="/api/devices/:id/data"} 2`,
+		`drowsiness_samples_ingested_total{device_id="device_01"} 2`,
+		`drowsiness_payloads_rejected_total{route="/api/v2/devices/:id/data",code="validation_failed"} 1`,
+		`drowsiness_alerts_total{severity="high"} 1`,
+		`drowsiness_devices_online 1`,
+		`drowsiness_job_duration_seconds_count{job="test_job",result="success"} 1`,
+		`# TYPE drowsiness_job_last_success_timestamp_seconds gauge`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("metrics lack %s", line)
+		}
+	}
+
+	e.server.cfg.MetricsToken = "scrape-secret"
+	if w := e.do(http.MethodGet, "/metrics", nil, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("metrics without token = %d", w.Code)
+	}
+	if w := e.do(http.MethodGet, "/metrics", nil, "scrape-secret"); w.Code != http.StatusOK {
+		t.Errorf("metrics with token = %d", w.Code)
+	}
+}
+
+// captureLogs sends the logs of the test, at debug level, to the returned buffer
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	prev, out, flags := slog.Default(), log.Writer(), log.Flags()
+	slog.SetDefault(logging.New(&buf, slog.LevelDebug, "json"))
+	t.Cleanup(func() {
+		slog.SetDefault(prev)
+		log.SetOutput(out)
+		log.SetFlags(flags)
+	})
+	return &buf
+}
+
+func TestRequestLogs(t *testing.T) {
+	e := newTestEnv(t)
+	e.server.logSampler = logging.NewSampler(10 * time.Second)
+	logs := captureLogs(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/devices/device_01/data", strings.NewReader(`{"eye_closure":0.1,"drowsiness_level":"low"}`))
+	req.Header.Set("X-Request-ID", "req-42")
+	e.router.ServeHTTP(httptest.NewRecorder(), req)
+	for i := 0; i < 3; i++ {
+		e.do(http.MethodPost, "/api/devices/device_01/data", gin.H{"eye_closure": 0.1, "drowsiness_level": "low"}, "")
+	}
+	e.do(http.MethodPost, "/api/devices/device_02/data", gin.H{"eye_closure": 0.1, "drowsiness_level": "low"}, "")
+	req = httptest.NewRequest(http.MethodPost, "/api/v2/devices/device_01/sessions/start", strings.NewReader(`{}`))
+	req.Header.Set("X-Request-ID", "req-43")
+	e.router.ServeHTTP(httptest.NewRecorder(), req)
+	e.register("somchai@example.com", "device_03")
+	w := e.do(http.MethodPost, "/api/auth/forgot-password", gin.H{"email": "somchai@example.com"}, "")
+	var reset struct {
+		ResetCode string `json:"reset_code"`
+	}
+	decode(t, w, &reset)
+
+	var received, requests int
+	var started bool
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		var r map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("log line %q: %v", line, err)
+		}
+		switch r["msg"] {
+		case "Data received":
+			received++
+			if received == 1 && r["request_id"] != "req-42" {
+				t.Errorf("data log = %v", r)
+			}
+		case "Driving session started":
+			// Handler logs carry the request ID and structured attributes
+			started = r["request_id"] == "req-43" && r["level"] == "INFO" && r["device_id"] == "device_01" && r["session_id"] != nil
+		case "request":
+			if r["route"] == "/api/devices/:id/data" {
+				requests++
+			}
+		}
+	}
+	// One line per device within the interval
+	if received != 2 || requests != 2 {
+		t.Errorf("%d data and %d request lines, want 2 each", received, requests)
+	}
+	if !started {
+		t.Errorf("no structured session log with the request ID:\n%s", logs.String())
+	}
+	if out := logs.String(); strings.Contains(out, "somchai@example.com") || reset.ResetCode == "" || strings.Contains(out, reset.ResetCode) ||
+		!strings.Contains(out, "s***@example.com") {
+		t.Errorf("logs leak secrets:\n%s", out)
+	}
+}
@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"driver-drowsiness-backend/health"
+	"driver-drowsiness-backend/models"
+	"driver-drowsiness-backend/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// logStateEvent emits a device state transition to the server log
+func logStateEvent(ctx context.Context, e models.DeviceStateEvent) {
+	from := e.FromState
+	if from == "" {
+		from = health.Unknown
+	}
+	attrs := []any{"device_id", e.DeviceID, "from", from, "to", e.ToState}
+	if e.Reason != "" {
+		attrs = append(attrs, "reason", e.Reason)
+	}
+	slog.InfoContext(ctx, "Device state changed", attrs...)
+}
+
+// sweepDevices marks devices whose heartbeat timed out as offline and
+// forgets the fatigue windows of devices that stopped sending samples
+func (s *Server) sweepDevices(ctx context.Context) {
+	now := s.now().UTC()
+	events, err := s.health.Sweep(ctx, now)
+	if err != nil {
+		slog.WarnContext(ctx, "Could not sweep device health", "error", err)
+	}
+	for _, e := range events {
+		logStateEvent(ctx, e)
+	}
+	// A device silent for longer than the session gap starts a new session,
+	// so its fatigue window is of no further use
+	s.fatigue.Sweep(now.Add(-s.tracker.Gap()))
+}
+
+// WatchDevices sweeps device health every interval until ctx is done, so
+// silent devices go offline even when nobody reads the dashboard
+func (s *Server) WatchDevices(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.sweepDevices(ctx)
+			}
+		}
+	}()
+	slog.InfoContext(ctx, "Device health watch scheduled", "timeout", s.health.Thresholds().Timeout.String(), "interval", interval.String())
+}
+
+// ensureDevice registers a device reporting for the first time without
+// bumping last_update, which only drowsiness data does
+func (s *Server) ensureDevice(ctx context.Context, deviceID string, at time.Time) {
+	if _, err := s.store.Devices.Get(ctx, deviceID); errors.Is(err, repository.ErrNotFound) {
+		if err := s.store.Devices.Touch(ctx, deviceID, "unknown@device.local", at); err != nil {
+			slog.WarnContext(ctx, "Could not ensure device exists", "error", err)
+		}
+	}
+}
+
+// ReceiveHeartbeat records the health report of a device and returns its state
+func (s *Server) ReceiveHeartbeat(c *gin.Context) {
+	ctx := c.Request.Context()
+	deviceID := c.Param("id")
+
+	var payload models.HeartbeatPayload
+	if !bindJSON(c, &payload) {
+		return
+	}
+
+	now := s.now().UTC()
+	s.ensureDevice(ctx, deviceID, now)
+	h, event, err := s.health.Heartbeat(ctx, deviceID, payload, now)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error recording heartbeat", "device_id", deviceID, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to record heartbeat")
+		return
+	}
+	if event != nil {
+		logStateEvent(ctx, *event)
+	}
+
+	resp := gin.H{"status": "success", "health": h}
+	// Push the desired configuration to devices running an older version
+	if shadow, err := s.deviceShadow(ctx, deviceID); err != nil {
+		slog.WarnContext(ctx, "Could not fetch config", "device_id", deviceID, "error", err)
+	} else {
+		resp["config_version"] = shadow.Version
+		if payload.ConfigVersion != nil && *payload.ConfigVersion != shadow.Version {
+			resp["config"] = shadow.Desired
+		}
+	}
+	// Hand over pending commands so devices need not poll separately
+	if pending, err := s.queue.Deliver(ctx, deviceID, now); err != nil {
+		slog.WarnContext(ctx, "Could not deliver commands", "device_id", deviceID, "error", err)
+	} else if len(pending) > 0 {
+		resp["commands"] = pending
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetDeviceHealth returns the last heartbeat and current state of a device
+func (s *Server) GetDeviceHealth(c *gin.Context) {
+	noCache(c)
+	ctx := c.Request.Context()
+	s.sweepDevices(ctx)
+
+	h, err := s.store.Health.Get(ctx, c.Param("id"))
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, "No heartbeat from this device")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Error fetching device health", "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to fetch device health")
+		return
+	}
+	c.JSON(http.StatusOK, h)
+}
+
+// ListDeviceEvents returns device state transitions, oldest first.
+// Query: device_id, from, to (RFC3339 or YYYY-MM-DD), limit and tz.
+func (s *Server) ListDeviceEvents(c *gin.Context) {
+	noCache(c)
+	loc, ok := s.requestLocation(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	s.sweepDevices(ctx)
+
+	f := repository.DeviceEventFilter{DeviceID: c.Query("device_id"), Limit: queryLimit(c, 100)}
+	if v := c.Query("from"); v != "" {
+		t, err := parseTimeParam(v, loc)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Invalid from")
+			return
+		}
+		f.Range.From = t.UTC()
+	}
+	if v := c.Query("to"); v != "" {
+		t, err := parseTimeParam(v, loc)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Invalid to")
+			return
+		}
+		f.Range.To = t.UTC()
+	}
+
+	events, err := s.store.Health.Events(ctx, f)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error fetching device events", "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to fetch device events")
+		return
+	}
+	if events == nil {
+		events = []models.DeviceStateEvent{}
+	}
+	c.JSON(http.StatusOK, gin.H{"count": len(events), "events": events})
+}
+
+// AdminDeviceUptime returns how long a device spent in each state.
+// Query: from, to (RFC3339 or YYYY-MM-DD; default the last 24 hours) and tz.
+func (s *Server) AdminDeviceUptime(c *gin.Context) {
+	noCache(c)
+	loc, ok := s.requestLocation(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	deviceID := c.Param("id")
+	s.sweepDevices(ctx)
+
+	now := s.now().UTC()
+	from, to := now.Add(-24*time.Hour), now
+	if v := c.Query("from"); v != "" {
+		t, err := parseTimeParam(v, loc)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Invalid from")
+			return
+		}
+		from = t.UTC()
+	}
+	if v := c.Query("to"); v != "" {
+		t, err := parseTimeParam(v, loc)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Invalid to")
+			return
+		}
+		to = t.UTC()
+	}
+	if to.After(now) {
+		to = now
+	}
+	if !to.After(from) {
+		respondError(c, http.StatusBadRequest, "from must be before to")
+		return
+	}
+
+	initial := health.Unknown
+	prior, err := s.store.Health.LastEventBefore(ctx, deviceID, from)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		slog.ErrorContext(ctx, "Error fetching device events", "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to fetch device uptime")
+		return
+	}
+	if prior != nil {
+		initial = prior.ToState
+	}
+	events, err := s.store.Health.Events(ctx, repository.DeviceEventFilter{
+		DeviceID: deviceID,
+		Range:    repository.TimeRange{From: from, To: to},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error fetching device events", "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to fetch device uptime")
+		return
+	}
+	c.JSON(http.StatusOK, health.Uptime(deviceID, initial, events, from, to))
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AccessLog logs every request with its route, status and duration, in
+// place of gin's text logger. Successful device requests are sampled per
+// device and route: devices report several times a second.
+func (s *Server) AccessLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route, status := c.FullPath(), c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.String("route", route),
+			slog.Int("status", status),
+			slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("client_ip", c.ClientIP()),
+		}
+		if deviceID := c.Param("id"); level == slog.LevelInfo && deviceID != "" &&
+			strings.Contains(route, "/devices/:id") && !strings.Contains(route, "/admin/") {
+			ok, suppressed := s.logSampler.Allow(deviceID+" "+c.Request.Method+" "+route, s.now())
+			if !ok {
+				return
+			}
+			attrs = append(attrs, slog.String("device_id", deviceID), slog.Int("suppressed", suppressed))
+		}
+		slog.LogAttrs(c.Request.Context(), level, "request", attrs...)
+	}
+}
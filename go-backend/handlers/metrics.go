@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime"
+	"strings"
+	"time"
+
+	"driver-drowsiness-backend/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Background jobs timed in job metrics
+const (
+	JobDailyPurge        = "daily_purge" // PurgeOldData, scheduled by main
+	jobEvidenceRetention = "evidence_retention"
+)
+
+// maxDeviceSeries bounds the devices counted by name in the samples
+// counter; the rest are counted as metrics.Other
+const maxDeviceSeries = 500
+
+// serverMetrics are the instruments the server exposes at /metrics
+type serverMetrics struct {
+	registry *metrics.Registry
+	requests *metrics.Counter   // method, route, status
+	latency  *metrics.Histogram // method, route
+	samples  *metrics.Counter   // device_id
+	rejected *metrics.Counter   // route, code
+	alerts   *metrics.Counter   // severity
+	jobs     *metrics.Histogram // job, result
+	jobDone  *metrics.Gauge     // job
+}
+
+func newServerMetrics() *serverMetrics {
+	r := metrics.NewRegistry()
+	m := &serverMetrics{
+		registry: r,
+		requests: r.Counter("http_requests_total", "HTTP requests by route and status.", "method", "route", "status"),
+		latency: r.Histogram("http_request_duration_seconds", "Time to answer HTTP requests by route.",
+			metrics.DefBuckets, "method", "route"),
+		samples: r.Counter("drowsiness_samples_ingested_total",
+			fmt.Sprintf("Drowsiness samples stored, by device; devices past the first %d are counted as other.", maxDeviceSeries),
+			"device_id").Limit(maxDeviceSeries),
+		rejected: r.Counter("drowsiness_payloads_rejected_total", "Device payloads answered with a 4xx, by route and error code.", "route", "code"),
+		alerts:   r.Counter("drowsiness_alerts_total", "Alerts raised, by severity.", "severity"),
+		jobs: r.Histogram("drowsiness_job_duration_seconds", "Run time of background jobs.",
+			[]float64{0.1, 0.5, 1, 5, 15, 60, 300, 900}, "job", "result"),
+		jobDone: r.Gauge("drowsiness_job_last_success_timestamp_seconds", "Unix time the job last succeeded.", "job"),
+	}
+	r.GaugeFunc("go_goroutines", "Number of goroutines.", func(set func(float64, ...string)) {
+		set(float64(runtime.NumGoroutine()))
+	})
+	return m
+}
+
+// collectOnline reports the devices online at each scrape
+func (s *Server) collectOnline() {
+	s.metrics.registry.GaugeFunc("drowsiness_devices_online", "Devices online now.", func(set func(float64, ...string)) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		n, err := s.store.Dashboard.OnlineDevices(ctx, s.now().UTC())
+		if err != nil {
+			slog.WarnContext(ctx, "Could not count online devices", "error", err)
+			return
+		}
+		set(float64(n))
+	})
+}
+
+// CollectDBStats reports the connection pool of db at each scrape
+func (s *Server) CollectDBStats(db *sql.DB) {
+	r := s.metrics.registry
+	stat := func(name, help string, counter bool, value func(sql.DBStats) float64) {
+		collect := func(set func(float64, ...string)) { set(value(db.Stats())) }
+		if counter {
+			r.CounterFunc(name, help, collect)
+		} else {
+			r.GaugeFunc(name, help, collect)
+		}
+	}
+	stat("db_pool_max_open_connections", "Maximum open connections to the database.", false,
+		func(st sql.DBStats) float64 { return float64(st.MaxOpenConnections) })
+	stat("db_pool_open_connections", "Open connections, in use or idle.", false,
+		func(st sql.DBStats) float64 { return float64(st.OpenConnections) })
+	stat("db_pool_in_use_connections", "Connections in use.", false,
+		func(st sql.DBStats) float64 { return float64(st.InUse) })
+	stat("db_pool_idle_connections", "Idle connections.", false,
+		func(st sql.DBStats) float64 { return float64(st.Idle) })
+	stat("db_pool_wait_count_total", "Connections waited for.", true,
+		func(st sql.DBStats) float64 { return float64(st.WaitCount) })
+	stat("db_pool_wait_duration_seconds_total", "Time spent waiting for connections.", true,
+		func(st sql.DBStats) float64 { return st.WaitDuration.Seconds() })
+	stat("db_pool_max_idle_closed_total", "Connections closed for exceeding the idle limits.", true,
+		func(st sql.DBStats) float64 { return float64(st.MaxIdleClosed + st.MaxIdleTimeClosed) })
+	stat("db_pool_max_lifetime_closed_total", "Connections closed for exceeding their lifetime.", true,
+		func(st sql.DBStats) float64 { return float64(st.MaxLifetimeClosed) })
+}
+
+// TimeJob returns run timed as job in the job metrics
+func (s *Server) TimeJob(job string, run func() error) func() error {
+	return func() error {
+		start := time.Now()
+		err := run()
+		result := "success"
+		if err != nil {
+			result = "error"
+		} else {
+			s.metrics.jobDone.Set(float64(time.Now().Unix()), job)
+		}
+		s.metrics.jobs.Observe(time.Since(start).Seconds(), job, result)
+		return err
+	}
+}
+
+// Instrument counts and times every request by route and status, and the
+// device payloads rejected with a 4xx
+func (s *Server) Instrument() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "(unmatched)" // keeps unknown paths from adding series
+		}
+		method, status := c.Request.Method, c.Writer.Status()
+		s.metrics.requests.Inc(method, route, fmt.Sprint(status))
+		s.metrics.latency.Observe(time.Since(start).Seconds(), method, route)
+		if method == http.MethodPost && status >= 400 && status < 500 && strings.Contains(route, "/devices/:id/") {
+			s.metrics.rejected.Inc(route, c.GetString("error_code"))
+		}
+	}
+}
+
+// Metrics serves the metrics in the Prometheus text format; with
+// METRICS_TOKEN set, scrapers must send it as a bearer token
+func (s *Server) Metrics(c *gin.Context) {
+	if token := s.cfg.MetricsToken; token != "" {
+		got := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			respondError(c, http.StatusUnauthorized, "Invalid metrics token")
+			return
+		}
+	}
+	c.Header("Content-Type", metrics.ContentType)
+	c.Status(http.StatusOK)
+	if _, err := s.metrics.registry.WriteTo(c.Writer); err != nil {
+		slog.WarnContext(c.Request.Context(), "Could not write metrics", "error", err)
+	}
+}
@@ -0,0 +1,406 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"driver-drowsiness-backend/geo"
+	"driver-drowsiness-backend/models"
+	"driver-drowsiness-backend/openapi"
+
+	"github.com/gin-gonic/gin"
+)
+
+// queryParams documents the query parameters shared by the routes
+var queryParams = map[string]openapi.Parameter{
+	"limit":       openapi.Query("limit", "integer", "Maximum number of items"),
+	"cursor":      openapi.Query("cursor", "string", "Opaque cursor taken from next or prev of a previous page"),
+	"from":        openapi.Query("from", "string", "Start of the range, RFC3339 or YYYY-MM-DD"),
+	"to":          openapi.Query("to", "string", "End of the range (exclusive), RFC3339 or YYYY-MM-DD"),
+	"tz":          openapi.Query("tz", "string", "IANA timezone of dates and day boundaries, e.g. Asia/Bangkok"),
+	"level":       openapi.Query("level", "string", "Drowsiness level: low, medium or high"),
+	"levels":      openapi.Query("level", "string", "Comma separated drowsiness levels, default medium,high"),
+	"severity":    openapi.Query("severity", "string", "Alert severity"),
+	"status":      openapi.Query("status", "string", "Status to filter by"),
+	"device_id":   openapi.Query("device_id", "string", "Device to filter by"),
+	"driver_id":   openapi.Query("driver_id", "integer", "Driver to filter by"),
+	"fleet_id":    openapi.Query("fleet_id", "integer", "Fleet to filter by"),
+	"vehicle_id":  openapi.Query("vehicle_id", "integer", "Vehicle to filter by"),
+	"user_id":     openapi.Query("user_id", "integer", "User to filter by"),
+	"geofence_id": openapi.Query("geofence_id", "integer", "Geofence to filter by"),
+	"bucket":      openapi.Query("bucket", "string", "Bucket width: 15m, 1h, 1d or 1w"),
+	"group_by":    openapi.Query("group_by", "string", "Series per fleet, driver, device or vehicle"),
+	"format":      openapi.Query("format", "string", "csv or xlsx"),
+	"lang":        openapi.Query("lang", "string", "th or en, default from Accept-Language"),
+	"sync":        openapi.Query("sync", "string", "in_sync, pending, drift or never_reported"),
+	"kind":        openapi.Query("kind", "string", "fleet or driver"),
+	"wait":        openapi.Query("wait", "integer", "Seconds to hold the request open for a command, at most 60"),
+	"tolerance":   openapi.Query("tolerance", "number", "Simplification tolerance in meters, default 10"),
+	"expires":     openapi.Query("expires", "integer", "Expiry of the signed URL, Unix seconds"),
+	"signature":   openapi.Query("signature", "string", "Signature of the URL"),
+}
+
+// query returns the documented query parameters of names
+func query(names ...string) []openapi.Parameter {
+	params := make([]openapi.Parameter, len(names))
+	for i, name := range names {
+		p, ok := queryParams[name]
+		if !ok {
+			panic(fmt.Sprintf("undocumented query parameter %q", name))
+		}
+		params[i] = p
+	}
+	return params
+}
+
+var (
+	// pageLink is the URL of the next or previous page, null at the ends
+	pageLink = &openapi.Schema{Type: "string", Nullable: true}
+	// deleted is the answer of a delete by id
+	deleted = openapi.Fields{"success": true, "id": 0}
+	// message is the answer of an action without a resource
+	message = openapi.Fields{"success": true, "message": ""}
+	// collection is a GeoJSON FeatureCollection
+	collection = geo.FeatureCollection{}
+)
+
+// rootOperations describes the unversioned routes outside /api
+func rootOperations() []openapi.Op {
+	return []openapi.Op{
+		{ID: "root", Method: "GET", Path: "/", Tag: "System", Summary: "API name, version and main endpoints",
+			Response: openapi.Fields{"app": "", "version": "", "endpoints": []string{}, "api_versions": map[string]string{}, "time": ""}},
+		{ID: "healthCheck", Method: "GET", Path: "/health", Tag: "System", Summary: "Liveness check",
+			Response: openapi.Fields{"status": "", "message": "", "time": ""}},
+		{ID: "devToolsManifest", Method: "GET", Path: "/.well-known/appspecific/com.chrome.devtools.json", Tag: "System",
+			Summary: "Empty manifest for Chrome devtools probing", Response: openapi.Fields{"version": 0, "targets": []openapi.Fields{}}},
+		{ID: "metrics", Method: "GET", Path: "/metrics", Tag: "System", Summary: "Prometheus metrics; bearer METRICS_TOKEN when set",
+			Produces: []string{"text/plain"}},
+	}
+}
+
+// apiOperations describes the routes registerAPI mounts for version, with
+// paths relative to the root of the version
+func apiOperations(version string) []openapi.Op {
+	v2 := version != apiV1
+	var dataPayload interface{} = models.DataPayload{}
+	history := openapi.Op{ID: "getHistory", Method: "GET", Path: "/devices/:id/history", Tag: "Devices", Summary: "Latest samples, newest first",
+		Params: query("limit"), Response: openapi.Fields{"device_id": "", "count": 0, "data": []models.DrowsinessData{}}}
+	alerts := openapi.Op{ID: "getAlerts", Method: "GET", Path: "/devices/:id/alerts", Tag: "Devices", Summary: "Latest alerts, newest first",
+		Params: query("limit"), Response: openapi.Fields{"device_id": "", "count": 0, "alerts": []models.Alert{}}}
+	if v2 {
+		dataPayload = models.DataPayloadV2{}
+		history.Summary, alerts.Summary = "Page of samples, newest first", "Page of alerts, newest first"
+		history.Params = query("limit", "cursor", "from", "to", "level", "status", "tz")
+		alerts.Params = query("limit", "cursor", "from", "to", "severity", "status", "tz")
+		history.Response = openapi.Fields{"device_id": "", "count": 0, "items": []models.DrowsinessData{}, "next": pageLink, "prev": pageLink}
+		alerts.Response = openapi.Fields{"device_id": "", "count": 0, "items": []models.Alert{}, "next": pageLink, "prev": pageLink}
+	}
+	ops := []openapi.Op{
+		// System
+		{ID: "apiHealthCheck", Method: "GET", Path: "/health", Tag: "System", Summary: "Liveness check",
+			Response: openapi.Fields{"status": "", "message": "", "time": ""}},
+		{ID: "openAPI", Method: "GET", Path: "/openapi.json", Tag: "System", Summary: "This OpenAPI document",
+			Response: &openapi.Schema{Type: "object"}},
+		{ID: "docs", Method: "GET", Path: "/docs", Tag: "System", Summary: "Browsable documentation of the API",
+			Produces: []string{"text/html"}},
+		{ID: "seedAdmin", Method: "POST", Path: "/seed/admin", Tag: "System", Summary: "Create the first admin account",
+			Body: models.SeedAdminRequest{}, Status: http.StatusCreated, Response: openapi.Fields{"success": true, "message": "", "user_id": 0}},
+
+		// Auth
+		{ID: "register", Method: "POST", Path: "/auth/register", Tag: "Auth", Summary: "Create an account",
+			Body: models.RegisterRequest{}, Status: http.StatusCreated, Response: models.AuthResponse{}},
+		{ID: "login", Method: "POST", Path: "/auth/login", Tag: "Auth", Summary: "Sign in and get a token",
+			Body: models.LoginRequest{}, Response: models.AuthResponse{}},
+		{ID: "me", Method: "GET", Path: "/auth/me", Tag: "Auth", Auth: true, Summary: "The signed-in user",
+			Response: models.Profile{}},
+		{ID: "forgotPassword", Method: "POST", Path: "/auth/forgot-password", Tag: "Auth", Summary: "Send a password reset code",
+			Body: models.ForgotPasswordRequest{}, Response: openapi.Fields{"success": true, "message": "", "reset_code": ""}},
+		{ID: "resetPassword", Method: "POST", Path: "/auth/reset-password", Tag: "Auth", Summary: "Set a new password with a reset code",
+			Body: models.ResetPasswordRequest{}, Response: message},
+
+		// Devices
+		{ID: "listDevices", Method: "GET", Path: "/devices", Tag: "Devices", Summary: "Every device",
+			Response: openapi.Fields{"count": 0, "devices": []models.Device{}}},
+		{ID: "sendData", Method: "POST", Path: "/devices/:id/data", Tag: "Devices", Summary: "Report a drowsiness sample",
+			Body: dataPayload, Response: openapi.Fields{
+				"success": true, "message": "", "device_id": "", "fatigue_score": 0.0, "fatigue_level": "",
+				"compliance": &models.ComplianceStatus{},
+			}},
+		{ID: "sendAlert", Method: "POST", Path: "/devices/:id/alert", Tag: "Devices", Summary: "Report an alert",
+			Body: models.AlertPayload{}, Response: openapi.Fields{"success": true, "message": "", "device_id": "", "alert_id": 0}},
+		{ID: "getLatestData", Method: "GET", Path: "/devices/:id/data", Tag: "Devices", Summary: "Latest sample of a device",
+			Response: models.DrowsinessData{}},
+		history,
+		alerts,
+
+		// Dashboard
+		{ID: "adminOverview", Method: "GET", Path: "/admin/overview", Tag: "Dashboard", Auth: true, Summary: "Totals of today",
+			Params: query("tz"), Response: models.AdminOverview{}},
+		{ID: "adminDrivers", Method: "GET", Path: "/admin/drivers", Tag: "Dashboard", Auth: true, Summary: "Drivers with online status and alerts of today",
+			Params: query("tz"), Response: openapi.Fields{"drivers": []models.AdminDriverSummary{}}},
+		{ID: "adminRecentAlerts", Method: "GET", Path: "/admin/recent-alerts", Tag: "Dashboard", Auth: true, Summary: "Recent medium and high events",
+			Params: query("limit", "tz"), Response: openapi.Fields{"alerts": []models.AdminRecentAlert{}}},
+		{ID: "adminAlertSlots", Method: "GET", Path: "/admin/alert-slots", Tag: "Dashboard", Auth: true, Summary: "High alerts of today per 2-hour slot",
+			Params:   query("tz"),
+			Response: openapi.Fields{"slots": []models.AdminAlertSlot{}, "total_high": 0, "peak_slot": "", "peak_count": 0}},
+		{ID: "adminAlertLevels", Method: "GET", Path: "/admin/alert-levels", Tag: "Dashboard", Auth: true, Summary: "Share of alert levels today",
+			Params: query("tz"), Response: models.AdminAlertLevelSummary{}},
+	}
+	if v2 {
+		ops = append(ops, v2Operations()...)
+	}
+	return ops
+}
+
+// v2Operations describes the routes registerV2 mounts
+func v2Operations() []openapi.Op {
+	return []openapi.Op{
+		// System
+
+		// Auth
+		{ID: "listMyFaces", Method: "GET", Path: "/auth/me/face", Tag: "Auth", Auth: true, Summary: "Face references of the signed-in user",
+			Response: openapi.Fields{"user_id": 0, "count": 0, "embeddings": []models.FaceEmbedding{}}},
+		{ID: "enrollMyFace", Method: "POST", Path: "/auth/me/face", Tag: "Auth", Auth: true, Summary: "Enroll a face embedding computed on the device",
+			Body: models.FaceEnrollRequest{}, Status: http.StatusCreated, Response: models.FaceEmbedding{}},
+		{ID: "deleteMyFace", Method: "DELETE", Path: "/auth/me/face/:id", Tag: "Auth", Auth: true, Summary: "Delete a face reference",
+			Response: deleted},
+
+		// Devices
+		{ID: "getFatigue", Method: "GET", Path: "/devices/:id/fatigue", Tag: "Devices", Summary: "Live fatigue metrics",
+			Response: openapi.Fields{"device_id": "", "fatigue": models.FatigueMetrics{}}},
+		{ID: "getCompliance", Method: "GET", Path: "/devices/:id/compliance", Tag: "Devices", Summary: "Driving time of the current driver against the limits",
+			Response: models.ComplianceStatus{}},
+		{ID: "heartbeat", Method: "POST", Path: "/devices/:id/heartbeat", Tag: "Devices", Summary: "Report device health; answers pending config and commands",
+			Body: models.HeartbeatPayload{}, Response: openapi.Fields{
+				"status": "", "health": models.DeviceHealth{}, "config_version": 0, "config": &models.DeviceConfig{},
+				"commands": []models.DeviceCommand{},
+			}},
+		{ID: "getHealth", Method: "GET", Path: "/devices/:id/health", Tag: "Devices", Summary: "Last heartbeat and state",
+			Response: models.DeviceHealth{}},
+		{ID: "getConfig", Method: "GET", Path: "/devices/:id/config", Tag: "Devices", Summary: "Desired configuration",
+			Response: openapi.Fields{"version": 0, "config": models.DeviceConfig{}}},
+		{ID: "reportConfig", Method: "POST", Path: "/devices/:id/config/reported", Tag: "Devices", Summary: "Report the configuration the device applied",
+			Body: models.ConfigReport{}, Response: models.DeviceShadow{}},
+		{ID: "pollCommands", Method: "GET", Path: "/devices/:id/commands", Tag: "Devices", Summary: "Long poll for remote commands",
+			Params: query("wait"), Response: openapi.Fields{"count": 0, "commands": []models.DeviceCommand{}}},
+		{ID: "ackCommand", Method: "POST", Path: "/devices/:id/commands/:command_id/ack", Tag: "Devices", Summary: "Report the outcome of a command",
+			Body: models.CommandAck{}, Response: models.DeviceCommand{}},
+		{ID: "uploadEvidence", Method: "POST", Path: "/devices/:id/alerts/:alert_id/evidence", Tag: "Devices", Summary: "Upload the evidence of an alert in one request",
+			BodyType: "multipart/form-data", Body: &openapi.Schema{Type: "object", Required: []string{"file"}, Properties: map[string]*openapi.Schema{
+				"file": openapi.Binary, "poster": openapi.Binary,
+			}}, Status: http.StatusCreated, Response: models.Evidence{}},
+		{ID: "startEvidenceUpload", Method: "POST", Path: "/devices/:id/alerts/:alert_id/uploads", Tag: "Devices", Summary: "Start a resumable evidence upload",
+			Body: models.EvidenceUploadRequest{}, Status: http.StatusCreated, Response: models.EvidenceUpload{}},
+		{ID: "getEvidenceUpload", Method: "GET", Path: "/devices/:id/uploads/:upload_id", Tag: "Devices", Summary: "Progress of a resumable upload",
+			Response: models.EvidenceUpload{}},
+		{ID: "uploadEvidenceChunk", Method: "PUT", Path: "/devices/:id/uploads/:upload_id", Tag: "Devices",
+			Summary: "Send the next chunk; 200 with the progress, 201 with the evidence after the last",
+			Params:  []openapi.Parameter{openapi.Header("Upload-Offset", "integer", "Bytes received so far")},
+			Body:    openapi.Binary, BodyType: "application/octet-stream", Status: http.StatusCreated, Response: models.Evidence{}},
+		{ID: "startSession", Method: "POST", Path: "/devices/:id/sessions/start", Tag: "Devices", Summary: "Start a driving session",
+			Body: models.SessionEventPayload{}, Status: http.StatusCreated, Response: models.DrivingSession{}},
+		{ID: "stopSession", Method: "POST", Path: "/devices/:id/sessions/stop", Tag: "Devices", Summary: "Stop the open driving session",
+			Body: models.SessionEventPayload{}, Response: models.DrivingSession{}},
+		{ID: "getDeviceSessions", Method: "GET", Path: "/devices/:id/sessions", Tag: "Devices", Summary: "Driving sessions of a device, newest first",
+			Params: query("from", "to", "limit", "tz"), Response: openapi.Fields{"count": 0, "sessions": []models.DrivingSession{}}},
+		{ID: "serveEvidence", Method: "GET", Path: "/evidence/:id/:variant", Tag: "Evidence", Summary: "Evidence file or thumbnail by signed URL",
+			Params: query("expires", "signature"), Produces: []string{"*/*"}},
+
+		// Dashboard
+		{ID: "adminAnalytics", Method: "GET", Path: "/admin/analytics/alerts", Tag: "Dashboard", Auth: true, Summary: "Zero-filled time series of samples",
+			Params: query("from", "to", "bucket", "group_by", "levels", "fleet_id", "driver_id", "device_id", "tz"),
+			Response: openapi.Fields{
+				"from": "", "to": "", "timezone": "", "bucket": "", "group_by": "", "levels": []string{},
+				"buckets": []string{}, "series": []models.AnalyticsSeries{},
+			}},
+
+		// Exports and reports
+		{ID: "exportHistory", Method: "GET", Path: "/admin/export/history", Tag: "Reports", Auth: true, Summary: "CSV or XLSX of samples",
+			Params: query("format", "lang", "fleet_id", "driver_id", "device_id", "from", "to", "tz"), Produces: exportTypes},
+		{ID: "exportAlerts", Method: "GET", Path: "/admin/export/alerts", Tag: "Reports", Auth: true, Summary: "CSV or XLSX of alerts",
+			Params: query("format", "lang", "fleet_id", "driver_id", "device_id", "from", "to", "tz"), Produces: exportTypes},
+		{ID: "createReport", Method: "POST", Path: "/admin/reports", Tag: "Reports", Auth: true, Summary: "Generate a PDF safety report",
+			Body: models.ReportRequest{}, Status: http.StatusCreated, Response: models.Report{}},
+		{ID: "listReports", Method: "GET", Path: "/admin/reports", Tag: "Reports", Auth: true, Summary: "Generated reports, newest first",
+			Params: query("kind", "fleet_id", "driver_id", "limit"), Response: openapi.Fields{"count": 0, "reports": []models.Report{}}},
+		{ID: "getReport", Method: "GET", Path: "/admin/reports/:id", Tag: "Reports", Auth: true, Summary: "One report",
+			Response: models.Report{}},
+		{ID: "downloadReport", Method: "GET", Path: "/admin/reports/:id/pdf", Tag: "Reports", Auth: true, Summary: "PDF of a ready report",
+			Produces: []string{"application/pdf"}},
+		{ID: "deleteReport", Method: "DELETE", Path: "/admin/reports/:id", Tag: "Reports", Auth: true, Summary: "Delete a report",
+			Response: deleted},
+		{ID: "createSubscription", Method: "POST", Path: "/admin/subscriptions", Tag: "Reports", Auth: true, Summary: "Subscribe to a digest",
+			Body: models.SubscriptionRequest{}, Status: http.StatusCreated, Response: models.Subscription{}},
+		{ID: "listSubscriptions", Method: "GET", Path: "/admin/subscriptions", Tag: "Reports", Auth: true, Summary: "Digest subscriptions",
+			Params: query("user_id", "fleet_id"), Response: openapi.Fields{"count": 0, "subscriptions": []models.Subscription{}}},
+		{ID: "getSubscription", Method: "GET", Path: "/admin/subscriptions/:id", Tag: "Reports", Auth: true, Summary: "One subscription",
+			Response: models.Subscription{}},
+		{ID: "updateSubscription", Method: "PUT", Path: "/admin/subscriptions/:id", Tag: "Reports", Auth: true, Summary: "Change a subscription",
+			Body: models.SubscriptionRequest{}, Response: models.Subscription{}},
+		{ID: "deleteSubscription", Method: "DELETE", Path: "/admin/subscriptions/:id", Tag: "Reports", Auth: true, Summary: "Unsubscribe",
+			Response: deleted},
+		{ID: "listDeliveries", Method: "GET", Path: "/admin/subscriptions/:id/deliveries", Tag: "Reports", Auth: true, Summary: "Deliveries of a subscription, newest first",
+			Params: query("limit"), Response: openapi.Fields{"count": 0, "deliveries": []models.Delivery{}}},
+
+		// Fleets, vehicles and shifts
+		{ID: "listFleets", Method: "GET", Path: "/admin/fleets", Tag: "Fleets", Auth: true, Summary: "Every fleet",
+			Response: openapi.Fields{"count": 0, "fleets": []models.Fleet{}}},
+		{ID: "createFleet", Method: "POST", Path: "/admin/fleets", Tag: "Fleets", Auth: true, Summary: "Create a fleet",
+			Body: models.FleetRequest{}, Status: http.StatusCreated, Response: models.Fleet{}},
+		{ID: "updateFleet", Method: "PUT", Path: "/admin/fleets/:id", Tag: "Fleets", Auth: true, Summary: "Rename a fleet or change its timezone",
+			Body: models.FleetRequest{}, Response: models.Fleet{}},
+		{ID: "listVehicles", Method: "GET", Path: "/admin/vehicles", Tag: "Fleets", Auth: true, Summary: "Every vehicle with its installed device",
+			Response: openapi.Fields{"count": 0, "vehicles": []models.Vehicle{}}},
+		{ID: "createVehicle", Method: "POST", Path: "/admin/vehicles", Tag: "Fleets", Auth: true, Summary: "Create a vehicle",
+			Body: models.VehicleRequest{}, Status: http.StatusCreated, Response: models.Vehicle{}},
+		{ID: "getVehicle", Method: "GET", Path: "/admin/vehicles/:id", Tag: "Fleets", Auth: true, Summary: "One vehicle",
+			Response: models.Vehicle{}},
+		{ID: "updateVehicle", Method: "PUT", Path: "/admin/vehicles/:id", Tag: "Fleets", Auth: true, Summary: "Change a vehicle",
+			Body: models.VehicleRequest{}, Response: models.Vehicle{}},
+		{ID: "installDevice", Method: "POST", Path: "/admin/vehicles/:id/devices", Tag: "Fleets", Auth: true, Summary: "Install a device in a vehicle",
+			Body: models.InstallRequest{}, Status: http.StatusCreated, Response: models.DeviceInstallation{}},
+		{ID: "removeDevice", Method: "DELETE", Path: "/admin/vehicles/:id/devices/:device_id", Tag: "Fleets", Auth: true, Summary: "Remove a device from a vehicle",
+			Response: openapi.Fields{"message": ""}},
+		{ID: "listInstallations", Method: "GET", Path: "/admin/installations", Tag: "Fleets", Auth: true, Summary: "Installation history, newest first",
+			Params: query("device_id", "vehicle_id"), Response: openapi.Fields{"count": 0, "installations": []models.DeviceInstallation{}}},
+		{ID: "listShifts", Method: "GET", Path: "/admin/shifts", Tag: "Fleets", Auth: true, Summary: "Shifts, latest start first",
+			Params: query("driver_id", "vehicle_id", "device_id", "from", "to", "limit", "tz"), Response: openapi.Fields{"count": 0, "shifts": []models.Shift{}}},
+		{ID: "createShift", Method: "POST", Path: "/admin/shifts", Tag: "Fleets", Auth: true, Summary: "Schedule a shift",
+			Params: query("tz"), Body: models.ShiftRequest{}, Status: http.StatusCreated, Response: models.Shift{}},
+		{ID: "getShift", Method: "GET", Path: "/admin/shifts/:id", Tag: "Fleets", Auth: true, Summary: "One shift",
+			Response: models.Shift{}},
+		{ID: "updateShift", Method: "PUT", Path: "/admin/shifts/:id", Tag: "Fleets", Auth: true, Summary: "Change a shift",
+			Params: query("tz"), Body: models.ShiftRequest{}, Response: models.Shift{}},
+		{ID: "endShift", Method: "POST", Path: "/admin/shifts/:id/end", Tag: "Fleets", Auth: true, Summary: "End a shift now",
+			Response: models.Shift{}},
+		{ID: "deleteShift", Method: "DELETE", Path: "/admin/shifts/:id", Tag: "Fleets", Auth: true, Summary: "Delete a shift",
+			Response: openapi.Fields{"message": ""}},
+
+		// Sessions, maps and geofences
+		{ID: "adminSessions", Method: "GET", Path: "/admin/sessions", Tag: "Sessions", Auth: true, Summary: "Driving sessions, newest first",
+			Params: query("driver_id", "device_id", "from", "to", "limit", "tz"), Response: openapi.Fields{"count": 0, "sessions": []models.DrivingSession{}}},
+		{ID: "adminSessionDetail", Method: "GET", Path: "/admin/sessions/:id", Tag: "Sessions", Auth: true, Summary: "A session with its samples and alerts",
+			Response: models.SessionDetail{}},
+		{ID: "adminSessionTrack", Method: "GET", Path: "/admin/sessions/:id/track", Tag: "Sessions", Auth: true, Summary: "GeoJSON track of a session",
+			Params: query("tolerance", "tz"), Response: collection},
+		{ID: "adminGeoAlerts", Method: "GET", Path: "/admin/geo/alerts", Tag: "Sessions", Auth: true, Summary: "GeoJSON of located alerts",
+			Params: query("from", "to", "driver_id", "fleet_id", "device_id", "tz"), Response: collection},
+		{ID: "listGeofences", Method: "GET", Path: "/admin/geofences", Tag: "Sessions", Auth: true, Summary: "Every geofence",
+			Response: openapi.Fields{"count": 0, "geofences": []models.Geofence{}}},
+		{ID: "createGeofence", Method: "POST", Path: "/admin/geofences", Tag: "Sessions", Auth: true, Summary: "Create a geofence",
+			Body: models.GeofenceRequest{}, Status: http.StatusCreated, Response: models.Geofence{}},
+		{ID: "listGeofenceEvents", Method: "GET", Path: "/admin/geofences/events", Tag: "Sessions", Auth: true, Summary: "Enter and exit events, newest first",
+			Params: query("device_id", "geofence_id", "from", "to", "limit", "tz"), Response: openapi.Fields{"count": 0, "events": []models.GeofenceEvent{}}},
+		{ID: "getGeofence", Method: "GET", Path: "/admin/geofences/:id", Tag: "Sessions", Auth: true, Summary: "One geofence",
+			Response: models.Geofence{}},
+		{ID: "updateGeofence", Method: "PUT", Path: "/admin/geofences/:id", Tag: "Sessions", Auth: true, Summary: "Change a geofence",
+			Body: models.GeofenceRequest{}, Response: models.Geofence{}},
+		{ID: "deleteGeofence", Method: "DELETE", Path: "/admin/geofences/:id", Tag: "Sessions", Auth: true, Summary: "Delete a geofence",
+			Response: openapi.Fields{"success": true}},
+
+		// Compliance
+		{ID: "adminCompliance", Method: "GET", Path: "/admin/compliance", Tag: "Compliance", Auth: true, Summary: "Hours-of-service per driver and fleet",
+			Params: query("from", "to", "driver_id", "fleet_id", "tz"),
+			Response: openapi.Fields{
+				"from": "", "to": "", "timezone": "",
+				"rules":   openapi.Fields{"max_continuous_seconds": 0.0, "min_break_seconds": 0.0, "max_daily_seconds": 0.0},
+				"drivers": []models.DriverCompliance{}, "fleets": []models.FleetCompliance{},
+			}},
+
+		// Device management
+		{ID: "listDeviceEvents", Method: "GET", Path: "/admin/device-events", Tag: "Device management", Auth: true, Summary: "Device state transitions, oldest first",
+			Params: query("device_id", "from", "to", "limit", "tz"), Response: openapi.Fields{"count": 0, "events": []models.DeviceStateEvent{}}},
+		{ID: "adminDeviceUptime", Method: "GET", Path: "/admin/devices/:id/uptime", Tag: "Device management", Auth: true, Summary: "Time a device spent in each state",
+			Params: query("from", "to", "tz"), Response: models.DeviceUptime{}},
+		{ID: "adminDeviceConfigs", Method: "GET", Path: "/admin/device-configs", Tag: "Device management", Auth: true, Summary: "Configuration shadows with a count per sync state",
+			Params: query("fleet_id", "sync"), Response: openapi.Fields{"count": 0, "summary": map[string]int{}, "devices": []models.DeviceShadow{}}},
+		{ID: "adminGetDeviceConfig", Method: "GET", Path: "/admin/devices/:id/config", Tag: "Device management", Auth: true, Summary: "Desired and reported configuration",
+			Response: models.DeviceShadow{}},
+		{ID: "adminUpdateDeviceConfig", Method: "PUT", Path: "/admin/devices/:id/config", Tag: "Device management", Auth: true, Summary: "Change the desired configuration",
+			Body: models.DeviceConfigRequest{}, Response: models.DeviceShadow{}},
+		{ID: "enqueueCommand", Method: "POST", Path: "/admin/devices/:id/commands", Tag: "Device management", Auth: true, Summary: "Queue a remote command",
+			Body: models.CommandRequest{}, Status: http.StatusCreated, Response: models.DeviceCommand{}},
+		{ID: "listDeviceCommands", Method: "GET", Path: "/admin/devices/:id/commands", Tag: "Device management", Auth: true, Summary: "Commands of a device, newest first",
+			Params: query("status", "from", "to", "limit", "tz"), Response: openapi.Fields{"count": 0, "commands": []models.DeviceCommand{}}},
+		{ID: "getCommand", Method: "GET", Path: "/admin/commands/:id", Tag: "Device management", Auth: true, Summary: "A command with its audit trail",
+			Response: openapi.Fields{"command": models.DeviceCommand{}, "events": []models.CommandEvent{}}},
+		{ID: "cancelCommand", Method: "POST", Path: "/admin/commands/:id/cancel", Tag: "Device management", Auth: true, Summary: "Withdraw an unacknowledged command",
+			Response: models.DeviceCommand{}},
+
+		// Evidence and identity
+		{ID: "adminAlertEvidence", Method: "GET", Path: "/admin/alerts/:id/evidence", Tag: "Evidence", Auth: true, Summary: "Evidence of an alert with signed URLs",
+			Response: openapi.Fields{"alert_id": 0, "count": 0, "evidence": []models.Evidence{}}},
+		{ID: "adminListEvidence", Method: "GET", Path: "/admin/evidence", Tag: "Evidence", Auth: true, Summary: "Evidence, newest first, with signed URLs",
+			Params: query("device_id", "from", "to", "limit", "tz"), Response: openapi.Fields{"count": 0, "evidence": []models.Evidence{}}},
+		{ID: "adminDeleteEvidence", Method: "DELETE", Path: "/admin/evidence/:id", Tag: "Evidence", Auth: true, Summary: "Delete evidence and its files",
+			Response: deleted},
+		{ID: "adminDriverFaces", Method: "GET", Path: "/admin/drivers/:id/face", Tag: "Evidence", Auth: true, Summary: "Face references of a driver",
+			Response: openapi.Fields{"user_id": 0, "count": 0, "embeddings": []models.FaceEmbedding{}}},
+		{ID: "adminResetDriverFaces", Method: "DELETE", Path: "/admin/drivers/:id/face", Tag: "Evidence", Auth: true, Summary: "Delete every face reference of a driver",
+			Response: openapi.Fields{"success": true, "user_id": 0}},
+
+		// System
+		{ID: "adminAPIUsage", Method: "GET", Path: "/admin/api-usage", Tag: "System", Auth: true,
+			Summary: "Requests per API version since the server started", Response: models.APIUsage{}},
+	}
+}
+
+// exportTypes are the content types of the exports
+var exportTypes = []string{"text/csv", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"}
+
+// apiDoc is the OpenAPI document of one API version
+type apiDoc struct {
+	doc  *openapi.Document
+	json []byte
+}
+
+var (
+	apiDocsOnce sync.Once
+	apiDocs     map[string]apiDoc
+)
+
+// apiDocument returns the OpenAPI document of an API version, built on
+// first use; v1 documents its /api/v1 paths, also served under /api
+func apiDocument(version string) (*openapi.Document, []byte) {
+	apiDocsOnce.Do(func() {
+		apiDocs = map[string]apiDoc{}
+		for _, v := range apiVersions {
+			description := "Devices report samples, alerts and heartbeats; dashboards read them. Errors share one envelope."
+			if v == apiV1 {
+				description += " Deprecated: v1 is frozen, also served under /api, and answers with Deprecation and Sunset headers; use v2."
+			}
+			doc := openapi.New(openapi.Info{
+				Title:       "Driver Drowsiness Detection API",
+				Version:     v,
+				Description: description,
+			}, models.ErrorResponse{})
+			for _, op := range rootOperations() {
+				doc.Add(op)
+			}
+			for _, op := range apiOperations(v) {
+				op.Path = "/api/" + v + op.Path
+				doc.Add(op)
+			}
+			b, err := json.Marshal(doc)
+			if err != nil {
+				panic(fmt.Sprintf("encode OpenAPI document %s: %v", v, err))
+			}
+			apiDocs[v] = apiDoc{doc: doc, json: b}
+		}
+	})
+	d := apiDocs[version]
+	return d.doc, d.json
+}
+
+// OpenAPI serves the OpenAPI 3 document of the API version of the request
+func (s *Server) OpenAPI(c *gin.Context) {
+	_, doc := apiDocument(apiVersionOf(c))
+	c.Data(http.StatusOK, "application/json; charset=utf-8", doc)
+}
+
+// Docs serves a page that renders the OpenAPI document
+func (s *Server) Docs(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", openapi.DocsPage)
+}
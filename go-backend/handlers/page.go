@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"driver-drowsiness-backend/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxPageSize caps the limit of history and alert pages
+const maxPageSize = 500
+
+// encodeCursor returns the opaque cursor of a page next to (t, id): older
+// rows for "next", newer rows for "prev"
+func encodeCursor(dir string, t time.Time, id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%d:%d", dir, t.UnixNano(), id)))
+}
+
+// decodeCursor sets the keyset of f from a cursor made by encodeCursor
+func decodeCursor(cursor string, f *repository.PageFilter) bool {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return false
+	}
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 3 {
+		return false
+	}
+	nanos, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return false
+	}
+	id, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return false
+	}
+	at := &repository.Cursor{Timestamp: time.Unix(0, nanos).UTC(), ID: id}
+	switch parts[0] {
+	case "next":
+		f.Before = at
+	case "prev":
+		f.After = at
+	default:
+		return false
+	}
+	return true
+}
+
+// parsePage reads the device listing query: limit (capped at maxPageSize),
+// cursor, from, to (RFC3339 or YYYY-MM-DD), status and tz, answering 422 when
+// from is before the data the daily purge kept. The filter asks for one row
+// more than the page so the caller can tell if more follow.
+func (s *Server) parsePage(c *gin.Context, fallback int) (repository.PageFilter, bool) {
+	f := repository.PageFilter{DeviceID: c.Param("id"), Status: c.Query("status"), Limit: min(queryLimit(c, fallback), maxPageSize) + 1}
+	loc, ok := s.requestLocation(c)
+	if !ok {
+		return f, false
+	}
+	if v := c.Query("from"); v != "" {
+		t, err := parseTimeParam(v, loc)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Invalid from")
+			return f, false
+		}
+		f.Range.From = t.UTC()
+	}
+	if v := c.Query("to"); v != "" {
+		t, err := parseTimeParam(v, loc)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Invalid to")
+			return f, false
+		}
+		f.Range.To = t.UTC()
+	}
+	if !f.Range.From.IsZero() && !f.Range.To.IsZero() && !f.Range.From.Before(f.Range.To) {
+		respondError(c, http.StatusBadRequest, "from must be before to")
+		return f, false
+	}
+	if !f.Range.From.IsZero() && !s.checkRetained(c, f.Range.From, 0, 0, f.DeviceID, loc) {
+		return f, false
+	}
+	if v := c.Query("cursor"); v != "" && !decodeCursor(v, &f) {
+		respondError(c, http.StatusBadRequest, "Invalid cursor")
+		return f, false
+	}
+	return f, true
+}
+
+// pageLinks returns the bounds of the rows of a page of n rows read with
+// parsePage's filter, newest first, without the extra row, and the links to
+// the pages around it; at returns the keyset of row i. The first page has no
+// prev link: clients poll it for new rows instead.
+func pageLinks(c *gin.Context, f repository.PageFilter, n int, at func(i int) (time.Time, int)) (lo, hi int, next, prev interface{}) {
+	link := func(dir string, t time.Time, id int) interface{} {
+		q := c.Request.URL.Query()
+		q.Set("cursor", encodeCursor(dir, t, id))
+		return c.Request.URL.Path + "?" + q.Encode()
+	}
+	more := n == f.Limit
+	lo, hi = 0, n
+	if more && f.After != nil {
+		lo = 1 // the extra row of a page after a cursor is its newest
+	} else if more {
+		hi = n - 1
+	}
+
+	if hi > lo {
+		if more || f.After != nil {
+			t, id := at(hi - 1)
+			next = link("next", t, id)
+		}
+		if (more && f.After != nil) || f.Before != nil {
+			t, id := at(lo)
+			prev = link("prev", t, id)
+		}
+		return lo, hi, next, prev
+	}
+	// An empty page links back to the rows next to its cursor, which the
+	// cursor itself excludes
+	if f.After != nil {
+		next = link("next", f.After.Timestamp, f.After.ID+1)
+	}
+	if f.Before != nil {
+		prev = link("prev", f.Before.Timestamp, f.Before.ID-1)
+	}
+	return lo, hi, next, prev
+}
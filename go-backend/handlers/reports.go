@@ -0,0 +1,332 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"driver-drowsiness-backend/models"
+	"driver-drowsiness-backend/pdf"
+	"driver-drowsiness-backend/reports"
+	"driver-drowsiness-backend/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// loadReportFont loads the configured report font, falling back to the
+// embedded Noto Sans Thai when none is set or it cannot be read
+func loadReportFont(path string) *pdf.Font {
+	if path == "" {
+		return pdf.DefaultFont()
+	}
+	font, err := pdf.LoadFont(path)
+	if err != nil {
+		slog.Warn("Could not load report font, using the embedded one", "path", path, "error", err)
+		return pdf.DefaultFont()
+	}
+	if !font.Has('ก') {
+		slog.Warn("Report font has no Thai glyphs, reports will be in English", "path", path)
+	}
+	return font
+}
+
+// reportKey is the storage key of a report's PDF
+func reportKey(id int) string {
+	return fmt.Sprintf("reports/%d.pdf", id)
+}
+
+// generateReport renders a pending report, stores its PDF and marks it ready
+// or failed
+func (s *Server) generateReport(ctx context.Context, rep *models.Report, subject string) error {
+	err := s.renderReport(ctx, rep, subject)
+	completed := s.now().UTC()
+	rep.CompletedAt = &completed
+	rep.Status = models.ReportReady
+	if err != nil {
+		rep.Status, rep.Error = models.ReportFailed, err.Error()
+	}
+	if uerr := s.store.Reports.Update(ctx, rep); uerr != nil && err == nil {
+		err = uerr
+	}
+	return err
+}
+
+func (s *Server) renderReport(ctx context.Context, rep *models.Report, subject string) error {
+	loc, err := time.LoadLocation(rep.Timezone)
+	if err != nil {
+		return err
+	}
+	period := repository.TimeRange{From: rep.PeriodFrom, To: rep.PeriodTo}
+	counts, err := s.store.Reports.HourlyCounts(ctx, repository.ExportFilter{
+		Range: period, FleetID: rep.FleetID, DriverID: rep.DriverID,
+	})
+	if err != nil {
+		return err
+	}
+	doc := reports.Render(reports.Summarize(subject, counts, period, loc), rep.Kind, s.reportFont, s.now())
+
+	var buf bytes.Buffer
+	if _, err := doc.WriteTo(&buf); err != nil {
+		return err
+	}
+	key := reportKey(rep.ID)
+	if err := s.files.Put(ctx, key, bytes.NewReader(buf.Bytes()), int64(buf.Len()), "application/pdf"); err != nil {
+		return err
+	}
+	rep.StorageKey, rep.Size, rep.Pages = key, int64(buf.Len()), doc.Pages()
+	return nil
+}
+
+// reportTitle names a report after its subject and local period
+func reportTitle(subject string, period repository.TimeRange, loc *time.Location) string {
+	return subject + " " + period.From.In(loc).Format("2006-01-02") + " – " +
+		period.To.Add(-time.Nanosecond).In(loc).Format("2006-01-02")
+}
+
+// CreateReport generates the PDF report of a fleet or driver, last week by default
+func (s *Server) CreateReport(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req models.ReportRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if (req.FleetID == 0) == (req.DriverID == 0) {
+		respondError(c, http.StatusBadRequest, "Exactly one of fleet_id or driver_id is required")
+		return
+	}
+
+	rep := &models.Report{Source: models.ReportManual, Status: models.ReportPending, CreatedAt: s.now().UTC()}
+	var subject string
+	var loc *time.Location
+	if req.FleetID != 0 {
+		fleet, err := s.store.Fleets.GetByID(ctx, req.FleetID)
+		if errors.Is(err, repository.ErrNotFound) {
+			respondError(c, http.StatusNotFound, "Fleet not found")
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Error fetching fleet", "fleet_id", req.FleetID, "error", err)
+			respondError(c, http.StatusInternalServerError, "Failed to fetch fleet")
+			return
+		}
+		rep.Kind, rep.FleetID, subject = models.ReportFleet, fleet.ID, fleet.Name
+		if loc, err = time.LoadLocation(fleet.Timezone); err != nil {
+			loc = s.defaultLocation()
+		}
+	} else {
+		user, err := s.store.Users.GetByID(ctx, req.DriverID)
+		if errors.Is(err, repository.ErrNotFound) {
+			respondError(c, http.StatusNotFound, "Driver not found")
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Error fetching user", "user_id", req.DriverID, "error", err)
+			respondError(c, http.StatusInternalServerError, "Failed to fetch driver")
+			return
+		}
+		rep.Kind, rep.DriverID, subject = models.ReportDriver, user.ID, user.Name
+		if subject == "" {
+			subject = user.Email
+		}
+		loc = s.userLocation(ctx, user.ID)
+	}
+
+	period := reports.LastWeek(s.now(), loc)
+	for name, v := range map[string]string{"from": req.From, "to": req.To} {
+		if v == "" {
+			continue
+		}
+		t, err := parseTimeParam(v, loc)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Invalid "+name)
+			return
+		}
+		if name == "from" {
+			period.From = t.UTC()
+		} else {
+			period.To = t.UTC()
+		}
+	}
+	if !period.From.Before(period.To) || period.To.Sub(period.From) > 93*24*time.Hour {
+		respondError(c, http.StatusBadRequest, "Period must be between 1 hour and 93 days")
+		return
+	}
+	rep.PeriodFrom, rep.PeriodTo, rep.Timezone = period.From, period.To, loc.String()
+	rep.Title = reportTitle(subject, period, loc)
+
+	if err := s.store.Reports.Create(ctx, rep); err != nil {
+		slog.ErrorContext(ctx, "Error creating report", "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to create report")
+		return
+	}
+	if err := s.generateReport(ctx, rep, subject); err != nil {
+		slog.ErrorContext(ctx, "Error generating report", "report_id", rep.ID, "error", err)
+		respondErrorWith(c, http.StatusInternalServerError, "Failed to generate report", gin.H{"report": rep})
+		return
+	}
+	slog.InfoContext(ctx, "Report generated", "user_id", c.GetInt("user_id"), "report_id", rep.ID, "title", rep.Title, "pages", rep.Pages)
+	c.JSON(http.StatusCreated, rep)
+}
+
+// ListReports returns generated reports, newest first
+func (s *Server) ListReports(c *gin.Context) {
+	f := repository.ReportFilter{Kind: c.Query("kind"), Limit: queryLimit(c, 50)}
+	for name, dst := range map[string]*int{"fleet_id": &f.FleetID, "driver_id": &f.DriverID} {
+		if v := c.Query(name); v != "" {
+			id, err := strconv.Atoi(v)
+			if err != nil || id <= 0 {
+				respondError(c, http.StatusBadRequest, "Invalid "+name)
+				return
+			}
+			*dst = id
+		}
+	}
+	list, err := s.store.Reports.List(c.Request.Context(), f)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error listing reports", "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to list reports")
+		return
+	}
+	if list == nil {
+		list = []models.Report{}
+	}
+	noCache(c)
+	c.JSON(http.StatusOK, gin.H{"count": len(list), "reports": list})
+}
+
+// report fetches the report named by the ":id" parameter, answering errors itself
+func (s *Server) report(c *gin.Context) (*models.Report, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Invalid report id")
+		return nil, false
+	}
+	rep, err := s.store.Reports.GetByID(c.Request.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, "Report not found")
+		return nil, false
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error fetching report", "report_id", id, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to fetch report")
+		return nil, false
+	}
+	return rep, true
+}
+
+// GetReport returns one report
+func (s *Server) GetReport(c *gin.Context) {
+	if rep, ok := s.report(c); ok {
+		noCache(c)
+		c.JSON(http.StatusOK, rep)
+	}
+}
+
+// DownloadReport streams the PDF of a ready report
+func (s *Server) DownloadReport(c *gin.Context) {
+	rep, ok := s.report(c)
+	if !ok {
+		return
+	}
+	if rep.Status != models.ReportReady {
+		respondError(c, http.StatusConflict, "Report is "+rep.Status)
+		return
+	}
+	body, size, err := s.files.Open(c.Request.Context(), rep.StorageKey)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error opening file", "key", rep.StorageKey, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to read report")
+		return
+	}
+	defer body.Close()
+	loc, err := time.LoadLocation(rep.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	filename := fmt.Sprintf("report_%s_%d_%s.pdf", rep.Kind, rep.ID, rep.PeriodFrom.In(loc).Format("2006-01-02"))
+	c.DataFromReader(http.StatusOK, size, "application/pdf", body, map[string]string{
+		"Content-Disposition": `attachment; filename="` + filename + `"`,
+		"Cache-Control":       "private, max-age=300",
+	})
+}
+
+// DeleteReport removes a report and its PDF
+func (s *Server) DeleteReport(c *gin.Context) {
+	rep, ok := s.report(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	if err := s.store.Reports.Delete(ctx, rep.ID); err != nil && !errors.Is(err, repository.ErrNotFound) {
+		slog.ErrorContext(ctx, "Error deleting report", "report_id", rep.ID, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to delete report")
+		return
+	}
+	if rep.StorageKey != "" {
+		if err := s.files.Delete(ctx, rep.StorageKey); err != nil {
+			slog.WarnContext(ctx, "Could not delete file", "key", rep.StorageKey, "error", err)
+		}
+	}
+	slog.InfoContext(ctx, "User deleted report", "user_id", c.GetInt("user_id"), "report_id", rep.ID)
+	c.JSON(http.StatusOK, gin.H{"success": true, "id": rep.ID})
+}
+
+// scheduleReports generates last week's report of every fleet that has none.
+// The unique scheduled report per fleet and week lets one replica win.
+func (s *Server) scheduleReports(ctx context.Context) {
+	fleets, err := s.store.Fleets.List(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "Could not list fleets for reports", "error", err)
+		return
+	}
+	for _, fleet := range fleets {
+		loc, err := time.LoadLocation(fleet.Timezone)
+		if err != nil {
+			loc = s.defaultLocation()
+		}
+		period := reports.LastWeek(s.now(), loc)
+		rep := &models.Report{
+			Kind: models.ReportFleet, FleetID: fleet.ID, Title: reportTitle(fleet.Name, period, loc),
+			Timezone: loc.String(), PeriodFrom: period.From, PeriodTo: period.To,
+			Source: models.ReportSchedule, Status: models.ReportPending, CreatedAt: s.now().UTC(),
+		}
+		err = s.store.Reports.Create(ctx, rep)
+		if errors.Is(err, repository.ErrConflict) {
+			continue
+		}
+		if err == nil {
+			err = s.generateReport(ctx, rep, fleet.Name)
+		}
+		if err != nil {
+			slog.WarnContext(ctx, "Could not generate weekly report of fleet", "fleet_id", fleet.ID, "error", err)
+			continue
+		}
+		slog.InfoContext(ctx, "Weekly report generated", "report_id", rep.ID, "title", rep.Title)
+	}
+}
+
+// WatchReports generates weekly fleet reports every interval until ctx is done
+func (s *Server) WatchReports(ctx context.Context, interval time.Duration) {
+	if !s.cfg.WeeklyReports {
+		return
+	}
+	go func() {
+		s.scheduleReports(ctx)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.scheduleReports(ctx)
+			}
+		}
+	}()
+	slog.InfoContext(ctx, "Weekly fleet reports scheduled", "interval", interval.String())
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"driver-drowsiness-backend/models"
+	"driver-drowsiness-backend/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fleetTimezone returns the timezone of a fleet, else the configured one
+func (s *Server) fleetTimezone(f models.Fleet) *time.Location {
+	if loc, err := time.LoadLocation(f.Timezone); err == nil {
+		return loc
+	}
+	return s.defaultLocation()
+}
+
+// purgeCutoffs returns the start of the day of now in the timezone of every
+// fleet, and in the configured one for the rest
+func (s *Server) purgeCutoffs(ctx context.Context, now time.Time) (repository.PurgeCutoffs, error) {
+	cutoffs := repository.PurgeCutoffs{
+		Default: repository.DayRange(now, s.defaultLocation()).From,
+		Fleets:  make(map[int]time.Time),
+	}
+	fleets, err := s.store.Fleets.List(ctx)
+	if err != nil {
+		return cutoffs, err
+	}
+	for _, f := range fleets {
+		cutoffs.Fleets[f.ID] = repository.DayRange(now, s.fleetTimezone(f)).From
+	}
+	return cutoffs, nil
+}
+
+// PurgeLocations returns the configured timezone and those of the fleets,
+// each once: the daily purge runs at midnight in every one of them
+func (s *Server) PurgeLocations() []*time.Location {
+	locations := []*time.Location{s.defaultLocation()}
+	fleets, err := s.store.Fleets.List(context.Background())
+	if err != nil {
+		slog.Warn("Failed to list fleet timezones, purging at midnight of the configured one only", "error", err)
+		return locations
+	}
+	seen := map[string]bool{locations[0].String(): true}
+	for _, f := range fleets {
+		if loc := s.fleetTimezone(f); !seen[loc.String()] {
+			seen[loc.String()] = true
+			locations = append(locations, loc)
+		}
+	}
+	return locations
+}
+
+// PurgeOldData archives the driving time of the days before today of every
+// fleet, then deletes their samples, alerts and sessions. It is scheduled at
+// midnight of every fleet's timezone; each run cuts every fleet at the start
+// of its own day, so the fleet whose day just ended loses it and the others
+// keep theirs.
+func (s *Server) PurgeOldData() error {
+	ctx := context.Background()
+	cutoffs, err := s.purgeCutoffs(ctx, s.now())
+	if err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "Purging drowsiness and alert data before today of each fleet",
+		"default_cutoff", cutoffs.Default, "fleets", len(cutoffs.Fleets))
+	// If archiving fails nothing is purged, so the next purge can archive again
+	if err := s.ArchiveCompliance(ctx, cutoffs); err != nil {
+		slog.WarnContext(ctx, "Failed to archive data before purge", "error", err)
+		return err
+	}
+	if err := s.store.Retention.Purge(ctx, cutoffs); err != nil {
+		slog.WarnContext(ctx, "Failed to purge", "error", err)
+		return err
+	}
+	slog.InfoContext(ctx, "Purge complete, retained only today's rows")
+	return nil
+}
+
+// retainedFrom returns where the raw samples and alerts of a fleet, driver
+// or device start: the start of today in the fleet's timezone, at whose
+// midnight the last purge ran. Without any of them it is the earliest
+// cutoff, before which nothing is left.
+func (s *Server) retainedFrom(ctx context.Context, fleetID, driverID int, deviceID string) (time.Time, error) {
+	cutoffs, err := s.purgeCutoffs(ctx, s.now())
+	if err != nil {
+		return time.Time{}, err
+	}
+	if fleetID != 0 {
+		return cutoffs.For(fleetID), nil
+	}
+	if driverID == 0 && deviceID != "" {
+		if driverID = s.driverAt(ctx, deviceID, s.now()); driverID == 0 {
+			return cutoffs.Default, nil
+		}
+	}
+	if driverID == 0 {
+		return cutoffs.Earliest(), nil
+	}
+	user, err := s.store.Users.GetByID(ctx, driverID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return cutoffs.Default, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return cutoffs.For(user.FleetID), nil
+}
+
+// checkRetained answers 422 when a query starting at from reaches back past
+// the raw data the daily purge kept, rather than returning it empty
+func (s *Server) checkRetained(c *gin.Context, from time.Time, fleetID, driverID int, deviceID string, loc *time.Location) bool {
+	cutoff, err := s.retainedFrom(c.Request.Context(), fleetID, driverID, deviceID)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error resolving retention", "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to resolve data retention")
+		return false
+	}
+	if from.Before(cutoff) {
+		retained := cutoff.In(loc).Format(time.RFC3339)
+		respondErrorWith(c, http.StatusUnprocessableEntity,
+			"Raw data before "+retained+" has been purged; use the analytics or reports endpoints for earlier days",
+			gin.H{"retained_from": retained})
+		return false
+	}
+	return true
+}
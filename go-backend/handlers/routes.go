@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterRoutes mounts every API route of the server on the router
+func (s *Server) RegisterRoutes(router gin.IRouter) {
+	// Every response, errors included, carries the ID of its request
+	router.Use(RequestID())
+	router.Use(s.Instrument())
+	router.Use(s.AccessLog())
+
+	// Root endpoints
+	router.GET("/", s.Root)
+	router.GET("/health", s.HealthCheck)
+	router.GET("/.well-known/appspecific/com.chrome.devtools.json", s.DevToolsManifest)
+	router.GET("/metrics", s.Metrics) // Prometheus scrape target
+
+	// API routes: /api is the frozen v1 of the first clients, also served
+	// under /api/v1; v2 carries the new contracts
+	s.registerAPI(router.Group("/api", s.APIVersion(apiV1)), apiV1)
+	for _, version := range apiVersions {
+		s.registerAPI(router.Group("/api/"+version, s.APIVersion(version)), version)
+	}
+}
+
+// registerAPI mounts the routes of one API version on api. v1 keeps the
+// routes of the first clients as they were when it froze; routes added
+// since are mounted in v2 only.
+func (s *Server) registerAPI(api *gin.RouterGroup, version string) {
+	v2 := version != apiV1
+	{
+		// Auth routes
+		api.POST("/auth/register", s.Register)
+		api.POST("/auth/login", s.Login)
+		api.GET("/auth/me", s.AuthMiddleware(), s.Me)
+		api.POST("/auth/forgot-password", s.ForgotPassword)
+		api.POST("/auth/reset-password", s.ResetPassword)
+
+		// Health check
+		api.GET("/health", s.HealthCheck)
+
+		// OpenAPI document of the version and the page that renders it
+		api.GET("/openapi.json", s.OpenAPI)
+		api.GET("/docs", s.Docs)
+
+		// Seed admin (one-time setup, should be removed in production)
+		api.POST("/seed/admin", s.SeedAdmin)
+
+		// Device routes
+		devices := api.Group("/devices")
+		{
+			// Public device list could be protected later
+			devices.GET("", s.GetAllDevices)
+
+			// Device-specific routes
+			// These could require AuthMiddleware() later
+			if v2 {
+				devices.POST("/:id/data", s.ReceiveDeviceDataV2) // Telemetry nested under "telemetry"
+				devices.GET("/:id/history", s.GetDeviceHistory)  // Pages with filters and links
+				devices.GET("/:id/alerts", s.GetDeviceAlerts)
+			} else {
+				devices.POST("/:id/data", s.ReceiveDeviceData)    // Python sends data here
+				devices.GET("/:id/history", s.GetDeviceHistoryV1) // Frontend gets history
+				devices.GET("/:id/alerts", s.GetDeviceAlertsV1)   // Frontend gets alerts
+			}
+			devices.POST("/:id/alert", s.ReceiveAlert)      // Python sends alerts here
+			devices.GET("/:id/data", s.GetDeviceLatestData) // Frontend gets latest data
+		}
+
+		// Admin routes (protected). v1 keeps taking any valid token; v2 also
+		// checks the role, as drivers register themselves and its admin routes
+		// command devices and delete data
+		guards := []gin.HandlerFunc{s.AuthMiddleware()}
+		if v2 {
+			guards = append(guards, RequireRole("admin"))
+		}
+		admin := api.Group("/admin", guards...)
+		{
+			admin.GET("/overview", s.AdminOverview)
+			admin.GET("/drivers", s.AdminDrivers)
+			admin.GET("/recent-alerts", s.AdminRecentAlerts)
+			admin.GET("/alert-slots", s.AdminAlertSlots)
+			admin.GET("/alert-levels", s.AdminAlertLevels)
+		}
+
+		if v2 {
+			s.registerV2(api, devices, admin)
+		}
+	}
+}
+
+// registerV2 mounts the routes added after v1 froze
+func (s *Server) registerV2(api, devices, admin *gin.RouterGroup) {
+	// Face references of the signed-in user
+	api.GET("/auth/me/face", s.AuthMiddleware(), s.ListMyFaces)
+	api.POST("/auth/me/face", s.AuthMiddleware(), s.EnrollMyFace) // Embedding computed on the Pi
+	api.DELETE("/auth/me/face/:id", s.AuthMiddleware(), s.DeleteMyFace)
+
+	// Device routes
+	{
+		devices.GET("/:id/fatigue", s.GetDeviceFatigue)            // Live fatigue metrics
+		devices.GET("/:id/compliance", s.GetDeviceCompliance)      // Driving time vs. limits
+		devices.POST("/:id/heartbeat", s.ReceiveHeartbeat)         // Device health report
+		devices.GET("/:id/health", s.GetDeviceHealth)              // Last heartbeat and state
+		devices.GET("/:id/config", s.GetDeviceConfig)              // Desired configuration
+		devices.POST("/:id/config/reported", s.ReportDeviceConfig) // Config the device applied
+		devices.GET("/:id/commands", s.PollCommands)               // Long poll for remote commands
+		devices.POST("/:id/commands/:command_id/ack", s.AckCommand)
+
+		// Alert evidence: one multipart request, or resumable chunks
+		devices.POST("/:id/alerts/:alert_id/evidence", s.UploadEvidence)
+		devices.POST("/:id/alerts/:alert_id/uploads", s.StartEvidenceUpload)
+		devices.GET("/:id/uploads/:upload_id", s.GetEvidenceUpload)
+		devices.PUT("/:id/uploads/:upload_id", s.UploadEvidenceChunk)
+
+		// Driving sessions
+		devices.POST("/:id/sessions/start", s.StartSession)
+		devices.POST("/:id/sessions/stop", s.StopSession)
+		devices.GET("/:id/sessions", s.GetDeviceSessions)
+	}
+
+	// Evidence files, authorized by the signature in the URL
+	api.GET("/evidence/:id/:variant", s.ServeEvidence)
+
+	// Admin routes
+	{
+		// Zero-filled time series of samples
+		admin.GET("/analytics/alerts", s.AdminAnalytics)
+
+		// CSV and XLSX exports of a driver, device or fleet
+		admin.GET("/export/history", s.ExportHistory)
+		admin.GET("/export/alerts", s.ExportAlerts)
+
+		// PDF safety reports, generated on demand or weekly per fleet
+		admin.POST("/reports", s.CreateReport)
+		admin.GET("/reports", s.ListReports)
+		admin.GET("/reports/:id", s.GetReport)
+		admin.GET("/reports/:id/pdf", s.DownloadReport)
+		admin.DELETE("/reports/:id", s.DeleteReport)
+
+		// Digest subscriptions delivered by email or webhook
+		admin.POST("/subscriptions", s.CreateSubscription)
+		admin.GET("/subscriptions", s.ListSubscriptions)
+		admin.GET("/subscriptions/:id", s.GetSubscription)
+		admin.PUT("/subscriptions/:id", s.UpdateSubscription)
+		admin.DELETE("/subscriptions/:id", s.DeleteSubscription)
+		admin.GET("/subscriptions/:id/deliveries", s.ListDeliveries)
+
+		// Fleets and their business timezone
+		admin.GET("/fleets", s.ListFleets)
+		admin.POST("/fleets", s.CreateFleet)
+		admin.PUT("/fleets/:id", s.UpdateFleet)
+
+		// Driving sessions
+		admin.GET("/sessions", s.AdminSessions)
+		admin.GET("/sessions/:id", s.AdminSessionDetail)
+		admin.GET("/sessions/:id/track", s.AdminSessionTrack)
+
+		// Map layers (GeoJSON)
+		admin.GET("/geo/alerts", s.AdminGeoAlerts)
+
+		// Geofences and location-based alert rules
+		admin.GET("/geofences", s.ListGeofences)
+		admin.POST("/geofences", s.CreateGeofence)
+		admin.GET("/geofences/events", s.ListGeofenceEvents)
+		admin.GET("/geofences/:id", s.GetGeofence)
+		admin.PUT("/geofences/:id", s.UpdateGeofence)
+		admin.DELETE("/geofences/:id", s.DeleteGeofence)
+
+		// Vehicles and the devices installed in them
+		admin.GET("/vehicles", s.ListVehicles)
+		admin.POST("/vehicles", s.CreateVehicle)
+		admin.GET("/vehicles/:id", s.GetVehicle)
+		admin.PUT("/vehicles/:id", s.UpdateVehicle)
+		admin.POST("/vehicles/:id/devices", s.InstallDevice)
+		admin.DELETE("/vehicles/:id/devices/:device_id", s.RemoveDevice)
+		admin.GET("/installations", s.ListInstallations)
+
+		// Shifts: which driver drives which vehicle and device, and when
+		admin.GET("/shifts", s.ListShifts)
+		admin.POST("/shifts", s.CreateShift)
+		admin.GET("/shifts/:id", s.GetShift)
+		admin.PUT("/shifts/:id", s.UpdateShift)
+		admin.POST("/shifts/:id/end", s.EndShift)
+		admin.DELETE("/shifts/:id", s.DeleteShift)
+
+		// Hours-of-service compliance
+		admin.GET("/compliance", s.AdminCompliance)
+
+		// Device state transitions and uptime
+		admin.GET("/device-events", s.ListDeviceEvents)
+		admin.GET("/devices/:id/uptime", s.AdminDeviceUptime)
+
+		// Remote device configuration (desired vs. reported)
+		admin.GET("/device-configs", s.AdminDeviceConfigs)
+		admin.GET("/devices/:id/config", s.AdminGetDeviceConfig)
+		admin.PUT("/devices/:id/config", s.AdminUpdateDeviceConfig)
+
+		// Remote commands to devices and their audit trail
+		admin.POST("/devices/:id/commands", s.EnqueueCommand)
+		admin.GET("/devices/:id/commands", s.ListDeviceCommands)
+		admin.GET("/commands/:id", s.GetCommand)
+		admin.POST("/commands/:id/cancel", s.CancelCommand)
+
+		// Alert evidence with signed download URLs
+		admin.GET("/alerts/:id/evidence", s.AdminAlertEvidence)
+		admin.GET("/evidence", s.AdminListEvidence)
+		admin.DELETE("/evidence/:id", s.AdminDeleteEvidence)
+
+		// Driver face references for identity checks
+		admin.GET("/drivers/:id/face", s.AdminDriverFaces)
+		admin.DELETE("/drivers/:id/face", s.AdminResetDriverFaces)
+
+		// Requests per API version, to tell when v1 can go
+		admin.GET("/api-usage", s.AdminAPIUsage)
+	}
+}
@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"driver-drowsiness-backend/models"
+	"driver-drowsiness-backend/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sessionEvent reads a start/stop event and its optional RFC3339 timestamp,
+// defaulting to now; an empty body is accepted
+func (s *Server) sessionEvent(c *gin.Context) (*models.SessionEventPayload, time.Time, bool) {
+	var payload models.SessionEventPayload
+	if c.Request.ContentLength > 0 {
+		if !bindJSON(c, &payload) {
+			return nil, time.Time{}, false
+		}
+	}
+	if payload.Timestamp == "" {
+		return &payload, s.now().UTC(), true
+	}
+	t, err := time.Parse(time.RFC3339, payload.Timestamp)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Invalid timestamp")
+		return nil, time.Time{}, false
+	}
+	return &payload, t.UTC(), true
+}
+
+// StartSession marks an explicit trip start, closing any open session. A
+// face_embedding in the body checks who is driving before attributing it.
+func (s *Server) StartSession(c *gin.Context) {
+	deviceID := c.Param("id")
+	payload, at, ok := s.sessionEvent(c)
+	if !ok {
+		return
+	}
+	check, ok := s.checkIdentity(c, deviceID, at, payload)
+	if !ok {
+		return
+	}
+
+	session, err := s.tracker.StartChecked(c.Request.Context(), deviceID, at, check)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error starting session", "device_id", deviceID, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to start session")
+		return
+	}
+	s.tracker.Describe(session, s.now().UTC())
+
+	if check != nil && check.Status == models.IdentityUnrecognized {
+		s.raiseUnrecognizedDriver(c, deviceID, at)
+	}
+
+	slog.InfoContext(c.Request.Context(), "Driving session started", "session_id", session.ID, "device_id", deviceID)
+	c.JSON(http.StatusCreated, session)
+}
+
+// StopSession marks an explicit trip stop
+func (s *Server) StopSession(c *gin.Context) {
+	deviceID := c.Param("id")
+	_, at, ok := s.sessionEvent(c)
+	if !ok {
+		return
+	}
+
+	session, err := s.tracker.Stop(c.Request.Context(), deviceID, at)
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, "No open session for this device")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error stopping session", "device_id", deviceID, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to stop session")
+		return
+	}
+	s.tracker.Describe(session, s.now().UTC())
+
+	slog.InfoContext(c.Request.Context(), "Driving session stopped", "session_id", session.ID, "device_id", deviceID)
+	c.JSON(http.StatusOK, session)
+}
+
+// sessionFilter parses from, to and limit shared by the session listings
+func (s *Server) sessionFilter(c *gin.Context) (repository.SessionFilter, bool) {
+	f := repository.SessionFilter{Limit: queryLimit(c, 50)}
+	loc, ok := s.requestLocation(c)
+	if !ok {
+		return f, false
+	}
+	if v := c.Query("from"); v != "" {
+		t, err := parseTimeParam(v, loc)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Invalid from")
+			return f, false
+		}
+		f.Range.From = t.UTC()
+	}
+	if v := c.Query("to"); v != "" {
+		t, err := parseTimeParam(v, loc)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Invalid to")
+			return f, false
+		}
+		f.Range.To = t.UTC()
+	}
+	return f, true
+}
+
+// listSessions answers a session listing for the given filter
+func (s *Server) listSessions(c *gin.Context, f repository.SessionFilter) {
+	list, err := s.store.Sessions.List(c.Request.Context(), f)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error fetching sessions", "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to fetch sessions")
+		return
+	}
+	now := s.now().UTC()
+	for i := range list {
+		s.tracker.Describe(&list[i], now)
+	}
+	c.JSON(http.StatusOK, gin.H{"count": len(list), "sessions": list})
+}
+
+// GetDeviceSessions returns the driving sessions of a device, newest first
+func (s *Server) GetDeviceSessions(c *gin.Context) {
+	noCache(c)
+	f, ok := s.sessionFilter(c)
+	if !ok {
+		return
+	}
+	f.DeviceID = c.Param("id")
+	s.listSessions(c, f)
+}
+
+// AdminSessions returns driving sessions filtered by driver_id, device_id,
+// from and to (RFC3339 or YYYY-MM-DD), newest first
+func (s *Server) AdminSessions(c *gin.Context) {
+	noCache(c)
+	f, ok := s.sessionFilter(c)
+	if !ok {
+		return
+	}
+	f.DeviceID = c.Query("device_id")
+	if v := c.Query("driver_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Invalid driver_id")
+			return
+		}
+		f.DriverID = id
+	}
+	s.listSessions(c, f)
+}
+
+// AdminSessionDetail returns one session with its sample timeline and alerts
+func (s *Server) AdminSessionDetail(c *gin.Context) {
+	noCache(c)
+	ctx := c.Request.Context()
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Invalid session id")
+		return
+	}
+
+	session, err := s.store.Sessions.GetByID(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, "Session not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Error fetching session", "session_id", id, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to fetch session")
+		return
+	}
+	s.tracker.Describe(session, s.now().UTC())
+
+	// Both bounds inclusive: the last sample sits exactly on the end, and
+	// PostgreSQL timestamps have microsecond precision
+	end := session.LastSampleAt
+	if session.EndedAt != nil {
+		end = *session.EndedAt
+	}
+	r := repository.TimeRange{From: session.StartedAt, To: end.Add(time.Microsecond)}
+
+	detail := models.SessionDetail{DrivingSession: *session}
+	if detail.Samples, err = s.store.Drowsiness.Between(ctx, session.DeviceID, r); err != nil {
+		slog.ErrorContext(ctx, "Error fetching session samples", "session_id", id, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to fetch session")
+		return
+	}
+	if detail.Alerts, err = s.store.Alerts.Between(ctx, session.DeviceID, r); err != nil {
+		slog.ErrorContext(ctx, "Error fetching session alerts", "session_id", id, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to fetch session")
+		return
+	}
+	if detail.Samples == nil {
+		detail.Samples = []models.DrowsinessData{}
+	}
+	if detail.Alerts == nil {
+		detail.Alerts = []models.Alert{}
+	}
+	c.JSON(http.StatusOK, detail)
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"driver-drowsiness-backend/models"
+	"driver-drowsiness-backend/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// deviceShadow returns the shadow of a device, with the default desired
+// configuration at version 0 when none was stored
+func (s *Server) deviceShadow(ctx context.Context, deviceID string) (*models.DeviceShadow, error) {
+	shadow, err := s.store.Shadows.Get(ctx, deviceID)
+	if errors.Is(err, repository.ErrNotFound) {
+		shadow, err = &models.DeviceShadow{DeviceID: deviceID, Desired: models.DefaultDeviceConfig()}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	shadow.Resolve()
+	return shadow, nil
+}
+
+// knownDevice answers 404 when a device was never registered
+func (s *Server) knownDevice(c *gin.Context, deviceID string) bool {
+	_, err := s.store.Devices.Get(c.Request.Context(), deviceID)
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, "Device not found")
+		return false
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error fetching device", "device_id", deviceID, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to fetch device")
+		return false
+	}
+	return true
+}
+
+// GetDeviceConfig returns the desired configuration a device should apply
+func (s *Server) GetDeviceConfig(c *gin.Context) {
+	noCache(c)
+	shadow, err := s.deviceShadow(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error fetching device config", "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to fetch device config")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"version": shadow.Version, "config": shadow.Desired})
+}
+
+// ReportDeviceConfig records the configuration a device applied
+func (s *Server) ReportDeviceConfig(c *gin.Context) {
+	ctx := c.Request.Context()
+	deviceID := c.Param("id")
+
+	var report models.ConfigReport
+	if !bindJSON(c, &report) {
+		return
+	}
+	if err := report.Config.Validate(); err != nil {
+		respondError(c, http.StatusBadRequest, "Invalid config: "+err.Error())
+		return
+	}
+	current, err := s.deviceShadow(ctx, deviceID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error fetching device config", "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to record device config")
+		return
+	}
+	if report.Version < 0 || report.Version > current.Version {
+		respondError(c, http.StatusBadRequest, "Unknown config version")
+		return
+	}
+
+	now := s.now().UTC()
+	s.ensureDevice(ctx, deviceID, now)
+	shadow, err := s.store.Shadows.Report(ctx, deviceID, report, now)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error recording config", "device_id", deviceID, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to record device config")
+		return
+	}
+	shadow.Resolve()
+	if shadow.Sync == models.ShadowDrift {
+		slog.InfoContext(ctx, "Device config drifted", "device_id", deviceID, "version", report.Version, "differing", len(shadow.Drift))
+	}
+	c.JSON(http.StatusOK, shadow)
+}
+
+// AdminGetDeviceConfig returns the desired and reported configuration of a device
+func (s *Server) AdminGetDeviceConfig(c *gin.Context) {
+	noCache(c)
+	deviceID := c.Param("id")
+	if !s.knownDevice(c, deviceID) {
+		return
+	}
+	shadow, err := s.deviceShadow(c.Request.Context(), deviceID)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error fetching device config", "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to fetch device config")
+		return
+	}
+	c.JSON(http.StatusOK, shadow)
+}
+
+// AdminUpdateDeviceConfig changes settings of the desired configuration of a
+// device; settings left out of the request keep their value
+func (s *Server) AdminUpdateDeviceConfig(c *gin.Context) {
+	ctx := c.Request.Context()
+	deviceID := c.Param("id")
+	if !s.knownDevice(c, deviceID) {
+		return
+	}
+	var req models.DeviceConfigRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	current, err := s.deviceShadow(ctx, deviceID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error fetching device config", "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to update device config")
+		return
+	}
+	desired := req.Apply(current.Desired)
+	if err := desired.Validate(); err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	shadow, err := s.store.Shadows.SetDesired(ctx, deviceID, desired, s.now().UTC())
+	if err != nil {
+		slog.ErrorContext(ctx, "Error updating config", "device_id", deviceID, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to update device config")
+		return
+	}
+	shadow.Resolve()
+	slog.InfoContext(ctx, "Desired device config updated", "device_id", deviceID, "version", shadow.Version)
+	c.JSON(http.StatusOK, shadow)
+}
+
+// AdminDeviceConfigs returns the configuration shadow of every device with
+// a count per sync state. Query: fleet_id and sync (in_sync|pending|drift|never_reported).
+func (s *Server) AdminDeviceConfigs(c *gin.Context) {
+	noCache(c)
+	var f repository.ShadowFilter
+	if v := c.Query("fleet_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Invalid fleet_id")
+			return
+		}
+		f.FleetID = id
+	}
+	sync := c.Query("sync")
+	switch sync {
+	case "", models.ShadowInSync, models.ShadowPending, models.ShadowDrift, models.ShadowNeverReported:
+	default:
+		respondError(c, http.StatusBadRequest, "sync must be in_sync, pending, drift or never_reported")
+		return
+	}
+
+	shadows, err := s.store.Shadows.List(c.Request.Context(), f)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error fetching device configs", "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to fetch device configs")
+		return
+	}
+	summary := map[string]int{
+		models.ShadowInSync: 0, models.ShadowPending: 0, models.ShadowDrift: 0, models.ShadowNeverReported: 0,
+	}
+	devices := []models.DeviceShadow{}
+	for _, shadow := range shadows {
+		shadow.Resolve()
+		summary[shadow.Sync]++
+		if sync == "" || shadow.Sync == sync {
+			devices = append(devices, shadow)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"count": len(devices), "summary": summary, "devices": devices})
+}
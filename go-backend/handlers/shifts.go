@@ -0,0 +1,284 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"driver-drowsiness-backend/models"
+	"driver-drowsiness-backend/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// driverAt returns the driver to attribute a device's data to: the driver a
+// face check pinned to the current trip, else the driver on shift, else the
+// device owner. Lookup failures attribute to nobody.
+func (s *Server) driverAt(ctx context.Context, deviceID string, at time.Time) int {
+	if open, err := s.store.Sessions.Open(ctx, deviceID); err == nil && open.Identity != nil && open.Identity.Pinned() &&
+		!at.Before(open.StartedAt) && at.Sub(open.LastSampleAt) <= s.tracker.Gap() {
+		return open.DriverID
+	}
+	driverID, err := repository.DriverAt(ctx, s.store.Shifts, s.store.Devices, deviceID, at)
+	if err != nil {
+		slog.WarnContext(ctx, "Could not resolve driver", "device_id", deviceID, "error", err)
+	}
+	return driverID
+}
+
+// currentDevice returns the device of the user's current shift, falling
+// back to the most recently registered device
+func (s *Server) currentDevice(ctx context.Context, userID int) string {
+	if shift, err := s.store.Shifts.OnDriver(ctx, userID, s.now()); err == nil {
+		return shift.DeviceID
+	}
+	deviceID, _ := s.store.Devices.PrimaryForUser(ctx, userID)
+	return deviceID
+}
+
+// describeShift fills the status of a shift at now
+func describeShift(sh *models.Shift, now time.Time) {
+	switch {
+	case now.Before(sh.StartsAt):
+		sh.Status = "scheduled"
+	case sh.EndsAt != nil && !now.Before(*sh.EndsAt):
+		sh.Status = "ended"
+	default:
+		sh.Status = "active"
+	}
+}
+
+// shiftID parses the ":id" path parameter
+func shiftID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Invalid shift id")
+		return 0, false
+	}
+	return id, true
+}
+
+// bindShift reads a shift request and checks the driver, vehicle and device
+// exist. The device defaults to the one installed in the vehicle.
+func (s *Server) bindShift(c *gin.Context) (*models.Shift, bool) {
+	var req models.ShiftRequest
+	if !bindJSON(c, &req) {
+		return nil, false
+	}
+	loc, ok := s.requestLocation(c)
+	if !ok {
+		return nil, false
+	}
+	startsAt, err := parseTimeParam(req.StartsAt, loc)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Invalid starts_at")
+		return nil, false
+	}
+	shift := models.Shift{
+		DriverID:  req.DriverID,
+		VehicleID: req.VehicleID,
+		DeviceID:  req.DeviceID,
+		StartsAt:  startsAt.UTC(),
+		Notes:     req.Notes,
+	}
+	if req.EndsAt != "" {
+		endsAt, err := parseTimeParam(req.EndsAt, loc)
+		if err != nil || !endsAt.After(startsAt) {
+			respondError(c, http.StatusBadRequest, "ends_at must be after starts_at")
+			return nil, false
+		}
+		endsAt = endsAt.UTC()
+		shift.EndsAt = &endsAt
+	}
+
+	ctx := c.Request.Context()
+	if u, err := s.store.Users.GetByID(ctx, req.DriverID); err != nil || u.Role != "driver" {
+		respondError(c, http.StatusBadRequest, "Unknown driver")
+		return nil, false
+	}
+	vehicle, err := s.store.Vehicles.GetByID(ctx, req.VehicleID)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Unknown vehicle")
+		return nil, false
+	}
+	if shift.DeviceID == "" {
+		shift.DeviceID = vehicle.DeviceID
+	}
+	if shift.DeviceID == "" {
+		respondError(c, http.StatusBadRequest, "Vehicle has no installed device; device_id is required")
+		return nil, false
+	}
+	if _, err := s.store.Devices.Get(ctx, shift.DeviceID); err != nil {
+		respondError(c, http.StatusBadRequest, "Unknown device")
+		return nil, false
+	}
+	return &shift, true
+}
+
+// saveShiftError answers the errors of creating or updating a shift
+func saveShiftError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, repository.ErrConflict):
+		respondError(c, http.StatusConflict, "Driver or device already has a shift in this period")
+	case errors.Is(err, repository.ErrNotFound):
+		respondError(c, http.StatusNotFound, "Shift not found")
+	default:
+		slog.ErrorContext(c.Request.Context(), "Error saving shift", "action", action, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to save shift")
+	}
+}
+
+// ListShifts returns shifts latest start first.
+// Query: driver_id, vehicle_id, device_id, from, to, limit and tz.
+func (s *Server) ListShifts(c *gin.Context) {
+	noCache(c)
+	loc, ok := s.requestLocation(c)
+	if !ok {
+		return
+	}
+	f := repository.ShiftFilter{DeviceID: c.Query("device_id"), Limit: queryLimit(c, 100)}
+	for name, dst := range map[string]*int{"driver_id": &f.DriverID, "vehicle_id": &f.VehicleID} {
+		if v := c.Query(name); v != "" {
+			id, err := strconv.Atoi(v)
+			if err != nil {
+				respondError(c, http.StatusBadRequest, "Invalid "+name)
+				return
+			}
+			*dst = id
+		}
+	}
+	for name, dst := range map[string]*time.Time{"from": &f.Range.From, "to": &f.Range.To} {
+		if v := c.Query(name); v != "" {
+			t, err := parseTimeParam(v, loc)
+			if err != nil {
+				respondError(c, http.StatusBadRequest, "Invalid "+name)
+				return
+			}
+			*dst = t.UTC()
+		}
+	}
+
+	shifts, err := s.store.Shifts.List(c.Request.Context(), f)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error fetching shifts", "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to fetch shifts")
+		return
+	}
+	if shifts == nil {
+		shifts = []models.Shift{}
+	}
+	now := s.now()
+	for i := range shifts {
+		describeShift(&shifts[i], now)
+	}
+	c.JSON(http.StatusOK, gin.H{"count": len(shifts), "shifts": shifts})
+}
+
+// GetShift returns one shift
+func (s *Server) GetShift(c *gin.Context) {
+	id, ok := shiftID(c)
+	if !ok {
+		return
+	}
+	shift, err := s.store.Shifts.GetByID(c.Request.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, "Shift not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error fetching shift", "shift_id", id, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to fetch shift")
+		return
+	}
+	describeShift(shift, s.now())
+	c.JSON(http.StatusOK, shift)
+}
+
+// CreateShift books a driver on a vehicle and device
+func (s *Server) CreateShift(c *gin.Context) {
+	shift, ok := s.bindShift(c)
+	if !ok {
+		return
+	}
+	if err := s.store.Shifts.Create(c.Request.Context(), shift); err != nil {
+		saveShiftError(c, err, "creating")
+		return
+	}
+	slog.InfoContext(c.Request.Context(), "Shift created", "shift_id", shift.ID, "driver_id", shift.DriverID,
+		"vehicle_id", shift.VehicleID, "device_id", shift.DeviceID, "starts_at", shift.StartsAt)
+	describeShift(shift, s.now())
+	c.JSON(http.StatusCreated, shift)
+}
+
+// UpdateShift replaces the assignment and period of a shift
+func (s *Server) UpdateShift(c *gin.Context) {
+	id, ok := shiftID(c)
+	if !ok {
+		return
+	}
+	shift, ok := s.bindShift(c)
+	if !ok {
+		return
+	}
+	shift.ID = id
+	if err := s.store.Shifts.Update(c.Request.Context(), shift); err != nil {
+		saveShiftError(c, err, "updating")
+		return
+	}
+	describeShift(shift, s.now())
+	c.JSON(http.StatusOK, shift)
+}
+
+// EndShift ends a running shift now
+func (s *Server) EndShift(c *gin.Context) {
+	id, ok := shiftID(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	shift, err := s.store.Shifts.GetByID(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, "Shift not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Error fetching shift", "shift_id", id, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to fetch shift")
+		return
+	}
+	now := s.now().UTC()
+	describeShift(shift, now)
+	if shift.Status != "active" {
+		respondError(c, http.StatusConflict, "Shift is "+shift.Status)
+		return
+	}
+	shift.EndsAt = &now
+	if err := s.store.Shifts.Update(ctx, shift); err != nil {
+		saveShiftError(c, err, "ending")
+		return
+	}
+	describeShift(shift, now)
+	c.JSON(http.StatusOK, shift)
+}
+
+// DeleteShift removes a shift; data already ingested keeps its driver
+func (s *Server) DeleteShift(c *gin.Context) {
+	id, ok := shiftID(c)
+	if !ok {
+		return
+	}
+	err := s.store.Shifts.Delete(c.Request.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, "Shift not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error deleting shift", "shift_id", id, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to delete shift")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Shift deleted"})
+}
@@ -0,0 +1,375 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"time"
+
+	"driver-drowsiness-backend/digest"
+	"driver-drowsiness-backend/i18n"
+	"driver-drowsiness-backend/models"
+	"driver-drowsiness-backend/reports"
+	"driver-drowsiness-backend/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// deliveryLease is how long an attempt may take before another replica
+// may try the delivery again
+const deliveryLease = 5 * time.Minute
+
+// fleetLocation returns a fleet with its timezone, else the configured one
+func (s *Server) fleetLocation(ctx context.Context, fleetID int) (*models.Fleet, *time.Location, error) {
+	fleet, err := s.store.Fleets.GetByID(ctx, fleetID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return fleet, s.fleetTimezone(*fleet), nil
+}
+
+// validTarget checks a subscription target against its channel
+func validTarget(channel, target string) bool {
+	if channel == models.ChannelEmail {
+		addr, err := mail.ParseAddress(target)
+		return err == nil && addr.Address == target
+	}
+	u, err := url.Parse(target)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// publicTarget answers 400 unless a webhook target resolves to public
+// addresses only, or internal targets are allowed
+func (s *Server) publicTarget(c *gin.Context, channel, target string) bool {
+	if channel != models.ChannelWebhook || s.cfg.WebhookAllowPrivate {
+		return true
+	}
+	if err := digest.CheckTarget(c.Request.Context(), target); err != nil {
+		slog.WarnContext(c.Request.Context(), "Webhook target refused", "target", target, "error", err)
+		respondError(c, http.StatusBadRequest, "webhook target must resolve to a public address")
+		return false
+	}
+	return true
+}
+
+// CreateSubscription subscribes the caller to a fleet digest
+func (s *Server) CreateSubscription(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req models.SubscriptionRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if req.Format == "" {
+		req.Format = models.DigestHTML
+	}
+	if !digest.Valid(req.Digest, req.Format, req.Channel) {
+		respondError(c, http.StatusBadRequest, "digest must be daily_summary or weekly_compliance, format html, pdf or csv, channel email or webhook")
+		return
+	}
+	if !validTarget(req.Channel, req.Target) {
+		respondError(c, http.StatusBadRequest, "target must be an email address or an http(s) URL")
+		return
+	}
+	if !s.publicTarget(c, req.Channel, req.Target) {
+		return
+	}
+	_, loc, err := s.fleetLocation(ctx, req.FleetID)
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, "Fleet not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Error fetching fleet", "fleet_id", req.FleetID, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to fetch fleet")
+		return
+	}
+
+	lang := req.Lang
+	if lang == "" {
+		lang = c.GetHeader("Accept-Language")
+	}
+	now := s.now().UTC()
+	sub := &models.Subscription{
+		UserID: c.GetInt("user_id"), FleetID: req.FleetID, Digest: req.Digest, Format: req.Format,
+		Channel: req.Channel, Target: req.Target, Secret: req.Secret, Lang: i18n.ParseLang(lang),
+		Active: req.Active == nil || *req.Active, NextRunAt: digest.NextRun(req.Digest, now, loc), CreatedAt: now,
+	}
+	if err := s.store.Subscriptions.Create(ctx, sub); err != nil {
+		slog.ErrorContext(ctx, "Error creating subscription", "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to create subscription")
+		return
+	}
+	slog.InfoContext(ctx, "Subscription created", "user_id", sub.UserID, "target", sub.Target, "digest", sub.Digest, "fleet_id", sub.FleetID)
+	c.JSON(http.StatusCreated, sub)
+}
+
+// ListSubscriptions returns subscriptions, optionally of one user or fleet
+func (s *Server) ListSubscriptions(c *gin.Context) {
+	var f repository.SubscriptionFilter
+	for name, dst := range map[string]*int{"user_id": &f.UserID, "fleet_id": &f.FleetID} {
+		if v := c.Query(name); v != "" {
+			id, err := strconv.Atoi(v)
+			if err != nil || id <= 0 {
+				respondError(c, http.StatusBadRequest, "Invalid "+name)
+				return
+			}
+			*dst = id
+		}
+	}
+	subs, err := s.store.Subscriptions.List(c.Request.Context(), f)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error listing subscriptions", "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to list subscriptions")
+		return
+	}
+	if subs == nil {
+		subs = []models.Subscription{}
+	}
+	noCache(c)
+	c.JSON(http.StatusOK, gin.H{"count": len(subs), "subscriptions": subs})
+}
+
+// subscription fetches the subscription named by the ":id" parameter, answering errors itself
+func (s *Server) subscription(c *gin.Context) (*models.Subscription, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Invalid subscription id")
+		return nil, false
+	}
+	sub, err := s.store.Subscriptions.GetByID(c.Request.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, "Subscription not found")
+		return nil, false
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error fetching subscription", "subscription_id", id, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to fetch subscription")
+		return nil, false
+	}
+	return sub, true
+}
+
+// GetSubscription returns one subscription
+func (s *Server) GetSubscription(c *gin.Context) {
+	if sub, ok := s.subscription(c); ok {
+		noCache(c)
+		c.JSON(http.StatusOK, sub)
+	}
+}
+
+// UpdateSubscription changes how a digest is rendered and delivered, or
+// pauses it. The digest and fleet of a subscription are fixed.
+func (s *Server) UpdateSubscription(c *gin.Context) {
+	sub, ok := s.subscription(c)
+	if !ok {
+		return
+	}
+	var req models.SubscriptionRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if req.Format != "" {
+		sub.Format = req.Format
+	}
+	if req.Channel != "" {
+		sub.Channel = req.Channel
+	}
+	if req.Target != "" {
+		sub.Target = req.Target
+	}
+	if req.Secret != "" {
+		sub.Secret = req.Secret
+	}
+	if req.Lang != "" {
+		sub.Lang = i18n.ParseLang(req.Lang)
+	}
+	if !digest.Valid(sub.Digest, sub.Format, sub.Channel) || !validTarget(sub.Channel, sub.Target) {
+		respondError(c, http.StatusBadRequest, "format must be html, pdf or csv and target an email address or http(s) URL matching channel")
+		return
+	}
+	if !s.publicTarget(c, sub.Channel, sub.Target) {
+		return
+	}
+
+	ctx := c.Request.Context()
+	if req.Active != nil {
+		// Resuming does not send the digests missed while paused
+		if *req.Active && !sub.Active {
+			_, loc, err := s.fleetLocation(ctx, sub.FleetID)
+			if err != nil {
+				loc = s.defaultLocation()
+			}
+			sub.NextRunAt = digest.NextRun(sub.Digest, s.now(), loc)
+		}
+		sub.Active = *req.Active
+	}
+	if err := s.store.Subscriptions.Update(ctx, sub); err != nil {
+		slog.ErrorContext(ctx, "Error updating subscription", "subscription_id", sub.ID, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to update subscription")
+		return
+	}
+	c.JSON(http.StatusOK, sub)
+}
+
+// DeleteSubscription removes a subscription and its delivery history
+func (s *Server) DeleteSubscription(c *gin.Context) {
+	sub, ok := s.subscription(c)
+	if !ok {
+		return
+	}
+	if err := s.store.Subscriptions.Delete(c.Request.Context(), sub.ID); err != nil && !errors.Is(err, repository.ErrNotFound) {
+		slog.ErrorContext(c.Request.Context(), "Error deleting subscription", "subscription_id", sub.ID, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to delete subscription")
+		return
+	}
+	slog.InfoContext(c.Request.Context(), "User deleted subscription", "user_id", c.GetInt("user_id"), "subscription_id", sub.ID)
+	c.JSON(http.StatusOK, gin.H{"success": true, "id": sub.ID})
+}
+
+// ListDeliveries returns the delivery history of a subscription, newest first
+func (s *Server) ListDeliveries(c *gin.Context) {
+	sub, ok := s.subscription(c)
+	if !ok {
+		return
+	}
+	deliveries, err := s.store.Subscriptions.ListDeliveries(c.Request.Context(), sub.ID, queryLimit(c, 50))
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error listing deliveries of subscription", "subscription_id", sub.ID, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to list deliveries")
+		return
+	}
+	if deliveries == nil {
+		deliveries = []models.Delivery{}
+	}
+	noCache(c)
+	c.JSON(http.StatusOK, gin.H{"count": len(deliveries), "deliveries": deliveries})
+}
+
+// renderDigest renders the digest of a subscription over period
+func (s *Server) renderDigest(ctx context.Context, sub *models.Subscription, period repository.TimeRange) (*digest.Content, error) {
+	fleet, loc, err := s.fleetLocation(ctx, sub.FleetID)
+	if err != nil {
+		return nil, err
+	}
+	if sub.Digest == models.DigestWeeklyCompliance {
+		days, err := s.store.Compliance.ArchivedDays(ctx, repository.DrivingFilter{Range: period, FleetID: fleet.ID})
+		if err != nil {
+			return nil, err
+		}
+		names := make(map[int]string)
+		for _, d := range days {
+			if _, ok := names[d.DriverID]; ok {
+				continue
+			}
+			if u, err := s.store.Users.GetByID(ctx, d.DriverID); err == nil {
+				names[d.DriverID] = u.Name
+				if u.Name == "" {
+					names[d.DriverID] = u.Email
+				}
+			}
+		}
+		cp := digest.SummarizeCompliance(fleet.Name, days, names, period, loc)
+		return digest.WeeklyCompliance(cp, sub.Format, sub.Lang, s.reportFont, s.now())
+	}
+
+	counts, err := s.store.Reports.HourlyCounts(ctx, repository.ExportFilter{Range: period, FleetID: fleet.ID})
+	if err != nil {
+		return nil, err
+	}
+	return digest.Daily(reports.Summarize(fleet.Name, counts, period, loc), sub.Format, sub.Lang, s.reportFont, s.now())
+}
+
+// deliver makes one attempt at a claimed delivery and records its outcome
+func (s *Server) deliver(ctx context.Context, d *models.Delivery) {
+	sub, err := s.store.Subscriptions.GetByID(ctx, d.SubscriptionID)
+	if err != nil {
+		slog.WarnContext(ctx, "Could not fetch subscription", "subscription_id", d.SubscriptionID, "error", err)
+		return
+	}
+	content, err := s.renderDigest(ctx, sub, repository.TimeRange{From: d.PeriodFrom, To: d.PeriodTo})
+	if err == nil {
+		sender := s.webhooks
+		if sub.Channel == models.ChannelEmail {
+			sender = s.mailer
+		}
+		err = sender.Send(ctx, digest.Message{To: sub.Target, Secret: sub.Secret, DeliveryID: d.ID, Content: content})
+	}
+
+	now := s.now().UTC()
+	switch {
+	case err == nil:
+		d.Status, d.Error, d.DeliveredAt = models.DeliverySent, "", &now
+		slog.InfoContext(ctx, "Digest delivered", "digest", sub.Digest, "delivery_id", d.ID, "target", sub.Target)
+	case d.Attempts >= digest.MaxAttempts:
+		d.Status, d.Error = models.DeliveryFailed, err.Error()
+		slog.ErrorContext(ctx, "Delivery failed", "delivery_id", d.ID, "target", sub.Target, "attempts", d.Attempts, "error", err)
+	default:
+		d.Error, d.NextAttemptAt = err.Error(), now.Add(digest.Backoff(d.Attempts))
+		slog.WarnContext(ctx, "Delivery failed, retrying", "delivery_id", d.ID, "target", sub.Target,
+			"attempts", d.Attempts, "retry_at", d.NextAttemptAt, "error", err)
+	}
+	if err := s.store.Subscriptions.UpdateDelivery(ctx, d); err != nil {
+		slog.WarnContext(ctx, "Could not record delivery", "delivery_id", d.ID, "error", err)
+	}
+}
+
+// runSubscriptions records a delivery for every subscription that is due
+// and attempts the deliveries due now. Replicas running it at the same
+// time record each delivery once and never attempt one together.
+func (s *Server) runSubscriptions(ctx context.Context) {
+	now := s.now().UTC()
+	due, err := s.store.Subscriptions.Due(ctx, now, 100)
+	if err != nil {
+		slog.WarnContext(ctx, "Could not list due subscriptions", "error", err)
+		return
+	}
+	for _, sub := range due {
+		_, loc, err := s.fleetLocation(ctx, sub.FleetID)
+		if err != nil {
+			loc = s.defaultLocation()
+		}
+		period := digest.Period(sub.Digest, sub.NextRunAt, loc)
+		d := &models.Delivery{
+			SubscriptionID: sub.ID, PeriodFrom: period.From, PeriodTo: period.To,
+			Status: models.DeliveryPending, NextAttemptAt: now, CreatedAt: now,
+		}
+		if err := s.store.Subscriptions.CreateDelivery(ctx, d); err != nil && !errors.Is(err, repository.ErrConflict) {
+			slog.WarnContext(ctx, "Could not schedule delivery of subscription", "subscription_id", sub.ID, "error", err)
+			continue
+		}
+		// Runs missed while the service was down collapse into the one above
+		if _, err := s.store.Subscriptions.Advance(ctx, sub.ID, sub.NextRunAt, digest.NextRun(sub.Digest, now, loc)); err != nil {
+			slog.WarnContext(ctx, "Could not advance subscription", "subscription_id", sub.ID, "error", err)
+		}
+	}
+
+	deliveries, err := s.store.Subscriptions.ClaimDeliveries(ctx, now, deliveryLease, 20)
+	if err != nil {
+		slog.WarnContext(ctx, "Could not claim deliveries", "error", err)
+		return
+	}
+	for i := range deliveries {
+		s.deliver(ctx, &deliveries[i])
+	}
+}
+
+// WatchSubscriptions sends due digests every interval until ctx is done
+func (s *Server) WatchSubscriptions(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.runSubscriptions(ctx)
+			}
+		}
+	}()
+	slog.InfoContext(ctx, "Digest subscriptions scheduled", "interval", interval.String())
+}
@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"driver-drowsiness-backend/models"
+	"driver-drowsiness-backend/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// vehicleID parses the ":id" path parameter
+func vehicleID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Invalid vehicle id")
+		return 0, false
+	}
+	return id, true
+}
+
+// bindVehicle reads a vehicle request body and checks its fleet exists
+func (s *Server) bindVehicle(c *gin.Context) (*models.Vehicle, bool) {
+	var req models.VehicleRequest
+	if !bindJSON(c, &req) {
+		return nil, false
+	}
+	if req.FleetID != 0 {
+		if _, err := s.store.Fleets.GetByID(c.Request.Context(), req.FleetID); err != nil {
+			respondError(c, http.StatusBadRequest, "Unknown fleet")
+			return nil, false
+		}
+	}
+	return &models.Vehicle{
+		PlateNumber: req.PlateNumber,
+		Type:        req.Type,
+		Capacity:    req.Capacity,
+		FleetID:     req.FleetID,
+	}, true
+}
+
+// getVehicle loads the vehicle of the ":id" path parameter, answering 404
+func (s *Server) getVehicle(c *gin.Context) (*models.Vehicle, bool) {
+	id, ok := vehicleID(c)
+	if !ok {
+		return nil, false
+	}
+	v, err := s.store.Vehicles.GetByID(c.Request.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, "Vehicle not found")
+		return nil, false
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error fetching vehicle", "vehicle_id", id, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to fetch vehicle")
+		return nil, false
+	}
+	return v, true
+}
+
+// ListVehicles returns every vehicle with the device installed now
+func (s *Server) ListVehicles(c *gin.Context) {
+	vehicles, err := s.store.Vehicles.List(c.Request.Context())
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error fetching vehicles", "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to fetch vehicles")
+		return
+	}
+	if vehicles == nil {
+		vehicles = []models.Vehicle{}
+	}
+	c.JSON(http.StatusOK, gin.H{"count": len(vehicles), "vehicles": vehicles})
+}
+
+// GetVehicle returns one vehicle
+func (s *Server) GetVehicle(c *gin.Context) {
+	if v, ok := s.getVehicle(c); ok {
+		c.JSON(http.StatusOK, v)
+	}
+}
+
+// CreateVehicle registers a vehicle; plate numbers are unique
+func (s *Server) CreateVehicle(c *gin.Context) {
+	v, ok := s.bindVehicle(c)
+	if !ok {
+		return
+	}
+	err := s.store.Vehicles.Create(c.Request.Context(), v)
+	if errors.Is(err, repository.ErrConflict) {
+		respondError(c, http.StatusConflict, "Plate number already registered")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error creating vehicle", "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to create vehicle")
+		return
+	}
+	slog.InfoContext(c.Request.Context(), "Vehicle registered", "vehicle_id", v.ID, "plate_number", v.PlateNumber)
+	c.JSON(http.StatusCreated, v)
+}
+
+// UpdateVehicle changes the plate, type, capacity or fleet of a vehicle
+func (s *Server) UpdateVehicle(c *gin.Context) {
+	id, ok := vehicleID(c)
+	if !ok {
+		return
+	}
+	v, ok := s.bindVehicle(c)
+	if !ok {
+		return
+	}
+	v.ID = id
+	err := s.store.Vehicles.Update(c.Request.Context(), v)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		respondError(c, http.StatusNotFound, "Vehicle not found")
+		return
+	case errors.Is(err, repository.ErrConflict):
+		respondError(c, http.StatusConflict, "Plate number already registered")
+		return
+	case err != nil:
+		slog.ErrorContext(c.Request.Context(), "Error updating vehicle", "vehicle_id", id, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to update vehicle")
+		return
+	}
+	// Reload so the response carries the installed device
+	if updated, err := s.store.Vehicles.GetByID(c.Request.Context(), id); err == nil {
+		v = updated
+	}
+	c.JSON(http.StatusOK, v)
+}
+
+// InstallDevice mounts a device in a vehicle, moving it out of any other
+// vehicle at the same moment. installed_at defaults to now.
+func (s *Server) InstallDevice(c *gin.Context) {
+	v, ok := s.getVehicle(c)
+	if !ok {
+		return
+	}
+	var req models.InstallRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	at := s.now().UTC()
+	if req.InstalledAt != "" {
+		t, err := parseTimeParam(req.InstalledAt, s.defaultLocation())
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Invalid installed_at")
+			return
+		}
+		at = t.UTC()
+	}
+
+	ctx := c.Request.Context()
+	if _, err := s.store.Devices.Get(ctx, req.DeviceID); err != nil {
+		respondError(c, http.StatusNotFound, "Device not found")
+		return
+	}
+	inst, err := s.store.Vehicles.Install(ctx, req.DeviceID, v.ID, at)
+	if errors.Is(err, repository.ErrConflict) {
+		respondError(c, http.StatusConflict, "installed_at is before the device's current installation")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Error installing device in vehicle", "device_id", req.DeviceID, "vehicle_id", v.ID, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to install device")
+		return
+	}
+	slog.InfoContext(ctx, "Device installed in vehicle", "device_id", req.DeviceID, "vehicle_id", v.ID, "plate_number", v.PlateNumber)
+	c.JSON(http.StatusCreated, inst)
+}
+
+// RemoveDevice ends the installation of a device in a vehicle now
+func (s *Server) RemoveDevice(c *gin.Context) {
+	v, ok := s.getVehicle(c)
+	if !ok {
+		return
+	}
+	deviceID := c.Param("device_id")
+	if v.DeviceID != deviceID {
+		respondError(c, http.StatusNotFound, "Device is not installed in this vehicle")
+		return
+	}
+	err := s.store.Vehicles.Remove(c.Request.Context(), deviceID, s.now().UTC())
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, "Device is not installed in this vehicle")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error removing device from vehicle", "device_id", deviceID, "vehicle_id", v.ID, "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to remove device")
+		return
+	}
+	slog.InfoContext(c.Request.Context(), "Device removed from vehicle", "device_id", deviceID, "vehicle_id", v.ID, "plate_number", v.PlateNumber)
+	c.JSON(http.StatusOK, gin.H{"message": "Device removed"})
+}
+
+// ListInstallations returns installation history newest first.
+// Query: device_id and vehicle_id.
+func (s *Server) ListInstallations(c *gin.Context) {
+	noCache(c)
+	f := repository.InstallationFilter{DeviceID: c.Query("device_id")}
+	if v := c.Query("vehicle_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Invalid vehicle_id")
+			return
+		}
+		f.VehicleID = id
+	}
+	history, err := s.store.Vehicles.Installations(c.Request.Context(), f)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error fetching installations", "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to fetch installations")
+		return
+	}
+	if history == nil {
+		history = []models.DeviceInstallation{}
+	}
+	c.JSON(http.StatusOK, gin.H{"count": len(history), "installations": history})
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"driver-drowsiness-backend/models"
+	"driver-drowsiness-backend/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// API versions. v1 is frozen and also served under the unversioned /api
+// prefix of the first clients; new contracts go to v2.
+const (
+	apiV1 = "v1"
+	apiV2 = "v2"
+)
+
+// apiVersions are the mounted versions, oldest first
+var apiVersions = []string{apiV1, apiV2}
+
+// Default v1 retirement dates, when the config sets none
+var (
+	defaultV1DeprecatedAt = time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	defaultV1Sunset       = time.Date(2027, 5, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// APIVersion tags the requests of a version and counts them; v1 responses
+// announce its deprecation (RFC 9745), sunset (RFC 8594) and successor
+func (s *Server) APIVersion(version string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("api_version", version)
+		if version == apiV1 {
+			h := c.Writer.Header()
+			h.Set("Deprecation", fmt.Sprintf("@%d", s.v1DeprecatedAt.Unix()))
+			h.Set("Sunset", s.v1Sunset.UTC().Format(http.TimeFormat))
+			h.Set("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, successorPath(c.Request.URL.Path)))
+		}
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "(unmatched)"
+		}
+		device := ""
+		if strings.Contains(route, "/devices/:id") {
+			device = c.Param("id")
+		}
+		s.usage.Observe(version, c.Request.Method+" "+route, c.Request.UserAgent(), device, s.now())
+	}
+}
+
+// successorPath maps a v1 path, under /api or /api/v1, to its v2 path
+func successorPath(path string) string {
+	rest := strings.TrimPrefix(path, "/api")
+	if rest == "/v1" || strings.HasPrefix(rest, "/v1/") {
+		rest = rest[len("/v1"):]
+	}
+	return "/api/" + apiV2 + rest
+}
+
+// apiVersionOf returns the API version of a request
+func apiVersionOf(c *gin.Context) string {
+	if v := c.GetString("api_version"); v != "" {
+		return v
+	}
+	return apiV1
+}
+
+// apiPrefix returns the path prefix of the request's API version, the
+// versioned one for v1 too, e.g. "/api/v2"
+func apiPrefix(c *gin.Context) string {
+	return "/api/" + apiVersionOf(c)
+}
+
+// ReceiveDeviceDataV2 receives a sample of API v2, its telemetry nested
+func (s *Server) ReceiveDeviceDataV2(c *gin.Context) {
+	var payload models.DataPayloadV2
+	if !bindJSON(c, &payload) {
+		return
+	}
+	s.ingestData(c, payload.DataPayload())
+}
+
+// GetDeviceHistoryV1 returns the latest samples of a device the way v1
+// always has: up to limit rows, newest first, without filters or links
+func (s *Server) GetDeviceHistoryV1(c *gin.Context) {
+	deviceID := c.Param("id")
+	noCache(c)
+	history, err := s.store.Drowsiness.History(c.Request.Context(), repository.PageFilter{DeviceID: deviceID, Limit: queryLimit(c, 100)})
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error fetching history", "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to fetch history")
+		return
+	}
+	if history == nil {
+		history = []models.DrowsinessData{}
+	}
+	c.JSON(http.StatusOK, gin.H{"device_id": deviceID, "count": len(history), "data": history})
+}
+
+// GetDeviceAlertsV1 returns the latest alerts of a device the way v1 always
+// has: up to limit rows, newest first, without filters or links
+func (s *Server) GetDeviceAlertsV1(c *gin.Context) {
+	deviceID := c.Param("id")
+	noCache(c)
+	alerts, err := s.store.Alerts.ListByDevice(c.Request.Context(), repository.PageFilter{DeviceID: deviceID, Limit: queryLimit(c, 50)})
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error fetching alerts", "error", err)
+		respondError(c, http.StatusInternalServerError, "Failed to fetch alerts")
+		return
+	}
+	if alerts == nil {
+		alerts = []models.Alert{}
+	}
+	c.JSON(http.StatusOK, gin.H{"device_id": deviceID, "count": len(alerts), "alerts": alerts})
+}
+
+// AdminAPIUsage returns the requests of every API version since the server
+// started, to tell when v1 can be retired
+func (s *Server) AdminAPIUsage(c *gin.Context) {
+	noCache(c)
+	u := s.usage.Usage()
+	for i := range u.Versions {
+		if u.Versions[i].Version == apiV1 {
+			sunset := s.v1Sunset
+			u.Versions[i].Deprecated = true
+			u.Versions[i].Sunset = &sunset
+		}
+	}
+	c.JSON(http.StatusOK, u)
+}
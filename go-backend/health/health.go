@@ -0,0 +1,80 @@
+// Package health derives the state of devices from their heartbeats
+package health
+
+import (
+	"fmt"
+	"time"
+
+	"driver-drowsiness-backend/models"
+)
+
+// Thresholds decide when a reporting device counts as degraded or offline
+type Thresholds struct {
+	Timeout    time.Duration // silence after which a device is offline
+	MaxCPUTemp float64       // degraded at or above this CPU temperature (°C)
+	MinFPS     float64       // degraded below this detector frame rate
+}
+
+// DefaultThresholds returns the thresholds used when none are configured
+func DefaultThresholds() Thresholds {
+	return Thresholds{Timeout: 90 * time.Second, MaxCPUTemp: 80, MinFPS: 10}
+}
+
+// Classify returns the state a heartbeat puts a device in and why
+func Classify(p models.HeartbeatPayload, t Thresholds) (state, reason string) {
+	switch p.CameraStatus {
+	case models.CameraBlocked:
+		return models.DeviceCameraBlocked, "camera blocked"
+	case models.CameraError, models.CameraDisconnected:
+		return models.DeviceDegraded, "camera " + p.CameraStatus
+	}
+	if p.CPUTempC != nil && *p.CPUTempC >= t.MaxCPUTemp {
+		return models.DeviceDegraded, fmt.Sprintf("cpu temperature %.1f°C", *p.CPUTempC)
+	}
+	if p.FPS != nil && *p.FPS < t.MinFPS {
+		return models.DeviceDegraded, fmt.Sprintf("low frame rate %.1f fps", *p.FPS)
+	}
+	return models.DeviceOnline, ""
+}
+
+// Unknown is the state reported for time before a device's first heartbeat
+const Unknown = "unknown"
+
+// Uptime splits [from, to) into the states a device was in. initial is the
+// state at from (Unknown if none); events are the transitions within the
+// period, oldest first.
+func Uptime(deviceID, initial string, events []models.DeviceStateEvent, from, to time.Time) models.DeviceUptime {
+	u := models.DeviceUptime{
+		DeviceID:  deviceID,
+		From:      from,
+		To:        to,
+		Seconds:   make(map[string]float64),
+		Intervals: []models.StateInterval{},
+	}
+	if initial == "" {
+		initial = Unknown
+	}
+	state, since := initial, from
+	add := func(until time.Time) {
+		if !until.After(since) {
+			return
+		}
+		secs := until.Sub(since).Seconds()
+		u.Seconds[state] += secs
+		u.Intervals = append(u.Intervals, models.StateInterval{State: state, From: since, To: until, Seconds: secs})
+	}
+	for _, e := range events {
+		if e.At.Before(from) || !e.At.Before(to) {
+			continue
+		}
+		add(e.At)
+		state, since = e.ToState, e.At
+	}
+	add(to)
+
+	known := to.Sub(from).Seconds() - u.Seconds[Unknown]
+	if known > 0 {
+		u.UptimePercent = (known - u.Seconds[models.DeviceOffline]) / known * 100
+	}
+	return u
+}
@@ -0,0 +1,89 @@
+package health
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"driver-drowsiness-backend/models"
+	"driver-drowsiness-backend/repository"
+)
+
+var t0 = time.Date(2025, 11, 9, 3, 0, 0, 0, time.UTC)
+
+func ptr(v float64) *float64 { return &v }
+
+func TestClassify(t *testing.T) {
+	th := DefaultThresholds()
+	cases := []struct {
+		payload models.HeartbeatPayload
+		want    string
+	}{
+		{models.HeartbeatPayload{CPUTempC: ptr(60), FPS: ptr(20)}, models.DeviceOnline},
+		{models.HeartbeatPayload{CPUTempC: ptr(80)}, models.DeviceDegraded},
+		{models.HeartbeatPayload{FPS: ptr(9.5)}, models.DeviceDegraded},
+		{models.HeartbeatPayload{CameraStatus: models.CameraDisconnected}, models.DeviceDegraded},
+		// A blocked camera wins over other faults
+		{models.HeartbeatPayload{CameraStatus: models.CameraBlocked, CPUTempC: ptr(90)}, models.DeviceCameraBlocked},
+	}
+	for _, c := range cases {
+		if got, reason := Classify(c.payload, th); got != c.want || (got != models.DeviceOnline && reason == "") {
+			t.Errorf("Classify(%+v) = %s (%q), want %s", c.payload, got, reason, c.want)
+		}
+	}
+}
+
+func TestMonitorTransitions(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore()
+	m := NewMonitor(store.Health, DefaultThresholds())
+
+	_, first, err := m.Heartbeat(ctx, "device_01", models.HeartbeatPayload{}, t0)
+	if err != nil || first == nil || first.ToState != models.DeviceOnline {
+		t.Fatalf("first heartbeat: event %+v, err %v", first, err)
+	}
+	// Same state: no event and state_since kept
+	h, event, _ := m.Heartbeat(ctx, "device_01", models.HeartbeatPayload{}, t0.Add(30*time.Second))
+	if event != nil || !h.StateSince.Equal(t0) {
+		t.Errorf("repeat heartbeat: health %+v, event %+v", h, event)
+	}
+
+	// Not yet timed out
+	if events, _ := m.Sweep(ctx, t0.Add(110*time.Second)); len(events) != 0 {
+		t.Errorf("early sweep = %+v", events)
+	}
+	events, err := m.Sweep(ctx, t0.Add(5*time.Minute))
+	if err != nil || len(events) != 1 || events[0].ToState != models.DeviceOffline || !events[0].At.Equal(t0.Add(120*time.Second)) {
+		t.Fatalf("sweep = %+v, %v", events, err)
+	}
+	// Offline devices are not swept twice
+	if events, _ := m.Sweep(ctx, t0.Add(10*time.Minute)); len(events) != 0 {
+		t.Errorf("second sweep = %+v", events)
+	}
+
+	_, back, _ := m.Heartbeat(ctx, "device_01", models.HeartbeatPayload{}, t0.Add(20*time.Minute))
+	if back == nil || back.FromState != models.DeviceOffline || back.ToState != models.DeviceOnline {
+		t.Errorf("reconnect event = %+v", back)
+	}
+}
+
+func TestUptime(t *testing.T) {
+	events := []models.DeviceStateEvent{
+		{ToState: models.DeviceOnline, At: t0.Add(10 * time.Minute)},
+		{FromState: models.DeviceOnline, ToState: models.DeviceOffline, At: t0.Add(40 * time.Minute)},
+		{FromState: models.DeviceOffline, ToState: models.DeviceOnline, At: t0.Add(50 * time.Minute)},
+	}
+	u := Uptime("device_01", "", events, t0, t0.Add(time.Hour))
+	if u.Seconds[Unknown] != 600 || u.Seconds[models.DeviceOnline] != 2400 || u.Seconds[models.DeviceOffline] != 600 {
+		t.Errorf("seconds = %v", u.Seconds)
+	}
+	if u.UptimePercent != 80 || len(u.Intervals) != 4 {
+		t.Errorf("uptime = %+v", u)
+	}
+
+	// The state before the period carries over
+	u = Uptime("device_01", models.DeviceOffline, nil, t0, t0.Add(time.Hour))
+	if u.UptimePercent != 0 || u.Seconds[models.DeviceOffline] != 3600 {
+		t.Errorf("offline all period = %+v", u)
+	}
+}
@@ -0,0 +1,101 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"driver-drowsiness-backend/models"
+	"driver-drowsiness-backend/repository"
+)
+
+// Monitor runs the device state machine on top of stored health
+type Monitor struct {
+	thresholds Thresholds
+	repo       repository.HealthRepository
+
+	// Serializes read-modify-write of a device's health
+	mu sync.Mutex
+}
+
+// NewMonitor creates a Monitor applying thresholds
+func NewMonitor(repo repository.HealthRepository, thresholds Thresholds) *Monitor {
+	return &Monitor{thresholds: thresholds, repo: repo}
+}
+
+// Thresholds returns the limits the monitor applies
+func (m *Monitor) Thresholds() Thresholds {
+	return m.thresholds
+}
+
+// Heartbeat records a heartbeat received at at and returns the new health of
+// the device, with the transition it caused, if any
+func (m *Monitor) Heartbeat(ctx context.Context, deviceID string, p models.HeartbeatPayload, at time.Time) (*models.DeviceHealth, *models.DeviceStateEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	prev, err := m.repo.Get(ctx, deviceID)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return nil, nil, err
+	}
+	state, reason := Classify(p, m.thresholds)
+	camera := p.CameraStatus
+	if camera == "" {
+		camera = models.CameraOK
+	}
+	h := &models.DeviceHealth{
+		DeviceID:      deviceID,
+		State:         state,
+		Reason:        reason,
+		StateSince:    at,
+		LastHeartbeat: at,
+		CPUTempC:      p.CPUTempC,
+		CameraStatus:  camera,
+		FPS:           p.FPS,
+		AppVersion:    p.AppVersion,
+	}
+
+	var event *models.DeviceStateEvent
+	switch {
+	case prev == nil:
+		event = &models.DeviceStateEvent{DeviceID: deviceID, ToState: state, Reason: reason, At: at}
+	case prev.State != state:
+		event = &models.DeviceStateEvent{DeviceID: deviceID, FromState: prev.State, ToState: state, Reason: reason, At: at}
+	default:
+		h.StateSince = prev.StateSince
+	}
+	if err := m.repo.Save(ctx, h, event); err != nil {
+		return nil, nil, err
+	}
+	return h, event, nil
+}
+
+// Sweep marks devices silent for longer than the timeout as offline, as of
+// the moment the timeout expired, and returns the transitions
+func (m *Monitor) Sweep(ctx context.Context, now time.Time) ([]models.DeviceStateEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stale, err := m.repo.Stale(ctx, now.Add(-m.thresholds.Timeout))
+	if err != nil {
+		return nil, err
+	}
+	var events []models.DeviceStateEvent
+	for _, h := range stale {
+		at := h.LastHeartbeat.Add(m.thresholds.Timeout)
+		event := models.DeviceStateEvent{
+			DeviceID:  h.DeviceID,
+			FromState: h.State,
+			ToState:   models.DeviceOffline,
+			Reason:    "no heartbeat",
+			At:        at,
+		}
+		h.State, h.Reason, h.StateSince = models.DeviceOffline, event.Reason, at
+		if err := m.repo.Save(ctx, &h, &event); err != nil {
+			return events, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
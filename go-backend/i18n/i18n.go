@@ -0,0 +1,44 @@
+// Package i18n picks the language of a request and holds texts in every
+// supported language
+package i18n
+
+import "strings"
+
+// Supported languages
+const (
+	Thai    = "th"
+	English = "en"
+)
+
+// ParseLang picks the language of a "lang" value or Accept-Language header:
+// the first supported tag in order of preference. No preference gets Thai;
+// a preference only for languages without a translation gets English.
+func ParseLang(s string) string {
+	lang := Thai
+	for _, part := range strings.Split(s, ",") {
+		tag := strings.ToLower(strings.TrimSpace(strings.SplitN(part, ";", 2)[0]))
+		switch {
+		case tag == Thai || strings.HasPrefix(tag, Thai+"-"):
+			return Thai
+		case tag == English || strings.HasPrefix(tag, English+"-"):
+			return English
+		case tag != "" && tag != "*":
+			lang = English
+		}
+	}
+	return lang
+}
+
+// Label is a text in every supported language
+type Label struct {
+	EN string
+	TH string
+}
+
+// In returns the text in lang, falling back to English
+func (l Label) In(lang string) string {
+	if lang == Thai && l.TH != "" {
+		return l.TH
+	}
+	return l.EN
+}
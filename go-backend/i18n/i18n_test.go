@@ -0,0 +1,27 @@
+package i18n
+
+import "testing"
+
+func TestParseLang(t *testing.T) {
+	cases := map[string]string{
+		"":                             Thai,
+		"*":                            Thai,
+		"th":                           Thai,
+		"en":                           English,
+		"EN-us":                        English,
+		"th-TH,en;q=0.8":               Thai,
+		"fr-FR, en-GB;q=0.8, th;q=0.5": English,
+		"de":                           English,
+		"de-DE, th;q=0.5":              Thai,
+		"thai":                         English,
+		"english":                      English,
+	}
+	for in, want := range cases {
+		if got := ParseLang(in); got != want {
+			t.Errorf("ParseLang(%q) = %s, want %s", in, got, want)
+		}
+	}
+	if got := (Label{EN: "Device"}).In(Thai); got != "Device" {
+		t.Errorf("missing Thai label = %q, want the English fallback", got)
+	}
+}
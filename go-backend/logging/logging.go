@@ -0,0 +1,113 @@
+// Package logging sets up structured logging with log/slog: JSON lines with
+// a configurable level, the request ID of the context on every record,
+// emails and secrets redacted, and per-device sampling of chatty logs.
+package logging
+
+import (
+	"context"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Setup makes a logger of level ("debug", "info", "warn" or "error") and
+// format ("json" or "text") writing to stdout the default, and routes the
+// standard log package through it
+func Setup(level, format string) *slog.Logger {
+	logger := New(os.Stdout, ParseLevel(level), format)
+	slog.SetDefault(logger)
+	log.SetFlags(0)
+	log.SetOutput(stdWriter{logger})
+	return logger
+}
+
+// New returns a logger writing records of level and above to w
+func New(w io.Writer, level slog.Level, format string) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: level}
+	var h slog.Handler
+	if strings.EqualFold(format, "text") {
+		h = slog.NewTextHandler(w, opts)
+	} else {
+		h = slog.NewJSONHandler(w, opts)
+	}
+	return slog.New(&handler{next: h})
+}
+
+// ParseLevel parses a level name, defaulting to info
+func ParseLevel(s string) slog.Level {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+		return slog.LevelInfo
+	}
+	return l
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns ctx carrying the ID of its request
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID of ctx, "" outside a request
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// handler adds the request ID of the context and redacts every record
+type handler struct {
+	next slog.Handler
+}
+
+func (h *handler) Enabled(ctx context.Context, l slog.Level) bool {
+	return h.next.Enabled(ctx, l)
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	out := slog.NewRecord(r.Time, r.Level, Redact(r.Message), r.PC)
+	if ctx != nil {
+		if id := RequestID(ctx); id != "" {
+			out.AddAttrs(slog.String("request_id", id))
+		}
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(redactAttr(a))
+		return true
+	})
+	return h.next.Handle(ctx, out)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = redactAttr(a)
+	}
+	return &handler{next: h.next.WithAttrs(redacted)}
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	return &handler{next: h.next.WithGroup(name)}
+}
+
+// stdWriter turns the lines third-party code writes with the standard log
+// package into records, leveled by an emoji prefix if any: ❌ errors,
+// ⚠️ warnings, the rest info. Our own code logs with slog.
+type stdWriter struct {
+	logger *slog.Logger
+}
+
+func (w stdWriter) Write(p []byte) (int, error) {
+	msg := strings.TrimSpace(string(p))
+	level := slog.LevelInfo
+	switch {
+	case strings.HasPrefix(msg, "❌"):
+		level = slog.LevelError
+	case strings.HasPrefix(msg, "⚠"):
+		level = slog.LevelWarn
+	}
+	w.logger.Log(context.Background(), level, msg)
+	return len(p), nil
+}
@@ -0,0 +1,99 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func records(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var out []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var r map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("line %q: %v", line, err)
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, ParseLevel("info"), "json")
+	ctx := WithRequestID(context.Background(), "req-1")
+
+	logger.DebugContext(ctx, "dropped below the level")
+	logger.InfoContext(ctx, "🔑 Reset code for somchai@example.com: 123456",
+		"email", "somchai@example.com", "reset_code", "123456", "err", errors.New(`duplicate key (email)=(a.b@fleet.co.th)`),
+		slog.Group("user", "email", "x@y.io", "id", 7))
+	logger.With("admin", "ops@example.com").Warn("no request")
+
+	recs := records(t, &buf)
+	if len(recs) != 2 {
+		t.Fatalf("records = %v", recs)
+	}
+	r := recs[0]
+	if r["msg"] != "🔑 Reset code for s***@example.com: [REDACTED]" || r["request_id"] != "req-1" || r["level"] != "INFO" {
+		t.Errorf("record = %v", r)
+	}
+	if r["email"] != "s***@example.com" || r["reset_code"] != Redacted || r["err"] != "duplicate key (email)=(a***@fleet.co.th)" {
+		t.Errorf("attrs = %v", r)
+	}
+	if user := r["user"].(map[string]interface{}); user["email"] != "x***@y.io" || user["id"] != float64(7) {
+		t.Errorf("group = %v", user)
+	}
+	if recs[1]["admin"] != "o***@example.com" || recs[1]["request_id"] != nil {
+		t.Errorf("record = %v", recs[1])
+	}
+	if ParseLevel("DEBUG") != slog.LevelDebug || ParseLevel("nonsense") != slog.LevelInfo {
+		t.Error("ParseLevel")
+	}
+}
+
+func TestStdWriter(t *testing.T) {
+	var buf bytes.Buffer
+	std := log.New(stdWriter{New(&buf, slog.LevelInfo, "json")}, "", 0)
+	std.Printf("❌ Error inserting data: %v", "boom")
+	std.Printf("⚠️ Warning: Could not link driver@example.com")
+	std.Println("✅ Database connected successfully")
+
+	recs := records(t, &buf)
+	for i, want := range []string{"ERROR", "WARN", "INFO"} {
+		if recs[i]["level"] != want {
+			t.Errorf("record %d = %v, want %s", i, recs[i], want)
+		}
+	}
+	if recs[1]["msg"] != "⚠️ Warning: Could not link d***@example.com" {
+		t.Errorf("msg = %v", recs[1]["msg"])
+	}
+}
+
+func TestSampler(t *testing.T) {
+	s := NewSampler(10 * time.Second)
+	start := time.Date(2025, 11, 9, 3, 30, 0, 0, time.UTC)
+	if ok, _ := s.Allow("pi-01", start); !ok {
+		t.Fatal("first line held back")
+	}
+	for i := 1; i <= 4; i++ {
+		if ok, _ := s.Allow("pi-01", start.Add(time.Duration(i)*time.Second)); ok {
+			t.Fatalf("line %d let through", i)
+		}
+	}
+	if ok, _ := s.Allow("pi-02", start.Add(time.Second)); !ok {
+		t.Error("other device held back")
+	}
+	if ok, suppressed := s.Allow("pi-01", start.Add(10*time.Second)); !ok || suppressed != 4 {
+		t.Errorf("after the interval: %v, %d suppressed", ok, suppressed)
+	}
+	if ok, _ := NewSampler(0).Allow("pi-01", start); !ok {
+		t.Error("zero interval held back")
+	}
+}
@@ -0,0 +1,62 @@
+package logging
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces secrets in log output
+const Redacted = "[REDACTED]"
+
+// secretKeys are attribute keys whose values are never logged
+var secretKeys = map[string]bool{
+	"password": true, "reset_code": true, "token": true, "authorization": true,
+}
+
+var (
+	emailPattern = regexp.MustCompile(`([A-Za-z0-9._%+-])[A-Za-z0-9._%+-]*@([A-Za-z0-9.-]+\.[A-Za-z]{2,})`)
+	// resetCodePattern finds a code within a few words after "reset code"
+	resetCodePattern = regexp.MustCompile(`(?i)(reset[ _-]?code\b[^0-9\n]{0,60})[0-9]{4,10}`)
+)
+
+// Redact masks emails, keeping their first letter and domain, and the
+// codes of password resets in s
+func Redact(s string) string {
+	if strings.Contains(s, "@") {
+		s = emailPattern.ReplaceAllString(s, "$1***@$2")
+	}
+	if strings.Contains(strings.ToLower(s), "code") {
+		s = resetCodePattern.ReplaceAllString(s, "${1}"+Redacted)
+	}
+	return s
+}
+
+// redactAttr redacts the value of a, recursing into groups; errors and
+// other values are logged as their redacted text
+func redactAttr(a slog.Attr) slog.Attr {
+	if secretKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, Redacted)
+	}
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, Redact(v.String()))
+	case slog.KindGroup:
+		attrs := v.Group()
+		redacted := make([]any, len(attrs))
+		for i, g := range attrs {
+			redacted[i] = redactAttr(g)
+		}
+		return slog.Group(a.Key, redacted...)
+	case slog.KindAny:
+		switch x := v.Any().(type) {
+		case error:
+			return slog.String(a.Key, Redact(x.Error()))
+		case fmt.Stringer:
+			return slog.String(a.Key, Redact(x.String()))
+		}
+	}
+	return slog.Attr{Key: a.Key, Value: v}
+}
@@ -0,0 +1,46 @@
+package logging
+
+import (
+	"sync"
+	"time"
+)
+
+// Sampler lets one log line per key through every interval, e.g. per
+// device, and counts the lines it held back in between
+type Sampler struct {
+	interval time.Duration
+	mu       sync.Mutex
+	keys     map[string]*window
+}
+
+type window struct {
+	start      time.Time
+	suppressed int
+}
+
+// NewSampler returns a sampler; an interval of zero lets every line through
+func NewSampler(interval time.Duration) *Sampler {
+	return &Sampler{interval: interval, keys: map[string]*window{}}
+}
+
+// Allow reports whether the line of key at now is logged and, when it is,
+// how many lines of key were held back since the previous one
+func (s *Sampler) Allow(key string, now time.Time) (bool, int) {
+	if s == nil || s.interval <= 0 {
+		return true, 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w := s.keys[key]
+	if w == nil {
+		s.keys[key] = &window{start: now}
+		return true, 0
+	}
+	if now.Sub(w.start) < s.interval {
+		w.suppressed++
+		return false, 0
+	}
+	suppressed := w.suppressed
+	w.start, w.suppressed = now, 0
+	return true, suppressed
+}
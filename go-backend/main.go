@@ -1,80 +1,112 @@
 package main
 
 import (
-	"log"
+	"context"
+	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
+	_ "time/tzdata" // embed IANA zones so timezone settings work on minimal images
 
 	"driver-drowsiness-backend/config"
 	"driver-drowsiness-backend/database"
 	"driver-drowsiness-backend/handlers"
+	"driver-drowsiness-backend/logging"
+	"driver-drowsiness-backend/repository"
 
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
-	log.Println("🚗 Starting Driver Drowsiness Detection Backend...")
-
-	// Load configuration
+	// Load configuration, then log JSON lines at its level
 	config.LoadConfig()
+	logging.Setup(config.AppConfig.LogLevel, config.AppConfig.LogFormat)
+	slog.Info("Starting Driver Drowsiness Detection Backend")
+	config.LogSummary()
 
 	// Connect to database
 	if err := database.Connect(); err != nil {
-		log.Fatalf("❌ Failed to connect to database: %v", err)
+		fatal("Failed to connect to database", err)
 	}
 	defer database.Close()
 
 	// Run migrations
 	if err := database.Migrate(); err != nil {
-		log.Fatalf("❌ Failed to run migrations: %v", err)
+		fatal("Failed to run migrations", err)
 	}
 
-	// Initial purge of previous days' data (retain only today)
-	if err := database.PurgeNonTodayData(); err != nil {
-		log.Printf("⚠️ Initial purge encountered an error: %v", err)
+	// Wire handlers to the PostgreSQL repositories
+	server := handlers.NewServer(repository.NewPostgresStore(database.DB), config.AppConfig)
+
+	// Report the connection pool at /metrics
+	server.CollectDBStats(database.DB)
+
+	// Initial purge of previous days' data (retain only today of each fleet)
+	purge := server.TimeJob(handlers.JobDailyPurge, server.PurgeOldData)
+	if err := purge(); err != nil {
+		slog.Warn("Initial purge encountered an error", "error", err)
 	}
 
-	// Schedule daily purge at midnight UTC
-	database.ScheduleDailyPurge()
+	// Schedule the purge at midnight of the business timezone and of every fleet's
+	database.ScheduleDailyPurge(server.PurgeLocations, purge)
+
+	// Mark devices offline when their heartbeats stop
+	server.WatchDevices(context.Background(), 15*time.Second)
+
+	// Delete alert evidence past its retention
+	server.WatchEvidence(context.Background(), time.Hour)
+
+	// Generate last week's PDF report of every fleet
+	server.WatchReports(context.Background(), time.Hour)
+
+	// Render and deliver subscribed digests when they are due
+	server.WatchSubscriptions(context.Background(), time.Minute)
 
 	// Setup Gin router
-	router := setupRouter()
+	router := setupRouter(server)
 
 	// Setup graceful shutdown
 	go func() {
 		sigChan := make(chan os.Signal, 1)
 		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 		<-sigChan
-		log.Println("\n🛑 Shutting down gracefully...")
+		slog.Info("Shutting down gracefully")
 		database.Close()
 		os.Exit(0)
 	}()
 
 	// Start server
 	port := config.AppConfig.ServerPort
-	log.Printf("🚀 Server starting on port %s", port)
-	log.Printf("📡 API Endpoint: http://localhost:%s", port)
-	log.Printf("💚 Health Check: http://localhost:%s/health", port)
+	slog.Info("Server starting", "port", port, "api", "http://localhost:"+port+"/api/v2", "health", "http://localhost:"+port+"/health")
 
 	if err := router.Run(":" + port); err != nil {
-		log.Fatalf("❌ Failed to start server: %v", err)
+		fatal("Failed to start server", err)
 	}
 }
 
-func setupRouter() *gin.Engine {
+// fatal logs err and exits
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+func setupRouter(server *handlers.Server) *gin.Engine {
 	// Set Gin mode
 	if config.AppConfig.Environment == "production" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	router := gin.Default()
+	// Requests are logged by the server's AccessLog instead of gin's text logger
+	router := gin.New()
+	router.Use(gin.Recovery())
 
 	// Simple CORS middleware: allow all origins, handle preflight
 	router.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type, Authorization, Accept, Cache-Control, Pragma")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type, Authorization, Accept, Accept-Language, Cache-Control, Pragma, X-Request-ID")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "X-Request-ID")
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
 			return
@@ -82,57 +114,15 @@ func setupRouter() *gin.Engine {
 		c.Next()
 	})
 
-	// Root endpoints
-	router.GET("/", handlers.Root)
-	router.GET("/health", handlers.HealthCheck)
-	router.GET("/.well-known/appspecific/com.chrome.devtools.json", handlers.DevToolsManifest)
-
 	// API routes
-	api := router.Group("/api")
-	{
-		// Auth routes
-		api.POST("/auth/register", handlers.Register)
-		api.POST("/auth/login", handlers.Login)
-		api.GET("/auth/me", handlers.AuthMiddleware(), handlers.Me)
-		api.POST("/auth/forgot-password", handlers.ForgotPassword)
-		api.POST("/auth/reset-password", handlers.ResetPassword)
-
-		// Health check
-		api.GET("/health", handlers.HealthCheck)
-
-		// Seed admin (one-time setup, should be removed in production)
-		api.POST("/seed/admin", handlers.SeedAdmin)
-
-		// Device routes
-		devices := api.Group("/devices")
-		{
-			// Public device list could be protected later
-			devices.GET("", handlers.GetAllDevices)
-
-			// Device-specific routes
-			// These could require AuthMiddleware() later
-			devices.POST("/:id/data", handlers.ReceiveDeviceData)  // Python sends data here
-			devices.POST("/:id/alert", handlers.ReceiveAlert)      // Python sends alerts here
-			devices.GET("/:id/data", handlers.GetDeviceLatestData) // Frontend gets latest data
-			devices.GET("/:id/history", handlers.GetDeviceHistory) // Frontend gets history
-			devices.GET("/:id/alerts", handlers.GetDeviceAlerts)   // Frontend gets alerts
-		}
-
-		// Admin routes (protected)
-		admin := api.Group("/admin", handlers.AuthMiddleware())
-		{
-			admin.GET("/overview", handlers.AdminOverview)
-			admin.GET("/drivers", handlers.AdminDrivers)
-			admin.GET("/recent-alerts", handlers.AdminRecentAlerts)
-			admin.GET("/alert-slots", handlers.AdminAlertSlots)
-			admin.GET("/alert-levels", handlers.AdminAlertLevels)
-		}
-	}
-
-	// Log all routes
-	log.Println("📍 Available API endpoints:")
-	for _, route := range router.Routes() {
-		log.Printf("   %s %s", route.Method, route.Path)
+	server.RegisterRoutes(router)
+	router.NoRoute(server.NotFound)
+
+	// Log all routes at debug level
+	routes := router.Routes()
+	slog.Info("API endpoints registered, see /api/v2/docs", "routes", len(routes))
+	for _, route := range routes {
+		slog.Debug("route", "method", route.Method, "path", route.Path)
 	}
 
 	return router
@@ -0,0 +1,355 @@
+// Package metrics keeps counters, gauges and histograms in memory and
+// writes them in the Prometheus text exposition format (version 0.0.4).
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the content type of the exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefBuckets are histogram buckets in seconds suited to HTTP latencies
+var DefBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Registry holds the metrics of a process in registration order
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+// NewRegistry returns an empty registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// metric writes its samples after its HELP and TYPE lines
+type metric interface {
+	describe() (name, help, typ string)
+	write(w *bufio.Writer)
+}
+
+func (r *Registry) register(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics = append(r.metrics, m)
+}
+
+// WriteTo writes every metric in the exposition format
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	metrics := append([]metric(nil), r.metrics...)
+	r.mu.Unlock()
+	cw := &countWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, m := range metrics {
+		name, help, typ := m.describe()
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s %s\n", name, escapeHelp(help), name, typ)
+		m.write(bw)
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Other replaces the label values of series past a vec's limit
+const Other = "other"
+
+// vec keeps one value per combination of label values
+type vec struct {
+	name, help string
+	labels     []string
+	mu         sync.Mutex
+	series     map[string][]string // key → label values
+	max        int                 // distinct series before Other, 0 for no limit
+}
+
+func newVec(name, help string, labels []string) vec {
+	return vec{name: name, help: help, labels: labels, series: map[string][]string{}}
+}
+
+// key returns the key of the label values, registering them; it panics on
+// a wrong number of values, a programming error
+func (v *vec) key(values []string) string {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", v.name, len(v.labels), len(values)))
+	}
+	k := strings.Join(values, "\xff")
+	if _, ok := v.series[k]; !ok {
+		if v.max > 0 && len(v.series) >= v.max {
+			values = make([]string, len(v.labels))
+			for i := range values {
+				values[i] = Other
+			}
+			k = strings.Join(values, "\xff")
+			if _, ok := v.series[k]; ok {
+				return k
+			}
+		}
+		v.series[k] = append([]string(nil), values...)
+	}
+	return k
+}
+
+// keys returns the registered keys in order of their label values
+func (v *vec) keys() []string {
+	keys := make([]string, 0, len(v.series))
+	for k := range v.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// labelPairs renders label values as {a="x",b="y"}, with extra pairs last
+func (v *vec) labelPairs(values []string, extra ...string) string {
+	if len(values) == 0 && len(extra) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range v.labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `%s="%s"`, name, escapeLabel(values[i]))
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		if b.Len() > 1 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `%s="%s"`, extra[i], escapeLabel(extra[i+1]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// Counter is a value that only goes up, per combination of label values
+type Counter struct {
+	vec
+	values map[string]float64
+}
+
+// Counter registers a counter; its name should end in _total
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	c := &Counter{vec: newVec(name, help, labels), values: map[string]float64{}}
+	r.register(c)
+	return c
+}
+
+// Limit counts the label values past the first n distinct ones under
+// Other, bounding the series of a label such as a device ID
+func (c *Counter) Limit(n int) *Counter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.max = n
+	return c
+}
+
+// Inc adds one to the counter of the label values
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds v, which must not be negative, to the counter of the label values
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[c.key(labelValues)] += v
+}
+
+// Value returns the counter of the label values
+func (c *Counter) Value(labelValues ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[strings.Join(labelValues, "\xff")]
+}
+
+func (c *Counter) describe() (string, string, string) { return c.name, c.help, "counter" }
+
+func (c *Counter) write(w *bufio.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, k := range c.keys() {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, c.labelPairs(c.series[k]), formatFloat(c.values[k]))
+	}
+}
+
+// Gauge is a value that goes up and down, per combination of label values
+type Gauge struct {
+	vec
+	values map[string]float64
+}
+
+// Gauge registers a gauge
+func (r *Registry) Gauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{vec: newVec(name, help, labels), values: map[string]float64{}}
+	r.register(g)
+	return g
+}
+
+// Set sets the gauge of the label values
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.values[g.key(labelValues)] = v
+}
+
+// Value returns the gauge of the label values
+func (g *Gauge) Value(labelValues ...string) float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.values[strings.Join(labelValues, "\xff")]
+}
+
+func (g *Gauge) describe() (string, string, string) { return g.name, g.help, "gauge" }
+
+func (g *Gauge) write(w *bufio.Writer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, k := range g.keys() {
+		fmt.Fprintf(w, "%s%s %s\n", g.name, g.labelPairs(g.series[k]), formatFloat(g.values[k]))
+	}
+}
+
+// Histogram counts observations in cumulative buckets, per combination of
+// label values
+type Histogram struct {
+	vec
+	buckets []float64
+	counts  map[string][]uint64 // per bucket, not cumulative
+	sums    map[string]float64
+	totals  map[string]uint64
+}
+
+// Histogram registers a histogram with the given upper bounds, ascending
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{
+		vec:     newVec(name, help, labels),
+		buckets: append([]float64(nil), buckets...),
+		counts:  map[string][]uint64{},
+		sums:    map[string]float64{},
+		totals:  map[string]uint64{},
+	}
+	sort.Float64s(h.buckets)
+	r.register(h)
+	return h
+}
+
+// Observe records v for the label values
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	k := h.key(labelValues)
+	counts := h.counts[k]
+	if counts == nil {
+		counts = make([]uint64, len(h.buckets))
+		h.counts[k] = counts
+	}
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		counts[i]++
+	}
+	h.sums[k] += v
+	h.totals[k]++
+}
+
+// Count returns the number of observations of the label values
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.totals[strings.Join(labelValues, "\xff")]
+}
+
+func (h *Histogram) describe() (string, string, string) { return h.name, h.help, "histogram" }
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, k := range h.keys() {
+		values := h.series[k]
+		var cumulative uint64
+		for i, le := range h.buckets {
+			cumulative += h.counts[k][i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelPairs(values, "le", formatFloat(le)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelPairs(values, "le", "+Inf"), h.totals[k])
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.labelPairs(values), formatFloat(h.sums[k]))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.labelPairs(values), h.totals[k])
+	}
+}
+
+// Collector reads values when the metrics are scraped, e.g. from a
+// connection pool; set reports the value of one combination of label values
+type Collector func(set func(v float64, labelValues ...string))
+
+// collected is a gauge or counter whose values a Collector reads on scrape
+type collected struct {
+	vec
+	typ     string
+	collect Collector
+}
+
+// GaugeFunc registers a gauge read by collect on every scrape
+func (r *Registry) GaugeFunc(name, help string, collect Collector, labels ...string) {
+	r.register(&collected{vec: newVec(name, help, labels), typ: "gauge", collect: collect})
+}
+
+// CounterFunc registers a counter read by collect on every scrape
+func (r *Registry) CounterFunc(name, help string, collect Collector, labels ...string) {
+	r.register(&collected{vec: newVec(name, help, labels), typ: "counter", collect: collect})
+}
+
+func (c *collected) describe() (string, string, string) { return c.name, c.help, c.typ }
+
+func (c *collected) write(w *bufio.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.series = map[string][]string{}
+	values := map[string]float64{}
+	c.collect(func(v float64, labelValues ...string) {
+		values[c.key(labelValues)] = v
+	})
+	for _, k := range c.keys() {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, c.labelPairs(c.series[k]), formatFloat(values[k]))
+	}
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
@@ -0,0 +1,73 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteTo(t *testing.T) {
+	r := NewRegistry()
+	requests := r.Counter("http_requests_total", "HTTP requests.", "method", "status")
+	requests.Inc("GET", "200")
+	requests.Inc("GET", "200")
+	requests.Add(3, "POST", "400")
+	requests.Add(-1, "POST", "400") // ignored
+	r.Gauge("up", "Whether the\nserver is up.").Set(1)
+	latency := r.Histogram("latency_seconds", "Latency.", []float64{1, 0.1}, "route")
+	latency.Observe(0.05, `/a"b`)
+	latency.Observe(0.5, `/a"b`)
+	latency.Observe(3, `/a"b`)
+	r.GaugeFunc("pool_connections", "Connections by state.", func(set func(float64, ...string)) {
+		set(2, "idle")
+		set(1, "in_use")
+	}, "state")
+
+	var b strings.Builder
+	if _, err := r.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP http_requests_total HTTP requests.
+# TYPE http_requests_total counter
+http_requests_total{method="GET",status="200"} 2
+http_requests_total{method="POST",status="400"} 3
+# HELP up Whether the\nserver is up.
+# TYPE up gauge
+up 1
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="/a\"b",le="0.1"} 1
+latency_seconds_bucket{route="/a\"b",le="1"} 2
+latency_seconds_bucket{route="/a\"b",le="+Inf"} 3
+latency_seconds_sum{route="/a\"b"} 3.55
+latency_seconds_count{route="/a\"b"} 3
+# HELP pool_connections Connections by state.
+# TYPE pool_connections gauge
+pool_connections{state="idle"} 2
+pool_connections{state="in_use"} 1
+`
+	if b.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", b.String(), want)
+	}
+	if requests.Value("GET", "200") != 2 || latency.Count(`/a"b`) != 3 {
+		t.Errorf("values = %v, %d", requests.Value("GET", "200"), latency.Count(`/a"b`))
+	}
+}
+
+func TestLimit(t *testing.T) {
+	r := NewRegistry()
+	samples := r.Counter("samples_total", "Samples.", "device_id").Limit(2)
+	for _, device := range []string{"a", "b", "c", "a", "d", "c"} {
+		samples.Inc(device)
+	}
+	var b strings.Builder
+	r.WriteTo(&b)
+	want := `# HELP samples_total Samples.
+# TYPE samples_total counter
+samples_total{device_id="a"} 2
+samples_total{device_id="b"} 1
+samples_total{device_id="other"} 3
+`
+	if b.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", b.String(), want)
+	}
+}
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// Remote command types
+const (
+	CommandAlarm        = "alarm"         // sound the alarm in the cab
+	CommandVoiceMessage = "voice_message" // speak Message to the driver
+	CommandSnapshot     = "snapshot"      // capture a camera snapshot
+	CommandRestart      = "restart"       // restart the detector
+)
+
+// Command delivery statuses
+const (
+	CommandQueued    = "queued"
+	CommandDelivered = "delivered" // handed to the device, not acknowledged yet
+	CommandSucceeded = "succeeded"
+	CommandFailed    = "failed"
+	CommandExpired   = "expired" // not acknowledged before expires_at
+	CommandCancelled = "cancelled"
+)
+
+// DeviceCommand is a command queued for a device
+type DeviceCommand struct {
+	ID              int        `json:"id" db:"id"`
+	DeviceID        string     `json:"device_id" db:"device_id"`
+	Type            string     `json:"type" db:"type"`
+	Message         string     `json:"message,omitempty" db:"message"`
+	DurationSeconds int        `json:"duration_seconds,omitempty" db:"duration_seconds"`
+	Status          string     `json:"status" db:"status"`
+	IssuedBy        int        `json:"issued_by" db:"issued_by"` // admin user id
+	Attempts        int        `json:"attempts" db:"attempts"`   // times handed to the device
+	Result          string     `json:"result,omitempty" db:"result"`
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+	ExpiresAt       time.Time  `json:"expires_at" db:"expires_at"`
+	DeliveredAt     *time.Time `json:"delivered_at,omitempty" db:"delivered_at"`
+	CompletedAt     *time.Time `json:"completed_at,omitempty" db:"completed_at"`
+}
+
+// Final reports whether the command can no longer change status
+func (c *DeviceCommand) Final() bool {
+	switch c.Status {
+	case CommandSucceeded, CommandFailed, CommandExpired, CommandCancelled:
+		return true
+	}
+	return false
+}
+
+// CommandEvent is one entry of the audit trail of a command
+type CommandEvent struct {
+	ID        int       `json:"id" db:"id"`
+	CommandID int       `json:"command_id" db:"command_id"`
+	Status    string    `json:"status" db:"status"`
+	Actor     string    `json:"actor" db:"actor"` // "user:<id>", "device" or "system"
+	Note      string    `json:"note,omitempty" db:"note"`
+	At        time.Time `json:"at" db:"at"`
+}
+
+// CommandRequest queues a command for a device
+type CommandRequest struct {
+	Type            string `json:"type" binding:"required"`
+	Message         string `json:"message"`
+	DurationSeconds int    `json:"duration_seconds"`
+	TTLSeconds      int    `json:"ttl_seconds"` // time to acknowledge; 0 uses the default
+}
+
+// Validate checks the command type and its parameters
+func (r *CommandRequest) Validate() error {
+	switch r.Type {
+	case CommandAlarm:
+		if r.DurationSeconds < 0 || r.DurationSeconds > 300 {
+			return errors.New("duration_seconds must be between 0 and 300")
+		}
+	case CommandVoiceMessage:
+		r.Message = strings.TrimSpace(r.Message)
+		if r.Message == "" || len([]rune(r.Message)) > 500 {
+			return errors.New("voice_message needs a message of at most 500 characters")
+		}
+	case CommandSnapshot, CommandRestart:
+	default:
+		return errors.New("type must be alarm, voice_message, snapshot or restart")
+	}
+	if r.TTLSeconds < 0 || r.TTLSeconds > 86400 {
+		return errors.New("ttl_seconds must be between 0 and 86400")
+	}
+	return nil
+}
+
+// CommandAck is a device's acknowledgement of a command
+type CommandAck struct {
+	Status string `json:"status" binding:"required,oneof=succeeded failed"`
+	Result string `json:"result"` // e.g. a snapshot reference or an error message
+}
@@ -0,0 +1,84 @@
+package models
+
+import "time"
+
+// Compliance states of a driver
+const (
+	ComplianceOK        = "ok"
+	ComplianceWarning   = "warning"   // a limit is close
+	ComplianceViolation = "violation" // a limit is exceeded
+)
+
+// ComplianceStatus is the live driving time of a driver against the limits
+type ComplianceStatus struct {
+	DriverID               int        `json:"driver_id"`
+	State                  string     `json:"state"`
+	ContinuousSeconds      float64    `json:"continuous_seconds"` // driving since the last break
+	ContinuousLimitSeconds float64    `json:"continuous_limit_seconds"`
+	BreakDueInSeconds      float64    `json:"break_due_in_seconds"` // negative when overdue
+	MinBreakSeconds        float64    `json:"min_break_seconds"`
+	DailySeconds           float64    `json:"daily_seconds"` // driving in the current business day
+	DailyLimitSeconds      float64    `json:"daily_limit_seconds"`
+	DailyRemainingSeconds  float64    `json:"daily_remaining_seconds"` // negative when exceeded
+	LastDrivingAt          *time.Time `json:"last_driving_at,omitempty"`
+}
+
+// ComplianceEvent is a driving-time limit that was approached or exceeded
+type ComplianceEvent struct {
+	Type           string    `json:"type"` // continuous_warning, continuous_violation, daily_warning, daily_violation
+	At             time.Time `json:"at"`
+	DrivingSeconds float64   `json:"driving_seconds"`
+	LimitSeconds   float64   `json:"limit_seconds"`
+}
+
+// ComplianceDay is the driving time of one business day
+type ComplianceDay struct {
+	Date           string  `json:"date"` // YYYY-MM-DD in the report timezone
+	DrivingSeconds float64 `json:"driving_seconds"`
+	Exceeded       bool    `json:"exceeded"`
+}
+
+// DriverCompliance is the compliance report of one driver over a period
+type DriverCompliance struct {
+	DriverID                 int               `json:"driver_id"`
+	Name                     string            `json:"name"`
+	FleetID                  int               `json:"fleet_id,omitempty"`
+	DrivingSeconds           float64           `json:"driving_seconds"`
+	LongestContinuousSeconds float64           `json:"longest_continuous_seconds"`
+	Breaks                   int               `json:"breaks"`
+	Warnings                 int               `json:"warnings"`
+	Violations               []ComplianceEvent `json:"violations"`
+	Days                     []ComplianceDay   `json:"days"`
+	Compliant                bool              `json:"compliant"`
+}
+
+// FleetCompliance aggregates the driver reports of one fleet
+type FleetCompliance struct {
+	FleetID          int     `json:"fleet_id"`
+	Name             string  `json:"name"`
+	Drivers          int     `json:"drivers"`
+	CompliantDrivers int     `json:"compliant_drivers"`
+	Violations       int     `json:"violations"`
+	DrivingSeconds   float64 `json:"driving_seconds"`
+}
+
+// ComplianceState is the live driving time of a driver, saved as samples
+// arrive so a restart resumes it without the samples the daily purge deletes
+type ComplianceState struct {
+	DriverID          int       `json:"driver_id" db:"driver_id"`
+	LastDrivingAt     time.Time `json:"last_driving_at" db:"last_driving_at"`
+	ContinuousSeconds float64   `json:"continuous_seconds" db:"continuous_seconds"`
+	DayStart          time.Time `json:"day_start" db:"day_start"` // midnight in the business timezone
+	DailySeconds      float64   `json:"daily_seconds" db:"daily_seconds"`
+	Fired             []string  `json:"fired" db:"fired"` // events already raised this streak or day
+}
+
+// ComplianceArchiveDay is the driving time of a driver on one business day,
+// kept when the daily purge deletes the samples it was derived from
+type ComplianceArchiveDay struct {
+	Day            time.Time `json:"day" db:"day"` // midnight in the business timezone
+	DriverID       int       `json:"driver_id" db:"driver_id"`
+	DrivingSeconds float64   `json:"driving_seconds" db:"driving_seconds"`
+	Exceeded       bool      `json:"exceeded" db:"exceeded"`
+	Violations     int       `json:"violations" db:"violations"`
+}
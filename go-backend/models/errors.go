@@ -0,0 +1,34 @@
+package models
+
+// Error codes of APIError. Every other status maps to its lowercased
+// status text, e.g. "too_many_requests".
+const (
+	CodeBadRequest   = "bad_request"
+	CodeInvalidJSON  = "invalid_json"      // the body is not JSON or has mistyped fields
+	CodeValidation   = "validation_failed" // see Details
+	CodeUnauthorized = "unauthorized"
+	CodeForbidden    = "forbidden"
+	CodeNotFound     = "not_found"
+	CodeConflict     = "conflict"
+	CodeInternal     = "internal_error"
+)
+
+// APIError is the body of every error response, under "error"
+type APIError struct {
+	Code      string       `json:"code"`
+	Message   string       `json:"message"`
+	Details   []FieldError `json:"details,omitempty"`
+	RequestID string       `json:"request_id,omitempty"` // also sent as X-Request-ID
+}
+
+// FieldError is one field of a payload failing validation
+type FieldError struct {
+	Field   string `json:"field"` // JSON name, dotted for nested fields
+	Rule    string `json:"rule"`  // e.g. "required", "max", "password"
+	Message string `json:"message"`
+}
+
+// ErrorResponse wraps an APIError
+type ErrorResponse struct {
+	Error APIError `json:"error"`
+}
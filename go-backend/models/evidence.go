@@ -0,0 +1,60 @@
+package models
+
+import "time"
+
+// Evidence kinds
+const (
+	EvidenceSnapshot = "snapshot" // JPEG image
+	EvidenceClip     = "clip"     // short video
+)
+
+// Evidence is a snapshot or clip a device uploaded for an alert. The alert
+// fields are copied because alerts are purged daily while evidence follows
+// its own retention.
+type Evidence struct {
+	ID           int       `json:"id" db:"id"`
+	AlertID      int       `json:"alert_id" db:"alert_id"`
+	DeviceID     string    `json:"device_id" db:"device_id"`
+	AlertType    string    `json:"alert_type" db:"alert_type"`
+	Severity     string    `json:"severity" db:"severity"`
+	AlertAt      time.Time `json:"alert_at" db:"alert_at"`
+	Kind         string    `json:"kind" db:"kind"`
+	ContentType  string    `json:"content_type" db:"content_type"`
+	Size         int64     `json:"size" db:"size"`
+	SHA256       string    `json:"sha256" db:"sha256"`
+	StorageKey   string    `json:"-" db:"storage_key"`
+	ThumbnailKey string    `json:"-" db:"thumbnail_key"` // empty when no thumbnail could be made
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	ExpiresAt    time.Time `json:"expires_at" db:"expires_at"` // deleted after this
+
+	// Signed download URLs, filled on read
+	URL          string     `json:"url,omitempty"`
+	ThumbnailURL string     `json:"thumbnail_url,omitempty"`
+	URLsExpireAt *time.Time `json:"urls_expire_at,omitempty"`
+}
+
+// EvidenceUpload is a resumable chunked upload in progress
+type EvidenceUpload struct {
+	ID          string    `json:"upload_id"`
+	DeviceID    string    `json:"device_id"`
+	AlertID     int       `json:"alert_id"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	Received    int64     `json:"offset"` // bytes received so far; the next chunk starts here
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	// The alert as it was when the upload started, for when the daily purge
+	// deletes it before the last chunk arrives
+	AlertType string    `json:"-"`
+	Severity  string    `json:"-"`
+	AlertAt   time.Time `json:"-"`
+
+	LockedUntil *time.Time `json:"-"` // while a chunk is being written
+}
+
+// EvidenceUploadRequest starts a chunked upload
+type EvidenceUploadRequest struct {
+	ContentType string `json:"content_type" binding:"required"`
+	Size        int64  `json:"size" binding:"required,min=1"`
+}
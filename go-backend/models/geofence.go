@@ -0,0 +1,88 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// Geofence is a named polygon such as a depot, highway or accident hotspot
+type Geofence struct {
+	ID        int            `json:"id" db:"id"`
+	Name      string         `json:"name" db:"name"`
+	Kind      string         `json:"kind" db:"kind"`       // free-form, e.g. "depot", "highway", "hotspot"
+	Polygon   [][2]float64   `json:"polygon" db:"polygon"` // outer ring of [lon, lat] positions, as in GeoJSON
+	Rules     []GeofenceRule `json:"rules" db:"rules"`
+	CreatedAt time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at" db:"updated_at"`
+}
+
+// GeofenceRule changes alert handling while a vehicle is inside a geofence
+type GeofenceRule struct {
+	Level    string `json:"level"`              // minimum drowsiness level: low, medium or high
+	Severity string `json:"severity,omitempty"` // severity given to matching alerts
+	Escalate bool   `json:"escalate"`           // raise an alert as soon as a matching sample arrives
+}
+
+// GeofenceRequest creates or replaces a geofence
+type GeofenceRequest struct {
+	Name    string         `json:"name" binding:"required"`
+	Kind    string         `json:"kind"`
+	Polygon [][2]float64   `json:"polygon" binding:"required"`
+	Rules   []GeofenceRule `json:"rules"`
+}
+
+// GeofenceEvent records a device entering or leaving a geofence
+type GeofenceEvent struct {
+	ID         int       `json:"id" db:"id"`
+	GeofenceID int       `json:"geofence_id" db:"geofence_id"`
+	DeviceID   string    `json:"device_id" db:"device_id"`
+	Event      string    `json:"event" db:"event"` // "enter" or "exit"
+	Latitude   float64   `json:"lat" db:"latitude"`
+	Longitude  float64   `json:"lon" db:"longitude"`
+	Timestamp  time.Time `json:"timestamp" db:"timestamp"`
+}
+
+// LevelRank orders drowsiness levels and alert severities; 0 is unknown
+func LevelRank(level string) int {
+	switch strings.ToLower(level) {
+	case "low", "info":
+		return 1
+	case "medium", "warning":
+		return 2
+	case "high", "danger":
+		return 3
+	case "critical":
+		return 4
+	}
+	return 0
+}
+
+// Validate checks the polygon ring and rules of a geofence request
+func (r *GeofenceRequest) Validate() error {
+	ring := r.Polygon
+	// A closed ring repeats its first position at the end
+	if len(ring) > 1 && ring[0] == ring[len(ring)-1] {
+		ring = ring[:len(ring)-1]
+	}
+	if len(ring) < 3 {
+		return errors.New("polygon needs at least 3 distinct positions")
+	}
+	for _, p := range ring {
+		if !between(p[0], -180, 180) || !between(p[1], -90, 90) {
+			return errors.New("polygon positions must be [lon, lat] within range")
+		}
+	}
+	for _, rule := range r.Rules {
+		if rank := LevelRank(rule.Level); rank < 1 || rank > 3 {
+			return errors.New("rule level must be low, medium or high")
+		}
+		if rule.Severity != "" && LevelRank(rule.Severity) == 0 {
+			return errors.New("rule severity must be low, medium, high or critical")
+		}
+		if rule.Severity == "" && !rule.Escalate {
+			return errors.New("rule needs a severity or escalate")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package models
+
+import "time"
+
+// Device health states
+const (
+	DeviceOnline        = "online"
+	DeviceDegraded      = "degraded"       // reporting, but hot, slow or with a faulty camera
+	DeviceCameraBlocked = "camera_blocked" // the camera cannot see the driver
+	DeviceOffline       = "offline"        // no heartbeat within the timeout
+)
+
+// Camera statuses reported in heartbeats
+const (
+	CameraOK           = "ok"
+	CameraBlocked      = "blocked"
+	CameraError        = "error"
+	CameraDisconnected = "disconnected"
+)
+
+// HeartbeatPayload is the periodic health report of a device
+type HeartbeatPayload struct {
+	CPUTempC     *float64 `json:"cpu_temp_c"`
+	CameraStatus string   `json:"camera_status" binding:"omitempty,oneof=ok blocked error disconnected"`
+	FPS          *float64 `json:"fps" binding:"omitempty,min=0"` // detector frames per second
+	AppVersion   string   `json:"app_version"`
+	// Version of the configuration the device runs; when behind, the
+	// response carries the desired configuration
+	ConfigVersion *int `json:"config_version"`
+}
+
+// DeviceHealth is the last reported health and derived state of a device
+type DeviceHealth struct {
+	DeviceID      string    `json:"device_id" db:"device_id"`
+	State         string    `json:"state" db:"state"`
+	Reason        string    `json:"reason,omitempty" db:"reason"` // why the device is not online
+	StateSince    time.Time `json:"state_since" db:"state_since"`
+	LastHeartbeat time.Time `json:"last_heartbeat" db:"last_heartbeat"`
+	CPUTempC      *float64  `json:"cpu_temp_c,omitempty" db:"cpu_temp_c"`
+	CameraStatus  string    `json:"camera_status,omitempty" db:"camera_status"`
+	FPS           *float64  `json:"fps,omitempty" db:"fps"`
+	AppVersion    string    `json:"app_version,omitempty" db:"app_version"`
+}
+
+// DeviceStateEvent is one transition of the device state machine
+type DeviceStateEvent struct {
+	ID        int       `json:"id" db:"id"`
+	DeviceID  string    `json:"device_id" db:"device_id"`
+	FromState string    `json:"from_state,omitempty" db:"from_state"` // empty for the first heartbeat
+	ToState   string    `json:"to_state" db:"to_state"`
+	Reason    string    `json:"reason,omitempty" db:"reason"`
+	At        time.Time `json:"at" db:"at"`
+}
+
+// StateInterval is a period a device spent in one state
+type StateInterval struct {
+	State   string    `json:"state"`
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+	Seconds float64   `json:"seconds"`
+}
+
+// DeviceUptime is the state history of a device over a period
+type DeviceUptime struct {
+	DeviceID      string             `json:"device_id"`
+	From          time.Time          `json:"from"`
+	To            time.Time          `json:"to"`
+	Seconds       map[string]float64 `json:"seconds"`        // time per state; unknown before the first heartbeat
+	UptimePercent float64            `json:"uptime_percent"` // share of known time not offline
+	Intervals     []StateInterval    `json:"intervals"`
+}
@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// Driver identity check outcomes at trip start
+const (
+	IdentityVerified     = "verified"     // face matched the expected driver
+	IdentityReassigned   = "reassigned"   // face matched another enrolled driver
+	IdentityUnrecognized = "unrecognized" // face matched nobody enrolled
+	IdentityNotEnrolled  = "not_enrolled" // expected driver has no reference, so nothing to check against
+)
+
+// FaceEmbedding is a reference face embedding a driver enrolled. Only the
+// vector computed on the device is kept, never the face image itself.
+type FaceEmbedding struct {
+	ID         int       `json:"id" db:"id"`
+	UserID     int       `json:"user_id" db:"user_id"`
+	Model      string    `json:"model,omitempty" db:"model"` // embedding model; only same-model vectors are compared
+	Dimensions int       `json:"dimensions"`
+	Vector     []float64 `json:"-" db:"embedding"` // L2-normalized
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+}
+
+// FaceEnrollRequest enrols one reference embedding
+type FaceEnrollRequest struct {
+	Embedding []float64 `json:"embedding" binding:"required"`
+	Model     string    `json:"model"`
+}
+
+// IdentityCheck is the outcome of comparing the face seen at trip start
+// with the enrolled references
+type IdentityCheck struct {
+	Status           string   `json:"status"`
+	ExpectedDriverID int      `json:"expected_driver_id,omitempty"` // driver on shift, else the device owner
+	DriverID         int      `json:"driver_id,omitempty"`          // driver the trip is attributed to
+	Similarity       *float64 `json:"similarity,omitempty"`         // best cosine similarity found
+}
+
+// Pinned reports whether the check decided who drives, so shifts and
+// device ownership no longer do for the rest of the trip
+func (c IdentityCheck) Pinned() bool {
+	switch c.Status {
+	case IdentityVerified, IdentityReassigned, IdentityUnrecognized:
+		return true
+	}
+	return false
+}
@@ -8,6 +8,7 @@ import (
 type Device struct {
 	ID          string    `json:"id" db:"id"`
 	DriverEmail string    `json:"driver_email" db:"driver_email"`
+	UserID      int       `json:"user_id,omitempty" db:"user_id"`
 	Status      string    `json:"status" db:"status"`
 	LastUpdate  time.Time `json:"last_update" db:"last_update"`
 	CreatedAt   time.Time `json:"created_at" db:"created_at"`
@@ -15,13 +16,32 @@ type Device struct {
 
 // DrowsinessData represents real-time drowsiness detection data
 type DrowsinessData struct {
-	ID              int       `json:"id" db:"id"`
-	DeviceID        string    `json:"device_id" db:"device_id"`
-	EyeClosure      float64   `json:"eye_closure" db:"eye_closure"`
-	DrowsinessLevel string    `json:"drowsiness_level" db:"drowsiness_level"`
-	Status          string    `json:"status" db:"status"`
-	Timestamp       time.Time `json:"timestamp" db:"timestamp"`
-	CreatedAt       time.Time `json:"created_at" db:"created_at"`
+	ID              int        `json:"id" db:"id"`
+	DeviceID        string     `json:"device_id" db:"device_id"`
+	DriverID        int        `json:"driver_id,omitempty" db:"driver_id"` // driver on shift, else the device owner
+	EyeClosure      float64    `json:"eye_closure" db:"eye_closure"`
+	DrowsinessLevel string     `json:"drowsiness_level" db:"drowsiness_level"`
+	Status          string     `json:"status" db:"status"`
+	FatigueScore    float64    `json:"fatigue_score" db:"fatigue_score"` // server-side 0-100 score at this sample
+	FatigueLevel    string     `json:"fatigue_level" db:"fatigue_level"` // level derived from FatigueScore
+	SchemaVersion   int        `json:"schema_version" db:"schema_version"`
+	Telemetry       *Telemetry `json:"telemetry,omitempty"` // extended fields of schema version 2
+	Timestamp       time.Time  `json:"timestamp" db:"timestamp"`
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+}
+
+// FatigueMetrics are eye-closure metrics over a sliding window
+type FatigueMetrics struct {
+	WindowSeconds            float64   `json:"window_seconds"`
+	Perclos                  float64   `json:"perclos"`    // fraction of time with eyes closed (0-1)
+	BlinkRate                float64   `json:"blink_rate"` // blinks per minute
+	BlinkCount               int       `json:"blink_count"`
+	MicrosleepCount          int       `json:"microsleep_count"`
+	MicrosleepSeconds        float64   `json:"microsleep_seconds"`
+	LongestMicrosleepSeconds float64   `json:"longest_microsleep_seconds"`
+	Score                    float64   `json:"fatigue_score"` // 0-100
+	Level                    string    `json:"fatigue_level"` // "low", "medium" or "high"
+	UpdatedAt                time.Time `json:"updated_at"`
 }
 
 // Alert represents drowsiness alert
@@ -32,45 +52,139 @@ type Alert struct {
 	Severity     string    `json:"severity" db:"severity"`
 	Acknowledged bool      `json:"acknowledged" db:"acknowledged"`
 	Status       string    `json:"status" db:"status"`
+	Location     *GPSFix   `json:"location,omitempty"`                     // where the alert was raised, if known
+	GeofenceID   int       `json:"geofence_id,omitempty" db:"geofence_id"` // geofence whose rule applied
+	VehicleID    int       `json:"vehicle_id,omitempty" db:"vehicle_id"`   // vehicle the device was mounted in
+	DriverID     int       `json:"driver_id,omitempty" db:"driver_id"`     // driver on shift, else the device owner
 	Timestamp    time.Time `json:"timestamp" db:"timestamp"`
 	CreatedAt    time.Time `json:"created_at" db:"created_at"`
 }
 
-// DataPayload is the incoming data from Python script
+// DrivingSession is one continuous drive of a device, split from the
+// sample stream by gaps in data or explicit start/stop events
+type DrivingSession struct {
+	ID                 int            `json:"id" db:"id"`
+	DeviceID           string         `json:"device_id" db:"device_id"`
+	DriverID           int            `json:"driver_id,omitempty" db:"driver_id"`
+	VehicleID          int            `json:"vehicle_id,omitempty" db:"vehicle_id"` // vehicle at session start
+	StartedAt          time.Time      `json:"started_at" db:"started_at"`
+	EndedAt            *time.Time     `json:"ended_at,omitempty" db:"ended_at"`
+	LastSampleAt       time.Time      `json:"last_sample_at" db:"last_sample_at"`
+	EndReason          string         `json:"end_reason,omitempty" db:"end_reason"` // "gap", "stop" or "restart"
+	SampleCount        int            `json:"sample_count" db:"sample_count"`
+	LowCount           int            `json:"low_count" db:"low_count"`
+	MediumCount        int            `json:"medium_count" db:"medium_count"`
+	HighCount          int            `json:"high_count" db:"high_count"`
+	LongestHighSeconds float64        `json:"longest_high_seconds" db:"longest_high_seconds"`
+	HighRunStart       *time.Time     `json:"-" db:"high_run_start"`            // start of the current high episode
+	FatigueScore       float64        `json:"fatigue_score" db:"fatigue_score"` // score at the last sample
+	MaxFatigueScore    float64        `json:"max_fatigue_score" db:"max_fatigue_score"`
+	BlinkCount         int            `json:"blink_count" db:"blink_count"`
+	MicrosleepCount    int            `json:"microsleep_count" db:"microsleep_count"`
+	MicrosleepSeconds  float64        `json:"microsleep_seconds" db:"microsleep_seconds"`
+	ObservedSeconds    float64        `json:"-" db:"observed_seconds"` // time covered by samples
+	ClosedSeconds      float64        `json:"-" db:"closed_seconds"`   // part of ObservedSeconds with eyes closed
+	Perclos            float64        `json:"perclos"`                 // computed on read
+	AlertCount         int            `json:"alert_count"`             // computed on read
+	DurationSeconds    float64        `json:"duration_seconds"`        // computed on read
+	Status             string         `json:"status"`                  // "active" or "ended", computed on read
+	Identity           *IdentityCheck `json:"identity,omitempty"`      // face check at trip start, if the device sent one
+}
+
+// SessionDetail is a session with its full sample timeline and alerts
+type SessionDetail struct {
+	DrivingSession
+	Samples []DrowsinessData `json:"samples"`
+	Alerts  []Alert          `json:"alerts"`
+}
+
+// SessionEventPayload is an explicit trip start/stop sent by a device
+type SessionEventPayload struct {
+	Timestamp     string    `json:"timestamp,omitempty"`
+	FaceEmbedding []float64 `json:"face_embedding,omitempty"` // driver's face at trip start, computed on the device
+	FaceModel     string    `json:"face_model,omitempty"`
+}
+
+// DataPayload is the incoming data from Python script. Payloads without
+// schema_version are version 1; version 2 adds the inline Telemetry groups.
 type DataPayload struct {
-	EyeClosure      float64 `json:"eye_closure"`
-	DrowsinessLevel string  `json:"drowsiness_level"`
+	SchemaVersion   int     `json:"schema_version,omitempty"`
+	EyeClosure      float64 `json:"eye_closure" binding:"min=0,max=1"`
+	DrowsinessLevel string  `json:"drowsiness_level" binding:"required,level"`
 	Status          string  `json:"status"`
 	Timestamp       string  `json:"timestamp,omitempty"`
-	DriverEmail     string  `json:"driver_email,omitempty"` // Optional: for device auto-registration
+	DriverEmail     string  `json:"driver_email,omitempty" binding:"omitempty,email"` // Optional: for device auto-registration
+	Telemetry
+}
+
+// DataPayloadV2 is a sample of API v2: the telemetry groups are nested
+// under "telemetry", as DrowsinessData returns them, and always schema 2
+type DataPayloadV2 struct {
+	EyeClosure      float64    `json:"eye_closure" binding:"min=0,max=1"`
+	DrowsinessLevel string     `json:"drowsiness_level" binding:"required,level"`
+	Status          string     `json:"status"`
+	Timestamp       string     `json:"timestamp,omitempty"`
+	DriverEmail     string     `json:"driver_email,omitempty" binding:"omitempty,email"`
+	Telemetry       *Telemetry `json:"telemetry,omitempty"`
+}
+
+// DataPayload returns the sample in the flat shape of API v1
+func (p DataPayloadV2) DataPayload() DataPayload {
+	d := DataPayload{
+		SchemaVersion:   TelemetrySchemaLatest,
+		EyeClosure:      p.EyeClosure,
+		DrowsinessLevel: p.DrowsinessLevel,
+		Status:          p.Status,
+		Timestamp:       p.Timestamp,
+		DriverEmail:     p.DriverEmail,
+	}
+	if p.Telemetry != nil {
+		d.Telemetry = *p.Telemetry
+	}
+	return d
 }
 
 // AlertPayload is the incoming alert from Python script
 type AlertPayload struct {
-	AlertType string `json:"alert_type"`
-	Severity  string `json:"severity"`
-	Timestamp string `json:"timestamp,omitempty"`
+	AlertType string  `json:"alert_type" binding:"required"`
+	Severity  string  `json:"severity" binding:"required,severity"`
+	Timestamp string  `json:"timestamp,omitempty"`
+	GPS       *GPSFix `json:"gps,omitempty"` // defaults to the device's last known position
+}
+
+// AdminOverview holds the headline counters of the master dashboard
+type AdminOverview struct {
+	TotalDrivers        int    `json:"total_drivers"`
+	ActiveDrivers       int    `json:"active_drivers"`
+	TotalDevices        int    `json:"total_devices"`
+	AlertsToday         int    `json:"alerts_today"`
+	CriticalAlertsToday int    `json:"critical_alerts_today"`
+	GeneratedAt         string `json:"generated_at"`
 }
 
 // AdminDriverSummary is a compact view for master dashboard driver list
 type AdminDriverSummary struct {
-	ID                  string `json:"id"`
-	Name                string `json:"name"`
-	DeviceID            string `json:"device_id"`
-	IsOnline            bool   `json:"is_online"`
-	CriticalAlertsToday int    `json:"critical_alerts_today"`
-	Source              string `json:"source"` // "real" or "mock"
+	ID                  string   `json:"id"`
+	Name                string   `json:"name"`
+	DeviceID            string   `json:"device_id"`
+	IsOnline            bool     `json:"is_online"`
+	DeviceState         string   `json:"device_state,omitempty"` // heartbeat state; empty for devices without heartbeats
+	CriticalAlertsToday int      `json:"critical_alerts_today"`
+	FatigueScore        *float64 `json:"fatigue_score"` // live score while online, else null
+	FatigueLevel        string   `json:"fatigue_level,omitempty"`
+	Source              string   `json:"source"` // "real" or "mock"
 }
 
 // AdminRecentAlert represents a unified recent alert/event item
 // used in the master dashboard "recent alerts" card.
 type AdminRecentAlert struct {
-	Time      string `json:"time"`
-	Driver    string `json:"driver"`
-	Type      string `json:"type"`
-	Severity  string `json:"severity"`
-	VehicleID string `json:"vehicleId"`
-	Source    string `json:"source"` // "real" or "mock"
+	Time         string `json:"time"`
+	Driver       string `json:"driver"`
+	Type         string `json:"type"`
+	Severity     string `json:"severity"`
+	VehicleID    string `json:"vehicleId"`
+	VehiclePlate string `json:"vehiclePlate,omitempty"` // plate number of the vehicle the device was in at the time
+	Source       string `json:"source"`                 // "real" or "mock"
 }
 
 // AdminAlertSlot represents alert count per time slot for analytics card
@@ -88,6 +202,20 @@ type AdminAlertLevelSummary struct {
 	SafePct     float64 `json:"safe_pct"`
 }
 
+// AnalyticsPoint is the sample count of one time bucket
+type AnalyticsPoint struct {
+	Time  string `json:"t"` // bucket start, RFC3339 in the response timezone
+	Count int    `json:"count"`
+}
+
+// AnalyticsSeries is a zero-filled time series for one group
+type AnalyticsSeries struct {
+	Key    string           `json:"key"`
+	Label  string           `json:"label"`
+	Total  int              `json:"total"`
+	Points []AnalyticsPoint `json:"points"`
+}
+
 // User represents an application user (for authentication)
 type User struct {
 	ID           int       `json:"id" db:"id"`
@@ -97,17 +225,90 @@ type User struct {
 	Phone        string    `json:"phone" db:"phone"`
 	Role         string    `json:"role" db:"role"`
 	UserType     string    `json:"user_type" db:"user_type"`
+	FleetID      int       `json:"fleet_id,omitempty" db:"fleet_id"`
 	CreatedAt    time.Time `json:"created_at" db:"created_at"`
 }
 
+// Fleet groups drivers operating under one business timezone
+type Fleet struct {
+	ID        int       `json:"id" db:"id"`
+	Name      string    `json:"name" db:"name"`
+	Timezone  string    `json:"timezone" db:"timezone"` // IANA name, e.g. Asia/Bangkok
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// FleetRequest represents incoming fleet create/update payload
+type FleetRequest struct {
+	Name     string `json:"name" binding:"required"`
+	Timezone string `json:"timezone"`
+}
+
+// Vehicle is a vehicle of a fleet; devices are installed in vehicles
+type Vehicle struct {
+	ID          int       `json:"id" db:"id"`
+	PlateNumber string    `json:"plate_number" db:"plate_number"`
+	Type        string    `json:"type" db:"type"`         // e.g. "bus", "truck", "van"
+	Capacity    int       `json:"capacity" db:"capacity"` // seats or payload units
+	FleetID     int       `json:"fleet_id,omitempty" db:"fleet_id"`
+	DeviceID    string    `json:"device_id,omitempty"` // device installed now, if any
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+// VehicleRequest represents incoming vehicle create/update payload
+type VehicleRequest struct {
+	PlateNumber string `json:"plate_number" binding:"required"`
+	Type        string `json:"type"`
+	Capacity    int    `json:"capacity" binding:"min=0"`
+	FleetID     int    `json:"fleet_id" binding:"min=0"`
+}
+
+// DeviceInstallation is one period a device was mounted in a vehicle
+type DeviceInstallation struct {
+	ID          int        `json:"id" db:"id"`
+	DeviceID    string     `json:"device_id" db:"device_id"`
+	VehicleID   int        `json:"vehicle_id" db:"vehicle_id"`
+	InstalledAt time.Time  `json:"installed_at" db:"installed_at"`
+	RemovedAt   *time.Time `json:"removed_at,omitempty" db:"removed_at"` // nil while installed
+}
+
+// InstallRequest mounts a device in a vehicle
+type InstallRequest struct {
+	DeviceID    string `json:"device_id" binding:"required"`
+	InstalledAt string `json:"installed_at,omitempty"` // RFC3339, defaults to now
+}
+
+// Shift assigns a driver to a vehicle and device from StartsAt until EndsAt
+type Shift struct {
+	ID        int        `json:"id" db:"id"`
+	DriverID  int        `json:"driver_id" db:"driver_id"`
+	VehicleID int        `json:"vehicle_id" db:"vehicle_id"`
+	DeviceID  string     `json:"device_id" db:"device_id"`
+	StartsAt  time.Time  `json:"starts_at" db:"starts_at"`
+	EndsAt    *time.Time `json:"ends_at,omitempty" db:"ends_at"` // nil until the shift is ended
+	Notes     string     `json:"notes,omitempty" db:"notes"`
+	Status    string     `json:"status"` // "scheduled", "active" or "ended" at read time
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+// ShiftRequest represents incoming shift create/update payload
+type ShiftRequest struct {
+	DriverID  int    `json:"driver_id" binding:"required"`
+	VehicleID int    `json:"vehicle_id" binding:"required"`
+	DeviceID  string `json:"device_id"` // defaults to the device installed in the vehicle
+	StartsAt  string `json:"starts_at" binding:"required"`
+	EndsAt    string `json:"ends_at"`
+	Notes     string `json:"notes"`
+}
+
 // RegisterRequest represents incoming register payload
 type RegisterRequest struct {
 	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=6"`
+	Password string `json:"password" binding:"required,password"`
 	Name     string `json:"name"`
 	DeviceID string `json:"device_id"`
 	Phone    string `json:"phone"`
 	UserType string `json:"user_type"`
+	FleetID  int    `json:"fleet_id"`
 }
 
 // LoginRequest represents incoming login payload
@@ -116,6 +317,38 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
+// SeedAdminRequest creates the first admin account
+type SeedAdminRequest struct {
+	Email    string `json:"email" binding:"required,email"`
+	Name     string `json:"name"`
+	Password string `json:"password" binding:"required,password"`
+	Secret   string `json:"secret" binding:"required"` // Simple protection
+}
+
+// ForgotPasswordRequest asks for a password reset code
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ResetPasswordRequest sets a new password with a reset code
+type ResetPasswordRequest struct {
+	Email       string `json:"email" binding:"required,email"`
+	ResetCode   string `json:"reset_code" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,password"`
+}
+
+// Profile is the account of the authenticated user
+type Profile struct {
+	ID       int    `json:"id"`
+	Email    string `json:"email"`
+	Name     string `json:"name"`
+	Role     string `json:"role"`
+	Phone    string `json:"phone"`
+	UserType string `json:"user_type"`
+	FleetID  int    `json:"fleet_id"`
+	DeviceID string `json:"device_id"` // device of the current shift, if any
+}
+
 // AuthResponse is returned after successful login/register
 type AuthResponse struct {
 	Token string `json:"token"`
@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// Report kinds
+const (
+	ReportFleet  = "fleet"  // fleet summary with a page per driver
+	ReportDriver = "driver" // one driver only
+)
+
+// Report statuses
+const (
+	ReportPending = "pending"
+	ReportReady   = "ready"
+	ReportFailed  = "failed"
+)
+
+// Report sources
+const (
+	ReportManual   = "manual"
+	ReportSchedule = "schedule"
+)
+
+// Report is a generated PDF safety report over a period
+type Report struct {
+	ID          int        `json:"id" db:"id"`
+	Kind        string     `json:"kind" db:"kind"`
+	FleetID     int        `json:"fleet_id,omitempty" db:"fleet_id"`
+	DriverID    int        `json:"driver_id,omitempty" db:"driver_id"`
+	Title       string     `json:"title" db:"title"`
+	Timezone    string     `json:"timezone" db:"timezone"`
+	PeriodFrom  time.Time  `json:"period_from" db:"period_from"`
+	PeriodTo    time.Time  `json:"period_to" db:"period_to"` // exclusive
+	Source      string     `json:"source" db:"source"`
+	Status      string     `json:"status" db:"status"`
+	Error       string     `json:"error,omitempty" db:"error"`
+	StorageKey  string     `json:"-" db:"storage_key"`
+	Size        int64      `json:"size" db:"size"`
+	Pages       int        `json:"pages" db:"pages"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty" db:"completed_at"`
+}
+
+// ReportRequest generates a report on demand; the period defaults to last week
+type ReportRequest struct {
+	FleetID  int    `json:"fleet_id"`
+	DriverID int    `json:"driver_id"`
+	From     string `json:"from"` // RFC3339 or YYYY-MM-DD in the fleet timezone
+	To       string `json:"to"`
+}
@@ -0,0 +1,131 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// DeviceConfig is the detection and alarm configuration of a device
+type DeviceConfig struct {
+	EARThreshold          float64 `json:"ear_threshold"`           // eye aspect ratio below which eyes count as closed
+	ConsecutiveFrames     int     `json:"consecutive_frames"`      // closed-eye frames before the alarm sounds
+	AlarmVolume           int     `json:"alarm_volume"`            // 0-100
+	UploadIntervalSeconds int     `json:"upload_interval_seconds"` // seconds between routine data uploads
+}
+
+// DefaultDeviceConfig matches the values shipped in the Pi firmware
+func DefaultDeviceConfig() DeviceConfig {
+	return DeviceConfig{EARThreshold: 0.25, ConsecutiveFrames: 20, AlarmVolume: 80, UploadIntervalSeconds: 30}
+}
+
+// Validate checks every setting is within the range the firmware accepts
+func (c DeviceConfig) Validate() error {
+	if !between(c.EARThreshold, 0.05, 0.5) {
+		return errors.New("ear_threshold must be between 0.05 and 0.5")
+	}
+	if c.ConsecutiveFrames < 1 || c.ConsecutiveFrames > 300 {
+		return errors.New("consecutive_frames must be between 1 and 300")
+	}
+	if c.AlarmVolume < 0 || c.AlarmVolume > 100 {
+		return errors.New("alarm_volume must be between 0 and 100")
+	}
+	if c.UploadIntervalSeconds < 1 || c.UploadIntervalSeconds > 3600 {
+		return errors.New("upload_interval_seconds must be between 1 and 3600")
+	}
+	return nil
+}
+
+// ConfigDrift is one setting whose reported value differs from the desired one
+type ConfigDrift struct {
+	Field    string      `json:"field"`
+	Desired  interface{} `json:"desired"`
+	Reported interface{} `json:"reported"`
+}
+
+// Drift returns the settings of reported that differ from c
+func (c DeviceConfig) Drift(reported DeviceConfig) []ConfigDrift {
+	var drift []ConfigDrift
+	if c.EARThreshold != reported.EARThreshold {
+		drift = append(drift, ConfigDrift{"ear_threshold", c.EARThreshold, reported.EARThreshold})
+	}
+	if c.ConsecutiveFrames != reported.ConsecutiveFrames {
+		drift = append(drift, ConfigDrift{"consecutive_frames", c.ConsecutiveFrames, reported.ConsecutiveFrames})
+	}
+	if c.AlarmVolume != reported.AlarmVolume {
+		drift = append(drift, ConfigDrift{"alarm_volume", c.AlarmVolume, reported.AlarmVolume})
+	}
+	if c.UploadIntervalSeconds != reported.UploadIntervalSeconds {
+		drift = append(drift, ConfigDrift{"upload_interval_seconds", c.UploadIntervalSeconds, reported.UploadIntervalSeconds})
+	}
+	return drift
+}
+
+// DeviceConfigRequest changes some settings of the desired configuration
+type DeviceConfigRequest struct {
+	EARThreshold          *float64 `json:"ear_threshold"`
+	ConsecutiveFrames     *int     `json:"consecutive_frames"`
+	AlarmVolume           *int     `json:"alarm_volume"`
+	UploadIntervalSeconds *int     `json:"upload_interval_seconds"`
+}
+
+// Apply returns c with the settings present in the request
+func (r DeviceConfigRequest) Apply(c DeviceConfig) DeviceConfig {
+	if r.EARThreshold != nil {
+		c.EARThreshold = *r.EARThreshold
+	}
+	if r.ConsecutiveFrames != nil {
+		c.ConsecutiveFrames = *r.ConsecutiveFrames
+	}
+	if r.AlarmVolume != nil {
+		c.AlarmVolume = *r.AlarmVolume
+	}
+	if r.UploadIntervalSeconds != nil {
+		c.UploadIntervalSeconds = *r.UploadIntervalSeconds
+	}
+	return c
+}
+
+// ConfigReport is the configuration a device applied and the version it came from
+type ConfigReport struct {
+	Version int          `json:"version"`
+	Config  DeviceConfig `json:"config"`
+}
+
+// Shadow sync states
+const (
+	ShadowInSync        = "in_sync"
+	ShadowPending       = "pending"        // the device has not applied the latest version yet
+	ShadowDrift         = "drift"          // the device applied the latest version with different values
+	ShadowNeverReported = "never_reported" // the device has not reported its configuration
+)
+
+// DeviceShadow is the desired and last reported configuration of a device
+type DeviceShadow struct {
+	DeviceID        string        `json:"device_id"`
+	Desired         DeviceConfig  `json:"desired"`
+	Version         int           `json:"version"` // bumped on every desired change; 0 means defaults
+	UpdatedAt       *time.Time    `json:"updated_at"`
+	Reported        *DeviceConfig `json:"reported"`
+	ReportedVersion int           `json:"reported_version"`
+	ReportedAt      *time.Time    `json:"reported_at"`
+	Sync            string        `json:"sync"`  // computed on read
+	Drift           []ConfigDrift `json:"drift"` // computed on read
+}
+
+// Resolve computes Sync and Drift from the desired and reported configuration
+func (s *DeviceShadow) Resolve() {
+	s.Drift = []ConfigDrift{}
+	switch {
+	case s.Reported == nil:
+		s.Sync = ShadowNeverReported
+	case s.ReportedVersion < s.Version:
+		s.Sync = ShadowPending
+		s.Drift = append(s.Drift, s.Desired.Drift(*s.Reported)...)
+	default:
+		s.Drift = append(s.Drift, s.Desired.Drift(*s.Reported)...)
+		s.Sync = ShadowInSync
+		if len(s.Drift) > 0 {
+			s.Sync = ShadowDrift
+		}
+	}
+}
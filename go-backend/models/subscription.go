@@ -0,0 +1,72 @@
+package models
+
+import "time"
+
+// Digests a user can subscribe to
+const (
+	DigestDailySummary     = "daily_summary"     // the previous day's events, every day
+	DigestWeeklyCompliance = "weekly_compliance" // last week's driving time, every Monday
+)
+
+// Digest formats
+const (
+	DigestHTML = "html"
+	DigestPDF  = "pdf"
+	DigestCSV  = "csv"
+)
+
+// Delivery channels
+const (
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+)
+
+// Delivery statuses
+const (
+	DeliveryPending = "pending" // waiting for its first or next attempt
+	DeliverySent    = "sent"
+	DeliveryFailed  = "failed" // out of attempts
+)
+
+// Subscription sends a fleet's digest on a schedule in the fleet timezone
+type Subscription struct {
+	ID        int       `json:"id" db:"id"`
+	UserID    int       `json:"user_id" db:"user_id"`
+	FleetID   int       `json:"fleet_id" db:"fleet_id"`
+	Digest    string    `json:"digest" db:"digest"`
+	Format    string    `json:"format" db:"format"`
+	Channel   string    `json:"channel" db:"channel"`
+	Target    string    `json:"target" db:"target"` // email address or webhook URL
+	Secret    string    `json:"-" db:"secret"`      // signs webhook bodies
+	Lang      string    `json:"lang" db:"lang"`
+	Active    bool      `json:"active" db:"active"`
+	NextRunAt time.Time `json:"next_run_at" db:"next_run_at"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// SubscriptionRequest creates or updates a subscription
+type SubscriptionRequest struct {
+	FleetID int    `json:"fleet_id"`
+	Digest  string `json:"digest" binding:"omitempty,oneof=daily_summary weekly_compliance"`
+	Format  string `json:"format" binding:"omitempty,oneof=html pdf csv"`
+	Channel string `json:"channel" binding:"omitempty,oneof=email webhook"`
+	Target  string `json:"target"`
+	Secret  string `json:"secret"`
+	Lang    string `json:"lang"`
+	Active  *bool  `json:"active"`
+}
+
+// Delivery is one digest of a subscription and its delivery attempts
+type Delivery struct {
+	ID             int        `json:"id" db:"id"`
+	SubscriptionID int        `json:"subscription_id" db:"subscription_id"`
+	PeriodFrom     time.Time  `json:"period_from" db:"period_from"`
+	PeriodTo       time.Time  `json:"period_to" db:"period_to"` // exclusive
+	Status         string     `json:"status" db:"status"`
+	Attempts       int        `json:"attempts" db:"attempts"`
+	Error          string     `json:"error,omitempty" db:"error"` // of the last failed attempt
+	NextAttemptAt  time.Time  `json:"next_attempt_at" db:"next_attempt_at"`
+	LastAttemptAt  *time.Time `json:"last_attempt_at,omitempty" db:"last_attempt_at"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty" db:"delivered_at"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"errors"
+	"math"
+)
+
+// Telemetry schema versions sent as "schema_version" in DataPayload
+const (
+	TelemetrySchemaV1     = 1 // eye_closure, drowsiness_level and status only
+	TelemetrySchemaV2     = 2 // adds the optional Telemetry groups
+	TelemetrySchemaLatest = TelemetrySchemaV2
+)
+
+// Telemetry is the extended detector output of schema version 2.
+// Every group is optional so devices send only what they measure.
+type Telemetry struct {
+	Mouth        *MouthTelemetry        `json:"mouth,omitempty"`
+	HeadPose     *HeadPose              `json:"head_pose,omitempty"`
+	Gaze         *GazeTelemetry         `json:"gaze,omitempty"`
+	FaceDetected *bool                  `json:"face_detected,omitempty"`
+	GPS          *GPSFix                `json:"gps,omitempty"`
+	Extra        map[string]interface{} `json:"extra,omitempty"` // vendor or newer fields, stored as-is
+}
+
+// MouthTelemetry is the mouth aspect ratio and yawn detection
+type MouthTelemetry struct {
+	AspectRatio float64 `json:"aspect_ratio"`
+	Yawning     bool    `json:"yawning"`
+	YawnCount   int     `json:"yawn_count"` // yawns detected since the previous sample
+}
+
+// HeadPose is the head orientation in degrees
+type HeadPose struct {
+	Pitch float64 `json:"pitch"`
+	Yaw   float64 `json:"yaw"`
+	Roll  float64 `json:"roll"`
+}
+
+// GazeTelemetry is where the driver is looking
+type GazeTelemetry struct {
+	OffRoadSeconds float64 `json:"off_road_seconds"` // current continuous time looking off the road
+}
+
+// GPSFix is the vehicle position; speed and heading are optional
+type GPSFix struct {
+	Latitude  float64  `json:"lat"`
+	Longitude float64  `json:"lon"`
+	SpeedKmh  *float64 `json:"speed_kmh,omitempty"`
+	Heading   *float64 `json:"heading,omitempty"` // degrees clockwise from north
+}
+
+// Empty reports whether no telemetry group is set
+func (t *Telemetry) Empty() bool {
+	return t == nil || (t.Mouth == nil && t.HeadPose == nil && t.Gaze == nil &&
+		t.FaceDetected == nil && t.GPS == nil && len(t.Extra) == 0)
+}
+
+// Validate checks that every present group holds plausible values
+func (t *Telemetry) Validate() error {
+	if t == nil {
+		return nil
+	}
+	if m := t.Mouth; m != nil {
+		if !between(m.AspectRatio, 0, 5) {
+			return errors.New("mouth.aspect_ratio must be between 0 and 5")
+		}
+		if m.YawnCount < 0 {
+			return errors.New("mouth.yawn_count must not be negative")
+		}
+	}
+	if h := t.HeadPose; h != nil {
+		if !between(h.Pitch, -180, 180) || !between(h.Yaw, -180, 180) || !between(h.Roll, -180, 180) {
+			return errors.New("head_pose angles must be between -180 and 180")
+		}
+	}
+	if g := t.Gaze; g != nil && !between(g.OffRoadSeconds, 0, 3600) {
+		return errors.New("gaze.off_road_seconds must be between 0 and 3600")
+	}
+	if g := t.GPS; g != nil {
+		if !between(g.Latitude, -90, 90) || !between(g.Longitude, -180, 180) {
+			return errors.New("gps lat/lon out of range")
+		}
+		if g.SpeedKmh != nil && !between(*g.SpeedKmh, 0, 400) {
+			return errors.New("gps.speed_kmh must be between 0 and 400")
+		}
+		if g.Heading != nil && !between(*g.Heading, 0, 360) {
+			return errors.New("gps.heading must be between 0 and 360")
+		}
+	}
+	return nil
+}
+
+// between reports lo <= v <= hi, rejecting NaN
+func between(v, lo, hi float64) bool {
+	return !math.IsNaN(v) && v >= lo && v <= hi
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// APIVersionUsage counts the requests of one API version since the server started
+type APIVersionUsage struct {
+	Version    string           `json:"version"`
+	Deprecated bool             `json:"deprecated"`
+	Sunset     *time.Time       `json:"sunset,omitempty"` // when the version stops being served
+	Requests   int64            `json:"requests"`
+	LastSeen   *time.Time       `json:"last_seen"`
+	Routes     map[string]int64 `json:"routes"`  // "METHOD /route" → requests
+	Clients    map[string]int64 `json:"clients"` // User-Agent → requests
+	Devices    map[string]int64 `json:"devices"` // device id → requests of device routes
+}
+
+// APIUsage is the usage of every API version
+type APIUsage struct {
+	Since    time.Time         `json:"since"`
+	Versions []APIVersionUsage `json:"versions"`
+}
@@ -0,0 +1,458 @@
+// Package openapi builds the OpenAPI 3 document of the HTTP API, deriving
+// the schemas of payloads from the Go types the handlers bind and answer with
+package openapi
+
+import (
+	_ "embed"
+	"encoding"
+	"encoding/json"
+	"net/http"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"driver-drowsiness-backend/validate"
+)
+
+// Version is the OpenAPI version of the documents built here
+const Version = "3.0.3"
+
+// DocsPage is a self-contained HTML page that renders the document served
+// next to it as openapi.json
+//
+//go:embed docs.html
+var DocsPage []byte
+
+// Document is an OpenAPI document
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Tags       []Tag               `json:"tags,omitempty"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+
+	errorSchema *Schema
+	names       map[string]reflect.Type // component name → Go type
+}
+
+// Info describes the API
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Tag groups operations
+type Tag struct {
+	Name string `json:"name"`
+}
+
+// PathItem maps the lower-case HTTP methods of a path to their operations
+type PathItem map[string]*Operation
+
+// Operation is one method of a path
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+// Parameter is a path, query or header parameter
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody is the body an operation accepts
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response is an answer of an operation
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType is the schema of a body in one content type
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Components holds the schemas and security schemes operations refer to
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes"`
+}
+
+// SecurityScheme is how a client authenticates
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+// Schema is a JSON schema in the OpenAPI 3.0 dialect
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+}
+
+// Fields describes a JSON object by a sample value of each field, for
+// bodies the handlers build as maps
+type Fields map[string]interface{}
+
+// Binary is the schema of a raw file body
+var Binary = &Schema{Type: "string", Format: "binary"}
+
+// Op is an operation as a route table declares it
+type Op struct {
+	ID       string // operationId, unique in the document
+	Method   string
+	Path     string // gin syntax, e.g. /api/devices/:id/data
+	Tag      string
+	Summary  string
+	Auth     bool // needs a bearer token
+	Params   []Parameter
+	Body     interface{} // sample of the request body, a *Schema or Fields
+	BodyType string      // content type of Body, application/json when empty
+	Status   int         // of success, 200 when 0
+	Response interface{} // sample of the JSON response, a *Schema or Fields
+	Produces []string    // content types of a non-JSON response, e.g. application/pdf
+}
+
+// New returns an empty document whose operations answer errors with
+// bodies shaped like errorBody
+func New(info Info, errorBody interface{}) *Document {
+	d := &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   map[string]PathItem{},
+		Components: Components{
+			Schemas: map[string]*Schema{},
+			SecuritySchemes: map[string]*SecurityScheme{
+				"bearerAuth": {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			},
+		},
+		names: map[string]reflect.Type{},
+	}
+	d.errorSchema = d.Schema(errorBody)
+	return d
+}
+
+// Query returns an optional query parameter
+func Query(name, typ, description string) Parameter {
+	return Parameter{Name: name, In: "query", Description: description, Schema: &Schema{Type: typ}}
+}
+
+// Header returns a required header parameter
+func Header(name, typ, description string) Parameter {
+	return Parameter{Name: name, In: "header", Description: description, Required: true, Schema: &Schema{Type: typ}}
+}
+
+// Path turns a gin route path into an OpenAPI path: /devices/:id → /devices/{id}
+func Path(route string) string {
+	parts := strings.Split(route, "/")
+	for i, p := range parts {
+		if strings.HasPrefix(p, ":") || strings.HasPrefix(p, "*") {
+			parts[i] = "{" + p[1:] + "}"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// Add adds an operation to the document
+func (d *Document) Add(op Op) {
+	o := &Operation{
+		OperationID: op.ID,
+		Summary:     op.Summary,
+		Responses:   map[string]*Response{},
+	}
+	if op.Tag != "" {
+		o.Tags = []string{op.Tag}
+		d.tag(op.Tag)
+	}
+	for _, p := range strings.Split(op.Path, "/") {
+		if strings.HasPrefix(p, ":") || strings.HasPrefix(p, "*") {
+			o.Parameters = append(o.Parameters, Parameter{Name: p[1:], In: "path", Required: true, Schema: &Schema{Type: "string"}})
+		}
+	}
+	o.Parameters = append(o.Parameters, op.Params...)
+
+	if op.Body != nil {
+		bodyType := op.BodyType
+		if bodyType == "" {
+			bodyType = "application/json"
+		}
+		o.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{bodyType: {Schema: d.Schema(op.Body)}}}
+	}
+
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	resp := &Response{Description: http.StatusText(status)}
+	switch {
+	case len(op.Produces) > 0:
+		resp.Content = map[string]MediaType{}
+		for _, ct := range op.Produces {
+			resp.Content[ct] = MediaType{Schema: Binary}
+		}
+	case op.Response != nil:
+		resp.Content = map[string]MediaType{"application/json": {Schema: d.Schema(op.Response)}}
+	}
+	o.Responses[strconv.Itoa(status)] = resp
+	if op.Auth {
+		o.Security = []map[string][]string{{"bearerAuth": {}}}
+		o.Responses["401"] = d.errorResponse(http.StatusText(http.StatusUnauthorized))
+	}
+	o.Responses["default"] = d.errorResponse("Error")
+
+	route := Path(op.Path)
+	if d.Paths[route] == nil {
+		d.Paths[route] = PathItem{}
+	}
+	d.Paths[route][strings.ToLower(op.Method)] = o
+}
+
+func (d *Document) tag(name string) {
+	for _, t := range d.Tags {
+		if t.Name == name {
+			return
+		}
+	}
+	d.Tags = append(d.Tags, Tag{Name: name})
+}
+
+func (d *Document) errorResponse(description string) *Response {
+	return &Response{Description: description, Content: map[string]MediaType{"application/json": {Schema: d.errorSchema}}}
+}
+
+// Operations lists the operations of the document as "METHOD /path", sorted
+func (d *Document) Operations() []string {
+	var ops []string
+	for route, item := range d.Paths {
+		for method := range item {
+			ops = append(ops, strings.ToUpper(method)+" "+route)
+		}
+	}
+	sort.Strings(ops)
+	return ops
+}
+
+// Operation returns the operation of method and an OpenAPI path, or nil
+func (d *Document) Operation(method, route string) *Operation {
+	return d.Paths[route][strings.ToLower(method)]
+}
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	jsonMarshaler = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshaler = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// Schema returns the schema of a sample value, adding the named struct
+// types it uses to the components. A *Schema is returned as is.
+func (d *Document) Schema(v interface{}) *Schema {
+	switch v := v.(type) {
+	case nil:
+		return &Schema{}
+	case *Schema:
+		return v
+	case Fields:
+		s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+		for name, field := range v {
+			s.Properties[name] = d.Schema(field)
+		}
+		return s
+	}
+	return d.schemaOf(reflect.TypeOf(v))
+}
+
+func (d *Document) schemaOf(t reflect.Type) *Schema {
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+	if t.Kind() == reflect.Ptr {
+		s := d.schemaOf(t.Elem())
+		if s.Ref == "" {
+			s.Nullable = true
+		}
+		return s
+	}
+	if t.Implements(jsonMarshaler) {
+		return &Schema{}
+	}
+	if t.Implements(textMarshaler) {
+		return &Schema{Type: "string"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: d.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: d.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return d.object(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + d.component(t)}
+	}
+	// Interfaces hold any JSON value
+	return &Schema{}
+}
+
+// component names the schema of a struct type, adding it on first use;
+// types of the same name in another package are prefixed with theirs
+func (d *Document) component(t reflect.Type) string {
+	name := t.Name()
+	if prev, ok := d.names[name]; ok && prev != t {
+		pkg := path.Base(t.PkgPath())
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+	if _, ok := d.names[name]; !ok {
+		d.names[name] = t
+		// Placed before the fields are described so recursive types end
+		s := &Schema{}
+		d.Components.Schemas[name] = s
+		*s = *d.object(t)
+	}
+	return name
+}
+
+func (d *Document) object(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	d.fields(t, s)
+	return s
+}
+
+// fields describes the JSON fields of struct type t on s, flattening
+// embedded structs the way encoding/json does
+func (d *Document) fields(t reflect.Type, s *Schema) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" && opts == "" {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				d.fields(ft, s)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		p := d.schemaOf(f.Type)
+		for _, opt := range strings.Split(opts, ",") {
+			if opt == "string" {
+				p = &Schema{Type: "string"}
+			}
+		}
+		rules(f.Tag.Get("binding"), name, p, s)
+		s.Properties[name] = p
+	}
+}
+
+// rules describes the binding rules of field name on its schema p and on
+// the schema of its struct
+func rules(binding, name string, p, parent *Schema) {
+	if binding == "" {
+		return
+	}
+	for _, rule := range strings.Split(binding, ",") {
+		tag, param, _ := strings.Cut(rule, "=")
+		if tag == "required" {
+			parent.Required = append(parent.Required, name)
+			continue
+		}
+		if p.Ref != "" {
+			continue
+		}
+		switch tag {
+		case "oneof":
+			p.Enum = strings.Fields(param)
+		case "level":
+			p.Enum = validate.Levels
+		case "severity":
+			p.Enum = validate.Severities
+		case "email":
+			p.Format = "email"
+		case "password":
+			n := validate.MinPasswordLength
+			p.MinLength = &n
+		case "min", "max":
+			bound(p, tag == "min", param)
+		}
+	}
+}
+
+// bound sets the lower or upper bound of a number, or of the length of a
+// string or array
+func bound(p *Schema, lower bool, param string) {
+	v, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+	n := int(v)
+	switch {
+	case p.Type == "integer" || p.Type == "number":
+		if lower {
+			p.Minimum = &v
+		} else {
+			p.Maximum = &v
+		}
+	case p.Type == "string" && lower:
+		p.MinLength = &n
+	case p.Type == "string":
+		p.MaxLength = &n
+	case p.Type == "array" && lower:
+		p.MinItems = &n
+	case p.Type == "array":
+		p.MaxItems = &n
+	}
+}
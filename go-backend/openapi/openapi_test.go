@@ -0,0 +1,107 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+type base struct {
+	ID        int       `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type node struct {
+	base
+	Name     string         `json:"name" binding:"required,min=2"`
+	Kind     string         `json:"kind" binding:"omitempty,oneof=a b"`
+	Score    *float64       `json:"score" binding:"omitempty,min=0,max=1"`
+	Parent   *node          `json:"parent,omitempty"`
+	Tags     []string       `json:"tags" binding:"max=3"`
+	Labels   map[string]int `json:"labels"`
+	Count    int64          `json:"count,string"`
+	Extra    interface{}    `json:"extra"`
+	Hidden   string         `json:"-"`
+	internal string
+	Level    string           `json:"level" binding:"level"`
+	Raw      []byte           `json:"raw"`
+	Nested   struct{ X int }  `json:"nested"`
+	Children map[string]*node `json:"children"`
+}
+
+func TestSchema(t *testing.T) {
+	d := New(Info{Title: "t", Version: "v1"}, struct {
+		Error string `json:"error"`
+	}{})
+	ref := d.Schema(node{})
+	if ref.Ref != "#/components/schemas/node" {
+		t.Fatalf("ref = %+v", ref)
+	}
+	s := d.Components.Schemas["node"]
+	for name, want := range map[string]string{
+		"id": "integer", "created_at": "string", "name": "string", "tags": "array", "labels": "object",
+		"count": "string", "raw": "string", "nested": "object", "score": "number",
+	} {
+		if p := s.Properties[name]; p == nil || p.Type != want {
+			t.Errorf("%s = %+v, want %s", name, p, want)
+		}
+	}
+	if s.Properties["created_at"].Format != "date-time" || !s.Properties["score"].Nullable || *s.Properties["score"].Maximum != 1 {
+		t.Errorf("created_at %+v, score %+v", s.Properties["created_at"], s.Properties["score"])
+	}
+	if s.Properties["parent"].Ref != "#/components/schemas/node" || s.Properties["children"].AdditionalProperties.Ref == "" {
+		t.Errorf("recursive parent = %+v", s.Properties["parent"])
+	}
+	if _, ok := s.Properties["Hidden"]; ok || s.Properties["internal"] != nil || s.Properties["base"] != nil {
+		t.Errorf("properties = %v", s.Properties)
+	}
+	if strings.Join(s.Required, ",") != "name" || *s.Properties["name"].MinLength != 2 || *s.Properties["tags"].MaxItems != 3 {
+		t.Errorf("rules: required %v, name %+v", s.Required, s.Properties["name"])
+	}
+	if strings.Join(s.Properties["kind"].Enum, ",") != "a,b" || len(s.Properties["level"].Enum) != 3 {
+		t.Errorf("enums: kind %v, level %v", s.Properties["kind"].Enum, s.Properties["level"].Enum)
+	}
+
+	fields := d.Schema(Fields{"count": 0, "nodes": []node{}, "next": &Schema{Type: "string", Nullable: true}})
+	if fields.Properties["count"].Type != "integer" || fields.Properties["nodes"].Items.Ref == "" || !fields.Properties["next"].Nullable {
+		t.Errorf("fields = %+v", fields.Properties)
+	}
+}
+
+func TestAdd(t *testing.T) {
+	d := New(Info{Title: "t", Version: "v1"}, struct {
+		Error string `json:"error"`
+	}{})
+	d.Add(Op{ID: "getThing", Method: "GET", Path: "/things/:id/parts/:part_id", Tag: "Things", Auth: true,
+		Params: []Parameter{Query("limit", "integer", "")}, Response: node{}})
+	d.Add(Op{ID: "createThing", Method: "POST", Path: "/things", Tag: "Things", Body: node{}, Status: http.StatusCreated, Response: node{}})
+	d.Add(Op{ID: "thingPDF", Method: "GET", Path: "/things/:id/pdf", Produces: []string{"application/pdf"}})
+
+	if got := strings.Join(d.Operations(), "|"); got != "GET /things/{id}/parts/{part_id}|GET /things/{id}/pdf|POST /things" {
+		t.Errorf("operations = %s", got)
+	}
+	op := d.Operation("GET", "/things/{id}/parts/{part_id}")
+	if len(op.Parameters) != 3 || op.Parameters[1].Name != "part_id" || op.Parameters[2].In != "query" || op.Security == nil {
+		t.Errorf("parameters = %+v", op.Parameters)
+	}
+	if op.Responses["401"] == nil || op.Responses["default"] == nil || op.Responses["200"].Content["application/json"].Schema.Ref == "" {
+		t.Errorf("responses = %+v", op.Responses)
+	}
+	if create := d.Operation("POST", "/things"); create.Responses["201"] == nil || create.RequestBody == nil {
+		t.Errorf("create = %+v", create)
+	}
+	if pdf := d.Operation("GET", "/things/{id}/pdf"); pdf.Responses["200"].Content["application/pdf"].Schema != Binary {
+		t.Errorf("pdf = %+v", pdf.Responses["200"])
+	}
+	if len(d.Tags) != 1 {
+		t.Errorf("tags = %v", d.Tags)
+	}
+	if _, err := json.Marshal(d); err != nil {
+		t.Fatal(err)
+	}
+	if Path("/a/:b/*rest") != "/a/{b}/{rest}" {
+		t.Errorf("path = %s", Path("/a/:b/*rest"))
+	}
+}
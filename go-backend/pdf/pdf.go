@@ -0,0 +1,326 @@
+// Package pdf writes simple PDF documents: text in an embedded TrueType
+// font (Thai included), filled rectangles and lines on A4 pages.
+// Coordinates are in points from the top-left corner of the page.
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+// A4 page size in points
+const (
+	PageWidth  = 595.28
+	PageHeight = 841.89
+)
+
+// Color is an RGB color with components in 0..1
+type Color struct{ R, G, B float64 }
+
+// Common colors
+var (
+	Black = Color{0, 0, 0}
+	Gray  = Color{0.45, 0.45, 0.45}
+	Light = Color{0.9, 0.9, 0.9}
+	White = Color{1, 1, 1}
+)
+
+// RGB returns the color of 8-bit components
+func RGB(r, g, b uint8) Color {
+	return Color{float64(r) / 255, float64(g) / 255, float64(b) / 255}
+}
+
+// Style is how text is drawn
+type Style struct {
+	Size  float64
+	Bold  bool // emboldened by stroking the outline, since one font is embedded
+	Color Color
+}
+
+// Document is a PDF being built page by page
+type Document struct {
+	Title string
+	font  *Font
+	pages []*Page
+	used  map[uint16]rune // glyphs drawn, for widths and text extraction
+}
+
+// New returns an empty document drawing text in font. A nil font falls
+// back to the built-in Helvetica, which only covers Latin-1.
+func New(font *Font) *Document {
+	return &Document{font: font, used: make(map[uint16]rune)}
+}
+
+// Page is one page of a document
+type Page struct {
+	doc     *Document
+	content bytes.Buffer
+}
+
+// AddPage appends a blank page
+func (d *Document) AddPage() *Page {
+	p := &Page{doc: d}
+	d.pages = append(d.pages, p)
+	return p
+}
+
+// Pages returns the number of pages
+func (d *Document) Pages() int {
+	return len(d.pages)
+}
+
+// helveticaWidth approximates Helvetica advances in 1/1000 em
+const helveticaWidth = 556
+
+// TextWidth returns the width of s in points at size
+func (d *Document) TextWidth(s string, size float64) float64 {
+	var w float64
+	for _, r := range s {
+		if d.font == nil {
+			w += helveticaWidth
+			continue
+		}
+		w += d.font.advance(d.font.glyph(r))
+	}
+	return w * size / 1000
+}
+
+// Truncate shortens s with an ellipsis to fit width at size
+func (d *Document) Truncate(s string, size, width float64) string {
+	if d.TextWidth(s, size) <= width {
+		return s
+	}
+	ellipsis := "..."
+	if d.font != nil && d.font.Has('…') {
+		ellipsis = "…"
+	}
+	runes := []rune(s)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		if t := string(runes) + ellipsis; d.TextWidth(t, size) <= width {
+			return t
+		}
+	}
+	return ""
+}
+
+// encode returns s as a PDF string operand in the document font
+func (d *Document) encode(s string) string {
+	var b strings.Builder
+	if d.font == nil {
+		b.WriteByte('(')
+		for _, r := range s {
+			switch {
+			case r == '(' || r == ')' || r == '\\':
+				b.WriteByte('\\')
+				b.WriteRune(r)
+			case r >= 0x20 && r < 0x7F:
+				b.WriteRune(r)
+			case r >= 0xA0 && r <= 0xFF:
+				fmt.Fprintf(&b, "\\%03o", r)
+			default:
+				b.WriteByte('?')
+			}
+		}
+		b.WriteByte(')')
+		return b.String()
+	}
+	b.WriteByte('<')
+	for _, r := range s {
+		g := d.font.glyph(r)
+		if _, ok := d.used[g]; !ok && g != 0 {
+			d.used[g] = r
+		}
+		fmt.Fprintf(&b, "%04X", g)
+	}
+	b.WriteByte('>')
+	return b.String()
+}
+
+func (c Color) fill() string {
+	return fmt.Sprintf("%.3f %.3f %.3f rg", c.R, c.G, c.B)
+}
+
+func (c Color) stroke() string {
+	return fmt.Sprintf("%.3f %.3f %.3f RG", c.R, c.G, c.B)
+}
+
+// Text draws s with its baseline at y
+func (p *Page) Text(x, y float64, s string, st Style) {
+	if s == "" {
+		return
+	}
+	mode := "0 Tr"
+	if st.Bold {
+		mode = fmt.Sprintf("2 Tr %.2f w %s", st.Size*0.03, st.Color.stroke())
+	}
+	fmt.Fprintf(&p.content, "BT /F1 %.2f Tf %s %s %.2f %.2f Td %s Tj ET\n",
+		st.Size, st.Color.fill(), mode, x, PageHeight-y, p.doc.encode(s))
+}
+
+// TextRight draws s ending at x
+func (p *Page) TextRight(x, y float64, s string, st Style) {
+	p.Text(x-p.doc.TextWidth(s, st.Size), y, s, st)
+}
+
+// Rect fills a rectangle whose top-left corner is (x, y)
+func (p *Page) Rect(x, y, w, h float64, c Color) {
+	fmt.Fprintf(&p.content, "%s %.2f %.2f %.2f %.2f re f\n", c.fill(), x, PageHeight-y-h, w, h)
+}
+
+// Line strokes a line of the given width
+func (p *Page) Line(x1, y1, x2, y2, width float64, c Color) {
+	fmt.Fprintf(&p.content, "%s %.2f w %.2f %.2f m %.2f %.2f l S\n", c.stroke(), width, x1, PageHeight-y1, x2, PageHeight-y2)
+}
+
+// writer numbers objects and records their offsets for the xref table
+type writer struct {
+	w       io.Writer
+	n       int64
+	offsets []int64
+	err     error
+}
+
+func (w *writer) printf(format string, args ...interface{}) {
+	if w.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(w.w, format, args...)
+	w.n += int64(n)
+	w.err = err
+}
+
+func (w *writer) write(b []byte) {
+	if w.err != nil {
+		return
+	}
+	n, err := w.w.Write(b)
+	w.n += int64(n)
+	w.err = err
+}
+
+// object writes object id with a dictionary body
+func (w *writer) object(id int, body string) {
+	w.offsets[id] = w.n
+	w.printf("%d 0 obj\n%s\nendobj\n", id, body)
+}
+
+// stream writes object id as a compressed stream with extra dictionary entries
+func (w *writer) stream(id int, extra string, data []byte) {
+	var z bytes.Buffer
+	zw := zlib.NewWriter(&z)
+	zw.Write(data)
+	zw.Close()
+	w.offsets[id] = w.n
+	w.printf("%d 0 obj\n<< /Length %d /Filter /FlateDecode%s >>\nstream\n", id, z.Len(), extra)
+	w.write(z.Bytes())
+	w.printf("\nendstream\nendobj\n")
+}
+
+// textString encodes s as a UTF-16 PDF text string
+func textString(s string) string {
+	var b strings.Builder
+	b.WriteString("<FEFF")
+	for _, u := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&b, "%04X", u)
+	}
+	b.WriteByte('>')
+	return b.String()
+}
+
+// WriteTo writes the document as a PDF file
+func (d *Document) WriteTo(out io.Writer) (int64, error) {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+	// 1 catalog, 2 pages, 3 info, then page and content pairs, then the font
+	pageID := func(i int) int { return 4 + 2*i }
+	fontID := pageID(len(d.pages))
+	objects := fontID + 1
+	if d.font != nil {
+		objects = fontID + 5
+	}
+	w := &writer{w: out, offsets: make([]int64, objects)}
+	w.printf("%%PDF-1.4\n%%\xe2\xe3\xcf\xd3\n")
+
+	w.object(1, "<< /Type /Catalog /Pages 2 0 R >>")
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", pageID(i))
+	}
+	w.object(2, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	w.object(3, fmt.Sprintf("<< /Title %s /Producer (driver-drowsiness-backend) >>", textString(d.Title)))
+	for i, p := range d.pages {
+		w.object(pageID(i), fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] "+
+			"/Resources << /Font << /F1 %d 0 R >> >> /Contents %d 0 R >>", PageWidth, PageHeight, fontID, pageID(i)+1))
+		w.stream(pageID(i)+1, "", p.content.Bytes())
+	}
+
+	if d.font == nil {
+		w.object(fontID, "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	} else {
+		d.writeFont(w, fontID)
+	}
+
+	xref := w.n
+	w.printf("xref\n0 %d\n0000000000 65535 f \n", objects)
+	for _, off := range w.offsets[1:] {
+		w.printf("%010d 00000 n \n", off)
+	}
+	w.printf("trailer\n<< /Size %d /Root 1 0 R /Info 3 0 R >>\nstartxref\n%d\n%%%%EOF\n", objects, xref)
+	return w.n, w.err
+}
+
+// writeFont writes the Type0 font at id and its CID font, descriptor,
+// font file and ToUnicode map at the four ids after it
+func (d *Document) writeFont(w *writer, id int) {
+	f := d.font
+	glyphs := make([]int, 0, len(d.used))
+	for g := range d.used {
+		glyphs = append(glyphs, int(g))
+	}
+	sort.Ints(glyphs)
+
+	var widths strings.Builder
+	for _, g := range glyphs {
+		fmt.Fprintf(&widths, "%d [%d] ", g, int(f.advance(uint16(g))))
+	}
+	w.object(id, fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /ReportFont /Encoding /Identity-H "+
+		"/DescendantFonts [%d 0 R] /ToUnicode %d 0 R >>", id+1, id+4))
+	w.object(id+1, fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType2 /BaseFont /ReportFont "+
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> "+
+		"/FontDescriptor %d 0 R /CIDToGIDMap /Identity /DW 1000 /W [%s] >>", id+2, widths.String()))
+	w.object(id+2, fmt.Sprintf("<< /Type /FontDescriptor /FontName /ReportFont /Flags 32 "+
+		"/FontBBox [%d %d %d %d] /ItalicAngle 0 /Ascent %d /Descent %d /CapHeight %d /StemV 80 /FontFile2 %d 0 R >>",
+		f.scale(f.bbox[0]), f.scale(f.bbox[1]), f.scale(f.bbox[2]), f.scale(f.bbox[3]),
+		f.scale(f.ascent), f.scale(f.descent), f.scale(f.ascent), id+3))
+	w.stream(id+3, fmt.Sprintf(" /Length1 %d", len(f.data)), f.data)
+
+	var cmap strings.Builder
+	cmap.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n" +
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n" +
+		"/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n" +
+		"1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+	for i := 0; i < len(glyphs); i += 100 {
+		chunk := glyphs[i:]
+		if len(chunk) > 100 {
+			chunk = chunk[:100]
+		}
+		fmt.Fprintf(&cmap, "%d beginbfchar\n", len(chunk))
+		for _, g := range chunk {
+			fmt.Fprintf(&cmap, "<%04X> <", g)
+			for _, u := range utf16.Encode([]rune{d.used[uint16(g)]}) {
+				fmt.Fprintf(&cmap, "%04X", u)
+			}
+			cmap.WriteString(">\n")
+		}
+		cmap.WriteString("endbfchar\n")
+	}
+	cmap.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend\n")
+	w.stream(id+4, "", []byte(cmap.String()))
+}
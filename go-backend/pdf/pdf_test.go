@@ -0,0 +1,252 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// testFont builds a TrueType font with a format 4 cmap for runes, whose
+// glyphs 1.. advance by the given widths in a 1000-unit em
+func testFont(t *testing.T, runes []rune, widths []uint16) []byte {
+	t.Helper()
+	be := binary.BigEndian
+	u16 := func(b *bytes.Buffer, v ...uint16) {
+		for _, x := range v {
+			binary.Write(b, be, x)
+		}
+	}
+	glyphs := uint16(len(runes) + 1)
+
+	var head bytes.Buffer
+	u16(&head, 1, 0, 0, 0, 0, 0, 0x5F0F, 0x3CF5, 0, 1000) // version, revision, checksum, magic, flags, unitsPerEm
+	head.Write(make([]byte, 16))                          // created, modified
+	u16(&head, 0, 0xFF38, 1000, 800, 0, 8, 2, 0, 0)       // bbox -200..800, style, ppem, direction, loca format, glyph format
+
+	var hhea bytes.Buffer
+	u16(&hhea, 1, 0, 800, 0xFF38, 0)
+	hhea.Write(make([]byte, 22))
+	u16(&hhea, 0, glyphs)
+
+	var maxp bytes.Buffer
+	u16(&maxp, 0, 0x5000, glyphs)
+
+	var hmtx bytes.Buffer
+	u16(&hmtx, 500, 0) // .notdef
+	for _, w := range widths {
+		u16(&hmtx, w, 0)
+	}
+
+	order := make([]int, len(runes))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return runes[order[i]] < runes[order[j]] })
+	segs := uint16(len(runes) + 1)
+	var sub bytes.Buffer
+	u16(&sub, 4, 0, 0, 2*segs, 0, 0, 0)
+	for _, i := range order {
+		u16(&sub, uint16(runes[i]))
+	}
+	u16(&sub, 0xFFFF, 0)
+	for _, i := range order {
+		u16(&sub, uint16(runes[i]))
+	}
+	u16(&sub, 0xFFFF)
+	for _, i := range order {
+		u16(&sub, uint16(i+1)-uint16(runes[i]))
+	}
+	u16(&sub, 1)
+	for i := uint16(0); i < segs; i++ {
+		u16(&sub, 0)
+	}
+	var cmap bytes.Buffer
+	u16(&cmap, 0, 1, 3, 1)
+	binary.Write(&cmap, be, uint32(12))
+	cmap.Write(sub.Bytes())
+
+	tables := []struct {
+		tag  string
+		body []byte
+	}{
+		{"cmap", cmap.Bytes()}, {"glyf", make([]byte, 4)}, {"head", head.Bytes()}, {"hhea", hhea.Bytes()},
+		{"hmtx", hmtx.Bytes()}, {"loca", make([]byte, 2*int(glyphs)+2)}, {"maxp", maxp.Bytes()},
+	}
+	var font bytes.Buffer
+	binary.Write(&font, be, uint32(0x00010000))
+	u16(&font, uint16(len(tables)), 0, 0, 0)
+	offset := 12 + 16*len(tables)
+	for _, tb := range tables {
+		font.WriteString(tb.tag)
+		binary.Write(&font, be, uint32(0))
+		binary.Write(&font, be, uint32(offset))
+		binary.Write(&font, be, uint32(len(tb.body)))
+		offset += len(tb.body)
+	}
+	for _, tb := range tables {
+		font.Write(tb.body)
+	}
+	return font.Bytes()
+}
+
+func TestParseFont(t *testing.T) {
+	// Thai consonant ko kai and the zero-width tone mark mai ek
+	f, err := ParseFont(testFont(t, []rune{'A', 'ก', '่', ' ', '.'}, []uint16{600, 550, 0, 250, 200}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.Has('ก') || f.Has('Z') {
+		t.Errorf("cmap = %v", f.cmap)
+	}
+	doc := New(f)
+	if got := doc.TextWidth("ก่A", 10); got != 11.5 {
+		t.Errorf("width = %v, want 11.5", got)
+	}
+	if got := doc.Truncate("AAAA A", 10, 30); got != "AAAA..." {
+		t.Errorf("truncate = %q", got)
+	}
+
+	if _, err := ParseFont([]byte("OTTO\x00\x01")); err != ErrUnsupportedFont {
+		t.Errorf("CFF font: got %v", err)
+	}
+	if _, err := ParseFont(testFont(t, nil, nil)[:40]); err == nil {
+		t.Error("truncated font parsed")
+	}
+}
+
+// objects splits a PDF into its numbered objects
+var objectRe = regexp.MustCompile(`(?s)(\d+) 0 obj\n(.*?)\nendobj\n`)
+
+// inflate returns the decompressed stream of an object body
+func inflate(t *testing.T, body string) string {
+	t.Helper()
+	start := strings.Index(body, "stream\n") + len("stream\n")
+	end := strings.LastIndex(body, "\nendstream")
+	r, err := zlib.NewReader(strings.NewReader(body[start:end]))
+	if err != nil {
+		t.Fatalf("inflate: %v", err)
+	}
+	data, _ := io.ReadAll(r)
+	return string(data)
+}
+
+func TestDocument(t *testing.T) {
+	f, err := ParseFont(testFont(t, []rune{'A', 'ก', '่'}, []uint16{600, 550, 0}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := New(f)
+	doc.Title = "รายงาน"
+	p := doc.AddPage()
+	p.Text(40, 60, "ก่A", Style{Size: 12, Bold: true})
+	p.Rect(40, 80, 100, 20, RGB(220, 53, 69))
+	doc.AddPage().Line(0, 0, 10, 10, 1, Gray)
+
+	var buf bytes.Buffer
+	n, err := doc.WriteTo(&buf)
+	if err != nil || n != int64(buf.Len()) {
+		t.Fatalf("write: %d, %v", n, err)
+	}
+	out := buf.String()
+	if !strings.HasPrefix(out, "%PDF-1.4") || !strings.HasSuffix(out, "%%EOF\n") {
+		t.Fatal("missing header or trailer")
+	}
+
+	// Every xref entry points at its object
+	xref := out[strings.LastIndex(out, "\nxref\n")+1:]
+	lines := strings.Split(xref, "\n")
+	count, _ := strconv.Atoi(strings.Fields(lines[1])[1])
+	if count != 13 {
+		t.Errorf("objects = %d, want 13", count)
+	}
+	for id := 1; id < count; id++ {
+		off, _ := strconv.Atoi(lines[2+id][:10])
+		if !strings.HasPrefix(out[off:], strconv.Itoa(id)+" 0 obj") {
+			t.Errorf("xref entry %d points at %q", id, out[off:off+10])
+		}
+	}
+	if start, _ := strconv.Atoi(strings.Split(out[strings.LastIndex(out, "startxref\n")+10:], "\n")[0]); out[start:start+4] != "xref" {
+		t.Error("startxref does not point at the xref table")
+	}
+
+	bodies := map[string]string{}
+	for _, m := range objectRe.FindAllStringSubmatch(out, -1) {
+		bodies[m[1]] = m[2]
+	}
+	if got := inflate(t, bodies["5"]); !strings.Contains(got, "<000200030001> Tj") || !strings.Contains(got, "2 Tr") {
+		t.Errorf("page content = %q", got)
+	}
+	if !strings.Contains(bodies["8"], "/Subtype /Type0") || !strings.Contains(bodies["9"], "/W [1 [600] 2 [550] 3 [0] ]") {
+		t.Errorf("font objects = %q / %q", bodies["8"], bodies["9"])
+	}
+	if !strings.Contains(bodies["3"], "<FEFF0E230E320E220E070E320E19>") {
+		t.Errorf("info = %q", bodies["3"])
+	}
+	if cmap := inflate(t, bodies["12"]); !strings.Contains(cmap, "<0002> <0E01>") || !strings.Contains(cmap, "<0003> <0E48>") {
+		t.Errorf("ToUnicode = %q", cmap)
+	}
+}
+
+func TestDefaultFont(t *testing.T) {
+	f := DefaultFont()
+	const text = "รายงานความง่วง ไม่ได้พักผ่อน 12:30 (Fleet A)"
+	for _, r := range text {
+		if !f.Has(r) {
+			t.Errorf("no glyph for %q", r)
+		}
+	}
+	doc := New(f)
+	doc.AddPage().Text(40, 60, text, Style{Size: 12})
+	var buf bytes.Buffer
+	if _, err := doc.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	bodies := map[string]string{}
+	for _, m := range objectRe.FindAllStringSubmatch(buf.String(), -1) {
+		bodies[m[1]] = m[2]
+	}
+
+	// Map the drawn glyphs back through ToUnicode
+	toUnicode := map[string]rune{}
+	for _, m := range regexp.MustCompile(`<([0-9A-F]{4})> <([0-9A-F]{4})>`).FindAllStringSubmatch(inflate(t, bodies["10"]), -1) {
+		u, _ := strconv.ParseUint(m[2], 16, 16)
+		toUnicode[m[1]] = rune(u)
+	}
+	shown := regexp.MustCompile(`<([0-9A-F]*)> Tj`).FindStringSubmatch(inflate(t, bodies["5"]))
+	if shown == nil {
+		t.Fatal("no text drawn")
+	}
+	var got []rune
+	for i := 0; i+4 <= len(shown[1]); i += 4 {
+		got = append(got, toUnicode[shown[1][i:i+4]])
+	}
+	if string(got) != text {
+		t.Errorf("text round-trips as %q", string(got))
+	}
+	if !strings.Contains(bodies["8"], "/FontFile2 9 0 R") || !strings.Contains(bodies["9"], fmt.Sprintf("/Length1 %d", len(notoSansThai))) {
+		t.Errorf("font file not embedded: %q", bodies["8"])
+	}
+}
+
+func TestHelveticaFallback(t *testing.T) {
+	doc := New(nil)
+	doc.AddPage().Text(10, 10, "Café (ก)", Style{Size: 10})
+	var buf bytes.Buffer
+	if _, err := doc.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	content := inflate(t, objectRe.FindAllStringSubmatch(buf.String(), -1)[4][2])
+	if !strings.Contains(content, `(Caf\351 \(?\)) Tj`) {
+		t.Errorf("content = %q", content)
+	}
+	if !strings.Contains(buf.String(), "/BaseFont /Helvetica") {
+		t.Error("Helvetica not referenced")
+	}
+}
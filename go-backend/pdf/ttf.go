@@ -0,0 +1,235 @@
+package pdf
+
+import (
+	_ "embed"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// notoSansThai is Noto Sans Thai Regular 2.001, under the SIL Open Font
+// License (fonts/OFL.txt). It covers Thai, Latin and digits.
+//
+//go:embed fonts/NotoSansThai-Regular.ttf
+var notoSansThai []byte
+
+// ErrUnsupportedFont is returned for fonts that are not TrueType outlines
+var ErrUnsupportedFont = errors.New("pdf: font must be a TrueType (.ttf) font")
+
+// Font is a parsed TrueType font, embedded whole into every document that
+// uses it. Only the tables needed for layout and embedding are read.
+type Font struct {
+	data       []byte
+	unitsPerEm float64
+	bbox       [4]int16
+	ascent     int16
+	descent    int16
+	advances   []uint16 // per glyph
+	cmap       map[rune]uint16
+}
+
+// DefaultFont returns the embedded Noto Sans Thai, so documents render Thai
+// without any font installed
+func DefaultFont() *Font {
+	f, err := ParseFont(notoSansThai)
+	if err != nil {
+		panic(fmt.Sprintf("pdf: embedded font: %v", err))
+	}
+	return f
+}
+
+// LoadFont reads a TrueType font file such as Sarabun or Noto Sans Thai
+func LoadFont(path string) (*Font, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseFont(data)
+}
+
+// ParseFont parses TrueType font data
+func ParseFont(data []byte) (*Font, error) {
+	if len(data) < 12 {
+		return nil, ErrUnsupportedFont
+	}
+	if v := binary.BigEndian.Uint32(data); v != 0x00010000 && v != 0x74727565 { // 'true'
+		return nil, ErrUnsupportedFont
+	}
+	tables := make(map[string][]byte)
+	n := int(binary.BigEndian.Uint16(data[4:]))
+	for i := 0; i < n; i++ {
+		rec := 12 + 16*i
+		if rec+16 > len(data) {
+			return nil, ErrUnsupportedFont
+		}
+		off := binary.BigEndian.Uint32(data[rec+8:])
+		size := binary.BigEndian.Uint32(data[rec+12:])
+		if uint64(off)+uint64(size) > uint64(len(data)) {
+			return nil, fmt.Errorf("pdf: font table %q is truncated", data[rec:rec+4])
+		}
+		tables[string(data[rec:rec+4])] = data[off : off+size]
+	}
+	for _, name := range []string{"head", "hhea", "maxp", "hmtx", "cmap", "glyf", "loca"} {
+		if tables[name] == nil {
+			return nil, fmt.Errorf("pdf: font has no %s table", name)
+		}
+	}
+
+	f := &Font{data: data}
+	head, hhea, maxp := tables["head"], tables["hhea"], tables["maxp"]
+	if len(head) < 54 || len(hhea) < 36 || len(maxp) < 6 {
+		return nil, ErrUnsupportedFont
+	}
+	f.unitsPerEm = float64(binary.BigEndian.Uint16(head[18:]))
+	if f.unitsPerEm == 0 {
+		return nil, ErrUnsupportedFont
+	}
+	for i := range f.bbox {
+		f.bbox[i] = int16(binary.BigEndian.Uint16(head[36+2*i:]))
+	}
+	f.ascent = int16(binary.BigEndian.Uint16(hhea[4:]))
+	f.descent = int16(binary.BigEndian.Uint16(hhea[6:]))
+
+	// Glyphs past numberOfHMetrics repeat the last advance
+	glyphs := int(binary.BigEndian.Uint16(maxp[4:]))
+	metrics := int(binary.BigEndian.Uint16(hhea[34:]))
+	hmtx := tables["hmtx"]
+	if metrics == 0 || metrics > glyphs || len(hmtx) < 4*metrics {
+		return nil, ErrUnsupportedFont
+	}
+	f.advances = make([]uint16, glyphs)
+	for g := range f.advances {
+		m := g
+		if m >= metrics {
+			m = metrics - 1
+		}
+		f.advances[g] = binary.BigEndian.Uint16(hmtx[4*m:])
+	}
+
+	cmap, err := parseCmap(tables["cmap"], glyphs)
+	if err != nil {
+		return nil, err
+	}
+	f.cmap = cmap
+	return f, nil
+}
+
+// parseCmap reads the best Unicode subtable: full-repertoire format 12,
+// else BMP format 4
+func parseCmap(t []byte, glyphs int) (map[rune]uint16, error) {
+	if len(t) < 4 {
+		return nil, ErrUnsupportedFont
+	}
+	best, bestRank := -1, 0
+	n := int(binary.BigEndian.Uint16(t[2:]))
+	for i := 0; i < n; i++ {
+		rec := 4 + 8*i
+		if rec+8 > len(t) {
+			break
+		}
+		platform := binary.BigEndian.Uint16(t[rec:])
+		encoding := binary.BigEndian.Uint16(t[rec+2:])
+		off := int(binary.BigEndian.Uint32(t[rec+4:]))
+		if off+2 > len(t) {
+			continue
+		}
+		format := binary.BigEndian.Uint16(t[off:])
+		rank := 0
+		switch {
+		case format == 12 && (platform == 3 && encoding == 10 || platform == 0):
+			rank = 2
+		case format == 4 && (platform == 3 && encoding == 1 || platform == 0):
+			rank = 1
+		}
+		if rank > bestRank {
+			best, bestRank = off, rank
+		}
+	}
+	if best < 0 {
+		return nil, errors.New("pdf: font has no Unicode cmap")
+	}
+
+	m := make(map[rune]uint16)
+	add := func(r rune, g int) {
+		if g > 0 && g < glyphs {
+			m[r] = uint16(g)
+		}
+	}
+	s := t[best:]
+	if bestRank == 2 {
+		if len(s) < 16 {
+			return nil, ErrUnsupportedFont
+		}
+		groups := int(binary.BigEndian.Uint32(s[12:]))
+		if 16+12*groups > len(s) {
+			return nil, ErrUnsupportedFont
+		}
+		for i := 0; i < groups; i++ {
+			g := s[16+12*i:]
+			start, end := binary.BigEndian.Uint32(g), binary.BigEndian.Uint32(g[4:])
+			gid := binary.BigEndian.Uint32(g[8:])
+			if end < start || end > 0x10FFFF {
+				continue
+			}
+			for c := start; c <= end; c++ {
+				add(rune(c), int(gid+c-start))
+			}
+		}
+		return m, nil
+	}
+
+	if len(s) < 14 {
+		return nil, ErrUnsupportedFont
+	}
+	segs := int(binary.BigEndian.Uint16(s[6:])) / 2
+	ends, starts := 14, 16+2*segs
+	deltas, ranges := starts+2*segs, starts+4*segs
+	if ranges+2*segs > len(s) {
+		return nil, ErrUnsupportedFont
+	}
+	for i := 0; i < segs; i++ {
+		end := int(binary.BigEndian.Uint16(s[ends+2*i:]))
+		start := int(binary.BigEndian.Uint16(s[starts+2*i:]))
+		delta := int(binary.BigEndian.Uint16(s[deltas+2*i:]))
+		rangeOff := int(binary.BigEndian.Uint16(s[ranges+2*i:]))
+		for c := start; c <= end && c != 0xFFFF; c++ {
+			if rangeOff == 0 {
+				add(rune(c), (c+delta)&0xFFFF)
+				continue
+			}
+			at := ranges + 2*i + rangeOff + 2*(c-start)
+			if at+2 > len(s) {
+				break
+			}
+			if g := int(binary.BigEndian.Uint16(s[at:])); g != 0 {
+				add(rune(c), (g+delta)&0xFFFF)
+			}
+		}
+	}
+	return m, nil
+}
+
+// glyph returns the glyph of r, 0 (.notdef) when the font lacks it
+func (f *Font) glyph(r rune) uint16 {
+	return f.cmap[r]
+}
+
+// Has reports whether the font has a glyph for r
+func (f *Font) Has(r rune) bool {
+	_, ok := f.cmap[r]
+	return ok
+}
+
+// advance returns the advance width of glyph g in 1/1000 em
+func (f *Font) advance(g uint16) float64 {
+	if int(g) >= len(f.advances) {
+		return 0
+	}
+	return float64(f.advances[g]) * 1000 / f.unitsPerEm
+}
+
+// scale converts font units to 1/1000 em
+func (f *Font) scale(v int16) int {
+	return int(float64(v) * 1000 / f.unitsPerEm)
+}
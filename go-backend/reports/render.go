@@ -0,0 +1,321 @@
+package reports
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	"driver-drowsiness-backend/i18n"
+	"driver-drowsiness-backend/models"
+	"driver-drowsiness-backend/pdf"
+)
+
+// topDrivers is the length of the top-risk table on the overview page
+const topDrivers = 10
+
+const margin = 40
+
+var (
+	headerColor = pdf.RGB(13, 71, 161)
+	highColor   = pdf.RGB(220, 53, 69)
+	mediumColor = pdf.RGB(255, 179, 0)
+	cardColor   = pdf.RGB(241, 243, 245)
+)
+
+var (
+	titleFleet  = i18n.Label{EN: "Weekly safety report", TH: "รายงานความปลอดภัยประจำสัปดาห์"}
+	titleDaily  = i18n.Label{EN: "Daily safety report", TH: "รายงานความปลอดภัยประจำวัน"}
+	titleDriver = i18n.Label{EN: "Driver safety report", TH: "รายงานความปลอดภัยของคนขับ"}
+	labelHigh   = i18n.Label{EN: "High events", TH: "เหตุการณ์ระดับสูง"}
+	labelMedium = i18n.Label{EN: "Medium events", TH: "เหตุการณ์ระดับกลาง"}
+	labelActive = i18n.Label{EN: "Drivers with events", TH: "คนขับที่มีเหตุการณ์"}
+	labelRisk   = i18n.Label{EN: "Risk score", TH: "คะแนนความเสี่ยง"}
+	labelPeak   = i18n.Label{EN: "Worst time slot", TH: "ช่วงเวลาเสี่ยงสุด"}
+	labelTrend  = i18n.Label{EN: "Daily trend", TH: "แนวโน้มรายวัน"}
+	labelSlots  = i18n.Label{EN: "Events by time of day", TH: "เหตุการณ์ตามช่วงเวลาของวัน"}
+	labelTop    = i18n.Label{EN: "Top-risk drivers", TH: "คนขับที่มีความเสี่ยงสูงสุด"}
+	labelWorst  = i18n.Label{EN: "Worst time slots", TH: "ช่วงเวลาที่เสี่ยงที่สุด"}
+	labelDriver = i18n.Label{EN: "Driver", TH: "คนขับ"}
+	labelHighS  = i18n.Label{EN: "High", TH: "สูง"}
+	labelMedS   = i18n.Label{EN: "Medium", TH: "กลาง"}
+	labelNone   = i18n.Label{EN: "No medium or high events in this period", TH: "ไม่มีเหตุการณ์ระดับกลางหรือสูงในช่วงนี้"}
+	labelPage   = i18n.Label{EN: "Page", TH: "หน้า"}
+	labelMade   = i18n.Label{EN: "Generated", TH: "สร้างเมื่อ"}
+	labelNoName = i18n.Label{EN: "Unassigned device", TH: "อุปกรณ์ที่ไม่มีคนขับ"}
+)
+
+var weekdays = map[string][7]string{
+	i18n.Thai:    {"อา.", "จ.", "อ.", "พ.", "พฤ.", "ศ.", "ส."},
+	i18n.English: {"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"},
+}
+
+// layout draws a summary into a document
+type layout struct {
+	doc  *pdf.Document
+	s    *Summary
+	lang string
+	made time.Time
+}
+
+// Render lays out a summary: a fleet report has an overview page followed
+// by a page per driver with events, a driver report the driver page only.
+// A fleet report of a single day is titled a daily report.
+// Labels are in Thai when the font has Thai glyphs, else in English.
+func Render(s *Summary, kind string, font *pdf.Font, now time.Time) *pdf.Document {
+	l := &layout{doc: pdf.New(font), s: s, lang: i18n.English, made: now}
+	if font != nil && font.Has('ก') {
+		l.lang = i18n.Thai
+	}
+	title := titleFleet
+	switch {
+	case kind == models.ReportDriver:
+		title = titleDriver
+	case s.Period.To.Sub(s.Period.From) <= 25*time.Hour: // a day, DST included
+		title = titleDaily
+	}
+	l.doc.Title = title.In(l.lang) + " – " + s.Subject
+
+	if kind == models.ReportDriver {
+		d := Driver{Name: s.Subject, Breakdown: s.Breakdown}
+		if len(s.Drivers) > 0 {
+			d.ID = s.Drivers[0].ID
+		}
+		l.driverPage(title, d)
+		return l.doc
+	}
+	l.overview(title)
+	for _, d := range s.Drivers {
+		l.driverPage(title, d)
+	}
+	return l.doc
+}
+
+func (l *layout) text(size float64) pdf.Style {
+	return pdf.Style{Size: size, Color: pdf.Black}
+}
+
+func (l *layout) bold(size float64) pdf.Style {
+	return pdf.Style{Size: size, Bold: true, Color: pdf.Black}
+}
+
+func (l *layout) muted(size float64) pdf.Style {
+	return pdf.Style{Size: size, Color: pdf.Gray}
+}
+
+// page starts a page with the title band and footer
+func (l *layout) page(title i18n.Label, heading string) *pdf.Page {
+	p := l.doc.AddPage()
+	p.Rect(0, 0, pdf.PageWidth, 78, headerColor)
+	p.Text(margin, 34, title.In(l.lang), pdf.Style{Size: 18, Bold: true, Color: pdf.White})
+	p.Text(margin, 58, l.doc.Truncate(heading, 12, pdf.PageWidth-2*margin-170), pdf.Style{Size: 12, Color: pdf.White})
+
+	loc := l.s.Location
+	period := l.s.Period.From.In(loc).Format("2006-01-02") + " – " +
+		l.s.Period.To.Add(-time.Nanosecond).In(loc).Format("2006-01-02")
+	p.TextRight(pdf.PageWidth-margin, 34, period, pdf.Style{Size: 11, Color: pdf.White})
+	p.TextRight(pdf.PageWidth-margin, 58, loc.String(), pdf.Style{Size: 9, Color: pdf.White})
+
+	p.Line(margin, pdf.PageHeight-36, pdf.PageWidth-margin, pdf.PageHeight-36, 0.5, pdf.Light)
+	p.Text(margin, pdf.PageHeight-22, labelMade.In(l.lang)+" "+l.made.In(loc).Format("2006-01-02 15:04"), l.muted(8))
+	p.TextRight(pdf.PageWidth-margin, pdf.PageHeight-22, labelPage.In(l.lang)+" "+strconv.Itoa(l.doc.Pages()), l.muted(8))
+	return p
+}
+
+// card draws a labeled figure
+func (l *layout) card(p *pdf.Page, x, y, w float64, label, value string, accent pdf.Color) {
+	p.Rect(x, y, w, 62, cardColor)
+	p.Rect(x, y, 4, 62, accent)
+	p.Text(x+14, y+22, l.doc.Truncate(label, 9, w-20), l.muted(9))
+	p.Text(x+14, y+48, l.doc.Truncate(value, 20, w-20), l.bold(20))
+}
+
+// cards draws a row of four cards
+func (l *layout) cards(p *pdf.Page, y float64, labels []i18n.Label, values []string, accents []pdf.Color) {
+	w := (pdf.PageWidth - 2*margin - 3*10) / 4
+	for i := range labels {
+		l.card(p, margin+float64(i)*(w+10), y, w, labels[i].In(l.lang), values[i], accents[i])
+	}
+}
+
+// section draws a section heading and returns the y below it
+func (l *layout) section(p *pdf.Page, y float64, label i18n.Label) float64 {
+	p.Text(margin, y+14, label.In(l.lang), l.bold(13))
+	return y + 26
+}
+
+// niceMax rounds v up to 1, 2 or 5 times a power of ten
+func niceMax(v int) int {
+	if v <= 4 {
+		return 4
+	}
+	mag := math.Pow(10, math.Floor(math.Log10(float64(v))))
+	for _, m := range []float64{1, 2, 5, 10} {
+		if n := int(m * mag); n >= v {
+			return n
+		}
+	}
+	return v
+}
+
+// chart draws grouped high and medium bars, one group per label
+func (l *layout) chart(p *pdf.Page, x, y, w, h float64, labels []string, values []Counts) {
+	top := 0
+	for _, v := range values {
+		top = max(top, v.High, v.Medium)
+	}
+	top = niceMax(top)
+
+	axis := 28.0
+	plotX, plotW, plotH := x+axis, w-axis, h-18
+	for i := 0; i <= 4; i++ {
+		gy := y + plotH - plotH*float64(i)/4
+		p.Line(plotX, gy, plotX+plotW, gy, 0.4, pdf.Light)
+		p.TextRight(plotX-4, gy+3, strconv.Itoa(top*i/4), l.muted(7))
+	}
+
+	group := plotW / float64(len(values))
+	bar := math.Min(group*0.32, 18)
+	for i, v := range values {
+		gx := plotX + group*float64(i) + (group-2*bar)/2
+		for j, c := range []struct {
+			n     int
+			color pdf.Color
+		}{{v.High, highColor}, {v.Medium, mediumColor}} {
+			bh := plotH * float64(c.n) / float64(top)
+			if bh > 0 {
+				p.Rect(gx+float64(j)*bar, y+plotH-bh, bar-1, bh, c.color)
+			}
+		}
+		lw := l.doc.TextWidth(labels[i], 7)
+		p.Text(plotX+group*float64(i)+(group-lw)/2, y+plotH+11, labels[i], l.muted(7))
+	}
+
+	// Legend above the top-right corner
+	lx := x + w - 120
+	for i, item := range []struct {
+		label i18n.Label
+		color pdf.Color
+	}{{labelHighS, highColor}, {labelMedS, mediumColor}} {
+		ix := lx + float64(i)*60
+		p.Rect(ix, y-14, 8, 8, item.color)
+		p.Text(ix+11, y-7, item.label.In(l.lang), l.muted(8))
+	}
+}
+
+// dayLabels returns "Mon 03/11" style labels of the days
+func (l *layout) dayLabels(days []Day) ([]string, []Counts) {
+	labels := make([]string, len(days))
+	values := make([]Counts, len(days))
+	for i, d := range days {
+		labels[i] = weekdays[l.lang][d.Date.Weekday()] + " " + d.Date.Format("02/01")
+		values[i] = d.Counts
+	}
+	// Long periods keep every label readable by showing dates only
+	if len(days) > 10 {
+		for i, d := range days {
+			labels[i] = d.Date.Format("02")
+		}
+	}
+	return labels, values
+}
+
+func slotLabels(slots []Slot) ([]string, []Counts) {
+	labels := make([]string, len(slots))
+	values := make([]Counts, len(slots))
+	for i, s := range slots {
+		labels[i] = s.Label()
+		values[i] = s.Counts
+	}
+	return labels, values
+}
+
+// peak returns the label of the worst slot, or "-"
+func peak(b *Breakdown) string {
+	worst := b.Worst(1)
+	if len(worst) == 0 {
+		return "-"
+	}
+	return worst[0].Label()
+}
+
+func (l *layout) driverName(d Driver) string {
+	if d.Name == "" {
+		return labelNoName.In(l.lang)
+	}
+	return d.Name
+}
+
+func (l *layout) overview(title i18n.Label) {
+	s := l.s
+	p := l.page(title, s.Subject)
+	l.cards(p, 96,
+		[]i18n.Label{labelHigh, labelMedium, labelActive, labelPeak},
+		[]string{strconv.Itoa(s.High), strconv.Itoa(s.Medium), strconv.Itoa(len(s.Drivers)), peak(&s.Breakdown)},
+		[]pdf.Color{highColor, mediumColor, headerColor, pdf.Gray})
+
+	y := l.section(p, 176, labelTrend)
+	labels, values := l.dayLabels(s.Days)
+	l.chart(p, margin, y+16, pdf.PageWidth-2*margin, 150, labels, values)
+
+	y = l.section(p, y+186, labelSlots)
+	labels, values = slotLabels(s.Slots)
+	l.chart(p, margin, y+16, pdf.PageWidth-2*margin, 130, labels, values)
+
+	y = l.section(p, y+166, labelTop)
+	if len(s.Drivers) == 0 {
+		p.Text(margin, y+12, labelNone.In(l.lang), l.muted(10))
+		return
+	}
+	cols := []float64{margin, margin + 28, pdf.PageWidth - margin - 170, pdf.PageWidth - margin - 100, pdf.PageWidth - margin}
+	p.Rect(margin, y, pdf.PageWidth-2*margin, 18, cardColor)
+	p.Text(cols[0]+4, y+12, "#", l.bold(9))
+	p.Text(cols[1], y+12, labelDriver.In(l.lang), l.bold(9))
+	p.TextRight(cols[2], y+12, labelHighS.In(l.lang), l.bold(9))
+	p.TextRight(cols[3], y+12, labelMedS.In(l.lang), l.bold(9))
+	p.TextRight(cols[4]-4, y+12, labelRisk.In(l.lang), l.bold(9))
+	for i, d := range s.Drivers {
+		if i == topDrivers {
+			break
+		}
+		ry := y + 18 + float64(i)*17
+		p.Text(cols[0]+4, ry+12, strconv.Itoa(i+1), l.text(9))
+		p.Text(cols[1], ry+12, l.doc.Truncate(l.driverName(d), 9, cols[2]-cols[1]-50), l.text(9))
+		p.TextRight(cols[2], ry+12, strconv.Itoa(d.High), l.text(9))
+		p.TextRight(cols[3], ry+12, strconv.Itoa(d.Medium), l.text(9))
+		p.TextRight(cols[4]-4, ry+12, strconv.Itoa(d.Risk()), l.bold(9))
+		p.Line(margin, ry+17, pdf.PageWidth-margin, ry+17, 0.3, pdf.Light)
+	}
+}
+
+func (l *layout) driverPage(title i18n.Label, d Driver) {
+	heading := l.driverName(d)
+	if d.ID != 0 && l.s.Subject != heading {
+		heading += " · " + l.s.Subject
+	}
+	p := l.page(title, heading)
+	l.cards(p, 96,
+		[]i18n.Label{labelHigh, labelMedium, labelRisk, labelPeak},
+		[]string{strconv.Itoa(d.High), strconv.Itoa(d.Medium), strconv.Itoa(d.Risk()), peak(&d.Breakdown)},
+		[]pdf.Color{highColor, mediumColor, headerColor, pdf.Gray})
+
+	y := l.section(p, 176, labelTrend)
+	labels, values := l.dayLabels(d.Days)
+	l.chart(p, margin, y+16, pdf.PageWidth-2*margin, 150, labels, values)
+
+	y = l.section(p, y+186, labelSlots)
+	labels, values = slotLabels(d.Slots)
+	l.chart(p, margin, y+16, pdf.PageWidth-2*margin, 130, labels, values)
+
+	y = l.section(p, y+166, labelWorst)
+	worst := d.Worst(3)
+	if len(worst) == 0 {
+		p.Text(margin, y+12, labelNone.In(l.lang), l.muted(10))
+		return
+	}
+	for i, s := range worst {
+		ry := y + float64(i)*20
+		p.Text(margin, ry+13, strconv.Itoa(i+1)+".  "+s.Label(), l.bold(11))
+		p.Text(margin+90, ry+13, labelHighS.In(l.lang)+" "+strconv.Itoa(s.High)+"   "+
+			labelMedS.In(l.lang)+" "+strconv.Itoa(s.Medium), l.text(10))
+	}
+}
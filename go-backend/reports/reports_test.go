@@ -0,0 +1,95 @@
+package reports
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"driver-drowsiness-backend/models"
+	"driver-drowsiness-backend/repository"
+)
+
+var bangkok = time.FixedZone("ICT", 7*3600)
+
+func TestLastWeek(t *testing.T) {
+	// Sunday 2025-11-09 10:30 Bangkok
+	week := LastWeek(time.Date(2025, 11, 9, 3, 30, 0, 0, time.UTC), bangkok)
+	if from := week.From.In(bangkok); from.Format("2006-01-02 15:04 Mon") != "2025-10-27 00:00 Mon" {
+		t.Errorf("from = %v", from)
+	}
+	if to := week.To.In(bangkok); to.Format("2006-01-02 15:04 Mon") != "2025-11-03 00:00 Mon" {
+		t.Errorf("to = %v", to)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	period := repository.TimeRange{
+		From: time.Date(2025, 11, 3, 0, 0, 0, 0, bangkok),
+		To:   time.Date(2025, 11, 10, 0, 0, 0, 0, bangkok),
+	}
+	hour := func(day, h int) time.Time { return time.Date(2025, 11, day, h, 0, 0, 0, bangkok).UTC() }
+	counts := []repository.HourlyCount{
+		{Hour: hour(3, 1), DriverID: 1, DriverName: "Somchai", High: 2, Medium: 1},
+		{Hour: hour(3, 2), DriverID: 1, DriverName: "Somchai", High: 1},
+		{Hour: hour(4, 14), DriverID: 2, DriverName: "Malee", Medium: 4},
+		{Hour: hour(9, 23), DriverID: 2, DriverName: "Malee", High: 1},
+		{Hour: hour(10, 0), DriverID: 2, DriverName: "Malee", High: 9}, // after the period
+	}
+	s := Summarize("Bangkok", counts, period, bangkok)
+
+	if len(s.Days) != 7 || s.High != 4 || s.Medium != 5 {
+		t.Fatalf("days %d, totals %+v", len(s.Days), s.Counts)
+	}
+	if d := s.Days[0]; d.Date.Format("Mon 02") != "Mon 03" || d.High != 3 || d.Medium != 1 {
+		t.Errorf("first day = %+v", d)
+	}
+	if got := s.Slots[0]; got.Label() != "00-02" || got.High != 2 || got.Medium != 1 {
+		t.Errorf("slot 00-02 = %+v", got)
+	}
+	if worst := s.Worst(2); len(worst) != 2 || worst[0].Label() != "00-02" || worst[1].Label() != "02-04" {
+		t.Errorf("worst slots = %+v", worst)
+	}
+
+	// Somchai's risk 3*3+1 = 10 beats Malee's 3*1+4 = 7
+	if len(s.Drivers) != 2 || s.Drivers[0].Name != "Somchai" || s.Drivers[0].Risk() != 10 || s.Drivers[1].Risk() != 7 {
+		t.Fatalf("drivers = %+v", s.Drivers)
+	}
+	if malee := s.Drivers[1]; malee.Days[6].High != 1 || malee.Slots[11].High != 1 {
+		t.Errorf("Malee breakdown = %+v", malee.Breakdown)
+	}
+}
+
+func TestRender(t *testing.T) {
+	period := repository.TimeRange{
+		From: time.Date(2025, 11, 3, 0, 0, 0, 0, bangkok),
+		To:   time.Date(2025, 11, 10, 0, 0, 0, 0, bangkok),
+	}
+	counts := []repository.HourlyCount{
+		{Hour: period.From.Add(time.Hour), DriverID: 1, DriverName: "Somchai", High: 2},
+		{Hour: period.From.Add(30 * time.Hour), DriverID: 2, DriverName: "Malee", Medium: 1},
+	}
+	now := time.Date(2025, 11, 10, 1, 0, 0, 0, time.UTC)
+
+	fleet := Render(Summarize("Bangkok", counts, period, bangkok), models.ReportFleet, nil, now)
+	if fleet.Pages() != 3 || fleet.Title != "Weekly safety report – Bangkok" {
+		t.Errorf("fleet report: %d pages, title %q", fleet.Pages(), fleet.Title)
+	}
+	var buf bytes.Buffer
+	if _, err := fleet.WriteTo(&buf); err != nil || !strings.HasPrefix(buf.String(), "%PDF-") {
+		t.Fatalf("write: %v", err)
+	}
+
+	empty := Render(Summarize("Malee", nil, period, bangkok), models.ReportDriver, nil, now)
+	if empty.Pages() != 1 {
+		t.Errorf("empty driver report: %d pages", empty.Pages())
+	}
+}
+
+func TestNiceMax(t *testing.T) {
+	for v, want := range map[int]int{0: 4, 3: 4, 7: 10, 12: 20, 45: 50, 100: 100, 101: 200} {
+		if got := niceMax(v); got != want {
+			t.Errorf("niceMax(%d) = %d, want %d", v, got, want)
+		}
+	}
+}
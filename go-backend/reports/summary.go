@@ -0,0 +1,170 @@
+// Package reports summarizes medium and high drowsiness events over a
+// period and renders the summary as a PDF safety report
+package reports
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"driver-drowsiness-backend/repository"
+)
+
+// SlotHours is the width of a time-of-day slot, as in the admin dashboard
+const SlotHours = 2
+
+// Counts are medium and high drowsiness events
+type Counts struct {
+	High   int
+	Medium int
+}
+
+// Total returns all events
+func (c Counts) Total() int {
+	return c.High + c.Medium
+}
+
+// Risk weighs a high event as three medium ones
+func (c Counts) Risk() int {
+	return 3*c.High + c.Medium
+}
+
+func (c *Counts) add(high, medium int) {
+	c.High += high
+	c.Medium += medium
+}
+
+// Day is the events of one local calendar day
+type Day struct {
+	Date time.Time // local midnight
+	Counts
+}
+
+// Slot is the events of one time-of-day slot over the whole period
+type Slot struct {
+	Start int // local hour
+	Counts
+}
+
+// Label returns the slot as "HH-HH"
+func (s Slot) Label() string {
+	return fmt.Sprintf("%02d-%02d", s.Start, s.Start+SlotHours)
+}
+
+// Breakdown is the events of a fleet or driver by day and by slot
+type Breakdown struct {
+	Counts
+	Days  []Day
+	Slots []Slot
+}
+
+// Worst returns the n slots with the most high events, then medium ones;
+// slots without events are left out
+func (b *Breakdown) Worst(n int) []Slot {
+	var slots []Slot
+	for _, s := range b.Slots {
+		if s.Total() > 0 {
+			slots = append(slots, s)
+		}
+	}
+	sort.SliceStable(slots, func(i, j int) bool {
+		if slots[i].High != slots[j].High {
+			return slots[i].High > slots[j].High
+		}
+		return slots[i].Medium > slots[j].Medium
+	})
+	if len(slots) > n {
+		slots = slots[:n]
+	}
+	return slots
+}
+
+// Driver is the breakdown of one driver
+type Driver struct {
+	ID   int
+	Name string
+	Breakdown
+}
+
+// Summary is the content of a report
+type Summary struct {
+	Subject  string // fleet or driver name
+	Period   repository.TimeRange
+	Location *time.Location
+	Breakdown
+	Drivers []Driver // riskiest first
+}
+
+// newBreakdown returns a zeroed breakdown over the local days of period
+func newBreakdown(days []time.Time) Breakdown {
+	b := Breakdown{Days: make([]Day, len(days)), Slots: make([]Slot, 24/SlotHours)}
+	for i, d := range days {
+		b.Days[i].Date = d
+	}
+	for i := range b.Slots {
+		b.Slots[i].Start = i * SlotHours
+	}
+	return b
+}
+
+// localDays returns the local midnights of the days overlapping period
+func localDays(period repository.TimeRange, loc *time.Location) []time.Time {
+	var days []time.Time
+	for d := repository.DayRange(period.From, loc).From.In(loc); d.Before(period.To); {
+		days = append(days, d)
+		d = time.Date(d.Year(), d.Month(), d.Day()+1, 0, 0, 0, 0, loc)
+	}
+	return days
+}
+
+// Summarize folds hourly counts into a summary on the calendar of loc
+func Summarize(subject string, counts []repository.HourlyCount, period repository.TimeRange, loc *time.Location) *Summary {
+	days := localDays(period, loc)
+	dayIndex := make(map[string]int, len(days))
+	for i, d := range days {
+		dayIndex[d.Format("2006-01-02")] = i
+	}
+
+	s := &Summary{Subject: subject, Period: period, Location: loc, Breakdown: newBreakdown(days)}
+	drivers := make(map[int]*Driver)
+	for _, h := range counts {
+		local := h.Hour.In(loc)
+		day, ok := dayIndex[local.Format("2006-01-02")]
+		if !ok {
+			continue
+		}
+		slot := local.Hour() / SlotHours
+
+		d, ok := drivers[h.DriverID]
+		if !ok {
+			d = &Driver{ID: h.DriverID, Name: h.DriverName, Breakdown: newBreakdown(days)}
+			drivers[h.DriverID] = d
+		}
+		for _, b := range []*Breakdown{&s.Breakdown, &d.Breakdown} {
+			b.add(h.High, h.Medium)
+			b.Days[day].add(h.High, h.Medium)
+			b.Slots[slot].add(h.High, h.Medium)
+		}
+	}
+
+	for _, d := range drivers {
+		s.Drivers = append(s.Drivers, *d)
+	}
+	sort.Slice(s.Drivers, func(i, j int) bool {
+		a, b := s.Drivers[i], s.Drivers[j]
+		if a.Risk() != b.Risk() {
+			return a.Risk() > b.Risk()
+		}
+		return a.ID < b.ID
+	})
+	return s
+}
+
+// LastWeek returns the Monday-to-Monday week before the one containing now
+func LastWeek(now time.Time, loc *time.Location) repository.TimeRange {
+	monday := repository.Bucket1w.Start(now, loc)
+	return repository.TimeRange{
+		From: time.Date(monday.Year(), monday.Month(), monday.Day()-7, 0, 0, 0, 0, loc).UTC(),
+		To:   monday.UTC(),
+	}
+}
@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// Bucket is the width of one analytics time bucket
+type Bucket string
+
+const (
+	Bucket15m Bucket = "15m"
+	Bucket1h  Bucket = "1h"
+	Bucket1d  Bucket = "1d"
+	Bucket1w  Bucket = "1w"
+)
+
+// Analytics groupings
+const (
+	GroupNone    = ""
+	GroupFleet   = "fleet"
+	GroupDriver  = "driver"
+	GroupDevice  = "device"
+	GroupVehicle = "vehicle"
+)
+
+// Valid reports whether b is a supported bucket width
+func (b Bucket) Valid() bool {
+	switch b {
+	case Bucket15m, Bucket1h, Bucket1d, Bucket1w:
+		return true
+	}
+	return false
+}
+
+// Start returns the start of the bucket containing t, on the wall clock of loc.
+// Weeks start on Monday, matching PostgreSQL date_trunc('week').
+func (b Bucket) Start(t time.Time, loc *time.Location) time.Time {
+	l := t.In(loc)
+	switch b {
+	case Bucket15m:
+		return time.Date(l.Year(), l.Month(), l.Day(), l.Hour(), l.Minute()/15*15, 0, 0, loc)
+	case Bucket1h:
+		return time.Date(l.Year(), l.Month(), l.Day(), l.Hour(), 0, 0, 0, loc)
+	case Bucket1w:
+		offset := (int(l.Weekday()) + 6) % 7
+		return time.Date(l.Year(), l.Month(), l.Day()-offset, 0, 0, 0, 0, loc)
+	default:
+		return time.Date(l.Year(), l.Month(), l.Day(), 0, 0, 0, 0, loc)
+	}
+}
+
+// Next returns the start of the bucket following the one starting at start
+func (b Bucket) Next(start time.Time) time.Time {
+	switch b {
+	case Bucket15m:
+		return start.Add(15 * time.Minute)
+	case Bucket1h:
+		return start.Add(time.Hour)
+	case Bucket1w:
+		return start.AddDate(0, 0, 7)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+// ValidGroup reports whether g is a supported grouping
+func ValidGroup(g string) bool {
+	switch g {
+	case GroupNone, GroupFleet, GroupDriver, GroupDevice, GroupVehicle:
+		return true
+	}
+	return false
+}
+
+// AnalyticsQuery selects drowsiness samples to count per bucket and group
+type AnalyticsQuery struct {
+	Range    TimeRange
+	Bucket   Bucket
+	Location *time.Location
+	GroupBy  string
+	Levels   []string // lower-case levels to count; empty counts all
+	FleetID  int
+	DriverID int
+	DeviceID string
+}
+
+// AnalyticsRow is the sample count of one group within one bucket
+type AnalyticsRow struct {
+	Group  string
+	Label  string
+	Bucket time.Time // bucket start in the query location
+	Count  int
+}
+
+// AnalyticsRepository aggregates drowsiness samples into time series
+type AnalyticsRepository interface {
+	// CountSamples counts live samples along with the hourly rollups of
+	// purged ones, which fall in the bucket holding the start of their hour.
+	// It returns only non-empty buckets; callers zero-fill.
+	CountSamples(ctx context.Context, q AnalyticsQuery) ([]AnalyticsRow, error)
+}
@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"driver-drowsiness-backend/models"
+)
+
+// CommandFilter selects commands; zero fields match everything
+type CommandFilter struct {
+	DeviceID string
+	Status   string
+	Range    TimeRange // on created_at
+	Limit    int
+}
+
+// CommandRepository stores the command queue of devices and its audit trail.
+// Every status change is recorded as a CommandEvent in the same transaction.
+type CommandRepository interface {
+	Create(ctx context.Context, cmd *models.DeviceCommand, actor string) error
+	GetByID(ctx context.Context, id int) (*models.DeviceCommand, error)
+	// List returns matching commands, newest first
+	List(ctx context.Context, f CommandFilter) ([]models.DeviceCommand, error)
+	// Deliver marks the unexpired queued commands of a device, and those
+	// delivered before redeliverBefore without acknowledgement, as delivered
+	// at now and returns them oldest first
+	Deliver(ctx context.Context, deviceID string, now, redeliverBefore time.Time) ([]models.DeviceCommand, error)
+	// Transition moves a command in one of the from statuses to status and
+	// returns it; ErrConflict if it is in another status
+	Transition(ctx context.Context, id int, from []string, status, result, actor string, at time.Time) (*models.DeviceCommand, error)
+	// Expire marks unacknowledged commands past their expiry as expired
+	Expire(ctx context.Context, now time.Time) (int, error)
+	// Events returns the audit trail of a command, oldest first
+	Events(ctx context.Context, commandID int) ([]models.CommandEvent, error)
+}
@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"driver-drowsiness-backend/models"
+)
+
+// DrivingFilter selects attributed samples; zero IDs match everything
+type DrivingFilter struct {
+	Range    TimeRange
+	DriverID int
+	FleetID  int
+}
+
+// DrivingSample is a sample attributed to the driver on shift or the device owner
+type DrivingSample struct {
+	DriverID  int
+	DeviceID  string
+	Timestamp time.Time
+}
+
+// ComplianceRepository reads when drivers were driving
+type ComplianceRepository interface {
+	// DrivingSamples returns samples with a known driver, ordered by driver then time
+	DrivingSamples(ctx context.Context, f DrivingFilter) ([]DrivingSample, error)
+	// ArchiveDays saves per-day driving time, replacing days already archived
+	ArchiveDays(ctx context.Context, days []models.ComplianceArchiveDay) error
+	// ArchivedDays returns archived days starting in f.Range, ordered by driver then day
+	ArchivedDays(ctx context.Context, f DrivingFilter) ([]models.ComplianceArchiveDay, error)
+	// State returns the saved live driving time of a driver, or ErrNotFound
+	State(ctx context.Context, driverID int) (*models.ComplianceState, error)
+	// SaveState saves the live driving time of a driver, replacing the previous one
+	SaveState(ctx context.Context, st models.ComplianceState) error
+}
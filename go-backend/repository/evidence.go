@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"driver-drowsiness-backend/models"
+)
+
+// EvidenceFilter selects evidence; zero fields match everything
+type EvidenceFilter struct {
+	AlertID  int
+	DeviceID string
+	Range    TimeRange // on the alert time
+	Limit    int
+}
+
+// EvidenceRepository stores alert evidence and uploads in progress
+type EvidenceRepository interface {
+	Create(ctx context.Context, e *models.Evidence) error
+	GetByID(ctx context.Context, id int) (*models.Evidence, error)
+	// List returns matching evidence, newest alert first
+	List(ctx context.Context, f EvidenceFilter) ([]models.Evidence, error)
+	Delete(ctx context.Context, id int) error
+	// Expired returns up to limit evidence past its retention at now
+	Expired(ctx context.Context, now time.Time, limit int) ([]models.Evidence, error)
+
+	CreateUpload(ctx context.Context, u *models.EvidenceUpload) error
+	GetUpload(ctx context.Context, id string) (*models.EvidenceUpload, error)
+	// AdvanceUpload moves the received offset of an upload from from to to;
+	// ErrConflict if the upload is not at from
+	AdvanceUpload(ctx context.Context, id string, from, to int64, at time.Time) error
+	// ClaimUpload locks an upload for one chunk until until, so chunks sent
+	// at once, to any replica, are written one at a time; ErrConflict while
+	// another chunk holds it and ErrNotFound without the upload
+	ClaimUpload(ctx context.Context, id string, now, until time.Time) error
+	// ReleaseUpload frees an upload claimed until until; a claim that
+	// expired and was taken by another chunk is left alone
+	ReleaseUpload(ctx context.Context, id string, until time.Time) error
+	DeleteUpload(ctx context.Context, id string) error
+	// StaleUploads returns uploads not advanced since before
+	StaleUploads(ctx context.Context, before time.Time) ([]models.EvidenceUpload, error)
+}
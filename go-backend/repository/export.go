@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"context"
+
+	"driver-drowsiness-backend/models"
+)
+
+// ExportFilter selects the rows of an export or report; zero fields match everything.
+// Fleet and driver match the driver on shift, else the device owner.
+type ExportFilter struct {
+	Range    TimeRange
+	FleetID  int
+	DriverID int
+	DeviceID string
+}
+
+// SampleExportRow is a drowsiness sample with the names an export shows
+type SampleExportRow struct {
+	models.DrowsinessData
+	DriverName   string
+	FleetName    string
+	VehiclePlate string
+}
+
+// AlertExportRow is an alert with the names an export shows
+type AlertExportRow struct {
+	models.Alert
+	DriverName   string
+	FleetName    string
+	VehiclePlate string
+}
+
+// ExportRepository streams rows for file exports, oldest first.
+// fn is called once per row as it is read; an error from fn stops the scan.
+type ExportRepository interface {
+	EachSample(ctx context.Context, f ExportFilter, fn func(*SampleExportRow) error) error
+	EachAlert(ctx context.Context, f ExportFilter, fn func(*AlertExportRow) error) error
+}
@@ -0,0 +1,20 @@
+package repository
+
+import (
+	"context"
+
+	"driver-drowsiness-backend/models"
+)
+
+// FaceRepository stores the reference face embeddings drivers enrolled
+type FaceRepository interface {
+	Enroll(ctx context.Context, e *models.FaceEmbedding) error
+	// List returns the embeddings of a user, or of every user when userID is 0
+	List(ctx context.Context, userID int) ([]models.FaceEmbedding, error)
+	// Candidates returns every user's embeddings of a model with the given
+	// number of dimensions, the only ones a probe can be compared with
+	Candidates(ctx context.Context, model string, dimensions int) ([]models.FaceEmbedding, error)
+	// Delete removes one embedding of a user; ErrNotFound if it is not theirs
+	Delete(ctx context.Context, userID, id int) error
+	DeleteAll(ctx context.Context, userID int) error
+}
@@ -0,0 +1,27 @@
+package repository
+
+import (
+	"context"
+
+	"driver-drowsiness-backend/models"
+)
+
+// GeoFilter selects located alerts; zero fields match everything
+type GeoFilter struct {
+	Range    TimeRange
+	FleetID  int
+	DriverID int
+	DeviceID string
+}
+
+// LocatedAlert is an alert with a location and the driver owning its device
+type LocatedAlert struct {
+	models.Alert
+	DriverID int
+}
+
+// GeoRepository serves location queries for maps
+type GeoRepository interface {
+	// Alerts returns located alerts within f.Range, oldest first
+	Alerts(ctx context.Context, f GeoFilter) ([]LocatedAlert, error)
+}
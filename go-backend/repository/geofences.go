@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"context"
+
+	"driver-drowsiness-backend/models"
+)
+
+// GeofenceEventFilter selects enter/exit events; zero fields match everything
+type GeofenceEventFilter struct {
+	DeviceID   string
+	GeofenceID int
+	Range      TimeRange
+	Limit      int
+}
+
+// GeofenceRepository persists geofences with their rules, and enter/exit events
+type GeofenceRepository interface {
+	List(ctx context.Context) ([]models.Geofence, error)
+	GetByID(ctx context.Context, id int) (*models.Geofence, error)
+	Create(ctx context.Context, g *models.Geofence) error
+	// Update replaces name, kind, polygon and rules
+	Update(ctx context.Context, g *models.Geofence) error
+	// Delete removes a geofence and its events
+	Delete(ctx context.Context, id int) error
+
+	InsertEvent(ctx context.Context, e *models.GeofenceEvent) error
+	// Inside returns the geofences whose latest event for the device is "enter"
+	Inside(ctx context.Context, deviceID string) ([]int, error)
+	// Events returns matching events newest first
+	Events(ctx context.Context, f GeofenceEventFilter) ([]models.GeofenceEvent, error)
+}
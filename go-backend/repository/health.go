@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"driver-drowsiness-backend/models"
+)
+
+// DeviceEventFilter selects device state transitions; zero fields match everything
+type DeviceEventFilter struct {
+	DeviceID string
+	Range    TimeRange
+	Limit    int
+}
+
+// HealthRepository stores device health and state transitions
+type HealthRepository interface {
+	Get(ctx context.Context, deviceID string) (*models.DeviceHealth, error)
+	// Save upserts the health of a device and records the transition, if any
+	Save(ctx context.Context, h *models.DeviceHealth, event *models.DeviceStateEvent) error
+	// Stale returns devices not offline whose last heartbeat is before cutoff
+	Stale(ctx context.Context, cutoff time.Time) ([]models.DeviceHealth, error)
+	// Events returns matching transitions, oldest first
+	Events(ctx context.Context, f DeviceEventFilter) ([]models.DeviceStateEvent, error)
+	// LastEventBefore returns the latest transition of a device before t
+	LastEventBefore(ctx context.Context, deviceID string, t time.Time) (*models.DeviceStateEvent, error)
+}
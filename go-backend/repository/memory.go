@@ -0,0 +1,693 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"driver-drowsiness-backend/models"
+)
+
+// memoryDB holds every table of the in-memory store behind one lock,
+// so cross-table aggregates see a consistent snapshot.
+type memoryDB struct {
+	mu sync.RWMutex
+
+	users          []models.User
+	devices        map[string]*memDevice
+	drowsiness     []models.DrowsinessData
+	alerts         []models.Alert
+	resets         map[int]memReset
+	fleets         []models.Fleet
+	sessions       []models.DrivingSession
+	geofences      []models.Geofence
+	geofenceEvents []models.GeofenceEvent
+	vehicles       []models.Vehicle
+	installations  []models.DeviceInstallation
+	shifts         []models.Shift
+	health         map[string]models.DeviceHealth
+	stateEvents    []models.DeviceStateEvent
+	shadows        map[string]models.DeviceShadow
+	commands       []models.DeviceCommand
+	commandEvents  []models.CommandEvent
+	evidence       []models.Evidence
+	uploads        map[string]models.EvidenceUpload
+	faces          []models.FaceEmbedding
+	reports        []models.Report
+	complianceDays []models.ComplianceArchiveDay
+	complianceLive map[int]models.ComplianceState
+	subscriptions  []models.Subscription
+	deliveries     []models.Delivery
+	hourly         []memHourly
+
+	seq int
+}
+
+type memDevice struct {
+	models.Device
+	UserID int
+}
+
+type memReset struct {
+	Code      string
+	ExpiresAt time.Time
+	Used      bool
+}
+
+// NewMemoryStore returns repositories backed by process memory.
+// It is meant for tests and local experiments; nothing is persisted.
+func NewMemoryStore() *Store {
+	m := &memoryDB{
+		devices:        make(map[string]*memDevice),
+		resets:         make(map[int]memReset),
+		health:         make(map[string]models.DeviceHealth),
+		shadows:        make(map[string]models.DeviceShadow),
+		uploads:        make(map[string]models.EvidenceUpload),
+		complianceLive: make(map[int]models.ComplianceState),
+	}
+	return &Store{
+		Users:          &memUsers{m},
+		Devices:        &memDevices{m},
+		Drowsiness:     &memDrowsiness{m},
+		Alerts:         &memAlerts{m},
+		PasswordResets: &memPasswordResets{m},
+		Fleets:         &memFleets{m},
+		Dashboard:      &memDashboard{m},
+		Analytics:      &memAnalytics{m},
+		Sessions:       &memSessions{m},
+		Geo:            &memGeo{m},
+		Geofences:      &memGeofences{m},
+		Vehicles:       &memVehicles{m},
+		Shifts:         &memShifts{m},
+		Compliance:     &memCompliance{m},
+		Health:         &memHealth{m},
+		Shadows:        &memShadows{m},
+		Commands:       &memCommands{m},
+		Evidence:       &memEvidence{m},
+		Faces:          &memFaces{m},
+		Exports:        &memExports{m},
+		Reports:        &memReports{m},
+		Subscriptions:  &memSubscriptions{m},
+		Retention:      &memRetention{m},
+	}
+}
+
+func (m *memoryDB) nextID() int {
+	m.seq++
+	return m.seq
+}
+
+func (m *memoryDB) userByID(id int) *models.User {
+	for i := range m.users {
+		if m.users[i].ID == id {
+			return &m.users[i]
+		}
+	}
+	return nil
+}
+
+func (m *memoryDB) userByEmail(email string) *models.User {
+	for i := range m.users {
+		if m.users[i].Email == email {
+			return &m.users[i]
+		}
+	}
+	return nil
+}
+
+// memUserView is a user joined with its fleet, as the SQL joins see it
+type memUserView struct {
+	models.User
+	DisplayName string
+	FleetName   string
+}
+
+func (m *memoryDB) userView(id int) *memUserView {
+	u := m.userByID(id)
+	if u == nil {
+		return nil
+	}
+	v := &memUserView{User: *u, DisplayName: u.Name}
+	if v.DisplayName == "" {
+		v.DisplayName = u.Email
+	}
+	for _, f := range m.fleets {
+		if f.ID == u.FleetID {
+			v.FleetName = f.Name
+		}
+	}
+	return v
+}
+
+// primaryDevice mirrors pgDevices.PrimaryForUser
+func (m *memoryDB) primaryDevice(userID int) *memDevice {
+	var best *memDevice
+	for _, d := range m.devices {
+		if d.UserID != userID {
+			continue
+		}
+		if best == nil || d.CreatedAt.After(best.CreatedAt) {
+			best = d
+		}
+	}
+	return best
+}
+
+// deviceOnline mirrors deviceOnlineSQL
+func (m *memoryDB) deviceOnline(d *memDevice, now time.Time) bool {
+	if h, ok := m.health[d.ID]; ok {
+		return h.State != models.DeviceOffline
+	}
+	return !d.LastUpdate.Before(now.Add(-time.Minute))
+}
+
+// sampleDriver mirrors COALESCE(driver_id, d.user_id): the driver recorded
+// at ingestion, else the device owner
+func (m *memoryDB) sampleDriver(deviceID string, driverID int) int {
+	if driverID != 0 {
+		return driverID
+	}
+	if dev, ok := m.devices[deviceID]; ok {
+		return dev.UserID
+	}
+	return 0
+}
+
+// ================== USERS ==================
+
+type memUsers struct{ *memoryDB }
+
+func (r *memUsers) Create(_ context.Context, u *models.User) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.userByEmail(u.Email) != nil {
+		return 0, ErrConflict
+	}
+	nu := *u
+	nu.ID = r.nextID()
+	if nu.Role == "" {
+		nu.Role = "driver"
+	}
+	nu.CreatedAt = time.Now().UTC()
+	r.users = append(r.users, nu)
+	return nu.ID, nil
+}
+
+func (r *memUsers) GetByEmail(_ context.Context, email string) (*models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if u := r.userByEmail(email); u != nil {
+		cp := *u
+		return &cp, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memUsers) GetByID(_ context.Context, id int) (*models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if u := r.userByID(id); u != nil {
+		cp := *u
+		return &cp, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memUsers) UpdatePassword(_ context.Context, id int, passwordHash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if u := r.userByID(id); u != nil {
+		u.PasswordHash = passwordHash
+	}
+	return nil
+}
+
+// ================== DEVICES ==================
+
+type memDevices struct{ *memoryDB }
+
+func (r *memDevices) List(_ context.Context) ([]models.Device, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var devices []models.Device
+	for _, d := range r.devices {
+		dev := d.Device
+		dev.UserID = d.UserID
+		devices = append(devices, dev)
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].LastUpdate.After(devices[j].LastUpdate) })
+	return devices, nil
+}
+
+func (r *memDevices) Get(_ context.Context, deviceID string) (*models.Device, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	d, ok := r.devices[deviceID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	dev := d.Device
+	dev.UserID = d.UserID
+	return &dev, nil
+}
+
+func (r *memDevices) Touch(_ context.Context, deviceID, driverEmail string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if d, ok := r.devices[deviceID]; ok {
+		d.LastUpdate = at
+		return nil
+	}
+	r.devices[deviceID] = &memDevice{Device: models.Device{
+		ID: deviceID, DriverEmail: driverEmail, Status: "active", LastUpdate: at, CreatedAt: at,
+	}}
+	return nil
+}
+
+func (r *memDevices) AssignToUser(_ context.Context, deviceID, email string, userID int) error {
+	if deviceID == "" {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if d, ok := r.devices[deviceID]; ok {
+		d.DriverEmail = email
+		d.UserID = userID
+		return nil
+	}
+	now := time.Now().UTC()
+	r.devices[deviceID] = &memDevice{
+		Device: models.Device{ID: deviceID, DriverEmail: email, Status: "active", LastUpdate: now, CreatedAt: now},
+		UserID: userID,
+	}
+	return nil
+}
+
+func (r *memDevices) PrimaryForUser(_ context.Context, userID int) (string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if d := r.primaryDevice(userID); d != nil {
+		return d.ID, nil
+	}
+	return "", ErrNotFound
+}
+
+// ================== DROWSINESS DATA ==================
+
+type memDrowsiness struct{ *memoryDB }
+
+func (r *memDrowsiness) Insert(_ context.Context, d *models.DrowsinessData) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	d.ID = r.nextID()
+	d.CreatedAt = time.Now().UTC()
+	if d.SchemaVersion == 0 {
+		d.SchemaVersion = models.TelemetrySchemaV1
+	}
+	r.drowsiness = append(r.drowsiness, *d)
+	return nil
+}
+
+// newestFirst orders samples by timestamp then id, both descending
+func newestFirst(data []models.DrowsinessData) {
+	sort.SliceStable(data, func(i, j int) bool {
+		if !data[i].Timestamp.Equal(data[j].Timestamp) {
+			return data[i].Timestamp.After(data[j].Timestamp)
+		}
+		return data[i].ID > data[j].ID
+	})
+}
+
+func (r *memDrowsiness) byDevice(deviceID string) []models.DrowsinessData {
+	var out []models.DrowsinessData
+	for _, d := range r.drowsiness {
+		if d.DeviceID == deviceID {
+			out = append(out, d)
+		}
+	}
+	newestFirst(out)
+	return out
+}
+
+func (r *memDrowsiness) Latest(_ context.Context, deviceID string) (*models.DrowsinessData, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	data := r.byDevice(deviceID)
+	if len(data) == 0 {
+		return nil, ErrNotFound
+	}
+	return &data[0], nil
+}
+
+func (r *memDrowsiness) History(_ context.Context, f PageFilter) ([]models.DrowsinessData, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var data []models.DrowsinessData
+	for _, d := range r.byDevice(f.DeviceID) {
+		if !f.match(d.Timestamp, d.ID) || (f.Level != "" && !strings.EqualFold(d.DrowsinessLevel, f.Level)) ||
+			(f.Status != "" && d.Status != f.Status) {
+			continue
+		}
+		data = append(data, d)
+	}
+	lo, hi := f.window(len(data))
+	return data[lo:hi], nil
+}
+
+func (r *memDrowsiness) Between(_ context.Context, deviceID string, tr TimeRange) ([]models.DrowsinessData, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	data := r.byDevice(deviceID)
+	var out []models.DrowsinessData
+	for i := len(data) - 1; i >= 0; i-- {
+		if tr.Contains(data[i].Timestamp) {
+			out = append(out, data[i])
+		}
+	}
+	return out, nil
+}
+
+// ================== ALERTS ==================
+
+func (r *memDrowsiness) LastLocation(_ context.Context, deviceID string, since time.Time) (*models.GPSFix, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var latest *models.DrowsinessData
+	for i := range r.drowsiness {
+		d := &r.drowsiness[i]
+		if d.DeviceID != deviceID || d.Timestamp.Before(since) || d.Telemetry == nil || d.Telemetry.GPS == nil {
+			continue
+		}
+		if latest == nil || !d.Timestamp.Before(latest.Timestamp) {
+			latest = d
+		}
+	}
+	if latest == nil {
+		return nil, ErrNotFound
+	}
+	fix := *latest.Telemetry.GPS
+	return &fix, nil
+}
+
+type memAlerts struct{ *memoryDB }
+
+func (r *memAlerts) Insert(_ context.Context, a *models.Alert) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if a.Status == "" {
+		a.Status = "active"
+	}
+	a.ID = r.nextID()
+	a.CreatedAt = time.Now().UTC()
+	a.VehicleID = r.vehicleAt(a.DeviceID, a.Timestamp)
+	r.alerts = append(r.alerts, *a)
+	return nil
+}
+
+func (r *memAlerts) GetByID(_ context.Context, id int) (*models.Alert, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, a := range r.alerts {
+		if a.ID == id {
+			return &a, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memAlerts) ListByDevice(_ context.Context, f PageFilter) ([]models.Alert, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var alerts []models.Alert
+	for _, a := range r.alerts {
+		if a.DeviceID != f.DeviceID || !f.match(a.Timestamp, a.ID) ||
+			(f.Severity != "" && a.Severity != f.Severity) || (f.Status != "" && a.Status != f.Status) {
+			continue
+		}
+		alerts = append(alerts, a)
+	}
+	sort.SliceStable(alerts, func(i, j int) bool {
+		if !alerts[i].Timestamp.Equal(alerts[j].Timestamp) {
+			return alerts[i].Timestamp.After(alerts[j].Timestamp)
+		}
+		return alerts[i].ID > alerts[j].ID
+	})
+	lo, hi := f.window(len(alerts))
+	return alerts[lo:hi], nil
+}
+
+func (r *memAlerts) Between(_ context.Context, deviceID string, tr TimeRange) ([]models.Alert, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var alerts []models.Alert
+	for _, a := range r.alerts {
+		if a.DeviceID == deviceID && tr.Contains(a.Timestamp) {
+			alerts = append(alerts, a)
+		}
+	}
+	sort.SliceStable(alerts, func(i, j int) bool { return alerts[i].Timestamp.Before(alerts[j].Timestamp) })
+	return alerts, nil
+}
+
+// ================== PASSWORD RESETS ==================
+
+type memPasswordResets struct{ *memoryDB }
+
+func (r *memPasswordResets) Store(_ context.Context, userID int, code string, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.resets[userID] = memReset{Code: code, ExpiresAt: expiresAt}
+	return nil
+}
+
+func (r *memPasswordResets) Validate(_ context.Context, email, code string, now time.Time) (*models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	u := r.userByEmail(email)
+	if u == nil {
+		return nil, ErrNotFound
+	}
+	reset, ok := r.resets[u.ID]
+	if !ok || reset.Used || reset.Code != code || !reset.ExpiresAt.After(now) {
+		return nil, ErrNotFound
+	}
+	cp := *u
+	return &cp, nil
+}
+
+func (r *memPasswordResets) MarkUsed(_ context.Context, userID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if reset, ok := r.resets[userID]; ok {
+		reset.Used = true
+		r.resets[userID] = reset
+	}
+	return nil
+}
+
+// ================== FLEETS ==================
+
+type memFleets struct{ *memoryDB }
+
+func (r *memFleets) Create(_ context.Context, f *models.Fleet) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f.ID = r.nextID()
+	f.CreatedAt = time.Now().UTC()
+	r.fleets = append(r.fleets, *f)
+	return nil
+}
+
+func (r *memFleets) GetByID(_ context.Context, id int) (*models.Fleet, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, f := range r.fleets {
+		if f.ID == id {
+			return &f, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memFleets) List(_ context.Context) ([]models.Fleet, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]models.Fleet(nil), r.fleets...), nil
+}
+
+func (r *memFleets) Update(_ context.Context, f *models.Fleet) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.fleets {
+		if r.fleets[i].ID == f.ID {
+			r.fleets[i].Name = f.Name
+			r.fleets[i].Timezone = f.Timezone
+			*f = r.fleets[i]
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// ================== DASHBOARD ==================
+
+type memDashboard struct{ *memoryDB }
+
+func (r *memDashboard) Overview(_ context.Context, now time.Time, day TimeRange) (models.AdminOverview, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var o models.AdminOverview
+	for _, u := range r.users {
+		if u.Role == "driver" {
+			o.TotalDrivers++
+		}
+	}
+	active := make(map[int]bool)
+	for _, d := range r.devices {
+		if d.UserID != 0 && r.deviceOnline(d, now) {
+			active[d.UserID] = true
+		}
+	}
+	o.ActiveDrivers = len(active)
+	o.TotalDevices = len(r.devices)
+	for _, d := range r.drowsiness {
+		if day.Contains(d.Timestamp) && strings.EqualFold(d.DrowsinessLevel, "high") {
+			o.AlertsToday++
+		}
+	}
+	o.CriticalAlertsToday = o.AlertsToday
+	return o, nil
+}
+
+func (r *memDashboard) OnlineDevices(_ context.Context, now time.Time) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	n := 0
+	for _, d := range r.devices {
+		if r.deviceOnline(d, now) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (r *memDashboard) DriverSummaries(_ context.Context, now time.Time, day TimeRange) ([]models.AdminDriverSummary, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	cutoff := now.Add(-time.Minute)
+	var results []models.AdminDriverSummary
+	for _, u := range r.users {
+		if u.Role != "driver" {
+			continue
+		}
+		s := models.AdminDriverSummary{ID: strconv.Itoa(u.ID), Name: u.Name, Source: "real"}
+		if s.Name == "" {
+			s.Name = u.Email
+		}
+		// The device of the driver's current shift, else the newest owned device
+		dev := r.primaryDevice(u.ID)
+		if shift := r.shiftAt(now, func(sh models.Shift) bool { return sh.DriverID == u.ID }); shift != nil {
+			if d, ok := r.devices[shift.DeviceID]; ok {
+				dev = d
+			}
+		}
+		heartbeat := false
+		if dev != nil {
+			s.DeviceID = dev.ID
+			s.IsOnline = r.deviceOnline(dev, now)
+			if h, ok := r.health[dev.ID]; ok {
+				s.DeviceState, heartbeat = h.State, true
+			}
+		}
+		for _, d := range r.drowsiness {
+			if !day.Contains(d.Timestamp) {
+				continue
+			}
+			if dev != nil && !heartbeat && d.DeviceID == dev.ID && !d.Timestamp.Before(cutoff) {
+				s.IsOnline = true
+			}
+			if _, ok := r.devices[d.DeviceID]; !ok || r.sampleDriver(d.DeviceID, d.DriverID) != u.ID {
+				continue
+			}
+			if strings.EqualFold(d.DrowsinessLevel, "high") {
+				s.CriticalAlertsToday++
+			}
+		}
+		results = append(results, s)
+	}
+	return results, nil
+}
+
+func (r *memDashboard) RecentAlerts(_ context.Context, limit int) ([]RecentAlertRow, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	data := make([]models.DrowsinessData, len(r.drowsiness))
+	copy(data, r.drowsiness)
+	newestFirst(data)
+
+	var results []RecentAlertRow
+	for _, d := range data {
+		if len(results) >= limit {
+			break
+		}
+		level := strings.ToLower(d.DrowsinessLevel)
+		if level != "medium" && level != "high" {
+			continue
+		}
+		if _, ok := r.devices[d.DeviceID]; !ok {
+			continue
+		}
+		u := r.userByID(r.sampleDriver(d.DeviceID, d.DriverID))
+		if u == nil || u.Role != "driver" {
+			continue
+		}
+		name := u.Name
+		if name == "" {
+			name = u.Email
+		}
+		row := RecentAlertRow{Timestamp: d.Timestamp, Driver: name, Level: level, DeviceID: d.DeviceID}
+		if v := r.vehicleByID(r.vehicleAt(d.DeviceID, d.Timestamp)); v != nil {
+			row.VehiclePlate = v.PlateNumber
+		}
+		results = append(results, row)
+	}
+	return results, nil
+}
+
+func (r *memDashboard) HighCountsByHour(_ context.Context, day TimeRange, loc *time.Location) (map[int]int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	counts := make(map[int]int)
+	for _, d := range r.drowsiness {
+		if day.Contains(d.Timestamp) && strings.EqualFold(d.DrowsinessLevel, "high") {
+			counts[d.Timestamp.In(loc).Hour()]++
+		}
+	}
+	return counts, nil
+}
+
+func (r *memDashboard) LevelCounts(_ context.Context, day TimeRange) (high, medium int, err error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, d := range r.drowsiness {
+		if !day.Contains(d.Timestamp) {
+			continue
+		}
+		if _, ok := r.devices[d.DeviceID]; !ok {
+			continue
+		}
+		if u := r.userByID(r.sampleDriver(d.DeviceID, d.DriverID)); u == nil || u.Role != "driver" {
+			continue
+		}
+		switch strings.ToLower(d.DrowsinessLevel) {
+		case "high":
+			high++
+		case "medium":
+			medium++
+		}
+	}
+	return high, medium, nil
+}
@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type memAnalytics struct{ *memoryDB }
+
+func (r *memAnalytics) CountSamples(_ context.Context, q AnalyticsQuery) ([]AnalyticsRow, error) {
+	if !q.Bucket.Valid() {
+		return nil, fmt.Errorf("analytics: unsupported bucket %q", q.Bucket)
+	}
+	if !ValidGroup(q.GroupBy) {
+		return nil, fmt.Errorf("analytics: unsupported grouping %q", q.GroupBy)
+	}
+	levels := make(map[string]bool, len(q.Levels))
+	for _, l := range q.Levels {
+		levels[l] = true
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	type key struct {
+		group  string
+		bucket int64
+	}
+	counts := make(map[key]*AnalyticsRow)
+	add := func(ts time.Time, deviceID string, driverID int, level string, n int) {
+		if !q.Range.Contains(ts) || n == 0 {
+			return
+		}
+		if len(levels) > 0 && !levels[level] {
+			return
+		}
+		u := r.userView(driverID)
+		if q.FleetID != 0 && (u == nil || u.FleetID != q.FleetID) {
+			return
+		}
+		if q.DriverID != 0 && (u == nil || u.ID != q.DriverID) {
+			return
+		}
+		if q.DeviceID != "" && deviceID != q.DeviceID {
+			return
+		}
+
+		var group, label string
+		switch q.GroupBy {
+		case GroupFleet:
+			if u != nil && u.FleetID != 0 {
+				group, label = strconv.Itoa(u.FleetID), u.FleetName
+			}
+		case GroupDriver:
+			if u != nil {
+				group, label = strconv.Itoa(u.ID), u.DisplayName
+			}
+		case GroupDevice:
+			group, label = deviceID, deviceID
+		case GroupVehicle:
+			if v := r.vehicleByID(r.vehicleAt(deviceID, ts)); v != nil {
+				group, label = strconv.Itoa(v.ID), v.PlateNumber
+			}
+		}
+
+		start := q.Bucket.Start(ts, q.Location)
+		k := key{group, start.Unix()}
+		if row, ok := counts[k]; ok {
+			row.Count += n
+			return
+		}
+		counts[k] = &AnalyticsRow{Group: group, Label: label, Bucket: start, Count: n}
+	}
+	for _, d := range r.drowsiness {
+		add(d.Timestamp, d.DeviceID, r.sampleDriver(d.DeviceID, d.DriverID), strings.ToLower(d.DrowsinessLevel), 1)
+	}
+	// Samples the purge rolled up, each taken at the start of its hour
+	for _, h := range r.hourly {
+		add(h.Hour, h.DeviceID, h.DriverID, "high", h.High)
+		add(h.Hour, h.DeviceID, h.DriverID, "medium", h.Medium)
+		add(h.Hour, h.DeviceID, h.DriverID, "low", h.Low)
+	}
+
+	results := make([]AnalyticsRow, 0, len(counts))
+	for _, row := range counts {
+		results = append(results, *row)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Group != results[j].Group {
+			return results[i].Group < results[j].Group
+		}
+		return results[i].Bucket.Before(results[j].Bucket)
+	})
+	return results, nil
+}
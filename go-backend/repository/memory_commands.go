@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"driver-drowsiness-backend/models"
+)
+
+type memCommands struct{ *memoryDB }
+
+func (r *memCommands) recordEvent(commandID int, status, actor, note string, at time.Time) {
+	r.commandEvents = append(r.commandEvents, models.CommandEvent{
+		ID: r.nextID(), CommandID: commandID, Status: status, Actor: actor, Note: note, At: at.UTC(),
+	})
+}
+
+func (r *memCommands) commandByID(id int) *models.DeviceCommand {
+	for i := range r.commands {
+		if r.commands[i].ID == id {
+			return &r.commands[i]
+		}
+	}
+	return nil
+}
+
+func (r *memCommands) Create(_ context.Context, cmd *models.DeviceCommand, actor string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cmd.ID = r.nextID()
+	cmd.Status = models.CommandQueued
+	cmd.CreatedAt, cmd.ExpiresAt = cmd.CreatedAt.UTC(), cmd.ExpiresAt.UTC()
+	r.commands = append(r.commands, *cmd)
+	r.recordEvent(cmd.ID, cmd.Status, actor, "", cmd.CreatedAt)
+	return nil
+}
+
+func (r *memCommands) GetByID(_ context.Context, id int) (*models.DeviceCommand, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	c := r.commandByID(id)
+	if c == nil {
+		return nil, ErrNotFound
+	}
+	found := *c
+	return &found, nil
+}
+
+func (r *memCommands) List(_ context.Context, f CommandFilter) ([]models.DeviceCommand, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var commands []models.DeviceCommand
+	for _, c := range r.commands {
+		if (f.DeviceID != "" && c.DeviceID != f.DeviceID) || (f.Status != "" && c.Status != f.Status) {
+			continue
+		}
+		if (!f.Range.From.IsZero() && c.CreatedAt.Before(f.Range.From)) || (!f.Range.To.IsZero() && !c.CreatedAt.Before(f.Range.To)) {
+			continue
+		}
+		commands = append(commands, c)
+	}
+	sort.SliceStable(commands, func(i, j int) bool {
+		if !commands[i].CreatedAt.Equal(commands[j].CreatedAt) {
+			return commands[i].CreatedAt.After(commands[j].CreatedAt)
+		}
+		return commands[i].ID > commands[j].ID
+	})
+	if f.Limit > 0 && len(commands) > f.Limit {
+		commands = commands[:f.Limit]
+	}
+	return commands, nil
+}
+
+func (r *memCommands) Deliver(_ context.Context, deviceID string, now, redeliverBefore time.Time) ([]models.DeviceCommand, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now = now.UTC()
+	var commands []models.DeviceCommand
+	for i := range r.commands {
+		c := &r.commands[i]
+		if c.DeviceID != deviceID || !c.ExpiresAt.After(now) {
+			continue
+		}
+		redeliver := c.Status == models.CommandDelivered && c.DeliveredAt.Before(redeliverBefore)
+		if c.Status != models.CommandQueued && !redeliver {
+			continue
+		}
+		delivered := now
+		c.Status, c.DeliveredAt = models.CommandDelivered, &delivered
+		c.Attempts++
+		r.recordEvent(c.ID, c.Status, "device", fmt.Sprintf("attempt %d", c.Attempts), now)
+		commands = append(commands, *c)
+	}
+	return commands, nil
+}
+
+func (r *memCommands) Transition(_ context.Context, id int, from []string, status, result, actor string, at time.Time) (*models.DeviceCommand, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c := r.commandByID(id)
+	if c == nil {
+		return nil, ErrNotFound
+	}
+	allowed := false
+	for _, s := range from {
+		allowed = allowed || s == c.Status
+	}
+	if !allowed {
+		return nil, ErrConflict
+	}
+	completed := at.UTC()
+	c.Status, c.Result, c.CompletedAt = status, result, &completed
+	r.recordEvent(id, status, actor, result, at)
+	found := *c
+	return &found, nil
+}
+
+func (r *memCommands) Expire(_ context.Context, now time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for i := range r.commands {
+		c := &r.commands[i]
+		if (c.Status != models.CommandQueued && c.Status != models.CommandDelivered) || c.ExpiresAt.After(now) {
+			continue
+		}
+		expired := c.ExpiresAt
+		c.Status, c.CompletedAt = models.CommandExpired, &expired
+		r.recordEvent(c.ID, c.Status, "system", "", expired)
+		n++
+	}
+	return n, nil
+}
+
+func (r *memCommands) Events(_ context.Context, commandID int) ([]models.CommandEvent, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var events []models.CommandEvent
+	for _, e := range r.commandEvents {
+		if e.CommandID == commandID {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"sort"
+
+	"driver-drowsiness-backend/models"
+)
+
+type memCompliance struct{ *memoryDB }
+
+func (r *memCompliance) DrivingSamples(_ context.Context, f DrivingFilter) ([]DrivingSample, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var samples []DrivingSample
+	for _, d := range r.drowsiness {
+		if !f.Range.Contains(d.Timestamp) {
+			continue
+		}
+		u := r.userByID(r.sampleDriver(d.DeviceID, d.DriverID))
+		if u == nil || (f.DriverID != 0 && u.ID != f.DriverID) || (f.FleetID != 0 && u.FleetID != f.FleetID) {
+			continue
+		}
+		samples = append(samples, DrivingSample{DriverID: u.ID, DeviceID: d.DeviceID, Timestamp: d.Timestamp})
+	}
+	sort.SliceStable(samples, func(i, j int) bool {
+		if samples[i].DriverID != samples[j].DriverID {
+			return samples[i].DriverID < samples[j].DriverID
+		}
+		return samples[i].Timestamp.Before(samples[j].Timestamp)
+	})
+	return samples, nil
+}
+
+func (r *memCompliance) ArchiveDays(_ context.Context, days []models.ComplianceArchiveDay) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+next:
+	for _, d := range days {
+		for i, stored := range r.complianceDays {
+			if stored.DriverID == d.DriverID && stored.Day.Equal(d.Day) {
+				r.complianceDays[i] = d
+				continue next
+			}
+		}
+		r.complianceDays = append(r.complianceDays, d)
+	}
+	return nil
+}
+
+func (r *memCompliance) ArchivedDays(_ context.Context, f DrivingFilter) ([]models.ComplianceArchiveDay, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var days []models.ComplianceArchiveDay
+	for _, d := range r.complianceDays {
+		if !f.Range.Contains(d.Day) || (f.DriverID != 0 && d.DriverID != f.DriverID) {
+			continue
+		}
+		if u := r.userByID(d.DriverID); f.FleetID != 0 && (u == nil || u.FleetID != f.FleetID) {
+			continue
+		}
+		days = append(days, d)
+	}
+	sort.Slice(days, func(i, j int) bool {
+		if days[i].DriverID != days[j].DriverID {
+			return days[i].DriverID < days[j].DriverID
+		}
+		return days[i].Day.Before(days[j].Day)
+	})
+	return days, nil
+}
+
+func (r *memCompliance) State(_ context.Context, driverID int) (*models.ComplianceState, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	st, ok := r.complianceLive[driverID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	st.Fired = append([]string(nil), st.Fired...)
+	return &st, nil
+}
+
+func (r *memCompliance) SaveState(_ context.Context, st models.ComplianceState) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	st.Fired = append([]string(nil), st.Fired...)
+	r.complianceLive[st.DriverID] = st
+	return nil
+}
@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"driver-drowsiness-backend/models"
+)
+
+type memEvidence struct{ *memoryDB }
+
+func (r *memEvidence) Create(_ context.Context, e *models.Evidence) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e.ID = r.nextID()
+	r.evidence = append(r.evidence, *e)
+	return nil
+}
+
+func (r *memEvidence) GetByID(_ context.Context, id int) (*models.Evidence, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, e := range r.evidence {
+		if e.ID == id {
+			return &e, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memEvidence) List(_ context.Context, f EvidenceFilter) ([]models.Evidence, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var evidence []models.Evidence
+	for _, e := range r.evidence {
+		if (f.AlertID != 0 && e.AlertID != f.AlertID) || (f.DeviceID != "" && e.DeviceID != f.DeviceID) {
+			continue
+		}
+		if (!f.Range.From.IsZero() && e.AlertAt.Before(f.Range.From)) || (!f.Range.To.IsZero() && !e.AlertAt.Before(f.Range.To)) {
+			continue
+		}
+		evidence = append(evidence, e)
+	}
+	sort.SliceStable(evidence, func(i, j int) bool {
+		if !evidence[i].AlertAt.Equal(evidence[j].AlertAt) {
+			return evidence[i].AlertAt.After(evidence[j].AlertAt)
+		}
+		return evidence[i].ID > evidence[j].ID
+	})
+	if f.Limit > 0 && len(evidence) > f.Limit {
+		evidence = evidence[:f.Limit]
+	}
+	return evidence, nil
+}
+
+func (r *memEvidence) Delete(_ context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, e := range r.evidence {
+		if e.ID == id {
+			r.evidence = append(r.evidence[:i], r.evidence[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (r *memEvidence) Expired(_ context.Context, now time.Time, limit int) ([]models.Evidence, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var expired []models.Evidence
+	for _, e := range r.evidence {
+		if !e.ExpiresAt.After(now) {
+			expired = append(expired, e)
+		}
+	}
+	sort.SliceStable(expired, func(i, j int) bool { return expired[i].ExpiresAt.Before(expired[j].ExpiresAt) })
+	if len(expired) > limit {
+		expired = expired[:limit]
+	}
+	return expired, nil
+}
+
+func (r *memEvidence) CreateUpload(_ context.Context, u *models.EvidenceUpload) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.uploads[u.ID]; ok {
+		return ErrConflict
+	}
+	r.uploads[u.ID] = *u
+	return nil
+}
+
+func (r *memEvidence) GetUpload(_ context.Context, id string) (*models.EvidenceUpload, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	u, ok := r.uploads[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &u, nil
+}
+
+func (r *memEvidence) AdvanceUpload(_ context.Context, id string, from, to int64, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.uploads[id]
+	if !ok || u.Received != from {
+		return ErrConflict
+	}
+	u.Received, u.UpdatedAt = to, at.UTC()
+	r.uploads[id] = u
+	return nil
+}
+
+func (r *memEvidence) ClaimUpload(_ context.Context, id string, now, until time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.uploads[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if u.LockedUntil != nil && u.LockedUntil.After(now) {
+		return ErrConflict
+	}
+	until = until.UTC()
+	u.LockedUntil = &until
+	r.uploads[id] = u
+	return nil
+}
+
+func (r *memEvidence) ReleaseUpload(_ context.Context, id string, until time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if u, ok := r.uploads[id]; ok && u.LockedUntil != nil && u.LockedUntil.Equal(until) {
+		u.LockedUntil = nil
+		r.uploads[id] = u
+	}
+	return nil
+}
+
+func (r *memEvidence) DeleteUpload(_ context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.uploads, id)
+	return nil
+}
+
+func (r *memEvidence) StaleUploads(_ context.Context, before time.Time) ([]models.EvidenceUpload, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var stale []models.EvidenceUpload
+	for _, u := range r.uploads {
+		if u.UpdatedAt.Before(before) {
+			stale = append(stale, u)
+		}
+	}
+	return stale, nil
+}
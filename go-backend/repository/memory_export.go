@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"sort"
+)
+
+type memExports struct{ *memoryDB }
+
+// exportNames mirrors the exportNames columns for the driver of a row
+func (m *memoryDB) exportNames(deviceID string, driverID int) (*memUserView, string, string) {
+	u := m.userView(m.sampleDriver(deviceID, driverID))
+	if u == nil {
+		return nil, "", ""
+	}
+	return u, u.DisplayName, u.FleetName
+}
+
+// exportMatch mirrors exportWhere
+func (f ExportFilter) exportMatch(u *memUserView, deviceID string) bool {
+	if f.FleetID != 0 && (u == nil || u.FleetID != f.FleetID) {
+		return false
+	}
+	if f.DriverID != 0 && (u == nil || u.ID != f.DriverID) {
+		return false
+	}
+	return f.DeviceID == "" || deviceID == f.DeviceID
+}
+
+// EachSample copies the matching rows under the lock and calls fn outside it
+func (r *memExports) EachSample(ctx context.Context, f ExportFilter, fn func(*SampleExportRow) error) error {
+	r.mu.RLock()
+	var rows []SampleExportRow
+	for _, d := range r.drowsiness {
+		if !f.Range.Contains(d.Timestamp) {
+			continue
+		}
+		u, driver, fleet := r.exportNames(d.DeviceID, d.DriverID)
+		if !f.exportMatch(u, d.DeviceID) {
+			continue
+		}
+		row := SampleExportRow{DrowsinessData: d, DriverName: driver, FleetName: fleet}
+		if v := r.vehicleByID(r.vehicleAt(d.DeviceID, d.Timestamp)); v != nil {
+			row.VehiclePlate = v.PlateNumber
+		}
+		rows = append(rows, row)
+	}
+	r.mu.RUnlock()
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		if !rows[i].Timestamp.Equal(rows[j].Timestamp) {
+			return rows[i].Timestamp.Before(rows[j].Timestamp)
+		}
+		return rows[i].ID < rows[j].ID
+	})
+	for i := range rows {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(&rows[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *memExports) EachAlert(ctx context.Context, f ExportFilter, fn func(*AlertExportRow) error) error {
+	r.mu.RLock()
+	var rows []AlertExportRow
+	for _, a := range r.alerts {
+		if !f.Range.Contains(a.Timestamp) {
+			continue
+		}
+		u, driver, fleet := r.exportNames(a.DeviceID, a.DriverID)
+		if !f.exportMatch(u, a.DeviceID) {
+			continue
+		}
+		row := AlertExportRow{Alert: a, DriverName: driver, FleetName: fleet}
+		if v := r.vehicleByID(a.VehicleID); v != nil {
+			row.VehiclePlate = v.PlateNumber
+		}
+		rows = append(rows, row)
+	}
+	r.mu.RUnlock()
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		if !rows[i].Timestamp.Equal(rows[j].Timestamp) {
+			return rows[i].Timestamp.Before(rows[j].Timestamp)
+		}
+		return rows[i].ID < rows[j].ID
+	})
+	for i := range rows {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(&rows[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
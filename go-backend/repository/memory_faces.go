@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+
+	"driver-drowsiness-backend/models"
+)
+
+type memFaces struct{ *memoryDB }
+
+func (r *memFaces) Enroll(_ context.Context, e *models.FaceEmbedding) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e.ID = r.nextID()
+	e.Dimensions = len(e.Vector)
+	stored := *e
+	stored.Vector = append([]float64(nil), e.Vector...)
+	r.faces = append(r.faces, stored)
+	return nil
+}
+
+func (r *memFaces) List(_ context.Context, userID int) ([]models.FaceEmbedding, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var embeddings []models.FaceEmbedding
+	for _, e := range r.faces {
+		if userID == 0 || e.UserID == userID {
+			embeddings = append(embeddings, e)
+		}
+	}
+	return embeddings, nil
+}
+
+func (r *memFaces) Candidates(_ context.Context, model string, dimensions int) ([]models.FaceEmbedding, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var embeddings []models.FaceEmbedding
+	for _, e := range r.faces {
+		if e.Model == model && e.Dimensions == dimensions {
+			embeddings = append(embeddings, e)
+		}
+	}
+	return embeddings, nil
+}
+
+func (r *memFaces) Delete(_ context.Context, userID, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, e := range r.faces {
+		if e.ID == id && e.UserID == userID {
+			r.faces = append(r.faces[:i], r.faces[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (r *memFaces) DeleteAll(_ context.Context, userID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	kept := r.faces[:0]
+	for _, e := range r.faces {
+		if e.UserID != userID {
+			kept = append(kept, e)
+		}
+	}
+	r.faces = kept
+	return nil
+}
@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"context"
+	"sort"
+)
+
+type memGeo struct{ *memoryDB }
+
+func (r *memGeo) Alerts(_ context.Context, f GeoFilter) ([]LocatedAlert, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var alerts []LocatedAlert
+	for _, a := range r.alerts {
+		if a.Location == nil || !f.Range.Contains(a.Timestamp) {
+			continue
+		}
+		if f.DeviceID != "" && a.DeviceID != f.DeviceID {
+			continue
+		}
+		driverID := r.sampleDriver(a.DeviceID, a.DriverID)
+		if f.DriverID != 0 && driverID != f.DriverID {
+			continue
+		}
+		if f.FleetID != 0 {
+			if u := r.userByID(driverID); u == nil || u.FleetID != f.FleetID {
+				continue
+			}
+		}
+		alerts = append(alerts, LocatedAlert{Alert: a, DriverID: driverID})
+	}
+	sort.SliceStable(alerts, func(i, j int) bool { return alerts[i].Timestamp.Before(alerts[j].Timestamp) })
+	return alerts, nil
+}
@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"driver-drowsiness-backend/models"
+)
+
+type memGeofences struct{ *memoryDB }
+
+func (r *memGeofences) List(_ context.Context) ([]models.Geofence, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]models.Geofence(nil), r.geofences...), nil
+}
+
+func (r *memGeofences) GetByID(_ context.Context, id int) (*models.Geofence, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, g := range r.geofences {
+		if g.ID == id {
+			return &g, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memGeofences) Create(_ context.Context, g *models.Geofence) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if g.Rules == nil {
+		g.Rules = []models.GeofenceRule{}
+	}
+	g.ID = r.nextID()
+	g.CreatedAt = time.Now().UTC()
+	g.UpdatedAt = g.CreatedAt
+	r.geofences = append(r.geofences, *g)
+	return nil
+}
+
+func (r *memGeofences) Update(_ context.Context, g *models.Geofence) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if g.Rules == nil {
+		g.Rules = []models.GeofenceRule{}
+	}
+	for i := range r.geofences {
+		if r.geofences[i].ID == g.ID {
+			g.CreatedAt = r.geofences[i].CreatedAt
+			g.UpdatedAt = time.Now().UTC()
+			r.geofences[i] = *g
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (r *memGeofences) Delete(_ context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.geofences {
+		if r.geofences[i].ID == id {
+			r.geofences = append(r.geofences[:i], r.geofences[i+1:]...)
+			kept := r.geofenceEvents[:0]
+			for _, e := range r.geofenceEvents {
+				if e.GeofenceID != id {
+					kept = append(kept, e)
+				}
+			}
+			r.geofenceEvents = kept
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (r *memGeofences) InsertEvent(_ context.Context, e *models.GeofenceEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e.ID = r.nextID()
+	r.geofenceEvents = append(r.geofenceEvents, *e)
+	return nil
+}
+
+func (r *memGeofences) Inside(_ context.Context, deviceID string) ([]int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	latest := make(map[int]models.GeofenceEvent)
+	for _, e := range r.geofenceEvents {
+		if e.DeviceID != deviceID {
+			continue
+		}
+		if prev, ok := latest[e.GeofenceID]; !ok || !e.Timestamp.Before(prev.Timestamp) {
+			latest[e.GeofenceID] = e
+		}
+	}
+	var ids []int
+	for id, e := range latest {
+		if e.Event == "enter" {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+func (r *memGeofences) Events(_ context.Context, f GeofenceEventFilter) ([]models.GeofenceEvent, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var events []models.GeofenceEvent
+	for _, e := range r.geofenceEvents {
+		if f.DeviceID != "" && e.DeviceID != f.DeviceID {
+			continue
+		}
+		if f.GeofenceID != 0 && e.GeofenceID != f.GeofenceID {
+			continue
+		}
+		if !f.Range.From.IsZero() && e.Timestamp.Before(f.Range.From) {
+			continue
+		}
+		if !f.Range.To.IsZero() && !e.Timestamp.Before(f.Range.To) {
+			continue
+		}
+		events = append(events, e)
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		if !events[i].Timestamp.Equal(events[j].Timestamp) {
+			return events[i].Timestamp.After(events[j].Timestamp)
+		}
+		return events[i].ID > events[j].ID
+	})
+	if f.Limit > 0 && len(events) > f.Limit {
+		events = events[:f.Limit]
+	}
+	return events, nil
+}
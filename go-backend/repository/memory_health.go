@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"driver-drowsiness-backend/models"
+)
+
+type memHealth struct{ *memoryDB }
+
+func (r *memHealth) Get(_ context.Context, deviceID string) (*models.DeviceHealth, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	h, ok := r.health[deviceID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &h, nil
+}
+
+func (r *memHealth) Save(_ context.Context, h *models.DeviceHealth, event *models.DeviceStateEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.health[h.DeviceID] = *h
+	if event != nil {
+		event.ID = r.nextID()
+		r.stateEvents = append(r.stateEvents, *event)
+	}
+	return nil
+}
+
+func (r *memHealth) Stale(_ context.Context, cutoff time.Time) ([]models.DeviceHealth, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var stale []models.DeviceHealth
+	for _, h := range r.health {
+		if h.State != models.DeviceOffline && h.LastHeartbeat.Before(cutoff) {
+			stale = append(stale, h)
+		}
+	}
+	sort.Slice(stale, func(i, j int) bool { return stale[i].DeviceID < stale[j].DeviceID })
+	return stale, nil
+}
+
+func (r *memHealth) Events(_ context.Context, f DeviceEventFilter) ([]models.DeviceStateEvent, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var events []models.DeviceStateEvent
+	for _, e := range r.stateEvents {
+		if f.DeviceID != "" && e.DeviceID != f.DeviceID {
+			continue
+		}
+		if !f.Range.From.IsZero() && e.At.Before(f.Range.From) {
+			continue
+		}
+		if !f.Range.To.IsZero() && !e.At.Before(f.Range.To) {
+			continue
+		}
+		events = append(events, e)
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].At.Before(events[j].At) })
+	if f.Limit > 0 && len(events) > f.Limit {
+		events = events[:f.Limit]
+	}
+	return events, nil
+}
+
+func (r *memHealth) LastEventBefore(_ context.Context, deviceID string, t time.Time) (*models.DeviceStateEvent, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var last *models.DeviceStateEvent
+	for i, e := range r.stateEvents {
+		if e.DeviceID == deviceID && e.At.Before(t) && (last == nil || !e.At.Before(last.At)) {
+			last = &r.stateEvents[i]
+		}
+	}
+	if last == nil {
+		return nil, ErrNotFound
+	}
+	found := *last
+	return &found, nil
+}
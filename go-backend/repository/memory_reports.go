@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"driver-drowsiness-backend/models"
+)
+
+type memReports struct{ *memoryDB }
+
+func (r *memReports) HourlyCounts(_ context.Context, f ExportFilter) ([]HourlyCount, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	type key struct {
+		hour   int64
+		driver int
+	}
+	counts := make(map[key]*HourlyCount)
+	add := func(hour time.Time, driver, high, medium int) {
+		u := r.userView(driver)
+		if (f.FleetID != 0 && (u == nil || u.FleetID != f.FleetID)) || (f.DriverID != 0 && driver != f.DriverID) {
+			return
+		}
+		k := key{hour.Unix(), driver}
+		h, ok := counts[k]
+		if !ok {
+			h = &HourlyCount{Hour: hour, DriverID: driver}
+			if u != nil {
+				h.DriverName = u.DisplayName
+			}
+			counts[k] = h
+		}
+		h.High += high
+		h.Medium += medium
+	}
+	for _, d := range r.drowsiness {
+		level := strings.ToLower(d.DrowsinessLevel)
+		if !f.Range.Contains(d.Timestamp) || (level != "high" && level != "medium") {
+			continue
+		}
+		high := 0
+		if level == "high" {
+			high = 1
+		}
+		add(d.Timestamp.UTC().Truncate(time.Hour), r.sampleDriver(d.DeviceID, d.DriverID), high, 1-high)
+	}
+	// Samples the purge rolled up
+	for _, h := range r.hourly {
+		if f.Range.Contains(h.Hour) {
+			add(h.Hour, h.DriverID, h.High, h.Medium)
+		}
+	}
+
+	results := make([]HourlyCount, 0, len(counts))
+	for _, h := range counts {
+		results = append(results, *h)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if !results[i].Hour.Equal(results[j].Hour) {
+			return results[i].Hour.Before(results[j].Hour)
+		}
+		return results[i].DriverID < results[j].DriverID
+	})
+	return results, nil
+}
+
+func (r *memReports) Create(_ context.Context, rep *models.Report) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if rep.Source == models.ReportSchedule {
+		for _, other := range r.reports {
+			if other.Source == models.ReportSchedule && other.Kind == rep.Kind && other.FleetID == rep.FleetID &&
+				other.DriverID == rep.DriverID && other.PeriodFrom.Equal(rep.PeriodFrom) {
+				return ErrConflict
+			}
+		}
+	}
+	rep.ID = r.nextID()
+	r.reports = append(r.reports, *rep)
+	return nil
+}
+
+func (r *memReports) Update(_ context.Context, rep *models.Report) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.reports {
+		if r.reports[i].ID == rep.ID {
+			stored := &r.reports[i]
+			stored.Status, stored.Error, stored.StorageKey = rep.Status, rep.Error, rep.StorageKey
+			stored.Size, stored.Pages, stored.CompletedAt = rep.Size, rep.Pages, rep.CompletedAt
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (r *memReports) GetByID(_ context.Context, id int) (*models.Report, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, rep := range r.reports {
+		if rep.ID == id {
+			return &rep, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memReports) List(_ context.Context, f ReportFilter) ([]models.Report, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var reports []models.Report
+	for _, rep := range r.reports {
+		if (f.Kind != "" && rep.Kind != f.Kind) || (f.FleetID != 0 && rep.FleetID != f.FleetID) ||
+			(f.DriverID != 0 && rep.DriverID != f.DriverID) {
+			continue
+		}
+		reports = append(reports, rep)
+	}
+	sort.SliceStable(reports, func(i, j int) bool {
+		if !reports[i].CreatedAt.Equal(reports[j].CreatedAt) {
+			return reports[i].CreatedAt.After(reports[j].CreatedAt)
+		}
+		return reports[i].ID > reports[j].ID
+	})
+	if f.Limit > 0 && len(reports) > f.Limit {
+		reports = reports[:f.Limit]
+	}
+	return reports, nil
+}
+
+func (r *memReports) Delete(_ context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, rep := range r.reports {
+		if rep.ID == id {
+			r.reports = append(r.reports[:i], r.reports[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// memHourly is a row of hourly_level_counts
+type memHourly struct {
+	Hour     time.Time // UTC
+	DeviceID string
+	DriverID int // 0 when the device had no owner
+	High     int
+	Medium   int
+	Low      int
+}
+
+type memRetention struct{ *memoryDB }
+
+func (r *memRetention) Purge(_ context.Context, c PurgeCutoffs) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cutoff := func(deviceID string, driverID int) time.Time {
+		if u := r.userByID(r.sampleDriver(deviceID, driverID)); u != nil {
+			return c.For(u.FleetID)
+		}
+		return c.Default
+	}
+
+	kept := r.drowsiness[:0]
+	for _, d := range r.drowsiness {
+		if !d.Timestamp.Before(cutoff(d.DeviceID, d.DriverID)) {
+			kept = append(kept, d)
+			continue
+		}
+		level := strings.ToLower(d.DrowsinessLevel)
+		if level != "high" && level != "medium" && level != "low" {
+			continue
+		}
+		h := r.hourlyRow(d.Timestamp.UTC().Truncate(time.Hour), d.DeviceID, r.sampleDriver(d.DeviceID, d.DriverID))
+		switch level {
+		case "high":
+			h.High++
+		case "medium":
+			h.Medium++
+		default:
+			h.Low++
+		}
+	}
+	r.drowsiness = kept
+
+	alerts := r.alerts[:0]
+	for _, a := range r.alerts {
+		if !a.Timestamp.Before(cutoff(a.DeviceID, a.DriverID)) {
+			alerts = append(alerts, a)
+		}
+	}
+	r.alerts = alerts
+
+	sessions := r.sessions[:0]
+	for _, s := range r.sessions {
+		if !s.LastSampleAt.Before(cutoff(s.DeviceID, s.DriverID)) {
+			sessions = append(sessions, s)
+		}
+	}
+	r.sessions = sessions
+	return nil
+}
+
+// hourlyRow returns the rollup of a device and driver in hour, adding it if needed
+func (m *memoryDB) hourlyRow(hour time.Time, deviceID string, driverID int) *memHourly {
+	for i := range m.hourly {
+		if h := &m.hourly[i]; h.Hour.Equal(hour) && h.DeviceID == deviceID && h.DriverID == driverID {
+			return h
+		}
+	}
+	m.hourly = append(m.hourly, memHourly{Hour: hour, DeviceID: deviceID, DriverID: driverID})
+	return &m.hourly[len(m.hourly)-1]
+}
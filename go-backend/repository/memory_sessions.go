@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"sort"
+
+	"driver-drowsiness-backend/models"
+)
+
+type memSessions struct{ *memoryDB }
+
+// withAlerts copies a session and counts its alerts like the SQL subquery
+func (r *memSessions) withAlerts(s models.DrivingSession) models.DrivingSession {
+	end := s.LastSampleAt
+	if s.EndedAt != nil {
+		end = *s.EndedAt
+	}
+	s.AlertCount = 0
+	for _, a := range r.alerts {
+		if a.DeviceID == s.DeviceID && !a.Timestamp.Before(s.StartedAt) && !a.Timestamp.After(end) {
+			s.AlertCount++
+		}
+	}
+	return s
+}
+
+func (r *memSessions) Create(_ context.Context, s *models.DrivingSession) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s.ID = r.nextID()
+	s.VehicleID = r.vehicleAt(s.DeviceID, s.StartedAt)
+	r.sessions = append(r.sessions, *s)
+	return nil
+}
+
+func (r *memSessions) Update(_ context.Context, s *models.DrivingSession) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.sessions {
+		if r.sessions[i].ID == s.ID {
+			r.sessions[i] = *s
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (r *memSessions) Open(_ context.Context, deviceID string) (*models.DrivingSession, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for i := len(r.sessions) - 1; i >= 0; i-- {
+		if s := r.sessions[i]; s.DeviceID == deviceID && s.EndedAt == nil {
+			s = r.withAlerts(s)
+			return &s, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memSessions) GetByID(_ context.Context, id int) (*models.DrivingSession, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, s := range r.sessions {
+		if s.ID == id {
+			s = r.withAlerts(s)
+			return &s, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memSessions) List(_ context.Context, f SessionFilter) ([]models.DrivingSession, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var sessions []models.DrivingSession
+	for _, s := range r.sessions {
+		if f.DeviceID != "" && s.DeviceID != f.DeviceID {
+			continue
+		}
+		if f.DriverID != 0 && s.DriverID != f.DriverID {
+			continue
+		}
+		end := s.LastSampleAt
+		if s.EndedAt != nil {
+			end = *s.EndedAt
+		}
+		if !f.Range.From.IsZero() && end.Before(f.Range.From) {
+			continue
+		}
+		if !f.Range.To.IsZero() && !s.StartedAt.Before(f.Range.To) {
+			continue
+		}
+		sessions = append(sessions, r.withAlerts(s))
+	}
+	sort.SliceStable(sessions, func(i, j int) bool {
+		if !sessions[i].StartedAt.Equal(sessions[j].StartedAt) {
+			return sessions[i].StartedAt.After(sessions[j].StartedAt)
+		}
+		return sessions[i].ID > sessions[j].ID
+	})
+	if f.Limit > 0 && len(sessions) > f.Limit {
+		sessions = sessions[:f.Limit]
+	}
+	return sessions, nil
+}
@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"driver-drowsiness-backend/models"
+)
+
+type memShadows struct{ *memoryDB }
+
+// copyShadow detaches a stored shadow from the pointers it shares with the store
+func copyShadow(s models.DeviceShadow) *models.DeviceShadow {
+	if s.Reported != nil {
+		reported := *s.Reported
+		s.Reported = &reported
+	}
+	if s.UpdatedAt != nil {
+		t := *s.UpdatedAt
+		s.UpdatedAt = &t
+	}
+	if s.ReportedAt != nil {
+		t := *s.ReportedAt
+		s.ReportedAt = &t
+	}
+	return &s
+}
+
+func (r *memShadows) Get(_ context.Context, deviceID string) (*models.DeviceShadow, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	s, ok := r.shadows[deviceID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return copyShadow(s), nil
+}
+
+func (r *memShadows) SetDesired(_ context.Context, deviceID string, cfg models.DeviceConfig, at time.Time) (*models.DeviceShadow, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.shadows[deviceID]
+	if !ok {
+		s = models.DeviceShadow{DeviceID: deviceID}
+	}
+	at = at.UTC()
+	s.Desired, s.Version, s.UpdatedAt = cfg, s.Version+1, &at
+	r.shadows[deviceID] = s
+	return copyShadow(s), nil
+}
+
+func (r *memShadows) Report(_ context.Context, deviceID string, report models.ConfigReport, at time.Time) (*models.DeviceShadow, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.shadows[deviceID]
+	if !ok {
+		s = models.DeviceShadow{DeviceID: deviceID, Desired: report.Config}
+	}
+	at = at.UTC()
+	reported := report.Config
+	s.Reported, s.ReportedVersion, s.ReportedAt = &reported, report.Version, &at
+	r.shadows[deviceID] = s
+	return copyShadow(s), nil
+}
+
+func (r *memShadows) List(_ context.Context, f ShadowFilter) ([]models.DeviceShadow, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var shadows []models.DeviceShadow
+	for _, d := range r.devices {
+		if f.DeviceID != "" && d.ID != f.DeviceID {
+			continue
+		}
+		if f.FleetID != 0 {
+			if u := r.userByID(d.UserID); u == nil || u.FleetID != f.FleetID {
+				continue
+			}
+		}
+		s, ok := r.shadows[d.ID]
+		if !ok {
+			s = models.DeviceShadow{DeviceID: d.ID, Desired: models.DefaultDeviceConfig()}
+		}
+		shadows = append(shadows, *copyShadow(s))
+	}
+	sort.Slice(shadows, func(i, j int) bool { return shadows[i].DeviceID < shadows[j].DeviceID })
+	return shadows, nil
+}
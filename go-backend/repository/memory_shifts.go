@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"driver-drowsiness-backend/models"
+)
+
+type memShifts struct{ *memoryDB }
+
+// shiftAt returns the shift matching match that covers at
+func (m *memoryDB) shiftAt(at time.Time, match func(models.Shift) bool) *models.Shift {
+	var best *models.Shift
+	for i, s := range m.shifts {
+		if !match(s) || s.StartsAt.After(at) || (s.EndsAt != nil && !s.EndsAt.After(at)) {
+			continue
+		}
+		if best == nil || s.StartsAt.After(best.StartsAt) {
+			best = &m.shifts[i]
+		}
+	}
+	return best
+}
+
+func (m *memoryDB) shiftOverlaps(s *models.Shift) bool {
+	for _, other := range m.shifts {
+		if other.ID == s.ID || (other.DriverID != s.DriverID && other.DeviceID != s.DeviceID) {
+			continue
+		}
+		if overlaps(s.StartsAt, s.EndsAt, other.StartsAt, other.EndsAt) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *memShifts) List(_ context.Context, f ShiftFilter) ([]models.Shift, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var shifts []models.Shift
+	for _, s := range r.shifts {
+		if f.DriverID != 0 && s.DriverID != f.DriverID {
+			continue
+		}
+		if f.VehicleID != 0 && s.VehicleID != f.VehicleID {
+			continue
+		}
+		if f.DeviceID != "" && s.DeviceID != f.DeviceID {
+			continue
+		}
+		if !f.Range.From.IsZero() && s.EndsAt != nil && !s.EndsAt.After(f.Range.From) {
+			continue
+		}
+		if !f.Range.To.IsZero() && !s.StartsAt.Before(f.Range.To) {
+			continue
+		}
+		shifts = append(shifts, s)
+	}
+	sort.Slice(shifts, func(i, j int) bool {
+		if !shifts[i].StartsAt.Equal(shifts[j].StartsAt) {
+			return shifts[i].StartsAt.After(shifts[j].StartsAt)
+		}
+		return shifts[i].ID > shifts[j].ID
+	})
+	if f.Limit > 0 && len(shifts) > f.Limit {
+		shifts = shifts[:f.Limit]
+	}
+	return shifts, nil
+}
+
+func (r *memShifts) GetByID(_ context.Context, id int) (*models.Shift, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, s := range r.shifts {
+		if s.ID == id {
+			return &s, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memShifts) Create(_ context.Context, s *models.Shift) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s.ID = 0
+	if r.shiftOverlaps(s) {
+		return ErrConflict
+	}
+	s.ID = r.nextID()
+	s.CreatedAt = time.Now().UTC()
+	r.shifts = append(r.shifts, *s)
+	return nil
+}
+
+func (r *memShifts) Update(_ context.Context, s *models.Shift) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.shifts {
+		if r.shifts[i].ID == s.ID {
+			if r.shiftOverlaps(s) {
+				return ErrConflict
+			}
+			s.CreatedAt = r.shifts[i].CreatedAt
+			r.shifts[i] = *s
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (r *memShifts) Delete(_ context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.shifts {
+		if r.shifts[i].ID == id {
+			r.shifts = append(r.shifts[:i], r.shifts[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (r *memShifts) OnDevice(_ context.Context, deviceID string, at time.Time) (*models.Shift, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if s := r.shiftAt(at, func(s models.Shift) bool { return s.DeviceID == deviceID }); s != nil {
+		found := *s
+		return &found, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memShifts) OnDriver(_ context.Context, driverID int, at time.Time) (*models.Shift, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if s := r.shiftAt(at, func(s models.Shift) bool { return s.DriverID == driverID }); s != nil {
+		found := *s
+		return &found, nil
+	}
+	return nil, ErrNotFound
+}
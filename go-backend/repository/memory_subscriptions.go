@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"driver-drowsiness-backend/models"
+)
+
+type memSubscriptions struct{ *memoryDB }
+
+func (r *memSubscriptions) Create(_ context.Context, s *models.Subscription) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s.ID = r.nextID()
+	r.subscriptions = append(r.subscriptions, *s)
+	return nil
+}
+
+func (r *memSubscriptions) GetByID(_ context.Context, id int) (*models.Subscription, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, s := range r.subscriptions {
+		if s.ID == id {
+			return &s, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memSubscriptions) List(_ context.Context, f SubscriptionFilter) ([]models.Subscription, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var subs []models.Subscription
+	for _, s := range r.subscriptions {
+		if (f.UserID == 0 || s.UserID == f.UserID) && (f.FleetID == 0 || s.FleetID == f.FleetID) {
+			subs = append(subs, s)
+		}
+	}
+	return subs, nil
+}
+
+func (r *memSubscriptions) Update(_ context.Context, s *models.Subscription) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.subscriptions {
+		if r.subscriptions[i].ID == s.ID {
+			stored := &r.subscriptions[i]
+			stored.Format, stored.Channel, stored.Target, stored.Secret = s.Format, s.Channel, s.Target, s.Secret
+			stored.Lang, stored.Active, stored.NextRunAt = s.Lang, s.Active, s.NextRunAt
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (r *memSubscriptions) Delete(_ context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, s := range r.subscriptions {
+		if s.ID == id {
+			r.subscriptions = append(r.subscriptions[:i], r.subscriptions[i+1:]...)
+			kept := r.deliveries[:0]
+			for _, d := range r.deliveries {
+				if d.SubscriptionID != id {
+					kept = append(kept, d)
+				}
+			}
+			r.deliveries = kept
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (r *memSubscriptions) Due(_ context.Context, now time.Time, limit int) ([]models.Subscription, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var subs []models.Subscription
+	for _, s := range r.subscriptions {
+		if s.Active && !s.NextRunAt.After(now) {
+			subs = append(subs, s)
+		}
+	}
+	sort.SliceStable(subs, func(i, j int) bool { return subs[i].NextRunAt.Before(subs[j].NextRunAt) })
+	if len(subs) > limit {
+		subs = subs[:limit]
+	}
+	return subs, nil
+}
+
+func (r *memSubscriptions) Advance(_ context.Context, id int, from, to time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.subscriptions {
+		if s := &r.subscriptions[i]; s.ID == id && s.NextRunAt.Equal(from) {
+			s.NextRunAt = to
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *memSubscriptions) CreateDelivery(_ context.Context, d *models.Delivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, other := range r.deliveries {
+		if other.SubscriptionID == d.SubscriptionID && other.PeriodFrom.Equal(d.PeriodFrom) {
+			return ErrConflict
+		}
+	}
+	d.ID = r.nextID()
+	r.deliveries = append(r.deliveries, *d)
+	return nil
+}
+
+func (r *memSubscriptions) ClaimDeliveries(_ context.Context, now time.Time, lease time.Duration, limit int) ([]models.Delivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var claimed []models.Delivery
+	for i := range r.deliveries {
+		d := &r.deliveries[i]
+		if len(claimed) == limit {
+			break
+		}
+		if d.Status != models.DeliveryPending || d.NextAttemptAt.After(now) {
+			continue
+		}
+		at := now
+		d.Attempts++
+		d.LastAttemptAt = &at
+		d.NextAttemptAt = now.Add(lease)
+		claimed = append(claimed, *d)
+	}
+	return claimed, nil
+}
+
+func (r *memSubscriptions) UpdateDelivery(_ context.Context, d *models.Delivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.deliveries {
+		if r.deliveries[i].ID == d.ID {
+			stored := &r.deliveries[i]
+			stored.Status, stored.Error, stored.NextAttemptAt, stored.DeliveredAt = d.Status, d.Error, d.NextAttemptAt, d.DeliveredAt
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (r *memSubscriptions) ListDeliveries(_ context.Context, subscriptionID, limit int) ([]models.Delivery, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var deliveries []models.Delivery
+	for _, d := range r.deliveries {
+		if d.SubscriptionID == subscriptionID {
+			deliveries = append(deliveries, d)
+		}
+	}
+	sort.SliceStable(deliveries, func(i, j int) bool {
+		if !deliveries[i].PeriodFrom.Equal(deliveries[j].PeriodFrom) {
+			return deliveries[i].PeriodFrom.After(deliveries[j].PeriodFrom)
+		}
+		return deliveries[i].ID > deliveries[j].ID
+	})
+	if limit > 0 && len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"driver-drowsiness-backend/models"
+)
+
+type memVehicles struct{ *memoryDB }
+
+func (m *memoryDB) vehicleByID(id int) *models.Vehicle {
+	for i := range m.vehicles {
+		if m.vehicles[i].ID == id {
+			return &m.vehicles[i]
+		}
+	}
+	return nil
+}
+
+// vehicleAt mirrors vehicleAtSQL; 0 when the device was not installed
+func (m *memoryDB) vehicleAt(deviceID string, at time.Time) int {
+	var best *models.DeviceInstallation
+	for i, inst := range m.installations {
+		if inst.DeviceID != deviceID || inst.InstalledAt.After(at) {
+			continue
+		}
+		if inst.RemovedAt != nil && !inst.RemovedAt.After(at) {
+			continue
+		}
+		if best == nil || inst.InstalledAt.After(best.InstalledAt) {
+			best = &m.installations[i]
+		}
+	}
+	if best == nil {
+		return 0
+	}
+	return best.VehicleID
+}
+
+// withDevice fills the device currently installed in a vehicle
+func (m *memoryDB) withDevice(v models.Vehicle) models.Vehicle {
+	v.DeviceID = ""
+	var latest time.Time
+	for _, inst := range m.installations {
+		if inst.VehicleID == v.ID && inst.RemovedAt == nil && !inst.InstalledAt.Before(latest) {
+			v.DeviceID, latest = inst.DeviceID, inst.InstalledAt
+		}
+	}
+	return v
+}
+
+func (m *memoryDB) plateTaken(plate string, exceptID int) bool {
+	for _, v := range m.vehicles {
+		if v.PlateNumber == plate && v.ID != exceptID {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *memVehicles) List(_ context.Context) ([]models.Vehicle, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	vehicles := make([]models.Vehicle, 0, len(r.vehicles))
+	for _, v := range r.vehicles {
+		vehicles = append(vehicles, r.withDevice(v))
+	}
+	sort.Slice(vehicles, func(i, j int) bool { return vehicles[i].PlateNumber < vehicles[j].PlateNumber })
+	return vehicles, nil
+}
+
+func (r *memVehicles) GetByID(_ context.Context, id int) (*models.Vehicle, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	v := r.vehicleByID(id)
+	if v == nil {
+		return nil, ErrNotFound
+	}
+	withDevice := r.withDevice(*v)
+	return &withDevice, nil
+}
+
+func (r *memVehicles) Create(_ context.Context, v *models.Vehicle) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.plateTaken(v.PlateNumber, 0) {
+		return ErrConflict
+	}
+	v.ID = r.nextID()
+	v.CreatedAt = time.Now().UTC()
+	v.DeviceID = ""
+	r.vehicles = append(r.vehicles, *v)
+	return nil
+}
+
+func (r *memVehicles) Update(_ context.Context, v *models.Vehicle) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	existing := r.vehicleByID(v.ID)
+	if existing == nil {
+		return ErrNotFound
+	}
+	if r.plateTaken(v.PlateNumber, v.ID) {
+		return ErrConflict
+	}
+	v.CreatedAt = existing.CreatedAt
+	v.DeviceID = ""
+	*existing = *v
+	return nil
+}
+
+func (r *memVehicles) Install(_ context.Context, deviceID string, vehicleID int, at time.Time) (*models.DeviceInstallation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	at = at.UTC()
+	for i := range r.installations {
+		inst := &r.installations[i]
+		if inst.DeviceID != deviceID || inst.RemovedAt != nil {
+			continue
+		}
+		if at.Before(inst.InstalledAt) {
+			return nil, ErrConflict
+		}
+		removed := at
+		inst.RemovedAt = &removed
+	}
+	inst := models.DeviceInstallation{ID: r.nextID(), DeviceID: deviceID, VehicleID: vehicleID, InstalledAt: at}
+	r.installations = append(r.installations, inst)
+	return &inst, nil
+}
+
+func (r *memVehicles) Remove(_ context.Context, deviceID string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.installations {
+		inst := &r.installations[i]
+		if inst.DeviceID == deviceID && inst.RemovedAt == nil {
+			removed := at.UTC()
+			if removed.Before(inst.InstalledAt) {
+				removed = inst.InstalledAt
+			}
+			inst.RemovedAt = &removed
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (r *memVehicles) Installations(_ context.Context, f InstallationFilter) ([]models.DeviceInstallation, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var history []models.DeviceInstallation
+	for _, inst := range r.installations {
+		if f.DeviceID != "" && inst.DeviceID != f.DeviceID {
+			continue
+		}
+		if f.VehicleID != 0 && inst.VehicleID != f.VehicleID {
+			continue
+		}
+		history = append(history, inst)
+	}
+	sort.Slice(history, func(i, j int) bool {
+		if !history[i].InstalledAt.Equal(history[j].InstalledAt) {
+			return history[i].InstalledAt.After(history[j].InstalledAt)
+		}
+		return history[i].ID > history[j].ID
+	})
+	return history, nil
+}
+
+func (r *memVehicles) VehicleAt(_ context.Context, deviceID string, at time.Time) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if id := r.vehicleAt(deviceID, at); id != 0 {
+		return id, nil
+	}
+	return 0, ErrNotFound
+}
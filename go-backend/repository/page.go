@@ -0,0 +1,50 @@
+package repository
+
+import "time"
+
+// Cursor is a position in a listing ordered by (timestamp, id)
+type Cursor struct {
+	Timestamp time.Time
+	ID        int
+}
+
+// Less reports whether the row at (t, id) sorts before the cursor
+func (c Cursor) Less(t time.Time, id int) bool {
+	return t.Before(c.Timestamp) || (t.Equal(c.Timestamp) && id < c.ID)
+}
+
+// PageFilter selects one page of the samples or alerts of a device, newest
+// first; zero fields match everything
+type PageFilter struct {
+	DeviceID string
+	Range    TimeRange // on timestamp; a zero bound is open
+	Level    string    // drowsiness level of samples, case-insensitive
+	Severity string    // severity of alerts
+	Status   string
+	Before   *Cursor // only rows older than the cursor (the next page)
+	After    *Cursor // only rows newer than the cursor (the previous page)
+	Limit    int
+}
+
+// match reports whether the row at (t, id) lies within the range and cursors
+func (f PageFilter) match(t time.Time, id int) bool {
+	if (!f.Range.From.IsZero() && t.Before(f.Range.From)) || (!f.Range.To.IsZero() && !t.Before(f.Range.To)) {
+		return false
+	}
+	if f.Before != nil && !f.Before.Less(t, id) {
+		return false
+	}
+	return f.After == nil || (!f.After.Less(t, id) && !(t.Equal(f.After.Timestamp) && id == f.After.ID))
+}
+
+// window returns the slice bounds of a page within n matching rows sorted
+// newest first: the newest rows, or those just newer than f.After
+func (f PageFilter) window(n int) (lo, hi int) {
+	if f.Limit <= 0 || n <= f.Limit {
+		return 0, n
+	}
+	if f.After != nil {
+		return n - f.Limit, n
+	}
+	return 0, f.Limit
+}
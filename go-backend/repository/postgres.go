@@ -0,0 +1,704 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"driver-drowsiness-backend/models"
+
+	"github.com/lib/pq"
+)
+
+// NewPostgresStore returns repositories backed by a PostgreSQL connection
+func NewPostgresStore(db *sql.DB) *Store {
+	return &Store{
+		Users:          &pgUsers{db: db},
+		Devices:        &pgDevices{db: db},
+		Drowsiness:     &pgDrowsiness{db: db},
+		Alerts:         &pgAlerts{db: db},
+		PasswordResets: &pgPasswordResets{db: db},
+		Fleets:         &pgFleets{db: db},
+		Dashboard:      &pgDashboard{db: db},
+		Analytics:      &pgAnalytics{db: db},
+		Sessions:       &pgSessions{db: db},
+		Geo:            &pgGeo{db: db},
+		Geofences:      &pgGeofences{db: db},
+		Vehicles:       &pgVehicles{db: db},
+		Shifts:         &pgShifts{db: db},
+		Compliance:     &pgCompliance{db: db},
+		Health:         &pgHealth{db: db},
+		Shadows:        &pgShadows{db: db},
+		Commands:       &pgCommands{db: db},
+		Evidence:       &pgEvidence{db: db},
+		Faces:          &pgFaces{db: db},
+		Exports:        &pgExports{db: db},
+		Reports:        &pgReports{db: db},
+		Subscriptions:  &pgSubscriptions{db: db},
+		Retention:      &pgRetention{db: db},
+	}
+}
+
+// notFound maps sql.ErrNoRows to ErrNotFound
+func notFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// conflict maps unique_violation to ErrConflict
+func conflict(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrConflict
+	}
+	return err
+}
+
+// ================== USERS ==================
+
+type pgUsers struct{ db *sql.DB }
+
+func (r *pgUsers) Create(ctx context.Context, u *models.User) (int, error) {
+	var id int
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO users (email, password_hash, name, role, phone, user_type, fleet_id)
+		VALUES ($1, $2, $3, COALESCE(NULLIF($4, ''), 'driver'), $5, $6, NULLIF($7, 0))
+		RETURNING id
+	`, u.Email, u.PasswordHash, u.Name, u.Role, u.Phone, u.UserType, u.FleetID).Scan(&id)
+	return id, conflict(err)
+}
+
+func (r *pgUsers) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	return r.get(ctx, `WHERE email = $1`, email)
+}
+
+func (r *pgUsers) GetByID(ctx context.Context, id int) (*models.User, error) {
+	return r.get(ctx, `WHERE id = $1`, id)
+}
+
+func (r *pgUsers) get(ctx context.Context, where string, arg interface{}) (*models.User, error) {
+	var u models.User
+	var name, phone, userType sql.NullString
+	var fleetID sql.NullInt64
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, email, password_hash, name, phone, role, user_type, fleet_id, created_at
+		FROM users `+where, arg,
+	).Scan(&u.ID, &u.Email, &u.PasswordHash, &name, &phone, &u.Role, &userType, &fleetID, &u.CreatedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	u.Name = name.String
+	u.Phone = phone.String
+	u.UserType = userType.String
+	u.FleetID = int(fleetID.Int64)
+	return &u, nil
+}
+
+func (r *pgUsers) UpdatePassword(ctx context.Context, id int, passwordHash string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE users SET password_hash = $1 WHERE id = $2
+	`, passwordHash, id)
+	return err
+}
+
+// ================== DEVICES ==================
+
+type pgDevices struct{ db *sql.DB }
+
+const deviceColumns = `id, driver_email, user_id, status, last_update, created_at`
+
+func scanDevice(row interface{ Scan(...interface{}) error }) (*models.Device, error) {
+	var d models.Device
+	var userID sql.NullInt64
+	if err := row.Scan(&d.ID, &d.DriverEmail, &userID, &d.Status, &d.LastUpdate, &d.CreatedAt); err != nil {
+		return nil, err
+	}
+	d.UserID = int(userID.Int64)
+	return &d, nil
+}
+
+func (r *pgDevices) List(ctx context.Context) ([]models.Device, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+deviceColumns+`
+		FROM devices
+		ORDER BY last_update DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var devices []models.Device
+	for rows.Next() {
+		d, err := scanDevice(rows)
+		if err != nil {
+			return nil, err
+		}
+		devices = append(devices, *d)
+	}
+	return devices, rows.Err()
+}
+
+func (r *pgDevices) Get(ctx context.Context, deviceID string) (*models.Device, error) {
+	d, err := scanDevice(r.db.QueryRowContext(ctx, `
+		SELECT `+deviceColumns+` FROM devices WHERE id = $1
+	`, deviceID))
+	return d, notFound(err)
+}
+
+func (r *pgDevices) Touch(ctx context.Context, deviceID, driverEmail string, at time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO devices (id, driver_email, status, last_update, created_at)
+		VALUES ($1, $2, 'active', $3, $3)
+		ON CONFLICT (id) DO UPDATE SET last_update = EXCLUDED.last_update
+	`, deviceID, driverEmail, at)
+	return err
+}
+
+func (r *pgDevices) AssignToUser(ctx context.Context, deviceID, email string, userID int) error {
+	if deviceID == "" {
+		return nil
+	}
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO devices (id, driver_email, user_id, status)
+		VALUES ($1, $2, $3, 'active')
+		ON CONFLICT (id) DO UPDATE SET
+		  driver_email = EXCLUDED.driver_email,
+		  user_id = EXCLUDED.user_id
+	`, deviceID, email, userID)
+	return err
+}
+
+func (r *pgDevices) PrimaryForUser(ctx context.Context, userID int) (string, error) {
+	var deviceID string
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id FROM devices
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+		LIMIT 1
+	`, userID).Scan(&deviceID)
+	if err != nil {
+		return "", notFound(err)
+	}
+	return deviceID, nil
+}
+
+// ================== DROWSINESS DATA ==================
+
+type pgDrowsiness struct{ db *sql.DB }
+
+const drowsinessColumns = `id, device_id, COALESCE(driver_id, 0), eye_closure, drowsiness_level, status,
+	COALESCE(fatigue_score, 0), COALESCE(fatigue_level, ''), schema_version, timestamp, created_at, ` + telemetryColumns
+
+// scanDrowsiness reads drowsinessColumns followed by any extra columns
+func scanDrowsiness(row interface{ Scan(...interface{}) error }, d *models.DrowsinessData, extra ...interface{}) error {
+	var t telemetryScan
+	dest := append([]interface{}{&d.ID, &d.DeviceID, &d.DriverID, &d.EyeClosure, &d.DrowsinessLevel, &d.Status,
+		&d.FatigueScore, &d.FatigueLevel, &d.SchemaVersion, &d.Timestamp, &d.CreatedAt}, t.dest()...)
+	dest = append(dest, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return err
+	}
+	var err error
+	d.Telemetry, err = t.telemetry()
+	return err
+}
+
+func (r *pgDrowsiness) Insert(ctx context.Context, d *models.DrowsinessData) error {
+	if d.SchemaVersion == 0 {
+		d.SchemaVersion = models.TelemetrySchemaV1
+	}
+	telemetry, err := telemetryArgs(d.Telemetry)
+	if err != nil {
+		return err
+	}
+	args := append([]interface{}{d.DeviceID, d.EyeClosure, d.DrowsinessLevel, d.Status,
+		d.FatigueScore, d.FatigueLevel, d.SchemaVersion, d.Timestamp, d.DriverID}, telemetry...)
+	return r.db.QueryRowContext(ctx, `
+		INSERT INTO drowsiness_data (device_id, eye_closure, drowsiness_level, status,
+			fatigue_score, fatigue_level, schema_version, timestamp, driver_id, `+telemetryColumns+`)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8, NULLIF($9, 0),
+			$10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22)
+		RETURNING id, created_at
+	`, args...).Scan(&d.ID, &d.CreatedAt)
+}
+
+func (r *pgDrowsiness) Latest(ctx context.Context, deviceID string) (*models.DrowsinessData, error) {
+	var d models.DrowsinessData
+	row := r.db.QueryRowContext(ctx, `
+		SELECT `+drowsinessColumns+`
+		FROM drowsiness_data
+		WHERE device_id = $1
+		ORDER BY timestamp DESC, id DESC
+		LIMIT 1
+	`, deviceID)
+	if err := scanDrowsiness(row, &d); err != nil {
+		return nil, notFound(err)
+	}
+	return &d, nil
+}
+
+func (r *pgDrowsiness) History(ctx context.Context, f PageFilter) ([]models.DrowsinessData, error) {
+	var args []interface{}
+	var where []string
+	if f.Level != "" {
+		args = append(args, strings.ToLower(f.Level))
+		where = append(where, fmt.Sprintf(`LOWER(drowsiness_level) = $%d`, len(args)))
+	}
+	if f.Status != "" {
+		args = append(args, f.Status)
+		where = append(where, fmt.Sprintf(`status = $%d`, len(args)))
+	}
+	query, args := pageQuery(`SELECT `+drowsinessColumns+` FROM drowsiness_data`, f, where, args)
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []models.DrowsinessData
+	for rows.Next() {
+		var d models.DrowsinessData
+		if err := scanDrowsiness(rows, &d); err != nil {
+			return nil, err
+		}
+		history = append(history, d)
+	}
+	if f.After != nil {
+		for i, j := 0, len(history)-1; i < j; i, j = i+1, j-1 {
+			history[i], history[j] = history[j], history[i]
+		}
+	}
+	return history, rows.Err()
+}
+
+func (r *pgDrowsiness) Between(ctx context.Context, deviceID string, tr TimeRange) ([]models.DrowsinessData, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+drowsinessColumns+`
+		FROM drowsiness_data
+		WHERE device_id = $1 AND timestamp >= $2 AND timestamp < $3
+		ORDER BY timestamp, id
+	`, deviceID, tr.From.UTC(), tr.To.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var data []models.DrowsinessData
+	for rows.Next() {
+		var d models.DrowsinessData
+		if err := scanDrowsiness(rows, &d); err != nil {
+			return nil, err
+		}
+		data = append(data, d)
+	}
+	return data, rows.Err()
+}
+
+func (r *pgDrowsiness) LastLocation(ctx context.Context, deviceID string, since time.Time) (*models.GPSFix, error) {
+	var fix models.GPSFix
+	var speed, heading sql.NullFloat64
+	err := r.db.QueryRowContext(ctx, `
+		SELECT latitude, longitude, speed_kmh, heading
+		FROM drowsiness_data
+		WHERE device_id = $1 AND timestamp >= $2 AND latitude IS NOT NULL AND longitude IS NOT NULL
+		ORDER BY timestamp DESC, id DESC
+		LIMIT 1
+	`, deviceID, since.UTC()).Scan(&fix.Latitude, &fix.Longitude, &speed, &heading)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if speed.Valid {
+		fix.SpeedKmh = &speed.Float64
+	}
+	if heading.Valid {
+		fix.Heading = &heading.Float64
+	}
+	return &fix, nil
+}
+
+// ================== ALERTS ==================
+
+type pgAlerts struct{ db *sql.DB }
+
+func (r *pgAlerts) Insert(ctx context.Context, a *models.Alert) error {
+	if a.Status == "" {
+		a.Status = "active"
+	}
+	var lat, lon interface{}
+	if a.Location != nil {
+		lat, lon = a.Location.Latitude, a.Location.Longitude
+	}
+	var vehicleID sql.NullInt64
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO alerts (device_id, alert_type, severity, timestamp, status, latitude, longitude, geofence_id,
+			driver_id, vehicle_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, 0), NULLIF($9, 0), `+fmt.Sprintf(vehicleAtSQL, "$1", "$4")+`)
+		RETURNING id, acknowledged, created_at, vehicle_id
+	`, a.DeviceID, a.AlertType, a.Severity, a.Timestamp, a.Status, lat, lon,
+		a.GeofenceID, a.DriverID).Scan(&a.ID, &a.Acknowledged, &a.CreatedAt, &vehicleID)
+	a.VehicleID = int(vehicleID.Int64)
+	return err
+}
+
+const alertColumns = `id, device_id, alert_type, severity, acknowledged, status, timestamp, created_at,
+	latitude, longitude, geofence_id, vehicle_id, driver_id`
+
+// scanAlert reads alertColumns followed by any extra columns
+func scanAlert(row interface{ Scan(...interface{}) error }, a *models.Alert, extra ...interface{}) error {
+	var lat, lon sql.NullFloat64
+	var geofenceID, vehicleID, driverID sql.NullInt64
+	dest := append([]interface{}{
+		&a.ID, &a.DeviceID, &a.AlertType, &a.Severity,
+		&a.Acknowledged, &a.Status, &a.Timestamp, &a.CreatedAt, &lat, &lon, &geofenceID, &vehicleID, &driverID,
+	}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return err
+	}
+	a.GeofenceID = int(geofenceID.Int64)
+	a.VehicleID = int(vehicleID.Int64)
+	a.DriverID = int(driverID.Int64)
+	if lat.Valid && lon.Valid {
+		a.Location = &models.GPSFix{Latitude: lat.Float64, Longitude: lon.Float64}
+	}
+	return nil
+}
+
+func scanAlerts(rows *sql.Rows) ([]models.Alert, error) {
+	defer rows.Close()
+	var alerts []models.Alert
+	for rows.Next() {
+		var a models.Alert
+		if err := scanAlert(rows, &a); err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, a)
+	}
+	return alerts, rows.Err()
+}
+
+func (r *pgAlerts) GetByID(ctx context.Context, id int) (*models.Alert, error) {
+	var a models.Alert
+	err := scanAlert(r.db.QueryRowContext(ctx, `SELECT `+alertColumns+` FROM alerts WHERE id = $1`, id), &a)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &a, nil
+}
+
+func (r *pgAlerts) ListByDevice(ctx context.Context, f PageFilter) ([]models.Alert, error) {
+	var args []interface{}
+	var where []string
+	if f.Severity != "" {
+		args = append(args, f.Severity)
+		where = append(where, fmt.Sprintf(`severity = $%d`, len(args)))
+	}
+	if f.Status != "" {
+		args = append(args, f.Status)
+		where = append(where, fmt.Sprintf(`status = $%d`, len(args)))
+	}
+	query, args := pageQuery(`SELECT `+alertColumns+` FROM alerts`, f, where, args)
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	alerts, err := scanAlerts(rows)
+	if f.After != nil {
+		for i, j := 0, len(alerts)-1; i < j; i, j = i+1, j-1 {
+			alerts[i], alerts[j] = alerts[j], alerts[i]
+		}
+	}
+	return alerts, err
+}
+
+func (r *pgAlerts) Between(ctx context.Context, deviceID string, tr TimeRange) ([]models.Alert, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+alertColumns+`
+		FROM alerts
+		WHERE device_id = $1 AND timestamp >= $2 AND timestamp < $3
+		ORDER BY timestamp, id
+	`, deviceID, tr.From.UTC(), tr.To.UTC())
+	if err != nil {
+		return nil, err
+	}
+	return scanAlerts(rows)
+}
+
+// ================== PASSWORD RESETS ==================
+
+type pgPasswordResets struct{ db *sql.DB }
+
+func (r *pgPasswordResets) Store(ctx context.Context, userID int, code string, expiresAt time.Time) error {
+	// Delete any existing reset codes for this user
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM password_resets WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO password_resets (user_id, reset_code, expires_at)
+		VALUES ($1, $2, $3)
+	`, userID, code, expiresAt)
+	return err
+}
+
+func (r *pgPasswordResets) Validate(ctx context.Context, email, code string, now time.Time) (*models.User, error) {
+	var u models.User
+	var name sql.NullString
+	err := r.db.QueryRowContext(ctx, `
+		SELECT u.id, u.email, u.name, u.role
+		FROM users u
+		JOIN password_resets pr ON u.id = pr.user_id
+		WHERE u.email = $1
+		  AND pr.reset_code = $2
+		  AND pr.expires_at > $3
+		  AND pr.used = FALSE
+	`, email, code, now).Scan(&u.ID, &u.Email, &name, &u.Role)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	u.Name = name.String
+	return &u, nil
+}
+
+func (r *pgPasswordResets) MarkUsed(ctx context.Context, userID int) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE password_resets SET used = TRUE WHERE user_id = $1
+	`, userID)
+	return err
+}
+
+// ================== FLEETS ==================
+
+type pgFleets struct{ db *sql.DB }
+
+func (r *pgFleets) Create(ctx context.Context, f *models.Fleet) error {
+	return r.db.QueryRowContext(ctx, `
+		INSERT INTO fleets (name, timezone)
+		VALUES ($1, $2)
+		RETURNING id, created_at
+	`, f.Name, f.Timezone).Scan(&f.ID, &f.CreatedAt)
+}
+
+func (r *pgFleets) GetByID(ctx context.Context, id int) (*models.Fleet, error) {
+	var f models.Fleet
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, name, timezone, created_at FROM fleets WHERE id = $1
+	`, id).Scan(&f.ID, &f.Name, &f.Timezone, &f.CreatedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &f, nil
+}
+
+func (r *pgFleets) List(ctx context.Context) ([]models.Fleet, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, name, timezone, created_at FROM fleets ORDER BY id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var fleets []models.Fleet
+	for rows.Next() {
+		var f models.Fleet
+		if err := rows.Scan(&f.ID, &f.Name, &f.Timezone, &f.CreatedAt); err != nil {
+			return nil, err
+		}
+		fleets = append(fleets, f)
+	}
+	return fleets, rows.Err()
+}
+
+func (r *pgFleets) Update(ctx context.Context, f *models.Fleet) error {
+	err := r.db.QueryRowContext(ctx, `
+		UPDATE fleets SET name = $1, timezone = $2 WHERE id = $3
+		RETURNING created_at
+	`, f.Name, f.Timezone, f.ID).Scan(&f.CreatedAt)
+	return notFound(err)
+}
+
+// ================== DASHBOARD ==================
+
+type pgDashboard struct{ db *sql.DB }
+
+// Timestamps are stored as UTC wall time and the day range is converted
+// to UTC by the caller, so plain comparisons honor any business timezone.
+const inDay = `dd.timestamp >= $2 AND dd.timestamp < $3`
+
+// deviceOnlineSQL decides whether device %[1]s (joined with its device_health
+// row as %[2]s) is online at $1: devices sending heartbeats follow the health
+// state machine, older firmware counts as online while updated in the last minute
+const deviceOnlineSQL = `CASE WHEN %[2]s.device_id IS NOT NULL THEN %[2]s.state <> 'offline'
+		ELSE %[1]s.last_update >= $1::timestamp - INTERVAL '1 minute' END`
+
+func (r *pgDashboard) Overview(ctx context.Context, now time.Time, day TimeRange) (models.AdminOverview, error) {
+	var o models.AdminOverview
+	err := r.db.QueryRowContext(ctx, `
+SELECT
+	COALESCE((SELECT COUNT(*) FROM users WHERE role = 'driver'), 0) AS total_drivers,
+	COALESCE((
+		SELECT COUNT(DISTINCT d.user_id)
+		FROM devices d
+		LEFT JOIN device_health h ON h.device_id = d.id
+		WHERE `+fmt.Sprintf(deviceOnlineSQL, "d", "h")+`
+		  AND d.user_id IS NOT NULL
+	), 0) AS active_drivers,
+	COALESCE((SELECT COUNT(*) FROM devices), 0) AS total_devices,
+	COALESCE((
+		SELECT COUNT(*)
+		FROM drowsiness_data dd
+		WHERE `+inDay+`
+		  AND LOWER(dd.drowsiness_level) = 'high'
+	), 0) AS alerts_today`, now.UTC(), day.From.UTC(), day.To.UTC(),
+	).Scan(&o.TotalDrivers, &o.ActiveDrivers, &o.TotalDevices, &o.AlertsToday)
+	o.CriticalAlertsToday = o.AlertsToday
+	return o, err
+}
+
+func (r *pgDashboard) OnlineDevices(ctx context.Context, now time.Time) (int, error) {
+	var n int
+	err := r.db.QueryRowContext(ctx, `
+SELECT COUNT(*)
+FROM devices d
+LEFT JOIN device_health h ON h.device_id = d.id
+WHERE `+fmt.Sprintf(deviceOnlineSQL, "d", "h"), now.UTC()).Scan(&n)
+	return n, err
+}
+
+func (r *pgDashboard) DriverSummaries(ctx context.Context, now time.Time, day TimeRange) ([]models.AdminDriverSummary, error) {
+	rows, err := r.db.QueryContext(ctx, `
+SELECT
+	u.id,
+	COALESCE(NULLIF(u.name, ''), u.email) AS name,
+	COALESCE(dev.device_id, '') AS device_id,
+	CASE
+		WHEN h.device_id IS NOT NULL THEN h.state <> 'offline'
+		WHEN (
+			(act.last_ts IS NOT NULL AND act.last_ts >= $1::timestamp - INTERVAL '1 minute')
+			OR (dev.last_update IS NOT NULL AND dev.last_update >= $1::timestamp - INTERVAL '1 minute')
+		) THEN TRUE
+		ELSE FALSE
+	END AS is_online,
+	COALESCE(h.state, '') AS device_state,
+	COALESCE(ac.critical_count, 0) AS critical_alerts_today
+FROM users u
+LEFT JOIN LATERAL (
+	-- The device of the driver's current shift, else the newest owned device
+	SELECT d.id AS device_id,
+	       d.last_update
+	FROM devices d
+	LEFT JOIN shifts sh ON sh.device_id = d.id AND sh.driver_id = u.id
+		AND sh.starts_at <= $1 AND (sh.ends_at IS NULL OR sh.ends_at > $1)
+	WHERE d.user_id = u.id OR sh.id IS NOT NULL
+	ORDER BY (sh.id IS NOT NULL) DESC, d.created_at DESC
+	LIMIT 1
+) dev ON TRUE
+LEFT JOIN device_health h ON h.device_id = dev.device_id
+LEFT JOIN LATERAL (
+	SELECT MAX(dd.timestamp) AS last_ts
+	FROM drowsiness_data dd
+	WHERE dd.device_id = dev.device_id
+		AND `+inDay+`
+) act ON TRUE
+LEFT JOIN LATERAL (
+	SELECT COUNT(*) AS critical_count
+	FROM drowsiness_data dd
+	JOIN devices d ON dd.device_id = d.id
+	WHERE COALESCE(dd.driver_id, d.user_id) = u.id
+		AND `+inDay+`
+		AND LOWER(dd.drowsiness_level) = 'high'
+) ac ON TRUE
+WHERE u.role = 'driver'
+ORDER BY u.id`, now.UTC(), day.From.UTC(), day.To.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []models.AdminDriverSummary
+	for rows.Next() {
+		var userID int
+		s := models.AdminDriverSummary{Source: "real"}
+		if err := rows.Scan(&userID, &s.Name, &s.DeviceID, &s.IsOnline, &s.DeviceState, &s.CriticalAlertsToday); err != nil {
+			return nil, err
+		}
+		s.ID = strconv.Itoa(userID)
+		results = append(results, s)
+	}
+	return results, rows.Err()
+}
+
+func (r *pgDashboard) RecentAlerts(ctx context.Context, limit int) ([]RecentAlertRow, error) {
+	rows, err := r.db.QueryContext(ctx, `
+SELECT
+	dd.timestamp,
+	COALESCE(NULLIF(u.name, ''), u.email) AS driver_name,
+	LOWER(dd.drowsiness_level),
+	d.id,
+	COALESCE(v.plate_number, '')
+FROM drowsiness_data dd
+JOIN devices d ON dd.device_id = d.id
+JOIN users u ON u.id = COALESCE(dd.driver_id, d.user_id)
+`+fmt.Sprintf(vehicleAtJoin, "dd.device_id", "dd.timestamp")+`
+WHERE LOWER(dd.drowsiness_level) IN ('medium', 'high')
+	AND u.role = 'driver'
+ORDER BY dd.timestamp DESC, dd.id DESC
+LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []RecentAlertRow
+	for rows.Next() {
+		var row RecentAlertRow
+		if err := rows.Scan(&row.Timestamp, &row.Driver, &row.Level, &row.DeviceID, &row.VehiclePlate); err != nil {
+			return nil, err
+		}
+		results = append(results, row)
+	}
+	return results, rows.Err()
+}
+
+func (r *pgDashboard) HighCountsByHour(ctx context.Context, day TimeRange, loc *time.Location) (map[int]int, error) {
+	// AT TIME ZONE with an IANA name applies the zone's DST rules per row
+	rows, err := r.db.QueryContext(ctx, `
+SELECT EXTRACT(HOUR FROM (dd.timestamp AT TIME ZONE 'UTC') AT TIME ZONE $1)::int AS hr, COUNT(*)
+FROM drowsiness_data dd
+WHERE `+inDay+`
+  AND LOWER(dd.drowsiness_level) = 'high'
+GROUP BY hr`, loc.String(), day.From.UTC(), day.To.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[int]int)
+	for rows.Next() {
+		var hour, count int
+		if err := rows.Scan(&hour, &count); err != nil {
+			return nil, err
+		}
+		counts[hour] = count
+	}
+	return counts, rows.Err()
+}
+
+func (r *pgDashboard) LevelCounts(ctx context.Context, day TimeRange) (high, medium int, err error) {
+	err = r.db.QueryRowContext(ctx, `
+SELECT
+	COUNT(*) FILTER (WHERE LOWER(dd.drowsiness_level) = 'high') AS high_total,
+	COUNT(*) FILTER (WHERE LOWER(dd.drowsiness_level) = 'medium') AS medium_total
+FROM drowsiness_data dd
+JOIN devices d ON dd.device_id = d.id
+JOIN users u ON u.id = COALESCE(dd.driver_id, d.user_id)
+WHERE u.role = 'driver'
+  AND dd.timestamp >= $1 AND dd.timestamp < $2`, day.From.UTC(), day.To.UTC()).Scan(&high, &medium)
+	return high, medium, err
+}
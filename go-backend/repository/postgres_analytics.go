@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type pgAnalytics struct{ db *sql.DB }
+
+// localTS converts the stored UTC wall time to the wall time of $1 (IANA name)
+const localTS = `((dd.timestamp AT TIME ZONE 'UTC') AT TIME ZONE $1)`
+
+var pgBucketExpr = map[Bucket]string{
+	Bucket15m: `date_trunc('hour', ` + localTS + `) + FLOOR(EXTRACT(MINUTE FROM ` + localTS + `) / 15)::int * INTERVAL '15 minutes'`,
+	Bucket1h:  `date_trunc('hour', ` + localTS + `)`,
+	Bucket1d:  `date_trunc('day', ` + localTS + `)`,
+	Bucket1w:  `date_trunc('week', ` + localTS + `)`,
+}
+
+var pgGroupExpr = map[string][2]string{
+	GroupNone:    {`''`, `''`},
+	GroupFleet:   {`COALESCE(u.fleet_id::text, '')`, `COALESCE(f.name, '')`},
+	GroupDriver:  {`COALESCE(u.id::text, '')`, `COALESCE(NULLIF(u.name, ''), u.email, '')`},
+	GroupDevice:  {`dd.device_id`, `dd.device_id`},
+	GroupVehicle: {`COALESCE(v.id::text, '')`, `COALESCE(v.plate_number, '')`},
+}
+
+// analyticsSamplesSQL is the live samples, one per row, along with the
+// hourly counts the purge rolled up, each taken at the start of its hour.
+// A rollup without an owner keeps driver_id 0 rather than falling back
+// to the device's current owner.
+const analyticsSamplesSQL = `
+	SELECT timestamp, device_id, driver_id, drowsiness_level, 1 AS n
+	FROM drowsiness_data
+	UNION ALL
+	SELECT h.hour, h.device_id, h.driver_id, l.level, l.n
+	FROM hourly_level_counts h
+	CROSS JOIN LATERAL (VALUES ('high', h.high), ('medium', h.medium), ('low', h.low)) AS l(level, n)
+	WHERE l.n > 0`
+
+func (r *pgAnalytics) CountSamples(ctx context.Context, q AnalyticsQuery) ([]AnalyticsRow, error) {
+	bucket, ok := pgBucketExpr[q.Bucket]
+	if !ok {
+		return nil, fmt.Errorf("analytics: unsupported bucket %q", q.Bucket)
+	}
+	group, ok := pgGroupExpr[q.GroupBy]
+	if !ok {
+		return nil, fmt.Errorf("analytics: unsupported grouping %q", q.GroupBy)
+	}
+
+	args := []interface{}{q.Location.String(), q.Range.From.UTC(), q.Range.To.UTC()}
+	where := []string{`dd.timestamp >= $2`, `dd.timestamp < $3`}
+	if len(q.Levels) > 0 {
+		args = append(args, pq.Array(q.Levels))
+		where = append(where, fmt.Sprintf(`LOWER(dd.drowsiness_level) = ANY($%d)`, len(args)))
+	}
+	if q.FleetID != 0 {
+		args = append(args, q.FleetID)
+		where = append(where, fmt.Sprintf(`u.fleet_id = $%d`, len(args)))
+	}
+	if q.DriverID != 0 {
+		args = append(args, q.DriverID)
+		where = append(where, fmt.Sprintf(`u.id = $%d`, len(args)))
+	}
+	if q.DeviceID != "" {
+		args = append(args, q.DeviceID)
+		where = append(where, fmt.Sprintf(`dd.device_id = $%d`, len(args)))
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+SELECT `+group[0]+` AS grp, MIN(`+group[1]+`) AS label, `+bucket+` AS bucket, SUM(dd.n)
+FROM (`+analyticsSamplesSQL+`) dd
+LEFT JOIN devices d ON dd.device_id = d.id
+LEFT JOIN users u ON u.id = COALESCE(dd.driver_id, d.user_id)
+LEFT JOIN fleets f ON u.fleet_id = f.id
+`+fmt.Sprintf(vehicleAtJoin, "dd.device_id", "dd.timestamp")+`
+WHERE `+strings.Join(where, " AND ")+`
+GROUP BY grp, bucket
+ORDER BY grp, bucket`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []AnalyticsRow
+	for rows.Next() {
+		var row AnalyticsRow
+		var wall time.Time
+		if err := rows.Scan(&row.Group, &row.Label, &wall, &row.Count); err != nil {
+			return nil, err
+		}
+		// The bucket comes back as a zone-less wall time of the query location
+		row.Bucket = time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), 0, 0, q.Location)
+		results = append(results, row)
+	}
+	return results, rows.Err()
+}
@@ -0,0 +1,245 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"driver-drowsiness-backend/models"
+)
+
+type pgCommands struct{ db *sql.DB }
+
+const commandColumns = `id, device_id, type, message, duration_seconds, status, issued_by, attempts, result,
+	created_at, expires_at, delivered_at, completed_at`
+
+func scanCommand(row interface{ Scan(...interface{}) error }) (*models.DeviceCommand, error) {
+	var c models.DeviceCommand
+	var issuedBy sql.NullInt64
+	var deliveredAt, completedAt sql.NullTime
+	err := row.Scan(&c.ID, &c.DeviceID, &c.Type, &c.Message, &c.DurationSeconds, &c.Status, &issuedBy,
+		&c.Attempts, &c.Result, &c.CreatedAt, &c.ExpiresAt, &deliveredAt, &completedAt)
+	if err != nil {
+		return nil, err
+	}
+	c.IssuedBy = int(issuedBy.Int64)
+	if deliveredAt.Valid {
+		c.DeliveredAt = &deliveredAt.Time
+	}
+	if completedAt.Valid {
+		c.CompletedAt = &completedAt.Time
+	}
+	return &c, nil
+}
+
+// recordCommandEvent appends to the audit trail of a command
+func recordCommandEvent(ctx context.Context, tx *sql.Tx, commandID int, status, actor, note string, at time.Time) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO device_command_events (command_id, status, actor, note, at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, commandID, status, actor, note, at.UTC())
+	return err
+}
+
+func (r *pgCommands) Create(ctx context.Context, cmd *models.DeviceCommand, actor string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	cmd.Status = models.CommandQueued
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO device_commands (device_id, type, message, duration_seconds, status, issued_by, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, 0), $7, $8)
+		RETURNING id
+	`, cmd.DeviceID, cmd.Type, cmd.Message, cmd.DurationSeconds, cmd.Status, cmd.IssuedBy,
+		cmd.CreatedAt.UTC(), cmd.ExpiresAt.UTC()).Scan(&cmd.ID)
+	if err != nil {
+		return err
+	}
+	if err := recordCommandEvent(ctx, tx, cmd.ID, cmd.Status, actor, "", cmd.CreatedAt); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *pgCommands) GetByID(ctx context.Context, id int) (*models.DeviceCommand, error) {
+	c, err := scanCommand(r.db.QueryRowContext(ctx, `SELECT `+commandColumns+` FROM device_commands WHERE id = $1`, id))
+	return c, notFound(err)
+}
+
+func (r *pgCommands) List(ctx context.Context, f CommandFilter) ([]models.DeviceCommand, error) {
+	var args []interface{}
+	var where []string
+	if f.DeviceID != "" {
+		args = append(args, f.DeviceID)
+		where = append(where, fmt.Sprintf(`device_id = $%d`, len(args)))
+	}
+	if f.Status != "" {
+		args = append(args, f.Status)
+		where = append(where, fmt.Sprintf(`status = $%d`, len(args)))
+	}
+	if !f.Range.From.IsZero() {
+		args = append(args, f.Range.From.UTC())
+		where = append(where, fmt.Sprintf(`created_at >= $%d`, len(args)))
+	}
+	if !f.Range.To.IsZero() {
+		args = append(args, f.Range.To.UTC())
+		where = append(where, fmt.Sprintf(`created_at < $%d`, len(args)))
+	}
+	query := `SELECT ` + commandColumns + ` FROM device_commands`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, ` AND `)
+	}
+	query += ` ORDER BY created_at DESC, id DESC`
+	if f.Limit > 0 {
+		args = append(args, f.Limit)
+		query += fmt.Sprintf(` LIMIT $%d`, len(args))
+	}
+	return r.query(ctx, query, args...)
+}
+
+func (r *pgCommands) query(ctx context.Context, query string, args ...interface{}) ([]models.DeviceCommand, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var commands []models.DeviceCommand
+	for rows.Next() {
+		c, err := scanCommand(rows)
+		if err != nil {
+			return nil, err
+		}
+		commands = append(commands, *c)
+	}
+	return commands, rows.Err()
+}
+
+func (r *pgCommands) Deliver(ctx context.Context, deviceID string, now, redeliverBefore time.Time) ([]models.DeviceCommand, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// SKIP LOCKED keeps concurrent polls of one device from delivering twice
+	rows, err := tx.QueryContext(ctx, `
+		WITH picked AS (
+			SELECT id FROM device_commands
+			WHERE device_id = $1 AND expires_at > $2
+			  AND (status = 'queued' OR (status = 'delivered' AND delivered_at < $3))
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE device_commands c
+		SET status = 'delivered', delivered_at = $2, attempts = c.attempts + 1
+		FROM picked
+		WHERE c.id = picked.id
+		RETURNING `+prefixed("c", commandColumns),
+		deviceID, now.UTC(), redeliverBefore.UTC())
+	if err != nil {
+		return nil, err
+	}
+	var commands []models.DeviceCommand
+	for rows.Next() {
+		c, err := scanCommand(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		commands = append(commands, *c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(commands, func(i, j int) bool { return commands[i].ID < commands[j].ID })
+	for _, c := range commands {
+		note := fmt.Sprintf("attempt %d", c.Attempts)
+		if err := recordCommandEvent(ctx, tx, c.ID, models.CommandDelivered, "device", note, now); err != nil {
+			return nil, err
+		}
+	}
+	return commands, tx.Commit()
+}
+
+func (r *pgCommands) Transition(ctx context.Context, id int, from []string, status, result, actor string, at time.Time) (*models.DeviceCommand, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var current string
+	if err := tx.QueryRowContext(ctx, `SELECT status FROM device_commands WHERE id = $1 FOR UPDATE`, id).Scan(&current); err != nil {
+		return nil, notFound(err)
+	}
+	allowed := false
+	for _, s := range from {
+		allowed = allowed || s == current
+	}
+	if !allowed {
+		return nil, ErrConflict
+	}
+
+	c, err := scanCommand(tx.QueryRowContext(ctx, `
+		UPDATE device_commands
+		SET status = $2, result = $3, completed_at = $4
+		WHERE id = $1
+		RETURNING `+commandColumns,
+		id, status, result, at.UTC()))
+	if err != nil {
+		return nil, err
+	}
+	if err := recordCommandEvent(ctx, tx, id, status, actor, result, at); err != nil {
+		return nil, err
+	}
+	return c, tx.Commit()
+}
+
+func (r *pgCommands) Expire(ctx context.Context, now time.Time) (int, error) {
+	res, err := r.db.ExecContext(ctx, `
+		WITH expired AS (
+			UPDATE device_commands
+			SET status = 'expired', completed_at = expires_at
+			WHERE status IN ('queued', 'delivered') AND expires_at <= $1
+			RETURNING id, expires_at
+		)
+		INSERT INTO device_command_events (command_id, status, actor, note, at)
+		SELECT id, 'expired', 'system', '', expires_at FROM expired
+	`, now.UTC())
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func (r *pgCommands) Events(ctx context.Context, commandID int) ([]models.CommandEvent, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, command_id, status, actor, note, at
+		FROM device_command_events
+		WHERE command_id = $1
+		ORDER BY at, id
+	`, commandID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []models.CommandEvent
+	for rows.Next() {
+		var e models.CommandEvent
+		if err := rows.Scan(&e.ID, &e.CommandID, &e.Status, &e.Actor, &e.Note, &e.At); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"driver-drowsiness-backend/models"
+
+	"github.com/lib/pq"
+)
+
+type pgCompliance struct{ db *sql.DB }
+
+func (r *pgCompliance) DrivingSamples(ctx context.Context, f DrivingFilter) ([]DrivingSample, error) {
+	args := []interface{}{f.Range.From.UTC(), f.Range.To.UTC()}
+	where := []string{`dd.timestamp >= $1`, `dd.timestamp < $2`}
+	if f.DriverID != 0 {
+		args = append(args, f.DriverID)
+		where = append(where, fmt.Sprintf(`u.id = $%d`, len(args)))
+	}
+	if f.FleetID != 0 {
+		args = append(args, f.FleetID)
+		where = append(where, fmt.Sprintf(`u.fleet_id = $%d`, len(args)))
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+SELECT u.id, dd.device_id, dd.timestamp
+FROM drowsiness_data dd
+LEFT JOIN devices d ON dd.device_id = d.id
+JOIN users u ON u.id = COALESCE(dd.driver_id, d.user_id)
+WHERE `+strings.Join(where, " AND ")+`
+ORDER BY u.id, dd.timestamp`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var samples []DrivingSample
+	for rows.Next() {
+		var s DrivingSample
+		if err := rows.Scan(&s.DriverID, &s.DeviceID, &s.Timestamp); err != nil {
+			return nil, err
+		}
+		samples = append(samples, s)
+	}
+	return samples, rows.Err()
+}
+
+func (r *pgCompliance) ArchiveDays(ctx context.Context, days []models.ComplianceArchiveDay) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, d := range days {
+		_, err := tx.ExecContext(ctx, `
+INSERT INTO compliance_days (day, driver_id, driving_seconds, exceeded, violations)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (day, driver_id) DO UPDATE
+SET driving_seconds = EXCLUDED.driving_seconds, exceeded = EXCLUDED.exceeded, violations = EXCLUDED.violations`,
+			d.Day.UTC(), d.DriverID, d.DrivingSeconds, d.Exceeded, d.Violations)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *pgCompliance) ArchivedDays(ctx context.Context, f DrivingFilter) ([]models.ComplianceArchiveDay, error) {
+	args := []interface{}{f.Range.From.UTC(), f.Range.To.UTC()}
+	where := []string{`cd.day >= $1`, `cd.day < $2`}
+	if f.DriverID != 0 {
+		args = append(args, f.DriverID)
+		where = append(where, fmt.Sprintf(`cd.driver_id = $%d`, len(args)))
+	}
+	if f.FleetID != 0 {
+		args = append(args, f.FleetID)
+		where = append(where, fmt.Sprintf(`u.fleet_id = $%d`, len(args)))
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+SELECT cd.day, cd.driver_id, cd.driving_seconds, cd.exceeded, cd.violations
+FROM compliance_days cd
+JOIN users u ON u.id = cd.driver_id
+WHERE `+strings.Join(where, " AND ")+`
+ORDER BY cd.driver_id, cd.day`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var days []models.ComplianceArchiveDay
+	for rows.Next() {
+		var d models.ComplianceArchiveDay
+		if err := rows.Scan(&d.Day, &d.DriverID, &d.DrivingSeconds, &d.Exceeded, &d.Violations); err != nil {
+			return nil, err
+		}
+		days = append(days, d)
+	}
+	return days, rows.Err()
+}
+
+func (r *pgCompliance) State(ctx context.Context, driverID int) (*models.ComplianceState, error) {
+	st := models.ComplianceState{DriverID: driverID}
+	err := r.db.QueryRowContext(ctx, `
+SELECT last_driving_at, continuous_seconds, day_start, daily_seconds, fired
+FROM compliance_state
+WHERE driver_id = $1`, driverID).Scan(&st.LastDrivingAt, &st.ContinuousSeconds, &st.DayStart, &st.DailySeconds, pq.Array(&st.Fired))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &st, nil
+}
+
+func (r *pgCompliance) SaveState(ctx context.Context, st models.ComplianceState) error {
+	_, err := r.db.ExecContext(ctx, `
+INSERT INTO compliance_state (driver_id, last_driving_at, continuous_seconds, day_start, daily_seconds, fired)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (driver_id) DO UPDATE
+SET last_driving_at = EXCLUDED.last_driving_at, continuous_seconds = EXCLUDED.continuous_seconds,
+	day_start = EXCLUDED.day_start, daily_seconds = EXCLUDED.daily_seconds, fired = EXCLUDED.fired`,
+		st.DriverID, st.LastDrivingAt.UTC(), st.ContinuousSeconds, st.DayStart.UTC(), st.DailySeconds, pq.Array(st.Fired))
+	return err
+}
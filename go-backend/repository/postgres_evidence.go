@@ -0,0 +1,199 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"driver-drowsiness-backend/models"
+)
+
+type pgEvidence struct{ db *sql.DB }
+
+const evidenceColumns = `id, alert_id, device_id, alert_type, severity, alert_at, kind, content_type, size, sha256,
+	storage_key, thumbnail_key, created_at, expires_at`
+
+func scanEvidence(row interface{ Scan(...interface{}) error }) (*models.Evidence, error) {
+	var e models.Evidence
+	err := row.Scan(&e.ID, &e.AlertID, &e.DeviceID, &e.AlertType, &e.Severity, &e.AlertAt, &e.Kind, &e.ContentType,
+		&e.Size, &e.SHA256, &e.StorageKey, &e.ThumbnailKey, &e.CreatedAt, &e.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (r *pgEvidence) queryEvidence(ctx context.Context, query string, args ...interface{}) ([]models.Evidence, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var evidence []models.Evidence
+	for rows.Next() {
+		e, err := scanEvidence(rows)
+		if err != nil {
+			return nil, err
+		}
+		evidence = append(evidence, *e)
+	}
+	return evidence, rows.Err()
+}
+
+func (r *pgEvidence) Create(ctx context.Context, e *models.Evidence) error {
+	return r.db.QueryRowContext(ctx, `
+		INSERT INTO evidence (alert_id, device_id, alert_type, severity, alert_at, kind, content_type, size, sha256,
+			storage_key, thumbnail_key, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		RETURNING id
+	`, e.AlertID, e.DeviceID, e.AlertType, e.Severity, e.AlertAt.UTC(), e.Kind, e.ContentType, e.Size, e.SHA256,
+		e.StorageKey, e.ThumbnailKey, e.CreatedAt.UTC(), e.ExpiresAt.UTC()).Scan(&e.ID)
+}
+
+func (r *pgEvidence) GetByID(ctx context.Context, id int) (*models.Evidence, error) {
+	e, err := scanEvidence(r.db.QueryRowContext(ctx, `SELECT `+evidenceColumns+` FROM evidence WHERE id = $1`, id))
+	return e, notFound(err)
+}
+
+func (r *pgEvidence) List(ctx context.Context, f EvidenceFilter) ([]models.Evidence, error) {
+	var args []interface{}
+	var where []string
+	if f.AlertID != 0 {
+		args = append(args, f.AlertID)
+		where = append(where, fmt.Sprintf(`alert_id = $%d`, len(args)))
+	}
+	if f.DeviceID != "" {
+		args = append(args, f.DeviceID)
+		where = append(where, fmt.Sprintf(`device_id = $%d`, len(args)))
+	}
+	if !f.Range.From.IsZero() {
+		args = append(args, f.Range.From.UTC())
+		where = append(where, fmt.Sprintf(`alert_at >= $%d`, len(args)))
+	}
+	if !f.Range.To.IsZero() {
+		args = append(args, f.Range.To.UTC())
+		where = append(where, fmt.Sprintf(`alert_at < $%d`, len(args)))
+	}
+	query := `SELECT ` + evidenceColumns + ` FROM evidence`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, ` AND `)
+	}
+	query += ` ORDER BY alert_at DESC, id DESC`
+	if f.Limit > 0 {
+		args = append(args, f.Limit)
+		query += fmt.Sprintf(` LIMIT $%d`, len(args))
+	}
+	return r.queryEvidence(ctx, query, args...)
+}
+
+func (r *pgEvidence) Delete(ctx context.Context, id int) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM evidence WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *pgEvidence) Expired(ctx context.Context, now time.Time, limit int) ([]models.Evidence, error) {
+	return r.queryEvidence(ctx, `
+		SELECT `+evidenceColumns+` FROM evidence
+		WHERE expires_at <= $1
+		ORDER BY expires_at, id
+		LIMIT $2
+	`, now.UTC(), limit)
+}
+
+const uploadColumns = `id, device_id, alert_id, content_type, size, received, created_at, updated_at,
+	alert_type, severity, alert_at, locked_until`
+
+func scanUpload(row interface{ Scan(...interface{}) error }) (*models.EvidenceUpload, error) {
+	var u models.EvidenceUpload
+	if err := row.Scan(&u.ID, &u.DeviceID, &u.AlertID, &u.ContentType, &u.Size, &u.Received, &u.CreatedAt, &u.UpdatedAt,
+		&u.AlertType, &u.Severity, &u.AlertAt, &u.LockedUntil); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (r *pgEvidence) CreateUpload(ctx context.Context, u *models.EvidenceUpload) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO evidence_uploads (`+uploadColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NULL)
+	`, u.ID, u.DeviceID, u.AlertID, u.ContentType, u.Size, u.Received, u.CreatedAt.UTC(), u.UpdatedAt.UTC(),
+		u.AlertType, u.Severity, u.AlertAt.UTC())
+	return err
+}
+
+func (r *pgEvidence) GetUpload(ctx context.Context, id string) (*models.EvidenceUpload, error) {
+	u, err := scanUpload(r.db.QueryRowContext(ctx, `SELECT `+uploadColumns+` FROM evidence_uploads WHERE id = $1`, id))
+	return u, notFound(err)
+}
+
+func (r *pgEvidence) AdvanceUpload(ctx context.Context, id string, from, to int64, at time.Time) error {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE evidence_uploads SET received = $3, updated_at = $4
+		WHERE id = $1 AND received = $2
+	`, id, from, to, at.UTC())
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+func (r *pgEvidence) ClaimUpload(ctx context.Context, id string, now, until time.Time) error {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE evidence_uploads SET locked_until = $3
+		WHERE id = $1 AND (locked_until IS NULL OR locked_until <= $2)
+	`, id, now.UTC(), until.UTC())
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		return nil
+	}
+	var exists bool
+	if err := r.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM evidence_uploads WHERE id = $1)`, id).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNotFound
+	}
+	return ErrConflict
+}
+
+func (r *pgEvidence) ReleaseUpload(ctx context.Context, id string, until time.Time) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE evidence_uploads SET locked_until = NULL WHERE id = $1 AND locked_until = $2`, id, until.UTC())
+	return err
+}
+
+func (r *pgEvidence) DeleteUpload(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM evidence_uploads WHERE id = $1`, id)
+	return err
+}
+
+func (r *pgEvidence) StaleUploads(ctx context.Context, before time.Time) ([]models.EvidenceUpload, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+uploadColumns+` FROM evidence_uploads WHERE updated_at < $1`, before.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var uploads []models.EvidenceUpload
+	for rows.Next() {
+		u, err := scanUpload(rows)
+		if err != nil {
+			return nil, err
+		}
+		uploads = append(uploads, *u)
+	}
+	return uploads, rows.Err()
+}
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+type pgExports struct{ db *sql.DB }
+
+// exportWhere appends the filter conditions on the joined driver (u) of
+// the table aliased t; args already hold the range bounds as $1 and $2
+func exportWhere(t string, f ExportFilter, args []interface{}) ([]string, []interface{}) {
+	where := []string{t + `.timestamp >= $1`, t + `.timestamp < $2`}
+	if f.FleetID != 0 {
+		args = append(args, f.FleetID)
+		where = append(where, fmt.Sprintf(`u.fleet_id = $%d`, len(args)))
+	}
+	if f.DriverID != 0 {
+		args = append(args, f.DriverID)
+		where = append(where, fmt.Sprintf(`u.id = $%d`, len(args)))
+	}
+	if f.DeviceID != "" {
+		args = append(args, f.DeviceID)
+		where = append(where, fmt.Sprintf(t+`.device_id = $%d`, len(args)))
+	}
+	return where, args
+}
+
+// exportNames are the display columns the inner export queries compute
+const exportNames = `COALESCE(NULLIF(u.name, ''), u.email, '') AS export_driver,
+	COALESCE(f.name, '') AS export_fleet, COALESCE(v.plate_number, '') AS export_plate`
+
+// EachSample iterates the result set as the driver reads it, so large
+// exports never hold more than one row in memory
+func (r *pgExports) EachSample(ctx context.Context, f ExportFilter, fn func(*SampleExportRow) error) error {
+	where, args := exportWhere("dd", f, []interface{}{f.Range.From.UTC(), f.Range.To.UTC()})
+	rows, err := r.db.QueryContext(ctx, `
+SELECT `+drowsinessColumns+`, export_driver, export_fleet, export_plate
+FROM (
+	SELECT dd.*, `+exportNames+`
+	FROM drowsiness_data dd
+	LEFT JOIN devices d ON dd.device_id = d.id
+	LEFT JOIN users u ON u.id = COALESCE(dd.driver_id, d.user_id)
+	LEFT JOIN fleets f ON u.fleet_id = f.id
+	`+fmt.Sprintf(vehicleAtJoin, "dd.device_id", "dd.timestamp")+`
+	WHERE `+strings.Join(where, " AND ")+`
+) samples
+ORDER BY timestamp, id`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row SampleExportRow
+		if err := scanDrowsiness(rows, &row.DrowsinessData, &row.DriverName, &row.FleetName, &row.VehiclePlate); err != nil {
+			return err
+		}
+		if err := fn(&row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (r *pgExports) EachAlert(ctx context.Context, f ExportFilter, fn func(*AlertExportRow) error) error {
+	where, args := exportWhere("a", f, []interface{}{f.Range.From.UTC(), f.Range.To.UTC()})
+	rows, err := r.db.QueryContext(ctx, `
+SELECT `+alertColumns+`, export_driver, export_fleet, export_plate
+FROM (
+	SELECT a.*, `+exportNames+`
+	FROM alerts a
+	LEFT JOIN devices d ON a.device_id = d.id
+	LEFT JOIN users u ON u.id = COALESCE(a.driver_id, d.user_id)
+	LEFT JOIN fleets f ON u.fleet_id = f.id
+	LEFT JOIN vehicles v ON v.id = a.vehicle_id
+	WHERE `+strings.Join(where, " AND ")+`
+) alerts
+ORDER BY timestamp, id`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row AlertExportRow
+		if err := scanAlert(rows, &row.Alert, &row.DriverName, &row.FleetName, &row.VehiclePlate); err != nil {
+			return err
+		}
+		if err := fn(&row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
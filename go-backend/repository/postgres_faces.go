@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"driver-drowsiness-backend/models"
+
+	"github.com/lib/pq"
+)
+
+type pgFaces struct{ db *sql.DB }
+
+func (r *pgFaces) Enroll(ctx context.Context, e *models.FaceEmbedding) error {
+	return r.db.QueryRowContext(ctx, `
+		INSERT INTO face_embeddings (user_id, model, embedding, created_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`, e.UserID, e.Model, pq.Float64Array(e.Vector), e.CreatedAt.UTC()).Scan(&e.ID)
+}
+
+func (r *pgFaces) List(ctx context.Context, userID int) ([]models.FaceEmbedding, error) {
+	return r.query(ctx, `
+		SELECT id, user_id, model, embedding, created_at
+		FROM face_embeddings
+		WHERE $1 = 0 OR user_id = $1
+		ORDER BY user_id, id
+	`, userID)
+}
+
+func (r *pgFaces) Candidates(ctx context.Context, model string, dimensions int) ([]models.FaceEmbedding, error) {
+	return r.query(ctx, `
+		SELECT id, user_id, model, embedding, created_at
+		FROM face_embeddings
+		WHERE model = $1 AND cardinality(embedding) = $2
+		ORDER BY user_id, id
+	`, model, dimensions)
+}
+
+func (r *pgFaces) query(ctx context.Context, query string, args ...interface{}) ([]models.FaceEmbedding, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var embeddings []models.FaceEmbedding
+	for rows.Next() {
+		var e models.FaceEmbedding
+		var vector pq.Float64Array
+		if err := rows.Scan(&e.ID, &e.UserID, &e.Model, &vector, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		e.Vector = vector
+		e.Dimensions = len(vector)
+		embeddings = append(embeddings, e)
+	}
+	return embeddings, rows.Err()
+}
+
+func (r *pgFaces) Delete(ctx context.Context, userID, id int) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM face_embeddings WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *pgFaces) DeleteAll(ctx context.Context, userID int) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM face_embeddings WHERE user_id = $1`, userID)
+	return err
+}
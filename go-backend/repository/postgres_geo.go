@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+type pgGeo struct{ db *sql.DB }
+
+func (r *pgGeo) Alerts(ctx context.Context, f GeoFilter) ([]LocatedAlert, error) {
+	args := []interface{}{f.Range.From.UTC(), f.Range.To.UTC()}
+	where := []string{`a.timestamp >= $1`, `a.timestamp < $2`, `a.latitude IS NOT NULL`}
+	if f.DeviceID != "" {
+		args = append(args, f.DeviceID)
+		where = append(where, fmt.Sprintf(`a.device_id = $%d`, len(args)))
+	}
+	if f.DriverID != 0 {
+		args = append(args, f.DriverID)
+		where = append(where, fmt.Sprintf(`COALESCE(a.driver_id, d.user_id) = $%d`, len(args)))
+	}
+	if f.FleetID != 0 {
+		args = append(args, f.FleetID)
+		where = append(where, fmt.Sprintf(`u.fleet_id = $%d`, len(args)))
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+prefixed("a", alertColumns)+`, COALESCE(a.driver_id, d.user_id, 0)
+		FROM alerts a
+		LEFT JOIN devices d ON d.id = a.device_id
+		LEFT JOIN users u ON u.id = COALESCE(a.driver_id, d.user_id)
+		WHERE `+strings.Join(where, ` AND `)+`
+		ORDER BY a.timestamp, a.id
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var alerts []LocatedAlert
+	for rows.Next() {
+		var la LocatedAlert
+		if err := scanAlert(rows, &la.Alert, &la.DriverID); err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, la)
+	}
+	return alerts, rows.Err()
+}
+
+// prefixed qualifies every column of a column list with a table alias
+func prefixed(alias, columns string) string {
+	parts := strings.Split(columns, ",")
+	for i, c := range parts {
+		parts[i] = alias + "." + strings.TrimSpace(c)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"driver-drowsiness-backend/models"
+)
+
+type pgGeofences struct{ db *sql.DB }
+
+const geofenceColumns = `id, name, kind, polygon, rules, created_at, updated_at`
+
+func scanGeofence(row interface{ Scan(...interface{}) error }) (*models.Geofence, error) {
+	var g models.Geofence
+	var polygon, rules []byte
+	if err := row.Scan(&g.ID, &g.Name, &g.Kind, &polygon, &rules, &g.CreatedAt, &g.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(polygon, &g.Polygon); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(rules, &g.Rules); err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+// geofenceJSON encodes the JSONB columns of a geofence
+func geofenceJSON(g *models.Geofence) (polygon, rules string, err error) {
+	if g.Rules == nil {
+		g.Rules = []models.GeofenceRule{}
+	}
+	p, err := json.Marshal(g.Polygon)
+	if err != nil {
+		return "", "", err
+	}
+	r, err := json.Marshal(g.Rules)
+	if err != nil {
+		return "", "", err
+	}
+	return string(p), string(r), nil
+}
+
+func (r *pgGeofences) List(ctx context.Context) ([]models.Geofence, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+geofenceColumns+` FROM geofences ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var geofences []models.Geofence
+	for rows.Next() {
+		g, err := scanGeofence(rows)
+		if err != nil {
+			return nil, err
+		}
+		geofences = append(geofences, *g)
+	}
+	return geofences, rows.Err()
+}
+
+func (r *pgGeofences) GetByID(ctx context.Context, id int) (*models.Geofence, error) {
+	g, err := scanGeofence(r.db.QueryRowContext(ctx, `SELECT `+geofenceColumns+` FROM geofences WHERE id = $1`, id))
+	return g, notFound(err)
+}
+
+func (r *pgGeofences) Create(ctx context.Context, g *models.Geofence) error {
+	polygon, rules, err := geofenceJSON(g)
+	if err != nil {
+		return err
+	}
+	return r.db.QueryRowContext(ctx, `
+		INSERT INTO geofences (name, kind, polygon, rules)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, updated_at
+	`, g.Name, g.Kind, polygon, rules).Scan(&g.ID, &g.CreatedAt, &g.UpdatedAt)
+}
+
+func (r *pgGeofences) Update(ctx context.Context, g *models.Geofence) error {
+	polygon, rules, err := geofenceJSON(g)
+	if err != nil {
+		return err
+	}
+	err = r.db.QueryRowContext(ctx, `
+		UPDATE geofences SET name = $2, kind = $3, polygon = $4, rules = $5, updated_at = NOW()
+		WHERE id = $1
+		RETURNING created_at, updated_at
+	`, g.ID, g.Name, g.Kind, polygon, rules).Scan(&g.CreatedAt, &g.UpdatedAt)
+	return notFound(err)
+}
+
+func (r *pgGeofences) Delete(ctx context.Context, id int) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM geofences WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *pgGeofences) InsertEvent(ctx context.Context, e *models.GeofenceEvent) error {
+	return r.db.QueryRowContext(ctx, `
+		INSERT INTO geofence_events (geofence_id, device_id, event, latitude, longitude, timestamp)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`, e.GeofenceID, e.DeviceID, e.Event, e.Latitude, e.Longitude, e.Timestamp.UTC()).Scan(&e.ID)
+}
+
+func (r *pgGeofences) Inside(ctx context.Context, deviceID string) ([]int, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT geofence_id FROM (
+			SELECT DISTINCT ON (geofence_id) geofence_id, event
+			FROM geofence_events
+			WHERE device_id = $1
+			ORDER BY geofence_id, timestamp DESC, id DESC
+		) latest
+		WHERE event = 'enter'
+		ORDER BY geofence_id
+	`, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (r *pgGeofences) Events(ctx context.Context, f GeofenceEventFilter) ([]models.GeofenceEvent, error) {
+	var args []interface{}
+	var where []string
+	if f.DeviceID != "" {
+		args = append(args, f.DeviceID)
+		where = append(where, fmt.Sprintf(`device_id = $%d`, len(args)))
+	}
+	if f.GeofenceID != 0 {
+		args = append(args, f.GeofenceID)
+		where = append(where, fmt.Sprintf(`geofence_id = $%d`, len(args)))
+	}
+	if !f.Range.From.IsZero() {
+		args = append(args, f.Range.From.UTC())
+		where = append(where, fmt.Sprintf(`timestamp >= $%d`, len(args)))
+	}
+	if !f.Range.To.IsZero() {
+		args = append(args, f.Range.To.UTC())
+		where = append(where, fmt.Sprintf(`timestamp < $%d`, len(args)))
+	}
+	query := `SELECT id, geofence_id, device_id, event, latitude, longitude, timestamp FROM geofence_events`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, ` AND `)
+	}
+	query += ` ORDER BY timestamp DESC, id DESC`
+	if f.Limit > 0 {
+		args = append(args, f.Limit)
+		query += fmt.Sprintf(` LIMIT $%d`, len(args))
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []models.GeofenceEvent
+	for rows.Next() {
+		var e models.GeofenceEvent
+		if err := rows.Scan(&e.ID, &e.GeofenceID, &e.DeviceID, &e.Event, &e.Latitude, &e.Longitude, &e.Timestamp); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
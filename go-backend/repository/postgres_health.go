@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"driver-drowsiness-backend/models"
+)
+
+type pgHealth struct{ db *sql.DB }
+
+const healthColumns = `device_id, state, reason, state_since, last_heartbeat, cpu_temp_c, camera_status, fps, app_version`
+
+func scanHealth(row interface{ Scan(...interface{}) error }) (*models.DeviceHealth, error) {
+	var h models.DeviceHealth
+	var cpu, fps sql.NullFloat64
+	err := row.Scan(&h.DeviceID, &h.State, &h.Reason, &h.StateSince, &h.LastHeartbeat,
+		&cpu, &h.CameraStatus, &fps, &h.AppVersion)
+	if err != nil {
+		return nil, err
+	}
+	if cpu.Valid {
+		h.CPUTempC = &cpu.Float64
+	}
+	if fps.Valid {
+		h.FPS = &fps.Float64
+	}
+	return &h, nil
+}
+
+func (r *pgHealth) Get(ctx context.Context, deviceID string) (*models.DeviceHealth, error) {
+	h, err := scanHealth(r.db.QueryRowContext(ctx, `SELECT `+healthColumns+` FROM device_health WHERE device_id = $1`, deviceID))
+	return h, notFound(err)
+}
+
+func (r *pgHealth) Save(ctx context.Context, h *models.DeviceHealth, event *models.DeviceStateEvent) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO device_health (`+healthColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (device_id) DO UPDATE SET
+			state = EXCLUDED.state, reason = EXCLUDED.reason, state_since = EXCLUDED.state_since,
+			last_heartbeat = EXCLUDED.last_heartbeat, cpu_temp_c = EXCLUDED.cpu_temp_c,
+			camera_status = EXCLUDED.camera_status, fps = EXCLUDED.fps, app_version = EXCLUDED.app_version
+	`, h.DeviceID, h.State, h.Reason, h.StateSince.UTC(), h.LastHeartbeat.UTC(),
+		h.CPUTempC, h.CameraStatus, h.FPS, h.AppVersion); err != nil {
+		return err
+	}
+	if event != nil {
+		if err := tx.QueryRowContext(ctx, `
+			INSERT INTO device_state_events (device_id, from_state, to_state, reason, at)
+			VALUES ($1, NULLIF($2, ''), $3, $4, $5)
+			RETURNING id
+		`, event.DeviceID, event.FromState, event.ToState, event.Reason, event.At.UTC()).Scan(&event.ID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *pgHealth) Stale(ctx context.Context, cutoff time.Time) ([]models.DeviceHealth, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+healthColumns+` FROM device_health
+		WHERE state <> $1 AND last_heartbeat < $2
+	`, models.DeviceOffline, cutoff.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stale []models.DeviceHealth
+	for rows.Next() {
+		h, err := scanHealth(rows)
+		if err != nil {
+			return nil, err
+		}
+		stale = append(stale, *h)
+	}
+	return stale, rows.Err()
+}
+
+const stateEventColumns = `id, device_id, COALESCE(from_state, ''), to_state, reason, at`
+
+func scanStateEvent(row interface{ Scan(...interface{}) error }) (*models.DeviceStateEvent, error) {
+	var e models.DeviceStateEvent
+	if err := row.Scan(&e.ID, &e.DeviceID, &e.FromState, &e.ToState, &e.Reason, &e.At); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (r *pgHealth) Events(ctx context.Context, f DeviceEventFilter) ([]models.DeviceStateEvent, error) {
+	var args []interface{}
+	var where []string
+	if f.DeviceID != "" {
+		args = append(args, f.DeviceID)
+		where = append(where, fmt.Sprintf(`device_id = $%d`, len(args)))
+	}
+	if !f.Range.From.IsZero() {
+		args = append(args, f.Range.From.UTC())
+		where = append(where, fmt.Sprintf(`at >= $%d`, len(args)))
+	}
+	if !f.Range.To.IsZero() {
+		args = append(args, f.Range.To.UTC())
+		where = append(where, fmt.Sprintf(`at < $%d`, len(args)))
+	}
+	query := `SELECT ` + stateEventColumns + ` FROM device_state_events`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, ` AND `)
+	}
+	query += ` ORDER BY at, id`
+	if f.Limit > 0 {
+		args = append(args, f.Limit)
+		query += fmt.Sprintf(` LIMIT $%d`, len(args))
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []models.DeviceStateEvent
+	for rows.Next() {
+		e, err := scanStateEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, *e)
+	}
+	return events, rows.Err()
+}
+
+func (r *pgHealth) LastEventBefore(ctx context.Context, deviceID string, t time.Time) (*models.DeviceStateEvent, error) {
+	e, err := scanStateEvent(r.db.QueryRowContext(ctx, `
+		SELECT `+stateEventColumns+` FROM device_state_events
+		WHERE device_id = $1 AND at < $2
+		ORDER BY at DESC, id DESC LIMIT 1
+	`, deviceID, t.UTC()))
+	return e, notFound(err)
+}
@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+)
+
+// pageQuery completes a listing of the (device_id, timestamp, id) rows of a
+// table with the device, range, keyset, order and limit of f. Pages after
+// f.After are read oldest first so the limit keeps the rows next to the
+// cursor; callers reverse them.
+func pageQuery(selectFrom string, f PageFilter, where []string, args []interface{}) (string, []interface{}) {
+	args = append(args, f.DeviceID)
+	where = append(where, fmt.Sprintf(`device_id = $%d`, len(args)))
+	if !f.Range.From.IsZero() {
+		args = append(args, f.Range.From.UTC())
+		where = append(where, fmt.Sprintf(`timestamp >= $%d`, len(args)))
+	}
+	if !f.Range.To.IsZero() {
+		args = append(args, f.Range.To.UTC())
+		where = append(where, fmt.Sprintf(`timestamp < $%d`, len(args)))
+	}
+	if f.Before != nil {
+		args = append(args, f.Before.Timestamp.UTC(), f.Before.ID)
+		where = append(where, fmt.Sprintf(`(timestamp, id) < ($%d, $%d)`, len(args)-1, len(args)))
+	}
+	order := `timestamp DESC, id DESC`
+	if f.After != nil {
+		args = append(args, f.After.Timestamp.UTC(), f.After.ID)
+		where = append(where, fmt.Sprintf(`(timestamp, id) > ($%d, $%d)`, len(args)-1, len(args)))
+		order = `timestamp, id`
+	}
+	query := selectFrom + ` WHERE ` + strings.Join(where, ` AND `) + ` ORDER BY ` + order
+	if f.Limit > 0 {
+		args = append(args, f.Limit)
+		query += fmt.Sprintf(` LIMIT $%d`, len(args))
+	}
+	return query, args
+}
@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"driver-drowsiness-backend/models"
+)
+
+type pgReports struct{ db *sql.DB }
+
+func (r *pgReports) HourlyCounts(ctx context.Context, f ExportFilter) ([]HourlyCount, error) {
+	args := []interface{}{f.Range.From.UTC(), f.Range.To.UTC()}
+	var where []string
+	if f.FleetID != 0 {
+		args = append(args, f.FleetID)
+		where = append(where, fmt.Sprintf(`u.fleet_id = $%d`, len(args)))
+	}
+	if f.DriverID != 0 {
+		args = append(args, f.DriverID)
+		where = append(where, fmt.Sprintf(`u.id = $%d`, len(args)))
+	}
+	filter := ""
+	if len(where) > 0 {
+		filter = `WHERE ` + strings.Join(where, " AND ")
+	}
+
+	// A purge rolls samples up and deletes them in one transaction,
+	// so each sample is counted either live or in a rollup
+	rows, err := r.db.QueryContext(ctx, `
+WITH counts AS (
+	SELECT date_trunc('hour', dd.timestamp) AS hour, COALESCE(dd.driver_id, d.user_id, 0) AS driver_id,
+		COUNT(*) FILTER (WHERE LOWER(dd.drowsiness_level) = 'high') AS high,
+		COUNT(*) FILTER (WHERE LOWER(dd.drowsiness_level) = 'medium') AS medium
+	FROM drowsiness_data dd
+	LEFT JOIN devices d ON dd.device_id = d.id
+	WHERE dd.timestamp >= $1 AND dd.timestamp < $2
+		AND LOWER(dd.drowsiness_level) IN ('medium', 'high')
+	GROUP BY 1, 2
+	UNION ALL
+	SELECT hour, driver_id, high, medium
+	FROM hourly_level_counts
+	WHERE hour >= $1 AND hour < $2
+)
+SELECT c.hour, c.driver_id, COALESCE(NULLIF(u.name, ''), u.email, ''), SUM(c.high), SUM(c.medium)
+FROM counts c
+LEFT JOIN users u ON u.id = c.driver_id
+`+filter+`
+GROUP BY c.hour, c.driver_id, u.name, u.email
+ORDER BY c.hour, c.driver_id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []HourlyCount
+	for rows.Next() {
+		var h HourlyCount
+		if err := rows.Scan(&h.Hour, &h.DriverID, &h.DriverName, &h.High, &h.Medium); err != nil {
+			return nil, err
+		}
+		counts = append(counts, h)
+	}
+	return counts, rows.Err()
+}
+
+const reportColumns = `id, kind, COALESCE(fleet_id, 0), COALESCE(driver_id, 0), title, timezone, period_from, period_to,
+	source, status, error, storage_key, size, pages, created_at, completed_at`
+
+func scanReport(row interface{ Scan(...interface{}) error }) (*models.Report, error) {
+	var rep models.Report
+	var completed sql.NullTime
+	err := row.Scan(&rep.ID, &rep.Kind, &rep.FleetID, &rep.DriverID, &rep.Title, &rep.Timezone, &rep.PeriodFrom,
+		&rep.PeriodTo, &rep.Source, &rep.Status, &rep.Error, &rep.StorageKey, &rep.Size, &rep.Pages, &rep.CreatedAt, &completed)
+	if err != nil {
+		return nil, err
+	}
+	if completed.Valid {
+		rep.CompletedAt = &completed.Time
+	}
+	return &rep, nil
+}
+
+func (r *pgReports) Create(ctx context.Context, rep *models.Report) error {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO reports (kind, fleet_id, driver_id, title, timezone, period_from, period_to, source, status, created_at)
+		VALUES ($1, NULLIF($2, 0), NULLIF($3, 0), $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id
+	`, rep.Kind, rep.FleetID, rep.DriverID, rep.Title, rep.Timezone, rep.PeriodFrom.UTC(), rep.PeriodTo.UTC(),
+		rep.Source, rep.Status, rep.CreatedAt.UTC()).Scan(&rep.ID)
+	return conflict(err)
+}
+
+func (r *pgReports) Update(ctx context.Context, rep *models.Report) error {
+	var completed interface{}
+	if rep.CompletedAt != nil {
+		completed = rep.CompletedAt.UTC()
+	}
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE reports SET status = $2, error = $3, storage_key = $4, size = $5, pages = $6, completed_at = $7
+		WHERE id = $1
+	`, rep.ID, rep.Status, rep.Error, rep.StorageKey, rep.Size, rep.Pages, completed)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *pgReports) GetByID(ctx context.Context, id int) (*models.Report, error) {
+	rep, err := scanReport(r.db.QueryRowContext(ctx, `SELECT `+reportColumns+` FROM reports WHERE id = $1`, id))
+	return rep, notFound(err)
+}
+
+func (r *pgReports) List(ctx context.Context, f ReportFilter) ([]models.Report, error) {
+	var args []interface{}
+	var where []string
+	if f.Kind != "" {
+		args = append(args, f.Kind)
+		where = append(where, fmt.Sprintf(`kind = $%d`, len(args)))
+	}
+	if f.FleetID != 0 {
+		args = append(args, f.FleetID)
+		where = append(where, fmt.Sprintf(`fleet_id = $%d`, len(args)))
+	}
+	if f.DriverID != 0 {
+		args = append(args, f.DriverID)
+		where = append(where, fmt.Sprintf(`driver_id = $%d`, len(args)))
+	}
+	query := `SELECT ` + reportColumns + ` FROM reports`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, ` AND `)
+	}
+	query += ` ORDER BY created_at DESC, id DESC`
+	if f.Limit > 0 {
+		args = append(args, f.Limit)
+		query += fmt.Sprintf(` LIMIT $%d`, len(args))
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reports []models.Report
+	for rows.Next() {
+		rep, err := scanReport(rows)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, *rep)
+	}
+	return reports, rows.Err()
+}
+
+func (r *pgReports) Delete(ctx context.Context, id int) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM reports WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+type pgRetention struct{ db *sql.DB }
+
+// purgeCutoffSQL is the cutoff of the fleet of the driver joined as u:
+// $1 and $2 pair fleet IDs with their cutoffs, $3 is the default
+const purgeCutoffSQL = `COALESCE((SELECT c.cutoff FROM unnest($1::int[], $2::timestamp[]) AS c(fleet_id, cutoff)
+	WHERE c.fleet_id = u.fleet_id), $3::timestamp)`
+
+func (r *pgRetention) Purge(ctx context.Context, c PurgeCutoffs) error {
+	fleets := make([]int64, 0, len(c.Fleets))
+	cutoffs := make([]string, 0, len(c.Fleets))
+	for id, t := range c.Fleets {
+		fleets = append(fleets, int64(id))
+		cutoffs = append(cutoffs, t.UTC().Format("2006-01-02 15:04:05"))
+	}
+	args := []interface{}{pq.Array(fleets), pq.Array(cutoffs), c.Default.UTC()}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Keep hourly level counts of the purged samples for reports and
+	// analytics, deleting and counting them in one statement
+	if _, err := tx.ExecContext(ctx, `
+WITH purged AS (
+	DELETE FROM drowsiness_data WHERE id IN (
+		SELECT dd.id
+		FROM drowsiness_data dd
+		LEFT JOIN devices d ON dd.device_id = d.id
+		LEFT JOIN users u ON u.id = COALESCE(dd.driver_id, d.user_id)
+		WHERE dd.timestamp < `+purgeCutoffSQL+`
+	)
+	RETURNING timestamp, device_id, driver_id, drowsiness_level
+)
+INSERT INTO hourly_level_counts (hour, device_id, driver_id, high, medium, low)
+SELECT date_trunc('hour', p.timestamp), p.device_id, COALESCE(p.driver_id, d.user_id, 0),
+	COUNT(*) FILTER (WHERE LOWER(p.drowsiness_level) = 'high'),
+	COUNT(*) FILTER (WHERE LOWER(p.drowsiness_level) = 'medium'),
+	COUNT(*) FILTER (WHERE LOWER(p.drowsiness_level) = 'low')
+FROM purged p
+LEFT JOIN devices d ON p.device_id = d.id
+WHERE LOWER(p.drowsiness_level) IN ('low', 'medium', 'high')
+GROUP BY 1, 2, 3
+ON CONFLICT (hour, device_id, driver_id) DO UPDATE
+SET high = hourly_level_counts.high + EXCLUDED.high, medium = hourly_level_counts.medium + EXCLUDED.medium,
+	low = hourly_level_counts.low + EXCLUDED.low`, args...); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `
+DELETE FROM alerts WHERE id IN (
+	SELECT a.id
+	FROM alerts a
+	LEFT JOIN devices d ON a.device_id = d.id
+	LEFT JOIN users u ON u.id = COALESCE(a.driver_id, d.user_id)
+	WHERE a.timestamp < `+purgeCutoffSQL+`
+)`, args...); err != nil {
+		return err
+	}
+	// Sessions whose samples are gone
+	if _, err := tx.ExecContext(ctx, `
+DELETE FROM driving_sessions WHERE id IN (
+	SELECT s.id
+	FROM driving_sessions s
+	LEFT JOIN devices d ON s.device_id = d.id
+	LEFT JOIN users u ON u.id = COALESCE(s.driver_id, d.user_id)
+	WHERE s.last_sample_at < `+purgeCutoffSQL+`
+)`, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"driver-drowsiness-backend/models"
+)
+
+type pgSessions struct{ db *sql.DB }
+
+// sessionColumns includes the alerts raised between start and last sample
+const sessionColumns = `s.id, s.device_id, s.driver_id, s.vehicle_id, s.started_at, s.ended_at, s.last_sample_at,
+	COALESCE(s.end_reason, ''), s.sample_count, s.low_count, s.medium_count, s.high_count,
+	s.longest_high_seconds, s.high_run_start, s.fatigue_score, s.max_fatigue_score,
+	s.blink_count, s.microsleep_count, s.microsleep_seconds, s.observed_seconds, s.closed_seconds,
+	s.identity, s.expected_driver_id, s.identity_similarity,
+	(SELECT COUNT(*) FROM alerts a
+	 WHERE a.device_id = s.device_id
+	   AND a.timestamp >= s.started_at
+	   AND a.timestamp <= COALESCE(s.ended_at, s.last_sample_at)) AS alert_count`
+
+func scanSession(row interface{ Scan(...interface{}) error }) (*models.DrivingSession, error) {
+	var s models.DrivingSession
+	var driverID, vehicleID sql.NullInt64
+	var endedAt, highRunStart sql.NullTime
+	var identity sql.NullString
+	var expectedDriverID sql.NullInt64
+	var similarity sql.NullFloat64
+	err := row.Scan(&s.ID, &s.DeviceID, &driverID, &vehicleID, &s.StartedAt, &endedAt, &s.LastSampleAt,
+		&s.EndReason, &s.SampleCount, &s.LowCount, &s.MediumCount, &s.HighCount,
+		&s.LongestHighSeconds, &highRunStart, &s.FatigueScore, &s.MaxFatigueScore,
+		&s.BlinkCount, &s.MicrosleepCount, &s.MicrosleepSeconds, &s.ObservedSeconds, &s.ClosedSeconds,
+		&identity, &expectedDriverID, &similarity, &s.AlertCount)
+	if err != nil {
+		return nil, err
+	}
+	s.DriverID = int(driverID.Int64)
+	s.VehicleID = int(vehicleID.Int64)
+	if endedAt.Valid {
+		s.EndedAt = &endedAt.Time
+	}
+	if highRunStart.Valid {
+		s.HighRunStart = &highRunStart.Time
+	}
+	if identity.Valid {
+		s.Identity = &models.IdentityCheck{
+			Status:           identity.String,
+			ExpectedDriverID: int(expectedDriverID.Int64),
+			DriverID:         s.DriverID,
+		}
+		if similarity.Valid {
+			s.Identity.Similarity = &similarity.Float64
+		}
+	}
+	return &s, nil
+}
+
+// Create inserts a new, usually empty, session; counters are saved by Update.
+// The vehicle is the one the device was mounted in when the session started.
+func (r *pgSessions) Create(ctx context.Context, s *models.DrivingSession) error {
+	var vehicleID sql.NullInt64
+	if err := r.db.QueryRowContext(ctx, `
+		INSERT INTO driving_sessions (device_id, driver_id, started_at, last_sample_at, vehicle_id)
+		VALUES ($1, NULLIF($2, 0), $3, $4, `+fmt.Sprintf(vehicleAtSQL, "$1", "$3")+`)
+		RETURNING id, vehicle_id
+	`, s.DeviceID, s.DriverID, s.StartedAt, s.LastSampleAt).Scan(&s.ID, &vehicleID); err != nil {
+		return err
+	}
+	s.VehicleID = int(vehicleID.Int64)
+	return r.Update(ctx, s)
+}
+
+func (r *pgSessions) Update(ctx context.Context, s *models.DrivingSession) error {
+	var identity, expectedDriverID, similarity interface{}
+	if s.Identity != nil {
+		identity, expectedDriverID = s.Identity.Status, s.Identity.ExpectedDriverID
+		if s.Identity.Similarity != nil {
+			similarity = *s.Identity.Similarity
+		}
+	}
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE driving_sessions SET
+			ended_at = $2, last_sample_at = $3, end_reason = NULLIF($4, ''),
+			sample_count = $5, low_count = $6, medium_count = $7, high_count = $8,
+			longest_high_seconds = $9, high_run_start = $10, driver_id = NULLIF($11, 0),
+			fatigue_score = $12, max_fatigue_score = $13, blink_count = $14,
+			microsleep_count = $15, microsleep_seconds = $16,
+			observed_seconds = $17, closed_seconds = $18,
+			identity = $19, expected_driver_id = NULLIF($20, 0), identity_similarity = $21
+		WHERE id = $1
+	`, s.ID, s.EndedAt, s.LastSampleAt, s.EndReason, s.SampleCount, s.LowCount,
+		s.MediumCount, s.HighCount, s.LongestHighSeconds, s.HighRunStart, s.DriverID,
+		s.FatigueScore, s.MaxFatigueScore, s.BlinkCount,
+		s.MicrosleepCount, s.MicrosleepSeconds,
+		s.ObservedSeconds, s.ClosedSeconds,
+		identity, expectedDriverID, similarity)
+	return err
+}
+
+func (r *pgSessions) Open(ctx context.Context, deviceID string) (*models.DrivingSession, error) {
+	s, err := scanSession(r.db.QueryRowContext(ctx, `
+		SELECT `+sessionColumns+`
+		FROM driving_sessions s
+		WHERE s.device_id = $1 AND s.ended_at IS NULL
+		ORDER BY s.started_at DESC
+		LIMIT 1
+	`, deviceID))
+	return s, notFound(err)
+}
+
+func (r *pgSessions) GetByID(ctx context.Context, id int) (*models.DrivingSession, error) {
+	s, err := scanSession(r.db.QueryRowContext(ctx, `
+		SELECT `+sessionColumns+` FROM driving_sessions s WHERE s.id = $1
+	`, id))
+	return s, notFound(err)
+}
+
+func (r *pgSessions) List(ctx context.Context, f SessionFilter) ([]models.DrivingSession, error) {
+	var args []interface{}
+	var where []string
+	if f.DeviceID != "" {
+		args = append(args, f.DeviceID)
+		where = append(where, fmt.Sprintf(`s.device_id = $%d`, len(args)))
+	}
+	if f.DriverID != 0 {
+		args = append(args, f.DriverID)
+		where = append(where, fmt.Sprintf(`s.driver_id = $%d`, len(args)))
+	}
+	if !f.Range.From.IsZero() {
+		args = append(args, f.Range.From.UTC())
+		where = append(where, fmt.Sprintf(`COALESCE(s.ended_at, s.last_sample_at) >= $%d`, len(args)))
+	}
+	if !f.Range.To.IsZero() {
+		args = append(args, f.Range.To.UTC())
+		where = append(where, fmt.Sprintf(`s.started_at < $%d`, len(args)))
+	}
+	query := `SELECT ` + sessionColumns + ` FROM driving_sessions s`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, ` AND `)
+	}
+	query += ` ORDER BY s.started_at DESC, s.id DESC`
+	if f.Limit > 0 {
+		args = append(args, f.Limit)
+		query += fmt.Sprintf(` LIMIT $%d`, len(args))
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []models.DrivingSession
+	for rows.Next() {
+		s, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, *s)
+	}
+	return sessions, rows.Err()
+}
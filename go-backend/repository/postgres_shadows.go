@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"driver-drowsiness-backend/models"
+)
+
+type pgShadows struct{ db *sql.DB }
+
+const shadowColumns = `device_id, desired, version, updated_at, reported, reported_version, reported_at`
+
+func scanShadow(row interface{ Scan(...interface{}) error }) (*models.DeviceShadow, error) {
+	var s models.DeviceShadow
+	var desired, reported []byte
+	var updatedAt, reportedAt sql.NullTime
+	if err := row.Scan(&s.DeviceID, &desired, &s.Version, &updatedAt, &reported, &s.ReportedVersion, &reportedAt); err != nil {
+		return nil, err
+	}
+	s.Desired = models.DefaultDeviceConfig()
+	if desired != nil {
+		if err := json.Unmarshal(desired, &s.Desired); err != nil {
+			return nil, err
+		}
+	}
+	if reported != nil {
+		s.Reported = &models.DeviceConfig{}
+		if err := json.Unmarshal(reported, s.Reported); err != nil {
+			return nil, err
+		}
+	}
+	if updatedAt.Valid {
+		s.UpdatedAt = &updatedAt.Time
+	}
+	if reportedAt.Valid {
+		s.ReportedAt = &reportedAt.Time
+	}
+	return &s, nil
+}
+
+func (r *pgShadows) Get(ctx context.Context, deviceID string) (*models.DeviceShadow, error) {
+	s, err := scanShadow(r.db.QueryRowContext(ctx, `SELECT `+shadowColumns+` FROM device_shadows WHERE device_id = $1`, deviceID))
+	return s, notFound(err)
+}
+
+func (r *pgShadows) SetDesired(ctx context.Context, deviceID string, cfg models.DeviceConfig, at time.Time) (*models.DeviceShadow, error) {
+	desired, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return scanShadow(r.db.QueryRowContext(ctx, `
+		INSERT INTO device_shadows (device_id, desired, version, updated_at)
+		VALUES ($1, $2, 1, $3)
+		ON CONFLICT (device_id) DO UPDATE SET
+			desired = EXCLUDED.desired,
+			version = device_shadows.version + 1,
+			updated_at = EXCLUDED.updated_at
+		RETURNING `+shadowColumns,
+		deviceID, string(desired), at.UTC()))
+}
+
+func (r *pgShadows) Report(ctx context.Context, deviceID string, report models.ConfigReport, at time.Time) (*models.DeviceShadow, error) {
+	reported, err := json.Marshal(report.Config)
+	if err != nil {
+		return nil, err
+	}
+	return scanShadow(r.db.QueryRowContext(ctx, `
+		INSERT INTO device_shadows (device_id, desired, version, reported, reported_version, reported_at)
+		VALUES ($1, $2, 0, $2, $3, $4)
+		ON CONFLICT (device_id) DO UPDATE SET
+			reported = EXCLUDED.reported,
+			reported_version = EXCLUDED.reported_version,
+			reported_at = EXCLUDED.reported_at
+		RETURNING `+shadowColumns,
+		deviceID, string(reported), report.Version, at.UTC()))
+}
+
+func (r *pgShadows) List(ctx context.Context, f ShadowFilter) ([]models.DeviceShadow, error) {
+	var args []interface{}
+	var where []string
+	if f.FleetID != 0 {
+		args = append(args, f.FleetID)
+		where = append(where, fmt.Sprintf(`u.fleet_id = $%d`, len(args)))
+	}
+	if f.DeviceID != "" {
+		args = append(args, f.DeviceID)
+		where = append(where, fmt.Sprintf(`d.id = $%d`, len(args)))
+	}
+	query := `
+		SELECT d.id, s.desired, COALESCE(s.version, 0), s.updated_at,
+		       s.reported, COALESCE(s.reported_version, 0), s.reported_at
+		FROM devices d
+		LEFT JOIN device_shadows s ON s.device_id = d.id
+		LEFT JOIN users u ON u.id = d.user_id`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, ` AND `)
+	}
+	query += ` ORDER BY d.id`
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var shadows []models.DeviceShadow
+	for rows.Next() {
+		s, err := scanShadow(rows)
+		if err != nil {
+			return nil, err
+		}
+		shadows = append(shadows, *s)
+	}
+	return shadows, rows.Err()
+}
@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"driver-drowsiness-backend/models"
+)
+
+type pgShifts struct{ db *sql.DB }
+
+const shiftColumns = `id, driver_id, vehicle_id, device_id, starts_at, ends_at, notes, created_at`
+
+// shiftCovers matches shifts covering the instant $2
+const shiftCovers = `starts_at <= $2 AND (ends_at IS NULL OR ends_at > $2)`
+
+func scanShift(row interface{ Scan(...interface{}) error }) (*models.Shift, error) {
+	var s models.Shift
+	var endsAt sql.NullTime
+	if err := row.Scan(&s.ID, &s.DriverID, &s.VehicleID, &s.DeviceID, &s.StartsAt, &endsAt, &s.Notes, &s.CreatedAt); err != nil {
+		return nil, err
+	}
+	if endsAt.Valid {
+		s.EndsAt = &endsAt.Time
+	}
+	return &s, nil
+}
+
+func (r *pgShifts) List(ctx context.Context, f ShiftFilter) ([]models.Shift, error) {
+	var args []interface{}
+	var where []string
+	if f.DriverID != 0 {
+		args = append(args, f.DriverID)
+		where = append(where, fmt.Sprintf(`driver_id = $%d`, len(args)))
+	}
+	if f.VehicleID != 0 {
+		args = append(args, f.VehicleID)
+		where = append(where, fmt.Sprintf(`vehicle_id = $%d`, len(args)))
+	}
+	if f.DeviceID != "" {
+		args = append(args, f.DeviceID)
+		where = append(where, fmt.Sprintf(`device_id = $%d`, len(args)))
+	}
+	if !f.Range.From.IsZero() {
+		args = append(args, f.Range.From.UTC())
+		where = append(where, fmt.Sprintf(`(ends_at IS NULL OR ends_at > $%d)`, len(args)))
+	}
+	if !f.Range.To.IsZero() {
+		args = append(args, f.Range.To.UTC())
+		where = append(where, fmt.Sprintf(`starts_at < $%d`, len(args)))
+	}
+	query := `SELECT ` + shiftColumns + ` FROM shifts`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, ` AND `)
+	}
+	query += ` ORDER BY starts_at DESC, id DESC`
+	if f.Limit > 0 {
+		args = append(args, f.Limit)
+		query += fmt.Sprintf(` LIMIT $%d`, len(args))
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var shifts []models.Shift
+	for rows.Next() {
+		s, err := scanShift(rows)
+		if err != nil {
+			return nil, err
+		}
+		shifts = append(shifts, *s)
+	}
+	return shifts, rows.Err()
+}
+
+func (r *pgShifts) GetByID(ctx context.Context, id int) (*models.Shift, error) {
+	s, err := scanShift(r.db.QueryRowContext(ctx, `SELECT `+shiftColumns+` FROM shifts WHERE id = $1`, id))
+	return s, notFound(err)
+}
+
+// checkOverlap locks the shifts table for writes and returns ErrConflict when
+// the driver or device of s is already booked during its period
+func (r *pgShifts) checkOverlap(ctx context.Context, tx *sql.Tx, s *models.Shift) error {
+	if _, err := tx.ExecContext(ctx, `LOCK TABLE shifts IN SHARE ROW EXCLUSIVE MODE`); err != nil {
+		return err
+	}
+	var taken bool
+	err := tx.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM shifts
+			WHERE id <> $1 AND (driver_id = $2 OR device_id = $3)
+			  AND (ends_at IS NULL OR ends_at > $4)
+			  AND ($5::timestamp IS NULL OR starts_at < $5)
+		)
+	`, s.ID, s.DriverID, s.DeviceID, s.StartsAt.UTC(), s.EndsAt).Scan(&taken)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrConflict
+	}
+	return nil
+}
+
+func (r *pgShifts) Create(ctx context.Context, s *models.Shift) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := r.checkOverlap(ctx, tx, s); err != nil {
+		return err
+	}
+	if err := tx.QueryRowContext(ctx, `
+		INSERT INTO shifts (driver_id, vehicle_id, device_id, starts_at, ends_at, notes)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`, s.DriverID, s.VehicleID, s.DeviceID, s.StartsAt.UTC(), s.EndsAt, s.Notes).Scan(&s.ID, &s.CreatedAt); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *pgShifts) Update(ctx context.Context, s *models.Shift) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := r.checkOverlap(ctx, tx, s); err != nil {
+		return err
+	}
+	err = tx.QueryRowContext(ctx, `
+		UPDATE shifts SET driver_id = $2, vehicle_id = $3, device_id = $4,
+			starts_at = $5, ends_at = $6, notes = $7
+		WHERE id = $1
+		RETURNING created_at
+	`, s.ID, s.DriverID, s.VehicleID, s.DeviceID, s.StartsAt.UTC(), s.EndsAt, s.Notes).Scan(&s.CreatedAt)
+	if err != nil {
+		return notFound(err)
+	}
+	return tx.Commit()
+}
+
+func (r *pgShifts) Delete(ctx context.Context, id int) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM shifts WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *pgShifts) OnDevice(ctx context.Context, deviceID string, at time.Time) (*models.Shift, error) {
+	s, err := scanShift(r.db.QueryRowContext(ctx, `
+		SELECT `+shiftColumns+` FROM shifts
+		WHERE device_id = $1 AND `+shiftCovers+`
+		ORDER BY starts_at DESC LIMIT 1
+	`, deviceID, at.UTC()))
+	return s, notFound(err)
+}
+
+func (r *pgShifts) OnDriver(ctx context.Context, driverID int, at time.Time) (*models.Shift, error) {
+	s, err := scanShift(r.db.QueryRowContext(ctx, `
+		SELECT `+shiftColumns+` FROM shifts
+		WHERE driver_id = $1 AND `+shiftCovers+`
+		ORDER BY starts_at DESC LIMIT 1
+	`, driverID, at.UTC()))
+	return s, notFound(err)
+}
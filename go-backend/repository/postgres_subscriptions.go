@@ -0,0 +1,212 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"driver-drowsiness-backend/models"
+)
+
+type pgSubscriptions struct{ db *sql.DB }
+
+const subscriptionColumns = `id, user_id, fleet_id, digest, format, channel, target, secret, lang, active, next_run_at, created_at`
+
+func scanSubscription(row interface{ Scan(...interface{}) error }) (*models.Subscription, error) {
+	var s models.Subscription
+	err := row.Scan(&s.ID, &s.UserID, &s.FleetID, &s.Digest, &s.Format, &s.Channel, &s.Target, &s.Secret,
+		&s.Lang, &s.Active, &s.NextRunAt, &s.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *pgSubscriptions) querySubscriptions(ctx context.Context, query string, args ...interface{}) ([]models.Subscription, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subs []models.Subscription
+	for rows.Next() {
+		s, err := scanSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, *s)
+	}
+	return subs, rows.Err()
+}
+
+func (r *pgSubscriptions) Create(ctx context.Context, s *models.Subscription) error {
+	return r.db.QueryRowContext(ctx, `
+		INSERT INTO report_subscriptions (user_id, fleet_id, digest, format, channel, target, secret, lang, active, next_run_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id
+	`, s.UserID, s.FleetID, s.Digest, s.Format, s.Channel, s.Target, s.Secret, s.Lang, s.Active,
+		s.NextRunAt.UTC(), s.CreatedAt.UTC()).Scan(&s.ID)
+}
+
+func (r *pgSubscriptions) GetByID(ctx context.Context, id int) (*models.Subscription, error) {
+	s, err := scanSubscription(r.db.QueryRowContext(ctx,
+		`SELECT `+subscriptionColumns+` FROM report_subscriptions WHERE id = $1`, id))
+	return s, notFound(err)
+}
+
+func (r *pgSubscriptions) List(ctx context.Context, f SubscriptionFilter) ([]models.Subscription, error) {
+	var args []interface{}
+	var where []string
+	if f.UserID != 0 {
+		args = append(args, f.UserID)
+		where = append(where, fmt.Sprintf(`user_id = $%d`, len(args)))
+	}
+	if f.FleetID != 0 {
+		args = append(args, f.FleetID)
+		where = append(where, fmt.Sprintf(`fleet_id = $%d`, len(args)))
+	}
+	query := `SELECT ` + subscriptionColumns + ` FROM report_subscriptions`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, ` AND `)
+	}
+	return r.querySubscriptions(ctx, query+` ORDER BY id`, args...)
+}
+
+func (r *pgSubscriptions) Update(ctx context.Context, s *models.Subscription) error {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE report_subscriptions
+		SET format = $2, channel = $3, target = $4, secret = $5, lang = $6, active = $7, next_run_at = $8
+		WHERE id = $1
+	`, s.ID, s.Format, s.Channel, s.Target, s.Secret, s.Lang, s.Active, s.NextRunAt.UTC())
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *pgSubscriptions) Delete(ctx context.Context, id int) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM report_subscriptions WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *pgSubscriptions) Due(ctx context.Context, now time.Time, limit int) ([]models.Subscription, error) {
+	return r.querySubscriptions(ctx, `
+		SELECT `+subscriptionColumns+` FROM report_subscriptions
+		WHERE active AND next_run_at <= $1
+		ORDER BY next_run_at, id
+		LIMIT $2
+	`, now.UTC(), limit)
+}
+
+func (r *pgSubscriptions) Advance(ctx context.Context, id int, from, to time.Time) (bool, error) {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE report_subscriptions SET next_run_at = $3 WHERE id = $1 AND next_run_at = $2`,
+		id, from.UTC(), to.UTC())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+const deliveryColumns = `id, subscription_id, period_from, period_to, status, attempts, error,
+	next_attempt_at, last_attempt_at, delivered_at, created_at`
+
+func scanDelivery(row interface{ Scan(...interface{}) error }) (*models.Delivery, error) {
+	var d models.Delivery
+	var last, delivered sql.NullTime
+	err := row.Scan(&d.ID, &d.SubscriptionID, &d.PeriodFrom, &d.PeriodTo, &d.Status, &d.Attempts, &d.Error,
+		&d.NextAttemptAt, &last, &delivered, &d.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if last.Valid {
+		d.LastAttemptAt = &last.Time
+	}
+	if delivered.Valid {
+		d.DeliveredAt = &delivered.Time
+	}
+	return &d, nil
+}
+
+func (r *pgSubscriptions) queryDeliveries(ctx context.Context, query string, args ...interface{}) ([]models.Delivery, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []models.Delivery
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, *d)
+	}
+	return deliveries, rows.Err()
+}
+
+func (r *pgSubscriptions) CreateDelivery(ctx context.Context, d *models.Delivery) error {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO subscription_deliveries (subscription_id, period_from, period_to, status, next_attempt_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`, d.SubscriptionID, d.PeriodFrom.UTC(), d.PeriodTo.UTC(), d.Status, d.NextAttemptAt.UTC(), d.CreatedAt.UTC()).Scan(&d.ID)
+	return conflict(err)
+}
+
+// ClaimDeliveries skips rows another replica is claiming at the same moment
+func (r *pgSubscriptions) ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]models.Delivery, error) {
+	return r.queryDeliveries(ctx, `
+		UPDATE subscription_deliveries
+		SET attempts = attempts + 1, last_attempt_at = $1, next_attempt_at = $2
+		WHERE id IN (
+			SELECT id FROM subscription_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= $1
+			ORDER BY next_attempt_at, id
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+deliveryColumns,
+		now.UTC(), now.Add(lease).UTC(), limit)
+}
+
+func (r *pgSubscriptions) UpdateDelivery(ctx context.Context, d *models.Delivery) error {
+	var delivered interface{}
+	if d.DeliveredAt != nil {
+		delivered = d.DeliveredAt.UTC()
+	}
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE subscription_deliveries SET status = $2, error = $3, next_attempt_at = $4, delivered_at = $5
+		WHERE id = $1
+	`, d.ID, d.Status, d.Error, d.NextAttemptAt.UTC(), delivered)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *pgSubscriptions) ListDeliveries(ctx context.Context, subscriptionID, limit int) ([]models.Delivery, error) {
+	return r.queryDeliveries(ctx, `
+		SELECT `+deliveryColumns+` FROM subscription_deliveries
+		WHERE subscription_id = $1
+		ORDER BY period_from DESC, id DESC
+		LIMIT $2
+	`, subscriptionID, limit)
+}
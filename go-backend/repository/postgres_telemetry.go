@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"driver-drowsiness-backend/models"
+)
+
+// telemetryColumns are the typed schema-v2 columns of drowsiness_data, in
+// the order of telemetryArgs and scanTelemetry
+const telemetryColumns = `mouth_aspect_ratio, yawning, yawn_count, head_pitch, head_yaw, head_roll,
+	gaze_off_road_seconds, face_detected, latitude, longitude, speed_kmh, heading, extra`
+
+// telemetryArgs flattens t into insert arguments; absent groups become NULL
+func telemetryArgs(t *models.Telemetry) ([]interface{}, error) {
+	args := make([]interface{}, 13)
+	if t == nil {
+		return args, nil
+	}
+	if m := t.Mouth; m != nil {
+		args[0], args[1], args[2] = m.AspectRatio, m.Yawning, m.YawnCount
+	}
+	if h := t.HeadPose; h != nil {
+		args[3], args[4], args[5] = h.Pitch, h.Yaw, h.Roll
+	}
+	if g := t.Gaze; g != nil {
+		args[6] = g.OffRoadSeconds
+	}
+	if t.FaceDetected != nil {
+		args[7] = *t.FaceDetected
+	}
+	if g := t.GPS; g != nil {
+		args[8], args[9] = g.Latitude, g.Longitude
+		if g.SpeedKmh != nil {
+			args[10] = *g.SpeedKmh
+		}
+		if g.Heading != nil {
+			args[11] = *g.Heading
+		}
+	}
+	if len(t.Extra) > 0 {
+		extra, err := json.Marshal(t.Extra)
+		if err != nil {
+			return nil, err
+		}
+		args[12] = string(extra)
+	}
+	return args, nil
+}
+
+// telemetryScan receives the nullable telemetry columns of one row
+type telemetryScan struct {
+	mar                      sql.NullFloat64
+	yawning                  sql.NullBool
+	yawnCount                sql.NullInt64
+	pitch, yaw, roll         sql.NullFloat64
+	offRoad                  sql.NullFloat64
+	face                     sql.NullBool
+	lat, lon, speed, heading sql.NullFloat64
+	extra                    []byte
+}
+
+func (s *telemetryScan) dest() []interface{} {
+	return []interface{}{&s.mar, &s.yawning, &s.yawnCount, &s.pitch, &s.yaw, &s.roll,
+		&s.offRoad, &s.face, &s.lat, &s.lon, &s.speed, &s.heading, &s.extra}
+}
+
+// telemetry rebuilds the groups present in the row, or nil for v1 samples
+func (s *telemetryScan) telemetry() (*models.Telemetry, error) {
+	var t models.Telemetry
+	if s.mar.Valid || s.yawning.Valid || s.yawnCount.Valid {
+		t.Mouth = &models.MouthTelemetry{AspectRatio: s.mar.Float64, Yawning: s.yawning.Bool, YawnCount: int(s.yawnCount.Int64)}
+	}
+	if s.pitch.Valid || s.yaw.Valid || s.roll.Valid {
+		t.HeadPose = &models.HeadPose{Pitch: s.pitch.Float64, Yaw: s.yaw.Float64, Roll: s.roll.Float64}
+	}
+	if s.offRoad.Valid {
+		t.Gaze = &models.GazeTelemetry{OffRoadSeconds: s.offRoad.Float64}
+	}
+	if s.face.Valid {
+		face := s.face.Bool
+		t.FaceDetected = &face
+	}
+	if s.lat.Valid && s.lon.Valid {
+		t.GPS = &models.GPSFix{Latitude: s.lat.Float64, Longitude: s.lon.Float64}
+		if s.speed.Valid {
+			speed := s.speed.Float64
+			t.GPS.SpeedKmh = &speed
+		}
+		if s.heading.Valid {
+			heading := s.heading.Float64
+			t.GPS.Heading = &heading
+		}
+	}
+	if len(s.extra) > 0 {
+		if err := json.Unmarshal(s.extra, &t.Extra); err != nil {
+			return nil, err
+		}
+	}
+	if t.Empty() {
+		return nil, nil
+	}
+	return &t, nil
+}
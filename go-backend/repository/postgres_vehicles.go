@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"driver-drowsiness-backend/models"
+)
+
+type pgVehicles struct{ db *sql.DB }
+
+// installedAtSQL selects the vehicle a device was mounted in at a time with
+// one lookup of idx_installations_device; format with the SQL expressions of
+// the device ID and the time
+const installedAtSQL = `SELECT di.vehicle_id FROM device_installations di
+	WHERE di.device_id = %[1]s AND di.installed_at <= %[2]s
+	  AND (di.removed_at IS NULL OR di.removed_at > %[2]s)
+	ORDER BY di.installed_at DESC LIMIT 1`
+
+// vehicleAtSQL is installedAtSQL as a scalar, for single-row statements
+const vehicleAtSQL = `(` + installedAtSQL + `)`
+
+// vehicleAtJoin joins the vehicle v of installedAtSQL to every row of a
+// query, as a lateral join the planner runs as an index lookup per row
+const vehicleAtJoin = `LEFT JOIN LATERAL (` + installedAtSQL + `) vi ON TRUE
+LEFT JOIN vehicles v ON v.id = vi.vehicle_id`
+
+const vehicleColumns = `v.id, v.plate_number, v.type, v.capacity, COALESCE(v.fleet_id, 0), v.created_at,
+	COALESCE((SELECT di.device_id FROM device_installations di
+	          WHERE di.vehicle_id = v.id AND di.removed_at IS NULL
+	          ORDER BY di.installed_at DESC LIMIT 1), '')`
+
+func scanVehicle(row interface{ Scan(...interface{}) error }) (*models.Vehicle, error) {
+	var v models.Vehicle
+	if err := row.Scan(&v.ID, &v.PlateNumber, &v.Type, &v.Capacity, &v.FleetID, &v.CreatedAt, &v.DeviceID); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+func (r *pgVehicles) List(ctx context.Context) ([]models.Vehicle, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+vehicleColumns+` FROM vehicles v ORDER BY v.plate_number`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var vehicles []models.Vehicle
+	for rows.Next() {
+		v, err := scanVehicle(rows)
+		if err != nil {
+			return nil, err
+		}
+		vehicles = append(vehicles, *v)
+	}
+	return vehicles, rows.Err()
+}
+
+func (r *pgVehicles) GetByID(ctx context.Context, id int) (*models.Vehicle, error) {
+	v, err := scanVehicle(r.db.QueryRowContext(ctx, `SELECT `+vehicleColumns+` FROM vehicles v WHERE v.id = $1`, id))
+	return v, notFound(err)
+}
+
+func (r *pgVehicles) Create(ctx context.Context, v *models.Vehicle) error {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO vehicles (plate_number, type, capacity, fleet_id)
+		VALUES ($1, $2, $3, NULLIF($4, 0))
+		RETURNING id, created_at
+	`, v.PlateNumber, v.Type, v.Capacity, v.FleetID).Scan(&v.ID, &v.CreatedAt)
+	return conflict(err)
+}
+
+func (r *pgVehicles) Update(ctx context.Context, v *models.Vehicle) error {
+	err := r.db.QueryRowContext(ctx, `
+		UPDATE vehicles SET plate_number = $2, type = $3, capacity = $4, fleet_id = NULLIF($5, 0)
+		WHERE id = $1
+		RETURNING created_at
+	`, v.ID, v.PlateNumber, v.Type, v.Capacity, v.FleetID).Scan(&v.CreatedAt)
+	if err != nil {
+		return conflict(notFound(err))
+	}
+	return nil
+}
+
+func (r *pgVehicles) Install(ctx context.Context, deviceID string, vehicleID int, at time.Time) (*models.DeviceInstallation, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Lock the open installation so concurrent installs serialize
+	var current time.Time
+	err = tx.QueryRowContext(ctx, `
+		SELECT installed_at FROM device_installations
+		WHERE device_id = $1 AND removed_at IS NULL
+		FOR UPDATE
+	`, deviceID).Scan(&current)
+	switch {
+	case err == sql.ErrNoRows:
+	case err != nil:
+		return nil, err
+	case at.Before(current):
+		return nil, ErrConflict
+	default:
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE device_installations SET removed_at = $2
+			WHERE device_id = $1 AND removed_at IS NULL
+		`, deviceID, at.UTC()); err != nil {
+			return nil, err
+		}
+	}
+
+	inst := models.DeviceInstallation{DeviceID: deviceID, VehicleID: vehicleID, InstalledAt: at.UTC()}
+	if err := tx.QueryRowContext(ctx, `
+		INSERT INTO device_installations (device_id, vehicle_id, installed_at)
+		VALUES ($1, $2, $3)
+		RETURNING id
+	`, deviceID, vehicleID, inst.InstalledAt).Scan(&inst.ID); err != nil {
+		return nil, conflict(err)
+	}
+	return &inst, tx.Commit()
+}
+
+func (r *pgVehicles) Remove(ctx context.Context, deviceID string, at time.Time) error {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE device_installations SET removed_at = GREATEST($2, installed_at)
+		WHERE device_id = $1 AND removed_at IS NULL
+	`, deviceID, at.UTC())
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *pgVehicles) Installations(ctx context.Context, f InstallationFilter) ([]models.DeviceInstallation, error) {
+	var args []interface{}
+	var where []string
+	if f.DeviceID != "" {
+		args = append(args, f.DeviceID)
+		where = append(where, fmt.Sprintf(`device_id = $%d`, len(args)))
+	}
+	if f.VehicleID != 0 {
+		args = append(args, f.VehicleID)
+		where = append(where, fmt.Sprintf(`vehicle_id = $%d`, len(args)))
+	}
+	query := `SELECT id, device_id, vehicle_id, installed_at, removed_at FROM device_installations`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, ` AND `)
+	}
+	query += ` ORDER BY installed_at DESC, id DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []models.DeviceInstallation
+	for rows.Next() {
+		var inst models.DeviceInstallation
+		var removed sql.NullTime
+		if err := rows.Scan(&inst.ID, &inst.DeviceID, &inst.VehicleID, &inst.InstalledAt, &removed); err != nil {
+			return nil, err
+		}
+		if removed.Valid {
+			inst.RemovedAt = &removed.Time
+		}
+		history = append(history, inst)
+	}
+	return history, rows.Err()
+}
+
+func (r *pgVehicles) VehicleAt(ctx context.Context, deviceID string, at time.Time) (int, error) {
+	var id int
+	err := r.db.QueryRowContext(ctx, `SELECT `+fmt.Sprintf(vehicleAtSQL, "$1", "$2"), deviceID, at.UTC()).Scan(&id)
+	return id, notFound(err)
+}
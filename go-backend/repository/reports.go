@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"driver-drowsiness-backend/models"
+)
+
+// HourlyCount is the medium and high samples of one driver in one UTC hour
+type HourlyCount struct {
+	Hour       time.Time
+	DriverID   int // 0 when the device had no owner
+	DriverName string
+	High       int
+	Medium     int
+}
+
+// ReportFilter selects generated reports; zero fields match everything
+type ReportFilter struct {
+	Kind     string
+	FleetID  int
+	DriverID int
+	Limit    int
+}
+
+// ReportRepository reads report data and stores generated reports
+type ReportRepository interface {
+	// HourlyCounts combines live samples with the hourly rollups the daily
+	// purge keeps, so reports can cover days whose samples are gone.
+	// DeviceID in f is ignored.
+	HourlyCounts(ctx context.Context, f ExportFilter) ([]HourlyCount, error)
+
+	// Create stores a new report; ErrConflict if a scheduled report of the
+	// same kind, subject and period exists
+	Create(ctx context.Context, r *models.Report) error
+	// Update saves the status, error, file and completion of a report
+	Update(ctx context.Context, r *models.Report) error
+	GetByID(ctx context.Context, id int) (*models.Report, error)
+	// List returns matching reports, newest first
+	List(ctx context.Context, f ReportFilter) ([]models.Report, error)
+	Delete(ctx context.Context, id int) error
+}
@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"driver-drowsiness-backend/models"
+)
+
+var (
+	// ErrNotFound is returned when a lookup matches no rows
+	ErrNotFound = errors.New("repository: not found")
+	// ErrConflict is returned when a unique constraint would be violated
+	ErrConflict = errors.New("repository: conflict")
+)
+
+// TimeRange is a half-open [From, To) interval of instants
+type TimeRange struct {
+	From time.Time
+	To   time.Time
+}
+
+// Contains reports whether t lies within the range
+func (r TimeRange) Contains(t time.Time) bool {
+	return !t.Before(r.From) && t.Before(r.To)
+}
+
+// DayRange returns the calendar day of t in loc as a UTC range.
+// time.Date normalizes across DST changes, so days may be 23 or 25 hours long.
+func DayRange(t time.Time, loc *time.Location) TimeRange {
+	local := t.In(loc)
+	start := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	end := time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, loc)
+	return TimeRange{From: start.UTC(), To: end.UTC()}
+}
+
+// UserRepository manages application accounts
+type UserRepository interface {
+	Create(ctx context.Context, u *models.User) (int, error)
+	GetByEmail(ctx context.Context, email string) (*models.User, error)
+	GetByID(ctx context.Context, id int) (*models.User, error)
+	UpdatePassword(ctx context.Context, id int, passwordHash string) error
+}
+
+// DeviceRepository manages hardware devices and their owners
+type DeviceRepository interface {
+	List(ctx context.Context) ([]models.Device, error)
+	Get(ctx context.Context, deviceID string) (*models.Device, error)
+	// Touch auto-registers a device and bumps its last_update
+	Touch(ctx context.Context, deviceID, driverEmail string, at time.Time) error
+	// AssignToUser links a device to a user, creating it if needed
+	AssignToUser(ctx context.Context, deviceID, email string, userID int) error
+	// PrimaryForUser returns the most recently created device of a user
+	PrimaryForUser(ctx context.Context, userID int) (string, error)
+}
+
+// DrowsinessRepository stores raw detection samples
+type DrowsinessRepository interface {
+	Insert(ctx context.Context, d *models.DrowsinessData) error
+	Latest(ctx context.Context, deviceID string) (*models.DrowsinessData, error)
+	// History returns one page of the samples of f.DeviceID, newest first
+	History(ctx context.Context, f PageFilter) ([]models.DrowsinessData, error)
+	// Between returns the samples of a device within r, oldest first
+	Between(ctx context.Context, deviceID string, r TimeRange) ([]models.DrowsinessData, error)
+	// LastLocation returns the newest GPS fix of a device taken at or after since
+	LastLocation(ctx context.Context, deviceID string, since time.Time) (*models.GPSFix, error)
+}
+
+// AlertRepository stores alerts raised by devices
+type AlertRepository interface {
+	Insert(ctx context.Context, a *models.Alert) error
+	GetByID(ctx context.Context, id int) (*models.Alert, error)
+	// ListByDevice returns one page of the alerts of f.DeviceID, newest first
+	ListByDevice(ctx context.Context, f PageFilter) ([]models.Alert, error)
+	// Between returns the alerts of a device within r, oldest first
+	Between(ctx context.Context, deviceID string, r TimeRange) ([]models.Alert, error)
+}
+
+// PasswordResetRepository stores one-time password reset codes
+type PasswordResetRepository interface {
+	// Store replaces any existing code of the user
+	Store(ctx context.Context, userID int, code string, expiresAt time.Time) error
+	// Validate returns the user owning an unused, unexpired code
+	Validate(ctx context.Context, email, code string, now time.Time) (*models.User, error)
+	MarkUsed(ctx context.Context, userID int) error
+}
+
+// RecentAlertRow is a medium/high sample joined with its driver and the
+// vehicle its device was mounted in at the time
+type RecentAlertRow struct {
+	Timestamp    time.Time
+	Driver       string
+	Level        string
+	DeviceID     string
+	VehiclePlate string
+}
+
+// FleetRepository manages fleets and their business timezone
+type FleetRepository interface {
+	Create(ctx context.Context, f *models.Fleet) error
+	GetByID(ctx context.Context, id int) (*models.Fleet, error)
+	List(ctx context.Context) ([]models.Fleet, error)
+	Update(ctx context.Context, f *models.Fleet) error
+}
+
+// DashboardRepository serves the aggregates behind the admin dashboard.
+// The caller resolves "today" into a day range in the business timezone.
+type DashboardRepository interface {
+	Overview(ctx context.Context, now time.Time, day TimeRange) (models.AdminOverview, error)
+	DriverSummaries(ctx context.Context, now time.Time, day TimeRange) ([]models.AdminDriverSummary, error)
+	// OnlineDevices counts the devices online at now
+	OnlineDevices(ctx context.Context, now time.Time) (int, error)
+	RecentAlerts(ctx context.Context, limit int) ([]RecentAlertRow, error)
+	// HighCountsByHour returns high samples within day keyed by hour (0-23) in loc
+	HighCountsByHour(ctx context.Context, day TimeRange, loc *time.Location) (map[int]int, error)
+	// LevelCounts returns high and medium samples of drivers within day
+	LevelCounts(ctx context.Context, day TimeRange) (high, medium int, err error)
+}
+
+// Store bundles every repository the handlers depend on
+type Store struct {
+	Users          UserRepository
+	Devices        DeviceRepository
+	Drowsiness     DrowsinessRepository
+	Alerts         AlertRepository
+	PasswordResets PasswordResetRepository
+	Fleets         FleetRepository
+	Dashboard      DashboardRepository
+	Analytics      AnalyticsRepository
+	Sessions       SessionRepository
+	Geo            GeoRepository
+	Geofences      GeofenceRepository
+	Vehicles       VehicleRepository
+	Shifts         ShiftRepository
+	Compliance     ComplianceRepository
+	Health         HealthRepository
+	Shadows        ShadowRepository
+	Commands       CommandRepository
+	Evidence       EvidenceRepository
+	Faces          FaceRepository
+	Exports        ExportRepository
+	Reports        ReportRepository
+	Subscriptions  SubscriptionRepository
+	Retention      RetentionRepository
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDayRange(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("load timezone: %v", err)
+	}
+	bangkok, err := time.LoadLocation("Asia/Bangkok")
+	if err != nil {
+		t.Fatalf("load timezone: %v", err)
+	}
+
+	cases := []struct {
+		name string
+		at   time.Time
+		loc  *time.Location
+		from time.Time
+		hrs  float64
+	}{
+		{"bangkok", time.Date(2025, 11, 8, 20, 0, 0, 0, time.UTC), bangkok, time.Date(2025, 11, 8, 17, 0, 0, 0, time.UTC), 24},
+		{"spring forward", time.Date(2025, 3, 9, 12, 0, 0, 0, time.UTC), newYork, time.Date(2025, 3, 9, 5, 0, 0, 0, time.UTC), 23},
+		{"fall back", time.Date(2025, 11, 2, 12, 0, 0, 0, time.UTC), newYork, time.Date(2025, 11, 2, 4, 0, 0, 0, time.UTC), 25},
+	}
+	for _, tc := range cases {
+		r := DayRange(tc.at, tc.loc)
+		if !r.From.Equal(tc.from) {
+			t.Errorf("%s: from = %v, want %v", tc.name, r.From, tc.from)
+		}
+		if got := r.To.Sub(r.From).Hours(); got != tc.hrs {
+			t.Errorf("%s: length = %vh, want %vh", tc.name, got, tc.hrs)
+		}
+		if !r.Contains(tc.at) || r.Contains(r.To) {
+			t.Errorf("%s: Contains is not half-open", tc.name)
+		}
+	}
+}
+
+func TestBucketStart(t *testing.T) {
+	bangkok, err := time.LoadLocation("Asia/Bangkok")
+	if err != nil {
+		t.Fatalf("load timezone: %v", err)
+	}
+	// Wednesday 2025-11-12 02:44 in Bangkok
+	at := time.Date(2025, 11, 11, 19, 44, 0, 0, time.UTC)
+	cases := map[Bucket]string{
+		Bucket15m: "2025-11-12T02:30:00+07:00",
+		Bucket1h:  "2025-11-12T02:00:00+07:00",
+		Bucket1d:  "2025-11-12T00:00:00+07:00",
+		Bucket1w:  "2025-11-10T00:00:00+07:00",
+	}
+	for b, want := range cases {
+		if got := b.Start(at, bangkok).Format(time.RFC3339); got != want {
+			t.Errorf("%s: start = %s, want %s", b, got, want)
+		}
+	}
+}
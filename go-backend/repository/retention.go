@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// PurgeCutoffs is where the daily purge cuts the rows of each fleet: the
+// start of today in the fleet's timezone, so no fleet loses part of its day
+type PurgeCutoffs struct {
+	Default time.Time         // rows of drivers without a fleet, or without a driver
+	Fleets  map[int]time.Time // by fleet ID
+}
+
+// For returns the cutoff of the rows of fleetID, 0 meaning no fleet
+func (c PurgeCutoffs) For(fleetID int) time.Time {
+	if t, ok := c.Fleets[fleetID]; ok && fleetID != 0 {
+		return t
+	}
+	return c.Default
+}
+
+// Earliest returns the earliest cutoff: rows before it are gone for every fleet
+func (c PurgeCutoffs) Earliest() time.Time {
+	earliest := c.Default
+	for _, t := range c.Fleets {
+		if t.Before(earliest) {
+			earliest = t
+		}
+	}
+	return earliest
+}
+
+// Latest returns the latest cutoff: rows from it on are kept for every fleet
+func (c PurgeCutoffs) Latest() time.Time {
+	latest := c.Default
+	for _, t := range c.Fleets {
+		if t.After(latest) {
+			latest = t
+		}
+	}
+	return latest
+}
+
+// RetentionRepository deletes the raw rows past their retention
+type RetentionRepository interface {
+	// Purge adds the samples before the cutoff of their driver's fleet to
+	// the hourly low/medium/high counts and deletes them, along with the alerts and
+	// sessions before it. Each sample is counted either live or in a rollup.
+	Purge(ctx context.Context, c PurgeCutoffs) error
+}
@@ -0,0 +1,27 @@
+package repository
+
+import (
+	"context"
+
+	"driver-drowsiness-backend/models"
+)
+
+// SessionFilter selects driving sessions; zero fields match everything
+type SessionFilter struct {
+	DeviceID string
+	DriverID int
+	Range    TimeRange // sessions overlapping the range, if set
+	Limit    int
+}
+
+// SessionRepository persists driving sessions
+type SessionRepository interface {
+	Create(ctx context.Context, s *models.DrivingSession) error
+	// Update saves the end and running counters of a session
+	Update(ctx context.Context, s *models.DrivingSession) error
+	// Open returns the session of a device that has not ended yet
+	Open(ctx context.Context, deviceID string) (*models.DrivingSession, error)
+	GetByID(ctx context.Context, id int) (*models.DrivingSession, error)
+	// List returns matching sessions newest first, with AlertCount filled
+	List(ctx context.Context, f SessionFilter) ([]models.DrivingSession, error)
+}
@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"driver-drowsiness-backend/models"
+)
+
+// ShadowFilter selects device shadows; zero fields match everything
+type ShadowFilter struct {
+	FleetID  int // fleet of the device owner
+	DeviceID string
+}
+
+// ShadowRepository stores the desired and reported configuration of devices
+type ShadowRepository interface {
+	// Get returns the stored shadow of a device
+	Get(ctx context.Context, deviceID string) (*models.DeviceShadow, error)
+	// SetDesired stores a new desired configuration and bumps its version
+	SetDesired(ctx context.Context, deviceID string, cfg models.DeviceConfig, at time.Time) (*models.DeviceShadow, error)
+	// Report stores the configuration a device applied. A device reporting
+	// before any desired configuration was set adopts it as desired.
+	Report(ctx context.Context, deviceID string, report models.ConfigReport, at time.Time) (*models.DeviceShadow, error)
+	// List returns the shadow of every matching device, with the default
+	// desired configuration for devices that have none
+	List(ctx context.Context, f ShadowFilter) ([]models.DeviceShadow, error)
+}
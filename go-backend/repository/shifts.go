@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"driver-drowsiness-backend/models"
+)
+
+// ShiftFilter selects shifts; zero fields match everything
+type ShiftFilter struct {
+	DriverID  int
+	VehicleID int
+	DeviceID  string
+	Range     TimeRange // shifts overlapping the range, if set
+	Limit     int
+}
+
+// ShiftRepository stores which driver drives which vehicle and device when
+type ShiftRepository interface {
+	// List returns matching shifts, latest start first
+	List(ctx context.Context, f ShiftFilter) ([]models.Shift, error)
+	GetByID(ctx context.Context, id int) (*models.Shift, error)
+	// Create and Update return ErrConflict when the driver or the device
+	// already has a shift overlapping the new period
+	Create(ctx context.Context, s *models.Shift) error
+	Update(ctx context.Context, s *models.Shift) error
+	Delete(ctx context.Context, id int) error
+	// OnDevice and OnDriver return the shift covering a time
+	OnDevice(ctx context.Context, deviceID string, at time.Time) (*models.Shift, error)
+	OnDriver(ctx context.Context, driverID int, at time.Time) (*models.Shift, error)
+}
+
+// DriverAt attributes a device's data at a time: the driver on shift with
+// the device, otherwise the device's registered owner. 0 means unknown.
+func DriverAt(ctx context.Context, shifts ShiftRepository, devices DeviceRepository, deviceID string, at time.Time) (int, error) {
+	shift, err := shifts.OnDevice(ctx, deviceID, at)
+	if err == nil {
+		return shift.DriverID, nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return 0, err
+	}
+	dev, err := devices.Get(ctx, deviceID)
+	if errors.Is(err, ErrNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return dev.UserID, nil
+}
+
+// overlaps reports whether [start, end) and [otherStart, otherEnd)
+// intersect; a nil end is open
+func overlaps(start time.Time, end *time.Time, otherStart time.Time, otherEnd *time.Time) bool {
+	return (otherEnd == nil || start.Before(*otherEnd)) && (end == nil || otherStart.Before(*end))
+}
@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"driver-drowsiness-backend/models"
+)
+
+// SubscriptionFilter selects subscriptions; zero fields match everything
+type SubscriptionFilter struct {
+	UserID  int
+	FleetID int
+}
+
+// SubscriptionRepository stores digest subscriptions and their deliveries.
+// Several replicas may run the scheduler: a subscription's run is recorded
+// once as a delivery, and each attempt is leased to one replica.
+type SubscriptionRepository interface {
+	Create(ctx context.Context, s *models.Subscription) error
+	GetByID(ctx context.Context, id int) (*models.Subscription, error)
+	List(ctx context.Context, f SubscriptionFilter) ([]models.Subscription, error)
+	// Update saves format, channel, target, secret, lang, active and next run
+	Update(ctx context.Context, s *models.Subscription) error
+	// Delete removes a subscription with its deliveries
+	Delete(ctx context.Context, id int) error
+	// Due returns active subscriptions whose next run is at or before now
+	Due(ctx context.Context, now time.Time, limit int) ([]models.Subscription, error)
+	// Advance moves the next run from "from" to "to"; false when it was
+	// already moved, by another replica or an update
+	Advance(ctx context.Context, id int, from, to time.Time) (bool, error)
+
+	// CreateDelivery records a digest to send; ErrConflict if the
+	// subscription already has one for the period
+	CreateDelivery(ctx context.Context, d *models.Delivery) error
+	// ClaimDeliveries leases pending deliveries due at now to the caller:
+	// each counts an attempt and is not due again until lease has passed
+	ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]models.Delivery, error)
+	// UpdateDelivery saves the status, error, next attempt and delivery time
+	UpdateDelivery(ctx context.Context, d *models.Delivery) error
+	// ListDeliveries returns the deliveries of a subscription, newest first
+	ListDeliveries(ctx context.Context, subscriptionID, limit int) ([]models.Delivery, error)
+}
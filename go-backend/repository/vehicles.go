@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"driver-drowsiness-backend/models"
+)
+
+// InstallationFilter selects installation history; zero fields match everything
+type InstallationFilter struct {
+	DeviceID  string
+	VehicleID int
+}
+
+// VehicleRepository manages vehicles and which device is mounted in which vehicle
+type VehicleRepository interface {
+	// List and GetByID fill DeviceID with the currently installed device
+	List(ctx context.Context) ([]models.Vehicle, error)
+	GetByID(ctx context.Context, id int) (*models.Vehicle, error)
+	Create(ctx context.Context, v *models.Vehicle) error
+	Update(ctx context.Context, v *models.Vehicle) error
+
+	// Install mounts a device in a vehicle at a time, ending the device's
+	// current installation there
+	Install(ctx context.Context, deviceID string, vehicleID int, at time.Time) (*models.DeviceInstallation, error)
+	// Remove ends the current installation of a device
+	Remove(ctx context.Context, deviceID string, at time.Time) error
+	// Installations returns matching history, newest first
+	Installations(ctx context.Context, f InstallationFilter) ([]models.DeviceInstallation, error)
+	// VehicleAt returns the vehicle a device was mounted in at a time
+	VehicleAt(ctx context.Context, deviceID string, at time.Time) (int, error)
+}
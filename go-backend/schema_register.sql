@@ -21,6 +21,17 @@ ALTER TABLE users
 ALTER TABLE users
     ADD COLUMN IF NOT EXISTS user_type VARCHAR(50);
 
+-- FLEETS: groups of drivers sharing a business timezone (IANA name)
+CREATE TABLE IF NOT EXISTS fleets (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    timezone VARCHAR(64) NOT NULL DEFAULT 'Asia/Bangkok',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS fleet_id INT REFERENCES fleets(id) ON DELETE SET NULL;
+
 -- DEVICES: physical monitoring devices that send drowsiness data
 CREATE TABLE IF NOT EXISTS devices (
     id VARCHAR(50) PRIMARY KEY,     -- Device ID from register form (e.g. device_01)
@@ -39,17 +50,34 @@ CREATE INDEX IF NOT EXISTS idx_devices_user_id ON devices(user_id);
 CREATE TABLE IF NOT EXISTS drowsiness_data (
     id SERIAL PRIMARY KEY,
     device_id VARCHAR(50) NOT NULL,
+    driver_id INT,                          -- driver on shift, NULL falls back to the device owner
     eye_closure FLOAT NOT NULL,
     drowsiness_level VARCHAR(50) NOT NULL,  -- low / medium / high
     status VARCHAR(50) NOT NULL,            -- text status shown on dashboard
+    fatigue_score DOUBLE PRECISION,         -- server-side 0-100 score
+    fatigue_level VARCHAR(20),              -- low / medium / high derived from fatigue_score
+    schema_version SMALLINT NOT NULL DEFAULT 1, -- telemetry payload version
+    mouth_aspect_ratio DOUBLE PRECISION,    -- v2 fields below are NULL when not reported
+    yawning BOOLEAN,
+    yawn_count INT,
+    head_pitch DOUBLE PRECISION,            -- degrees
+    head_yaw DOUBLE PRECISION,
+    head_roll DOUBLE PRECISION,
+    gaze_off_road_seconds DOUBLE PRECISION,
+    face_detected BOOLEAN,
+    latitude DOUBLE PRECISION,
+    longitude DOUBLE PRECISION,
+    speed_kmh DOUBLE PRECISION,
+    heading DOUBLE PRECISION,               -- degrees clockwise from north
+    extra JSONB,                            -- vendor / newer fields
     timestamp TIMESTAMP NOT NULL,           -- server-side event time
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     CONSTRAINT fk_drowsiness_device FOREIGN KEY (device_id) REFERENCES devices(id) ON DELETE CASCADE
 );
 
 -- Index for dashboard queries by device + time
-CREATE INDEX IF NOT EXISTS idx_drowsiness_device_timestamp 
-ON drowsiness_data(device_id, timestamp DESC);
+CREATE INDEX IF NOT EXISTS idx_drowsiness_device_keyset
+ON drowsiness_data(device_id, timestamp DESC, id DESC);
 
 -- ALERTS: summarized alert events per device
 CREATE TABLE IF NOT EXISTS alerts (
@@ -59,14 +87,345 @@ CREATE TABLE IF NOT EXISTS alerts (
     severity VARCHAR(50) NOT NULL,          -- e.g. warning / danger
     acknowledged BOOLEAN DEFAULT FALSE,
     status VARCHAR(50) DEFAULT 'active',
+    latitude DOUBLE PRECISION,              -- where the alert was raised, if known
+    longitude DOUBLE PRECISION,
+    route VARCHAR(100) NOT NULL DEFAULT '', -- notification routing set by a geofence rule
+    geofence_id INT,                        -- geofence whose rule applied
+    vehicle_id INT,                         -- vehicle the device was mounted in
+    driver_id INT,                          -- driver on shift
     timestamp TIMESTAMP NOT NULL,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     CONSTRAINT fk_alerts_device FOREIGN KEY (device_id) REFERENCES devices(id) ON DELETE CASCADE
 );
 
 -- Index for alert queries by device + time
-CREATE INDEX IF NOT EXISTS idx_alerts_device 
-ON alerts(device_id, timestamp DESC);
+CREATE INDEX IF NOT EXISTS idx_alerts_device_keyset
+ON alerts(device_id, timestamp DESC, id DESC);
+
+-- GEOFENCES: polygons ([lon, lat] ring) with rules that change alert handling inside
+CREATE TABLE IF NOT EXISTS geofences (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    kind VARCHAR(50) NOT NULL DEFAULT '',   -- depot / highway / hotspot ...
+    polygon JSONB NOT NULL,
+    rules JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- GEOFENCE EVENTS: devices entering or leaving a geofence
+CREATE TABLE IF NOT EXISTS geofence_events (
+    id SERIAL PRIMARY KEY,
+    geofence_id INT NOT NULL,
+    device_id VARCHAR(50) NOT NULL,
+    event VARCHAR(10) NOT NULL,             -- enter / exit
+    latitude DOUBLE PRECISION NOT NULL,
+    longitude DOUBLE PRECISION NOT NULL,
+    timestamp TIMESTAMP NOT NULL,
+    CONSTRAINT fk_geofence_events_geofence FOREIGN KEY (geofence_id) REFERENCES geofences(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_geofence_events_device
+ON geofence_events(device_id, geofence_id, timestamp DESC);
+
+-- VEHICLES: separate from the devices mounted in them
+CREATE TABLE IF NOT EXISTS vehicles (
+    id SERIAL PRIMARY KEY,
+    plate_number VARCHAR(20) UNIQUE NOT NULL,
+    type VARCHAR(50) NOT NULL DEFAULT '',   -- bus / truck / van ...
+    capacity INT NOT NULL DEFAULT 0,
+    fleet_id INT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_vehicles_fleet FOREIGN KEY (fleet_id) REFERENCES fleets(id) ON DELETE SET NULL
+);
+
+-- DEVICE INSTALLATIONS: which device was mounted in which vehicle, and when
+CREATE TABLE IF NOT EXISTS device_installations (
+    id SERIAL PRIMARY KEY,
+    device_id VARCHAR(50) NOT NULL,
+    vehicle_id INT NOT NULL,
+    installed_at TIMESTAMP NOT NULL,
+    removed_at TIMESTAMP,                   -- NULL while mounted
+    CONSTRAINT fk_installations_device FOREIGN KEY (device_id) REFERENCES devices(id) ON DELETE CASCADE,
+    CONSTRAINT fk_installations_vehicle FOREIGN KEY (vehicle_id) REFERENCES vehicles(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_installations_device
+ON device_installations(device_id, installed_at DESC);
+
+-- A device is mounted in at most one vehicle at a time
+CREATE UNIQUE INDEX IF NOT EXISTS uq_installations_open_device
+ON device_installations(device_id) WHERE removed_at IS NULL;
+
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS vehicle_id INT REFERENCES vehicles(id) ON DELETE SET NULL;
+
+-- SHIFTS: which driver drives which vehicle and device from start to end
+CREATE TABLE IF NOT EXISTS shifts (
+    id SERIAL PRIMARY KEY,
+    driver_id INT NOT NULL,
+    vehicle_id INT NOT NULL,
+    device_id VARCHAR(50) NOT NULL,
+    starts_at TIMESTAMP NOT NULL,
+    ends_at TIMESTAMP,                      -- NULL until the shift is ended
+    notes TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_shifts_driver FOREIGN KEY (driver_id) REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT fk_shifts_vehicle FOREIGN KEY (vehicle_id) REFERENCES vehicles(id) ON DELETE CASCADE,
+    CONSTRAINT fk_shifts_device FOREIGN KEY (device_id) REFERENCES devices(id) ON DELETE CASCADE,
+    CHECK (ends_at IS NULL OR ends_at > starts_at)
+);
+
+CREATE INDEX IF NOT EXISTS idx_shifts_device ON shifts(device_id, starts_at DESC);
+CREATE INDEX IF NOT EXISTS idx_shifts_driver ON shifts(driver_id, starts_at DESC);
+
+-- DEVICE HEALTH: last heartbeat and derived state of each device
+CREATE TABLE IF NOT EXISTS device_health (
+    device_id VARCHAR(50) PRIMARY KEY,
+    state VARCHAR(20) NOT NULL,             -- online, degraded, camera_blocked or offline
+    reason VARCHAR(100) NOT NULL DEFAULT '',
+    state_since TIMESTAMP NOT NULL,
+    last_heartbeat TIMESTAMP NOT NULL,
+    cpu_temp_c DOUBLE PRECISION,
+    camera_status VARCHAR(20) NOT NULL DEFAULT '',
+    fps DOUBLE PRECISION,
+    app_version VARCHAR(50) NOT NULL DEFAULT '',
+    CONSTRAINT fk_health_device FOREIGN KEY (device_id) REFERENCES devices(id) ON DELETE CASCADE
+);
+
+-- DEVICE STATE EVENTS: transitions of the device state machine (uptime history)
+CREATE TABLE IF NOT EXISTS device_state_events (
+    id SERIAL PRIMARY KEY,
+    device_id VARCHAR(50) NOT NULL,
+    from_state VARCHAR(20),                 -- NULL for the first heartbeat
+    to_state VARCHAR(20) NOT NULL,
+    reason VARCHAR(100) NOT NULL DEFAULT '',
+    at TIMESTAMP NOT NULL,
+    CONSTRAINT fk_state_events_device FOREIGN KEY (device_id) REFERENCES devices(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_state_events_device_at ON device_state_events(device_id, at);
+
+-- DEVICE SHADOWS: desired configuration pushed to devices and what they applied
+CREATE TABLE IF NOT EXISTS device_shadows (
+    device_id VARCHAR(50) PRIMARY KEY,
+    desired JSONB NOT NULL,                 -- ear_threshold, consecutive_frames, alarm_volume, upload_interval_seconds
+    version INT NOT NULL DEFAULT 0,         -- bumped on every desired change
+    updated_at TIMESTAMP,
+    reported JSONB,                         -- NULL until the device reports
+    reported_version INT NOT NULL DEFAULT 0,
+    reported_at TIMESTAMP,
+    CONSTRAINT fk_shadows_device FOREIGN KEY (device_id) REFERENCES devices(id) ON DELETE CASCADE
+);
+
+-- DEVICE COMMANDS: remote commands queued for devices (alarm, voice_message, snapshot, restart)
+CREATE TABLE IF NOT EXISTS device_commands (
+    id SERIAL PRIMARY KEY,
+    device_id VARCHAR(50) NOT NULL,
+    type VARCHAR(30) NOT NULL,
+    message TEXT NOT NULL DEFAULT '',       -- text of voice_message
+    duration_seconds INT NOT NULL DEFAULT 0, -- alarm length
+    status VARCHAR(20) NOT NULL,            -- queued, delivered, succeeded, failed, expired, cancelled
+    issued_by INT,
+    attempts INT NOT NULL DEFAULT 0,
+    result TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    delivered_at TIMESTAMP,
+    completed_at TIMESTAMP,
+    CONSTRAINT fk_commands_device FOREIGN KEY (device_id) REFERENCES devices(id) ON DELETE CASCADE,
+    CONSTRAINT fk_commands_user FOREIGN KEY (issued_by) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_commands_device_status ON device_commands(device_id, status, created_at DESC);
+
+-- DEVICE COMMAND EVENTS: audit trail of every command status change
+CREATE TABLE IF NOT EXISTS device_command_events (
+    id SERIAL PRIMARY KEY,
+    command_id INT NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    actor VARCHAR(50) NOT NULL,             -- user:<id>, device or system
+    note TEXT NOT NULL DEFAULT '',
+    at TIMESTAMP NOT NULL,
+    CONSTRAINT fk_command_events_command FOREIGN KEY (command_id) REFERENCES device_commands(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_command_events_command ON device_command_events(command_id, at);
+
+-- EVIDENCE: snapshots and clips uploaded for alerts; files live in object storage
+CREATE TABLE IF NOT EXISTS evidence (
+    id SERIAL PRIMARY KEY,
+    alert_id INT NOT NULL,                  -- no FK: alerts are purged daily, evidence has its own retention
+    device_id VARCHAR(50) NOT NULL,
+    alert_type VARCHAR(100) NOT NULL,
+    severity VARCHAR(50) NOT NULL,
+    alert_at TIMESTAMP NOT NULL,
+    kind VARCHAR(20) NOT NULL,              -- snapshot / clip
+    content_type VARCHAR(50) NOT NULL,
+    size BIGINT NOT NULL,
+    sha256 CHAR(64) NOT NULL,
+    storage_key TEXT NOT NULL,
+    thumbnail_key TEXT NOT NULL DEFAULT '', -- empty when no thumbnail could be made
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,          -- deleted by the retention sweep after this
+    CONSTRAINT fk_evidence_device FOREIGN KEY (device_id) REFERENCES devices(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_evidence_alert ON evidence(alert_id);
+CREATE INDEX IF NOT EXISTS idx_evidence_device_time ON evidence(device_id, alert_at DESC);
+CREATE INDEX IF NOT EXISTS idx_evidence_expires ON evidence(expires_at);
+
+-- EVIDENCE UPLOADS: resumable chunked uploads in progress
+CREATE TABLE IF NOT EXISTS evidence_uploads (
+    id VARCHAR(64) PRIMARY KEY,
+    device_id VARCHAR(50) NOT NULL,
+    alert_id INT NOT NULL,
+    content_type VARCHAR(50) NOT NULL,
+    size BIGINT NOT NULL,                   -- declared total size
+    received BIGINT NOT NULL DEFAULT 0,     -- next chunk starts at this offset
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    CONSTRAINT fk_evidence_uploads_device FOREIGN KEY (device_id) REFERENCES devices(id) ON DELETE CASCADE
+);
+
+-- FACE EMBEDDINGS: reference embeddings drivers enrolled, computed on the device (no face images are stored)
+CREATE TABLE IF NOT EXISTS face_embeddings (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    model VARCHAR(50) NOT NULL DEFAULT '',  -- embedding model; only same-model vectors are compared
+    embedding DOUBLE PRECISION[] NOT NULL,  -- L2-normalized
+    created_at TIMESTAMP NOT NULL,
+    CONSTRAINT fk_face_embeddings_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_face_embeddings_user ON face_embeddings(user_id);
+
+-- HOURLY LEVEL COUNTS: medium/high samples per hour, kept by the daily purge for reports
+CREATE TABLE IF NOT EXISTS hourly_level_counts (
+    hour TIMESTAMP NOT NULL,                -- UTC hour start
+    device_id VARCHAR(50) NOT NULL,
+    driver_id INT NOT NULL DEFAULT 0,       -- driver on shift, else the device owner; 0 if none
+    high INT NOT NULL DEFAULT 0,
+    medium INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (hour, device_id, driver_id)
+);
+
+-- REPORTS: generated PDF safety reports
+CREATE TABLE IF NOT EXISTS reports (
+    id SERIAL PRIMARY KEY,
+    kind VARCHAR(20) NOT NULL,              -- 'fleet' or 'driver'
+    fleet_id INT,
+    driver_id INT,
+    title TEXT NOT NULL,
+    timezone VARCHAR(64) NOT NULL,          -- timezone the report was laid out in
+    period_from TIMESTAMP NOT NULL,
+    period_to TIMESTAMP NOT NULL,           -- exclusive
+    source VARCHAR(20) NOT NULL DEFAULT 'manual',  -- 'manual' or 'schedule'
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- 'pending', 'ready' or 'failed'
+    error TEXT NOT NULL DEFAULT '',
+    storage_key TEXT NOT NULL DEFAULT '',   -- PDF in the evidence storage backend
+    size BIGINT NOT NULL DEFAULT 0,
+    pages INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL,
+    completed_at TIMESTAMP,
+    CONSTRAINT fk_reports_fleet FOREIGN KEY (fleet_id) REFERENCES fleets(id) ON DELETE CASCADE,
+    CONSTRAINT fk_reports_driver FOREIGN KEY (driver_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_reports_created ON reports(created_at DESC);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reports_scheduled
+    ON reports(kind, COALESCE(fleet_id, 0), COALESCE(driver_id, 0), period_from)
+    WHERE source = 'schedule';
+
+-- COMPLIANCE DAYS: driving time per driver and business day, archived
+-- before the daily purge deletes the samples it was derived from
+CREATE TABLE IF NOT EXISTS compliance_days (
+    day TIMESTAMP NOT NULL,                 -- midnight in the business timezone
+    driver_id INT NOT NULL,
+    driving_seconds DOUBLE PRECISION NOT NULL DEFAULT 0,
+    exceeded BOOLEAN NOT NULL DEFAULT FALSE, -- over the daily driving limit
+    violations INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (day, driver_id),
+    CONSTRAINT fk_compliance_days_driver FOREIGN KEY (driver_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- REPORT SUBSCRIPTIONS: digests sent on a schedule in the fleet timezone
+CREATE TABLE IF NOT EXISTS report_subscriptions (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    fleet_id INT NOT NULL,
+    digest VARCHAR(30) NOT NULL,            -- 'daily_summary' or 'weekly_compliance'
+    format VARCHAR(10) NOT NULL,            -- 'html', 'pdf' or 'csv'
+    channel VARCHAR(10) NOT NULL,           -- 'email' or 'webhook'
+    target TEXT NOT NULL,                   -- email address or webhook URL
+    secret TEXT NOT NULL DEFAULT '',        -- HMAC key of webhook signatures
+    lang VARCHAR(5) NOT NULL DEFAULT 'th',
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    next_run_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    CONSTRAINT fk_report_subscriptions_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT fk_report_subscriptions_fleet FOREIGN KEY (fleet_id) REFERENCES fleets(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_report_subscriptions_due ON report_subscriptions(next_run_at) WHERE active;
+
+-- SUBSCRIPTION DELIVERIES: one per subscription and period, with retries
+CREATE TABLE IF NOT EXISTS subscription_deliveries (
+    id SERIAL PRIMARY KEY,
+    subscription_id INT NOT NULL,
+    period_from TIMESTAMP NOT NULL,
+    period_to TIMESTAMP NOT NULL,           -- exclusive
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- 'pending', 'sent' or 'failed'
+    attempts INT NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',         -- of the last failed attempt
+    next_attempt_at TIMESTAMP NOT NULL,     -- also the lease of an attempt in progress
+    last_attempt_at TIMESTAMP,
+    delivered_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL,
+    CONSTRAINT uq_subscription_deliveries_period UNIQUE (subscription_id, period_from),
+    CONSTRAINT fk_subscription_deliveries_subscription FOREIGN KEY (subscription_id) REFERENCES report_subscriptions(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscription_deliveries_pending
+    ON subscription_deliveries(next_attempt_at) WHERE status = 'pending';
+
+-- DRIVING SESSIONS: continuous drives split by data gaps or start/stop events
+CREATE TABLE IF NOT EXISTS driving_sessions (
+    id SERIAL PRIMARY KEY,
+    device_id VARCHAR(50) NOT NULL,
+    driver_id INT,
+    vehicle_id INT,                         -- vehicle at session start
+    started_at TIMESTAMP NOT NULL,
+    ended_at TIMESTAMP,                     -- NULL while the session is open
+    last_sample_at TIMESTAMP NOT NULL,
+    end_reason VARCHAR(20),                 -- gap / stop / restart
+    sample_count INT NOT NULL DEFAULT 0,
+    low_count INT NOT NULL DEFAULT 0,
+    medium_count INT NOT NULL DEFAULT 0,
+    high_count INT NOT NULL DEFAULT 0,
+    longest_high_seconds DOUBLE PRECISION NOT NULL DEFAULT 0,
+    high_run_start TIMESTAMP,
+    fatigue_score DOUBLE PRECISION NOT NULL DEFAULT 0,      -- score at the last sample
+    max_fatigue_score DOUBLE PRECISION NOT NULL DEFAULT 0,
+    blink_count INT NOT NULL DEFAULT 0,
+    microsleep_count INT NOT NULL DEFAULT 0,
+    microsleep_seconds DOUBLE PRECISION NOT NULL DEFAULT 0,
+    observed_seconds DOUBLE PRECISION NOT NULL DEFAULT 0,   -- PERCLOS = closed / observed
+    closed_seconds DOUBLE PRECISION NOT NULL DEFAULT 0,
+    identity VARCHAR(20),                   -- face check at trip start: verified / reassigned / unrecognized / not_enrolled
+    expected_driver_id INT,                 -- driver on shift (or owner) when the face was checked
+    identity_similarity DOUBLE PRECISION,   -- best cosine similarity found
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_sessions_device FOREIGN KEY (device_id) REFERENCES devices(id) ON DELETE CASCADE,
+    CONSTRAINT fk_sessions_driver FOREIGN KEY (driver_id) REFERENCES users(id) ON DELETE SET NULL,
+    CONSTRAINT fk_sessions_expected_driver FOREIGN KEY (expected_driver_id) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_device_started
+ON driving_sessions(device_id, started_at DESC);
+
+-- At most one open session per device
+CREATE UNIQUE INDEX IF NOT EXISTS uq_sessions_open_device
+ON driving_sessions(device_id) WHERE ended_at IS NULL;
 
 -- OPTIONAL: seed sample devices for quick testing (safe to re-run)
 INSERT INTO devices (id, driver_email, status)
@@ -0,0 +1,244 @@
+// Package sessions segments the per-device sample stream into driving sessions.
+package sessions
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"driver-drowsiness-backend/fatigue"
+	"driver-drowsiness-backend/models"
+	"driver-drowsiness-backend/repository"
+)
+
+// End reasons recorded on closed sessions
+const (
+	EndGap      = "gap"      // no samples for longer than the gap
+	EndStop     = "stop"     // explicit stop event from the device
+	EndRestart  = "restart"  // explicit start while a session was open
+	EndHandover = "handover" // samples started coming from another driver
+)
+
+// deviceLock serializes the session updates of one device; refs counts the
+// callers holding or waiting for it, so it is dropped when nobody needs it
+type deviceLock struct {
+	sync.Mutex
+	refs int
+}
+
+// Tracker keeps each device's open session up to date as samples arrive
+type Tracker struct {
+	sessions repository.SessionRepository
+	devices  repository.DeviceRepository
+	shifts   repository.ShiftRepository
+	gap      time.Duration
+
+	mu    sync.Mutex
+	locks map[string]*deviceLock // only devices being updated right now
+}
+
+// NewTracker creates a Tracker that splits sessions after gap without samples
+func NewTracker(store *repository.Store, gap time.Duration) *Tracker {
+	return &Tracker{
+		sessions: store.Sessions,
+		devices:  store.Devices,
+		shifts:   store.Shifts,
+		gap:      gap,
+		locks:    make(map[string]*deviceLock),
+	}
+}
+
+// Gap returns the silence after which a session is considered ended
+func (t *Tracker) Gap() time.Duration {
+	return t.gap
+}
+
+// lock serializes session updates of one device
+func (t *Tracker) lock(deviceID string) func() {
+	t.mu.Lock()
+	l, ok := t.locks[deviceID]
+	if !ok {
+		l = &deviceLock{}
+		t.locks[deviceID] = l
+	}
+	l.refs++
+	t.mu.Unlock()
+	l.Lock()
+	return func() {
+		l.Unlock()
+		t.mu.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(t.locks, deviceID)
+		}
+		t.mu.Unlock()
+	}
+}
+
+// Observe folds a sample and its fatigue update into the device's open
+// session. A new session is started when none is open, the previous
+// sample is older than the gap, or the sample's driver took over (unless
+// a face check at trip start decided the driver).
+func (t *Tracker) Observe(ctx context.Context, d models.DrowsinessData, u fatigue.Update) (*models.DrivingSession, error) {
+	defer t.lock(d.DeviceID)()
+
+	s, err := t.current(ctx, d.DeviceID, d.Timestamp)
+	if err != nil {
+		return nil, err
+	}
+	if s != nil && d.DriverID != 0 && s.DriverID != d.DriverID && (s.Identity == nil || !s.Identity.Pinned()) {
+		if err := t.end(ctx, s, s.LastSampleAt, EndHandover); err != nil {
+			return nil, err
+		}
+		s = nil
+	}
+	if s == nil {
+		if s, err = t.begin(ctx, d.DeviceID, d.DriverID, d.Timestamp, nil); err != nil {
+			return nil, err
+		}
+	}
+	apply(s, d)
+	applyFatigue(s, u)
+	return s, t.sessions.Update(ctx, s)
+}
+
+// Start opens a new session at an explicit trip start, closing any open one
+func (t *Tracker) Start(ctx context.Context, deviceID string, at time.Time) (*models.DrivingSession, error) {
+	return t.StartChecked(ctx, deviceID, at, nil)
+}
+
+// StartChecked is Start for a trip whose driver's face was checked. A pinned
+// check attributes the session to the driver it found, or to nobody, instead
+// of the driver on shift.
+func (t *Tracker) StartChecked(ctx context.Context, deviceID string, at time.Time, check *models.IdentityCheck) (*models.DrivingSession, error) {
+	defer t.lock(deviceID)()
+
+	open, err := t.current(ctx, deviceID, at)
+	if err != nil {
+		return nil, err
+	}
+	if open != nil {
+		if err := t.end(ctx, open, at, EndRestart); err != nil {
+			return nil, err
+		}
+	}
+	driverID, err := repository.DriverAt(ctx, t.shifts, t.devices, deviceID, at)
+	if err != nil {
+		return nil, err
+	}
+	if check != nil && check.Pinned() {
+		driverID = check.DriverID
+	}
+	return t.begin(ctx, deviceID, driverID, at, check)
+}
+
+// Stop closes the open session at an explicit trip stop.
+// It returns repository.ErrNotFound when no session is open.
+func (t *Tracker) Stop(ctx context.Context, deviceID string, at time.Time) (*models.DrivingSession, error) {
+	defer t.lock(deviceID)()
+
+	open, err := t.current(ctx, deviceID, at)
+	if err != nil {
+		return nil, err
+	}
+	if open == nil {
+		return nil, repository.ErrNotFound
+	}
+	return open, t.end(ctx, open, at, EndStop)
+}
+
+// Describe fills the fields of a session computed at read time
+func (t *Tracker) Describe(s *models.DrivingSession, now time.Time) {
+	end := s.LastSampleAt
+	if s.EndedAt != nil {
+		end = *s.EndedAt
+	}
+	s.DurationSeconds = end.Sub(s.StartedAt).Seconds()
+	s.Perclos = 0
+	if s.ObservedSeconds > 0 {
+		s.Perclos = s.ClosedSeconds / s.ObservedSeconds
+	}
+	s.Status = "active"
+	if s.EndedAt != nil || now.Sub(s.LastSampleAt) > t.gap {
+		s.Status = "ended"
+	}
+}
+
+// current returns the open session of a device, closing it first if the
+// gap has elapsed by at; nil means a new session is needed.
+func (t *Tracker) current(ctx context.Context, deviceID string, at time.Time) (*models.DrivingSession, error) {
+	s, err := t.sessions.Open(ctx, deviceID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if at.Sub(s.LastSampleAt) > t.gap {
+		return nil, t.end(ctx, s, s.LastSampleAt, EndGap)
+	}
+	return s, nil
+}
+
+// begin creates a session attributed to driverID (0 when unknown)
+func (t *Tracker) begin(ctx context.Context, deviceID string, driverID int, at time.Time, check *models.IdentityCheck) (*models.DrivingSession, error) {
+	s := &models.DrivingSession{DeviceID: deviceID, DriverID: driverID, StartedAt: at, LastSampleAt: at, Identity: check}
+	return s, t.sessions.Create(ctx, s)
+}
+
+func (t *Tracker) end(ctx context.Context, s *models.DrivingSession, at time.Time, reason string) error {
+	if at.Before(s.LastSampleAt) {
+		at = s.LastSampleAt
+	}
+	s.EndedAt = &at
+	s.EndReason = reason
+	s.HighRunStart = nil
+	return t.sessions.Update(ctx, s)
+}
+
+// apply updates the running counters of a session with one sample
+func apply(s *models.DrivingSession, d models.DrowsinessData) {
+	s.SampleCount++
+	if d.Timestamp.After(s.LastSampleAt) {
+		s.LastSampleAt = d.Timestamp
+	}
+	switch strings.ToLower(d.DrowsinessLevel) {
+	case "low":
+		s.LowCount++
+	case "medium":
+		s.MediumCount++
+	case "high":
+		s.HighCount++
+	}
+
+	if !strings.EqualFold(d.DrowsinessLevel, "high") {
+		s.HighRunStart = nil
+		return
+	}
+	if s.HighRunStart == nil {
+		start := d.Timestamp
+		s.HighRunStart = &start
+	}
+	if run := d.Timestamp.Sub(*s.HighRunStart).Seconds(); run > s.LongestHighSeconds {
+		s.LongestHighSeconds = run
+	}
+}
+
+// applyFatigue adds the increments of one fatigue update to a session. Time
+// credited to the sample before the session began is not counted.
+func applyFatigue(s *models.DrivingSession, u fatigue.Update) {
+	if s.SampleCount > 1 {
+		s.ObservedSeconds += u.ObservedSeconds
+		s.ClosedSeconds += u.ClosedSeconds
+	}
+	s.BlinkCount += u.Blinks
+	if u.MicrosleepSeconds > 0 {
+		s.MicrosleepCount++
+		s.MicrosleepSeconds += u.MicrosleepSeconds
+	}
+	s.FatigueScore = u.Metrics.Score
+	if u.Metrics.Score > s.MaxFatigueScore {
+		s.MaxFatigueScore = u.Metrics.Score
+	}
+}
@@ -0,0 +1,178 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"driver-drowsiness-backend/fatigue"
+	"driver-drowsiness-backend/models"
+	"driver-drowsiness-backend/repository"
+)
+
+var start = time.Date(2025, 11, 9, 3, 0, 0, 0, time.UTC)
+
+func sample(level string, offset time.Duration) models.DrowsinessData {
+	return models.DrowsinessData{DeviceID: "device_01", DrowsinessLevel: level, Timestamp: start.Add(offset)}
+}
+
+func TestObserveSplitsOnGap(t *testing.T) {
+	ctx := context.Background()
+	tr := NewTracker(repository.NewMemoryStore(), 5*time.Minute)
+
+	first, _ := tr.Observe(ctx, sample("low", 0), fatigue.Update{})
+	if s, _ := tr.Observe(ctx, sample("low", 5*time.Minute), fatigue.Update{}); s.ID != first.ID {
+		t.Errorf("sample exactly one gap later started session %d", s.ID)
+	}
+	second, err := tr.Observe(ctx, sample("low", 10*time.Minute+time.Second), fatigue.Update{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.ID == first.ID {
+		t.Fatal("gap did not start a new session")
+	}
+
+	closed, _ := tr.sessions.GetByID(ctx, first.ID)
+	if closed.EndedAt == nil || !closed.EndedAt.Equal(start.Add(5*time.Minute)) || closed.EndReason != EndGap {
+		t.Errorf("closed session = %+v", closed)
+	}
+}
+
+func TestDeviceLocksAreReleased(t *testing.T) {
+	ctx := context.Background()
+	tr := NewTracker(repository.NewMemoryStore(), 5*time.Minute)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			d := sample("low", time.Duration(i)*time.Second)
+			d.DeviceID = fmt.Sprintf("device_%02d", i%4)
+			if _, err := tr.Observe(ctx, d, fatigue.Update{}); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	tr.Stop(ctx, "device_00", start.Add(time.Minute))
+
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	if len(tr.locks) != 0 {
+		t.Errorf("%d device locks left after all updates finished", len(tr.locks))
+	}
+}
+
+func TestObserveLongestHighEpisode(t *testing.T) {
+	ctx := context.Background()
+	tr := NewTracker(repository.NewMemoryStore(), 5*time.Minute)
+
+	var s *models.DrivingSession
+	for i, level := range []string{"high", "high", "low", "high", "high", "high", "medium"} {
+		s, _ = tr.Observe(ctx, sample(level, time.Duration(i)*10*time.Second), fatigue.Update{})
+	}
+	if s.HighCount != 5 || s.LowCount != 1 || s.MediumCount != 1 || s.SampleCount != 7 {
+		t.Errorf("counts = %+v", s)
+	}
+	if s.LongestHighSeconds != 20 {
+		t.Errorf("longest high = %v, want 20", s.LongestHighSeconds)
+	}
+	if s.HighRunStart != nil {
+		t.Error("high run still open after a medium sample")
+	}
+}
+
+func TestStartStop(t *testing.T) {
+	ctx := context.Background()
+	tr := NewTracker(repository.NewMemoryStore(), 5*time.Minute)
+
+	if _, err := tr.Stop(ctx, "device_01", start); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("stop without session: err = %v", err)
+	}
+	first, _ := tr.Observe(ctx, sample("low", 0), fatigue.Update{})
+	second, err := tr.Start(ctx, "device_01", start.Add(time.Minute))
+	if err != nil || second.ID == first.ID {
+		t.Fatalf("start: %+v, %v", second, err)
+	}
+	if s, _ := tr.sessions.GetByID(ctx, first.ID); s.EndReason != EndRestart {
+		t.Errorf("restarted session reason = %q", s.EndReason)
+	}
+
+	stopped, err := tr.Stop(ctx, "device_01", start.Add(3*time.Minute))
+	if err != nil || stopped.ID != second.ID || stopped.EndReason != EndStop {
+		t.Fatalf("stop: %+v, %v", stopped, err)
+	}
+	tr.Describe(stopped, start.Add(4*time.Minute))
+	if stopped.Status != "ended" || stopped.DurationSeconds != 120 {
+		t.Errorf("described = %+v", stopped)
+	}
+}
+
+func TestDescribeIdleSessionIsEnded(t *testing.T) {
+	tr := NewTracker(repository.NewMemoryStore(), 5*time.Minute)
+	s := &models.DrivingSession{StartedAt: start, LastSampleAt: start.Add(time.Minute)}
+
+	tr.Describe(s, start.Add(3*time.Minute))
+	if s.Status != "active" {
+		t.Errorf("status = %q, want active", s.Status)
+	}
+	tr.Describe(s, start.Add(7*time.Minute))
+	if s.Status != "ended" || s.DurationSeconds != 60 {
+		t.Errorf("idle session = %+v", s)
+	}
+}
+
+func TestObserveFatigueTotals(t *testing.T) {
+	ctx := context.Background()
+	tr := NewTracker(repository.NewMemoryStore(), 5*time.Minute)
+
+	updates := []fatigue.Update{
+		{ObservedSeconds: 2, ClosedSeconds: 2, Metrics: models.FatigueMetrics{Score: 10}}, // before the session
+		{ObservedSeconds: 1, ClosedSeconds: 1, Blinks: 1, Metrics: models.FatigueMetrics{Score: 70}},
+		{ObservedSeconds: 1, MicrosleepSeconds: 1.5, Metrics: models.FatigueMetrics{Score: 40}},
+		{ObservedSeconds: 2, Metrics: models.FatigueMetrics{Score: 20}},
+	}
+	var s *models.DrivingSession
+	for i, u := range updates {
+		s, _ = tr.Observe(ctx, sample("low", time.Duration(i)*time.Second), u)
+	}
+	tr.Describe(s, start)
+	if s.ObservedSeconds != 4 || s.Perclos != 0.25 || s.BlinkCount != 1 || s.MicrosleepCount != 1 ||
+		s.MicrosleepSeconds != 1.5 || s.FatigueScore != 20 || s.MaxFatigueScore != 70 {
+		t.Errorf("fatigue totals = %+v", s)
+	}
+}
+
+func TestCheckedStartPinsDriver(t *testing.T) {
+	ctx := context.Background()
+	tr := NewTracker(repository.NewMemoryStore(), 5*time.Minute)
+
+	check := &models.IdentityCheck{Status: models.IdentityUnrecognized, ExpectedDriverID: 7}
+	s, err := tr.StartChecked(ctx, "device_01", start, check)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.DriverID != 0 || s.Identity == nil || s.Identity.Status != models.IdentityUnrecognized {
+		t.Fatalf("checked session = %+v", s)
+	}
+
+	// Samples attributed to the driver on shift do not hand the trip over
+	d := sample("low", time.Minute)
+	d.DriverID = 7
+	if got, _ := tr.Observe(ctx, d, fatigue.Update{}); got.ID != s.ID {
+		t.Errorf("sample of the expected driver started session %d", got.ID)
+	}
+
+	// An unpinned check behaves like an unchecked start
+	check = &models.IdentityCheck{Status: models.IdentityNotEnrolled, ExpectedDriverID: 7, DriverID: 7}
+	s, _ = tr.StartChecked(ctx, "device_01", start.Add(2*time.Minute), check)
+	d = sample("low", 3*time.Minute)
+	d.DriverID = 8
+	if got, _ := tr.Observe(ctx, d, fatigue.Update{}); got.ID == s.ID {
+		t.Error("another driver's sample did not hand over an unpinned trip")
+	}
+}
@@ -0,0 +1,36 @@
+// Package storage keeps binary objects such as alert evidence behind a
+// backend-neutral interface
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+)
+
+// ErrNotFound is returned for keys with no stored object
+var ErrNotFound = errors.New("object not found")
+
+// Backend stores objects under slash-separated keys
+type Backend interface {
+	// Put stores size bytes read from r under key, replacing any object there
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Open returns the object stored under key and its size
+	Open(ctx context.Context, key string) (io.ReadCloser, int64, error)
+	// Delete removes the object under key; missing objects are not an error
+	Delete(ctx context.Context, key string) error
+}
+
+// validKey rejects keys that could escape the storage root
+func validKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return errors.New("invalid storage key")
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return errors.New("invalid storage key")
+		}
+	}
+	return nil
+}
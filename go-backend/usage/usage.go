@@ -0,0 +1,90 @@
+// Package usage counts the requests of each API version, so a deprecated
+// version can be retired once its last clients have moved on.
+package usage
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"driver-drowsiness-backend/models"
+)
+
+// MaxKeys bounds the distinct routes, clients and devices counted per
+// version; later ones are counted under Other
+const MaxKeys = 200
+
+// Other collects the requests past MaxKeys
+const Other = "(other)"
+
+// Tracker counts requests in memory; counts restart with the server
+type Tracker struct {
+	mu       sync.Mutex
+	since    time.Time
+	versions map[string]*models.APIVersionUsage
+}
+
+// NewTracker returns a tracker counting from since
+func NewTracker(since time.Time) *Tracker {
+	return &Tracker{since: since, versions: map[string]*models.APIVersionUsage{}}
+}
+
+// Observe counts one request of version on route by client (its
+// User-Agent); device is "" outside device routes
+func (t *Tracker) Observe(version, route, client, device string, at time.Time) {
+	if client == "" {
+		client = "unknown"
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	v := t.versions[version]
+	if v == nil {
+		v = &models.APIVersionUsage{
+			Version: version,
+			Routes:  map[string]int64{},
+			Clients: map[string]int64{},
+			Devices: map[string]int64{},
+		}
+		t.versions[version] = v
+	}
+	v.Requests++
+	if v.LastSeen == nil || at.After(*v.LastSeen) {
+		at := at.UTC()
+		v.LastSeen = &at
+	}
+	count(v.Routes, route)
+	count(v.Clients, client)
+	if device != "" {
+		count(v.Devices, device)
+	}
+}
+
+// count adds one to key, or to Other once m is full
+func count(m map[string]int64, key string) {
+	if _, ok := m[key]; !ok && len(m) >= MaxKeys {
+		key = Other
+	}
+	m[key]++
+}
+
+// Usage returns a copy of the counts, by version
+func (t *Tracker) Usage() models.APIUsage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	u := models.APIUsage{Since: t.since, Versions: []models.APIVersionUsage{}}
+	for _, v := range t.versions {
+		c := *v
+		c.Routes, c.Clients, c.Devices = clone(v.Routes), clone(v.Clients), clone(v.Devices)
+		u.Versions = append(u.Versions, c)
+	}
+	sort.Slice(u.Versions, func(i, j int) bool { return u.Versions[i].Version < u.Versions[j].Version })
+	return u
+}
+
+func clone(m map[string]int64) map[string]int64 {
+	c := make(map[string]int64, len(m))
+	for k, n := range m {
+		c[k] = n
+	}
+	return c
+}
@@ -0,0 +1,42 @@
+package usage
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestTracker(t *testing.T) {
+	start := time.Date(2025, 11, 9, 3, 30, 0, 0, time.UTC)
+	tr := NewTracker(start)
+	tr.Observe("v1", "POST /api/devices/:id/data", "python-requests/2.31", "pi-01", start.Add(time.Minute))
+	tr.Observe("v1", "POST /api/devices/:id/data", "python-requests/2.31", "pi-01", start)
+	tr.Observe("v1", "GET /api/admin/overview", "", "", start)
+	tr.Observe("v2", "GET /api/v2/devices", "Go-http-client/1.1", "", start.Add(2*time.Minute))
+
+	u := tr.Usage()
+	if !u.Since.Equal(start) || len(u.Versions) != 2 || u.Versions[0].Version != "v1" {
+		t.Fatalf("usage = %+v", u)
+	}
+	v1 := u.Versions[0]
+	if v1.Requests != 3 || !v1.LastSeen.Equal(start.Add(time.Minute)) {
+		t.Errorf("v1 = %+v", v1)
+	}
+	if v1.Routes["POST /api/devices/:id/data"] != 2 || v1.Clients["unknown"] != 1 || v1.Devices["pi-01"] != 2 || len(v1.Devices) != 1 {
+		t.Errorf("v1 counts = %+v", v1)
+	}
+
+	// The copy does not change with later requests
+	tr.Observe("v1", "GET /api/devices", "curl", "", start)
+	if v1.Requests != 3 || len(v1.Clients) != 2 {
+		t.Errorf("copy changed: %+v", v1)
+	}
+
+	for i := 0; i < MaxKeys+5; i++ {
+		tr.Observe("v2", "GET /api/v2/devices/:id/data", "sim", fmt.Sprintf("d%d", i), start)
+	}
+	v2 := tr.Usage().Versions[1]
+	if len(v2.Devices) != MaxKeys+1 || v2.Devices[Other] != 5 {
+		t.Errorf("v2 devices: %d keys, other %d", len(v2.Devices), v2.Devices[Other])
+	}
+}
@@ -0,0 +1,158 @@
+// Package validate holds the declarative rules of request payloads and
+// turns their failures into field errors in Thai or English
+package validate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"driver-drowsiness-backend/i18n"
+	"driver-drowsiness-backend/models"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Levels are the drowsiness levels a device may report
+var Levels = []string{"low", "medium", "high"}
+
+// Severities are the alert severities a device may report
+var Severities = []string{"info", "low", "medium", "high", "warning", "critical"}
+
+// MinPasswordLength is the shortest password the "password" rule accepts
+const MinPasswordLength = 8
+
+// Register adds the custom rules to v and names fields by their JSON name:
+//
+//	level     one of Levels, any case
+//	severity  one of Severities, any case
+//	password  MinPasswordLength characters with a letter and a digit
+func Register(v *validator.Validate) error {
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	for tag, fn := range map[string]validator.Func{
+		"level":    oneOfFold(Levels),
+		"severity": oneOfFold(Severities),
+		"password": password,
+	} {
+		if err := v.RegisterValidation(tag, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func oneOfFold(values []string) validator.Func {
+	return func(fl validator.FieldLevel) bool {
+		for _, v := range values {
+			if strings.EqualFold(fl.Field().String(), v) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func password(fl validator.FieldLevel) bool {
+	s := fl.Field().String()
+	var letter, digit bool
+	for _, r := range s {
+		letter = letter || unicode.IsLetter(r)
+		digit = digit || unicode.IsDigit(r)
+	}
+	return len([]rune(s)) >= MinPasswordLength && letter && digit
+}
+
+// messages are the field error texts by rule; %s is the rule parameter
+var messages = map[string]i18n.Label{
+	"required": {EN: "is required", TH: "จำเป็นต้องระบุ"},
+	"email":    {EN: "must be a valid email address", TH: "ต้องเป็นอีเมลที่ถูกต้อง"},
+	"oneof":    {EN: "must be one of %s", TH: "ต้องเป็นหนึ่งใน %s"},
+	"level":    {EN: "must be low, medium or high", TH: "ต้องเป็น low, medium หรือ high"},
+	"severity": {EN: "must be one of " + strings.Join(Severities, ", "), TH: "ต้องเป็นหนึ่งใน " + strings.Join(Severities, ", ")},
+	"password": {
+		EN: fmt.Sprintf("must be at least %d characters with a letter and a digit", MinPasswordLength),
+		TH: fmt.Sprintf("ต้องมีอย่างน้อย %d ตัวอักษร และมีทั้งตัวอักษรและตัวเลข", MinPasswordLength),
+	},
+	"min":        {EN: "must be at least %s", TH: "ต้องไม่น้อยกว่า %s"},
+	"max":        {EN: "must be at most %s", TH: "ต้องไม่เกิน %s"},
+	"min_length": {EN: "must be at least %s characters", TH: "ต้องมีอย่างน้อย %s ตัวอักษร"},
+	"max_length": {EN: "must be at most %s characters", TH: "ต้องไม่เกิน %s ตัวอักษร"},
+	"min_items":  {EN: "must have at least %s items", TH: "ต้องมีอย่างน้อย %s รายการ"},
+	"max_items":  {EN: "must have at most %s items", TH: "ต้องไม่เกิน %s รายการ"},
+	"type":       {EN: "must be of type %s", TH: "ต้องเป็นชนิด %s"},
+	"invalid":    {EN: "is invalid (%s)", TH: "ไม่ถูกต้อง (%s)"},
+}
+
+// message returns the text of rule in lang, prefixed with the field name
+func message(field, rule, param, lang string) string {
+	label, ok := messages[rule]
+	if !ok {
+		label, param = messages["invalid"], rule
+	}
+	text := label.In(lang)
+	if strings.Contains(text, "%s") {
+		text = fmt.Sprintf(text, strings.ReplaceAll(param, " ", ", "))
+	}
+	return field + " " + text
+}
+
+// jsonType names the JSON type a Go type is decoded from
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	case reflect.Ptr:
+		return jsonType(t.Elem())
+	}
+	return "number"
+}
+
+// Details turns a binding error into field errors in lang. It returns nil
+// for errors that are not about fields, such as malformed JSON.
+func Details(err error, lang string) []models.FieldError {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return []models.FieldError{{
+			Field: typeErr.Field, Rule: "type", Message: message(typeErr.Field, "type", jsonType(typeErr.Type), lang),
+		}}
+	}
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return nil
+	}
+	details := make([]models.FieldError, 0, len(verrs))
+	for _, fe := range verrs {
+		// The namespace starts with the Go name of the payload type
+		field := fe.Namespace()
+		if i := strings.IndexByte(field, '.'); i >= 0 {
+			field = field[i+1:]
+		}
+		rule := fe.Tag()
+		text := rule
+		if rule == "min" || rule == "max" {
+			switch fe.Kind() {
+			case reflect.String:
+				text += "_length"
+			case reflect.Slice, reflect.Array, reflect.Map:
+				text += "_items"
+			}
+		}
+		details = append(details, models.FieldError{Field: field, Rule: rule, Message: message(field, text, fe.Param(), lang)})
+	}
+	return details
+}
@@ -0,0 +1,55 @@
+package validate
+
+import (
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+)
+
+type payload struct {
+	Level    string   `json:"drowsiness_level" binding:"required,level"`
+	Password string   `json:"password" binding:"omitempty,password"`
+	Name     string   `json:"name" binding:"max=3"`
+	Tags     []string `json:"tags" binding:"max=1"`
+	Mode     string   `json:"mode" binding:"omitempty,oneof=fast slow"`
+}
+
+func TestDetails(t *testing.T) {
+	v := validator.New()
+	v.SetTagName("binding")
+	if err := Register(v); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := v.Struct(payload{Level: "High", Password: "secret123"}); err != nil {
+		t.Errorf("valid payload: %v", err)
+	}
+	for _, pw := range []string{"short1", "lettersonly", "12345678"} {
+		if err := v.Struct(payload{Level: "low", Password: pw}); err == nil {
+			t.Errorf("password %q accepted", pw)
+		}
+	}
+
+	err := v.Struct(payload{Level: "sleepy", Name: "Somchai", Tags: []string{"a", "b"}, Mode: "turbo"})
+	want := map[string]string{
+		"drowsiness_level": "drowsiness_level must be low, medium or high",
+		"name":             "name must be at most 3 characters",
+		"tags":             "tags must have at most 1 items",
+		"mode":             "mode must be one of fast, slow",
+	}
+	details := Details(err, "en")
+	if len(details) != len(want) {
+		t.Fatalf("details = %+v", details)
+	}
+	for _, d := range details {
+		if want[d.Field] != d.Message {
+			t.Errorf("%s: %q, want %q", d.Field, d.Message, want[d.Field])
+		}
+	}
+	if th := Details(err, "th"); th[1].Message != "name ต้องไม่เกิน 3 ตัวอักษร" {
+		t.Errorf("thai = %q", th[1].Message)
+	}
+	if Details(nil, "en") != nil {
+		t.Error("details of nil")
+	}
+}
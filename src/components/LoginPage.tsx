@@ -74,7 +74,7 @@ export function LoginPage({ onBack, onSwitchToSignup, onDriverDashboard, onMaste
       const data = await res.json();
       
       if (!res.ok) {
-        throw new Error(data.error || 'เกิดข้อผิดพลาด');
+        throw new Error(data.error?.details?.[0]?.message || data.error?.message || 'เกิดข้อผิดพลาด');
       }
       
       setForgotSuccess('รหัสยืนยันถูกส่งไปยังอีเมลของคุณแล้ว (สำหรับ demo: ' + data.code + ')');
@@ -95,8 +95,8 @@ export function LoginPage({ onBack, onSwitchToSignup, onDriverDashboard, onMaste
       return;
     }
     
-    if (newPassword.length < 6) {
-      setForgotError('รหัสผ่านต้องมีอย่างน้อย 6 ตัวอักษร');
+    if (newPassword.length < 8 || !/[A-Za-z]/.test(newPassword) || !/[0-9]/.test(newPassword)) {
+      setForgotError('รหัสผ่านต้องมีอย่างน้อย 8 ตัวอักษร และมีทั้งตัวอักษรและตัวเลข');
       return;
     }
     
@@ -115,7 +115,7 @@ export function LoginPage({ onBack, onSwitchToSignup, onDriverDashboard, onMaste
       const data = await res.json();
       
       if (!res.ok) {
-        throw new Error(data.error || 'เกิดข้อผิดพลาด');
+        throw new Error(data.error?.details?.[0]?.message || data.error?.message || 'เกิดข้อผิดพลาด');
       }
       
       setForgotPasswordMode('success');